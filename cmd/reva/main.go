@@ -87,6 +87,8 @@ var (
 		appTokensListCommand(),
 		appTokensRemoveCommand(),
 		appTokensCreateCommand(),
+		sessionsListCommand(),
+		sessionsRevokeCommand(),
 		setlockCommand(),
 		getlockCommand(),
 		unlockCommand(),
@@ -0,0 +1,83 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+func sessionsListCommand() *command {
+	cmd := newCommand("sessions-list")
+	cmd.Description = func() string { return "list the active sessions" }
+	cmd.Usage = func() string { return "Usage: sessions-list [-flags]" }
+	userFlag := cmd.String("user", "", "the opaque id of the user whose sessions to list, needs the permission to manage sessions")
+	idpFlag := cmd.String("idp", "", "the identity provider of the user")
+
+	cmd.ResetFlags = func() {
+		*userFlag, *idpFlag = "", ""
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		client, err := getSessionsClient()
+		if err != nil {
+			return err
+		}
+
+		ctx := getAuthContext()
+		res, err := client.ListSessions(ctx, &session.ListSessionsRequest{User: sessionsUser(*userFlag, *idpFlag)})
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(table.Row{"ID", "User", "Issued", "Expires"})
+		for _, s := range res.Sessions {
+			t.AppendRow(table.Row{s.ID, s.User.GetOpaqueId(), s.IssuedAt.Format(time.RFC3339), s.ExpiresAt.Format(time.RFC3339)})
+		}
+		t.Render()
+		return nil
+	}
+	return cmd
+}
+
+func getSessionsClient() (session.SessionsAPIClient, error) {
+	conn, err := getConn()
+	if err != nil {
+		return nil, err
+	}
+	return session.NewSessionsAPIClient(conn), nil
+}
+
+func sessionsUser(user, idp string) *userpb.UserId {
+	if user == "" {
+		return nil
+	}
+	return &userpb.UserId{OpaqueId: user, Idp: idp}
+}
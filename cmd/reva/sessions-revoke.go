@@ -0,0 +1,67 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/session"
+)
+
+func sessionsRevokeCommand() *command {
+	cmd := newCommand("sessions-revoke")
+	cmd.Description = func() string { return "revoke a session, or all the sessions of a user" }
+	cmd.Usage = func() string { return "Usage: sessions-revoke [-flags] [<session id>]" }
+	userFlag := cmd.String("user", "", "the opaque id of the user whose sessions to revoke, needs the permission to manage sessions")
+	idpFlag := cmd.String("idp", "", "the identity provider of the user")
+
+	cmd.ResetFlags = func() {
+		*userFlag, *idpFlag = "", ""
+	}
+
+	cmd.Action = func(w ...io.Writer) error {
+		if cmd.NArg() > 1 {
+			return errtypes.BadRequest("Invalid arguments: " + cmd.Usage())
+		}
+
+		client, err := getSessionsClient()
+		if err != nil {
+			return err
+		}
+
+		ctx := getAuthContext()
+		res, err := client.RevokeSessions(ctx, &session.RevokeSessionsRequest{
+			ID:   cmd.Arg(0),
+			User: sessionsUser(*userFlag, *idpFlag),
+		})
+		if err != nil {
+			return err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return formatError(res.Status)
+		}
+
+		fmt.Println("OK")
+		return nil
+	}
+	return cmd
+}
@@ -20,13 +20,21 @@ package main
 
 import (
 	"encoding/gob"
+	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	txdriver "github.com/cs3org/reva/pkg/datatx"
 	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
 )
 
 func transferListCommand() *command {
@@ -34,6 +42,9 @@ func transferListCommand() *command {
 	cmd.Description = func() string { return "get a list of transfers" }
 	cmd.Usage = func() string { return "Usage: transfer-list [-flags]" }
 	filterShareID := cmd.String("shareId", "", "share ID filter (optional)")
+	filterStatus := cmd.String("status", "", "status filter, e.g. complete or STATUS_TRANSFER_FAILED (optional)")
+	filterFrom := cmd.String("from", "", "only transfers created after this time, in RFC3339 format (optional)")
+	filterTo := cmd.String("to", "", "only transfers created before this time, in RFC3339 format (optional)")
 
 	cmd.Action = func(w ...io.Writer) error {
 		ctx := getAuthContext()
@@ -55,8 +66,37 @@ func transferListCommand() *command {
 			})
 		}
 
+		if *filterStatus != "" {
+			status, err := parseTransferStatus(*filterStatus)
+			if err != nil {
+				return err
+			}
+			filters = append(filters, &datatx.ListTransfersRequest_Filter{
+				Type: datatx.ListTransfersRequest_Filter_TYPE_STATUS,
+				Term: &datatx.ListTransfersRequest_Filter_Status{
+					Status: status,
+				},
+			})
+		}
+
+		opaque := &types.Opaque{Map: map[string]*types.OpaqueEntry{}}
+		for key, value := range map[string]string{"ctime_from": *filterFrom, "ctime_to": *filterTo} {
+			if value == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return errors.Wrap(err, "invalid time: "+value)
+			}
+			opaque.Map[key] = &types.OpaqueEntry{
+				Decoder: "plain",
+				Value:   []byte(strconv.FormatInt(t.Unix(), 10)),
+			}
+		}
+
 		transferslistRequest := &datatx.ListTransfersRequest{
 			Filters: filters,
+			Opaque:  opaque,
 		}
 
 		listTransfersResponse, err := client.ListTransfers(ctx, transferslistRequest)
@@ -70,11 +110,16 @@ func transferListCommand() *command {
 		if len(w) == 0 {
 			t := table.NewWriter()
 			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId"})
+			t.AppendHeader(table.Row{"ShareId.OpaqueId", "Id.OpaqueId", "Status", "Ctime", "Progress"})
 
+			progress := map[string]*txdriver.Progress{}
+			if err := readTransferProgress(listTransfersResponse.Opaque, &progress); err != nil {
+				return err
+			}
 			for _, s := range listTransfersResponse.Transfers {
+				cTime := time.Unix(int64(s.GetCtime().GetSeconds()), int64(s.GetCtime().GetNanos()))
 				t.AppendRows([]table.Row{
-					{s.ShareId.OpaqueId, s.Id.OpaqueId},
+					{s.GetShareId().GetOpaqueId(), s.Id.OpaqueId, s.Status, cTime.Format("Mon Jan 2 15:04:05 -0700 MST 2006"), formatTransferProgress(progress[s.Id.OpaqueId])},
 				})
 			}
 			t.Render()
@@ -89,3 +134,33 @@ func transferListCommand() *command {
 	}
 	return cmd
 }
+
+// parseTransferStatus parses a transfer status, either by its full name or without its prefix.
+func parseTransferStatus(s string) (datatx.Status, error) {
+	s = strings.ToUpper(s)
+	for _, name := range []string{s, "STATUS_TRANSFER_" + s, "STATUS_" + s} {
+		if v, ok := datatx.Status_value[name]; ok {
+			return datatx.Status(v), nil
+		}
+	}
+	return datatx.Status_STATUS_INVALID, errors.New("invalid transfer status: " + s)
+}
+
+// readTransferProgress decodes the progress reported in the opaque of the datatx responses.
+func readTransferProgress(opaque *types.Opaque, progress interface{}) error {
+	entry, ok := opaque.GetMap()["progress"]
+	if !ok {
+		return nil
+	}
+	return errors.Wrap(json.Unmarshal(entry.Value, progress), "error decoding transfer progress")
+}
+
+func formatTransferProgress(p *txdriver.Progress) string {
+	if p == nil {
+		return ""
+	}
+	if p.ETA < 0 {
+		return fmt.Sprintf("%.1f%% of %d bytes", p.Percentage, p.BytesTotal)
+	}
+	return fmt.Sprintf("%.1f%% of %d bytes, %v left", p.Percentage, p.BytesTotal, time.Duration(p.ETA)*time.Second)
+}
@@ -43,6 +43,7 @@ type Watcher struct {
 	ss        map[string]Server
 	pidFile   string
 	childPIDs []int
+	hooks     []func()
 }
 
 // Option represent an option.
@@ -254,10 +255,21 @@ type Server interface {
 	Address() string
 }
 
+// OnShutdown registers a function called before stopping the servers.
+func (w *Watcher) OnShutdown(f func()) {
+	w.hooks = append(w.hooks, f)
+}
+
+func (w *Watcher) shutdown() {
+	for _, f := range w.hooks {
+		f()
+	}
+}
+
 // TrapSignals captures the OS signal.
 func (w *Watcher) TrapSignals() {
 	signalCh := make(chan os.Signal, 1024)
-	signal.Notify(signalCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+	signal.Notify(signalCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
 	for {
 		s := <-signalCh
 		w.log.Info().Msgf("%v signal received", s)
@@ -278,6 +290,7 @@ func (w *Watcher) TrapSignals() {
 
 		case syscall.SIGQUIT:
 			w.log.Info().Msg("preparing for a graceful shutdown with deadline of 10 seconds")
+			w.shutdown()
 			go func() {
 				count := 10
 				ticker := time.NewTicker(time.Second)
@@ -310,6 +323,7 @@ func (w *Watcher) TrapSignals() {
 			w.Exit(0)
 		case syscall.SIGINT, syscall.SIGTERM:
 			w.log.Info().Msg("preparing for hard shutdown, aborting all conns")
+			w.shutdown()
 			for _, s := range w.ss {
 				w.log.Info().Msgf("fd to %s:%s abruptly closed", s.Network(), s.Address())
 				err := s.Stop()
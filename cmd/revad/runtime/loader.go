@@ -34,6 +34,7 @@ import (
 	_ "github.com/cs3org/reva/pkg/auth/registry/loader"
 	_ "github.com/cs3org/reva/pkg/cbox/loader"
 	_ "github.com/cs3org/reva/pkg/datatx/manager/loader"
+	_ "github.com/cs3org/reva/pkg/events/stream/loader"
 	_ "github.com/cs3org/reva/pkg/group/manager/loader"
 	_ "github.com/cs3org/reva/pkg/metrics/driver/loader"
 	_ "github.com/cs3org/reva/pkg/ocm/invite/manager/loader"
@@ -42,7 +43,10 @@ import (
 	_ "github.com/cs3org/reva/pkg/permission/manager/loader"
 	_ "github.com/cs3org/reva/pkg/preferences/loader"
 	_ "github.com/cs3org/reva/pkg/publicshare/manager/loader"
+	_ "github.com/cs3org/reva/pkg/ratelimit/loader"
+	_ "github.com/cs3org/reva/pkg/registry/loader"
 	_ "github.com/cs3org/reva/pkg/rhttp/datatx/manager/loader"
+	_ "github.com/cs3org/reva/pkg/search/loader"
 	_ "github.com/cs3org/reva/pkg/share/cache/loader"
 	_ "github.com/cs3org/reva/pkg/share/cache/warmup/loader"
 	_ "github.com/cs3org/reva/pkg/share/manager/loader"
@@ -50,5 +54,6 @@ import (
 	_ "github.com/cs3org/reva/pkg/storage/fs/loader"
 	_ "github.com/cs3org/reva/pkg/storage/registry/loader"
 	_ "github.com/cs3org/reva/pkg/token/manager/loader"
+	_ "github.com/cs3org/reva/pkg/token/session/loader"
 	_ "github.com/cs3org/reva/pkg/user/manager/loader"
 )
@@ -0,0 +1,165 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cs3org/reva/pkg/registry"
+	"github.com/cs3org/reva/pkg/registry/memory"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+type registryConf struct {
+	// Driver is the registry backend, the process registers its grpc services in it when set.
+	Driver    string                            `mapstructure:"driver"`
+	Drivers   map[string]map[string]interface{} `mapstructure:"drivers"`
+	Advertise string                            `mapstructure:"advertise"`
+	Heartbeat int                               `mapstructure:"heartbeat"`
+	Metadata  map[string]map[string]string      `mapstructure:"metadata"`
+	// Services are registered statically.
+	Services map[string]interface{} `mapstructure:"services"`
+}
+
+func (c *registryConf) init() {
+	if c.Advertise == "" {
+		c.Advertise, _ = os.Hostname()
+	}
+	if c.Heartbeat == 0 {
+		c.Heartbeat = 10
+	}
+}
+
+func parseRegistryConf(v interface{}) (*registryConf, error) {
+	c := &registryConf{}
+	if err := mapstructure.Decode(v, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding registry config")
+	}
+	c.init()
+	return c, nil
+}
+
+// initRegistry sets up the global registry from the configuration,
+// adding the services declared statically.
+func initRegistry(c *registryConf) error {
+	if c.Driver != "" {
+		r, err := registry.New(c.Driver, c.Drivers[c.Driver])
+		if err != nil {
+			return err
+		}
+		utils.GlobalRegistry = r
+	}
+
+	for sName, instances := range c.Services {
+		list, ok := instances.([]interface{})
+		if !ok {
+			return fmt.Errorf("invalid registry config for service %s", sName)
+		}
+		for _, instance := range list {
+			if err := utils.GlobalRegistry.Add(memory.NewService(sName, instance.(map[string]interface{})["nodes"].([]interface{}))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// registrar keeps the grpc services of the process registered,
+// adding them again at every heartbeat for them not to expire.
+type registrar struct {
+	services []registry.Service
+	interval time.Duration
+	log      *zerolog.Logger
+	done     chan struct{}
+}
+
+// newRegistrar returns a registrar for the grpc services of the process,
+// reachable at the advertised host on the port of the grpc server.
+func newRegistrar(c *registryConf, mainConf map[string]interface{}, address string, log *zerolog.Logger) (*registrar, error) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing the grpc address")
+	}
+	addr := net.JoinHostPort(c.Advertise, port)
+	// the pid tells apart the processes of a hot reload
+	id := fmt.Sprintf("%s-%d", addr, os.Getpid())
+
+	names := []string{}
+	if services, ok := mainConf["grpc"].(map[string]interface{})["services"].(map[string]interface{}); ok {
+		for name := range services {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	r := &registrar{
+		interval: time.Duration(c.Heartbeat) * time.Second,
+		log:      log,
+		done:     make(chan struct{}),
+	}
+	for _, name := range names {
+		r.services = append(r.services, registry.NewService(name, registry.NewNode(id, addr, c.Metadata[name])))
+	}
+	return r, nil
+}
+
+func (r *registrar) add() {
+	for _, s := range r.services {
+		if err := utils.GlobalRegistry.Add(s); err != nil {
+			r.log.Error().Err(err).Str("service", s.Name()).Msg("error registering service")
+		}
+	}
+}
+
+// start registers the services and keeps them registered until stopped.
+func (r *registrar) start() {
+	r.add()
+	for _, s := range r.services {
+		r.log.Info().Str("service", s.Name()).Str("address", s.Nodes()[0].Address()).Msg("service registered")
+	}
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				r.add()
+			}
+		}
+	}()
+}
+
+// stop deregisters the services, for the clients to stop calling them.
+func (r *registrar) stop() {
+	close(r.done)
+	for _, s := range r.services {
+		if err := utils.GlobalRegistry.Remove(s); err != nil {
+			r.log.Error().Err(err).Str("service", s.Name()).Msg("error deregistering service")
+		}
+	}
+}
@@ -29,8 +29,8 @@ import (
 	"strings"
 
 	"github.com/cs3org/reva/cmd/revad/internal/grace"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/logger"
-	"github.com/cs3org/reva/pkg/registry/memory"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rhttp"
 	"github.com/cs3org/reva/pkg/sharedconf"
@@ -54,22 +54,15 @@ func RunWithOptions(mainConf map[string]interface{}, pidFile string, opts ...Opt
 	parseSharedConfOrDie(mainConf["shared"])
 	coreConf := parseCoreConfOrDie(mainConf["core"])
 
-	// TODO: one can pass the options from the config file to registry.New() and initialize a registry based upon config files.
+	regConf := parseRegistryConfOrDie(mainConf["registry"])
 	if options.Registry != nil {
 		utils.GlobalRegistry = options.Registry
-	} else if _, ok := mainConf["registry"]; ok {
-		for _, services := range mainConf["registry"].(map[string]interface{}) {
-			for sName, nodes := range services.(map[string]interface{}) {
-				for _, instance := range nodes.([]interface{}) {
-					if err := utils.GlobalRegistry.Add(memory.NewService(sName, instance.(map[string]interface{})["nodes"].([]interface{}))); err != nil {
-						panic(err)
-					}
-				}
-			}
-		}
+	} else if err := initRegistry(regConf); err != nil {
+		fmt.Fprintf(os.Stderr, "error initializing the registry: %s\n", err.Error())
+		os.Exit(1)
 	}
 
-	run(mainConf, coreConf, options.Logger, pidFile)
+	run(mainConf, coreConf, regConf, options.Logger, pidFile)
 }
 
 type coreConf struct {
@@ -81,9 +74,12 @@ type coreConf struct {
 
 	// TracingService specifies the service. i.e OpenCensus, OpenTelemetry, OpenTracing...
 	TracingService string `mapstructure:"tracing_service"`
+
+	// HealthAddress is the address of the internal listener serving the probes.
+	HealthAddress string `mapstructure:"health_address"`
 }
 
-func run(mainConf map[string]interface{}, coreConf *coreConf, logger *zerolog.Logger, filename string) {
+func run(mainConf map[string]interface{}, coreConf *coreConf, regConf *registryConf, logger *zerolog.Logger, filename string) {
 	host, _ := os.Hostname()
 	logger.Info().Msgf("host info: %s", host)
 
@@ -92,14 +88,14 @@ func run(mainConf map[string]interface{}, coreConf *coreConf, logger *zerolog.Lo
 	}
 	initCPUCount(coreConf, logger)
 
-	servers := initServers(mainConf, logger)
+	servers := initServers(mainConf, coreConf, logger)
 	watcher, err := initWatcher(logger, filename)
 	if err != nil {
 		log.Panic(err)
 	}
 	listeners := initListeners(watcher, servers, logger)
 
-	start(mainConf, servers, listeners, logger, watcher)
+	start(mainConf, regConf, servers, listeners, logger, watcher)
 }
 
 func initListeners(watcher *grace.Watcher, servers map[string]grace.Server, log *zerolog.Logger) map[string]net.Listener {
@@ -121,7 +117,7 @@ func initWatcher(log *zerolog.Logger, filename string) (*grace.Watcher, error) {
 	return watcher, err
 }
 
-func initServers(mainConf map[string]interface{}, log *zerolog.Logger) map[string]grace.Server {
+func initServers(mainConf map[string]interface{}, coreConf *coreConf, log *zerolog.Logger) map[string]grace.Server {
 	servers := map[string]grace.Server{}
 	if isEnabledHTTP(mainConf) {
 		s, err := getHTTPServer(mainConf["http"], log)
@@ -145,6 +141,10 @@ func initServers(mainConf map[string]interface{}, log *zerolog.Logger) map[strin
 		log.Info().Msg("nothing to do, no grpc/http enabled_services declared in config")
 		os.Exit(1)
 	}
+
+	if coreConf.HealthAddress != "" {
+		servers["health"] = health.NewServer(coreConf.HealthAddress, log.With().Str("pkg", "health").Logger())
+	}
 	return servers
 }
 
@@ -184,7 +184,7 @@ func handlePIDFlag(l *zerolog.Logger, pidFile string) (*grace.Watcher, error) {
 	return w, nil
 }
 
-func start(mainConf map[string]interface{}, servers map[string]grace.Server, listeners map[string]net.Listener, log *zerolog.Logger, watcher *grace.Watcher) {
+func start(mainConf map[string]interface{}, regConf *registryConf, servers map[string]grace.Server, listeners map[string]net.Listener, log *zerolog.Logger, watcher *grace.Watcher) {
 	if isEnabledHTTP(mainConf) {
 		go func() {
 			if err := servers["http"].(*rhttp.Server).Start(listeners["http"]); err != nil {
@@ -200,6 +200,24 @@ func start(mainConf map[string]interface{}, servers map[string]grace.Server, lis
 				watcher.Exit(1)
 			}
 		}()
+		if regConf.Driver != "" {
+			r, err := newRegistrar(regConf, mainConf, servers["grpc"].Address(), log)
+			if err != nil {
+				log.Error().Err(err).Msg("error registering the grpc services")
+				watcher.Exit(1)
+			}
+			r.start()
+			watcher.OnShutdown(r.stop)
+		}
+	}
+	if s, ok := servers["health"].(*health.Server); ok {
+		go func() {
+			log.Info().Msgf("health probes listening at %s", s.Address())
+			if err := s.Start(listeners["health"]); err != nil {
+				log.Error().Err(err).Msg("error starting the health server")
+				watcher.Exit(1)
+			}
+		}()
 	}
 	watcher.TrapSignals()
 }
@@ -324,6 +342,15 @@ func parseCoreConfOrDie(v interface{}) *coreConf {
 	return c
 }
 
+func parseRegistryConfOrDie(v interface{}) *registryConf {
+	c, err := parseRegistryConf(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err.Error())
+		os.Exit(1)
+	}
+	return c
+}
+
 func parseSharedConfOrDie(v interface{}) {
 	if err := sharedconf.Decode(v); err != nil {
 		fmt.Fprintf(os.Stderr, "error decoding shared config: %s\n", err.Error())
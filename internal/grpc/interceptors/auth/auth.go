@@ -34,6 +34,8 @@ import (
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/token"
 	tokenmgr "github.com/cs3org/reva/pkg/token/manager/registry"
+	"github.com/cs3org/reva/pkg/token/session"
+	sessionregistry "github.com/cs3org/reva/pkg/token/session/registry"
 	"github.com/cs3org/reva/pkg/user"
 	"github.com/cs3org/reva/pkg/utils"
 	"github.com/mitchellh/mapstructure"
@@ -52,6 +54,10 @@ type config struct {
 	TokenManager  string                            `mapstructure:"token_manager"`
 	TokenManagers map[string]map[string]interface{} `mapstructure:"token_managers"`
 	GatewayAddr   string                            `mapstructure:"gateway_addr"`
+	// SessionStore holds the revoked tokens, which are refused, and records the tokens minted here.
+	// Revocation is disabled if empty.
+	SessionStore  string                            `mapstructure:"session_store"`
+	SessionStores map[string]map[string]interface{} `mapstructure:"session_stores"`
 	blockedUsers  []string
 }
 
@@ -94,6 +100,13 @@ func NewUnary(m map[string]interface{}, unprotected []string) (grpc.UnaryServerI
 		return nil, errors.Wrap(err, "auth: error creating token manager")
 	}
 
+	sessions, err := sessionregistry.GetStore(conf.SessionStore, conf.SessionStores)
+	if err != nil {
+		return nil, errors.Wrap(err, "auth: error creating session store")
+	}
+	// the tokens minted to expand the scopes are sessions as well
+	tokenManager = session.NewRecordingManager(tokenManager, sessions)
+
 	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		log := appctx.GetLogger(ctx)
 
@@ -104,7 +117,7 @@ func NewUnary(m map[string]interface{}, unprotected []string) (grpc.UnaryServerI
 			// to decide the storage provider.
 			tkn, ok := ctxpkg.ContextGetToken(ctx)
 			if ok {
-				u, scopes, err := dismantleToken(ctx, tkn, req, tokenManager, conf.GatewayAddr, true)
+				u, scopes, err := dismantleToken(ctx, tkn, req, tokenManager, sessions, conf.GatewayAddr, true)
 				if err == nil {
 					if blockedUsers.IsBlocked(u.Username) {
 						return nil, status.Errorf(codes.PermissionDenied, "user %s blocked", u.Username)
@@ -124,7 +137,7 @@ func NewUnary(m map[string]interface{}, unprotected []string) (grpc.UnaryServerI
 		}
 
 		// scopes, validate the token and ensure access to the resource is allowed
-		u, scopes, err := dismantleToken(ctx, tkn, req, tokenManager, conf.GatewayAddr, false)
+		u, scopes, err := dismantleToken(ctx, tkn, req, tokenManager, sessions, conf.GatewayAddr, false)
 		if err != nil {
 			log.Warn().Err(err).Msg("access token is invalid")
 			return nil, status.Errorf(codes.PermissionDenied, "auth: core access token is invalid")
@@ -166,6 +179,13 @@ func NewStream(m map[string]interface{}, unprotected []string) (grpc.StreamServe
 		return nil, errtypes.NotFound("auth: token manager not found: " + conf.TokenManager)
 	}
 
+	sessions, err := sessionregistry.GetStore(conf.SessionStore, conf.SessionStores)
+	if err != nil {
+		return nil, errors.Wrap(err, "auth: error creating session store")
+	}
+	// the tokens minted to expand the scopes are sessions as well
+	tokenManager = session.NewRecordingManager(tokenManager, sessions)
+
 	interceptor := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		ctx := ss.Context()
 		log := appctx.GetLogger(ctx)
@@ -177,7 +197,7 @@ func NewStream(m map[string]interface{}, unprotected []string) (grpc.StreamServe
 			// to decide the storage provider.
 			tkn, ok := ctxpkg.ContextGetToken(ctx)
 			if ok {
-				u, scopes, err := dismantleToken(ctx, tkn, ss, tokenManager, conf.GatewayAddr, true)
+				u, scopes, err := dismantleToken(ctx, tkn, ss, tokenManager, sessions, conf.GatewayAddr, true)
 				if err == nil {
 					ctx = ctxpkg.ContextSetUser(ctx, u)
 					ctx = ctxpkg.ContextSetScopes(ctx, scopes)
@@ -196,7 +216,7 @@ func NewStream(m map[string]interface{}, unprotected []string) (grpc.StreamServe
 		}
 
 		// validate the token and ensure access to the resource is allowed
-		u, scopes, err := dismantleToken(ctx, tkn, ss, tokenManager, conf.GatewayAddr, false)
+		u, scopes, err := dismantleToken(ctx, tkn, ss, tokenManager, sessions, conf.GatewayAddr, false)
 		if err != nil {
 			log.Warn().Err(err).Msg("access token is invalid")
 			return status.Errorf(codes.PermissionDenied, "auth: core access token is invalid")
@@ -224,12 +244,16 @@ func (ss *wrappedServerStream) Context() context.Context {
 	return ss.newCtx
 }
 
-func dismantleToken(ctx context.Context, tkn string, req interface{}, mgr token.Manager, gatewayAddr string, unprotected bool) (*userpb.User, map[string]*authpb.Scope, error) {
+func dismantleToken(ctx context.Context, tkn string, req interface{}, mgr token.Manager, sessions session.Store, gatewayAddr string, unprotected bool) (*userpb.User, map[string]*authpb.Scope, error) {
 	u, tokenScope, err := mgr.DismantleToken(ctx, tkn)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if err := session.Check(ctx, sessions, mgr, tkn); err != nil {
+		return nil, nil, err
+	}
+
 	if unprotected {
 		return u, nil, nil
 	}
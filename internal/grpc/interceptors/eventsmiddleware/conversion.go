@@ -19,13 +19,20 @@
 package eventsmiddleware
 
 import (
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/utils"
 )
 
 // ShareCreated converts response to event.
-func ShareCreated(r *collaboration.CreateShareResponse) events.ShareCreated {
+func ShareCreated(r *collaboration.CreateShareResponse, executant *user.UserId) events.ShareCreated {
 	e := events.ShareCreated{
+		Executant:      executant,
 		Sharer:         r.Share.Creator,
 		GranteeUserID:  r.Share.GetGrantee().GetUserId(),
 		GranteeGroupID: r.Share.GetGrantee().GetGroupId(),
@@ -35,3 +42,229 @@ func ShareCreated(r *collaboration.CreateShareResponse) events.ShareCreated {
 
 	return e
 }
+
+// ShareRemoved converts response and request to event.
+func ShareRemoved(r *collaboration.RemoveShareResponse, req *collaboration.RemoveShareRequest, executant *user.UserId) events.ShareRemoved {
+	return events.ShareRemoved{
+		Executant: executant,
+		ShareID:   req.Ref.GetId(),
+		ShareKey:  req.Ref.GetKey(),
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// ShareUpdated converts response to event.
+func ShareUpdated(r *collaboration.UpdateShareResponse, executant *user.UserId) events.ShareUpdated {
+	return events.ShareUpdated{
+		Executant:      executant,
+		ShareID:        r.Share.Id,
+		ItemID:         r.Share.ResourceId,
+		Permissions:    r.Share.Permissions,
+		GranteeUserID:  r.Share.GetGrantee().GetUserId(),
+		GranteeGroupID: r.Share.GetGrantee().GetGroupId(),
+		Sharer:         r.Share.Creator,
+		MTime:          r.Share.Mtime,
+		Timestamp:      utils.TSNow(),
+	}
+}
+
+// ReceivedShareUpdated converts response to event.
+func ReceivedShareUpdated(r *collaboration.UpdateReceivedShareResponse, executant *user.UserId) events.ReceivedShareUpdated {
+	return events.ReceivedShareUpdated{
+		Executant:      executant,
+		ShareID:        r.Share.Share.Id,
+		ItemID:         r.Share.Share.ResourceId,
+		GranteeUserID:  r.Share.Share.GetGrantee().GetUserId(),
+		GranteeGroupID: r.Share.Share.GetGrantee().GetGroupId(),
+		Sharer:         r.Share.Share.Creator,
+		State:          r.Share.State.String(),
+		Timestamp:      utils.TSNow(),
+	}
+}
+
+// LinkCreated converts response to event.
+func LinkCreated(r *link.CreatePublicShareResponse, executant *user.UserId) events.LinkCreated {
+	return events.LinkCreated{
+		Executant:         executant,
+		ShareID:           r.Share.Id,
+		Sharer:            r.Share.Creator,
+		ItemID:            r.Share.ResourceId,
+		Permissions:       r.Share.Permissions,
+		DisplayName:       r.Share.DisplayName,
+		Expiration:        r.Share.Expiration,
+		PasswordProtected: r.Share.PasswordProtected,
+		CTime:             r.Share.Ctime,
+		Token:             r.Share.Token,
+		Timestamp:         utils.TSNow(),
+	}
+}
+
+// LinkUpdated converts response to event.
+func LinkUpdated(r *link.UpdatePublicShareResponse, req *link.UpdatePublicShareRequest, executant *user.UserId) events.LinkUpdated {
+	return events.LinkUpdated{
+		Executant:         executant,
+		ShareID:           r.Share.Id,
+		Sharer:            r.Share.Creator,
+		ItemID:            r.Share.ResourceId,
+		Permissions:       r.Share.Permissions,
+		DisplayName:       r.Share.DisplayName,
+		Expiration:        r.Share.Expiration,
+		PasswordProtected: r.Share.PasswordProtected,
+		MTime:             r.Share.Mtime,
+		Token:             r.Share.Token,
+		FieldUpdated:      req.GetUpdate().GetType().String(),
+		Timestamp:         utils.TSNow(),
+	}
+}
+
+// LinkRemoved converts response and request to event.
+func LinkRemoved(r *link.RemovePublicShareResponse, req *link.RemovePublicShareRequest, executant *user.UserId) events.LinkRemoved {
+	return events.LinkRemoved{
+		Executant:  executant,
+		ShareID:    req.Ref.GetId(),
+		ShareToken: req.Ref.GetToken(),
+		ItemID:     resourceIDFromOpaque(r.Opaque),
+		Timestamp:  utils.TSNow(),
+	}
+}
+
+// OCMShareCreated converts response to event.
+func OCMShareCreated(r *ocm.CreateOCMShareResponse, executant *user.UserId) events.OCMShareCreated {
+	return events.OCMShareCreated{
+		Executant:      executant,
+		ShareID:        r.Share.Id,
+		Sharer:         r.Share.Creator,
+		GranteeUserID:  r.Share.GetGrantee().GetUserId(),
+		GranteeGroupID: r.Share.GetGrantee().GetGroupId(),
+		ItemID:         r.Share.ResourceId,
+		Permissions:    r.Share.Permissions,
+		CTime:          r.Share.Ctime,
+		Timestamp:      utils.TSNow(),
+	}
+}
+
+// FileDownloaded converts response and request to event.
+func FileDownloaded(r *provider.InitiateFileDownloadResponse, req *provider.InitiateFileDownloadRequest, executant *user.UserId) events.FileDownloaded {
+	return events.FileDownloaded{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// ItemTrashed converts response and request to event.
+func ItemTrashed(r *provider.DeleteResponse, req *provider.DeleteRequest, executant *user.UserId) events.ItemTrashed {
+	return events.ItemTrashed{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// ItemMoved converts response and request to event.
+func ItemMoved(r *provider.MoveResponse, req *provider.MoveRequest, executant *user.UserId) events.ItemMoved {
+	return events.ItemMoved{
+		Executant:    executant,
+		Ref:          req.Destination,
+		OldReference: req.Source,
+		ItemID:       resourceIDFromOpaque(r.Opaque),
+		Timestamp:    utils.TSNow(),
+	}
+}
+
+// ItemRestored converts request to event.
+func ItemRestored(req *provider.RestoreRecycleItemRequest, executant *user.UserId) events.ItemRestored {
+	return events.ItemRestored{
+		Executant:  executant,
+		Ref:        req.Ref,
+		Key:        req.Key,
+		RestoreRef: req.RestoreRef,
+		Timestamp:  utils.TSNow(),
+	}
+}
+
+// FileVersionRestored converts response and request to event.
+func FileVersionRestored(r *provider.RestoreFileVersionResponse, req *provider.RestoreFileVersionRequest, executant *user.UserId) events.FileVersionRestored {
+	return events.FileVersionRestored{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Key:       req.Key,
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// SpaceCreated converts response to event.
+func SpaceCreated(r *provider.CreateStorageSpaceResponse, executant *user.UserId) events.SpaceCreated {
+	s := r.StorageSpace
+	return events.SpaceCreated{
+		Executant: executant,
+		ID:        s.Id,
+		Owner:     s.Owner.GetId(),
+		Root:      s.Root,
+		Name:      s.Name,
+		Type:      s.SpaceType,
+		Quota:     s.Quota,
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// SpaceUpdated converts response to event.
+func SpaceUpdated(r *provider.UpdateStorageSpaceResponse, executant *user.UserId) events.SpaceUpdated {
+	s := r.StorageSpace
+	return events.SpaceUpdated{
+		Executant: executant,
+		ID:        s.Id,
+		Owner:     s.Owner.GetId(),
+		Root:      s.Root,
+		Name:      s.Name,
+		Type:      s.SpaceType,
+		Quota:     s.Quota,
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// FileLocked converts response and request to event.
+func FileLocked(r *provider.SetLockResponse, req *provider.SetLockRequest, executant *user.UserId) events.FileLocked {
+	return events.FileLocked{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Lock:      req.Lock,
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// LockRefreshed converts response and request to event.
+func LockRefreshed(r *provider.RefreshLockResponse, req *provider.RefreshLockRequest, executant *user.UserId) events.LockRefreshed {
+	return events.LockRefreshed{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Lock:      req.Lock,
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// FileUnlocked converts response and request to event.
+func FileUnlocked(r *provider.UnlockResponse, req *provider.UnlockRequest, executant *user.UserId) events.FileUnlocked {
+	return events.FileUnlocked{
+		Executant: executant,
+		Ref:       req.Ref,
+		ItemID:    resourceIDFromOpaque(r.Opaque),
+		Timestamp: utils.TSNow(),
+	}
+}
+
+// resourceIDFromOpaque reads the id of the affected resource which the providers
+// add to the responses of operations after which the resource can't be looked up anymore.
+func resourceIDFromOpaque(o *types.Opaque) *provider.ResourceId {
+	var id *provider.ResourceId
+	if err := utils.ReadJSONFromOpaque(o, "resourceid", &id); err != nil {
+		return nil
+	}
+	return id
+}
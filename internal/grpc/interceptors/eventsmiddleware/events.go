@@ -22,10 +22,15 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/asim/go-micro/plugins/events/nats/v4"
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/events"
-	"github.com/cs3org/reva/pkg/events/server"
+	"github.com/cs3org/reva/pkg/events/stream/registry"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"go-micro.dev/v4/util/log"
 	"google.golang.org/grpc"
@@ -39,10 +44,7 @@ func init() {
 	rgrpc.RegisterUnaryInterceptor("eventsmiddleware", NewUnary)
 }
 
-// NewUnary returns a new unary interceptor that emits events when needed
-// no lint because of the switch statement that should be extendable
-//
-
+// NewUnary returns a new unary interceptor that emits events when needed.
 func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
 	publisher, err := publisherFromConfig(m)
 	if err != nil {
@@ -55,12 +57,85 @@ func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error
 			return res, err
 		}
 
-		var ev interface{}
+		var executantID *user.UserId
+		if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+			executantID = u.Id
+		}
 
-		// gocritic is disabled because the use of .(type) outside type switch is forbidden
-		switch v := res.(type) { //nolint:gocritic
+		var ev interface{}
+		switch v := res.(type) {
 		case *collaboration.CreateShareResponse:
-			ev = ShareCreated(v)
+			if isSuccess(v) {
+				ev = ShareCreated(v, executantID)
+			}
+		case *collaboration.RemoveShareResponse:
+			if isSuccess(v) {
+				ev = ShareRemoved(v, req.(*collaboration.RemoveShareRequest), executantID)
+			}
+		case *collaboration.UpdateShareResponse:
+			if isSuccess(v) {
+				ev = ShareUpdated(v, executantID)
+			}
+		case *collaboration.UpdateReceivedShareResponse:
+			if isSuccess(v) {
+				ev = ReceivedShareUpdated(v, executantID)
+			}
+		case *link.CreatePublicShareResponse:
+			if isSuccess(v) {
+				ev = LinkCreated(v, executantID)
+			}
+		case *link.UpdatePublicShareResponse:
+			if isSuccess(v) {
+				ev = LinkUpdated(v, req.(*link.UpdatePublicShareRequest), executantID)
+			}
+		case *link.RemovePublicShareResponse:
+			if isSuccess(v) {
+				ev = LinkRemoved(v, req.(*link.RemovePublicShareRequest), executantID)
+			}
+		case *ocm.CreateOCMShareResponse:
+			if isSuccess(v) {
+				ev = OCMShareCreated(v, executantID)
+			}
+		case *provider.InitiateFileDownloadResponse:
+			if isSuccess(v) {
+				ev = FileDownloaded(v, req.(*provider.InitiateFileDownloadRequest), executantID)
+			}
+		case *provider.DeleteResponse:
+			if isSuccess(v) {
+				ev = ItemTrashed(v, req.(*provider.DeleteRequest), executantID)
+			}
+		case *provider.MoveResponse:
+			if isSuccess(v) {
+				ev = ItemMoved(v, req.(*provider.MoveRequest), executantID)
+			}
+		case *provider.RestoreRecycleItemResponse:
+			if isSuccess(v) {
+				ev = ItemRestored(req.(*provider.RestoreRecycleItemRequest), executantID)
+			}
+		case *provider.RestoreFileVersionResponse:
+			if isSuccess(v) {
+				ev = FileVersionRestored(v, req.(*provider.RestoreFileVersionRequest), executantID)
+			}
+		case *provider.CreateStorageSpaceResponse:
+			if isSuccess(v) {
+				ev = SpaceCreated(v, executantID)
+			}
+		case *provider.UpdateStorageSpaceResponse:
+			if isSuccess(v) {
+				ev = SpaceUpdated(v, executantID)
+			}
+		case *provider.SetLockResponse:
+			if isSuccess(v) {
+				ev = FileLocked(v, req.(*provider.SetLockRequest), executantID)
+			}
+		case *provider.RefreshLockResponse:
+			if isSuccess(v) {
+				ev = LockRefreshed(v, req.(*provider.RefreshLockRequest), executantID)
+			}
+		case *provider.UnlockResponse:
+			if isSuccess(v) {
+				ev = FileUnlocked(v, req.(*provider.UnlockRequest), executantID)
+			}
 		}
 
 		if ev != nil {
@@ -74,6 +149,15 @@ func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error
 	return interceptor, defaultPriority, nil
 }
 
+// su is a response that carries a status.
+type su interface {
+	GetStatus() *rpc.Status
+}
+
+func isSuccess(res su) bool {
+	return res.GetStatus().GetCode() == rpc.Code_CODE_OK
+}
+
 // NewStream returns a new server stream interceptor
 // that creates the application context.
 func NewStream() grpc.StreamServerInterceptor {
@@ -85,13 +169,10 @@ func NewStream() grpc.StreamServerInterceptor {
 }
 
 func publisherFromConfig(m map[string]interface{}) (events.Publisher, error) {
-	typ := m["type"].(string)
-	switch typ {
-	default:
+	typ, _ := m["type"].(string)
+	f, ok := registry.NewFuncs[typ]
+	if !ok {
 		return nil, fmt.Errorf("stream type '%s' not supported", typ)
-	case "nats":
-		address := m["address"].(string)
-		cid := m["clusterID"].(string)
-		return server.NewNatsStream(nats.Address(address), nats.ClusterID(cid))
 	}
+	return f(m)
 }
@@ -21,6 +21,8 @@ package loader
 import (
 	// Load core GRPC services.
 	_ "github.com/cs3org/reva/internal/grpc/interceptors/eventsmiddleware"
+	_ "github.com/cs3org/reva/internal/grpc/interceptors/ratelimit"
 	_ "github.com/cs3org/reva/internal/grpc/interceptors/readonly"
+	_ "github.com/cs3org/reva/internal/grpc/interceptors/spiffe"
 	// Add your own service here.
 )
@@ -0,0 +1,262 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package ratelimit rejects the grpc calls of the clients
+// exceeding the configured rates.
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/ratelimit"
+	"github.com/cs3org/reva/pkg/ratelimit/registry"
+	"github.com/cs3org/reva/pkg/rgrpc"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	defaultPriority = 150
+)
+
+func init() {
+	rgrpc.RegisterUnaryInterceptor("ratelimit", NewUnary)
+	rgrpc.RegisterStreamInterceptor("ratelimit", NewStream)
+	rgrpc.RegisterPreAuthUnaryInterceptor("ratelimit", NewFailedAuthUnary)
+	rgrpc.RegisterPreAuthStreamInterceptor("ratelimit", NewFailedAuthStream)
+}
+
+type config struct {
+	Store      string                            `mapstructure:"store" docs:"memory;The store keeping the counters, redis to share them between the replicas."`
+	Stores     map[string]map[string]interface{} `mapstructure:"stores" docs:"url:pkg/ratelimit/redis/redis.go"`
+	Rules      []*ratelimit.Rule                 `mapstructure:"rules" docs:"url:pkg/ratelimit/ratelimit.go"`
+	FailedAuth *ratelimit.Rule                   `mapstructure:"failed_auth" docs:";The rate and the burst of the calls failing the authentication allowed per ip. They are not limited when unset."`
+	Priority   int                               `mapstructure:"priority" docs:"150;The priority of the interceptor."`
+}
+
+func (c *config) init() {
+	if c.Store == "" {
+		c.Store = "memory"
+	}
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "ratelimit: error decoding conf")
+	}
+	c.init()
+	return c, nil
+}
+
+func newLimiter(m map[string]interface{}) (*ratelimit.Limiter, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	store, err := registry.GetStore(c.Store, c.Stores)
+	if err != nil {
+		return nil, 0, err
+	}
+	l, err := ratelimit.NewLimiter(c.Rules, store)
+	if err != nil {
+		return nil, 0, err
+	}
+	return l, c.Priority, nil
+}
+
+// newFailedAuth returns the limiter of the failed authentications, nil if they are not limited.
+func newFailedAuth(m map[string]interface{}) (*ratelimit.FailedAuth, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.FailedAuth == nil {
+		return nil, 0, nil
+	}
+
+	store, err := registry.GetStore(c.Store, c.Stores)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := ratelimit.NewFailedAuth(c.FailedAuth, store)
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, c.Priority, nil
+}
+
+// request describes the call for the limiter.
+func request(ctx context.Context, method string) *ratelimit.Request {
+	req := &ratelimit.Request{Method: method, IP: peerIP(ctx)}
+	req.User, _ = ctxpkg.ContextGetUser(ctx)
+	req.Credential, _ = ctxpkg.ContextGetToken(ctx)
+	return req
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// allow returns the error to reject the call with, if it exceeds the limits.
+// The calls are let through when the store cannot be reached.
+func allow(ctx context.Context, l *ratelimit.Limiter, method string, setHeader func(metadata.MD) error) error {
+	ok, wait, err := l.Allow(ctx, request(ctx, method))
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Str("method", method).Msg("ratelimit: error checking the limits, allowing the call")
+		return nil
+	}
+	if ok {
+		return nil
+	}
+
+	return tooManyRequests(ctx, method, wait, setHeader)
+}
+
+// tooManyRequests returns the error to reject the call with,
+// telling the client how long to wait.
+func tooManyRequests(ctx context.Context, method string, wait time.Duration, setHeader func(metadata.MD) error) error {
+	appctx.GetLogger(ctx).Warn().Str("method", method).Dur("retry_after", wait).Msg("ratelimit: too many requests")
+	retryAfter := ratelimit.RetryAfter(wait)
+	_ = setHeader(metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+	st := status.Newf(codes.ResourceExhausted, "ratelimit: too many requests, retry in %d seconds", retryAfter)
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(retryAfter) * time.Second)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// NewUnary returns a new unary interceptor that rejects
+// the calls exceeding the configured rates.
+func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
+	l, prio, err := newLimiter(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := allow(ctx, l, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}, prio, nil
+}
+
+// NewStream returns a new stream interceptor that rejects
+// the calls exceeding the configured rates.
+func NewStream(m map[string]interface{}) (grpc.StreamServerInterceptor, int, error) {
+	l, prio, err := newLimiter(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), l, info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}, prio, nil
+}
+
+// allowFailedAuth returns the error to reject the call with,
+// if the client failed to authenticate too often.
+func allowFailedAuth(ctx context.Context, f *ratelimit.FailedAuth, method string, setHeader func(metadata.MD) error) error {
+	ok, wait, err := f.Allow(ctx, peerIP(ctx))
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Str("method", method).Msg("ratelimit: error checking the failed authentications, allowing the call")
+		return nil
+	}
+	if ok {
+		return nil
+	}
+	return tooManyRequests(ctx, method, wait, setHeader)
+}
+
+// countFailedAuth counts the call as a failed authentication if it was rejected by the auth
+// interceptor, or if the response has an unauthenticated status, e.g. a failed login.
+func countFailedAuth(ctx context.Context, f *ratelimit.FailedAuth, method string, res interface{}, err error) {
+	failed := status.Code(err) == codes.Unauthenticated || status.Code(err) == codes.PermissionDenied
+	if r, ok := res.(interface{ GetStatus() *rpc.Status }); ok && err == nil {
+		failed = r.GetStatus().GetCode() == rpc.Code_CODE_UNAUTHENTICATED
+	}
+	if !failed {
+		return
+	}
+	if err := f.Fail(ctx, peerIP(ctx)); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Str("method", method).Msg("ratelimit: error counting the failed authentication")
+	}
+}
+
+// NewFailedAuthUnary returns a new unary interceptor, chained before the auth, that rejects
+// the calls of the clients which failed to authenticate too often. It returns no interceptor
+// when the failed authentications are not limited.
+func NewFailedAuthUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
+	f, prio, err := newFailedAuth(m)
+	if err != nil || f == nil {
+		return nil, 0, err
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := allowFailedAuth(ctx, f, info.FullMethod, func(md metadata.MD) error { return grpc.SetHeader(ctx, md) }); err != nil {
+			return nil, err
+		}
+		res, err := handler(ctx, req)
+		countFailedAuth(ctx, f, info.FullMethod, res, err)
+		return res, err
+	}, prio, nil
+}
+
+// NewFailedAuthStream returns a new stream interceptor, chained before the auth, that rejects
+// the calls of the clients which failed to authenticate too often. It returns no interceptor
+// when the failed authentications are not limited.
+func NewFailedAuthStream(m map[string]interface{}) (grpc.StreamServerInterceptor, int, error) {
+	f, prio, err := newFailedAuth(m)
+	if err != nil || f == nil {
+		return nil, 0, err
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allowFailedAuth(ss.Context(), f, info.FullMethod, ss.SetHeader); err != nil {
+			return err
+		}
+		err := handler(srv, ss)
+		countFailedAuth(ss.Context(), f, info.FullMethod, nil, err)
+		return err
+	}, prio, nil
+}
@@ -20,12 +20,20 @@ package readonly
 
 import (
 	"context"
+	"time"
 
+	"github.com/bluele/gcache"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/maintenance"
 	"github.com/cs3org/reva/pkg/rgrpc"
-	rstatus "github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -33,20 +41,114 @@ import (
 
 const (
 	defaultPriority = 200
+	groupsTTL       = 5 * time.Minute
 )
 
 func init() {
 	rgrpc.RegisterUnaryInterceptor("readonly", NewUnary)
 }
 
-// NewUnary returns a new unary interceptor
-// that checks grpc calls and blocks write requests.
-func NewUnary(map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
+type config struct {
+	maintenance.Config `mapstructure:",squash"`
+	// Name identifies the read-only mode in the process, to toggle it at runtime.
+	Name       string `mapstructure:"name" docs:"default;The name of the read-only mode, used to toggle it through the maintenance service."`
+	GatewaySVC string `mapstructure:"gatewaysvc" docs:";The gateway used to resolve the paths of the references by id and the groups missing from the tokens."`
+}
+
+func (c *config) init() {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+	c.GatewaySVC = sharedconf.GetGatewaySVC(c.GatewaySVC)
+}
+
+// resolver looks up the paths and the groups through the gateway.
+type resolver struct {
+	gatewaySVC string
+	groups     gcache.Cache
+}
+
+func (r *resolver) GetPath(ctx context.Context, id *provider.ResourceId) (string, error) {
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(r.gatewaySVC))
+	if err != nil {
+		return "", errors.Wrap(err, "readonly: error getting gateway client")
+	}
+	res, err := client.GetPath(ctx, &provider.GetPathRequest{ResourceId: id})
+	if err != nil {
+		return "", errors.Wrap(err, "readonly: error calling GetPath")
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return "", errors.New("readonly: error getting path: " + res.Status.Message)
+	}
+	return res.Path, nil
+}
+
+func (r *resolver) GetUserGroups(ctx context.Context, u *userpb.User) ([]string, error) {
+	if groups, err := r.groups.Get(u.Id.GetOpaqueId()); err == nil {
+		return groups.([]string), nil
+	}
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(r.gatewaySVC))
+	if err != nil {
+		return nil, errors.Wrap(err, "readonly: error getting gateway client")
+	}
+	res, err := client.GetUserGroups(ctx, &userpb.GetUserGroupsRequest{UserId: u.Id})
+	if err != nil {
+		return nil, errors.Wrap(err, "readonly: error calling GetUserGroups")
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, errors.New("readonly: error getting user groups: " + res.Status.Message)
+	}
+	_ = r.groups.SetWithExpire(u.Id.GetOpaqueId(), res.Groups, groupsTTL)
+	return res.Groups, nil
+}
+
+// refs returns the references the request writes to.
+func refs(req interface{}) []*provider.Reference {
+	switch r := req.(type) {
+	case *provider.MoveRequest:
+		return []*provider.Reference{r.Source, r.Destination}
+	case *provider.RestoreRecycleItemRequest:
+		return []*provider.Reference{r.Ref, r.RestoreRef}
+	case interface{ GetRef() *provider.Reference }:
+		return []*provider.Reference{r.GetRef()}
+	}
+	return nil
+}
+
+// readOnly uses the existing PermissionsSet and changes the writes to false.
+func readOnly(ps *provider.ResourcePermissions) {
+	ps.AddGrant = false
+	ps.CreateContainer = false
+	ps.Delete = false
+	ps.InitiateFileUpload = false
+	ps.Move = false
+	ps.RemoveGrant = false
+	ps.PurgeRecycle = false
+	ps.RestoreFileVersion = false
+	ps.RestoreRecycleItem = false
+	ps.UpdateGrant = false
+}
+
+// NewUnary returns a new unary interceptor that checks grpc calls and blocks
+// write requests, to the whole server or to the configured storages, spaces
+// and paths, unless the read-only mode is disabled or the user exempted.
+func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, 0, errors.Wrap(err, "readonly: error decoding conf")
+	}
+	c.init()
+	mode := maintenance.Register(c.Name, &c.Config, &resolver{
+		gatewaySVC: c.GatewaySVC,
+		groups:     gcache.New(10000).LFU().Build(),
+	})
+
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		log := appctx.GetLogger(ctx)
 
 		switch req.(type) {
-		// handle known non-write request types
+		// handle known non-write request types, the lookups of the
+		// mode included
 		case *provider.GetHomeRequest,
 			*provider.GetPathRequest,
 			*provider.GetQuotaRequest,
@@ -54,96 +156,120 @@ func NewUnary(map[string]interface{}) (grpc.UnaryServerInterceptor, int, error)
 			*provider.InitiateFileDownloadRequest,
 			*provider.ListFileVersionsRequest,
 			*provider.ListGrantsRequest,
-			*provider.ListRecycleRequest:
+			*provider.ListRecycleRequest,
+			*userpb.GetUserGroupsRequest:
 			return handler(ctx, req)
 		case *provider.ListContainerRequest:
 			resp, err := handler(ctx, req)
 			if listResp, ok := resp.(*provider.ListContainerResponse); ok && listResp.Infos != nil {
 				for _, info := range listResp.Infos {
-					// use the existing PermissionsSet and change the writes to false
-					if info.PermissionSet != nil {
-						info.PermissionSet.AddGrant = false
-						info.PermissionSet.CreateContainer = false
-						info.PermissionSet.Delete = false
-						info.PermissionSet.InitiateFileUpload = false
-						info.PermissionSet.Move = false
-						info.PermissionSet.RemoveGrant = false
-						info.PermissionSet.PurgeRecycle = false
-						info.PermissionSet.RestoreFileVersion = false
-						info.PermissionSet.RestoreRecycleItem = false
-						info.PermissionSet.UpdateGrant = false
+					if info.PermissionSet != nil && mode.BlocksResource(ctx, info) {
+						readOnly(info.PermissionSet)
 					}
 				}
 			}
 			return resp, err
 		case *provider.StatRequest:
 			resp, err := handler(ctx, req)
-			if statResp, ok := resp.(*provider.StatResponse); ok && statResp.Info != nil && statResp.Info.PermissionSet != nil {
-				// use the existing PermissionsSet and change the writes to false
-				statResp.Info.PermissionSet.AddGrant = false
-				statResp.Info.PermissionSet.CreateContainer = false
-				statResp.Info.PermissionSet.Delete = false
-				statResp.Info.PermissionSet.InitiateFileUpload = false
-				statResp.Info.PermissionSet.Move = false
-				statResp.Info.PermissionSet.RemoveGrant = false
-				statResp.Info.PermissionSet.PurgeRecycle = false
-				statResp.Info.PermissionSet.RestoreFileVersion = false
-				statResp.Info.PermissionSet.RestoreRecycleItem = false
-				statResp.Info.PermissionSet.UpdateGrant = false
+			if statResp, ok := resp.(*provider.StatResponse); ok && statResp.Info != nil && statResp.Info.PermissionSet != nil && mode.BlocksResource(ctx, statResp.Info) {
+				readOnly(statResp.Info.PermissionSet)
 			}
 			return resp, err
+		}
+
+		if !mode.Blocks(ctx, refs(req)...) {
+			return handler(ctx, req)
+		}
+
+		switch req.(type) {
 		// Don't allow the following requests types
 		case *provider.AddGrantRequest:
 			return &provider.AddGrantResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to add grant on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to add grant on readonly storage"),
 			}, nil
 		case *provider.CreateContainerRequest:
 			return &provider.CreateContainerResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to create resource on read-only storage"),
+				Status: mode.Status(ctx, "permission denied: tried to create resource on read-only storage"),
 			}, nil
 		case *provider.TouchFileRequest:
 			return &provider.TouchFileResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to create resource on read-only storage"),
+				Status: mode.Status(ctx, "permission denied: tried to create resource on read-only storage"),
 			}, nil
 		case *provider.CreateHomeRequest:
 			return &provider.CreateHomeResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to create home on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to create home on readonly storage"),
 			}, nil
 		case *provider.DeleteRequest:
 			return &provider.DeleteResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to delete resource on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to delete resource on readonly storage"),
 			}, nil
 		case *provider.InitiateFileUploadRequest:
 			return &provider.InitiateFileUploadResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to upload resource on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to upload resource on readonly storage"),
 			}, nil
 		case *provider.MoveRequest:
 			return &provider.MoveResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to move resource on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to move resource on readonly storage"),
 			}, nil
 		case *provider.PurgeRecycleRequest:
 			return &provider.PurgeRecycleResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to purge recycle on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to purge recycle on readonly storage"),
 			}, nil
 		case *provider.RemoveGrantRequest:
 			return &provider.RemoveGrantResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to remove grant on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to remove grant on readonly storage"),
 			}, nil
 		case *provider.RestoreRecycleItemRequest:
 			return &provider.RestoreRecycleItemResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to restore recycle item on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to restore recycle item on readonly storage"),
 			}, nil
 		case *provider.SetArbitraryMetadataRequest:
 			return &provider.SetArbitraryMetadataResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to set arbitrary metadata on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to set arbitrary metadata on readonly storage"),
 			}, nil
 		case *provider.UnsetArbitraryMetadataRequest:
 			return &provider.UnsetArbitraryMetadataResponse{
-				Status: rstatus.NewPermissionDenied(ctx, nil, "permission denied: tried to unset arbitrary metadata on readonly storage"),
+				Status: mode.Status(ctx, "permission denied: tried to unset arbitrary metadata on readonly storage"),
+			}, nil
+		case *provider.UpdateGrantRequest:
+			return &provider.UpdateGrantResponse{
+				Status: mode.Status(ctx, "permission denied: tried to update grant on readonly storage"),
+			}, nil
+		case *provider.DenyGrantRequest:
+			return &provider.DenyGrantResponse{
+				Status: mode.Status(ctx, "permission denied: tried to deny grant on readonly storage"),
+			}, nil
+		case *provider.RestoreFileVersionRequest:
+			return &provider.RestoreFileVersionResponse{
+				Status: mode.Status(ctx, "permission denied: tried to restore file version on readonly storage"),
+			}, nil
+		case *provider.CreateReferenceRequest:
+			return &provider.CreateReferenceResponse{
+				Status: mode.Status(ctx, "permission denied: tried to create reference on readonly storage"),
+			}, nil
+		case *provider.CreateSymlinkRequest:
+			return &provider.CreateSymlinkResponse{
+				Status: mode.Status(ctx, "permission denied: tried to create symlink on readonly storage"),
+			}, nil
+		case *provider.SetLockRequest:
+			return &provider.SetLockResponse{
+				Status: mode.Status(ctx, "permission denied: tried to lock resource on readonly storage"),
+			}, nil
+		case *provider.RefreshLockRequest:
+			return &provider.RefreshLockResponse{
+				Status: mode.Status(ctx, "permission denied: tried to refresh lock on readonly storage"),
+			}, nil
+		case *provider.UnlockRequest:
+			return &provider.UnlockResponse{
+				Status: mode.Status(ctx, "permission denied: tried to unlock resource on readonly storage"),
 			}, nil
 		// block unknown request types and return error
 		default:
 			log.Debug().Msg("storage is readonly")
+			if st := mode.State(); st.Maintenance {
+				maintenance.SetRetryAfter(ctx, st.RetryAfter)
+				return nil, status.Errorf(codes.Unavailable, "%s: tried to execute an unknown operation: %T", st.Message, req)
+			}
 			return nil, status.Errorf(codes.PermissionDenied, "permission denied: tried to execute an unknown operation: %T!", req)
 		}
 	}, defaultPriority, nil
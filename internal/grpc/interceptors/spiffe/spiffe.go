@@ -0,0 +1,113 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package spiffe rejects the grpc calls of the peers whose SPIFFE ID,
+// presented in their client certificate, is not allowed.
+package spiffe
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc"
+	"github.com/cs3org/reva/pkg/rgrpc/mtls"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPriority = 100
+)
+
+func init() {
+	rgrpc.RegisterUnaryInterceptor("spiffe", NewUnary)
+	rgrpc.RegisterStreamInterceptor("spiffe", NewStream)
+}
+
+type config struct {
+	AllowedIDs  []string `mapstructure:"allowed_ids" docs:";The SPIFFE IDs allowed to call the services, as patterns such as spiffe://example.org/reva/*."`
+	Unprotected []string `mapstructure:"unprotected" docs:";The prefixes of the methods callable by any peer, e.g. /grpc.health.v1.Health/."`
+	Priority    int      `mapstructure:"priority" docs:"100;The priority of the interceptor."`
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "spiffe: error decoding conf")
+	}
+	if len(c.AllowedIDs) == 0 {
+		return nil, errors.New("spiffe: no allowed ids configured")
+	}
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+	return c, nil
+}
+
+// check verifies the identity of the peer calling the method.
+func (c *config) check(ctx context.Context, method string) error {
+	for _, prefix := range c.Unprotected {
+		if strings.HasPrefix(method, prefix) {
+			return nil
+		}
+	}
+
+	id, ok := mtls.PeerID(ctx)
+	if !ok {
+		return status.Errorf(codes.Unauthenticated, "spiffe: peer without a verified spiffe id")
+	}
+	if !mtls.MatchID(id, c.AllowedIDs) {
+		appctx.GetLogger(ctx).Warn().Str("peer", id).Str("method", method).Msg("spiffe: peer not allowed")
+		return status.Errorf(codes.PermissionDenied, "spiffe: peer %s not allowed", id)
+	}
+	return nil
+}
+
+// NewUnary returns a new unary interceptor that only lets
+// the allowed peers call the services.
+func NewUnary(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := c.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}, c.Priority, nil
+}
+
+// NewStream returns a new stream interceptor that only lets
+// the allowed peers call the services.
+func NewStream(m map[string]interface{}) (grpc.StreamServerInterceptor, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := c.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}, c.Priority, nil
+}
@@ -21,16 +21,22 @@ package datatx
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"sync"
 
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
 	txdriver "github.com/cs3org/reva/pkg/datatx"
 	txregistry "github.com/cs3org/reva/pkg/datatx/manager/registry"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/events"
+	streamregistry "github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/mitchellh/mapstructure"
@@ -51,12 +57,16 @@ type config struct {
 	StorageDrivers      map[string]map[string]interface{} `mapstructure:"storage_drivers"`
 	TxSharesFile        string                            `mapstructure:"tx_shares_file"`
 	DataTransfersFolder string                            `mapstructure:"data_transfers_folder"`
+	// event stream to publish the end of the transfers to
+	EventStream  string                            `mapstructure:"event_stream" docs:";The event stream to publish transfer events to. Events are disabled if empty."`
+	EventStreams map[string]map[string]interface{} `mapstructure:"event_streams" docs:"url:pkg/events/stream/nats/nats.go;The configuration for the event streams"`
 }
 
 type service struct {
 	conf          *config
 	txManager     txdriver.Manager
 	txShareDriver *txShareDriver
+	publisher     events.Publisher
 }
 
 type txShareDriver struct {
@@ -91,13 +101,23 @@ func (s *service) Register(ss *grpc.Server) {
 	datatx.RegisterTxAPIServer(ss, s)
 }
 
-func getDatatxManager(c *config) (txdriver.Manager, error) {
+func getDatatxManager(c *config, publisher events.Publisher) (txdriver.Manager, error) {
 	if f, ok := txregistry.NewFuncs[c.TxDriver]; ok {
-		return f(c.TxDrivers[c.TxDriver])
+		return f(c.TxDrivers[c.TxDriver], publisher)
 	}
 	return nil, errtypes.NotFound("datatx service: driver not found: " + c.TxDriver)
 }
 
+func getPublisher(c *config) (events.Publisher, error) {
+	if c.EventStream == "" {
+		return nil, nil
+	}
+	if f, ok := streamregistry.NewFuncs[c.EventStream]; ok {
+		return f(c.EventStreams[c.EventStream])
+	}
+	return nil, errtypes.NotFound("datatx service: event stream not found: " + c.EventStream)
+}
+
 func parseConfig(m map[string]interface{}) (*config, error) {
 	c := &config{}
 	if err := mapstructure.Decode(m, c); err != nil {
@@ -115,7 +135,12 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 	}
 	c.init()
 
-	txManager, err := getDatatxManager(c)
+	publisher, err := getPublisher(c)
+	if err != nil {
+		return nil, err
+	}
+
+	txManager, err := getDatatxManager(c, publisher)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +158,7 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		conf:          c,
 		txManager:     txManager,
 		txShareDriver: txShareDriver,
+		publisher:     publisher,
 	}
 
 	return service, nil
@@ -146,6 +172,14 @@ func (s *service) UnprotectedEndpoints() []string {
 	return []string{}
 }
 
+// Ready tells whether the transfer driver and the event stream are ready, when they can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.All(ctx, []health.Named{
+		{Name: "transfer driver", Checker: s.txManager},
+		{Name: "event stream", Checker: s.publisher},
+	})
+}
+
 func (s *service) PullTransfer(ctx context.Context, req *datatx.PullTransferRequest) (*datatx.PullTransferResponse, error) {
 	txInfo, startTransferErr := s.txManager.CreateTransfer(ctx, req.SrcTargetUri, req.DestTargetUri)
 
@@ -200,9 +234,22 @@ func (s *service) GetTransferStatus(ctx context.Context, req *datatx.GetTransfer
 
 	txInfo.ShareId = &ocm.ShareId{OpaqueId: string(txShare.Opaque.Map["shareId"].Value)}
 
+	var opaque *types.Opaque
+	if progress, err := s.txManager.GetTransfersProgress(ctx, []string{req.GetTxId().OpaqueId}); err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Str("transfer", req.GetTxId().OpaqueId).Msg("datatx service: error getting transfer progress")
+	} else if p, ok := progress[req.GetTxId().OpaqueId]; ok {
+		if opaque, err = progressOpaque(p); err != nil {
+			return &datatx.GetTransferStatusResponse{
+				Status: status.NewInternal(ctx, err, "datatx service: error encoding transfer progress"),
+				TxInfo: txInfo,
+			}, nil
+		}
+	}
+
 	return &datatx.GetTransferStatusResponse{
 		Status: status.NewOK(ctx),
 		TxInfo: txInfo,
+		Opaque: opaque,
 	}, nil
 }
 
@@ -230,32 +277,109 @@ func (s *service) CancelTransfer(ctx context.Context, req *datatx.CancelTransfer
 	}, nil
 }
 
+// ListTransfers lists the transfers of the user matching the filters. Besides the filters
+// of the request, the creation time of the transfers can be restricted with the "ctime_from"
+// and "ctime_to" opaque entries, in seconds since the epoch. The progress of the transfers is
+// returned in the opaque, under the "progress" key.
 func (s *service) ListTransfers(ctx context.Context, req *datatx.ListTransfersRequest) (*datatx.ListTransfersResponse, error) {
-	filters := req.Filters
-	var txInfos []*datatx.TxInfo
-	for _, txShare := range s.txShareDriver.model.TxShares {
-		if len(filters) == 0 {
-			txInfos = append(txInfos, &datatx.TxInfo{
-				Id:      &datatx.TxId{OpaqueId: txShare.TxID},
-				ShareId: &ocm.ShareId{OpaqueId: string(txShare.Opaque.Map["shareId"].Value)},
-			})
-		} else {
-			for _, f := range filters {
-				if f.Type == datatx.ListTransfersRequest_Filter_TYPE_SHARE_ID {
-					if f.GetShareId().GetOpaqueId() == string(txShare.Opaque.Map["shareId"].Value) {
-						txInfos = append(txInfos, &datatx.TxInfo{
-							Id:      &datatx.TxId{OpaqueId: txShare.TxID},
-							ShareId: &ocm.ShareId{OpaqueId: string(txShare.Opaque.Map["shareId"].Value)},
-						})
-					}
-				}
+	from, to, err := ctimeRange(req.Opaque)
+	if err != nil {
+		return &datatx.ListTransfersResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+
+	txInfos, err := s.txManager.ListTransfers(ctx, req.Filters)
+	if err != nil {
+		err = errors.Wrap(err, "datatx service: error listing transfers")
+		return &datatx.ListTransfersResponse{
+			Status: status.NewInternal(ctx, err, "datatx service: error listing transfers"),
+		}, nil
+	}
+
+	var transfers []*datatx.TxInfo
+	var ids []string
+	for _, txInfo := range txInfos {
+		if txShare, ok := s.txShareDriver.model.TxShares[txInfo.Id.OpaqueId]; ok && txShare.Opaque != nil {
+			if shareID, ok := txShare.Opaque.Map["shareId"]; ok {
+				txInfo.ShareId = &ocm.ShareId{OpaqueId: string(shareID.Value)}
 			}
 		}
+		if !matchesShareFilters(txInfo, req.Filters) {
+			continue
+		}
+		if ctime := txInfo.GetCtime().GetSeconds(); ctime < from || ctime > to {
+			continue
+		}
+		transfers = append(transfers, txInfo)
+		ids = append(ids, txInfo.Id.OpaqueId)
+	}
+
+	progress, err := s.txManager.GetTransfersProgress(ctx, ids)
+	if err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Msg("datatx service: error getting transfers progress")
+		progress = map[string]*txdriver.Progress{}
+	}
+
+	opaque, err := progressOpaque(progress)
+	if err != nil {
+		return &datatx.ListTransfersResponse{
+			Status: status.NewInternal(ctx, err, "datatx service: error encoding transfer progress"),
+		}, nil
 	}
 
 	return &datatx.ListTransfersResponse{
 		Status:    status.NewOK(ctx),
-		Transfers: txInfos,
+		Transfers: transfers,
+		Opaque:    opaque,
+	}, nil
+}
+
+// matchesShareFilters tells whether the transfer matches one of the share id filters, if any.
+func matchesShareFilters(txInfo *datatx.TxInfo, filters []*datatx.ListTransfersRequest_Filter) bool {
+	matches := true
+	for _, f := range filters {
+		if f.Type != datatx.ListTransfersRequest_Filter_TYPE_SHARE_ID {
+			continue
+		}
+		if f.GetShareId().GetOpaqueId() == txInfo.GetShareId().GetOpaqueId() {
+			return true
+		}
+		matches = false
+	}
+	return matches
+}
+
+// ctimeRange returns the creation time range of the transfers to list from the opaque.
+func ctimeRange(opaque *types.Opaque) (uint64, uint64, error) {
+	from, to := uint64(0), uint64(1<<64-1)
+	for key, bound := range map[string]*uint64{"ctime_from": &from, "ctime_to": &to} {
+		entry, ok := opaque.GetMap()[key]
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseUint(string(entry.Value), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("datatx service: invalid %s: %s", key, entry.Value)
+		}
+		*bound = v
+	}
+	return from, to, nil
+}
+
+// progressOpaque returns an opaque holding the progress of the transfers.
+func progressOpaque(progress interface{}) (*types.Opaque, error) {
+	val, err := json.Marshal(progress)
+	if err != nil {
+		return nil, err
+	}
+	return &types.Opaque{
+		Map: map[string]*types.OpaqueEntry{
+			"progress": {
+				Decoder: "json",
+				Value:   val,
+			},
+		},
 	}, nil
 }
 
@@ -22,15 +22,19 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ReneKroon/ttlcache/v2"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rgrpc"
+	"github.com/cs3org/reva/pkg/search"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/token"
 	"github.com/cs3org/reva/pkg/token/manager/registry"
+	"github.com/cs3org/reva/pkg/token/session"
+	sessionregistry "github.com/cs3org/reva/pkg/token/session/registry"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -71,6 +75,14 @@ type config struct {
 	EtagCacheTTL        int                               `mapstructure:"etag_cache_ttl"`
 	AllowedUserAgents   map[string][]string               `mapstructure:"allowed_user_agents"` // map[path][]user-agent
 	CreateHomeCacheTTL  int                               `mapstructure:"create_home_cache_ttl"`
+	// SessionStore records the minted tokens so that they can be listed and revoked, disabled if empty.
+	SessionStore  string                            `mapstructure:"session_store"`
+	SessionStores map[string]map[string]interface{} `mapstructure:"session_stores"`
+	// SearchIndexDriver is the search index kept current with the changes passing the gateway.
+	SearchIndexDriver     string                            `mapstructure:"search_index_driver"`
+	SearchIndexDrivers    map[string]map[string]interface{} `mapstructure:"search_index_drivers"`
+	SearchMaxResults      int                               `mapstructure:"search_max_results"`
+	DisableSearchBackfill bool                              `mapstructure:"disable_search_backfill"`
 }
 
 // sets defaults.
@@ -89,6 +101,14 @@ func (c *config) init() {
 		c.TokenManager = "jwt"
 	}
 
+	if c.SearchIndexDriver == "" {
+		c.SearchIndexDriver = "memory"
+	}
+
+	if c.SearchMaxResults <= 0 {
+		c.SearchMaxResults = 100
+	}
+
 	// if services address are not specified we used the shared conf
 	// for the gatewaysvc to have dev setups very quickly.
 	c.AuthRegistryEndpoint = sharedconf.GetGatewaySVC(c.AuthRegistryEndpoint)
@@ -118,11 +138,15 @@ func (c *config) init() {
 }
 
 type svc struct {
-	c               *config
-	dataGatewayURL  url.URL
-	tokenmgr        token.Manager
-	etagCache       *ttlcache.Cache `mapstructure:"etag_cache"`
-	createHomeCache *ttlcache.Cache `mapstructure:"create_home_cache"`
+	c              *config
+	dataGatewayURL url.URL
+	tokenmgr       token.Manager
+	sessions       session.Store
+	searchIndex    search.Index
+	// searchBackfilled holds the users whose spaces have been walked to backfill the search index
+	searchBackfilled sync.Map
+	etagCache        *ttlcache.Cache `mapstructure:"etag_cache"`
+	createHomeCache  *ttlcache.Cache `mapstructure:"create_home_cache"`
 }
 
 // New creates a new gateway svc that acts as a proxy for any grpc operation.
@@ -147,6 +171,16 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	sessions, err := sessionregistry.GetStore(c.SessionStore, c.SessionStores)
+	if err != nil {
+		return nil, err
+	}
+
+	searchIndex, err := getSearchIndex(c)
+	if err != nil {
+		return nil, err
+	}
+
 	etagCache := ttlcache.NewCache()
 	_ = etagCache.SetTTL(time.Duration(c.EtagCacheTTL) * time.Second)
 	etagCache.SkipTTLExtensionOnHit(true)
@@ -158,7 +192,9 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 	s := &svc{
 		c:               c,
 		dataGatewayURL:  *u,
-		tokenmgr:        tokenManager,
+		tokenmgr:        session.NewRecordingManager(tokenManager, sessions),
+		sessions:        sessions,
+		searchIndex:     searchIndex,
 		etagCache:       etagCache,
 		createHomeCache: createHomeCache,
 	}
@@ -168,6 +204,8 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 
 func (s *svc) Register(ss *grpc.Server) {
 	gateway.RegisterGatewayAPIServer(ss, s)
+	session.RegisterSessionsAPIServer(ss, s)
+	search.RegisterSearchAPIServer(ss, s)
 }
 
 func (s *svc) Close() error {
@@ -34,6 +34,7 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/permission"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/pkg/errors"
@@ -41,6 +42,18 @@ import (
 
 // TODO(labkode): add multi-phase commit logic when commit share or commit ref is enabled.
 func (s *svc) CreateOCMShare(ctx context.Context, req *ocm.CreateOCMShareRequest) (*ocm.CreateOCMShareResponse, error) {
+	ok, err := s.checkPermission(ctx, permission.ShareExternal, &provider.Reference{ResourceId: req.ResourceId})
+	if err != nil {
+		return &ocm.CreateOCMShareResponse{
+			Status: status.NewInternal(ctx, err, "error checking permission"),
+		}, nil
+	}
+	if !ok {
+		return &ocm.CreateOCMShareResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "user is not allowed to share with other sites"),
+		}, nil
+	}
+
 	c, err := pool.GetOCMShareProviderClient(pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
 		return &ocm.CreateOCMShareResponse{
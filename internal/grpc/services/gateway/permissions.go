@@ -22,6 +22,10 @@ import (
 	"context"
 
 	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/pkg/errors"
@@ -37,3 +41,36 @@ func (s *svc) CheckPermission(ctx context.Context, req *permissions.CheckPermiss
 	}
 	return c.CheckPermission(ctx, req)
 }
+
+// checkPermission tells whether the user in the context has the permission, optionally on the given reference.
+// Every permission is granted when no permissions service is configured.
+func (s *svc) checkPermission(ctx context.Context, permission string, ref *provider.Reference) (bool, error) {
+	if s.c.PermissionsEndpoint == "" {
+		return true, nil
+	}
+
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		return false, errtypes.UserRequired("gateway: user not found in context")
+	}
+
+	res, err := s.CheckPermission(ctx, &permissions.CheckPermissionRequest{
+		Permission: permission,
+		SubjectRef: &permissions.SubjectReference{
+			Spec: &permissions.SubjectReference_UserId{
+				UserId: u.Id,
+			},
+		},
+		Ref: ref,
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "gateway: error calling CheckPermission")
+	}
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+		return true, nil
+	case rpc.Code_CODE_PERMISSION_DENIED:
+		return false, nil
+	}
+	return false, status.NewErrorFromCode(res.Status.Code, "gateway")
+}
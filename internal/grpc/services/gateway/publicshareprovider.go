@@ -23,8 +23,11 @@ import (
 
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/pkg/errors"
 )
@@ -37,6 +40,18 @@ func (s *svc) CreatePublicShare(ctx context.Context, req *link.CreatePublicShare
 	log := appctx.GetLogger(ctx)
 	log.Info().Msg("create public share")
 
+	ok, err := s.checkPermission(ctx, permission.CreatePublicLink, &provider.Reference{ResourceId: req.ResourceInfo.GetId(), Path: req.ResourceInfo.GetPath()})
+	if err != nil {
+		return &link.CreatePublicShareResponse{
+			Status: status.NewInternal(ctx, err, "error checking permission"),
+		}, nil
+	}
+	if !ok {
+		return &link.CreatePublicShareResponse{
+			Status: status.NewPermissionDenied(ctx, nil, "user is not allowed to create public links"),
+		}, nil
+	}
+
 	c, err := pool.GetPublicShareProviderClient(pool.Endpoint(s.c.PublicShareProviderEndpoint))
 	if err != nil {
 		return nil, err
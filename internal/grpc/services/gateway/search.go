@@ -0,0 +1,249 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"sync"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/search"
+	searchregistry "github.com/cs3org/reva/pkg/search/registry"
+	"google.golang.org/grpc/metadata"
+)
+
+// searchStatWorkers is the number of concurrent stat requests
+// used to check the results of a search.
+const searchStatWorkers = 8
+
+func getSearchIndex(c *config) (search.Index, error) {
+	if f, ok := searchregistry.NewFuncs[c.SearchIndexDriver]; ok {
+		return f(c.SearchIndexDrivers[c.SearchIndexDriver])
+	}
+	return nil, errtypes.NotFound("gateway: search index driver not found: " + c.SearchIndexDriver)
+}
+
+// indexRef stats the referenced resource at its storage provider and adds it
+// to the search index. Failing to do so must not fail the request that changed
+// the resource, so errors are only logged.
+func (s *svc) indexRef(ctx context.Context, c provider.ProviderAPIClient, ref *provider.Reference) {
+	log := appctx.GetLogger(ctx)
+	res, err := c.Stat(ctx, &provider.StatRequest{Ref: ref})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK || res.Info.GetId() == nil {
+		log.Debug().Err(err).Interface("ref", ref).Msg("gateway: could not stat resource for the search index")
+		return
+	}
+	if err := s.searchIndex.Upsert(ctx, search.EntryFromResourceInfo(res.Info)); err != nil {
+		log.Error().Err(err).Interface("ref", ref).Msg("gateway: error updating search index")
+	}
+}
+
+// indexedID returns the id of the referenced resource, so that it can be dropped
+// from the search index once the resource has been deleted.
+func (s *svc) indexedID(ctx context.Context, c provider.ProviderAPIClient, ref *provider.Reference) *provider.ResourceId {
+	res, err := c.Stat(ctx, &provider.StatRequest{Ref: ref})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		return nil
+	}
+	return res.Info.GetId()
+}
+
+// unindex drops the resource from the search index.
+func (s *svc) unindex(ctx context.Context, id *provider.ResourceId) {
+	if id == nil {
+		return
+	}
+	if err := s.searchIndex.Remove(ctx, id); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Interface("id", id).Msg("gateway: error removing resource from search index")
+	}
+}
+
+// Search looks up the resources of the caller by name. The index may hold
+// resources the caller can't access, so the candidates are checked with a
+// stat and the offset and limit applied to the visible ones only.
+func (s *svc) Search(ctx context.Context, req *search.SearchRequest) (*search.SearchResponse, error) {
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		return &search.SearchResponse{
+			Status: status.NewUnauthenticated(ctx, nil, "user not found in context"),
+		}, nil
+	}
+	if req.Pattern == "" {
+		return &search.SearchResponse{
+			Status: status.NewInvalidArg(ctx, "search pattern must not be empty"),
+		}, nil
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > s.c.SearchMaxResults {
+		limit = s.c.SearchMaxResults
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	// deep pages would require checking too many candidates
+	if offset >= 10*s.c.SearchMaxResults {
+		return &search.SearchResponse{Status: status.NewOK(ctx), Infos: []*provider.ResourceInfo{}}, nil
+	}
+
+	spaces, owners := s.searchSpaces(ctx, u)
+	s.backfillSearchIndex(ctx, u, spaces)
+
+	wanted := offset + limit
+	visible := []*provider.ResourceInfo{}
+	for scanned := 0; len(visible) < wanted && scanned < 4*wanted; {
+		candidates, err := s.searchIndex.Search(ctx, &search.Query{
+			Pattern: req.Pattern,
+			Owners:  owners,
+			Offset:  scanned,
+			Limit:   wanted - len(visible),
+		})
+		if err != nil {
+			return &search.SearchResponse{
+				Status: status.NewInternal(ctx, err, "error searching the index"),
+			}, nil
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		scanned += len(candidates)
+
+		ids := make([]*provider.ResourceId, 0, len(candidates))
+		for _, c := range candidates {
+			ids = append(ids, c.ID)
+		}
+		for _, info := range s.statAll(ctx, ids) {
+			if info != nil {
+				visible = append(visible, info)
+			}
+		}
+	}
+
+	infos := []*provider.ResourceInfo{}
+	if offset < len(visible) {
+		infos = visible[offset:]
+		if len(infos) > limit {
+			infos = infos[:limit]
+		}
+	}
+	return &search.SearchResponse{Status: status.NewOK(ctx), Infos: infos}, nil
+}
+
+// Index adds the referenced resource to the search index.
+func (s *svc) Index(ctx context.Context, req *search.IndexRequest) (*search.IndexResponse, error) {
+	res, err := s.Stat(ctx, &provider.StatRequest{Ref: req.Ref})
+	if err != nil {
+		return &search.IndexResponse{
+			Status: status.NewInternal(ctx, err, "error stating resource"),
+		}, nil
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return &search.IndexResponse{Status: res.Status}, nil
+	}
+	if err := s.searchIndex.Upsert(ctx, search.EntryFromResourceInfo(res.Info)); err != nil {
+		return &search.IndexResponse{
+			Status: status.NewInternal(ctx, err, "error updating search index"),
+		}, nil
+	}
+	return &search.IndexResponse{Status: status.NewOK(ctx)}, nil
+}
+
+// searchSpaces lists the spaces of the user and returns their owners,
+// which restrict the search to resources the user can possibly see.
+func (s *svc) searchSpaces(ctx context.Context, u *userpb.User) ([]*provider.StorageSpace, []*userpb.UserId) {
+	owners := []*userpb.UserId{u.Id}
+	res, err := s.ListStorageSpaces(ctx, &provider.ListStorageSpacesRequest{})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		appctx.GetLogger(ctx).Debug().Err(err).Msg("gateway: could not list the storage spaces of the user")
+		return nil, owners
+	}
+	for _, space := range res.StorageSpaces {
+		if id := space.GetOwner().GetId(); id != nil {
+			owners = append(owners, id)
+		}
+	}
+	return res.StorageSpaces, owners
+}
+
+// backfillSearchIndex walks the spaces of the user in the background, once per
+// process, to add the resources changed while the index was not kept current,
+// e.g. before the in-memory index was created. The searches don't wait for it.
+func (s *svc) backfillSearchIndex(ctx context.Context, u *userpb.User, spaces []*provider.StorageSpace) {
+	if s.c.DisableSearchBackfill || len(spaces) == 0 {
+		return
+	}
+	if _, loaded := s.searchBackfilled.LoadOrStore(u.Id.GetOpaqueId(), true); loaded {
+		return
+	}
+
+	log := appctx.GetLogger(ctx)
+	bctx := appctx.WithLogger(ctxpkg.ContextSetUser(context.Background(), u), log)
+	if tkn, ok := ctxpkg.ContextGetToken(ctx); ok {
+		bctx = ctxpkg.ContextSetToken(bctx, tkn)
+		bctx = metadata.AppendToOutgoingContext(bctx, ctxpkg.TokenHeader, tkn)
+	}
+
+	go func() {
+		for _, space := range spaces {
+			if space.Root == nil {
+				continue
+			}
+			if err := search.Reindex(bctx, s, s.searchIndex, &provider.Reference{ResourceId: space.Root, Path: "."}); err != nil {
+				log.Error().Err(err).Interface("space", space.Root).Msg("gateway: error backfilling the search index")
+			}
+		}
+	}()
+}
+
+// statAll stats the resources concurrently, the infos keep the order of the
+// ids and are nil for resources the user can't access or that are gone.
+func (s *svc) statAll(ctx context.Context, ids []*provider.ResourceId) []*provider.ResourceInfo {
+	infos := make([]*provider.ResourceInfo, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < searchStatWorkers && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res, err := s.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{ResourceId: ids[i]}})
+				if err != nil {
+					appctx.GetLogger(ctx).Error().Err(err).Msg("gateway: error getting resource info")
+					continue
+				}
+				if res.Status.Code == rpc.Code_CODE_OK {
+					infos[i] = res.Info
+				}
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return infos
+}
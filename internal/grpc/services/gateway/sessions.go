@@ -0,0 +1,140 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// ListSessions lists the sessions of the caller, or those of another user for the callers
+// with the permission to manage sessions.
+func (s *svc) ListSessions(ctx context.Context, req *session.ListSessionsRequest) (*session.ListSessionsResponse, error) {
+	if s.sessions == nil {
+		return &session.ListSessionsResponse{
+			Status: status.NewUnimplemented(ctx, nil, "sessions are not recorded"),
+		}, nil
+	}
+
+	subject, err := s.sessionsSubject(ctx, req.User)
+	if err != nil {
+		return &session.ListSessionsResponse{
+			Status: status.NewStatusFromErrType(ctx, "error listing sessions", err),
+		}, nil
+	}
+
+	sessions, err := s.sessions.List(ctx, subject)
+	if err != nil {
+		return &session.ListSessionsResponse{
+			Status: status.NewInternal(ctx, err, "error listing sessions"),
+		}, nil
+	}
+	return &session.ListSessionsResponse{
+		Status:   status.NewOK(ctx),
+		Sessions: sessions,
+	}, nil
+}
+
+// RevokeSessions revokes a single session, or all the sessions of a user if no id is given.
+func (s *svc) RevokeSessions(ctx context.Context, req *session.RevokeSessionsRequest) (*session.RevokeSessionsResponse, error) {
+	if s.sessions == nil {
+		return &session.RevokeSessionsResponse{
+			Status: status.NewUnimplemented(ctx, nil, "sessions are not recorded"),
+		}, nil
+	}
+
+	var err error
+	if req.ID != "" {
+		err = s.revokeSession(ctx, req.ID)
+	} else {
+		err = s.revokeAllSessions(ctx, req.User)
+	}
+	if err != nil {
+		return &session.RevokeSessionsResponse{
+			Status: status.NewStatusFromErrType(ctx, "error revoking sessions", err),
+		}, nil
+	}
+	return &session.RevokeSessionsResponse{
+		Status: status.NewOK(ctx),
+	}, nil
+}
+
+func (s *svc) revokeSession(ctx context.Context, id string) error {
+	sess, err := s.sessions.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	// sessions of other users are reported as missing to the callers who may not manage them
+	if _, err := s.sessionsSubject(ctx, sess.User); err != nil {
+		if _, ok := err.(errtypes.IsPermissionDenied); ok {
+			return errtypes.NotFound(id)
+		}
+		return err
+	}
+	return s.sessions.Revoke(ctx, id)
+}
+
+func (s *svc) revokeAllSessions(ctx context.Context, u *userpb.UserId) error {
+	subject, err := s.sessionsSubject(ctx, u)
+	if err != nil {
+		return err
+	}
+	sessions, err := s.sessions.List(ctx, subject)
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions {
+		if err := s.sessions.Revoke(ctx, sess.ID); err != nil {
+			return errors.Wrapf(err, "error revoking session %s", sess.ID)
+		}
+	}
+	return nil
+}
+
+// sessionsSubject returns the user whose sessions the caller wants to manage, the caller if u is nil.
+// Managing the sessions of other users needs the permission, which is never
+// granted when no permissions service is configured.
+func (s *svc) sessionsSubject(ctx context.Context, u *userpb.UserId) (*userpb.UserId, error) {
+	caller, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		return nil, errtypes.UserRequired("gateway: user not found in context")
+	}
+	if u == nil || utils.UserEqual(u, caller.Id) {
+		return caller.Id, nil
+	}
+	if s.c.PermissionsEndpoint == "" {
+		return nil, errtypes.PermissionDenied("gateway: not allowed to manage the sessions of other users")
+	}
+	ok, err := s.checkPermission(ctx, permission.ManageSessions, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errtypes.PermissionDenied("gateway: not allowed to manage the sessions of other users")
+	}
+	return u, nil
+}
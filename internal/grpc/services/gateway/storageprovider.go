@@ -28,6 +28,7 @@ import (
 	"time"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
@@ -36,6 +37,7 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/permission"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/storage/utils/etag"
@@ -101,6 +103,22 @@ func (s *svc) CreateHome(ctx context.Context, req *provider.CreateHomeRequest) (
 
 func (s *svc) CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (*provider.CreateStorageSpaceResponse, error) {
 	log := appctx.GetLogger(ctx)
+
+	// everyone may create their own personal space, creating any other space needs the permission
+	if req.Type != "personal" || !isCallersSpace(ctx, req.Owner) {
+		ok, err := s.checkPermission(ctx, permission.CreateSpace, nil)
+		if err != nil {
+			return &provider.CreateStorageSpaceResponse{
+				Status: status.NewInternal(ctx, err, "error checking permission"),
+			}, nil
+		}
+		if !ok {
+			return &provider.CreateStorageSpaceResponse{
+				Status: status.NewPermissionDenied(ctx, nil, "user is not allowed to create spaces"),
+			}, nil
+		}
+	}
+
 	// TODO: needs to be fixed
 	c, err := s.findByPath(ctx, "/users")
 	if err != nil {
@@ -119,6 +137,16 @@ func (s *svc) CreateStorageSpace(ctx context.Context, req *provider.CreateStorag
 	return res, nil
 }
 
+// isCallersSpace tells whether a space with the given owner belongs to the user in the context.
+// Spaces without an owner are created for the user in the context.
+func isCallersSpace(ctx context.Context, owner *userpb.User) bool {
+	if owner == nil || owner.Id == nil {
+		return true
+	}
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	return ok && utils.UserEqual(u.Id, owner.Id)
+}
+
 func (s *svc) ListStorageSpaces(ctx context.Context, req *provider.ListStorageSpacesRequest) (*provider.ListStorageSpacesResponse, error) {
 	log := appctx.GetLogger(ctx)
 	var id *provider.StorageSpaceId
@@ -249,6 +277,21 @@ func (s *svc) listStorageSpacesOnProvider(ctx context.Context, req *provider.Lis
 
 func (s *svc) UpdateStorageSpace(ctx context.Context, req *provider.UpdateStorageSpaceRequest) (*provider.UpdateStorageSpaceResponse, error) {
 	log := appctx.GetLogger(ctx)
+
+	if req.StorageSpace.Quota != nil {
+		ok, err := s.checkPermission(ctx, permission.ManageQuota, &provider.Reference{ResourceId: req.StorageSpace.Root})
+		if err != nil {
+			return &provider.UpdateStorageSpaceResponse{
+				Status: status.NewInternal(ctx, err, "error checking permission"),
+			}, nil
+		}
+		if !ok {
+			return &provider.UpdateStorageSpaceResponse{
+				Status: status.NewPermissionDenied(ctx, nil, "user is not allowed to change the quota"),
+			}, nil
+		}
+	}
+
 	// TODO: needs to be fixed
 	c, err := s.find(ctx, &provider.Reference{ResourceId: req.StorageSpace.Root})
 	if err != nil {
@@ -839,6 +882,9 @@ func (s *svc) createContainer(ctx context.Context, req *provider.CreateContainer
 		return nil, errors.Wrap(err, "gateway: error calling CreateContainer")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Ref)
+	}
 	return res, nil
 }
 
@@ -858,6 +904,9 @@ func (s *svc) TouchFile(ctx context.Context, req *provider.TouchFileRequest) (*p
 		return nil, errors.Wrap(err, "gateway: error calling TouchFile")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Ref)
+	}
 	return res, nil
 }
 
@@ -1008,6 +1057,8 @@ func (s *svc) delete(ctx context.Context, req *provider.DeleteRequest) (*provide
 		}, nil
 	}
 
+	id := s.indexedID(ctx, c, req.Ref)
+
 	res, err := c.Delete(ctx, req)
 	if err != nil {
 		if gstatus.Code(err) == codes.PermissionDenied {
@@ -1016,6 +1067,9 @@ func (s *svc) delete(ctx context.Context, req *provider.DeleteRequest) (*provide
 		return nil, errors.Wrap(err, "gateway: error calling Delete")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.unindex(ctx, id)
+	}
 	return res, nil
 }
 
@@ -1174,7 +1228,11 @@ func (s *svc) move(ctx context.Context, req *provider.MoveRequest) (*provider.Mo
 		}, nil
 	}
 
-	return c.Move(ctx, req)
+	res, err := c.Move(ctx, req)
+	if err == nil && res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Destination)
+	}
+	return res, err
 }
 
 func (s *svc) SetArbitraryMetadata(ctx context.Context, req *provider.SetArbitraryMetadataRequest) (*provider.SetArbitraryMetadataResponse, error) {
@@ -1194,6 +1252,9 @@ func (s *svc) SetArbitraryMetadata(ctx context.Context, req *provider.SetArbitra
 		return nil, errors.Wrap(err, "gateway: error calling SetArbitraryMetadata")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Ref)
+	}
 	return res, nil
 }
 
@@ -1214,6 +1275,9 @@ func (s *svc) UnsetArbitraryMetadata(ctx context.Context, req *provider.UnsetArb
 		return nil, errors.Wrap(err, "gateway: error calling UnsetArbitraryMetadata")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Ref)
+	}
 	return res, nil
 }
 
@@ -2187,6 +2251,9 @@ func (s *svc) RestoreFileVersion(ctx context.Context, req *provider.RestoreFileV
 		return nil, errors.Wrap(err, "gateway: error calling RestoreFileVersion")
 	}
 
+	if res.Status.Code == rpc.Code_CODE_OK {
+		s.indexRef(ctx, c, req.Ref)
+	}
 	return res, nil
 }
 
@@ -2224,6 +2291,10 @@ func (s *svc) RestoreRecycleItem(ctx context.Context, req *provider.RestoreRecyc
 		return nil, errors.Wrap(err, "gateway: error calling RestoreRecycleItem")
 	}
 
+	// items restored to their original location are picked up by the next reindex
+	if res.Status.Code == rpc.Code_CODE_OK && req.RestoreRef != nil {
+		s.indexRef(ctx, c, req.RestoreRef)
+	}
 	return res, nil
 }
 
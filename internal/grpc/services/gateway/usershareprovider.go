@@ -540,6 +540,10 @@ func (s *svc) denyGrant(ctx context.Context, id *provider.ResourceId, g *provide
 	if err != nil {
 		return nil, errors.Wrap(err, "gateway: error calling DenyGrant")
 	}
+	// maintenances are passed on for the clients to retry later
+	if grantRes.Status.Code == rpc.Code_CODE_UNAVAILABLE {
+		return grantRes.Status, nil
+	}
 	if grantRes.Status.Code != rpc.Code_CODE_OK {
 		return status.NewInternal(ctx, status.NewErrorFromCode(grantRes.Status.Code, "gateway"),
 			"error committing share to storage grant"), nil
@@ -573,6 +577,9 @@ func (s *svc) addGrant(ctx context.Context, id *provider.ResourceId, g *provider
 	if err != nil {
 		return nil, errors.Wrap(err, "gateway: error calling AddGrant")
 	}
+	if grantRes.Status.Code == rpc.Code_CODE_UNAVAILABLE {
+		return grantRes.Status, nil
+	}
 	if grantRes.Status.Code != rpc.Code_CODE_OK {
 		return status.NewInternal(ctx, status.NewErrorFromCode(grantRes.Status.Code, "gateway"),
 			"error committing share to storage grant"), nil
@@ -605,6 +612,9 @@ func (s *svc) updateGrant(ctx context.Context, id *provider.ResourceId, g *provi
 	if err != nil {
 		return nil, errors.Wrap(err, "gateway: error calling UpdateGrant")
 	}
+	if grantRes.Status.Code == rpc.Code_CODE_UNAVAILABLE {
+		return grantRes.Status, nil
+	}
 	if grantRes.Status.Code != rpc.Code_CODE_OK {
 		return status.NewInternal(ctx, status.NewErrorFromCode(grantRes.Status.Code, "gateway"),
 			"error committing share to storage grant"), nil
@@ -638,6 +648,9 @@ func (s *svc) removeGrant(ctx context.Context, id *provider.ResourceId, g *provi
 	if err != nil {
 		return nil, errors.Wrap(err, "gateway: error calling RemoveGrant")
 	}
+	if grantRes.Status.Code == rpc.Code_CODE_UNAVAILABLE {
+		return grantRes.Status, nil
+	}
 	if grantRes.Status.Code != rpc.Code_CODE_OK {
 		return status.NewInternal(ctx, status.NewErrorFromCode(grantRes.Status.Code, "gateway"),
 			"error removing storage grant"), nil
@@ -23,6 +23,7 @@ import (
 
 	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/ocm/invite"
 	"github.com/cs3org/reva/pkg/ocm/invite/manager/registry"
 	"github.com/cs3org/reva/pkg/rgrpc"
@@ -100,6 +101,11 @@ func (s *service) UnprotectedEndpoints() []string {
 	return []string{"/cs3.ocm.invite.v1beta1.InviteAPI/AcceptInvite"}
 }
 
+// Ready tells whether the invite manager is ready, when it can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.Ready(ctx, s.im)
+}
+
 func (s *service) GenerateInviteToken(ctx context.Context, req *invitepb.GenerateInviteTokenRequest) (*invitepb.GenerateInviteTokenResponse, error) {
 	token, err := s.im.GenerateToken(ctx)
 	if err != nil {
@@ -23,6 +23,7 @@ import (
 
 	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/ocm/share"
 	"github.com/cs3org/reva/pkg/ocm/share/manager/registry"
 	"github.com/cs3org/reva/pkg/rgrpc"
@@ -101,6 +102,11 @@ func (s *service) UnprotectedEndpoints() []string {
 	return []string{}
 }
 
+// Ready tells whether the share manager is ready, when it can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.Ready(ctx, s.sm)
+}
+
 // Note: this is for outgoing OCM shares
 // This function is used when you for instance
 // call `ocm-share-create` in reva-cli.
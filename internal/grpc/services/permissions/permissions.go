@@ -27,6 +27,7 @@ import (
 	"github.com/cs3org/reva/pkg/permission"
 	"github.com/cs3org/reva/pkg/permission/manager/registry"
 	"github.com/cs3org/reva/pkg/rgrpc"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -87,18 +88,16 @@ func (s *service) Register(ss *grpc.Server) {
 }
 
 func (s *service) CheckPermission(ctx context.Context, req *permissions.CheckPermissionRequest) (*permissions.CheckPermissionResponse, error) {
-	var subject string
-	switch ref := req.SubjectRef.Spec.(type) {
-	case *permissions.SubjectReference_UserId:
-		subject = ref.UserId.OpaqueId
-	case *permissions.SubjectReference_GroupId:
-		subject = ref.GroupId.OpaqueId
+	ok, err := s.manager.CheckPermission(ctx, req.Permission, req.SubjectRef, req.Ref)
+	if err != nil {
+		return &permissions.CheckPermissionResponse{
+			Status: status.NewInternal(ctx, err, "error checking permission"),
+		}, nil
 	}
-	var status *rpc.Status
-	if ok := s.manager.CheckPermission(req.Permission, subject, req.Ref); ok {
-		status = &rpc.Status{Code: rpc.Code_CODE_OK}
-	} else {
-		status = &rpc.Status{Code: rpc.Code_CODE_PERMISSION_DENIED}
+	if !ok {
+		return &permissions.CheckPermissionResponse{
+			Status: &rpc.Status{Code: rpc.Code_CODE_PERMISSION_DENIED},
+		}, nil
 	}
-	return &permissions.CheckPermissionResponse{Status: status}, nil
+	return &permissions.CheckPermissionResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}}, nil
 }
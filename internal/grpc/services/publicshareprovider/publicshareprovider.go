@@ -27,10 +27,12 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/publicshare"
 	"github.com/cs3org/reva/pkg/publicshare/manager/registry"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -73,6 +75,11 @@ func (s *service) UnprotectedEndpoints() []string {
 	return []string{"/cs3.sharing.link.v1beta1.LinkAPI/GetPublicShareByToken"}
 }
 
+// Ready tells whether the share manager is ready, when it can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.Ready(ctx, s.sm)
+}
+
 func (s *service) Register(ss *grpc.Server) {
 	link.RegisterLinkAPIServer(ss, s)
 }
@@ -172,11 +179,16 @@ func (s *service) RemovePublicShare(ctx context.Context, req *link.RemovePublicS
 	log.Info().Str("publicshareprovider", "remove").Msg("remove public share")
 
 	user := ctxpkg.ContextMustGetUser(ctx)
-	err := s.sm.RevokePublicShare(ctx, user, req.Ref)
+	// look up the link first so that the response can tell which resource it pointed to
+	ps, err := s.sm.GetPublicShare(ctx, user, req.Ref, false)
+	if err == nil {
+		err = s.sm.RevokePublicShare(ctx, user, req.Ref)
+	}
 	switch err.(type) {
 	case nil:
 		return &link.RemovePublicShareResponse{
 			Status: status.NewOK(ctx),
+			Opaque: utils.AppendJSONToOpaque(nil, "resourceid", ps.GetResourceId()),
 		}, nil
 	case errtypes.NotFound:
 		return &link.RemovePublicShareResponse{
@@ -32,8 +32,11 @@ import (
 
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/mime"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
@@ -112,6 +115,11 @@ func (s *service) Close() error {
 
 func (s *service) UnprotectedEndpoints() []string { return []string{} }
 
+// Ready tells whether the storage driver is ready, when it can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.Ready(ctx, s.storage)
+}
+
 func (s *service) Register(ss *grpc.Server) {
 	provider.RegisterProviderAPIServer(ss, s)
 }
@@ -225,6 +233,7 @@ func (s *service) SetArbitraryMetadata(ctx context.Context, req *provider.SetArb
 		}, nil
 	}
 
+	ctx = ctxWithLockID(ctx, req.Opaque)
 	if err := s.storage.SetArbitraryMetadata(ctx, newRef, req.ArbitraryMetadata); err != nil {
 		var st *rpc.Status
 		switch err.(type) {
@@ -232,6 +241,8 @@ func (s *service) SetArbitraryMetadata(ctx context.Context, req *provider.SetArb
 			st = status.NewNotFound(ctx, "path not found when setting arbitrary metadata")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "resource is locked")
 		default:
 			st = status.NewInternal(ctx, err, "error setting arbitrary metadata: "+req.Ref.String())
 		}
@@ -255,6 +266,7 @@ func (s *service) UnsetArbitraryMetadata(ctx context.Context, req *provider.Unse
 		}, nil
 	}
 
+	ctx = ctxWithLockID(ctx, req.Opaque)
 	if err := s.storage.UnsetArbitraryMetadata(ctx, newRef, req.ArbitraryMetadataKeys); err != nil {
 		var st *rpc.Status
 		switch err.(type) {
@@ -262,6 +274,8 @@ func (s *service) UnsetArbitraryMetadata(ctx context.Context, req *provider.Unse
 			st = status.NewNotFound(ctx, "path not found when unsetting arbitrary metadata")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "resource is locked")
 		default:
 			st = status.NewInternal(ctx, err, "error unsetting arbitrary metadata: "+req.Ref.String())
 		}
@@ -293,6 +307,8 @@ func (s *service) SetLock(ctx context.Context, req *provider.SetLockRequest) (*p
 			st = status.NewNotFound(ctx, "path not found when setting lock")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "reference already locked")
 		case errtypes.BadRequest:
 			st = status.NewFailedPrecondition(ctx, err, "reference already locked")
 		default:
@@ -305,6 +321,7 @@ func (s *service) SetLock(ctx context.Context, req *provider.SetLockRequest) (*p
 
 	res := &provider.SetLockResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(s.resourceID(ctx, newRef)),
 	}
 	return res, nil
 }
@@ -359,6 +376,8 @@ func (s *service) RefreshLock(ctx context.Context, req *provider.RefreshLockRequ
 			st = status.NewNotFound(ctx, "path not found when refreshing lock")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "caller does not hold the lock")
 		case errtypes.BadRequest:
 			st = status.NewFailedPrecondition(ctx, err, "reference not locked or caller does not hold the lock")
 		default:
@@ -371,6 +390,7 @@ func (s *service) RefreshLock(ctx context.Context, req *provider.RefreshLockRequ
 
 	res := &provider.RefreshLockResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(s.resourceID(ctx, newRef)),
 	}
 	return res, nil
 }
@@ -392,6 +412,8 @@ func (s *service) Unlock(ctx context.Context, req *provider.UnlockRequest) (*pro
 			st = status.NewNotFound(ctx, "path not found when unlocking")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "caller does not hold the lock")
 		case errtypes.BadRequest:
 			st = status.NewFailedPrecondition(ctx, err, "reference not locked")
 		default:
@@ -404,6 +426,7 @@ func (s *service) Unlock(ctx context.Context, req *provider.UnlockRequest) (*pro
 
 	res := &provider.UnlockResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(s.resourceID(ctx, newRef)),
 	}
 	return res, nil
 }
@@ -420,11 +443,13 @@ func (s *service) InitiateFileDownload(ctx context.Context, req *provider.Initia
 
 	protocol := &provider.FileDownloadProtocol{Expose: s.conf.ExposeDataServer}
 
+	newRef := req.Ref
 	if utils.IsRelativeReference(req.Ref) {
 		protocol.Protocol = "spaces"
 		u.Path = path.Join(u.Path, "spaces", req.Ref.ResourceId.StorageId+"!"+req.Ref.ResourceId.OpaqueId, req.Ref.Path)
 	} else {
-		newRef, err := s.unwrap(ctx, req.Ref)
+		var err error
+		newRef, err = s.unwrap(ctx, req.Ref)
 		if err != nil {
 			return &provider.InitiateFileDownloadResponse{
 				Status: status.NewInternal(ctx, err, "error unwrapping path"),
@@ -436,11 +461,21 @@ func (s *service) InitiateFileDownload(ctx context.Context, req *provider.Initia
 		u.Path = path.Join(u.Path, "simple", newRef.GetPath())
 	}
 
+	// the data server downloads the given revision of the file or item of the recycle bin
+	q := u.Query()
+	for _, k := range []string{"revision", "recycle"} {
+		if req.Opaque != nil && req.Opaque.Map[k] != nil {
+			q.Set(k, string(req.Opaque.Map[k].Value))
+		}
+	}
+	u.RawQuery = q.Encode()
+
 	protocol.DownloadEndpoint = u.String()
 
 	return &provider.InitiateFileDownloadResponse{
 		Protocols: []*provider.FileDownloadProtocol{protocol},
 		Status:    status.NewOK(ctx),
+		Opaque:    resourceIDOpaque(s.resourceID(ctx, newRef)),
 	}, nil
 }
 
@@ -459,7 +494,8 @@ func (s *service) InitiateFileUpload(ctx context.Context, req *provider.Initiate
 		}, nil
 	}
 
-	metadata := map[string]string{}
+	// the id of the storage lets the upload identify the uploaded file once it is finished
+	metadata := map[string]string{"providerID": s.mountID}
 	var uploadLength int64
 	if req.Opaque != nil && req.Opaque.Map != nil {
 		if req.Opaque.Map["Upload-Length"] != nil {
@@ -480,6 +516,9 @@ func (s *service) InitiateFileUpload(ctx context.Context, req *provider.Initiate
 			metadata["mtime"] = string(req.Opaque.Map["X-OC-Mtime"].Value)
 		}
 	}
+	if lockID, ok := ctxpkg.ContextGetLockID(ctxWithLockID(ctx, req.Opaque)); ok {
+		metadata[ctxpkg.LockIDHeader] = lockID
+	}
 	uploadIDs, err := s.storage.InitiateUpload(ctx, newRef, uploadLength, metadata)
 	if err != nil {
 		var st *rpc.Status
@@ -501,6 +540,8 @@ func (s *service) InitiateFileUpload(ctx context.Context, req *provider.Initiate
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
 		case errtypes.InsufficientStorage:
 			st = status.NewInsufficientStorage(ctx, err, "insufficient storage")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "resource is locked")
 		default:
 			st = status.NewInternal(ctx, err, "error getting upload id: "+req.Ref.String())
 		}
@@ -728,6 +769,11 @@ func (s *service) Delete(ctx context.Context, req *provider.DeleteRequest) (*pro
 		}
 	}
 
+	ctx = ctxWithLockID(ctx, req.Opaque)
+
+	// the resource is gone after the delete, look up its id beforehand
+	resourceID := s.resourceID(ctx, newRef)
+
 	if err := s.storage.Delete(ctx, newRef); err != nil {
 		var st *rpc.Status
 		switch err.(type) {
@@ -735,6 +781,8 @@ func (s *service) Delete(ctx context.Context, req *provider.DeleteRequest) (*pro
 			st = status.NewNotFound(ctx, "path not found when creating container")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "resource is locked")
 		default:
 			st = status.NewInternal(ctx, err, "error deleting file: "+req.Ref.String())
 		}
@@ -745,6 +793,7 @@ func (s *service) Delete(ctx context.Context, req *provider.DeleteRequest) (*pro
 
 	res := &provider.DeleteResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(resourceID),
 	}
 	return res, nil
 }
@@ -763,6 +812,8 @@ func (s *service) Move(ctx context.Context, req *provider.MoveRequest) (*provide
 		}, nil
 	}
 
+	ctx = ctxWithLockID(ctx, req.Opaque)
+
 	if err := s.storage.Move(ctx, sourceRef, targetRef); err != nil {
 		var st *rpc.Status
 		switch err.(type) {
@@ -770,6 +821,8 @@ func (s *service) Move(ctx context.Context, req *provider.MoveRequest) (*provide
 			st = status.NewNotFound(ctx, "path not found when moving")
 		case errtypes.PermissionDenied:
 			st = status.NewPermissionDenied(ctx, err, "permission denied")
+		case errtypes.Locked:
+			st = status.NewFailedPrecondition(ctx, err, "resource is locked")
 		default:
 			st = status.NewInternal(ctx, err, "error moving: "+sourceRef.String())
 		}
@@ -778,8 +831,10 @@ func (s *service) Move(ctx context.Context, req *provider.MoveRequest) (*provide
 		}, nil
 	}
 
+	// ids survive moves, so the id of the target is the one of the moved resource
 	res := &provider.MoveResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(s.resourceID(ctx, targetRef)),
 	}
 	return res, nil
 }
@@ -1104,6 +1159,7 @@ func (s *service) RestoreFileVersion(ctx context.Context, req *provider.RestoreF
 
 	res := &provider.RestoreFileVersionResponse{
 		Status: status.NewOK(ctx),
+		Opaque: resourceIDOpaque(s.resourceID(ctx, newRef)),
 	}
 	return res, nil
 }
@@ -1569,6 +1625,26 @@ func (s *service) wrap(ctx context.Context, ri *provider.ResourceInfo, prefixMou
 	return nil
 }
 
+// resourceID looks up the id of the referenced resource, which the responses carry
+// for the events emitted about them.
+func (s *service) resourceID(ctx context.Context, ref *provider.Reference) *provider.ResourceId {
+	md, err := s.storage.GetMD(ctx, ref, nil)
+	if err != nil || md.GetId() == nil {
+		return nil
+	}
+	if md.Id.StorageId == "" {
+		md.Id.StorageId = s.mountID
+	}
+	return md.Id
+}
+
+func resourceIDOpaque(id *provider.ResourceId) *types.Opaque {
+	if id == nil {
+		return nil
+	}
+	return utils.AppendJSONToOpaque(nil, "resourceid", id)
+}
+
 func (s *service) wrapReference(ctx context.Context, ref *provider.Reference, prefixMountpoint bool) error {
 	if ref.ResourceId != nil && ref.ResourceId.StorageId == "" {
 		// For wrapper drivers, the storage ID might already be set. In that case, skip setting it
@@ -1594,3 +1670,15 @@ func (v descendingMtime) Less(i, j int) bool {
 func (v descendingMtime) Swap(i, j int) {
 	v[i], v[j] = v[j], v[i]
 }
+
+// ctxWithLockID stores the lock id sent along with a request in the context,
+// so the storage driver can match it against the lock held on the resource.
+func ctxWithLockID(ctx context.Context, opaque *types.Opaque) context.Context {
+	if opaque == nil || opaque.Map == nil {
+		return ctx
+	}
+	if entry, ok := opaque.Map[ctxpkg.LockIDHeader]; ok && entry.Decoder == "plain" {
+		return ctxpkg.ContextSetLockID(ctx, string(entry.Value))
+	}
+	return ctx
+}
@@ -0,0 +1,160 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package usershareprovider
+
+import (
+	"context"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/auth/scope"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/logger"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/share"
+	"github.com/cs3org/reva/pkg/token"
+	tokenregistry "github.com/cs3org/reva/pkg/token/manager/registry"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
+)
+
+func getTokenManager(manager string, m map[string]map[string]interface{}) (token.Manager, error) {
+	if f, ok := tokenregistry.NewFuncs[manager]; ok {
+		return f(m[manager])
+	}
+	return nil, errtypes.NotFound("usershareprovider: token manager not found: " + manager)
+}
+
+// expirationOpaque returns an opaque carrying the expiration of the share, if it has one.
+func (s *service) expirationOpaque(ctx context.Context, sh *collaboration.Share) (*typespb.Opaque, error) {
+	if sh == nil {
+		return nil, nil
+	}
+	expirations, err := s.sm.GetExpirations(ctx, []*collaboration.ShareId{sh.Id})
+	if err != nil {
+		return nil, err
+	}
+	expiration, ok := expirations[sh.Id.GetOpaqueId()]
+	if !ok {
+		return nil, nil
+	}
+	return share.AddExpirationToOpaque(nil, expiration)
+}
+
+// expirationsOpaque returns an opaque carrying the expirations of the given shares indexed by share id.
+func (s *service) expirationsOpaque(ctx context.Context, shares []*collaboration.Share) (*typespb.Opaque, error) {
+	if len(shares) == 0 {
+		return nil, nil
+	}
+	ids := make([]*collaboration.ShareId, 0, len(shares))
+	for _, sh := range shares {
+		ids = append(ids, sh.Id)
+	}
+	expirations, err := s.sm.GetExpirations(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	return share.AddExpirationsToOpaque(nil, expirations)
+}
+
+func (s *service) startJanitorRun() {
+	ticker := time.NewTicker(time.Duration(s.conf.JanitorRunInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.removeExpiredShares(context.Background())
+		}
+	}
+}
+
+// removeExpiredShares removes the expired shares through the gateway on behalf of their owners,
+// so that the grants committed to the storage are removed together with the shares.
+func (s *service) removeExpiredShares(ctx context.Context) {
+	log := logger.New().With().Str("service", "usershareprovider").Logger()
+
+	expired, err := s.sm.ListExpiredShares(ctx, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("error listing expired shares")
+		return
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	gw, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewayAddr))
+	if err != nil {
+		log.Error().Err(err).Msg("error getting gateway client")
+		return
+	}
+
+	for _, sh := range expired {
+		ownerCtx, err := s.impersonate(ctx, gw, sh.Owner)
+		if err != nil {
+			log.Error().Err(err).Str("share", sh.Id.GetOpaqueId()).Msg("error impersonating share owner")
+			continue
+		}
+		res, err := gw.RemoveShare(ownerCtx, &collaboration.RemoveShareRequest{
+			Ref: &collaboration.ShareReference{Spec: &collaboration.ShareReference_Id{Id: sh.Id}},
+		})
+		switch {
+		case err != nil:
+			log.Error().Err(err).Str("share", sh.Id.GetOpaqueId()).Msg("error removing expired share")
+		case res.Status.Code != rpc.Code_CODE_OK:
+			log.Error().Str("share", sh.Id.GetOpaqueId()).Str("status", res.Status.Code.String()).Msg("error removing expired share")
+		default:
+			log.Info().Str("share", sh.Id.GetOpaqueId()).Msg("removed expired share")
+		}
+	}
+}
+
+// impersonate returns a context authenticated as the given user. The stored share only
+// knows the owner id, so the full user is fetched before minting the final token.
+func (s *service) impersonate(ctx context.Context, gw gateway.GatewayAPIClient, id *userpb.UserId) (context.Context, error) {
+	ownerScope, err := scope.AddOwnerScope(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	tkn, err := s.tokenmgr.MintToken(ctx, &userpb.User{Id: id}, ownerScope)
+	if err != nil {
+		return nil, err
+	}
+	res, err := gw.GetUser(metadata.AppendToOutgoingContext(ctx, ctxpkg.TokenHeader, tkn), &userpb.GetUserRequest{UserId: id, SkipFetchingUserGroups: true})
+	if err != nil {
+		return nil, err
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return nil, errors.New("usershareprovider: error getting user: " + res.Status.Message)
+	}
+
+	tkn, err = s.tokenmgr.MintToken(ctx, res.User, ownerScope)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, ctxpkg.TokenHeader, tkn), nil
+}
@@ -28,10 +28,16 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/share"
 	"github.com/cs3org/reva/pkg/share/manager/registry"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/cs3org/reva/pkg/token"
+	"github.com/cs3org/reva/pkg/token/session"
+	sessionregistry "github.com/cs3org/reva/pkg/token/session/registry"
+	"github.com/cs3org/reva/pkg/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -42,21 +48,38 @@ func init() {
 }
 
 type config struct {
-	Driver                string                            `mapstructure:"driver"`
-	Drivers               map[string]map[string]interface{} `mapstructure:"drivers"`
-	AllowedPathsForShares []string                          `mapstructure:"allowed_paths_for_shares"`
+	Driver                     string                            `mapstructure:"driver"`
+	Drivers                    map[string]map[string]interface{} `mapstructure:"drivers"`
+	AllowedPathsForShares      []string                          `mapstructure:"allowed_paths_for_shares"`
+	EnableExpiredSharesCleanup bool                              `mapstructure:"enable_expired_shares_cleanup"`
+	JanitorRunInterval         int                               `mapstructure:"janitor_run_interval"`
+	GatewayAddr                string                            `mapstructure:"gateway_addr"`
+	TokenManager               string                            `mapstructure:"token_manager"`
+	TokenManagers              map[string]map[string]interface{} `mapstructure:"token_managers"`
+	// SessionStore records the tokens minted to remove the expired shares, disabled if empty.
+	SessionStore  string                            `mapstructure:"session_store"`
+	SessionStores map[string]map[string]interface{} `mapstructure:"session_stores"`
 }
 
 func (c *config) init() {
 	if c.Driver == "" {
 		c.Driver = "json"
 	}
+	if c.JanitorRunInterval == 0 {
+		c.JanitorRunInterval = 3600
+	}
+	if c.TokenManager == "" {
+		c.TokenManager = "jwt"
+	}
+	c.GatewayAddr = sharedconf.GetGatewaySVC(c.GatewayAddr)
 }
 
 type service struct {
 	conf                  *config
 	sm                    share.Manager
 	allowedPathsForShares []*regexp.Regexp
+	tokenmgr              token.Manager
+	stop                  chan struct{}
 }
 
 func getShareManager(c *config) (share.Manager, error) {
@@ -68,6 +91,9 @@ func getShareManager(c *config) (share.Manager, error) {
 
 // TODO(labkode): add ctx to Close.
 func (s *service) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+	}
 	return nil
 }
 
@@ -75,6 +101,11 @@ func (s *service) UnprotectedEndpoints() []string {
 	return []string{}
 }
 
+// Ready tells whether the share manager is ready, when it can tell.
+func (s *service) Ready(ctx context.Context) error {
+	return health.Ready(ctx, s.sm)
+}
+
 func (s *service) Register(ss *grpc.Server) {
 	collaboration.RegisterCollaborationAPIServer(ss, s)
 }
@@ -117,6 +148,20 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		allowedPathsForShares: allowedPathsForShares,
 	}
 
+	if c.EnableExpiredSharesCleanup {
+		tokenmgr, err := getTokenManager(c.TokenManager, c.TokenManagers)
+		if err != nil {
+			return nil, err
+		}
+		sessions, err := sessionregistry.GetStore(c.SessionStore, c.SessionStores)
+		if err != nil {
+			return nil, err
+		}
+		service.tokenmgr = session.NewRecordingManager(tokenmgr, sessions)
+		service.stop = make(chan struct{})
+		go service.startJanitorRun()
+	}
+
 	return service, nil
 }
 
@@ -146,22 +191,50 @@ func (s *service) CreateShare(ctx context.Context, req *collaboration.CreateShar
 		}, nil
 	}
 
-	share, err := s.sm.Share(ctx, req.ResourceInfo, req.Grant)
+	expiration, _, err := share.ExpirationFromOpaque(req.Opaque)
+	if err != nil {
+		return &collaboration.CreateShareResponse{
+			Status: status.NewInvalidArg(ctx, "invalid share expiration"),
+		}, nil
+	}
+	if share.IsExpired(expiration) {
+		return &collaboration.CreateShareResponse{
+			Status: status.NewInvalidArg(ctx, "share expiration must be in the future"),
+		}, nil
+	}
+
+	createdShare, err := s.sm.Share(ctx, req.ResourceInfo, req.Grant, expiration)
 	if err != nil {
 		return &collaboration.CreateShareResponse{
 			Status: status.NewInternal(ctx, err, "error creating share"),
 		}, nil
 	}
 
+	opaque, err := share.AddExpirationToOpaque(nil, expiration)
+	if err != nil {
+		return &collaboration.CreateShareResponse{
+			Status: status.NewInternal(ctx, err, "error encoding share expiration"),
+		}, nil
+	}
+
 	res := &collaboration.CreateShareResponse{
 		Status: status.NewOK(ctx),
-		Share:  share,
+		Opaque: opaque,
+		Share:  createdShare,
 	}
 	return res, nil
 }
 
 func (s *service) RemoveShare(ctx context.Context, req *collaboration.RemoveShareRequest) (*collaboration.RemoveShareResponse, error) {
-	err := s.sm.Unshare(ctx, req.Ref)
+	// look up the share first so that the response can tell which resource it pointed to
+	sh, err := s.sm.GetShare(ctx, req.Ref)
+	if err != nil {
+		return &collaboration.RemoveShareResponse{
+			Status: status.NewInternal(ctx, err, "error getting share"),
+		}, nil
+	}
+
+	err = s.sm.Unshare(ctx, req.Ref)
 	if err != nil {
 		return &collaboration.RemoveShareResponse{
 			Status: status.NewInternal(ctx, err, "error removing share"),
@@ -170,20 +243,29 @@ func (s *service) RemoveShare(ctx context.Context, req *collaboration.RemoveShar
 
 	return &collaboration.RemoveShareResponse{
 		Status: status.NewOK(ctx),
+		Opaque: utils.AppendJSONToOpaque(nil, "resourceid", sh.GetResourceId()),
 	}, nil
 }
 
 func (s *service) GetShare(ctx context.Context, req *collaboration.GetShareRequest) (*collaboration.GetShareResponse, error) {
-	share, err := s.sm.GetShare(ctx, req.Ref)
+	sh, err := s.sm.GetShare(ctx, req.Ref)
 	if err != nil {
 		return &collaboration.GetShareResponse{
 			Status: status.NewInternal(ctx, err, "error getting share"),
 		}, nil
 	}
 
+	opaque, err := s.expirationOpaque(ctx, sh)
+	if err != nil {
+		return &collaboration.GetShareResponse{
+			Status: status.NewInternal(ctx, err, "error getting share expiration"),
+		}, nil
+	}
+
 	return &collaboration.GetShareResponse{
 		Status: status.NewOK(ctx),
-		Share:  share,
+		Opaque: opaque,
+		Share:  sh,
 	}, nil
 }
 
@@ -195,24 +277,68 @@ func (s *service) ListShares(ctx context.Context, req *collaboration.ListSharesR
 		}, nil
 	}
 
+	opaque, err := s.expirationsOpaque(ctx, shares)
+	if err != nil {
+		return &collaboration.ListSharesResponse{
+			Status: status.NewInternal(ctx, err, "error getting share expirations"),
+		}, nil
+	}
+
 	res := &collaboration.ListSharesResponse{
 		Status: status.NewOK(ctx),
+		Opaque: opaque,
 		Shares: shares,
 	}
 	return res, nil
 }
 
 func (s *service) UpdateShare(ctx context.Context, req *collaboration.UpdateShareRequest) (*collaboration.UpdateShareResponse, error) {
-	share, err := s.sm.UpdateShare(ctx, req.Ref, req.Field.GetPermissions()) // TODO(labkode): check what to update
+	expiration, updateExpiration, err := share.ExpirationFromOpaque(req.Opaque)
 	if err != nil {
 		return &collaboration.UpdateShareResponse{
-			Status: status.NewInternal(ctx, err, "error updating share"),
+			Status: status.NewInvalidArg(ctx, "invalid share expiration"),
+		}, nil
+	}
+	if share.IsExpired(expiration) {
+		return &collaboration.UpdateShareResponse{
+			Status: status.NewInvalidArg(ctx, "share expiration must be in the future"),
+		}, nil
+	}
+	if !updateExpiration && req.Field.GetPermissions() == nil {
+		return &collaboration.UpdateShareResponse{
+			Status: status.NewInvalidArg(ctx, "nothing to update"),
+		}, nil
+	}
+
+	var sh *collaboration.Share
+	if req.Field.GetPermissions() != nil {
+		sh, err = s.sm.UpdateShare(ctx, req.Ref, req.Field.GetPermissions()) // TODO(labkode): check what to update
+		if err != nil {
+			return &collaboration.UpdateShareResponse{
+				Status: status.NewInternal(ctx, err, "error updating share"),
+			}, nil
+		}
+	}
+	if updateExpiration {
+		sh, err = s.sm.UpdateShareExpiration(ctx, req.Ref, expiration)
+		if err != nil {
+			return &collaboration.UpdateShareResponse{
+				Status: status.NewInternal(ctx, err, "error updating share expiration"),
+			}, nil
+		}
+	}
+
+	opaque, err := s.expirationOpaque(ctx, sh)
+	if err != nil {
+		return &collaboration.UpdateShareResponse{
+			Status: status.NewInternal(ctx, err, "error getting share expiration"),
 		}, nil
 	}
 
 	res := &collaboration.UpdateShareResponse{
 		Status: status.NewOK(ctx),
-		Share:  share,
+		Opaque: opaque,
+		Share:  sh,
 	}
 	return res, nil
 }
@@ -236,8 +362,20 @@ func (s *service) ListReceivedShares(ctx context.Context, req *collaboration.Lis
 		}, nil
 	}
 
+	received := make([]*collaboration.Share, 0, len(shares))
+	for _, rs := range shares {
+		received = append(received, rs.Share)
+	}
+	opaque, err := s.expirationsOpaque(ctx, received)
+	if err != nil {
+		return &collaboration.ListReceivedSharesResponse{
+			Status: status.NewInternal(ctx, err, "error getting share expirations"),
+		}, nil
+	}
+
 	res := &collaboration.ListReceivedSharesResponse{
 		Status: status.NewOK(ctx),
+		Opaque: opaque,
 		Shares: shares,
 	}
 	return res, nil
@@ -254,8 +392,16 @@ func (s *service) GetReceivedShare(ctx context.Context, req *collaboration.GetRe
 		}, nil
 	}
 
+	opaque, err := s.expirationOpaque(ctx, share.Share)
+	if err != nil {
+		return &collaboration.GetReceivedShareResponse{
+			Status: status.NewInternal(ctx, err, "error getting share expiration"),
+		}, nil
+	}
+
 	res := &collaboration.GetReceivedShareResponse{
 		Status: status.NewOK(ctx),
+		Opaque: opaque,
 		Share:  share,
 	}
 	return res, nil
@@ -43,6 +43,8 @@ import (
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/token"
 	tokenmgr "github.com/cs3org/reva/pkg/token/manager/registry"
+	"github.com/cs3org/reva/pkg/token/session"
+	sessionregistry "github.com/cs3org/reva/pkg/token/session/registry"
 	"github.com/cs3org/reva/pkg/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
@@ -66,6 +68,9 @@ type config struct {
 	TokenManagers          map[string]map[string]interface{} `mapstructure:"token_managers"`
 	TokenWriter            string                            `mapstructure:"token_writer"`
 	TokenWriters           map[string]map[string]interface{} `mapstructure:"token_writers"`
+	// SessionStore holds the revoked tokens, which are refused. Revocation is disabled if empty.
+	SessionStore  string                            `mapstructure:"session_store"`
+	SessionStores map[string]map[string]interface{} `mapstructure:"session_stores"`
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -143,6 +148,11 @@ func New(m map[string]interface{}, unprotected []string) (global.Middleware, err
 		return nil, err
 	}
 
+	sessions, err := sessionregistry.GetStore(conf.SessionStore, conf.SessionStores)
+	if err != nil {
+		return nil, err
+	}
+
 	i, ok := tokenwriterregistry.NewTokenFuncs[conf.TokenWriter]
 	if !ok {
 		return nil, fmt.Errorf("token writer not found: %s", conf.TokenWriter)
@@ -173,7 +183,7 @@ func New(m map[string]interface{}, unprotected []string) (global.Middleware, err
 				isUnprotectedEndpoint = true
 			}
 
-			ctx, err := authenticateUser(w, r, conf, tokenStrategy, tokenManager, tokenWriter, credChain, isUnprotectedEndpoint)
+			ctx, err := authenticateUser(w, r, conf, tokenStrategy, tokenManager, sessions, tokenWriter, credChain, isUnprotectedEndpoint)
 			if err != nil {
 				if !isUnprotectedEndpoint {
 					return
@@ -187,7 +197,7 @@ func New(m map[string]interface{}, unprotected []string) (global.Middleware, err
 	return chain, nil
 }
 
-func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, tokenStrategy auth.TokenStrategy, tokenManager token.Manager, tokenWriter auth.TokenWriter, credChain map[string]auth.CredentialStrategy, isUnprotectedEndpoint bool) (context.Context, error) {
+func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, tokenStrategy auth.TokenStrategy, tokenManager token.Manager, sessions session.Store, tokenWriter auth.TokenWriter, credChain map[string]auth.CredentialStrategy, isUnprotectedEndpoint bool) (context.Context, error) {
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
 
@@ -206,7 +216,7 @@ func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, toke
 
 	token := tokenStrategy.GetToken(r)
 	if token != "" {
-		if user, ok := isTokenValid(r, tokenManager, token); ok {
+		if user, ok := isTokenValid(r, tokenManager, sessions, token); ok {
 			if err := insertGroupsInUser(ctx, userGroupsCache, client, user); err != nil {
 				logError(isUnprotectedEndpoint, log, err, "got an error retrieving groups for user "+user.Username, http.StatusInternalServerError, w)
 				return nil, err
@@ -323,7 +333,7 @@ func insertGroupsInUser(ctx context.Context, userGroupsCache gcache.Cache, clien
 	return nil
 }
 
-func isTokenValid(r *http.Request, tokenManager token.Manager, token string) (*userpb.User, bool) {
+func isTokenValid(r *http.Request, tokenManager token.Manager, sessions session.Store, token string) (*userpb.User, bool) {
 	ctx := r.Context()
 
 	u, tokenScope, err := tokenManager.DismantleToken(ctx, token)
@@ -331,6 +341,11 @@ func isTokenValid(r *http.Request, tokenManager token.Manager, token string) (*u
 		return nil, false
 	}
 
+	if err := session.Check(ctx, sessions, tokenManager, token); err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Msg("refusing access token")
+		return nil, false
+	}
+
 	// ensure access to the resource is allowed
 	ok, err := scope.VerifyScope(ctx, tokenScope, r.URL.Path)
 	if err != nil {
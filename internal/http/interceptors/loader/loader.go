@@ -22,5 +22,6 @@ import (
 	// Load core HTTP middlewares.
 	_ "github.com/cs3org/reva/internal/http/interceptors/cors"
 	_ "github.com/cs3org/reva/internal/http/interceptors/providerauthorizer"
+	_ "github.com/cs3org/reva/internal/http/interceptors/ratelimit"
 	// Add your own middleware.
 )
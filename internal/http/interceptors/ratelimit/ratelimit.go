@@ -0,0 +1,254 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package ratelimit rejects the http requests of the clients
+// exceeding the configured rates.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/ratelimit"
+	"github.com/cs3org/reva/pkg/ratelimit/registry"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPriority = 300
+)
+
+func init() {
+	global.RegisterMiddleware("ratelimit", New)
+	global.RegisterPreAuthMiddleware("ratelimit", NewFailedAuth)
+}
+
+type config struct {
+	Store          string                            `mapstructure:"store" docs:"memory;The store keeping the counters, redis to share them between the replicas."`
+	Stores         map[string]map[string]interface{} `mapstructure:"stores" docs:"url:pkg/ratelimit/redis/redis.go"`
+	Rules          []*ratelimit.Rule                 `mapstructure:"rules" docs:"url:pkg/ratelimit/ratelimit.go"`
+	FailedAuth     *ratelimit.Rule                   `mapstructure:"failed_auth" docs:";The rate and the burst of the requests failing the authentication allowed per ip. They are not limited when unset."`
+	ForwardedFor   bool                              `mapstructure:"forwarded_for" docs:"false;Whether to take the address of the client from the X-Forwarded-For header, when revad is behind a proxy."`
+	TrustedProxies []string                          `mapstructure:"trusted_proxies" docs:";The addresses, or CIDRs, of the proxies in front of revad. The client is the rightmost address of the X-Forwarded-For header which is not a trusted proxy. When empty, only the peer is trusted."`
+	Priority       int                               `mapstructure:"priority" docs:"300;The priority of the middleware."`
+
+	proxies []*net.IPNet
+}
+
+func (c *config) init() error {
+	if c.Store == "" {
+		c.Store = "memory"
+	}
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+	for _, p := range c.TrustedProxies {
+		if !strings.Contains(p, "/") {
+			if ip := net.ParseIP(p); ip != nil && ip.To4() != nil {
+				p += "/32"
+			} else {
+				p += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return errors.Wrapf(err, "ratelimit: invalid trusted proxy %q", p)
+		}
+		c.proxies = append(c.proxies, n)
+	}
+	return nil
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "ratelimit: error decoding conf")
+	}
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// New returns a new middleware that rejects
+// the requests exceeding the configured rates.
+func New(m map[string]interface{}) (global.Middleware, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	store, err := registry.GetStore(c.Store, c.Stores)
+	if err != nil {
+		return nil, 0, err
+	}
+	l, err := ratelimit.NewLimiter(c.Rules, store)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			ok, wait, err := l.Allow(ctx, c.request(r))
+			if err != nil {
+				// the requests are let through when the store cannot be reached
+				appctx.GetLogger(ctx).Error().Err(err).Str("path", r.URL.Path).Msg("ratelimit: error checking the limits, allowing the request")
+			}
+			if err == nil && !ok {
+				tooManyRequests(w, r, wait)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}, c.Priority, nil
+}
+
+// NewFailedAuth returns a new middleware, chained before the auth, that rejects the requests
+// of the clients which failed to authenticate too often. It returns no middleware when the
+// failed authentications are not limited.
+func NewFailedAuth(m map[string]interface{}) (global.Middleware, int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.FailedAuth == nil {
+		return nil, 0, nil
+	}
+
+	store, err := registry.GetStore(c.Store, c.Stores)
+	if err != nil {
+		return nil, 0, err
+	}
+	f, err := ratelimit.NewFailedAuth(c.FailedAuth, store)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			ip := c.clientIP(r)
+			ok, wait, err := f.Allow(ctx, ip)
+			if err != nil {
+				appctx.GetLogger(ctx).Error().Err(err).Str("path", r.URL.Path).Msg("ratelimit: error checking the failed authentications, allowing the request")
+			}
+			if err == nil && !ok {
+				tooManyRequests(w, r, wait)
+				return
+			}
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			h.ServeHTTP(sw, r)
+			if sw.status == http.StatusUnauthorized {
+				if err := f.Fail(ctx, ip); err != nil {
+					appctx.GetLogger(ctx).Error().Err(err).Str("path", r.URL.Path).Msg("ratelimit: error counting the failed authentication")
+				}
+			}
+		})
+	}, c.Priority, nil
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	appctx.GetLogger(r.Context()).Warn().Str("path", r.URL.Path).Dur("retry_after", wait).Msg("ratelimit: too many requests")
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+// request describes the http request for the limiter.
+func (c *config) request(r *http.Request) *ratelimit.Request {
+	req := &ratelimit.Request{Method: r.URL.Path}
+	req.User, _ = ctxpkg.ContextGetUser(r.Context())
+
+	// the app passwords are sent with basic auth, the other clients are told apart by their token
+	if _, password, ok := r.BasicAuth(); ok {
+		req.Credential = password
+	} else {
+		req.Credential, _ = ctxpkg.ContextGetToken(r.Context())
+	}
+
+	req.IP = c.clientIP(r)
+	return req
+}
+
+// clientIP returns the address of the client. Behind proxies, it is the rightmost
+// address of the X-Forwarded-For header which is not a trusted proxy, as the
+// addresses on its left are set by the client and cannot be trusted.
+func (c *config) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if !c.ForwardedFor || (len(c.proxies) > 0 && !c.trusted(ip)) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !c.trusted(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+func (c *config) trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range c.proxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusWriter records the status of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,139 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	_ "github.com/cs3org/reva/pkg/ratelimit/memory"
+)
+
+func TestMiddleware(t *testing.T) {
+	m, _, err := New(map[string]interface{}{
+		"forwarded_for":   true,
+		"trusted_proxies": []string{"192.0.2.0/24", "10.0.0.254"},
+		"rules": []map[string]interface{}{
+			{"match": []string{"/remote.php/"}, "per": "ip", "rate": 1, "burst": 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	get := func(path, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("X-Forwarded-For", ip+", 10.0.0.254")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := get("/remote.php/dav/files/einstein", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be allowed, got %d", w.Code)
+	}
+	w := get("/remote.php/dav/files/einstein", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected a 429 with Retry-After, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := get("/remote.php/dav/files/einstein", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("expected the other client to be allowed, got %d", w.Code)
+	}
+	if w := get("/ocs/v1.php/cloud/user", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("expected the unmatched route to be allowed, got %d", w.Code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	c, err := parseConfig(map[string]interface{}{
+		"forwarded_for":   true,
+		"trusted_proxies": []string{"192.0.2.0/24", "10.0.0.254"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		remote, fwd, ip string
+	}{
+		// the addresses set by the client on the left are ignored
+		{"192.0.2.1:1234", "10.0.0.1, 203.0.113.7, 10.0.0.254", "203.0.113.7"},
+		{"192.0.2.1:1234", "203.0.113.7", "203.0.113.7"},
+		// the header of an untrusted peer is ignored
+		{"198.51.100.1:1234", "203.0.113.7", "198.51.100.1"},
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.fwd != "" {
+			r.Header.Set("X-Forwarded-For", tt.fwd)
+		}
+		if ip := c.clientIP(r); ip != tt.ip {
+			t.Errorf("%s %q: expected %s, got %s", tt.remote, tt.fwd, tt.ip, ip)
+		}
+	}
+
+	if _, err := parseConfig(map[string]interface{}{"trusted_proxies": []string{"proxy"}}); err == nil {
+		t.Fatal("expected an invalid trusted proxy to be rejected")
+	}
+}
+
+func TestFailedAuth(t *testing.T) {
+	m, _, err := NewFailedAuth(map[string]interface{}{
+		"failed_auth": map[string]interface{}{"rate": 0.001, "burst": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	get := func(ip string, authenticated bool) int {
+		r := httptest.NewRequest(http.MethodGet, "/remote.php/dav/files/einstein", nil)
+		r.RemoteAddr = ip + ":1234"
+		if authenticated {
+			r.SetBasicAuth("einstein", "relativity")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if get("10.0.0.1", true) != http.StatusOK || get("10.0.0.1", true) != http.StatusOK || get("10.0.0.1", true) != http.StatusOK {
+		t.Fatal("expected the authenticated requests not to be counted")
+	}
+	if get("10.0.0.1", false) != http.StatusUnauthorized || get("10.0.0.1", false) != http.StatusUnauthorized {
+		t.Fatal("expected the failures to reach the auth")
+	}
+	if code := get("10.0.0.1", true); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the client to be blocked after its failures, got %d", code)
+	}
+	if get("10.0.0.2", false) != http.StatusUnauthorized {
+		t.Fatal("expected the other clients not to be blocked")
+	}
+
+	if m, _, err := NewFailedAuth(map[string]interface{}{}); err != nil || m != nil {
+		t.Fatalf("expected no middleware without failed_auth, got %v", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"time"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
@@ -34,6 +35,7 @@ import (
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/cs3org/reva/pkg/rhttp/datatx/utils/download"
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/storage/utils/downloader"
@@ -51,6 +53,7 @@ type svc struct {
 	log        *zerolog.Logger
 	walker     walker.Walker
 	downloader downloader.Downloader
+	checksums  *manager.Checksums
 
 	allowedFolders []*regexp.Regexp
 }
@@ -62,9 +65,10 @@ type Config struct {
 	Timeout        int64    `mapstructure:"timeout"`
 	Insecure       bool     `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
 	Name           string   `mapstructure:"name"`
-	MaxNumFiles    int64    `mapstructure:"max_num_files"`
-	MaxSize        int64    `mapstructure:"max_size"`
+	MaxNumFiles    int64    `mapstructure:"max_num_files" docs:"0;The maximum number of files and folders of an archive. A negative value means no limit."`
+	MaxSize        int64    `mapstructure:"max_size" docs:"0;The maximum size in bytes of the files of an archive. A negative value means no limit."`
 	AllowedFolders []string `mapstructure:"allowed_folders"`
+	ChecksumsCache int      `mapstructure:"checksums_cache_size" docs:"100000;The number of file checksums kept to resume zip downloads without downloading again the files before the requested range."`
 }
 
 func init() {
@@ -101,6 +105,7 @@ func New(conf map[string]interface{}, log *zerolog.Logger) (global.Service, erro
 		gtwClient:      gtw,
 		downloader:     downloader.NewDownloader(gtw, rhttp.Insecure(c.Insecure), rhttp.Timeout(time.Duration(c.Timeout*int64(time.Second)))),
 		walker:         walker.NewWalker(gtw),
+		checksums:      manager.NewChecksums(c.ChecksumsCache),
 		log:            log,
 		allowedFolders: allowedFolderRegex,
 	}, nil
@@ -115,6 +120,10 @@ func (c *Config) init() {
 		c.Name = "download"
 	}
 
+	if c.ChecksumsCache == 0 {
+		c.ChecksumsCache = 100000
+	}
+
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
 
@@ -228,16 +237,31 @@ func (s *svc) Handler() http.Handler {
 		arch, err := manager.NewArchiver(files, s.walker, s.downloader, manager.Config{
 			MaxNumFiles: s.config.MaxNumFiles,
 			MaxSize:     s.config.MaxSize,
+			Include:     v["include"],
+			Exclude:     v["exclude"],
+			Revisions:   v.Get("revisions") == "true",
+			Trash:       v.Get("trash") == "true",
+			Checksums:   s.checksums,
 		})
 		if err != nil {
 			s.writeHTTPError(rw, err)
 			return
 		}
 
-		userAgent := ua.Parse(r.Header.Get("User-Agent"))
+		var zipFormat bool
+		switch v.Get("format") {
+		case "zip":
+			zipFormat = true
+		case "tar":
+		case "":
+			zipFormat = ua.Parse(r.Header.Get("User-Agent")).OS == ua.Windows
+		default:
+			s.writeHTTPError(rw, errtypes.BadRequest("unknown archive format "+v.Get("format")))
+			return
+		}
 
 		archName := s.config.Name
-		if userAgent.OS == ua.Windows {
+		if zipFormat {
 			archName += ".zip"
 		} else {
 			archName += ".tar"
@@ -245,18 +269,70 @@ func (s *svc) Handler() http.Handler {
 
 		log.Debug().Msg("Requested the following files/folders to archive: " + render.Render(files))
 
+		// the manifest fixes the content of the archive, so that its size
+		// is known in advance and ranges of it can be requested
+		m, err := arch.Manifest(ctx)
+		if err != nil {
+			s.writeHTTPError(rw, err)
+			return
+		}
+
+		var size int64
+		if zipFormat {
+			size, err = arch.ZipSize(m)
+		} else {
+			size, err = arch.TarSize(m)
+		}
+		if err != nil {
+			s.writeHTTPError(rw, err)
+			return
+		}
+
+		etag := m.ETag()
 		rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", archName))
 		rw.Header().Set("Content-Transfer-Encoding", "binary")
+		rw.Header().Set("Accept-Ranges", "bytes")
+		rw.Header().Set("ETag", etag)
+
+		code := http.StatusOK
+		start, length := int64(0), size
+
+		if rng := r.Header.Get("Range"); rng != "" {
+			ranges, err := download.ParseRange(rng, size)
+			if err != nil {
+				if err == download.ErrNoOverlap {
+					rw.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+				}
+				rw.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			// a resumed download only gets a part of the archive if it did not change,
+			// multiple ranges are not supported and get the whole archive
+			ifRange := r.Header.Get("If-Range")
+			if len(ranges) == 1 && (ifRange == "" || ifRange == etag) {
+				start, length = ranges[0].Start, ranges[0].Length
+				code = http.StatusPartialContent
+				rw.Header().Set("Content-Range", ranges[0].ContentRange(size))
+			}
+		}
+
+		rw.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		rw.WriteHeader(code)
+
+		if r.Method == http.MethodHead {
+			return
+		}
 
 		// create the archive
-		if userAgent.OS == ua.Windows {
-			err = arch.CreateZip(ctx, rw)
+		if zipFormat {
+			err = arch.WriteZip(ctx, rw, m, start, length)
 		} else {
-			err = arch.CreateTar(ctx, rw)
+			err = arch.WriteTar(ctx, rw, m, start, length)
 		}
 
 		if err != nil {
-			s.writeHTTPError(rw, err)
+			// the headers are already sent, the client sees a truncated archive
+			log.Error().Err(err).Msg("error writing the archive")
 			return
 		}
 	})
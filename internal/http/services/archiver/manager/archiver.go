@@ -20,22 +20,37 @@ package manager
 
 import (
 	"archive/tar"
-	"archive/zip"
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"path"
 	"path/filepath"
-	"time"
 
-	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/storage/utils/downloader"
 	"github.com/cs3org/reva/pkg/storage/utils/walker"
 )
 
 // Config is the config for the Archiver.
 type Config struct {
+	// MaxNumFiles is the maximum number of entries of an archive, a negative value means no limit.
 	MaxNumFiles int64
-	MaxSize     int64
+	// MaxSize is the maximum size of the files of an archive, a negative value means no limit.
+	MaxSize int64
+	// Include keeps only the files matching one of the glob patterns.
+	Include []string
+	// Exclude skips the files and the folders matching one of the glob patterns.
+	Exclude []string
+	// Revisions adds the revisions of the files to the archive.
+	Revisions bool
+	// Trash adds the items of the recycle bin deleted from the archived folders.
+	Trash bool
+	// Checksums keeps the checksums of the files downloaded into zips,
+	// so that resumed downloads can skip them. It can be nil.
+	Checksums *Checksums
 }
 
 // Archiver is the struct able to create an archive.
@@ -53,6 +68,12 @@ func NewArchiver(files []string, w walker.Walker, d downloader.Downloader, confi
 		return nil, ErrEmptyList{}
 	}
 
+	for _, p := range append(append([]string{}, config.Include...), config.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, errtypes.BadRequest("invalid pattern " + p)
+		}
+	}
+
 	dir := getDeepestCommonDir(files)
 	if pathIn(files, dir) {
 		dir = filepath.Dir(dir)
@@ -113,143 +134,256 @@ func getDeepestCommonDir(files []string) string {
 
 // CreateTar creates a tar and write it into the dst Writer.
 func (a *Archiver) CreateTar(ctx context.Context, dst io.Writer) error {
-	w := tar.NewWriter(dst)
+	m, err := a.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	return a.WriteTar(ctx, dst, m, 0, -1)
+}
 
-	var filesCount, sizeFiles int64
+// CreateZip creates a zip and write it into the dst Writer.
+func (a *Archiver) CreateZip(ctx context.Context, dst io.Writer) error {
+	m, err := a.Manifest(ctx)
+	if err != nil {
+		return err
+	}
+	return a.WriteZip(ctx, dst, m, 0, -1)
+}
 
-	for _, root := range a.files {
-		err := a.walker.Walk(ctx, root, func(path string, info *provider.ResourceInfo, err error) error {
-			if err != nil {
-				return err
-			}
+// TarSize returns the size in bytes of the tar built from the manifest.
+func (a *Archiver) TarSize(m *Manifest) (int64, error) {
+	var size int64
+	for _, e := range m.Entries {
+		header, err := tarHeader(e)
+		if err != nil {
+			return 0, err
+		}
+		size += int64(len(header)) + int64(e.Size) + tarPadding(e.Size)
+	}
+	return size + tarEndLen, nil
+}
 
-			isDir := info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER
+// ZipSize returns the size in bytes of the zip built from the manifest.
+func (a *Archiver) ZipSize(m *Manifest) (int64, error) {
+	l, err := newZipLayout(m)
+	if err != nil {
+		return 0, err
+	}
+	return int64(l.size), nil
+}
 
-			filesCount++
-			if filesCount > a.config.MaxNumFiles {
-				return ErrMaxFileCount{}
-			}
+// WriteTar writes the bytes of the tar built from the manifest in the range [offset, offset+length)
+// into the dst Writer. A negative length writes the archive up to its end.
+// As a tar has no checksum of the content, the files before the range are not downloaded.
+func (a *Archiver) WriteTar(ctx context.Context, dst io.Writer, m *Manifest, offset, length int64) error {
+	rw := &rangeWriter{w: dst, start: offset, end: end(offset, length)}
+	err := writeTar(rw, m, func(e *Entry) error {
+		if rw.before(int64(e.Size)) {
+			rw.skip(int64(e.Size))
+			return nil
+		}
+		return a.downloadExact(ctx, e, rw)
+	})
+	if err == errRangeDone {
+		return nil
+	}
+	return err
+}
+
+// WriteZip writes the bytes of the zip built from the manifest in the range [offset, offset+length)
+// into the dst Writer. A negative length writes the archive up to its end.
+// The central directory holds the checksum of every file, so the files before
+// the range are only skipped when their checksum is known from an earlier download.
+func (a *Archiver) WriteZip(ctx context.Context, dst io.Writer, m *Manifest, offset, length int64) error {
+	l, err := newZipLayout(m)
+	if err != nil {
+		return err
+	}
 
-			if !isDir {
-				// only add the size if the resource is not a directory
-				// as its size could be resursive-computed, and we would
-				// count the files not only once
-				sizeFiles += int64(info.Size)
-				if sizeFiles > a.config.MaxSize {
-					return ErrMaxSize{}
-				}
+	rw := &rangeWriter{w: dst, start: offset, end: end(offset, length)}
+	err = l.write(rw, func(r *zipRecord) error {
+		if rw.before(int64(r.Size)) {
+			if crc, ok := a.config.Checksums.get(r.Entry); ok {
+				r.crc = crc
+				rw.skip(int64(r.Size))
+				return nil
 			}
+		}
 
-			// TODO (gdelmont): remove duplicates if the resources requested overlaps
-			fileName, err := filepath.Rel(a.dir, path)
+		h := crc32.NewIEEE()
+		if err := a.downloadExact(ctx, r.Entry, io.MultiWriter(rw, h)); err != nil {
+			return err
+		}
+		r.crc = h.Sum32()
+		a.config.Checksums.add(r.Entry, r.crc)
+		return nil
+	})
+	if err == errRangeDone {
+		return nil
+	}
+	return err
+}
 
-			if err != nil {
-				return err
-			}
+// downloadExact downloads the content of a file entry, failing when its size
+// is not the one of the manifest, as the archive would be corrupted.
+func (a *Archiver) downloadExact(ctx context.Context, e *Entry, w io.Writer) error {
+	c := &countingWriter{w: w}
+	if err := a.download(ctx, e, c); err != nil {
+		return err
+	}
+	if c.n != int64(e.Size) {
+		return errtypes.InternalError(fmt.Sprintf("%s changed while creating the archive", e.Name))
+	}
+	return nil
+}
 
-			header := tar.Header{
-				Name:    fileName,
-				ModTime: time.Unix(int64(info.Mtime.Seconds), 0),
-			}
+func (a *Archiver) download(ctx context.Context, e *Entry, w io.Writer) error {
+	switch {
+	case e.Revision != "":
+		rd, ok := a.downloader.(downloader.RevisionDownloader)
+		if !ok {
+			return errtypes.NotSupported("downloading revisions")
+		}
+		return rd.DownloadRevision(ctx, e.Path, e.Revision, w)
+	case e.Trash != "":
+		rd, ok := a.downloader.(downloader.RecycleDownloader)
+		if !ok {
+			return errtypes.NotSupported("downloading recycle items")
+		}
+		return rd.DownloadRecycleItem(ctx, e.Path, e.Trash, w)
+	}
+	return a.downloader.Download(ctx, e.Path, w)
+}
 
-			if isDir {
-				// the resource is a folder
-				header.Mode = 0755
-				header.Typeflag = tar.TypeDir
-			} else {
-				header.Mode = 0644
-				header.Typeflag = tar.TypeReg
-				header.Size = int64(info.Size)
-			}
+const (
+	tarBlockSize = 512
+	// tarEndLen is the length of the two zero blocks ending a tar.
+	tarEndLen = 2 * tarBlockSize
+)
 
-			err = w.WriteHeader(&header)
-			if err != nil {
-				return err
-			}
+// tarHeader returns the encoded header of an entry, including the pax records
+// needed for long names or big files. The encoding does not depend on the
+// position in the archive, which allows computing the size of a tar upfront.
+func tarHeader(e *Entry) ([]byte, error) {
+	header := tar.Header{
+		Name:    e.Name,
+		ModTime: e.Mtime,
+	}
 
-			if !isDir {
-				err = a.downloader.Download(ctx, path, w)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+	if e.IsDir {
+		// the resource is a folder
+		header.Mode = 0755
+		header.Typeflag = tar.TypeDir
+	} else {
+		header.Mode = 0644
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(e.Size)
+	}
 
-		if err != nil {
-			return err
-		}
+	var b bytes.Buffer
+	if err := tar.NewWriter(&b).WriteHeader(&header); err != nil {
+		return nil, err
 	}
-	return w.Close()
+	return b.Bytes(), nil
 }
 
-// CreateZip creates a zip and write it into the dst Writer.
-func (a *Archiver) CreateZip(ctx context.Context, dst io.Writer) error {
-	w := zip.NewWriter(dst)
+// tarPadding returns the number of zero bytes filling the last block of a content.
+func tarPadding(size uint64) int64 {
+	return int64(-size % tarBlockSize)
+}
 
-	var filesCount, sizeFiles int64
+// contentFunc writes the content of a file entry.
+type contentFunc func(e *Entry) error
 
-	for _, root := range a.files {
-		err := a.walker.Walk(ctx, root, func(path string, info *provider.ResourceInfo, err error) error {
-			if err != nil {
+func writeTar(w io.Writer, m *Manifest, content contentFunc) error {
+	for _, e := range m.Entries {
+		header, err := tarHeader(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(header); err != nil {
+			return err
+		}
+
+		if !e.IsDir {
+			if err := content(e); err != nil {
+				return err
+			}
+			if _, err := w.Write(zeros[:tarPadding(e.Size)]); err != nil {
 				return err
 			}
+		}
+	}
+	_, err := w.Write(zeros[:tarEndLen])
+	return err
+}
 
-			isDir := info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER
+var zeros = make([]byte, tarEndLen)
 
-			filesCount++
-			if filesCount > a.config.MaxNumFiles {
-				return ErrMaxFileCount{}
-			}
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-			if !isDir {
-				// only add the size if the resource is not a directory
-				// as its size could be resursive-computed, and we would
-				// count the files not only once
-				sizeFiles += int64(info.Size)
-				if sizeFiles > a.config.MaxSize {
-					return ErrMaxSize{}
-				}
-			}
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
 
-			// TODO (gdelmont): remove duplicates if the resources requested overlaps
-			fileName, err := filepath.Rel(a.dir, path)
-			if err != nil {
-				return err
-			}
+// errRangeDone stops writing an archive once the requested range has been written.
+var errRangeDone = errors.New("range written")
 
-			if fileName == "" {
-				return nil
-			}
+// rangeWriter only forwards the bytes in [start, end) to the underlying writer.
+// A negative end means up to the end of the stream.
+type rangeWriter struct {
+	w          io.Writer
+	start, end int64
+	pos        int64
+}
 
-			header := zip.FileHeader{
-				Name:     fileName,
-				Modified: time.Unix(int64(info.Mtime.Seconds), 0),
-			}
+func end(offset, length int64) int64 {
+	if length < 0 {
+		return -1
+	}
+	return offset + length
+}
 
-			if isDir {
-				header.Name += "/"
-			} else {
-				header.UncompressedSize64 = info.Size
-			}
+// before tells whether the next n bytes are all before the range.
+func (r *rangeWriter) before(n int64) bool {
+	return r.pos+n <= r.start
+}
 
-			dst, err := w.CreateHeader(&header)
-			if err != nil {
-				return err
-			}
+// skip moves forward of n bytes before the range without writing them.
+func (r *rangeWriter) skip(n int64) {
+	r.pos += n
+}
 
-			if !isDir {
-				err = a.downloader.Download(ctx, path, dst)
-				if err != nil {
-					return err
-				}
-			}
-			return nil
-		})
+func (r *rangeWriter) Write(p []byte) (int, error) {
+	n := int64(len(p))
+	from, to := r.pos, r.pos+n
+	r.pos = to
 
-		if err != nil {
-			return err
-		}
+	if to <= r.start {
+		return len(p), nil
+	}
+	if r.end >= 0 && from >= r.end {
+		return 0, errRangeDone
+	}
+
+	lo, hi := int64(0), n
+	if from < r.start {
+		lo = r.start - from
+	}
+	if r.end >= 0 && to > r.end {
+		hi = r.end - from
+	}
+	if _, err := r.w.Write(p[lo:hi]); err != nil {
+		return 0, err
+	}
+	if r.end >= 0 && to >= r.end {
+		return len(p), errRangeDone
 	}
-	return w.Close()
+	return len(p), nil
 }
@@ -0,0 +1,61 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"fmt"
+
+	"github.com/bluele/gcache"
+)
+
+// Checksums keeps the CRC32 checksums of the files written into zips.
+// A file is identified by its etag, so a checksum is not used anymore
+// once the file changes.
+type Checksums struct {
+	cache gcache.Cache
+}
+
+// NewChecksums creates a cache of the checksums of at most size files.
+func NewChecksums(size int) *Checksums {
+	return &Checksums{
+		cache: gcache.New(size).LRU().Build(),
+	}
+}
+
+func checksumKey(e *Entry) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%d", e.Path, e.Revision, e.Trash, e.Etag, e.Size)
+}
+
+func (c *Checksums) get(e *Entry) (uint32, bool) {
+	if c == nil {
+		return 0, false
+	}
+	v, err := c.cache.Get(checksumKey(e))
+	if err != nil {
+		return 0, false
+	}
+	return v.(uint32), true
+}
+
+func (c *Checksums) add(e *Entry, crc uint32) {
+	if c == nil {
+		return
+	}
+	_ = c.cache.Set(checksumKey(e), crc)
+}
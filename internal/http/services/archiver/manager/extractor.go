@@ -0,0 +1,376 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/uploader"
+)
+
+// ConflictPolicy tells what to do when extracting a file over an existing one.
+// The folders of an archive are always merged with the existing ones.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip keeps the existing file and skips the one of the archive.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing file with the one of the archive.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictRename extracts the file of the archive next to the existing one, under a new name.
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// ExtractorConfig is the config for the Extractor.
+type ExtractorConfig struct {
+	// MaxNumFiles is the maximum number of entries of an archive, a negative value means no limit.
+	MaxNumFiles int64
+	// MaxSize is the maximum size of the files of an archive, a negative value means no limit.
+	MaxSize int64
+	// Conflict is the policy applied to the files which already exist, skip by default.
+	Conflict ConflictPolicy
+}
+
+// Progress tells how far the extraction of an archive is.
+type Progress struct {
+	TotalEntries int64
+	TotalSize    int64
+	Entries      int64
+	Size         int64
+	Skipped      int64
+}
+
+// Extractor is the struct able to extract an archive into a folder.
+type Extractor struct {
+	target   string
+	uploader uploader.Uploader
+	config   ExtractorConfig
+
+	// folders holds the folders known to exist
+	folders map[string]bool
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// archiveEntry is an entry of an archive, checked and ready to be extracted.
+type archiveEntry struct {
+	name  string
+	isDir bool
+	size  int64
+}
+
+// NewExtractor creates a new extractor able to extract archives into the target folder.
+func NewExtractor(target string, u uploader.Uploader, config ExtractorConfig) (*Extractor, error) {
+	switch config.Conflict {
+	case "":
+		config.Conflict = ConflictSkip
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return nil, errtypes.BadRequest("unknown conflict policy " + string(config.Conflict))
+	}
+
+	return &Extractor{
+		target:   path.Clean(target),
+		uploader: u,
+		config:   config,
+		folders:  map[string]bool{},
+	}, nil
+}
+
+// Progress returns the progress of the extraction.
+func (e *Extractor) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.progress
+}
+
+// ExtractTar extracts the tar read from r into the target folder.
+func (e *Extractor) ExtractTar(ctx context.Context, r io.ReadSeeker) error {
+	run, err := e.PrepareTar(ctx, r)
+	if err != nil {
+		return err
+	}
+	return run(ctx)
+}
+
+// ExtractZip extracts the zip read from r, of the given size, into the target folder.
+func (e *Extractor) ExtractZip(ctx context.Context, r io.ReaderAt, size int64) error {
+	run, err := e.PrepareZip(ctx, r, size)
+	if err != nil {
+		return err
+	}
+	return run(ctx)
+}
+
+// PrepareTar checks all the entries of the tar read from r against the limits,
+// and returns the function extracting them. The tar is read twice.
+func (e *Extractor) PrepareTar(ctx context.Context, r io.ReadSeeker) (func(context.Context) error, error) {
+	entries := []*archiveEntry{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errtypes.BadRequest("invalid tar: " + err.Error())
+		}
+		entry, err := checkEntry(hdr.Name, hdr.Typeflag == tar.TypeDir, hdr.Typeflag == tar.TypeReg, hdr.Size)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := e.start(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		tr := tar.NewReader(r)
+		for _, entry := range entries {
+			if _, err := tr.Next(); err != nil {
+				return err
+			}
+			if err := e.extract(ctx, entry, tr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// PrepareZip checks all the entries of the zip read from r, of the given size,
+// against the limits, and returns the function extracting them.
+func (e *Extractor) PrepareZip(ctx context.Context, r io.ReaderAt, size int64) (func(context.Context) error, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errtypes.BadRequest("invalid zip: " + err.Error())
+	}
+
+	entries := make([]*archiveEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		mode := f.Mode()
+		entry, err := checkEntry(f.Name, mode.IsDir(), mode.IsRegular(), int64(f.UncompressedSize64))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := e.start(ctx, entries); err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		for i, entry := range entries {
+			if err := e.extractZipFile(ctx, entry, zr.File[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+func (e *Extractor) extractZipFile(ctx context.Context, entry *archiveEntry, f *zip.File) error {
+	if entry == nil || entry.isDir {
+		return e.extract(ctx, entry, nil)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return e.extract(ctx, entry, rc)
+}
+
+// checkEntry validates an entry of an archive, returning nil for the ones
+// which are not extracted, such as the links.
+func checkEntry(name string, isDir, isRegular bool, size int64) (*archiveEntry, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || (len(name) > 1 && name[1] == ':') {
+		return nil, errtypes.BadRequest("absolute path in archive: " + name)
+	}
+	for _, el := range strings.Split(name, "/") {
+		if el == ".." {
+			return nil, errtypes.BadRequest("path outside of the archive: " + name)
+		}
+	}
+
+	name = path.Clean(name)
+	if name == "." || (!isDir && !isRegular) {
+		return nil, nil
+	}
+	return &archiveEntry{name: name, isDir: isDir, size: size}, nil
+}
+
+// start applies the limits to the entries and checks that the target is a folder.
+func (e *Extractor) start(ctx context.Context, entries []*archiveEntry) error {
+	var p Progress
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		p.TotalEntries++
+		if e.config.MaxNumFiles >= 0 && p.TotalEntries > e.config.MaxNumFiles {
+			return ErrMaxFileCount{}
+		}
+		if !entry.isDir {
+			p.TotalSize += entry.size
+			if e.config.MaxSize >= 0 && p.TotalSize > e.config.MaxSize {
+				return ErrMaxSize{}
+			}
+		}
+	}
+
+	info, err := e.uploader.Stat(ctx, e.target)
+	if err != nil {
+		return err
+	}
+	if info.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		return errtypes.BadRequest(e.target + " is not a folder")
+	}
+	e.folders[e.target] = true
+
+	e.mu.Lock()
+	e.progress = p
+	e.mu.Unlock()
+	return nil
+}
+
+func (e *Extractor) extract(ctx context.Context, entry *archiveEntry, r io.Reader) error {
+	if entry == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p := path.Join(e.target, entry.name)
+	if entry.isDir {
+		if err := e.ensureFolder(ctx, p); err != nil {
+			return err
+		}
+		e.update(0, false)
+		return nil
+	}
+
+	if err := e.ensureFolder(ctx, path.Dir(p)); err != nil {
+		return err
+	}
+	dst, err := e.destination(ctx, p)
+	if err != nil {
+		return err
+	}
+	if dst == "" {
+		e.update(0, true)
+		return nil
+	}
+	if err := e.uploader.Upload(ctx, dst, r, entry.size); err != nil {
+		return err
+	}
+	e.update(entry.size, false)
+	return nil
+}
+
+func (e *Extractor) update(size int64, skipped bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.progress.Entries++
+	e.progress.Size += size
+	if skipped {
+		e.progress.Skipped++
+	}
+}
+
+// ensureFolder creates the folder p and its parents if they do not exist.
+func (e *Extractor) ensureFolder(ctx context.Context, p string) error {
+	if e.folders[p] {
+		return nil
+	}
+	if err := e.ensureFolder(ctx, path.Dir(p)); err != nil {
+		return err
+	}
+
+	info, err := e.uploader.Stat(ctx, p)
+	switch err.(type) {
+	case nil:
+		if info.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+			return errtypes.AlreadyExists("a file exists at the path of the folder " + p)
+		}
+	case errtypes.IsNotFound:
+		if err := e.uploader.CreateFolder(ctx, p); err != nil {
+			if _, ok := err.(errtypes.IsAlreadyExists); !ok {
+				return err
+			}
+		}
+	default:
+		return err
+	}
+	e.folders[p] = true
+	return nil
+}
+
+// destination returns the path where the file p of the archive is extracted,
+// according to the conflict policy, or an empty path if the file is skipped.
+func (e *Extractor) destination(ctx context.Context, p string) (string, error) {
+	info, err := e.uploader.Stat(ctx, p)
+	switch err.(type) {
+	case nil:
+	case errtypes.IsNotFound:
+		return p, nil
+	default:
+		return "", err
+	}
+
+	switch e.config.Conflict {
+	case ConflictOverwrite:
+		if info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+			return "", errtypes.AlreadyExists("a folder exists at the path of the file " + p)
+		}
+		return p, nil
+	case ConflictRename:
+		ext := path.Ext(p)
+		base := strings.TrimSuffix(p, ext)
+		for i := 1; ; i++ {
+			candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+			_, err := e.uploader.Stat(ctx, candidate)
+			switch err.(type) {
+			case nil:
+			case errtypes.IsNotFound:
+				return candidate, nil
+			default:
+				return "", err
+			}
+		}
+	default:
+		return "", nil
+	}
+}
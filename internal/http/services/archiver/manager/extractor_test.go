@@ -0,0 +1,237 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+	upMock "github.com/cs3org/reva/pkg/storage/utils/uploader/mock"
+	"github.com/cs3org/reva/pkg/test"
+)
+
+type archiveFile struct {
+	name    string
+	content string
+	dir     bool
+}
+
+func makeTar(t *testing.T, files []archiveFile) *bytes.Reader {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(f.content))}
+		if f.dir {
+			hdr.Mode, hdr.Typeflag, hdr.Size = 0755, tar.TypeDir, 0
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b.Bytes())
+}
+
+func makeZip(t *testing.T, files []archiveFile) *bytes.Reader {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, f := range files {
+		name := f.name
+		if f.dir {
+			name += "/"
+		}
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(b.Bytes())
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      test.Dir
+		files    []archiveFile
+		config   ExtractorConfig
+		expected test.Dir
+		err      error
+	}{
+		{
+			name: "nested folders without folder entries",
+			src:  test.Dir{},
+			files: []archiveFile{
+				{name: "foo/bar/file.txt", content: "file"},
+				{name: "empty", dir: true},
+				{name: "main.py", content: "print(\"Hello world!\")\n"},
+			},
+			config: ExtractorConfig{MaxNumFiles: -1, MaxSize: -1},
+			expected: test.Dir{
+				"foo": test.Dir{
+					"bar": test.Dir{
+						"file.txt": test.File{Content: "file"},
+					},
+				},
+				"empty":   test.Dir{},
+				"main.py": test.File{Content: "print(\"Hello world!\")\n"},
+			},
+		},
+		{
+			name: "conflict skip",
+			src: test.Dir{
+				"foo": test.Dir{
+					"file.txt": test.File{Content: "old"},
+				},
+			},
+			files: []archiveFile{
+				{name: "foo/file.txt", content: "new"},
+				{name: "foo/other.txt", content: "other"},
+			},
+			config: ExtractorConfig{MaxNumFiles: -1, MaxSize: -1},
+			expected: test.Dir{
+				"foo": test.Dir{
+					"file.txt":  test.File{Content: "old"},
+					"other.txt": test.File{Content: "other"},
+				},
+			},
+		},
+		{
+			name: "conflict overwrite",
+			src: test.Dir{
+				"foo": test.Dir{
+					"file.txt": test.File{Content: "old"},
+				},
+			},
+			files: []archiveFile{
+				{name: "foo/file.txt", content: "new"},
+			},
+			config: ExtractorConfig{MaxNumFiles: -1, MaxSize: -1, Conflict: ConflictOverwrite},
+			expected: test.Dir{
+				"foo": test.Dir{
+					"file.txt": test.File{Content: "new"},
+				},
+			},
+		},
+		{
+			name: "conflict rename",
+			src: test.Dir{
+				"file.txt":     test.File{Content: "old"},
+				"file (1).txt": test.File{Content: "older"},
+			},
+			files: []archiveFile{
+				{name: "file.txt", content: "new"},
+			},
+			config: ExtractorConfig{MaxNumFiles: -1, MaxSize: -1, Conflict: ConflictRename},
+			expected: test.Dir{
+				"file.txt":     test.File{Content: "old"},
+				"file (1).txt": test.File{Content: "older"},
+				"file (2).txt": test.File{Content: "new"},
+			},
+		},
+		{
+			name: "path traversal",
+			src:  test.Dir{},
+			files: []archiveFile{
+				{name: "foo/file.txt", content: "file"},
+				{name: "foo/../../evil", content: "evil"},
+			},
+			config:   ExtractorConfig{MaxNumFiles: -1, MaxSize: -1},
+			expected: test.Dir{},
+			err:      errtypes.BadRequest("path outside of the archive: foo/../../evil"),
+		},
+		{
+			name: "max num files",
+			src:  test.Dir{},
+			files: []archiveFile{
+				{name: "foo", dir: true},
+				{name: "foo/file.txt", content: "file"},
+			},
+			config:   ExtractorConfig{MaxNumFiles: 1, MaxSize: -1},
+			expected: test.Dir{},
+			err:      ErrMaxFileCount{},
+		},
+		{
+			name: "max size",
+			src:  test.Dir{},
+			files: []archiveFile{
+				{name: "foo.txt", content: "foo"},
+				{name: "bar.txt", content: "bar"},
+			},
+			config:   ExtractorConfig{MaxNumFiles: -1, MaxSize: 5},
+			expected: test.Dir{},
+			err:      ErrMaxSize{},
+		},
+	}
+
+	formats := map[string]func(*Extractor, []archiveFile) error{
+		"tar": func(e *Extractor, files []archiveFile) error {
+			return e.ExtractTar(context.TODO(), makeTar(t, files))
+		},
+		"zip": func(e *Extractor, files []archiveFile) error {
+			r := makeZip(t, files)
+			return e.ExtractZip(context.TODO(), r, r.Size())
+		},
+	}
+
+	for format, extract := range formats {
+		for _, tt := range tests {
+			t.Run(format+" "+tt.name, func(t *testing.T) {
+				tmpdir, cleanup, err := test.NewTestDir(tt.src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer cleanup()
+
+				e, err := NewExtractor(tmpdir, upMock.NewUploader(), tt.config)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err = extract(e, tt.files)
+				if err != tt.err {
+					t.Fatalf("error result different from expected: got=%v, expected=%v", err, tt.err)
+				}
+
+				expectedTmp, cleanup, err := test.NewTestDir(tt.expected)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer cleanup()
+				if !test.DirEquals(tmpdir, expectedTmp) {
+					t.Fatalf("extracted dir different from expected")
+				}
+			})
+		}
+	}
+}
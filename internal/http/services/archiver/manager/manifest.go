@@ -0,0 +1,301 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/storage/utils/downloader"
+)
+
+const (
+	// revisionsDir is the folder of the archive containing the revisions of the archived files.
+	revisionsDir = ".versions"
+	// trashDir is the folder of the archive containing the recycle items of the archived folders.
+	trashDir = ".trash"
+)
+
+// Entry is a file or a folder of an archive.
+type Entry struct {
+	// Name is the path of the entry inside the archive.
+	Name string
+	// Path is the path of the resource in reva.
+	Path string
+	// Revision is the key of the revision the entry contains, if any.
+	Revision string
+	// Trash is the key of the recycle item the entry contains, if any.
+	Trash string
+	Size  uint64
+	Mtime time.Time
+	Etag  string
+	IsDir bool
+}
+
+// Manifest lists the entries of an archive in the order they are written.
+// Building the same archive twice from a manifest gives the same bytes,
+// which is what allows serving ranges of an archive.
+type Manifest struct {
+	Entries []*Entry
+}
+
+// ETag returns an identifier of the archive described by the manifest,
+// which changes whenever one of the entries changes.
+func (m *Manifest) ETag() string {
+	h := sha256.New()
+	for _, e := range m.Entries {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%d\x00%d\x00%s\x00%t\n", e.Name, e.Path, e.Revision, e.Trash, e.Size, e.Mtime.Unix(), e.Etag, e.IsDir)
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// Manifest walks the files of the archive, applying the filters and the limits of the config.
+func (a *Archiver) Manifest(ctx context.Context) (*Manifest, error) {
+	m := &Manifest{}
+	seen := map[string]bool{}
+	var filesCount, sizeFiles int64
+
+	add := func(e *Entry) error {
+		filesCount++
+		if a.config.MaxNumFiles >= 0 && filesCount > a.config.MaxNumFiles {
+			return ErrMaxFileCount{}
+		}
+		if !e.IsDir {
+			// only add the size if the resource is not a directory
+			// as its size could be resursive-computed, and we would
+			// count the files not only once
+			sizeFiles += int64(e.Size)
+			if a.config.MaxSize >= 0 && sizeFiles > a.config.MaxSize {
+				return ErrMaxSize{}
+			}
+		}
+		m.Entries = append(m.Entries, e)
+		return nil
+	}
+
+	var revisions []*Entry
+	for _, root := range a.files {
+		err := a.walker.Walk(ctx, root, func(path string, info *provider.ResourceInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			isDir := info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER
+
+			fileName, err := filepath.Rel(a.dir, path)
+			if err != nil {
+				return err
+			}
+			if fileName == "" || fileName == "." || seen[fileName] {
+				// the requested resources can overlap
+				return nil
+			}
+			seen[fileName] = true
+
+			if a.excluded(fileName) {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if len(a.config.Include) > 0 && (isDir || !a.included(fileName)) {
+				// with include filters only the matching files are archived,
+				// their folders are implied by their names
+				return nil
+			}
+
+			e := &Entry{
+				Name:  fileName,
+				Path:  path,
+				Size:  info.Size,
+				Mtime: time.Unix(int64(info.Mtime.GetSeconds()), 0),
+				Etag:  info.Etag,
+				IsDir: isDir,
+			}
+			if isDir {
+				e.Size = 0
+			}
+			if err := add(e); err != nil {
+				return err
+			}
+
+			if a.config.Revisions && !isDir {
+				revs, err := a.revisions(ctx, e)
+				if err != nil {
+					return err
+				}
+				revisions = append(revisions, revs...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if a.config.Trash {
+		seenItems := map[string]bool{}
+		for _, root := range a.files {
+			items, err := a.trash(ctx, root, seenItems)
+			if err != nil {
+				return nil, err
+			}
+			revisions = append(revisions, items...)
+		}
+	}
+
+	// the revisions and the recycle items are kept together at the end of the archive
+	for _, e := range revisions {
+		if err := add(e); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (a *Archiver) revisions(ctx context.Context, e *Entry) ([]*Entry, error) {
+	rd, ok := a.downloader.(downloader.RevisionDownloader)
+	if !ok {
+		return nil, nil
+	}
+	versions, err := rd.ListRevisions(ctx, e.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*Entry, 0, len(versions))
+	for _, v := range versions {
+		entries = append(entries, &Entry{
+			Name:     path.Join(revisionsDir, e.Name+".v"+v.Key),
+			Path:     e.Path,
+			Revision: v.Key,
+			Size:     v.Size,
+			Mtime:    time.Unix(int64(v.Mtime), 0),
+			Etag:     v.Etag,
+		})
+	}
+	return entries, nil
+}
+
+// trash returns the entries of the recycle items deleted from the root folder.
+// An item is named after its original path and its deletion time,
+// as the same path can be deleted several times.
+func (a *Archiver) trash(ctx context.Context, root string, seen map[string]bool) ([]*Entry, error) {
+	rd, ok := a.downloader.(downloader.RecycleDownloader)
+	if !ok {
+		return nil, nil
+	}
+	items, err := rd.ListRecycle(ctx, root, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, item := range items {
+		origin := item.Ref.GetPath()
+		if seen[item.Key] || (origin != root && !strings.HasPrefix(origin, strings.TrimSuffix(root, "/")+"/")) {
+			continue
+		}
+		seen[item.Key] = true
+
+		fileName, err := filepath.Rel(a.dir, origin)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Join(trashDir, fmt.Sprintf("%s.d%d", fileName, item.DeletionTime.GetSeconds()))
+		es, err := a.trashItem(ctx, rd, root, item, fileName, name)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	return entries, nil
+}
+
+// trashItem returns the entries of a recycle item, listing the content of the folders.
+func (a *Archiver) trashItem(ctx context.Context, rd downloader.RecycleDownloader, root string, item *provider.RecycleItem, fileName, name string) ([]*Entry, error) {
+	isDir := item.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER
+	if a.excluded(fileName) {
+		return nil, nil
+	}
+
+	e := &Entry{
+		Name:  name,
+		Path:  root,
+		Trash: item.Key,
+		Size:  item.Size,
+		Mtime: time.Unix(int64(item.DeletionTime.GetSeconds()), 0),
+		IsDir: isDir,
+	}
+	if !isDir {
+		if len(a.config.Include) > 0 && !a.included(fileName) {
+			return nil, nil
+		}
+		return []*Entry{e}, nil
+	}
+
+	var entries []*Entry
+	if len(a.config.Include) == 0 {
+		e.Size = 0
+		entries = append(entries, e)
+	}
+	children, err := rd.ListRecycle(ctx, root, item.Key)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range children {
+		base := path.Base(c.Ref.GetPath())
+		es, err := a.trashItem(ctx, rd, root, c, path.Join(fileName, base), path.Join(name, base))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, es...)
+	}
+	return entries, nil
+}
+
+// excluded tells whether the entry matches one of the exclude patterns.
+func (a *Archiver) excluded(name string) bool {
+	return matchAny(a.config.Exclude, name)
+}
+
+// included tells whether the entry matches one of the include patterns.
+func (a *Archiver) included(name string) bool {
+	return matchAny(a.config.Include, name)
+}
+
+// matchAny tells whether the path or its base name matches one of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
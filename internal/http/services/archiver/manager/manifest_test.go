@@ -0,0 +1,397 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/storage/utils/downloader"
+	downMock "github.com/cs3org/reva/pkg/storage/utils/downloader/mock"
+	walkerMock "github.com/cs3org/reva/pkg/storage/utils/walker/mock"
+	"github.com/cs3org/reva/pkg/test"
+)
+
+var manifestSrc = test.Dir{
+	"foo": test.Dir{
+		"bar.txt": test.File{
+			Content: "qwerty\ntest",
+		},
+		"bar.log": test.File{
+			Content: "some logs",
+		},
+	},
+	"main.py": test.File{
+		Content: "print(\"Hello world!\")\n",
+	},
+	"other_dir": test.Dir{
+		"images": test.Dir{
+			"foo.png": test.File{
+				Content: strings.Repeat("<png content>", 100),
+			},
+		},
+		"build": test.Dir{
+			"main.o": test.File{
+				Content: "<object>",
+			},
+		},
+	},
+}
+
+func newTestArchiver(t *testing.T, files []string, config Config) (*Archiver, func()) {
+	tmpdir, cleanup, err := test.NewTestDir(manifestSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filesAbs := []string{}
+	for _, f := range files {
+		filesAbs = append(filesAbs, path.Join(tmpdir, f))
+	}
+
+	arch, err := NewArchiver(filesAbs, walkerMock.NewWalker(), downMock.NewDownloader(), config)
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	return arch, cleanup
+}
+
+func TestManifestFilters(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected []string
+	}{
+		{
+			name:   "no filters",
+			config: Config{MaxNumFiles: -1, MaxSize: -1},
+			expected: []string{"foo", "foo/bar.log", "foo/bar.txt", "main.py", "other_dir", "other_dir/build",
+				"other_dir/build/main.o", "other_dir/images", "other_dir/images/foo.png"},
+		},
+		{
+			name:     "exclude files and folders",
+			config:   Config{MaxNumFiles: -1, MaxSize: -1, Exclude: []string{"*.log", "build"}},
+			expected: []string{"foo", "foo/bar.txt", "main.py", "other_dir", "other_dir/images", "other_dir/images/foo.png"},
+		},
+		{
+			name:     "include files",
+			config:   Config{MaxNumFiles: -1, MaxSize: -1, Include: []string{"*.txt", "other_dir/*/*.png"}},
+			expected: []string{"foo/bar.txt", "other_dir/images/foo.png"},
+		},
+		{
+			name:     "include and exclude",
+			config:   Config{MaxNumFiles: -1, MaxSize: -1, Include: []string{"*.txt", "*.png"}, Exclude: []string{"images"}},
+			expected: []string{"foo/bar.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arch, cleanup := newTestArchiver(t, []string{"foo", "main.py", "other_dir", "foo/bar.txt"}, tt.config)
+			defer cleanup()
+
+			m, err := arch.Manifest(context.TODO())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := []string{}
+			for _, e := range m.Entries {
+				names = append(names, e.Name)
+			}
+			sort.Strings(names)
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Fatalf("manifest different from expected: got=%v, expected=%v", names, tt.expected)
+			}
+		})
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := NewArchiver([]string{"/foo"}, walkerMock.NewWalker(), downMock.NewDownloader(), Config{Include: []string{"[a-"}})
+	if err == nil {
+		t.Fatal("expected an error for an invalid pattern")
+	}
+}
+
+func TestWriteRanges(t *testing.T) {
+	ctx := context.TODO()
+	arch, cleanup := newTestArchiver(t, []string{"foo", "main.py", "other_dir"}, Config{MaxNumFiles: -1, MaxSize: -1})
+	defer cleanup()
+
+	m, err := arch.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formats := []struct {
+		name  string
+		size  func(*Manifest) (int64, error)
+		write func(context.Context, *bytes.Buffer, *Manifest, int64, int64) error
+	}{
+		{
+			name: "tar",
+			size: arch.TarSize,
+			write: func(ctx context.Context, b *bytes.Buffer, m *Manifest, offset, length int64) error {
+				return arch.WriteTar(ctx, b, m, offset, length)
+			},
+		},
+		{
+			name: "zip",
+			size: arch.ZipSize,
+			write: func(ctx context.Context, b *bytes.Buffer, m *Manifest, offset, length int64) error {
+				return arch.WriteZip(ctx, b, m, offset, length)
+			},
+		},
+	}
+
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			var full bytes.Buffer
+			if err := f.write(ctx, &full, m, 0, -1); err != nil {
+				t.Fatal(err)
+			}
+
+			size, err := f.size(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != int64(full.Len()) {
+				t.Fatalf("size different from the archive: got=%d, expected=%d", size, full.Len())
+			}
+
+			for _, r := range [][2]int64{{0, 10}, {10, 1000}, {700, 512}, {size - 100, 100}, {size - 1, 1}, {1500, -1}} {
+				var part bytes.Buffer
+				if err := f.write(ctx, &part, m, r[0], r[1]); err != nil {
+					t.Fatal(err)
+				}
+				end := size
+				if r[1] >= 0 {
+					end = r[0] + r[1]
+				}
+				if !bytes.Equal(part.Bytes(), full.Bytes()[r[0]:end]) {
+					t.Fatalf("range %v different from the archive", r)
+				}
+			}
+		})
+	}
+}
+
+func TestManifestETag(t *testing.T) {
+	arch, cleanup := newTestArchiver(t, []string{"foo"}, Config{MaxNumFiles: -1, MaxSize: -1})
+	defer cleanup()
+
+	m1, err := arch.Manifest(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := arch.Manifest(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m1.ETag() != m2.ETag() {
+		t.Fatal("etag of the same content changed")
+	}
+
+	m2.Entries[len(m2.Entries)-1].Size++
+	if m1.ETag() == m2.ETag() {
+		t.Fatal("etag of a different content did not change")
+	}
+}
+
+// countingDownloader counts the downloaded files and serves a recycle bin.
+type countingDownloader struct {
+	downloader.Downloader
+	downloads int
+	trash     map[string][]*provider.RecycleItem
+	content   map[string]string
+}
+
+func (d *countingDownloader) Download(ctx context.Context, path string, dst io.Writer) error {
+	d.downloads++
+	return d.Downloader.Download(ctx, path, dst)
+}
+
+func (d *countingDownloader) ListRecycle(ctx context.Context, path, key string) ([]*provider.RecycleItem, error) {
+	return d.trash[key], nil
+}
+
+func (d *countingDownloader) DownloadRecycleItem(ctx context.Context, path, key string, dst io.Writer) error {
+	d.downloads++
+	_, err := io.WriteString(dst, d.content[key])
+	return err
+}
+
+func TestZipResume(t *testing.T) {
+	ctx := context.TODO()
+	tmpdir, cleanup, err := test.NewTestDir(manifestSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	d := &countingDownloader{Downloader: downMock.NewDownloader()}
+	arch, err := NewArchiver([]string{path.Join(tmpdir, "foo"), path.Join(tmpdir, "main.py")}, walkerMock.NewWalker(), d,
+		Config{MaxNumFiles: -1, MaxSize: -1, Checksums: NewChecksums(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := arch.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var full bytes.Buffer
+	if err := arch.WriteZip(ctx, &full, m, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if d.downloads != 3 {
+		t.Fatalf("unexpected number of downloads: got=%d, expected=3", d.downloads)
+	}
+
+	// resume the download in the content of the last file
+	d.downloads = 0
+	l, err := newZipLayout(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := l.records[len(l.records)-1]
+	offset := int64(last.offset + last.localHeaderLen() + 1)
+
+	var part bytes.Buffer
+	if err := arch.WriteZip(ctx, &part, m, offset, -1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(part.Bytes(), full.Bytes()[offset:]) {
+		t.Fatal("resumed zip different from the archive")
+	}
+	if d.downloads != 1 {
+		t.Fatalf("files before the range downloaded again: got=%d downloads, expected=1", d.downloads)
+	}
+}
+
+func TestZip64Layout(t *testing.T) {
+	m := &Manifest{Entries: []*Entry{
+		{Name: "big", Size: 5 << 30},
+		{Name: "small", Size: 10},
+		{Name: "dir", IsDir: true},
+	}}
+	l, err := newZipLayout(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.zip64 {
+		t.Fatal("expected zip64 records for a file bigger than 4GB")
+	}
+
+	var c countingWriter
+	c.w = io.Discard
+	if err := l.write(&c, func(r *zipRecord) error {
+		c.n += int64(r.Size)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if uint64(c.n) != l.size {
+		t.Fatalf("size different from the written bytes: got=%d, expected=%d", l.size, c.n)
+	}
+	if l.records[2].directory64Fields() != 1 {
+		t.Fatal("expected the offset after 4GB in a zip64 record")
+	}
+}
+
+func TestManifestTrash(t *testing.T) {
+	ctx := context.TODO()
+	tmpdir, cleanup, err := test.NewTestDir(manifestSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	deleted := &types.Timestamp{Seconds: 100}
+	d := &countingDownloader{
+		Downloader: downMock.NewDownloader(),
+		trash: map[string][]*provider.RecycleItem{
+			"": {
+				{Key: "k1", Type: provider.ResourceType_RESOURCE_TYPE_FILE, Size: 3, DeletionTime: deleted,
+					Ref: &provider.Reference{Path: path.Join(tmpdir, "foo", "old.txt")}},
+				{Key: "k2", Type: provider.ResourceType_RESOURCE_TYPE_CONTAINER, DeletionTime: deleted,
+					Ref: &provider.Reference{Path: path.Join(tmpdir, "foo", "olddir")}},
+				{Key: "k3", Type: provider.ResourceType_RESOURCE_TYPE_FILE, Size: 5, DeletionTime: deleted,
+					Ref: &provider.Reference{Path: path.Join(tmpdir, "elsewhere.txt")}},
+			},
+			"k2": {
+				{Key: "k2/a.txt", Type: provider.ResourceType_RESOURCE_TYPE_FILE, Size: 4, DeletionTime: deleted,
+					Ref: &provider.Reference{Path: path.Join(tmpdir, "foo", "olddir", "a.txt")}},
+			},
+		},
+		content: map[string]string{"k1": "old", "k2/a.txt": "aaaa", "k3": "other"},
+	}
+	arch, err := NewArchiver([]string{path.Join(tmpdir, "foo")}, walkerMock.NewWalker(), d, Config{MaxNumFiles: -1, MaxSize: -1, Trash: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := arch.Manifest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := arch.WriteTar(ctx, &b, m, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	r := tar.NewReader(&b)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[h.Name] = string(content)
+	}
+
+	expected := map[string]string{
+		"foo":                          "",
+		"foo/bar.txt":                  "qwerty\ntest",
+		"foo/bar.log":                  "some logs",
+		".trash/foo/old.txt.d100":      "old",
+		".trash/foo/olddir.d100":       "",
+		".trash/foo/olddir.d100/a.txt": "aaaa",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("archive different from expected: got=%v, expected=%v", got, expected)
+	}
+}
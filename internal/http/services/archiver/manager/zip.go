@@ -0,0 +1,370 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+// The zip is written by hand rather than with archive/zip, as the position of
+// every record must be known upfront to compute the size of the archive and to
+// skip the files before a range: the files are stored, with their checksum in a
+// data descriptor, and zip64 records are used for the sizes and offsets that do
+// not fit in the classic format.
+const (
+	zipLocalHeaderLen      = 30
+	zipDirectoryHeaderLen  = 46
+	zipDataDescriptorLen   = 16
+	zipDataDescriptor64Len = 24
+	zipDirectoryEndLen     = 22
+	zipDirectory64EndLen   = 56
+	zipDirectory64LocLen   = 20
+	zipExtTimeLen          = 9
+	zipLocal64ExtraLen     = 20
+
+	zipLocalHeaderSig         = 0x04034b50
+	zipDirectoryHeaderSig     = 0x02014b50
+	zipDataDescriptorSig      = 0x08074b50
+	zipDirectoryEndSig        = 0x06054b50
+	zipDirectory64EndSig      = 0x06064b50
+	zipDirectory64LocSig      = 0x07064b50
+	zipExtTimeExtraID         = 0x5455
+	zip64ExtraID              = 0x0001
+	zipFlagDataDescriptor     = 0x8
+	zipFlagUTF8               = 0x800
+	zipVersion20              = 20
+	zipVersion45              = 45
+	zipCreatorUnix            = 3 << 8
+	zipUint16Max              = 1<<16 - 1
+	zipUint32Max              = 1<<32 - 1
+	zipDirMode                = 0755 | 0040000
+	zipFileMode               = 0644 | 0100000
+	zipExternalAttrsModeShift = 16
+)
+
+// zipRecord is an entry of a zip with the position of its local header.
+type zipRecord struct {
+	*Entry
+	name   string
+	offset uint64
+	crc    uint32
+}
+
+// zip64 tells whether the size of the file needs the zip64 records.
+func (r *zipRecord) zip64() bool {
+	return !r.IsDir && r.Size >= zipUint32Max
+}
+
+func (r *zipRecord) flags() uint16 {
+	if r.IsDir {
+		return zipFlagUTF8
+	}
+	return zipFlagUTF8 | zipFlagDataDescriptor
+}
+
+func (r *zipRecord) localHeaderLen() uint64 {
+	n := uint64(zipLocalHeaderLen + len(r.name) + zipExtTimeLen)
+	if r.zip64() {
+		n += zipLocal64ExtraLen
+	}
+	return n
+}
+
+func (r *zipRecord) dataDescriptorLen() uint64 {
+	switch {
+	case r.IsDir:
+		return 0
+	case r.zip64():
+		return zipDataDescriptor64Len
+	}
+	return zipDataDescriptorLen
+}
+
+// directory64Fields returns the number of fields of the zip64 extra of the central directory.
+func (r *zipRecord) directory64Fields() int {
+	n := 0
+	if r.zip64() {
+		// uncompressed and compressed sizes
+		n += 2
+	}
+	if r.offset >= zipUint32Max {
+		n++
+	}
+	return n
+}
+
+func (r *zipRecord) directoryExtraLen() uint64 {
+	n := uint64(zipExtTimeLen)
+	if f := r.directory64Fields(); f > 0 {
+		n += 4 + 8*uint64(f)
+	}
+	return n
+}
+
+func (r *zipRecord) directoryHeaderLen() uint64 {
+	return zipDirectoryHeaderLen + uint64(len(r.name)) + r.directoryExtraLen()
+}
+
+// zipLayout is the position of the records of a zip.
+type zipLayout struct {
+	records   []*zipRecord
+	dirOffset uint64
+	dirSize   uint64
+	zip64     bool
+	size      uint64
+}
+
+func newZipLayout(m *Manifest) (*zipLayout, error) {
+	l := &zipLayout{}
+
+	var offset uint64
+	for _, e := range m.Entries {
+		r := &zipRecord{Entry: e, name: e.Name, offset: offset}
+		if e.IsDir {
+			r.name += "/"
+		}
+		if len(r.name) > zipUint16Max {
+			return nil, errtypes.BadRequest("name too long for a zip: " + e.Name)
+		}
+		offset += r.localHeaderLen() + r.Size + r.dataDescriptorLen()
+		l.records = append(l.records, r)
+	}
+
+	l.dirOffset = offset
+	for _, r := range l.records {
+		l.dirSize += r.directoryHeaderLen()
+		if r.directory64Fields() > 0 {
+			l.zip64 = true
+		}
+	}
+	if len(l.records) >= zipUint16Max || l.dirSize >= zipUint32Max || l.dirOffset >= zipUint32Max {
+		l.zip64 = true
+	}
+
+	l.size = l.dirOffset + l.dirSize + zipDirectoryEndLen
+	if l.zip64 {
+		l.size += zipDirectory64EndLen + zipDirectory64LocLen
+	}
+	return l, nil
+}
+
+// write writes the zip into w, calling content to write the content of the
+// files, which sets the checksum of the record.
+func (l *zipLayout) write(w io.Writer, content func(r *zipRecord) error) error {
+	for _, r := range l.records {
+		if _, err := w.Write(r.localHeader()); err != nil {
+			return err
+		}
+		if r.IsDir {
+			continue
+		}
+		if err := content(r); err != nil {
+			return err
+		}
+		if _, err := w.Write(r.dataDescriptor()); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range l.records {
+		if _, err := w.Write(r.directoryHeader()); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(l.directoryEnd())
+	return err
+}
+
+// zipBuf is a little endian writer of the fields of the records.
+type zipBuf []byte
+
+func (b *zipBuf) uint16(v uint16) { *b = binary.LittleEndian.AppendUint16(*b, v) }
+func (b *zipBuf) uint32(v uint32) { *b = binary.LittleEndian.AppendUint32(*b, v) }
+func (b *zipBuf) uint64(v uint64) { *b = binary.LittleEndian.AppendUint64(*b, v) }
+
+func (b *zipBuf) extTime(t time.Time) {
+	b.uint16(zipExtTimeExtraID)
+	b.uint16(5)
+	*b = append(*b, 1) // only the modification time
+	b.uint32(uint32(t.Unix()))
+}
+
+func (r *zipRecord) readerVersion() uint16 {
+	if r.directory64Fields() > 0 {
+		return zipVersion45
+	}
+	return zipVersion20
+}
+
+// localHeader returns the local header of the record. The checksum and the
+// sizes of the files are in the data descriptor following the content.
+func (r *zipRecord) localHeader() []byte {
+	b := make(zipBuf, 0, r.localHeaderLen())
+	date, tm := msDosTime(r.Mtime)
+
+	extraLen := uint16(zipExtTimeLen)
+	var size uint32
+	if r.zip64() {
+		extraLen += zipLocal64ExtraLen
+		size = zipUint32Max
+	}
+
+	b.uint32(zipLocalHeaderSig)
+	b.uint16(r.readerVersion())
+	b.uint16(r.flags())
+	b.uint16(0) // stored
+	b.uint16(tm)
+	b.uint16(date)
+	b.uint32(0) // checksum
+	b.uint32(size)
+	b.uint32(size)
+	b.uint16(uint16(len(r.name)))
+	b.uint16(extraLen)
+	b = append(b, r.name...)
+	b.extTime(r.Mtime)
+	if r.zip64() {
+		// the sizes are in the data descriptor
+		b.uint16(zip64ExtraID)
+		b.uint16(16)
+		b.uint64(0)
+		b.uint64(0)
+	}
+	return b
+}
+
+func (r *zipRecord) dataDescriptor() []byte {
+	b := make(zipBuf, 0, r.dataDescriptorLen())
+	b.uint32(zipDataDescriptorSig)
+	b.uint32(r.crc)
+	if r.zip64() {
+		b.uint64(r.Size)
+		b.uint64(r.Size)
+	} else {
+		b.uint32(uint32(r.Size))
+		b.uint32(uint32(r.Size))
+	}
+	return b
+}
+
+func (r *zipRecord) directoryHeader() []byte {
+	b := make(zipBuf, 0, r.directoryHeaderLen())
+	date, tm := msDosTime(r.Mtime)
+
+	size, offset := uint32(r.Size), uint32(r.offset)
+	if r.zip64() {
+		size = zipUint32Max
+	}
+	if r.offset >= zipUint32Max {
+		offset = zipUint32Max
+	}
+	mode := uint32(zipFileMode)
+	if r.IsDir {
+		mode = zipDirMode
+	}
+
+	b.uint32(zipDirectoryHeaderSig)
+	b.uint16(zipCreatorUnix | zipVersion20)
+	b.uint16(r.readerVersion())
+	b.uint16(r.flags())
+	b.uint16(0) // stored
+	b.uint16(tm)
+	b.uint16(date)
+	b.uint32(r.crc)
+	b.uint32(size)
+	b.uint32(size)
+	b.uint16(uint16(len(r.name)))
+	b.uint16(uint16(r.directoryExtraLen()))
+	b.uint16(0) // comment length
+	b.uint16(0) // disk number
+	b.uint16(0) // internal attributes
+	b.uint32(mode << zipExternalAttrsModeShift)
+	b.uint32(offset)
+	b = append(b, r.name...)
+	b.extTime(r.Mtime)
+	if f := r.directory64Fields(); f > 0 {
+		b.uint16(zip64ExtraID)
+		b.uint16(uint16(8 * f))
+		if r.zip64() {
+			b.uint64(r.Size)
+			b.uint64(r.Size)
+		}
+		if r.offset >= zipUint32Max {
+			b.uint64(r.offset)
+		}
+	}
+	return b
+}
+
+func (l *zipLayout) directoryEnd() []byte {
+	b := make(zipBuf, 0, zipDirectory64EndLen+zipDirectory64LocLen+zipDirectoryEndLen)
+	records, size, offset := uint64(len(l.records)), l.dirSize, l.dirOffset
+
+	if l.zip64 {
+		b.uint32(zipDirectory64EndSig)
+		b.uint64(zipDirectory64EndLen - 12) // without the signature and this length
+		b.uint16(zipCreatorUnix | zipVersion45)
+		b.uint16(zipVersion45)
+		b.uint32(0) // disk number
+		b.uint32(0) // disk of the central directory
+		b.uint64(records)
+		b.uint64(records)
+		b.uint64(size)
+		b.uint64(offset)
+
+		b.uint32(zipDirectory64LocSig)
+		b.uint32(0) // disk of the zip64 end record
+		b.uint64(offset + size)
+		b.uint32(1) // number of disks
+
+		records, size, offset = minUint64(records, zipUint16Max), minUint64(size, zipUint32Max), minUint64(offset, zipUint32Max)
+	}
+
+	b.uint32(zipDirectoryEndSig)
+	b.uint16(0) // disk number
+	b.uint16(0) // disk of the central directory
+	b.uint16(uint16(records))
+	b.uint16(uint16(records))
+	b.uint32(uint32(size))
+	b.uint32(uint32(offset))
+	b.uint16(0) // comment length
+	return b
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// msDosTime returns the date and the time of t in the MS-DOS format,
+// which only covers the years from 1980.
+func msDosTime(t time.Time) (uint16, uint16) {
+	t = t.UTC()
+	if t.Year() < 1980 {
+		t = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	date := uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	tm := uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, tm
+}
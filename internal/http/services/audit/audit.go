@@ -0,0 +1,231 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/events"
+	streamregistry "github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	global.Register("audit", New)
+}
+
+type config struct {
+	Prefix        string                            `mapstructure:"prefix" docs:"audit;The prefix to be used for this HTTP service"`
+	EventStream   string                            `mapstructure:"event_stream" docs:";The event stream to consume the events from."`
+	EventStreams  map[string]map[string]interface{} `mapstructure:"event_streams" docs:"url:pkg/events/stream/nats/nats.go;The configuration for the event streams"`
+	Group         string                            `mapstructure:"group" docs:"audit;The consumer group, every group gets a copy of each event."`
+	Sink          string                            `mapstructure:"sink" docs:"file;Where to write the audit log, file or syslog."`
+	File          string                            `mapstructure:"file" docs:"/var/tmp/reva/audit.log;The file the audit log is written to when using the file sink."`
+	SyslogNetwork string                            `mapstructure:"syslog_network" docs:";The network of the syslog daemon, the local daemon is used if empty."`
+	SyslogAddress string                            `mapstructure:"syslog_address" docs:";The address of the syslog daemon."`
+	SyslogTag     string                            `mapstructure:"syslog_tag" docs:"reva-audit;The tag of the syslog messages."`
+	Admins        []string                          `mapstructure:"admins" docs:";The usernames allowed to query the entries of all users, other users only get their own."`
+}
+
+func (c *config) init() {
+	if c.Prefix == "" {
+		c.Prefix = "audit"
+	}
+	if c.Group == "" {
+		c.Group = "audit"
+	}
+	if c.Sink == "" {
+		c.Sink = "file"
+	}
+	if c.File == "" {
+		c.File = "/var/tmp/reva/audit.log"
+	}
+	if c.SyslogTag == "" {
+		c.SyslogTag = "reva-audit"
+	}
+}
+
+type svc struct {
+	conf   *config
+	log    *zerolog.Logger
+	sink   sink
+	admins map[string]struct{}
+}
+
+// New returns a new audit service recording the events of the configured stream.
+func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, err
+	}
+	conf.init()
+
+	stream, err := getStream(conf)
+	if err != nil {
+		return nil, err
+	}
+	sink, err := getSink(conf)
+	if err != nil {
+		return nil, err
+	}
+	return newService(conf, stream, sink, log)
+}
+
+func newService(conf *config, stream events.Consumer, sink sink, log *zerolog.Logger) (*svc, error) {
+	ch, err := events.ConsumeWithAck(stream, conf.Group, nil, auditedEvents...)
+	if err != nil {
+		return nil, errors.Wrap(err, "audit: error consuming events")
+	}
+
+	s := &svc{
+		conf:   conf,
+		log:    log,
+		sink:   sink,
+		admins: make(map[string]struct{}, len(conf.Admins)),
+	}
+	for _, a := range conf.Admins {
+		s.admins[a] = struct{}{}
+	}
+
+	go s.record(ch)
+	return s, nil
+}
+
+func getStream(c *config) (events.Stream, error) {
+	if c.EventStream == "" {
+		return nil, errors.New("audit: event_stream must be configured")
+	}
+	if f, ok := streamregistry.NewFuncs[c.EventStream]; ok {
+		return f(c.EventStreams[c.EventStream])
+	}
+	return nil, fmt.Errorf("audit: event stream not found: %s", c.EventStream)
+}
+
+func getSink(c *config) (sink, error) {
+	switch c.Sink {
+	case "file":
+		return newFileSink(c.File)
+	case "syslog":
+		return newSyslogSink(c.SyslogNetwork, c.SyslogAddress, c.SyslogTag)
+	}
+	return nil, fmt.Errorf("audit: sink not found: %s", c.Sink)
+}
+
+// record writes the consumed events to the sink, events that could not be written are delivered again.
+func (s *svc) record(ch <-chan events.AckEvent) {
+	for ev := range ch {
+		e := newEntry(ev.Event)
+		if err := s.sink.Write(e); err != nil {
+			s.log.Error().Err(err).Str("action", e.Action).Msg("audit: error writing entry")
+			if err := ev.Nack(); err != nil {
+				s.log.Error().Err(err).Msg("audit: error rejecting event")
+			}
+			continue
+		}
+		if err := ev.Ack(); err != nil {
+			s.log.Error().Err(err).Msg("audit: error acknowledging event")
+		}
+	}
+}
+
+func (s *svc) Close() error {
+	return s.sink.Close()
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+func (s *svc) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		q, ok := s.sink.(querier)
+		if !ok {
+			http.Error(w, "audit: the configured sink can't be queried", http.StatusNotImplemented)
+			return
+		}
+
+		u, ok := ctxpkg.ContextGetUser(r.Context())
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		f, err := parseFilter(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, admin := s.admins[u.Username]; !admin {
+			if f.User != "" && f.User != u.Id.GetOpaqueId() {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			f.User = u.Id.GetOpaqueId()
+		}
+
+		entries, err := q.Query(f)
+		if err != nil {
+			s.log.Error().Err(err).Msg("audit: error querying entries")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(entries); err != nil {
+			s.log.Err(err).Msg("error writing response")
+		}
+	})
+}
+
+func parseFilter(r *http.Request) (*filter, error) {
+	q := r.URL.Query()
+	f := &filter{
+		User:     q.Get("user"),
+		Resource: q.Get("resource"),
+	}
+	var err error
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.Wrap(err, "audit: invalid from")
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, errors.Wrap(err, "audit: invalid to")
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/events/stream/memory"
+	"github.com/rs/zerolog"
+)
+
+func newTestService(t *testing.T) (*svc, events.Stream) {
+	t.Helper()
+	conf := &config{Admins: []string{"admin"}}
+	conf.init()
+
+	sink, err := newFileSink(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := memory.NewStream()
+	log := zerolog.Nop()
+	s, err := newService(conf, stream, sink, &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s, stream
+}
+
+func query(t *testing.T, s *svc, u *userpb.User, params string) (int, []*Entry) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/?"+params, nil)
+	r = r.WithContext(ctxpkg.ContextSetUser(context.Background(), u))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	var entries []*Entry
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&entries); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, entries
+}
+
+func ts(year, month int) *types.Timestamp {
+	return &types.Timestamp{Seconds: uint64(time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC).Unix())}
+}
+
+func waitForEntries(t *testing.T, s *svc, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		entries, err := s.sink.(querier).Query(&filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) >= n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d entries", n)
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	s, stream := newTestService(t)
+
+	einstein := &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}, Username: "einstein"}
+	marie := &userpb.User{Id: &userpb.UserId{OpaqueId: "marie"}, Username: "marie"}
+	admin := &userpb.User{Id: &userpb.UserId{OpaqueId: "admin"}, Username: "admin"}
+
+	file := &provider.ResourceId{StorageId: "storage", OpaqueId: "file"}
+	evs := []interface{}{
+		events.FileUploaded{Executant: einstein.Id, Ref: &provider.Reference{ResourceId: file}, Timestamp: ts(2022, 1)},
+		events.FileDownloaded{Executant: marie.Id, Ref: &provider.Reference{ResourceId: file}, Timestamp: ts(2022, 2)},
+		events.ItemTrashed{Executant: einstein.Id, Ref: &provider.Reference{Path: "/other"}, Timestamp: ts(2022, 3)},
+	}
+	for _, ev := range evs {
+		if err := events.Publish(stream, ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitForEntries(t, s, len(evs))
+
+	tests := []struct {
+		name    string
+		user    *userpb.User
+		params  string
+		code    int
+		actions []string
+	}{
+		{"own entries", einstein, "", http.StatusOK, []string{"events.FileUploaded", "events.ItemTrashed"}},
+		{"other user", einstein, "user=marie", http.StatusForbidden, nil},
+		{"admin by user", admin, "user=marie", http.StatusOK, []string{"events.FileDownloaded"}},
+		{"admin by resource", admin, "resource=storage!file", http.StatusOK, []string{"events.FileUploaded", "events.FileDownloaded"}},
+		{"admin by time", admin, "from=2022-01-15T00:00:00Z&to=2022-02-15T00:00:00Z", http.StatusOK, []string{"events.FileDownloaded"}},
+		{"invalid time", admin, "from=yesterday", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, entries := query(t, s, tt.user, tt.params)
+			if code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, code)
+			}
+			if len(entries) != len(tt.actions) {
+				t.Fatalf("expected %d entries, got %d", len(tt.actions), len(entries))
+			}
+			for i, e := range entries {
+				if e.Action != tt.actions[i] {
+					t.Errorf("expected action %s, got %s", tt.actions[i], e.Action)
+				}
+			}
+		})
+	}
+}
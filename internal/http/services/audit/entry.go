@@ -0,0 +1,156 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/cs3org/reva/pkg/utils/resourceid"
+)
+
+// auditedEvents are the events recorded in the audit log.
+var auditedEvents = []events.Unmarshaller{
+	events.ShareCreated{},
+	events.ShareRemoved{},
+	events.ShareUpdated{},
+	events.ReceivedShareUpdated{},
+	events.LinkCreated{},
+	events.LinkUpdated{},
+	events.LinkRemoved{},
+	events.OCMShareCreated{},
+	events.FileUploaded{},
+	events.FileDownloaded{},
+	events.ItemTrashed{},
+	events.ItemMoved{},
+	events.ItemRestored{},
+	events.FileVersionRestored{},
+	events.SpaceCreated{},
+	events.SpaceUpdated{},
+	events.FileLocked{},
+	events.LockRefreshed{},
+	events.FileUnlocked{},
+}
+
+// Entry is a record of the audit log.
+type Entry struct {
+	Time     time.Time   `json:"time"`
+	Action   string      `json:"action"`
+	User     string      `json:"user,omitempty"`
+	Resource string      `json:"resource,omitempty"`
+	Event    interface{} `json:"event"`
+}
+
+func newEntry(ev interface{}) *Entry {
+	var (
+		u        *user.UserId
+		resource string
+		ts       *types.Timestamp
+	)
+
+	switch e := ev.(type) {
+	case events.ShareCreated:
+		u, resource, ts = firstUser(e.Executant, e.Sharer), resourceID(e.ItemID), e.CTime
+	case events.ShareRemoved:
+		u, ts = e.Executant, e.Timestamp
+		if e.ShareKey != nil {
+			resource = resourceID(e.ShareKey.ResourceId)
+		}
+	case events.ShareUpdated:
+		u, resource, ts = firstUser(e.Executant, e.Sharer), resourceID(e.ItemID), e.MTime
+	case events.ReceivedShareUpdated:
+		u, resource, ts = e.Executant, resourceID(e.ItemID), e.Timestamp
+	case events.LinkCreated:
+		u, resource, ts = firstUser(e.Executant, e.Sharer), resourceID(e.ItemID), e.CTime
+	case events.LinkUpdated:
+		u, resource, ts = firstUser(e.Executant, e.Sharer), resourceID(e.ItemID), e.MTime
+	case events.LinkRemoved:
+		u, ts = e.Executant, e.Timestamp
+	case events.OCMShareCreated:
+		u, resource, ts = firstUser(e.Executant, e.Sharer), resourceID(e.ItemID), e.CTime
+	case events.FileUploaded:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.FileDownloaded:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.ItemTrashed:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.ItemMoved:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.ItemRestored:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.FileVersionRestored:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.SpaceCreated:
+		u, resource, ts = firstUser(e.Executant, e.Owner), resourceID(e.Root), e.Timestamp
+	case events.SpaceUpdated:
+		u, resource, ts = firstUser(e.Executant, e.Owner), resourceID(e.Root), e.Timestamp
+	case events.FileLocked:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.LockRefreshed:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	case events.FileUnlocked:
+		u, resource, ts = e.Executant, reference(e.Ref), e.Timestamp
+	}
+
+	entry := &Entry{
+		Time:     time.Now().UTC(),
+		Action:   fmt.Sprintf("%T", ev),
+		Resource: resource,
+		Event:    ev,
+	}
+	if ts != nil {
+		entry.Time = utils.TSToTime(ts).UTC()
+	}
+	if u != nil {
+		entry.User = u.OpaqueId
+	}
+	return entry
+}
+
+func firstUser(ids ...*user.UserId) *user.UserId {
+	for _, id := range ids {
+		if id != nil && id.OpaqueId != "" {
+			return id
+		}
+	}
+	return nil
+}
+
+func resourceID(id *provider.ResourceId) string {
+	if id == nil {
+		return ""
+	}
+	return resourceid.OwnCloudResourceIDWrap(id)
+}
+
+// reference returns the resource id of the reference if set, its path otherwise.
+func reference(ref *provider.Reference) string {
+	if ref == nil {
+		return ""
+	}
+	if ref.ResourceId != nil {
+		return resourceID(ref.ResourceId)
+	}
+	return ref.Path
+}
@@ -19,10 +19,15 @@
 package dataprovider
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/events"
+	streamregistry "github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/cs3org/reva/pkg/health"
+	"github.com/cs3org/reva/pkg/rhttp/datatx"
 	datatxregistry "github.com/cs3org/reva/pkg/rhttp/datatx/manager/registry"
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/rhttp/router"
@@ -43,6 +48,9 @@ type config struct {
 	DataTXs  map[string]map[string]interface{} `mapstructure:"data_txs" docs:"url:pkg/rhttp/datatx/manager/simple/simple.go;The configuration for the data tx protocols"`
 	Timeout  int64                             `mapstructure:"timeout"`
 	Insecure bool                              `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
+
+	EventStream  string                            `mapstructure:"event_stream" docs:";The event stream to publish upload events to. Events are disabled if empty."`
+	EventStreams map[string]map[string]interface{} `mapstructure:"event_streams" docs:"url:pkg/events/stream/nats/nats.go;The configuration for the event streams"`
 }
 
 func (c *config) init() {
@@ -55,10 +63,11 @@ func (c *config) init() {
 }
 
 type svc struct {
-	conf    *config
-	handler http.Handler
-	storage storage.FS
-	dataTXs map[string]http.Handler
+	conf      *config
+	handler   http.Handler
+	storage   storage.FS
+	dataTXs   map[string]http.Handler
+	publisher events.Publisher
 }
 
 // New returns a new datasvc.
@@ -75,15 +84,21 @@ func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error)
 		return nil, err
 	}
 
-	dataTXs, err := getDataTXs(conf, fs)
+	publisher, err := getPublisher(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	dataTXs, err := getDataTXs(conf, fs, publisher)
 	if err != nil {
 		return nil, err
 	}
 
 	s := &svc{
-		storage: fs,
-		conf:    conf,
-		dataTXs: dataTXs,
+		storage:   fs,
+		conf:      conf,
+		dataTXs:   dataTXs,
+		publisher: publisher,
 	}
 
 	err = s.setHandler()
@@ -97,7 +112,17 @@ func getFS(c *config) (storage.FS, error) {
 	return nil, fmt.Errorf("driver not found: %s", c.Driver)
 }
 
-func getDataTXs(c *config, fs storage.FS) (map[string]http.Handler, error) {
+func getPublisher(c *config) (events.Publisher, error) {
+	if c.EventStream == "" {
+		return nil, nil
+	}
+	if f, ok := streamregistry.NewFuncs[c.EventStream]; ok {
+		return f(c.EventStreams[c.EventStream])
+	}
+	return nil, fmt.Errorf("event stream not found: %s", c.EventStream)
+}
+
+func getDataTXs(c *config, fs storage.FS, publisher events.Publisher) (map[string]http.Handler, error) {
 	if c.DataTXs == nil {
 		c.DataTXs = make(map[string]map[string]interface{})
 	}
@@ -111,6 +136,9 @@ func getDataTXs(c *config, fs storage.FS) (map[string]http.Handler, error) {
 	for t := range c.DataTXs {
 		if f, ok := datatxregistry.NewFuncs[t]; ok {
 			if tx, err := f(c.DataTXs[t]); err == nil {
+				if e, ok := tx.(datatx.EventEmitter); ok && publisher != nil {
+					e.SetPublisher(publisher)
+				}
 				if handler, err := tx.Handler(fs); err == nil {
 					txs[t] = handler
 				}
@@ -124,6 +152,14 @@ func (s *svc) Close() error {
 	return nil
 }
 
+// Ready tells whether the storage driver and the event stream are ready, when they can tell.
+func (s *svc) Ready(ctx context.Context) error {
+	return health.All(ctx, []health.Named{
+		{Name: "storage driver", Checker: s.storage},
+		{Name: "event stream", Checker: s.publisher},
+	})
+}
+
 func (s *svc) Unprotected() []string {
 	return []string{
 		"/tus",
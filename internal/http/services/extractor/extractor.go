@@ -0,0 +1,505 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package extractor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/archiver/manager"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/cs3org/reva/pkg/storage/utils/uploader"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+)
+
+func init() {
+	global.Register("extractor", New)
+}
+
+type config struct {
+	Prefix         string   `mapstructure:"prefix" docs:"upload_archive;The prefix to be used for this HTTP service"`
+	GatewaySvc     string   `mapstructure:"gatewaysvc"`
+	Timeout        int64    `mapstructure:"timeout"`
+	Insecure       bool     `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
+	MaxNumFiles    int64    `mapstructure:"max_num_files" docs:"0;The maximum number of files and folders of an archive. A negative value means no limit."`
+	MaxSize        int64    `mapstructure:"max_size" docs:"0;The maximum size in bytes of the files of an archive. A negative value means no limit."`
+	AllowedFolders []string `mapstructure:"allowed_folders" docs:";The regular expressions of the folders archives can be extracted into. All folders are allowed if empty."`
+	TempDir        string   `mapstructure:"temp_dir" docs:";The folder where the uploaded archives are stored while being extracted. Defaults to the temporary folder of the system."`
+	MaxUploadSize  int64    `mapstructure:"max_upload_size" docs:"1073741824;The maximum size in bytes of an uploaded archive. A negative value means no limit."`
+	JobsDir        string   `mapstructure:"jobs_dir" docs:";The folder where the status of the extractions is stored. Defaults to reva-extractor-jobs in the temp_dir."`
+	JobExpiration  int64    `mapstructure:"job_expiration" docs:"86400;The seconds the status of a finished extraction is kept."`
+}
+
+func (c *config) init() {
+	if c.Prefix == "" {
+		c.Prefix = "upload_archive"
+	}
+	if c.TempDir == "" {
+		c.TempDir = os.TempDir()
+	}
+	if c.MaxUploadSize == 0 {
+		c.MaxUploadSize = 1 << 30
+	}
+	if c.JobsDir == "" {
+		c.JobsDir = filepath.Join(c.TempDir, "reva-extractor-jobs")
+	}
+	if c.JobExpiration == 0 {
+		c.JobExpiration = 86400
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+type svc struct {
+	conf     *config
+	uploader uploader.Uploader
+	log      *zerolog.Logger
+
+	allowedFolders []*regexp.Regexp
+	store          *jobStore
+
+	// jobs holds the extractions running in this process
+	mu        sync.Mutex
+	jobs      map[string]*job
+	lastPurge time.Time
+}
+
+// job is an extraction running in the background.
+type job struct {
+	id        string
+	owner     *userpb.UserId
+	target    string
+	extractor *manager.Extractor
+
+	mu       sync.Mutex
+	state    string
+	err      error
+	finished time.Time
+}
+
+// record returns the status of the job to be persisted.
+func (j *job) record() *jobRecord {
+	p := j.extractor.Progress()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := &jobRecord{
+		jobStatus: jobStatus{
+			ID:           j.id,
+			Path:         j.target,
+			State:        j.state,
+			TotalEntries: p.TotalEntries,
+			TotalSize:    p.TotalSize,
+			Entries:      p.Entries,
+			Size:         p.Size,
+			Skipped:      p.Skipped,
+		},
+		Owner:    j.owner,
+		Updated:  time.Now(),
+		Finished: j.finished,
+	}
+	if j.err != nil {
+		r.Error = j.err.Error()
+	}
+	return r
+}
+
+const (
+	stateRunning = "running"
+	stateDone    = "done"
+	stateFailed  = "failed"
+)
+
+type jobStatus struct {
+	ID           string `json:"id"`
+	Path         string `json:"path"`
+	State        string `json:"state"`
+	TotalEntries int64  `json:"total_entries"`
+	TotalSize    int64  `json:"total_size"`
+	Entries      int64  `json:"entries"`
+	Size         int64  `json:"size"`
+	Skipped      int64  `json:"skipped"`
+	Error        string `json:"error,omitempty"`
+}
+
+// New creates a new extractor service, which extracts the uploaded archives into a folder.
+func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, err
+	}
+	conf.init()
+
+	gtw, err := pool.GetGatewayServiceClient(pool.Endpoint(conf.GatewaySvc))
+	if err != nil {
+		return nil, err
+	}
+
+	u := uploader.NewUploader(gtw, rhttp.Insecure(conf.Insecure), rhttp.Timeout(time.Duration(conf.Timeout*int64(time.Second))))
+	return newService(conf, u, log)
+}
+
+func newService(conf *config, u uploader.Uploader, log *zerolog.Logger) (*svc, error) {
+	allowedFolders := make([]*regexp.Regexp, 0, len(conf.AllowedFolders))
+	for _, s := range conf.AllowedFolders {
+		regex, err := regexp.Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		allowedFolders = append(allowedFolders, regex)
+	}
+
+	store, err := newJobStore(conf.JobsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &svc{
+		conf:           conf,
+		uploader:       u,
+		log:            log,
+		allowedFolders: allowedFolders,
+		store:          store,
+		jobs:           map[string]*job{},
+	}, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+// Handler serves
+//
+//	POST /?path=<folder>  extract the archive in the body into the folder
+//	GET  /<id>            the status of an extraction
+//
+// The conflict query parameter is one of skip (the default), overwrite or rename,
+// and the format one of zip or tar. Without a format the archive type is detected
+// from its content.
+func (s *svc) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		u, ok := ctxpkg.ContextGetUser(ctx)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		s.purgeJobs()
+
+		id := path.Base(path.Clean("/" + r.URL.Path))
+		switch {
+		case r.Method == http.MethodPost && id == "/":
+			s.handleExtract(w, r, u)
+		case r.Method == http.MethodGet && id != "/":
+			s.handleStatus(w, r, u, id)
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func (s *svc) handleExtract(w http.ResponseWriter, r *http.Request, u *userpb.User) {
+	ctx := r.Context()
+	q := r.URL.Query()
+
+	target := q.Get("path")
+	if target == "" {
+		s.writeHTTPError(w, errtypes.BadRequest("missing path of the folder to extract the archive into"))
+		return
+	}
+	target = path.Clean(target)
+	if !s.isPathAllowed(target) {
+		s.writeHTTPError(w, errtypes.BadRequest(fmt.Sprintf("archives are not allowed to be extracted into %s", target)))
+		return
+	}
+
+	extractor, err := manager.NewExtractor(target, s.uploader, manager.ExtractorConfig{
+		MaxNumFiles: s.conf.MaxNumFiles,
+		MaxSize:     s.conf.MaxSize,
+		Conflict:    manager.ConflictPolicy(q.Get("conflict")),
+	})
+	if err != nil {
+		s.writeHTTPError(w, err)
+		return
+	}
+
+	// the archive is stored as zip files need random access, and the entries
+	// are all checked before extracting them
+	f, err := os.CreateTemp(s.conf.TempDir, "reva-extract-")
+	if err != nil {
+		s.writeHTTPError(w, err)
+		return
+	}
+	discard := func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+
+	body := r.Body
+	if s.conf.MaxUploadSize >= 0 {
+		body = http.MaxBytesReader(w, r.Body, s.conf.MaxUploadSize)
+	}
+	size, err := io.Copy(f, body)
+	if err != nil {
+		discard()
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			err = manager.ErrMaxSize{}
+		}
+		s.writeHTTPError(w, err)
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format, err = detectFormat(f)
+		if err != nil {
+			discard()
+			s.writeHTTPError(w, err)
+			return
+		}
+	}
+
+	var extract func(context.Context) error
+	switch format {
+	case "zip":
+		extract, err = extractor.PrepareZip(ctx, f, size)
+	case "tar":
+		extract, err = extractor.PrepareTar(ctx, f)
+	default:
+		err = errtypes.BadRequest("unknown archive format " + format)
+	}
+	if err != nil {
+		discard()
+		s.writeHTTPError(w, err)
+		return
+	}
+
+	j := &job{
+		id:        uuid.New().String(),
+		owner:     u.Id,
+		target:    target,
+		extractor: extractor,
+		state:     stateRunning,
+	}
+	if err := s.store.save(j.record()); err != nil {
+		discard()
+		s.writeHTTPError(w, err)
+		return
+	}
+	s.mu.Lock()
+	s.jobs[j.id] = j
+	s.mu.Unlock()
+
+	// the extraction outlives the request
+	jobCtx := detachedContext(ctx)
+	go func() {
+		defer discard()
+		log := appctx.GetLogger(jobCtx).With().Str("job", j.id).Str("path", target).Logger()
+
+		done, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			s.saveProgress(j, done, &log)
+			close(stopped)
+		}()
+
+		err := extract(jobCtx)
+		close(done)
+		<-stopped
+		j.mu.Lock()
+		j.finished = time.Now()
+		if err != nil {
+			log.Error().Err(err).Msg("error extracting archive")
+			j.state, j.err = stateFailed, err
+		} else {
+			j.state = stateDone
+		}
+		j.mu.Unlock()
+
+		if err := s.store.save(j.record()); err != nil {
+			log.Error().Err(err).Msg("error saving the status of the extraction")
+		}
+		s.mu.Lock()
+		delete(s.jobs, j.id)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Location", path.Join("/", s.conf.Prefix, j.id))
+	s.writeStatus(w, http.StatusAccepted, &j.record().jobStatus)
+}
+
+// saveProgress saves the progress of a running job until done is closed,
+// which also tells the other processes that the job is still running.
+func (s *svc) saveProgress(j *job, done <-chan struct{}, log *zerolog.Logger) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := s.store.save(j.record()); err != nil {
+				log.Error().Err(err).Msg("error saving the progress of the extraction")
+			}
+		}
+	}
+}
+
+func (s *svc) handleStatus(w http.ResponseWriter, r *http.Request, u *userpb.User, id string) {
+	// the jobs running in this process have a live progress,
+	// the other ones are read from the store
+	var rec *jobRecord
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if ok {
+		rec = j.record()
+	} else {
+		var err error
+		if rec, err = s.store.load(id); err != nil {
+			s.writeHTTPError(w, err)
+			return
+		}
+	}
+
+	if !utils.UserEqual(rec.Owner, u.Id) {
+		s.writeHTTPError(w, errtypes.NotFound(id))
+		return
+	}
+	s.writeStatus(w, http.StatusOK, &rec.jobStatus)
+}
+
+func (s *svc) writeStatus(w http.ResponseWriter, code int, status *jobStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		s.log.Error().Err(err).Msg("error writing response")
+	}
+}
+
+// purgeJobs forgets the extractions finished since longer than the job expiration.
+// The store is scanned at most once a minute.
+func (s *svc) purgeJobs() {
+	s.mu.Lock()
+	if time.Since(s.lastPurge) < time.Minute {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPurge = time.Now()
+	s.mu.Unlock()
+
+	expiration := time.Now().Add(-time.Duration(s.conf.JobExpiration) * time.Second)
+	if err := s.store.purge(expiration); err != nil {
+		s.log.Error().Err(err).Msg("error purging the extraction jobs")
+	}
+}
+
+// detachedContext returns a context carrying the user and the token of ctx,
+// which is not canceled when ctx is.
+func detachedContext(ctx context.Context) context.Context {
+	jobCtx := appctx.WithLogger(context.Background(), appctx.GetLogger(ctx))
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		jobCtx = ctxpkg.ContextSetUser(jobCtx, u)
+	}
+	if tkn, ok := ctxpkg.ContextGetToken(ctx); ok {
+		jobCtx = ctxpkg.ContextSetToken(jobCtx, tkn)
+		jobCtx = metadata.AppendToOutgoingContext(jobCtx, ctxpkg.TokenHeader, tkn)
+	}
+	return jobCtx
+}
+
+// detectFormat tells whether the archive stored in f is a zip or a tar.
+func detectFormat(f *os.File) (string, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	magic, err := bufio.NewReader(f).Peek(4)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	if string(magic) == "PK\x03\x04" || string(magic) == "PK\x05\x06" {
+		return "zip", nil
+	}
+	return "tar", nil
+}
+
+// isPathAllowed tells whether archives can be extracted into the folder.
+func (s *svc) isPathAllowed(p string) bool {
+	if len(s.allowedFolders) == 0 {
+		return true
+	}
+	for _, reg := range s.allowedFolders {
+		if reg.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *svc) writeHTTPError(w http.ResponseWriter, err error) {
+	s.log.Error().Msg(err.Error())
+
+	switch err.(type) {
+	case errtypes.NotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case manager.ErrMaxSize, manager.ErrMaxFileCount:
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	case errtypes.BadRequest:
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	_, _ = w.Write([]byte(err.Error()))
+}
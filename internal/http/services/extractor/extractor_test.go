@@ -0,0 +1,105 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package extractor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/storage/utils/uploader/mock"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+func TestJobStore(t *testing.T) {
+	store, err := newJobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}
+	done := &jobRecord{
+		jobStatus: jobStatus{ID: uuid.New().String(), Path: "/home", State: stateDone, Entries: 3},
+		Owner:     owner,
+		Updated:   time.Now(),
+		Finished:  time.Now().Add(-2 * time.Hour),
+	}
+	stale := &jobRecord{
+		jobStatus: jobStatus{ID: uuid.New().String(), Path: "/home", State: stateRunning},
+		Owner:     owner,
+		Updated:   time.Now().Add(-time.Hour),
+	}
+	for _, r := range []*jobRecord{done, stale} {
+		if err := store.save(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := store.load(done.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != stateDone || r.Entries != 3 || r.Owner.OpaqueId != "einstein" {
+		t.Fatalf("unexpected record: %+v", r)
+	}
+
+	r, err = store.load(stale.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.State != stateFailed {
+		t.Fatalf("interrupted job not failed: %+v", r)
+	}
+
+	if _, err := store.load("../" + done.ID); err == nil {
+		t.Fatal("expected an error for an invalid id")
+	}
+
+	if err := store.purge(time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.load(done.ID); err == nil {
+		t.Fatal("expired job not purged")
+	}
+}
+
+func TestMaxUploadSize(t *testing.T) {
+	dir := t.TempDir()
+	log := zerolog.Nop()
+	conf := &config{TempDir: dir, MaxUploadSize: 10}
+	conf.init()
+	s, err := newService(conf, mock.NewUploader(), &log)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/?path="+dir, strings.NewReader(strings.Repeat("a", 100)))
+	r = r.WithContext(ctxpkg.ContextSetUser(r.Context(), &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}}))
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, r)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("unexpected status code: got=%d, expected=%d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package extractor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// staleAfter is the time after which a running job which was not updated
+// is considered interrupted, as the process extracting it stopped.
+const staleAfter = time.Minute
+
+// jobRecord is the status of an extraction as persisted by the jobStore.
+type jobRecord struct {
+	jobStatus
+	Owner    *userpb.UserId `json:"owner"`
+	Updated  time.Time      `json:"updated"`
+	Finished time.Time      `json:"finished,omitempty"`
+}
+
+// jobStore persists the status of the extractions as a file per job,
+// so that it survives restarts and is shared by the services using the same folder.
+type jobStore struct {
+	dir string
+}
+
+func newJobStore(dir string) (*jobStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "extractor: error creating the jobs folder")
+	}
+	return &jobStore{dir: dir}, nil
+}
+
+func (s *jobStore) path(id string) (string, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", errtypes.NotFound(id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// save writes the record of a job, replacing the previous one at once.
+func (s *jobStore) save(r *jobRecord) error {
+	p, err := s.path(r.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-"+r.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// load reads the record of a job. A running job not updated anymore is reported as failed.
+func (s *jobStore) load(id string) (*jobRecord, error) {
+	p, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errtypes.NotFound(id)
+		}
+		return nil, err
+	}
+	r := &jobRecord{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+
+	if r.State == stateRunning && time.Since(r.Updated) > staleAfter {
+		r.State, r.Error = stateFailed, "extraction interrupted"
+		r.Finished = r.Updated
+	}
+	return r, nil
+}
+
+// purge removes the records of the jobs finished before the expiration.
+func (s *jobStore) purge(expiration time.Time) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".json")
+		if id == e.Name() {
+			continue
+		}
+		r, err := s.load(id)
+		if err != nil {
+			continue
+		}
+		if r.State != stateRunning && r.Finished.Before(expiration) {
+			_ = os.Remove(filepath.Join(s.dir, e.Name()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sort"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/cs3org/reva/pkg/token"
+	"github.com/cs3org/reva/pkg/token/manager/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	global.Register("jwks", New)
+}
+
+type config struct {
+	Prefix        string                            `mapstructure:"prefix" docs:"jwks;The prefix to be used for this HTTP service"`
+	TokenManager  string                            `mapstructure:"token_manager" docs:"jwt;The token manager whose verification keys are published."`
+	TokenManagers map[string]map[string]interface{} `mapstructure:"token_managers" docs:"url:pkg/token/manager/jwt/jwt.go;The configuration for the token managers"`
+}
+
+func (c *config) init() {
+	if c.Prefix == "" {
+		c.Prefix = "jwks"
+	}
+	if c.TokenManager == "" {
+		c.TokenManager = "jwt"
+	}
+}
+
+type svc struct {
+	conf *config
+	jwks []byte
+}
+
+// jwk is a JSON Web Key as defined in RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// New returns a new jwks service publishing the keys to verify the reva tokens.
+func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, err
+	}
+	conf.init()
+
+	f, ok := registry.NewFuncs[conf.TokenManager]
+	if !ok {
+		return nil, fmt.Errorf("jwks: token manager not found: %s", conf.TokenManager)
+	}
+	tm, err := f(conf.TokenManagers[conf.TokenManager])
+	if err != nil {
+		return nil, errors.Wrap(err, "jwks: error creating token manager")
+	}
+	ks, ok := tm.(token.KeySet)
+	if !ok || len(ks.PublicKeys()) == 0 {
+		return nil, fmt.Errorf("jwks: token manager %s does not use public keys", conf.TokenManager)
+	}
+
+	jwks, err := marshalKeySet(ks.PublicKeys())
+	if err != nil {
+		return nil, err
+	}
+	return &svc{conf: conf, jwks: jwks}, nil
+}
+
+func marshalKeySet(keys map[string]crypto.PublicKey) ([]byte, error) {
+	set := struct {
+		Keys []*jwk `json:"keys"`
+	}{}
+	for kid, key := range keys {
+		k, err := newJWK(kid, key)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, k)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return json.Marshal(set)
+}
+
+func newJWK(kid string, key crypto.PublicKey) (*jwk, error) {
+	enc := base64.RawURLEncoding
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &jwk{Kty: "RSA", Use: "sig", Kid: kid, N: enc.EncodeToString(k.N.Bytes()), E: enc.EncodeToString(big.NewInt(int64(k.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		return &jwk{Kty: "EC", Use: "sig", Kid: kid, Crv: k.Curve.Params().Name, X: enc.EncodeToString(k.X.FillBytes(make([]byte, size))), Y: enc.EncodeToString(k.Y.FillBytes(make([]byte, size)))}, nil
+	case ed25519.PublicKey:
+		return &jwk{Kty: "OKP", Use: "sig", Kid: kid, Crv: "Ed25519", X: enc.EncodeToString(k)}, nil
+	}
+	return nil, fmt.Errorf("jwks: unsupported key type %T", key)
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{"/"}
+}
+
+func (s *svc) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log := appctx.GetLogger(r.Context())
+		if r.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		if _, err := w.Write(s.jwks); err != nil {
+			log.Err(err).Msg("error writing response")
+		}
+	})
+}
@@ -22,10 +22,14 @@ import (
 	// Load core HTTP services.
 	_ "github.com/cs3org/reva/internal/http/services/appprovider"
 	_ "github.com/cs3org/reva/internal/http/services/archiver"
+	_ "github.com/cs3org/reva/internal/http/services/audit"
 	_ "github.com/cs3org/reva/internal/http/services/datagateway"
 	_ "github.com/cs3org/reva/internal/http/services/dataprovider"
+	_ "github.com/cs3org/reva/internal/http/services/extractor"
 	_ "github.com/cs3org/reva/internal/http/services/helloworld"
+	_ "github.com/cs3org/reva/internal/http/services/jwks"
 	_ "github.com/cs3org/reva/internal/http/services/mailer"
+	_ "github.com/cs3org/reva/internal/http/services/maintenance"
 	_ "github.com/cs3org/reva/internal/http/services/mentix"
 	_ "github.com/cs3org/reva/internal/http/services/meshdirectory"
 	_ "github.com/cs3org/reva/internal/http/services/metrics"
@@ -35,6 +39,7 @@ import (
 	_ "github.com/cs3org/reva/internal/http/services/preferences"
 	_ "github.com/cs3org/reva/internal/http/services/prometheus"
 	_ "github.com/cs3org/reva/internal/http/services/reverseproxy"
+	_ "github.com/cs3org/reva/internal/http/services/sessions"
 	_ "github.com/cs3org/reva/internal/http/services/siteacc"
 	_ "github.com/cs3org/reva/internal/http/services/sysinfo"
 	_ "github.com/cs3org/reva/internal/http/services/wellknown"
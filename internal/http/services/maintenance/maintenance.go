@@ -0,0 +1,210 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/maintenance"
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	global.Register("maintenance", New)
+}
+
+type config struct {
+	Prefix     string `mapstructure:"prefix" docs:"maintenance;The prefix to be used for this HTTP service"`
+	GatewaySVC string `mapstructure:"gatewaysvc" docs:";The gateway checking the manage-maintenance permission of the users."`
+}
+
+func (c *config) init() {
+	if c.Prefix == "" {
+		c.Prefix = "maintenance"
+	}
+	c.GatewaySVC = sharedconf.GetGatewaySVC(c.GatewaySVC)
+}
+
+type svc struct {
+	conf *config
+}
+
+// New returns a new maintenance service, to toggle at runtime the read-only
+// modes of the readonly interceptors running in the same process. The toggles
+// are not spread to the other revad processes: to block the writes to a storage
+// provider running elsewhere, the service has to be served by its own process.
+func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, err
+	}
+	conf.init()
+
+	return &svc{conf: conf}, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+// checkPermission tells whether the user may toggle the read-only modes.
+// Unlike most permissions, it is denied when it cannot be checked.
+func (s *svc) checkPermission(ctx context.Context, u *userpb.User) (bool, error) {
+	client, err := pool.GetGatewayServiceClient(pool.Endpoint(s.conf.GatewaySVC))
+	if err != nil {
+		return false, errors.Wrap(err, "error getting gateway client")
+	}
+	res, err := client.CheckPermission(ctx, &permissions.CheckPermissionRequest{
+		Permission: permission.ManageMaintenance,
+		SubjectRef: &permissions.SubjectReference{
+			Spec: &permissions.SubjectReference_UserId{
+				UserId: u.Id,
+			},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "error calling CheckPermission")
+	}
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+		return true, nil
+	case rpc.Code_CODE_PERMISSION_DENIED:
+		return false, nil
+	}
+	return false, errors.New("error checking permission: " + res.Status.Message)
+}
+
+type mode struct {
+	Name string `json:"name"`
+	maintenance.State
+}
+
+// update changes the fields of a state, the missing ones are kept.
+type update struct {
+	Enabled     *bool   `json:"enabled"`
+	Maintenance *bool   `json:"maintenance"`
+	Message     *string `json:"message"`
+	RetryAfter  *int    `json:"retry_after"`
+}
+
+func (u *update) apply(st maintenance.State) maintenance.State {
+	if u.Enabled != nil {
+		st.Enabled = *u.Enabled
+	}
+	if u.Maintenance != nil {
+		st.Maintenance = *u.Maintenance
+	}
+	if u.Message != nil {
+		st.Message = *u.Message
+	}
+	if u.RetryAfter != nil {
+		st.RetryAfter = *u.RetryAfter
+	}
+	return st
+}
+
+// Handler serves
+//
+//	GET /        the read-only modes of the process
+//	GET /<name>  a single mode
+//	PUT /<name>  change a mode, e.g. {"enabled": true, "message": "migrating the storage"}
+//
+// Only the users with the manage-maintenance permission are allowed.
+func (s *svc) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := appctx.GetLogger(ctx)
+
+		u, ok := ctxpkg.ContextGetUser(ctx)
+		if !ok {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		allowed, err := s.checkPermission(ctx, u)
+		if err != nil {
+			log.Error().Err(err).Msg("maintenance: error checking the permission")
+		}
+		if !allowed {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		name := path.Base(path.Clean("/" + r.URL.Path))
+		if name == "/" {
+			name = ""
+		}
+
+		var res interface{}
+		switch {
+		case r.Method == http.MethodGet && name == "":
+			modes := []*mode{}
+			for _, m := range maintenance.List() {
+				modes = append(modes, &mode{Name: m.Name(), State: m.State()})
+			}
+			res = modes
+		case r.Method == http.MethodGet, r.Method == http.MethodPut:
+			m, ok := maintenance.Get(name)
+			if !ok {
+				http.Error(w, "read-only mode not found: "+name, http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodPut {
+				up := &update{}
+				if err := json.NewDecoder(r.Body).Decode(up); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				m.SetState(up.apply(m.State()))
+				log.Info().Str("mode", name).Str("user", u.Username).Interface("state", m.State()).Msg("maintenance: read-only mode changed")
+			}
+			res = &mode{Name: m.Name(), State: m.State()}
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			log.Err(err).Msg("error writing response")
+		}
+	})
+}
@@ -267,6 +267,7 @@ func (s *svc) executePathCopy(ctx context.Context, client gateway.GatewayAPIClie
 		if httpUploadRes.StatusCode != http.StatusOK {
 			return err
 		}
+		s.indexResource(ctx, cp.destination)
 	}
 	return nil
 }
@@ -485,6 +486,7 @@ func (s *svc) executeSpacesCopy(ctx context.Context, w http.ResponseWriter, clie
 		if httpUploadRes.StatusCode != http.StatusOK {
 			return err
 		}
+		s.indexResource(ctx, cp.destination)
 	}
 	return nil
 }
@@ -45,6 +45,8 @@ const (
 	SabredavNotFound
 	// SabredavConflict maps to HTTP 409.
 	SabredavConflict
+	// SabredavServiceUnavailable maps to HTTP 503.
+	SabredavServiceUnavailable
 )
 
 var (
@@ -56,6 +58,7 @@ var (
 		"Sabre\\DAV\\Exception\\PermissionDenied",
 		"Sabre\\DAV\\Exception\\NotFound",
 		"Sabre\\DAV\\Exception\\Conflict",
+		"Sabre\\DAV\\Exception\\ServiceUnavailable",
 	}
 )
 
@@ -122,6 +125,15 @@ func HandleErrorStatus(log *zerolog.Logger, w http.ResponseWriter, s *rpc.Status
 	case rpc.Code_CODE_FAILED_PRECONDITION:
 		log.Debug().Interface("status", s).Msg("destination does not exist")
 		w.WriteHeader(http.StatusConflict)
+	case rpc.Code_CODE_UNAVAILABLE:
+		log.Debug().Interface("status", s).Msg("service unavailable")
+		// the Retry-After header is added by maintenance.Handler
+		w.WriteHeader(http.StatusServiceUnavailable)
+		b, err := Marshal(exception{
+			code:    SabredavServiceUnavailable,
+			message: s.Message,
+		})
+		HandleWebdavError(log, w, b, err)
 	default:
 		log.Error().Interface("status", s).Msg("grpc request failed")
 		w.WriteHeader(http.StatusInternalServerError)
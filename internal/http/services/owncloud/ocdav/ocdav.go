@@ -33,6 +33,7 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/maintenance"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/rhttp"
 	"github.com/cs3org/reva/pkg/rhttp/global"
@@ -182,7 +183,7 @@ func (s *svc) Unprotected() []string {
 }
 
 func (s *svc) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return maintenance.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		log := appctx.GetLogger(ctx)
 
@@ -251,7 +252,7 @@ func (s *svc) Handler() http.Handler {
 		}
 		log.Warn().Msg("resource not found")
 		w.WriteHeader(http.StatusNotFound)
-	})
+	}))
 }
 
 func (s *svc) getClient() (gateway.GatewayAPIClient, error) {
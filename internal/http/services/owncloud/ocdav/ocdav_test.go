@@ -23,10 +23,14 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/maintenance"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/utils/resourceid"
+	"github.com/rs/zerolog"
 )
 
 /*
@@ -159,3 +163,24 @@ func TestNameDoesNotContainRule(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleErrorStatusMaintenance(t *testing.T) {
+	log := zerolog.Nop()
+	w := httptest.NewRecorder()
+	h := maintenance.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// as received from the gateway
+		maintenance.SetRetryAfter(r.Context(), 600)
+		HandleErrorStatus(&log, w, status.NewUnavailable(r.Context(), "migrating the storage"))
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/remote.php/webdav/file", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+	if h := w.Header().Get(HeaderRetryAfter); h != "600" {
+		t.Fatalf("expected Retry-After 600, got %q", h)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<s:message>migrating the storage</s:message>") {
+		t.Fatalf("expected the maintenance message in the body, got %s", body)
+	}
+}
@@ -28,6 +28,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/search"
 )
 
 const (
@@ -47,7 +48,7 @@ func (s *svc) handleReport(w http.ResponseWriter, r *http.Request, ns string) {
 		return
 	}
 	if rep.SearchFiles != nil {
-		s.doSearchFiles(w, r, rep.SearchFiles)
+		s.doSearchFiles(w, r, rep.SearchFiles, ns)
 		return
 	}
 
@@ -61,16 +62,72 @@ func (s *svc) handleReport(w http.ResponseWriter, r *http.Request, ns string) {
 	w.WriteHeader(http.StatusNotImplemented)
 }
 
-func (s *svc) doSearchFiles(w http.ResponseWriter, r *http.Request, sf *reportSearchFiles) {
+func (s *svc) doSearchFiles(w http.ResponseWriter, r *http.Request, sf *reportSearchFiles, namespace string) {
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
-	_, err := s.getClient()
+
+	if strings.TrimSpace(sf.Search.Pattern) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		b, err := Marshal(exception{
+			code:    SabredavBadRequest,
+			message: "search pattern must not be empty",
+		})
+		HandleWebdavError(log, w, b, err)
+		return
+	}
+
+	client, err := s.getSearchClient()
 	if err != nil {
-		log.Error().Err(err).Msg("error getting grpc client")
+		log.Error().Err(err).Msg("error getting search client")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusNotImplemented)
+
+	res, err := client.Search(ctx, &search.SearchRequest{
+		Pattern: sf.Search.Pattern,
+		Offset:  sf.Search.Offset,
+		Limit:   sf.Search.Limit,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("error sending a grpc search request")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if res.Status.Code != rpcv1beta1.Code_CODE_OK {
+		HandleErrorStatus(log, w, res.Status)
+		return
+	}
+	infos := res.Infos
+
+	if s.c.WebdavNamespace != "" {
+		trimmed := infos[:0]
+		for _, info := range infos {
+			// If global URLs are not supported, return only the file path
+			// The paths we receive have the format /user/<username>/<filepath>
+			// We only want the `<filepath>` part. Thus we remove the /user/<username>/ part.
+			parts := strings.SplitN(info.Path, "/", 4)
+			if len(parts) != 4 {
+				log.Error().Str("path", info.Path).Msg("path doesn't have the expected format")
+				continue
+			}
+			info.Path = parts[3]
+			trimmed = append(trimmed, info)
+		}
+		infos = trimmed
+	}
+
+	responsesXML, err := s.multistatusResponse(ctx, &propfindXML{Prop: sf.Prop}, infos, namespace, nil, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("error formatting propfind")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(HeaderDav, "1, 3, extended-mkcol")
+	w.Header().Set(HeaderContentType, "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	if _, err := w.Write([]byte(responsesXML)); err != nil {
+		log.Err(err).Msg("error writing response")
+	}
 }
 
 func (s *svc) doFilterFiles(w http.ResponseWriter, r *http.Request, ff *reportFilterFiles, namespace string) {
@@ -148,7 +205,7 @@ type reportSearchFiles struct {
 	Search  reportSearchFilesSearch `xml:"search"`
 }
 type reportSearchFilesSearch struct {
-	Pattern string `xml:"search"`
+	Pattern string `xml:"pattern"`
 	Limit   int    `xml:"limit"`
 	Offset  int    `xml:"offset"`
 }
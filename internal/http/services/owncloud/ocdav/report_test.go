@@ -61,3 +61,37 @@ func TestUnmarshallReportFilterFiles(t *testing.T) {
 		t.Error("Failed to correctly unmarshal filter-rules. Favorite is expected to be true.")
 	}
 }
+
+func TestUnmarshallReportSearchFiles(t *testing.T) {
+	sfXML := `<oc:search-files xmlns:d="DAV:" xmlns:oc="http://owncloud.org/ns">
+    <d:prop>
+        <d:getlastmodified />
+        <oc:fileid />
+        <oc:size />
+    </d:prop>
+    <oc:search>
+        <oc:pattern>report</oc:pattern>
+        <oc:limit>30</oc:limit>
+        <oc:offset>10</oc:offset>
+    </oc:search>
+</oc:search-files>`
+
+	report, status, err := readReport(strings.NewReader(sfXML))
+	if status != 0 || err != nil {
+		t.Fatal("Failed to unmarshal search-files xml")
+	}
+
+	if report.SearchFiles == nil {
+		t.Fatal("Failed to unmarshal search-files xml. SearchFiles is nil")
+	}
+
+	if report.SearchFiles.Search.Pattern != "report" {
+		t.Errorf("Expected pattern 'report', got '%s'", report.SearchFiles.Search.Pattern)
+	}
+	if report.SearchFiles.Search.Limit != 30 || report.SearchFiles.Search.Offset != 10 {
+		t.Errorf("Expected limit 30 and offset 10, got %d and %d", report.SearchFiles.Search.Limit, report.SearchFiles.Search.Offset)
+	}
+	if len(report.SearchFiles.Prop) != 3 {
+		t.Errorf("Expected 3 requested properties, got %d", len(report.SearchFiles.Prop))
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocdav
+
+import (
+	"context"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/search"
+)
+
+func (s *svc) getSearchClient() (search.SearchAPIClient, error) {
+	return pool.GetSearchClient(pool.Endpoint(s.c.GatewaySvc))
+}
+
+// indexInfo asks the gateway to add the resource to the search index.
+// The gateway indexes the other changes, but it does not see uploads finish.
+func (s *svc) indexInfo(ctx context.Context, info *provider.ResourceInfo) {
+	if info == nil || info.Id == nil {
+		return
+	}
+	s.indexResource(ctx, &provider.Reference{ResourceId: info.Id})
+}
+
+// indexResource asks the gateway to add the referenced resource to the search index.
+// Failing to do so must not fail the request that changed the resource, so errors are only logged.
+func (s *svc) indexResource(ctx context.Context, ref *provider.Reference) {
+	log := appctx.GetLogger(ctx)
+	client, err := s.getSearchClient()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting search client")
+		return
+	}
+	res, err := client.Index(ctx, &search.IndexRequest{Ref: ref})
+	if err != nil {
+		log.Error().Err(err).Interface("ref", ref).Msg("error sending a grpc index request")
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		log.Debug().Interface("ref", ref).Interface("status", res.Status).Msg("could not update the search index")
+	}
+}
@@ -288,6 +288,7 @@ func (s *svc) handleTusPost(ctx context.Context, w http.ResponseWriter, r *http.
 				w.WriteHeader(http.StatusInternalServerError)
 				return
 			}
+			s.indexInfo(ctx, info)
 			if httpRes != nil && httpRes.Header != nil && httpRes.Header.Get(HeaderOCMtime) != "" {
 				// set the "accepted" value if returned in the upload response headers
 				w.Header().Set(HeaderOCMtime, httpRes.Header.Get(HeaderOCMtime))
@@ -50,6 +50,7 @@ const (
 	HeaderLastModified               = "Last-Modified"
 	HeaderLocation                   = "Location"
 	HeaderRange                      = "Range"
+	HeaderRetryAfter                 = "Retry-After"
 	HeaderIfMatch                    = "If-Match"
 	HeaderChecksum                   = "Digest"
 )
@@ -227,7 +227,7 @@ func PublicShare2ShareData(share *link.PublicShare, r *http.Request, publicURL s
 		sd.Permissions = RoleFromResourcePermissions(share.GetPermissions().GetPermissions()).OCSPermissions()
 	}
 	if share.Expiration != nil {
-		sd.Expiration = timestampToExpiration(share.Expiration)
+		sd.Expiration = TimestampToExpiration(share.Expiration)
 	}
 	if share.Ctime != nil {
 		sd.STime = share.Ctime.Seconds // TODO CS3 api birth time = btime
@@ -277,9 +277,10 @@ func GetPublicShareManager(manager string, m map[string]map[string]interface{})
 	return nil, fmt.Errorf("driver %s not found for public shares manager", manager)
 }
 
+// TimestampToExpiration formats a share expiration for the ocs api.
 // timestamp is assumed to be UTC ... just human readable ...
 // FIXME and ambiguous / error prone because there is no time zone ...
-func timestampToExpiration(t *types.Timestamp) string {
+func TimestampToExpiration(t *types.Timestamp) string {
 	return time.Unix(int64(t.Seconds), int64(t.Nanos)).UTC().Format("2006-01-02 15:05:05")
 }
 
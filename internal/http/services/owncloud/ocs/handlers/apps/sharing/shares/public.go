@@ -153,6 +153,9 @@ func (h *Handler) createPublicLinkShare(w http.ResponseWriter, r *http.Request,
 	}
 
 	if createRes.Status.Code != rpc.Code_CODE_OK {
+		if response.WriteOCSMaintenance(w, r, createRes.Status) {
+			return
+		}
 		log.Debug().Err(errors.New("create public share failed")).Str("shares", "createShare").Msgf("create public share failed with status code: %v", createRes.Status.Code.String())
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "grpc create public share request failed", err)
 		return
@@ -444,6 +447,9 @@ func (h *Handler) removePublicShare(w http.ResponseWriter, r *http.Request, shar
 		return
 	}
 	if res.Status.Code != rpc.Code_CODE_OK {
+		if response.WriteOCSMaintenance(w, r, res.Status) {
+			return
+		}
 		if res.Status.Code == rpc.Code_CODE_NOT_FOUND {
 			response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "not found", nil)
 			return
@@ -39,6 +39,7 @@ import (
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocdav"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/config"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/conversions"
@@ -375,6 +376,7 @@ func (h *Handler) GetShare(w http.ResponseWriter, r *http.Request) {
 				response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error mapping share data", err)
 				return
 			}
+			setExpiration(share, uRes.Opaque)
 		}
 	}
 
@@ -423,21 +425,46 @@ func (h *Handler) updateShare(w http.ResponseWriter, r *http.Request, shareID st
 	ctx := r.Context()
 	log := appctx.GetLogger(ctx)
 
+	expiration, updateExpiration, err := expirationFromRequest(r)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "invalid datetime format", err)
+		return
+	}
+
 	pval := r.FormValue("permissions")
-	if pval == "" {
+	if pval == "" && !updateExpiration {
 		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "permissions missing", nil)
 		return
 	}
 
-	pint, err := strconv.Atoi(pval)
-	if err != nil {
-		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "permissions must be an integer", nil)
-		return
+	var field *collaboration.UpdateShareRequest_UpdateField
+	if pval != "" {
+		pint, err := strconv.Atoi(pval)
+		if err != nil {
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "permissions must be an integer", nil)
+			return
+		}
+		permissions, err := conversions.NewPermissions(pint)
+		if err != nil {
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), nil)
+			return
+		}
+		field = &collaboration.UpdateShareRequest_UpdateField{
+			Field: &collaboration.UpdateShareRequest_UpdateField_Permissions{
+				Permissions: &collaboration.SharePermissions{
+					// this completely overwrites the permissions for this user
+					Permissions: conversions.RoleFromOCSPermissions(permissions).CS3ResourcePermissions(),
+				},
+			},
+		}
 	}
-	permissions, err := conversions.NewPermissions(pint)
-	if err != nil {
-		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), nil)
-		return
+
+	var opaque *types.Opaque
+	if updateExpiration {
+		if opaque, err = share.AddExpirationToOpaque(nil, expiration); err != nil {
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding the expiration", err)
+			return
+		}
 	}
 
 	client, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
@@ -447,6 +474,7 @@ func (h *Handler) updateShare(w http.ResponseWriter, r *http.Request, shareID st
 	}
 
 	uReq := &collaboration.UpdateShareRequest{
+		Opaque: opaque,
 		Ref: &collaboration.ShareReference{
 			Spec: &collaboration.ShareReference_Id{
 				Id: &collaboration.ShareId{
@@ -454,14 +482,7 @@ func (h *Handler) updateShare(w http.ResponseWriter, r *http.Request, shareID st
 				},
 			},
 		},
-		Field: &collaboration.UpdateShareRequest_UpdateField{
-			Field: &collaboration.UpdateShareRequest_UpdateField_Permissions{
-				Permissions: &collaboration.SharePermissions{
-					// this completely overwrites the permissions for this user
-					Permissions: conversions.RoleFromOCSPermissions(permissions).CS3ResourcePermissions(),
-				},
-			},
-		},
+		Field: field,
 	}
 	uRes, err := client.UpdateShare(ctx, uReq)
 	if err != nil {
@@ -470,9 +491,16 @@ func (h *Handler) updateShare(w http.ResponseWriter, r *http.Request, shareID st
 	}
 
 	if uRes.Status.Code != rpc.Code_CODE_OK {
-		if uRes.Status.Code == rpc.Code_CODE_NOT_FOUND {
+		if response.WriteOCSMaintenance(w, r, uRes.Status) {
+			return
+		}
+		switch uRes.Status.Code {
+		case rpc.Code_CODE_NOT_FOUND:
 			response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "not found", nil)
 			return
+		case rpc.Code_CODE_INVALID_ARGUMENT:
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, uRes.Status.Message, nil)
+			return
 		}
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "grpc update share request failed", err)
 		return
@@ -483,6 +511,7 @@ func (h *Handler) updateShare(w http.ResponseWriter, r *http.Request, shareID st
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error mapping share data", err)
 		return
 	}
+	setExpiration(share, uRes.Opaque)
 
 	statReq := provider.StatRequest{Ref: &provider.Reference{
 		ResourceId: uRes.Share.ResourceId,
@@ -657,6 +686,10 @@ func (h *Handler) listSharesWithMe(w http.ResponseWriter, r *http.Request) {
 	}
 
 	shares := make([]*conversions.ShareData, 0, len(lrsRes.GetShares()))
+	expirations, err := share.ExpirationsFromOpaque(lrsRes.Opaque)
+	if err != nil {
+		log.Debug().Err(err).Msg("could not decode share expirations, continuing without them")
+	}
 
 	// TODO(refs) filter out "invalid" shares
 	for _, rs := range lrsRes.GetShares() {
@@ -688,6 +721,9 @@ func (h *Handler) listSharesWithMe(w http.ResponseWriter, r *http.Request) {
 		}
 
 		data.State = mapState(rs.GetState())
+		if e, ok := expirations[rs.Share.Id.GetOpaqueId()]; ok {
+			data.Expiration = conversions.TimestampToExpiration(e)
+		}
 
 		if err := h.addFileInfo(ctx, data, info); err != nil {
 			log.Debug().Interface("received_share", rs).Interface("info", info).Interface("shareData", data).Err(err).Msg("could not add file info, skipping")
@@ -1089,15 +1125,34 @@ func (h *Handler) getResourceInfo(ctx context.Context, client gateway.GatewayAPI
 }
 
 func (h *Handler) createCs3Share(ctx context.Context, w http.ResponseWriter, r *http.Request, client gateway.GatewayAPIClient, req *collaboration.CreateShareRequest, info *provider.ResourceInfo) {
+	expiration, _, err := expirationFromRequest(r)
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, "invalid datetime format", err)
+		return
+	}
+	if expiration != nil {
+		if req.Opaque, err = share.AddExpirationToOpaque(req.Opaque, expiration); err != nil {
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error encoding the expiration", err)
+			return
+		}
+	}
+
 	createShareResponse, err := client.CreateShare(ctx, req)
 	if err != nil {
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error sending a grpc create share request", err)
 		return
 	}
 	if createShareResponse.Status.Code != rpc.Code_CODE_OK {
-		if createShareResponse.Status.Code == rpc.Code_CODE_NOT_FOUND {
+		if response.WriteOCSMaintenance(w, r, createShareResponse.Status) {
+			return
+		}
+		switch createShareResponse.Status.Code {
+		case rpc.Code_CODE_NOT_FOUND:
 			response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "not found", nil)
 			return
+		case rpc.Code_CODE_INVALID_ARGUMENT:
+			response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, createShareResponse.Status.Message, nil)
+			return
 		}
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "grpc create share request failed", err)
 		return
@@ -1107,6 +1162,7 @@ func (h *Handler) createCs3Share(ctx context.Context, w http.ResponseWriter, r *
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error mapping share data", err)
 		return
 	}
+	setExpiration(s, createShareResponse.Opaque)
 	err = h.addFileInfo(ctx, s, info)
 	if err != nil {
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error adding fileinfo to share", err)
@@ -1117,6 +1173,31 @@ func (h *Handler) createCs3Share(ctx context.Context, w http.ResponseWriter, r *
 	response.WriteOCSSuccess(w, r, s)
 }
 
+// expirationFromRequest parses the expireDate of a user or group share. The returned bool reports
+// whether the request carried an expireDate at all, an empty expireDate removes the expiration.
+func expirationFromRequest(r *http.Request) (*types.Timestamp, bool, error) {
+	_ = r.FormValue("expireDate") // populates r.Form
+	v, ok := r.Form["expireDate"]
+	if !ok {
+		return nil, false, nil
+	}
+	if v[0] == "" {
+		return nil, true, nil
+	}
+	expiration, err := conversions.ParseTimestamp(v[0])
+	if err != nil {
+		return nil, true, err
+	}
+	return expiration, true, nil
+}
+
+// setExpiration copies the expiration the user share provider returned in the opaque to the share data.
+func setExpiration(s *conversions.ShareData, o *types.Opaque) {
+	if expiration, _, err := share.ExpirationFromOpaque(o); err == nil && expiration != nil {
+		s.Expiration = conversions.TimestampToExpiration(expiration)
+	}
+}
+
 func mapState(state collaboration.ShareState) int {
 	var mapped int
 	switch state {
@@ -30,6 +30,7 @@ import (
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/response"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/share"
 )
 
 func (h *Handler) createUserShare(w http.ResponseWriter, r *http.Request, statInfo *provider.ResourceInfo, role *conversions.Role, roleVal []byte) {
@@ -155,6 +156,9 @@ func (h *Handler) removeUserShare(w http.ResponseWriter, r *http.Request, shareI
 	}
 
 	if uRes.Status.Code != rpc.Code_CODE_OK {
+		if response.WriteOCSMaintenance(w, r, uRes.Status) {
+			return
+		}
 		if uRes.Status.Code == rpc.Code_CODE_NOT_FOUND {
 			response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "not found", nil)
 			return
@@ -190,6 +194,11 @@ func (h *Handler) listUserShares(r *http.Request, filters []*collaboration.Filte
 			return ocsDataPayload, lsUserSharesResponse.Status, nil
 		}
 
+		expirations, err := share.ExpirationsFromOpaque(lsUserSharesResponse.Opaque)
+		if err != nil {
+			log.Debug().Err(err).Msg("could not decode share expirations, continuing without them")
+		}
+
 		// build OCS response payload
 		for _, s := range lsUserSharesResponse.Shares {
 			data, err := conversions.CS3Share2ShareData(ctx, s)
@@ -197,6 +206,9 @@ func (h *Handler) listUserShares(r *http.Request, filters []*collaboration.Filte
 				log.Debug().Interface("share", s).Interface("shareData", data).Err(err).Msg("could not CS3Share2ShareData, skipping")
 				continue
 			}
+			if e, ok := expirations[s.Id.GetOpaqueId()]; ok {
+				data.Expiration = conversions.TimestampToExpiration(e)
+			}
 
 			info, status, err := h.getResourceInfoByID(ctx, client, s.ResourceId)
 			if err != nil || status.Code != rpc.Code_CODE_OK {
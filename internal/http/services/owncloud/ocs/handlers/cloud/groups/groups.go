@@ -0,0 +1,211 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package groups
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/config"
+	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/response"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler renders group data for the /cloud/groups endpoints.
+type Handler struct {
+	gatewayAddr string
+}
+
+// Init initializes this and any contained handlers.
+func (h *Handler) Init(c *config.Config) {
+	h.gatewayAddr = c.GatewaySvc
+}
+
+// Group holds the details of a single group.
+type Group struct {
+	ID          string `json:"id" xml:"id"`
+	DisplayName string `json:"displayname" xml:"displayname"`
+	Email       string `json:"email,omitempty" xml:"email,omitempty"`
+}
+
+// Groups holds group data.
+type Groups struct {
+	// api compatibility with oc10: the plain list only carries the group names
+	Groups  []string `json:"groups" xml:"groups>element"`
+	Details []*Group `json:"details" xml:"details>element"`
+}
+
+// Member holds the details of a single group member.
+type Member struct {
+	ID          string `json:"id" xml:"id"`
+	DisplayName string `json:"displayname" xml:"displayname"`
+	Email       string `json:"email,omitempty" xml:"email,omitempty"`
+}
+
+// Members holds the members of a group.
+type Members struct {
+	// api compatibility with oc10: the plain list only carries the usernames
+	Users   []string  `json:"users" xml:"users>element"`
+	Details []*Member `json:"details" xml:"details>element"`
+}
+
+// ListGroups handles GET requests on /cloud/groups.
+// The optional search, limit and offset query parameters narrow down the result.
+func (h *Handler) ListGroups(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+	q := r.URL.Query()
+
+	limit, offset, err := pagination(q.Get("limit"), q.Get("offset"))
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaBadRequest.StatusCode, err.Error(), err)
+		return
+	}
+
+	gwc, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error getting gateway grpc client", err)
+		return
+	}
+
+	res, err := gwc.FindGroups(ctx, &grouppb.FindGroupsRequest{Filter: q.Get("search"), SkipFetchingMembers: true})
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error searching groups", err)
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, res.Status.Message, nil)
+		return
+	}
+	log.Debug().Int("count", len(res.GetGroups())).Str("search", q.Get("search")).Msg("groups found")
+
+	found := res.GetGroups()
+	if offset >= len(found) {
+		found = nil
+	} else {
+		found = found[offset:]
+	}
+	if limit > 0 && limit < len(found) {
+		found = found[:limit]
+	}
+
+	groups := &Groups{Groups: make([]string, 0, len(found)), Details: make([]*Group, 0, len(found))}
+	for _, g := range found {
+		groups.Groups = append(groups.Groups, g.GroupName)
+		groups.Details = append(groups.Details, AsGroup(g))
+	}
+	response.WriteOCSSuccess(w, r, groups)
+}
+
+// GetGroupMembers handles GET requests on /cloud/groups/{groupid} and lists the members of the group.
+func (h *Handler) GetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+	name := chi.URLParam(r, "groupid")
+
+	gwc, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error getting gateway grpc client", err)
+		return
+	}
+
+	groupRes, err := gwc.GetGroupByClaim(ctx, &grouppb.GetGroupByClaimRequest{Claim: "group_name", Value: name, SkipFetchingMembers: true})
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error looking up group", err)
+		return
+	}
+	switch groupRes.Status.Code {
+	case rpc.Code_CODE_OK:
+	case rpc.Code_CODE_NOT_FOUND:
+		response.WriteOCSError(w, r, response.MetaNotFound.StatusCode, "group not found", nil)
+		return
+	default:
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, groupRes.Status.Message, nil)
+		return
+	}
+
+	membersRes, err := gwc.GetMembers(ctx, &grouppb.GetMembersRequest{GroupId: groupRes.Group.Id})
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error listing group members", err)
+		return
+	}
+	if membersRes.Status.Code != rpc.Code_CODE_OK {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, membersRes.Status.Message, nil)
+		return
+	}
+
+	members := &Members{Users: make([]string, 0, len(membersRes.Members)), Details: make([]*Member, 0, len(membersRes.Members))}
+	for _, id := range membersRes.Members {
+		u, err := getUser(ctx, gwc, id)
+		if err != nil {
+			response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error looking up group member", err)
+			return
+		}
+		if u == nil {
+			// the member may have been removed from the user backend in the meantime
+			log.Debug().Interface("user", id).Str("group", name).Msg("skipping unknown group member")
+			continue
+		}
+		members.Users = append(members.Users, u.Username)
+		members.Details = append(members.Details, &Member{ID: u.Username, DisplayName: u.DisplayName, Email: u.Mail})
+	}
+	response.WriteOCSSuccess(w, r, members)
+}
+
+// AsGroup converts a cs3 group into its ocs representation.
+func AsGroup(g *grouppb.Group) *Group {
+	return &Group{ID: g.GroupName, DisplayName: g.DisplayName, Email: g.Mail}
+}
+
+func getUser(ctx context.Context, gwc gateway.GatewayAPIClient, id *userpb.UserId) (*userpb.User, error) {
+	res, err := gwc.GetUser(ctx, &userpb.GetUserRequest{UserId: id, SkipFetchingUserGroups: true})
+	if err != nil {
+		return nil, err
+	}
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+		return res.User, nil
+	case rpc.Code_CODE_NOT_FOUND:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("error getting user: %s", res.Status.Message)
+	}
+}
+
+func pagination(l, o string) (limit, offset int, err error) {
+	if l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			return 0, 0, fmt.Errorf("invalid limit %q", l)
+		}
+	}
+	if o != "" {
+		if offset, err = strconv.Atoi(o); err != nil || offset < 0 {
+			return 0, 0, fmt.Errorf("invalid offset %q", o)
+		}
+	}
+	return limit, offset, nil
+}
@@ -19,20 +19,25 @@
 package users
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocdav"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/config"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/conversions"
+	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/cloud/groups"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/response"
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/utils"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -46,10 +51,53 @@ func (h *Handler) Init(c *config.Config) {
 	h.gatewayAddr = c.GatewaySvc
 }
 
-// GetGroups handles GET requests on /cloud/users/groups
-// TODO: implement.
+// GetGroups handles GET requests on /cloud/users/{userid}/groups
+// Only allow self-read currently.
 func (h *Handler) GetGroups(w http.ResponseWriter, r *http.Request) {
-	response.WriteOCSSuccess(w, r, &Groups{})
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	user := chi.URLParam(r, "userid")
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "missing user in context", fmt.Errorf("missing user in context"))
+		return
+	}
+	if user != u.Username {
+		response.WriteOCSError(w, r, http.StatusForbidden, "user id mismatch", fmt.Errorf("%s tried to access %s user groups endpoint", u.Id.OpaqueId, user))
+		return
+	}
+
+	gc, err := pool.GetGatewayServiceClient(pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error getting gateway grpc client", err)
+		return
+	}
+
+	res, err := gc.GetUserGroups(ctx, &userpb.GetUserGroupsRequest{UserId: u.Id})
+	if err != nil {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "error getting user groups", err)
+		return
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, res.Status.Message, nil)
+		return
+	}
+
+	result := &groups.Groups{Groups: make([]string, 0, len(res.Groups)), Details: make([]*groups.Group, 0, len(res.Groups))}
+	for _, name := range res.Groups {
+		result.Groups = append(result.Groups, name)
+
+		groupRes, err := gc.GetGroupByClaim(ctx, &grouppb.GetGroupByClaimRequest{Claim: "group_name", Value: name, SkipFetchingMembers: true})
+		if err != nil || groupRes.Status.Code != rpc.Code_CODE_OK {
+			// fall back to the plain name when the group provider does not know the group
+			log.Debug().Err(err).Str("group", name).Msg("could not resolve group details")
+			result.Details = append(result.Details, &groups.Group{ID: name, DisplayName: name})
+			continue
+		}
+		result.Details = append(result.Details, groups.AsGroup(groupRes.Group))
+	}
+	response.WriteOCSSuccess(w, r, result)
 }
 
 // Quota holds quota information.
@@ -72,11 +120,6 @@ type Users struct {
 	TwoFactorAuthEnabled bool `json:"two_factor_auth_enabled" xml:"two_factor_auth_enabled"`
 }
 
-// Groups holds group data.
-type Groups struct {
-	Groups []string `json:"groups" xml:"groups>element"`
-}
-
 // GetUsers handles GET requests on /cloud/users
 // Only allow self-read currently. TODO: List Users and Get on other users (both require
 // administrative privileges).
@@ -104,50 +147,103 @@ func (h *Handler) GetUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var total, used uint64 = 2, 1
+	var total, used uint64
 	var relative float32
+	definition := "none"
 	// lightweight and federated accounts don't have access to their storage space
 	if u.Id.Type != userpb.UserType_USER_TYPE_LIGHTWEIGHT && u.Id.Type != userpb.UserType_USER_TYPE_FEDERATED {
-		getHomeRes, err := gc.GetHome(ctx, &provider.GetHomeRequest{})
+		var status *rpc.Status
+		total, used, status, err = h.getQuota(ctx, gc, u)
 		if err != nil {
-			sublog.Error().Err(err).Msg("error calling GetHome")
+			sublog.Error().Err(err).Msg("error getting quota")
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		if getHomeRes.Status.Code != rpc.Code_CODE_OK {
-			ocdav.HandleErrorStatus(sublog, w, getHomeRes.Status)
+		if status.Code != rpc.Code_CODE_OK {
+			ocdav.HandleErrorStatus(sublog, w, status)
 			return
 		}
-
-		getQuotaRes, err := gc.GetQuota(ctx, &gateway.GetQuotaRequest{Ref: &provider.Reference{Path: getHomeRes.Path}})
-		if err != nil {
-			sublog.Error().Err(err).Msg("error calling GetQuota")
-			w.WriteHeader(http.StatusInternalServerError)
-			return
+		definition = "default"
+		if total > 0 {
+			relative = float32(float64(used)/float64(total)) * 100
 		}
+	}
 
-		if getQuotaRes.Status.Code != rpc.Code_CODE_OK {
-			ocdav.HandleErrorStatus(sublog, w, getQuotaRes.Status)
-			return
-		}
-		total = getQuotaRes.TotalBytes
-		used = getQuotaRes.UsedBytes
-		relative = float32(float64(used)/float64(total)) * 100
+	var free uint64
+	if total > used {
+		free = total - used
 	}
 
 	response.WriteOCSSuccess(w, r, &Users{
 		// ocs can only return the home storage quota
 		Quota: &Quota{
-			Free: int64(total - used),
+			Free: int64(free),
 			Used: int64(used),
 			// TODO support negative values or flags for the quota to carry special meaning: -1 = uncalculated, -2 = unknown, -3 = unlimited
 			// for now we can only report total and used
 			Total:      int64(total),
 			Relative:   relative,
-			Definition: "default",
+			Definition: definition,
 		},
 		DisplayName: u.DisplayName,
 		Email:       u.Mail,
 		UserType:    conversions.UserTypeString(u.Id.Type),
 	})
 }
+
+// getQuota sums up the quota of the personal spaces owned by the user. When the
+// storage providers do not expose any personal space the quota of the home is used.
+func (h *Handler) getQuota(ctx context.Context, gc gateway.GatewayAPIClient, u *userpb.User) (total, used uint64, st *rpc.Status, err error) {
+	spacesRes, err := gc.ListStorageSpaces(ctx, &provider.ListStorageSpacesRequest{
+		Filters: []*provider.ListStorageSpacesRequest_Filter{
+			{
+				Type: provider.ListStorageSpacesRequest_Filter_TYPE_SPACE_TYPE,
+				Term: &provider.ListStorageSpacesRequest_Filter_SpaceType{SpaceType: "personal"},
+			},
+			{
+				Type: provider.ListStorageSpacesRequest_Filter_TYPE_OWNER,
+				Term: &provider.ListStorageSpacesRequest_Filter_Owner{Owner: u.Id},
+			},
+		},
+	})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	var found bool
+	if spacesRes.Status.Code == rpc.Code_CODE_OK {
+		for _, space := range spacesRes.StorageSpaces {
+			// not every provider honours the owner filter
+			if space.Root == nil || space.Owner == nil || !utils.UserEqual(space.Owner.Id, u.Id) {
+				continue
+			}
+			quotaRes, err := gc.GetQuota(ctx, &gateway.GetQuotaRequest{Ref: &provider.Reference{ResourceId: space.Root, Path: "."}})
+			if err != nil {
+				return 0, 0, nil, err
+			}
+			if quotaRes.Status.Code != rpc.Code_CODE_OK {
+				return 0, 0, quotaRes.Status, nil
+			}
+			total += quotaRes.TotalBytes
+			used += quotaRes.UsedBytes
+			found = true
+		}
+	}
+	if found {
+		return total, used, status.NewOK(ctx), nil
+	}
+
+	getHomeRes, err := gc.GetHome(ctx, &provider.GetHomeRequest{})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if getHomeRes.Status.Code != rpc.Code_CODE_OK {
+		return 0, 0, getHomeRes.Status, nil
+	}
+
+	getQuotaRes, err := gc.GetQuota(ctx, &gateway.GetQuotaRequest{Ref: &provider.Reference{Path: getHomeRes.Path}})
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return getQuotaRes.TotalBytes, getQuotaRes.UsedBytes, getQuotaRes.Status, nil
+}
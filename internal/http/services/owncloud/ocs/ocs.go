@@ -27,11 +27,13 @@ import (
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/apps/sharing/sharees"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/apps/sharing/shares"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/cloud/capabilities"
+	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/cloud/groups"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/cloud/user"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/cloud/users"
 	configHandler "github.com/cs3org/reva/internal/http/services/owncloud/ocs/handlers/config"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/response"
 	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/maintenance"
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/go-chi/chi/v5"
 	"github.com/mitchellh/mapstructure"
@@ -90,11 +92,13 @@ func (s *svc) routerInit() error {
 	capabilitiesHandler := new(capabilities.Handler)
 	userHandler := new(user.Handler)
 	usersHandler := new(users.Handler)
+	groupsHandler := new(groups.Handler)
 	configHandler := new(configHandler.Handler)
 	sharesHandler := new(shares.Handler)
 	shareesHandler := new(sharees.Handler)
 	capabilitiesHandler.Init(s.c)
 	usersHandler.Init(s.c)
+	groupsHandler.Init(s.c)
 	userHandler.Init(s.c)
 	configHandler.Init(s.c)
 	sharesHandler.Init(s.c)
@@ -139,13 +143,17 @@ func (s *svc) routerInit() error {
 				r.Get("/{userid}", usersHandler.GetUsers)
 				r.Get("/{userid}/groups", usersHandler.GetGroups)
 			})
+			r.Route("/groups", func(r chi.Router) {
+				r.Get("/", groupsHandler.ListGroups)
+				r.Get("/{groupid}", groupsHandler.GetGroupMembers)
+			})
 		})
 	})
 	return nil
 }
 
 func (s *svc) Handler() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	return maintenance.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log := appctx.GetLogger(r.Context())
 		log.Debug().Str("path", r.URL.Path).Msg("ocs routing")
 
@@ -155,5 +163,5 @@ func (s *svc) Handler() http.Handler {
 		// unset raw path, otherwise chi uses it to route and then fails to match percent encoded path segments
 		r.URL.RawPath = ""
 		s.router.ServeHTTP(w, r)
-	})
+	}))
 }
@@ -26,6 +26,7 @@ import (
 	"net/http"
 	"reflect"
 
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/go-chi/chi/v5"
 )
@@ -127,6 +128,9 @@ var MetaNotFound = Meta{Status: "error", StatusCode: 998, Message: "Not Found"}
 // MetaUnknownError is used for unknown errors.
 var MetaUnknownError = Meta{Status: "error", StatusCode: 999, Message: "Unknown Error"}
 
+// MetaServiceUnavailable is returned during a maintenance.
+var MetaServiceUnavailable = Meta{Status: "error", StatusCode: 503, Message: "Service Unavailable"}
+
 // WriteOCSSuccess handles writing successful ocs response data.
 func WriteOCSSuccess(w http.ResponseWriter, r *http.Request, d interface{}) {
 	WriteOCSData(w, r, MetaOK, d, nil)
@@ -137,6 +141,16 @@ func WriteOCSError(w http.ResponseWriter, r *http.Request, c int, m string, err
 	WriteOCSData(w, r, Meta{Status: "error", StatusCode: c, Message: m}, nil, err)
 }
 
+// WriteOCSMaintenance writes the error of a request rejected during a maintenance,
+// with its message. It returns false for any other status.
+func WriteOCSMaintenance(w http.ResponseWriter, r *http.Request, s *rpc.Status) bool {
+	if s.GetCode() != rpc.Code_CODE_UNAVAILABLE {
+		return false
+	}
+	WriteOCSError(w, r, MetaServiceUnavailable.StatusCode, s.Message, nil)
+	return true
+}
+
 // WriteOCSData handles writing ocs data in json and xml.
 func WriteOCSData(w http.ResponseWriter, r *http.Request, m Meta, d interface{}, err error) {
 	WriteOCSResponse(w, r, Response{
@@ -0,0 +1,157 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sessions
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	global.Register("sessions", New)
+}
+
+type config struct {
+	Prefix     string `mapstructure:"prefix" docs:"sessions;The prefix to be used for this HTTP service"`
+	GatewaySvc string `mapstructure:"gatewaysvc" docs:";The gateway recording the sessions."`
+}
+
+func (c *config) init() {
+	if c.Prefix == "" {
+		c.Prefix = "sessions"
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+type svc struct {
+	conf *config
+}
+
+// New returns a new sessions service, to list and revoke the tokens minted for the users.
+// The sessions are managed by the gateway, this service only exposes them over http.
+func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, err
+	}
+	conf.init()
+	return &svc{conf: conf}, nil
+}
+
+// Close performs cleanup.
+func (s *svc) Close() error {
+	return nil
+}
+
+func (s *svc) Prefix() string {
+	return s.conf.Prefix
+}
+
+func (s *svc) Unprotected() []string {
+	return []string{}
+}
+
+// Handler serves
+//
+//	GET    /      the sessions of the user
+//	DELETE /      revoke all the sessions of the user
+//	DELETE /<id>  revoke a single session
+//
+// Users with the permission to manage sessions may pass the user and idp query
+// parameters to manage the sessions of other users.
+func (s *svc) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := appctx.GetLogger(ctx)
+
+		client, err := pool.GetSessionsClient(pool.Endpoint(s.conf.GatewaySvc))
+		if err != nil {
+			log.Error().Err(err).Msg("error getting sessions client")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var subject *userpb.UserId
+		if q := r.URL.Query(); q.Get("user") != "" {
+			subject = &userpb.UserId{OpaqueId: q.Get("user"), Idp: q.Get("idp")}
+		}
+
+		id := path.Base(path.Clean("/" + r.URL.Path))
+		if id == "/" {
+			id = ""
+		}
+
+		var st *rpc.Status
+		switch {
+		case r.Method == http.MethodGet && id == "":
+			res, err := client.ListSessions(ctx, &session.ListSessionsRequest{User: subject})
+			if err != nil {
+				log.Error().Err(err).Msg("error listing sessions")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if res.Status.GetCode() == rpc.Code_CODE_OK {
+				w.Header().Set("Content-Type", "application/json")
+				if err := json.NewEncoder(w).Encode(res.Sessions); err != nil {
+					log.Err(err).Msg("error writing response")
+				}
+				return
+			}
+			st = res.Status
+		case r.Method == http.MethodDelete:
+			res, err := client.RevokeSessions(ctx, &session.RevokeSessionsRequest{ID: id, User: subject})
+			if err != nil {
+				log.Error().Err(err).Msg("error revoking sessions")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			st = res.Status
+		default:
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch st.GetCode() {
+		case rpc.Code_CODE_OK:
+			w.WriteHeader(http.StatusNoContent)
+		case rpc.Code_CODE_NOT_FOUND:
+			http.Error(w, st.Message, http.StatusNotFound)
+		case rpc.Code_CODE_PERMISSION_DENIED:
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		case rpc.Code_CODE_UNAUTHENTICATED:
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		case rpc.Code_CODE_UNIMPLEMENTED:
+			http.Error(w, st.Message, http.StatusNotImplemented)
+		default:
+			log.Error().Str("status", st.GetCode().String()).Str("message", st.GetMessage()).Msg("error managing sessions")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+}
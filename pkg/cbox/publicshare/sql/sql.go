@@ -86,6 +86,11 @@ func (c *config) init() {
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
 
+// Ready tells whether the database is reachable.
+func (m *manager) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
 func (m *manager) startJanitorRun() {
 	if !m.c.EnableExpiredSharesCleanup {
 		return
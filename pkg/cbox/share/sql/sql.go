@@ -102,7 +102,12 @@ func parseConfig(m map[string]interface{}) (*config, error) {
 	return c, nil
 }
 
-func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant) (*collaboration.Share, error) {
+// Ready tells whether the database is reachable.
+func (m *mgr) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant, expiration *typespb.Timestamp) (*collaboration.Share, error) {
 	user := ctxpkg.ContextMustGetUser(ctx)
 
 	// do not allow share to myself or the owner if share is for a user
@@ -145,6 +150,10 @@ func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collabora
 
 	stmtString := "insert into oc_share set share_type=?,uid_owner=?,uid_initiator=?,item_type=?,fileid_prefix=?,item_source=?,file_source=?,permissions=?,stime=?,share_with=?,file_target=?"
 	stmtValues := []interface{}{shareType, conversions.FormatUserID(md.Owner), conversions.FormatUserID(user.Id), itemType, prefix, itemSource, fileSource, permissions, now, shareWith, targetPath}
+	if expiration != nil {
+		stmtString += ",expiration=?"
+		stmtValues = append(stmtValues, utils.TSToTime(expiration))
+	}
 
 	stmt, err := m.db.Prepare(stmtString)
 	if err != nil {
@@ -289,6 +298,102 @@ func (m *mgr) UpdateShare(ctx context.Context, ref *collaboration.ShareReference
 	return m.GetShare(ctx, ref)
 }
 
+func (m *mgr) UpdateShareExpiration(ctx context.Context, ref *collaboration.ShareReference, expiration *typespb.Timestamp) (*collaboration.Share, error) {
+	uid := conversions.FormatUserID(ctxpkg.ContextMustGetUser(ctx).Id)
+
+	var e interface{}
+	if expiration != nil {
+		e = utils.TSToTime(expiration)
+	}
+
+	var query string
+	params := []interface{}{}
+	switch {
+	case ref.GetId() != nil:
+		query = "update oc_share set expiration=?,stime=? where id=? AND (uid_owner=? or uid_initiator=?)"
+		params = append(params, e, time.Now().Unix(), ref.GetId().OpaqueId, uid, uid)
+	case ref.GetKey() != nil:
+		key := ref.GetKey()
+		shareType, shareWith := conversions.FormatGrantee(key.Grantee)
+		owner := conversions.FormatUserID(key.Owner)
+		query = "update oc_share set expiration=?,stime=? where (uid_owner=? or uid_initiator=?) AND fileid_prefix=? AND item_source=? AND share_type=? AND share_with=? AND (uid_owner=? or uid_initiator=?)"
+		params = append(params, e, time.Now().Unix(), owner, owner, key.ResourceId.StorageId, key.ResourceId.OpaqueId, shareType, shareWith, uid, uid)
+	default:
+		return nil, errtypes.NotFound(ref.String())
+	}
+
+	stmt, err := m.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = stmt.Exec(params...); err != nil {
+		return nil, err
+	}
+
+	return m.GetShare(ctx, ref)
+}
+
+func (m *mgr) GetExpirations(ctx context.Context, ids []*collaboration.ShareId) (map[string]*typespb.Timestamp, error) {
+	expirations := make(map[string]*typespb.Timestamp)
+	if len(ids) == 0 {
+		return expirations, nil
+	}
+
+	params := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		params = append(params, id.GetOpaqueId())
+	}
+	query := "select id, expiration FROM oc_share WHERE expiration IS NOT NULL AND id in (?" + strings.Repeat(",?", len(ids)-1) + ")"
+	rows, err := m.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, e string
+		if err := rows.Scan(&id, &e); err != nil {
+			return nil, err
+		}
+		t, err := time.Parse("2006-01-02 15:04:05", e)
+		if err != nil {
+			return nil, err
+		}
+		expirations[id] = &typespb.Timestamp{Seconds: uint64(t.Unix())}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return expirations, nil
+}
+
+func (m *mgr) ListExpiredShares(ctx context.Context, before time.Time) ([]*collaboration.Share, error) {
+	query := `select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with,
+				coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type,
+			  	id, stime, permissions, share_type
+			  FROM oc_share WHERE (orphan = 0 or orphan IS NULL) AND (share_type=? OR share_type=?) AND expiration IS NOT NULL AND expiration < ?`
+
+	rows, err := m.db.Query(query, shareTypeUser, shareTypeGroup, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var s conversions.DBShare
+	shares := []*collaboration.Share{}
+	for rows.Next() {
+		if err := rows.Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.ID, &s.STime, &s.Permissions, &s.ShareType); err != nil {
+			continue
+		}
+		shares = append(shares, conversions.ConvertToCS3Share(s))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
 func (m *mgr) ListShares(ctx context.Context, filters []*collaboration.Filter) ([]*collaboration.Share, error) {
 	query := `select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with,
 				coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type,
@@ -358,6 +463,8 @@ func (m *mgr) ListReceivedShares(ctx context.Context, filters []*collaboration.F
 	} else {
 		query += " AND (share_with=? AND share_type = 0)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now())
 
 	groupedFilters := share.GroupFiltersByType(filters)
 	filterQuery, filterParams, err := translateFilters(groupedFilters)
@@ -411,6 +518,8 @@ func (m *mgr) getReceivedByID(ctx context.Context, id *collaboration.ShareId) (*
 	} else {
 		query += " AND (share_with=?  AND share_type = 0)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now())
 	if err := m.db.QueryRow(query, params...).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.STime, &s.Permissions, &s.ShareType, &s.State); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errtypes.NotFound(id.OpaqueId)
@@ -441,6 +550,8 @@ func (m *mgr) getReceivedByKey(ctx context.Context, key *collaboration.ShareKey)
 	} else {
 		query += " AND (share_with=? AND share_type = 0)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now())
 
 	if err := m.db.QueryRow(query, params...).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.ID, &s.STime, &s.Permissions, &s.ShareType, &s.State); err != nil {
 		if err == sql.ErrNoRows {
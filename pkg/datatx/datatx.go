@@ -21,7 +21,10 @@ package datatx
 import (
 	"context"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/utils"
 )
 
 // Manager the interface any transfer driver should implement.
@@ -36,4 +39,89 @@ type Manager interface {
 	// RetryTransfer retries the transfer and returns a TxInfo object and error if any.
 	// Note that tokens must still be valid.
 	RetryTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error)
+	// ListTransfers returns the transfers created by the user in the context matching the filters.
+	// Filters other than the status and the transfer id ones are ignored.
+	ListTransfers(ctx context.Context, filters []*datatx.ListTransfersRequest_Filter) ([]*datatx.TxInfo, error)
+	// GetTransfersProgress returns the progress of the transfers, by transfer id.
+	// Unknown transfers are left out.
+	GetTransfersProgress(ctx context.Context, transferIDs []string) (map[string]*Progress, error)
+}
+
+// Progress is the progress of a transfer. As TxInfo has no opaque, it is reported
+// in the opaque of the responses of the datatx service, under the "progress" key.
+type Progress struct {
+	BytesTransferred int64   `json:"bytes_transferred"`
+	BytesTotal       int64   `json:"bytes_total"`
+	Percentage       float64 `json:"percentage"`
+	// ETA is the estimated number of seconds until the end of the transfer, -1 if unknown.
+	ETA int64 `json:"eta"`
+}
+
+// NewProgress returns the progress of a transfer, estimating the ETA
+// from the current rate in bytes per second.
+func NewProgress(transferred, total int64, rate float64) *Progress {
+	p := &Progress{
+		BytesTransferred: transferred,
+		BytesTotal:       total,
+		ETA:              -1,
+	}
+	if total > 0 {
+		p.Percentage = float64(transferred) * 100 / float64(total)
+	}
+	if rate > 0 && total >= transferred {
+		p.ETA = int64(float64(total-transferred) / rate)
+	}
+	return p
+}
+
+// MatchesFilters tells whether the transfer matches the status and transfer id filters.
+// Filters of the same type are ORed, filters of different types are ANDed.
+func MatchesFilters(info *datatx.TxInfo, filters []*datatx.ListTransfersRequest_Filter) bool {
+	matched := map[datatx.ListTransfersRequest_Filter_Type]bool{}
+	for _, f := range filters {
+		switch f.Type {
+		case datatx.ListTransfersRequest_Filter_TYPE_STATUS:
+			matched[f.Type] = matched[f.Type] || f.GetStatus() == info.Status
+		case datatx.ListTransfersRequest_Filter_TYPE_TX_ID:
+			matched[f.Type] = matched[f.Type] || f.GetTxId().GetOpaqueId() == info.GetId().GetOpaqueId()
+		}
+	}
+	for _, ok := range matched {
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// EmitTransferEvent publishes a TransferCompleted or a TransferFailed event for a
+// transfer which reached an end status. Nothing is published for the other statuses,
+// such as the cancelled transfers, or without a publisher.
+func EmitTransferEvent(publisher events.Publisher, info *datatx.TxInfo, creator *userpb.UserId, reason error) error {
+	if publisher == nil {
+		return nil
+	}
+
+	switch info.Status {
+	case datatx.Status_STATUS_TRANSFER_COMPLETE:
+		return events.Publish(publisher, events.TransferCompleted{
+			Executant: creator,
+			ID:        info.Id,
+			Timestamp: utils.TSNow(),
+		})
+	case datatx.Status_STATUS_INVALID, datatx.Status_STATUS_DESTINATION_NOT_FOUND,
+		datatx.Status_STATUS_TRANSFER_FAILED, datatx.Status_STATUS_TRANSFER_EXPIRED:
+		ev := events.TransferFailed{
+			Executant: creator,
+			ID:        info.Id,
+			Status:    info.Status,
+			Timestamp: utils.TSNow(),
+		}
+		if reason != nil {
+			ev.Error = reason.Error()
+		}
+		return events.Publish(publisher, ev)
+	default:
+		return nil
+	}
 }
@@ -20,6 +20,7 @@ package loader
 
 import (
 	// Load datatx drivers.
+	_ "github.com/cs3org/reva/pkg/datatx/manager/native"
 	_ "github.com/cs3org/reva/pkg/datatx/manager/rclone"
 	// Add your own here.
 )
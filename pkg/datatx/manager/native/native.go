@@ -0,0 +1,680 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	txdriver "github.com/cs3org/reva/pkg/datatx"
+	registry "github.com/cs3org/reva/pkg/datatx/manager/registry"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/logger"
+	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	registry.Register("native", New)
+}
+
+type config struct {
+	DBEngine   string `mapstructure:"db_engine" docs:"sqlite3;The database storing the transfers, either sqlite3 or mysql."`
+	DBFile     string `mapstructure:"db_file" docs:"/var/tmp/reva/datatx-native.db;The file of the sqlite3 database."`
+	DBUsername string `mapstructure:"db_username"`
+	DBPassword string `mapstructure:"db_password"`
+	DBHost     string `mapstructure:"db_host"`
+	DBPort     int    `mapstructure:"db_port"`
+	DBName     string `mapstructure:"db_name"`
+	AuthHeader string `mapstructure:"auth_header" docs:"x-access-token;How the tokens are sent to the endpoints, either x-access-token or bearer."`
+	Streams    int    `mapstructure:"streams" docs:"4;The number of files transferred in parallel by a transfer."`
+	Bandwidth  int64  `mapstructure:"bandwidth" docs:"0;The bandwidth in bytes per second shared by all the transfers. 0 means no limit."`
+	Retries    int    `mapstructure:"retries" docs:"3;The number of times the transfer of a file is attempted."`
+	Insecure   bool   `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
+}
+
+func (c *config) init() {
+	if c.DBEngine == "" {
+		c.DBEngine = "sqlite3"
+	}
+	if c.DBFile == "" {
+		c.DBFile = "/var/tmp/reva/datatx-native.db"
+	}
+	if c.AuthHeader == "" {
+		c.AuthHeader = "x-access-token"
+	}
+	if c.Streams == 0 {
+		c.Streams = 4
+	}
+	if c.Retries == 0 {
+		c.Retries = 3
+	}
+}
+
+type manager struct {
+	conf      *config
+	client    *http.Client
+	store     *store
+	limiter   *rate.Limiter
+	publisher events.Publisher
+	log       *zerolog.Logger
+
+	mu      sync.Mutex
+	running map[string]*job
+}
+
+// txEndStatuses are the statuses of the transfers which are not running.
+var txEndStatuses = map[datatx.Status]bool{
+	datatx.Status_STATUS_INVALID:                true,
+	datatx.Status_STATUS_DESTINATION_NOT_FOUND:  true,
+	datatx.Status_STATUS_TRANSFER_COMPLETE:      true,
+	datatx.Status_STATUS_TRANSFER_FAILED:        true,
+	datatx.Status_STATUS_TRANSFER_CANCELLED:     true,
+	datatx.Status_STATUS_TRANSFER_CANCEL_FAILED: true,
+	datatx.Status_STATUS_TRANSFER_EXPIRED:       true,
+}
+
+// New returns a datatx driver which transfers the data itself between the webdav
+// endpoints of the source and the destination. The transfers which were running
+// when reva stopped are resumed.
+func New(m map[string]interface{}, publisher events.Publisher) (txdriver.Manager, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "native: error decoding conf")
+	}
+	c.init()
+	if c.Streams < 0 || c.Retries < 0 {
+		return nil, errtypes.BadRequest("native: streams and retries must be positive")
+	}
+
+	db, err := openDB(c)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newStore(context.Background(), c.DBEngine, db)
+	if err != nil {
+		return nil, err
+	}
+
+	l := logger.New().With().Str("pkg", "datatx").Str("driver", "native").Logger()
+	mgr := &manager{
+		conf:      c,
+		client:    rhttp.GetHTTPClient(rhttp.Insecure(c.Insecure)),
+		store:     s,
+		limiter:   rate.NewLimiter(rate.Inf, 0),
+		publisher: publisher,
+		log:       &l,
+		running:   map[string]*job{},
+	}
+	if c.Bandwidth > 0 {
+		mgr.limiter = rate.NewLimiter(rate.Limit(c.Bandwidth), int(c.Bandwidth))
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	for _, t := range s.Transfers {
+		if !txEndStatuses[t.Status] {
+			mgr.log.Info().Str("transfer", t.ID).Msg("resuming transfer")
+			mgr.start(t)
+		}
+	}
+	return mgr, nil
+}
+
+func txInfo(t *transfer) *datatx.TxInfo {
+	return &datatx.TxInfo{
+		Id:          &datatx.TxId{OpaqueId: t.ID},
+		Status:      t.Status,
+		Creator:     t.Creator,
+		Ctime:       &typespb.Timestamp{Seconds: t.Ctime},
+		Description: t.Error,
+	}
+}
+
+// parseTargetURI parses a target URI of the form scheme://token@host:port/path?name={path}.
+func parseTargetURI(targetURI string) (endpoint, error) {
+	if targetURI == "" {
+		return endpoint{}, errtypes.BadRequest("native: ref target is an empty uri")
+	}
+	u, err := url.Parse(targetURI)
+	if err != nil {
+		return endpoint{}, errors.Wrap(err, "native: error parsing target uri: "+targetURI)
+	}
+	name := u.Query().Get("name")
+	if name == "" {
+		return endpoint{}, errtypes.BadRequest("native: target uri without name: " + targetURI)
+	}
+	ep := endpoint{Path: name}
+	if u.User != nil {
+		ep.Token = u.User.Username()
+	}
+	u.User, u.RawQuery = nil, ""
+	ep.Endpoint = u.String()
+	return ep, nil
+}
+
+// CreateTransfer creates a transfer job and returns a TxInfo object that includes a unique transfer id.
+func (m *manager) CreateTransfer(ctx context.Context, srcTargetURI string, dstTargetURI string) (*datatx.TxInfo, error) {
+	src, err := parseTargetURI(srcTargetURI)
+	if err != nil {
+		return nil, err
+	}
+	dest, err := parseTargetURI(dstTargetURI)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &transfer{
+		ID:     uuid.New().String(),
+		Status: datatx.Status_STATUS_TRANSFER_NEW,
+		Ctime:  uint64(time.Now().Unix()),
+		Src:    src,
+		Dest:   dest,
+	}
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		t.Creator = u.Id
+	}
+
+	if err := m.store.insert(ctx, t); err != nil {
+		return nil, err
+	}
+	m.store.Lock()
+	defer m.store.Unlock()
+	m.store.Transfers[t.ID] = t
+	m.start(t)
+	return txInfo(t), nil
+}
+
+// GetTransferStatus returns the status of the transfer with the specified id.
+func (m *manager) GetTransferStatus(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+	t, err := m.store.get(transferID)
+	if err != nil {
+		return nil, err
+	}
+	return txInfo(t), nil
+}
+
+// CancelTransfer cancels the transfer with the specified id.
+func (m *manager) CancelTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+	t, err := m.store.get(transferID)
+	if err != nil {
+		return nil, err
+	}
+	if txEndStatuses[t.Status] {
+		return txInfo(t), errtypes.BadRequest("native: transfer already in end state")
+	}
+
+	m.mu.Lock()
+	if j, ok := m.running[t.ID]; ok {
+		j.cancel()
+	}
+	m.mu.Unlock()
+
+	t.Status = datatx.Status_STATUS_TRANSFER_CANCELLED
+	if err := m.store.saveStatus(ctx, t); err != nil {
+		return txInfo(t), err
+	}
+	return txInfo(t), nil
+}
+
+// RetryTransfer restarts a transfer in an end state, skipping the files already transferred.
+// Note that tokens must still be valid.
+func (m *manager) RetryTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+	t, err := m.store.get(transferID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	_, running := m.running[t.ID]
+	m.mu.Unlock()
+	if running || !txEndStatuses[t.Status] {
+		// a cancelled transfer runs until its current requests are interrupted
+		return txInfo(t), errtypes.BadRequest("native: transfer still running, unable to restart")
+	}
+
+	t.Status, t.Error = datatx.Status_STATUS_TRANSFER_NEW, ""
+	if err := m.store.saveStatus(ctx, t); err != nil {
+		return txInfo(t), err
+	}
+	m.start(t)
+	return txInfo(t), nil
+}
+
+// ListTransfers returns the transfers created by the user in the context, or all
+// the transfers without a user, matching the status and transfer id filters.
+func (m *manager) ListTransfers(ctx context.Context, filters []*datatx.ListTransfersRequest_Filter) ([]*datatx.TxInfo, error) {
+	u, hasUser := ctxpkg.ContextGetUser(ctx)
+
+	m.store.Lock()
+	defer m.store.Unlock()
+	var infos []*datatx.TxInfo
+	for _, t := range m.store.Transfers {
+		if hasUser && !utils.UserEqual(u.Id, t.Creator) {
+			continue
+		}
+		if info := txInfo(t); txdriver.MatchesFilters(info, filters) {
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Ctime.Seconds != infos[j].Ctime.Seconds {
+			return infos[i].Ctime.Seconds < infos[j].Ctime.Seconds
+		}
+		return infos[i].Id.OpaqueId < infos[j].Id.OpaqueId
+	})
+	return infos, nil
+}
+
+// GetTransfersProgress returns the progress of the transfers with the specified ids,
+// including the files being transferred. The ETA is known while a transfer runs.
+func (m *manager) GetTransfersProgress(ctx context.Context, transferIDs []string) (map[string]*txdriver.Progress, error) {
+	m.store.Lock()
+	defer m.store.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	progress := make(map[string]*txdriver.Progress, len(transferIDs))
+	for _, id := range transferIDs {
+		t, err := m.store.get(id)
+		if err != nil {
+			continue
+		}
+
+		transferred, speed := t.Transferred, 0.0
+		if j, ok := m.running[t.ID]; ok {
+			transferred += j.inflight.Load()
+			if elapsed := time.Since(j.started).Seconds(); elapsed > 0 {
+				speed = float64(j.moved.Load()) / elapsed
+			}
+		}
+		progress[id] = txdriver.NewProgress(transferred, t.Size, speed)
+	}
+	return progress, nil
+}
+
+// start runs the transfer in the background. It must be called with the store lock held.
+func (m *manager) start(t *transfer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &job{
+		m:       m,
+		t:       t,
+		src:     m.webdavClient(t.Src),
+		dest:    m.webdavClient(t.Dest),
+		log:     m.log.With().Str("transfer", t.ID).Logger(),
+		cancel:  cancel,
+		started: time.Now(),
+	}
+	m.mu.Lock()
+	m.running[t.ID] = job
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		status, err := job.run(ctx)
+
+		// the transfer stops running before reaching its end status,
+		// so that it can be retried as soon as the status is visible
+		m.mu.Lock()
+		delete(m.running, t.ID)
+		m.mu.Unlock()
+		info := job.setStatus(status, err)
+		if err := txdriver.EmitTransferEvent(m.publisher, info, t.Creator, err); err != nil {
+			job.log.Error().Err(err).Msg("error publishing transfer event")
+		}
+	}()
+}
+
+func (m *manager) webdavClient(ep endpoint) *webdavClient {
+	base, err := url.Parse(ep.Endpoint)
+	if err != nil {
+		// the endpoint was parsed when the transfer was created
+		base = &url.URL{}
+	}
+	return &webdavClient{
+		client:     m.client,
+		base:       base,
+		token:      ep.Token,
+		authHeader: m.conf.AuthHeader,
+	}
+}
+
+// job is a running transfer. inflight counts the bytes of the files being
+// transferred, moved all the bytes read from the source since the job started.
+type job struct {
+	m         *manager
+	t         *transfer
+	src, dest *webdavClient
+	log       zerolog.Logger
+	cancel    context.CancelFunc
+	started   time.Time
+	inflight  atomic.Int64
+	moved     atomic.Int64
+}
+
+// file is a file to transfer, at the given path relative to the source and the destination.
+type file struct {
+	rel  string
+	size int64
+	etag string
+}
+
+// run transfers the data, returning the end status of the transfer.
+func (j *job) run(ctx context.Context) (datatx.Status, error) {
+	j.setStatus(datatx.Status_STATUS_TRANSFER_IN_PROGRESS, nil)
+
+	err := j.transfer(ctx)
+	switch {
+	case ctx.Err() != nil:
+		j.log.Info().Msg("transfer cancelled")
+		return datatx.Status_STATUS_TRANSFER_CANCELLED, nil
+	case err != nil:
+		j.log.Error().Err(err).Msg("transfer failed")
+		return datatx.Status_STATUS_TRANSFER_FAILED, err
+	default:
+		j.log.Info().Msg("transfer complete")
+		return datatx.Status_STATUS_TRANSFER_COMPLETE, nil
+	}
+}
+
+// setStatus stores the status of the transfer, unless it was cancelled,
+// and returns the resulting transfer info.
+func (j *job) setStatus(status datatx.Status, err error) *datatx.TxInfo {
+	j.m.store.Lock()
+	defer j.m.store.Unlock()
+	if j.t.Status == datatx.Status_STATUS_TRANSFER_CANCELLED {
+		return txInfo(j.t)
+	}
+	j.t.Status = status
+	if err != nil {
+		j.t.Error = err.Error()
+	}
+	if err := j.m.store.saveStatus(context.Background(), j.t); err != nil {
+		j.log.Error().Err(err).Msg("error saving transfer")
+	}
+	return txInfo(j.t)
+}
+
+// transfer copies the source into the destination, a file into a file and
+// the content of a folder into a folder.
+func (j *job) transfer(ctx context.Context) error {
+	root, err := j.src.propfind(ctx, j.t.Src.Path, 0)
+	if err != nil {
+		return errors.Wrap(err, "error stating the source")
+	}
+	if !root[0].isDir {
+		return j.transferFiles(ctx, []*file{{rel: "", size: root[0].size, etag: root[0].etag}})
+	}
+
+	var files []*file
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		if err := j.dest.mkcol(ctx, path.Join(j.t.Dest.Path, rel)); err != nil {
+			return errors.Wrap(err, "error creating destination folder")
+		}
+		resources, err := j.src.propfind(ctx, path.Join(j.t.Src.Path, rel), 1)
+		if err != nil {
+			return errors.Wrap(err, "error listing the source")
+		}
+		for _, r := range resources[1:] {
+			childRel := path.Join(rel, path.Base(r.path))
+			if r.isDir {
+				if err := walk(childRel); err != nil {
+					return err
+				}
+				continue
+			}
+			files = append(files, &file{rel: childRel, size: r.size, etag: r.etag})
+		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return err
+	}
+	return j.transferFiles(ctx, files)
+}
+
+// transferFiles transfers the files not yet transferred on as many streams as configured.
+func (j *job) transferFiles(ctx context.Context, files []*file) error {
+	todo, err := j.plan(ctx, files)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan *file)
+	errs := make(chan error, j.m.conf.Streams)
+	var wg sync.WaitGroup
+	for i := 0; i < j.m.conf.Streams; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range queue {
+				if err := j.transferFile(ctx, f); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+loop:
+	for _, f := range todo {
+		select {
+		case queue <- f:
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// plan records the size of the transfer and returns the files not yet transferred.
+func (j *job) plan(ctx context.Context, files []*file) ([]*file, error) {
+	done, err := j.m.store.done(ctx, j.t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	j.m.store.Lock()
+	defer j.m.store.Unlock()
+	var todo []*file
+	j.t.Size, j.t.Transferred = 0, 0
+	for _, f := range files {
+		j.t.Size += f.size
+		if etag, ok := done[f.rel]; ok && etag == f.etag {
+			j.t.Transferred += f.size
+			continue
+		}
+		todo = append(todo, f)
+	}
+	return todo, j.m.store.saveSize(ctx, j.t)
+}
+
+// transferFile copies a file, retrying on errors.
+func (j *job) transferFile(ctx context.Context, f *file) error {
+	var err error
+	for attempt := 1; attempt <= j.m.conf.Retries; attempt++ {
+		if err = j.copyFile(ctx, f); err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		j.log.Warn().Err(err).Str("file", f.rel).Int("attempt", attempt).Msg("error transferring file")
+	}
+	if err != nil {
+		return errors.Wrap(err, "error transferring "+f.rel)
+	}
+
+	j.m.store.Lock()
+	j.t.Transferred += f.size
+	j.m.store.Unlock()
+	return j.m.store.saveFile(ctx, j.t.ID, f)
+}
+
+// copyFile streams a file from the source to the destination, verifying its checksum
+// when the source announces one.
+func (j *job) copyFile(ctx context.Context, f *file) error {
+	content, checksum, err := j.src.get(ctx, path.Join(j.t.Src.Path, f.rel))
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	counter := &countingReader{r: &limitedReader{ctx: ctx, r: content, limiter: j.m.limiter}, j: j}
+	defer func() { j.inflight.Add(-counter.n) }()
+
+	var r io.Reader = counter
+	var v *verifyingReader
+	if h, expected := checksumHash(checksum); h != nil {
+		v = &verifyingReader{r: counter, h: h, expected: expected, remaining: f.size, name: f.rel}
+		r = v
+	}
+
+	if err := j.dest.put(ctx, path.Join(j.t.Dest.Path, f.rel), r, f.size, checksum); err != nil {
+		// the upload was aborted because of the mismatch
+		if v != nil && v.err != nil {
+			return v.err
+		}
+		return err
+	}
+	return nil
+}
+
+// verifyingReader verifies the checksum of the content while it is streamed to the
+// destination. On a mismatch it fails before handing out the last bytes, so that
+// the upload is aborted instead of storing the corrupt copy.
+type verifyingReader struct {
+	r         io.Reader
+	h         hash.Hash
+	expected  string
+	remaining int64
+	name      string
+	err       error
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	v.remaining -= int64(n)
+	if err == io.EOF || v.remaining <= 0 {
+		if actual := hex.EncodeToString(v.h.Sum(nil)); !strings.EqualFold(actual, v.expected) {
+			v.err = errtypes.ChecksumMismatch(fmt.Sprintf("%s: expected %s got %s", v.name, v.expected, actual))
+			return 0, v.err
+		}
+	}
+	return n, err
+}
+
+// checksumHash returns the hash to compute for a checksum in the OC-Checksum format,
+// or nil if the type is not supported.
+func checksumHash(checksum string) (hash.Hash, string) {
+	parts := strings.SplitN(checksum, ":", 2)
+	if len(parts) != 2 {
+		return nil, ""
+	}
+	switch strings.ToLower(parts[0]) {
+	case "sha1":
+		return sha1.New(), parts[1]
+	case "md5":
+		return md5.New(), parts[1]
+	case "adler32":
+		return adler32.New(), parts[1]
+	default:
+		return nil, ""
+	}
+}
+
+// countingReader accounts the bytes read in the progress of the job.
+type countingReader struct {
+	r io.Reader
+	j *job
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.j.inflight.Add(int64(n))
+	c.j.moved.Add(int64(n))
+	return n, err
+}
+
+// limitedReader reads at most the bandwidth allowed by the limiter.
+type limitedReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.limiter.Limit() != rate.Inf && len(p) > l.limiter.Burst() {
+		p = p[:l.limiter.Burst()]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.limiter.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
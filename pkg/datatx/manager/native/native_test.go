@@ -0,0 +1,405 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	txdriver "github.com/cs3org/reva/pkg/datatx"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/utils"
+	microevents "go-micro.dev/v4/events"
+	"golang.org/x/net/webdav"
+)
+
+// newWebdavServer serves a webdav endpoint from an in-memory file system,
+// announcing the sha1 of the files it serves unless a checksum is overridden.
+func newWebdavServer(t *testing.T, token string, checksums map[string]string) (*httptest.Server, webdav.FileSystem) {
+	fs := webdav.NewMemFS()
+	h := &webdav.Handler{FileSystem: fs, LockSystem: webdav.NewMemLS()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-access-token") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// like the storages, only store uploads that were received completely
+		if r.Method == http.MethodPut {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if r.Method == http.MethodGet {
+			if c, ok := checksums[r.URL.Path]; ok {
+				w.Header().Set("OC-Checksum", c)
+			} else if data, err := readFile(fs, r.URL.Path); err == nil {
+				sum := sha1.Sum(data)
+				w.Header().Set("OC-Checksum", "SHA1:"+hex.EncodeToString(sum[:]))
+			}
+		}
+		h.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, fs
+}
+
+func readFile(fs webdav.FileSystem, name string) ([]byte, error) {
+	f, err := fs.OpenFile(context.Background(), name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, info.Size())
+	_, err = f.Read(data)
+	return data, err
+}
+
+func writeFiles(t *testing.T, fs webdav.FileSystem, files map[string]string) {
+	for name, content := range files {
+		if dir := path.Dir(name); dir != "/" {
+			if err := fs.Mkdir(context.Background(), dir, 0755); err != nil && !os.IsExist(err) {
+				t.Fatal(err)
+			}
+		}
+		f, err := fs.OpenFile(context.Background(), name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+}
+
+func targetURI(srv *httptest.Server, token, name string) string {
+	return fmt.Sprintf("http://%s@%s/?name=%s", token, srv.Listener.Addr().String(), name)
+}
+
+// recorder is a publisher recording the published events.
+type recorder struct {
+	mu     sync.Mutex
+	events []interface{}
+}
+
+func (r *recorder) Publish(_ string, ev interface{}, _ ...microevents.PublishOption) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, ev)
+	return nil
+}
+
+func (r *recorder) published() []interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]interface{}{}, r.events...)
+}
+
+func newManager(t *testing.T, file string) txdriver.Manager {
+	return newManagerWithPublisher(t, file, nil)
+}
+
+func newManagerWithPublisher(t *testing.T, file string, publisher events.Publisher) txdriver.Manager {
+	m, err := New(map[string]interface{}{
+		"db_file": file,
+		"streams": 2,
+		"retries": 1,
+	}, publisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func waitEnd(t *testing.T, m txdriver.Manager, id string) datatx.Status {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		info, err := m.GetTransferStatus(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if txEndStatuses[info.Status] {
+			return info.Status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("transfer did not end")
+	return datatx.Status_STATUS_INVALID
+}
+
+func TestTransferFolder(t *testing.T) {
+	src, srcFS := newWebdavServer(t, "src-token", nil)
+	dest, destFS := newWebdavServer(t, "dest-token", nil)
+
+	if err := srcFS.Mkdir(context.Background(), "/data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcFS.Mkdir(context.Background(), "/data/nested", 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"/data/a.txt":        "a",
+		"/data/nested/b.txt": "bb",
+		"/data/nested/c.txt": "ccc",
+	}
+	writeFiles(t, srcFS, files)
+	if err := destFS.Mkdir(context.Background(), "/received", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newManager(t, filepath.Join(t.TempDir(), "transfers.db"))
+	info, err := m.CreateTransfer(context.Background(), targetURI(src, "src-token", "/data"), targetURI(dest, "dest-token", "/received"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitEnd(t, m, info.Id.OpaqueId); status != datatx.Status_STATUS_TRANSFER_COMPLETE {
+		t.Fatalf("got status %v", status)
+	}
+
+	for name, content := range files {
+		data, err := readFile(destFS, path.Join("/received", name[len("/data"):]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("%s: got content %q, expected %q", name, data, content)
+		}
+	}
+
+	if _, err := m.RetryTransfer(context.Background(), info.Id.OpaqueId); err != nil {
+		t.Fatal(err)
+	}
+	if status := waitEnd(t, m, info.Id.OpaqueId); status != datatx.Status_STATUS_TRANSFER_COMPLETE {
+		t.Fatalf("got status %v", status)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	src, srcFS := newWebdavServer(t, "src-token", map[string]string{"/file.txt": "SHA1:0000000000000000000000000000000000000000"})
+	dest, destFS := newWebdavServer(t, "dest-token", nil)
+	writeFiles(t, srcFS, map[string]string{"/file.txt": "content"})
+
+	m := newManager(t, filepath.Join(t.TempDir(), "transfers.db"))
+	info, err := m.CreateTransfer(context.Background(), targetURI(src, "src-token", "/file.txt"), targetURI(dest, "dest-token", "/file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := waitEnd(t, m, info.Id.OpaqueId); status != datatx.Status_STATUS_TRANSFER_FAILED {
+		t.Fatalf("got status %v", status)
+	}
+	if _, err := readFile(destFS, "/file.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected the corrupt copy not to be stored, got %v", err)
+	}
+}
+
+func TestResumeAfterRestart(t *testing.T) {
+	src, srcFS := newWebdavServer(t, "src-token", nil)
+	dest, destFS := newWebdavServer(t, "dest-token", nil)
+	if err := srcFS.Mkdir(context.Background(), "/data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, srcFS, map[string]string{"/data/done.txt": "done", "/data/todo.txt": "todo"})
+
+	c := &webdavClient{client: http.DefaultClient, token: "src-token", authHeader: "x-access-token"}
+	c.base, _ = url.Parse("http://" + src.Listener.Addr().String())
+	resources, err := c.propfind(context.Background(), "/data/done.txt", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a transfer interrupted after transferring done.txt
+	srcEp, _ := parseTargetURI(targetURI(src, "src-token", "/data"))
+	destEp, _ := parseTargetURI(targetURI(dest, "dest-token", "/"))
+	dbFile := filepath.Join(t.TempDir(), "transfers.db")
+	db, err := openDB(&config{DBEngine: "sqlite3", DBFile: dbFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := newStore(context.Background(), "sqlite3", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interrupted := &transfer{
+		ID:     "interrupted",
+		Status: datatx.Status_STATUS_TRANSFER_IN_PROGRESS,
+		Src:    srcEp,
+		Dest:   destEp,
+	}
+	if err := st.insert(context.Background(), interrupted); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.saveFile(context.Background(), interrupted.ID, &file{rel: "done.txt", etag: resources[0].etag}); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	m := newManager(t, dbFile)
+	if status := waitEnd(t, m, "interrupted"); status != datatx.Status_STATUS_TRANSFER_COMPLETE {
+		t.Fatalf("got status %v", status)
+	}
+	if _, err := readFile(destFS, "/todo.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readFile(destFS, "/done.txt"); !os.IsNotExist(err) {
+		t.Fatalf("the file already transferred was transferred again: %v", err)
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	m := newManager(t, filepath.Join(t.TempDir(), "transfers.db"))
+	if _, err := m.CancelTransfer(context.Background(), "unknown"); err == nil {
+		t.Fatal("expected an error cancelling an unknown transfer")
+	}
+
+	// the source never answers, so the transfer runs until cancelled
+	block := make(chan struct{})
+	src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer src.Close()
+	defer close(block)
+
+	info, err := m.CreateTransfer(context.Background(), targetURI(src, "token", "/data"), targetURI(src, "token", "/dest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = m.CancelTransfer(context.Background(), info.Id.OpaqueId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != datatx.Status_STATUS_TRANSFER_CANCELLED {
+		t.Fatalf("got status %v", info.Status)
+	}
+	if status := waitEnd(t, m, info.Id.OpaqueId); status != datatx.Status_STATUS_TRANSFER_CANCELLED {
+		t.Fatalf("got status %v", status)
+	}
+}
+
+func TestListTransfersAndProgress(t *testing.T) {
+	src, srcFS := newWebdavServer(t, "src-token", map[string]string{"/broken.txt": "SHA1:0000000000000000000000000000000000000000"})
+	dest, _ := newWebdavServer(t, "dest-token", nil)
+	writeFiles(t, srcFS, map[string]string{"/file.txt": "content", "/broken.txt": "broken"})
+
+	einstein := &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}
+	marie := &userpb.UserId{Idp: "idp", OpaqueId: "marie"}
+	einsteinCtx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: einstein})
+	marieCtx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: marie})
+
+	r := &recorder{}
+	m := newManagerWithPublisher(t, filepath.Join(t.TempDir(), "transfers.db"), r)
+	complete, err := m.CreateTransfer(einsteinCtx, targetURI(src, "src-token", "/file.txt"), targetURI(dest, "dest-token", "/file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := m.CreateTransfer(einsteinCtx, targetURI(src, "src-token", "/broken.txt"), targetURI(dest, "dest-token", "/broken.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.CreateTransfer(marieCtx, targetURI(src, "src-token", "/file.txt"), targetURI(dest, "dest-token", "/other.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitEnd(t, m, complete.Id.OpaqueId)
+	waitEnd(t, m, failed.Id.OpaqueId)
+	waitEnd(t, m, other.Id.OpaqueId)
+
+	infos, err := m.ListTransfers(einsteinCtx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 {
+		t.Fatalf("expected the 2 transfers of the user, got %d", len(infos))
+	}
+	for _, info := range infos {
+		if info.Id.OpaqueId == other.Id.OpaqueId {
+			t.Fatal("got the transfer of another user")
+		}
+	}
+
+	infos, err = m.ListTransfers(einsteinCtx, []*datatx.ListTransfersRequest_Filter{{
+		Type: datatx.ListTransfersRequest_Filter_TYPE_STATUS,
+		Term: &datatx.ListTransfersRequest_Filter_Status{Status: datatx.Status_STATUS_TRANSFER_FAILED},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Id.OpaqueId != failed.Id.OpaqueId || infos[0].Description == "" {
+		t.Fatalf("expected the failed transfer with its error, got %v", infos)
+	}
+
+	progress, err := m.GetTransfersProgress(einsteinCtx, []string{complete.Id.OpaqueId, "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := progress[complete.Id.OpaqueId]
+	if !ok || len(progress) != 1 {
+		t.Fatalf("expected only the progress of the complete transfer, got %v", progress)
+	}
+	if p.BytesTotal != int64(len("content")) || p.BytesTransferred != p.BytesTotal || p.Percentage != 100 {
+		t.Fatalf("unexpected progress %+v", p)
+	}
+
+	var completed, failures int
+	for _, ev := range r.published() {
+		switch ev := ev.(type) {
+		case events.TransferCompleted:
+			completed++
+		case events.TransferFailed:
+			failures++
+			if ev.ID.OpaqueId != failed.Id.OpaqueId || !utils.UserEqual(ev.Executant, einstein) || ev.Error == "" {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+		}
+	}
+	if completed != 2 || failures != 1 {
+		t.Fatalf("got %d completed and %d failed events", completed, failures)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	for _, conf := range []map[string]interface{}{{"streams": -1}, {"retries": -1}} {
+		conf["db_file"] = filepath.Join(t.TempDir(), "transfers.db")
+		if _, err := New(conf, nil); err == nil {
+			t.Fatalf("expected an error for %v", conf)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	// Provides sqlite drivers.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// endpoint is the webdav location of the source or the destination of a transfer.
+type endpoint struct {
+	Endpoint string
+	Path     string
+	Token    string
+}
+
+// transfer is the state of a transfer. Size and Transferred are the bytes of all
+// the files and of the files already transferred, known once the source has been listed.
+type transfer struct {
+	ID          string
+	Status      datatx.Status
+	Ctime       uint64
+	Creator     *userpb.UserId
+	Src         endpoint
+	Dest        endpoint
+	Size        int64
+	Transferred int64
+	Error       string
+}
+
+// schema holds the statements creating the tables for every supported engine.
+// The files already transferred are recorded with their etag, which allows
+// resuming a transfer after a restart. They are keyed by the hash of their path,
+// as mysql limits the length of the keys.
+var schema = map[string][]string{
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS datatx_transfers (
+	id VARCHAR(64) NOT NULL PRIMARY KEY,
+	status INTEGER NOT NULL,
+	ctime BIGINT NOT NULL,
+	creator_idp VARCHAR(255) NOT NULL DEFAULT '',
+	creator_opaque_id VARCHAR(255) NOT NULL DEFAULT '',
+	creator_type INTEGER NOT NULL DEFAULT 0,
+	src_endpoint TEXT NOT NULL,
+	src_path TEXT NOT NULL,
+	src_token TEXT NOT NULL,
+	dest_endpoint TEXT NOT NULL,
+	dest_path TEXT NOT NULL,
+	dest_token TEXT NOT NULL,
+	size BIGINT NOT NULL DEFAULT 0,
+	transferred BIGINT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS datatx_transfer_files (
+	transfer_id VARCHAR(64) NOT NULL,
+	path_hash CHAR(64) NOT NULL,
+	path TEXT NOT NULL,
+	etag VARCHAR(255) NOT NULL,
+	PRIMARY KEY (transfer_id, path_hash))`,
+	},
+	"sqlite3": {
+		`CREATE TABLE IF NOT EXISTS datatx_transfers (
+	id VARCHAR(64) NOT NULL PRIMARY KEY,
+	status INTEGER NOT NULL,
+	ctime BIGINT NOT NULL,
+	creator_idp VARCHAR(255) NOT NULL DEFAULT '',
+	creator_opaque_id VARCHAR(255) NOT NULL DEFAULT '',
+	creator_type INTEGER NOT NULL DEFAULT 0,
+	src_endpoint TEXT NOT NULL,
+	src_path TEXT NOT NULL,
+	src_token TEXT NOT NULL,
+	dest_endpoint TEXT NOT NULL,
+	dest_path TEXT NOT NULL,
+	dest_token TEXT NOT NULL,
+	size BIGINT NOT NULL DEFAULT 0,
+	transferred BIGINT NOT NULL DEFAULT 0,
+	error TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS datatx_transfer_files (
+	transfer_id VARCHAR(64) NOT NULL,
+	path_hash CHAR(64) NOT NULL,
+	path TEXT NOT NULL,
+	etag VARCHAR(255) NOT NULL,
+	PRIMARY KEY (transfer_id, path_hash))`,
+	},
+}
+
+func openDB(c *config) (*sql.DB, error) {
+	switch c.DBEngine {
+	case "mysql":
+		return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	case "sqlite3":
+		return sql.Open("sqlite3", c.DBFile)
+	default:
+		return nil, errors.New("native: unsupported db engine " + c.DBEngine)
+	}
+}
+
+// store persists the transfers in a database, updating only the rows of a transfer
+// when it changes, and keeps them in memory. The lock protects the transfers in memory.
+type store struct {
+	sync.Mutex
+	db        *sql.DB
+	Transfers map[string]*transfer
+}
+
+// newStore creates the tables if needed and loads the transfers.
+func newStore(ctx context.Context, engine string, db *sql.DB) (*store, error) {
+	stmts, ok := schema[engine]
+	if !ok {
+		return nil, errors.New("native: unsupported db engine " + engine)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, errors.Wrap(err, "native: error creating the transfer tables")
+		}
+	}
+
+	s := &store{db: db, Transfers: map[string]*transfer{}}
+	rows, err := db.QueryContext(ctx, "SELECT id, status, ctime, creator_idp, creator_opaque_id, creator_type, "+
+		"src_endpoint, src_path, src_token, dest_endpoint, dest_path, dest_token, size, transferred, error FROM datatx_transfers")
+	if err != nil {
+		return nil, errors.Wrap(err, "native: error loading the transfers")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t := &transfer{}
+		var status, creatorType int32
+		creator := &userpb.UserId{}
+		if err := rows.Scan(&t.ID, &status, &t.Ctime, &creator.Idp, &creator.OpaqueId, &creatorType,
+			&t.Src.Endpoint, &t.Src.Path, &t.Src.Token, &t.Dest.Endpoint, &t.Dest.Path, &t.Dest.Token,
+			&t.Size, &t.Transferred, &t.Error); err != nil {
+			return nil, errors.Wrap(err, "native: error loading the transfers")
+		}
+		t.Status = datatx.Status(status)
+		if creator.OpaqueId != "" {
+			creator.Type = userpb.UserType(creatorType)
+			t.Creator = creator
+		}
+		s.Transfers[t.ID] = t
+	}
+	return s, rows.Err()
+}
+
+// insert stores a new transfer.
+func (s *store) insert(ctx context.Context, t *transfer) error {
+	creator := t.Creator
+	if creator == nil {
+		creator = &userpb.UserId{}
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT INTO datatx_transfers (id, status, ctime, creator_idp, creator_opaque_id, creator_type, "+
+		"src_endpoint, src_path, src_token, dest_endpoint, dest_path, dest_token, size, transferred, error) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		t.ID, int32(t.Status), t.Ctime, creator.Idp, creator.OpaqueId, int32(creator.Type),
+		t.Src.Endpoint, t.Src.Path, t.Src.Token, t.Dest.Endpoint, t.Dest.Path, t.Dest.Token, t.Size, t.Transferred, t.Error)
+	return errors.Wrap(err, "native: error storing transfer "+t.ID)
+}
+
+// saveStatus stores the status and the error of a transfer.
+func (s *store) saveStatus(ctx context.Context, t *transfer) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE datatx_transfers SET status=?, error=? WHERE id=?", int32(t.Status), t.Error, t.ID)
+	return errors.Wrap(err, "native: error storing transfer "+t.ID)
+}
+
+// saveSize stores the size of a transfer and the bytes already transferred.
+func (s *store) saveSize(ctx context.Context, t *transfer) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE datatx_transfers SET size=?, transferred=? WHERE id=?", t.Size, t.Transferred, t.ID)
+	return errors.Wrap(err, "native: error storing transfer "+t.ID)
+}
+
+// saveFile records a file transferred by a transfer and adds its size to the bytes transferred.
+func (s *store) saveFile(ctx context.Context, id string, f *file) error {
+	h := sha256.Sum256([]byte(f.rel))
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "native: error storing transfer "+id)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, "REPLACE INTO datatx_transfer_files (transfer_id, path_hash, path, etag) VALUES (?, ?, ?, ?)",
+		id, hex.EncodeToString(h[:]), f.rel, f.etag); err != nil {
+		return errors.Wrap(err, "native: error storing transfer "+id)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE datatx_transfers SET transferred=transferred+? WHERE id=?", f.size, id); err != nil {
+		return errors.Wrap(err, "native: error storing transfer "+id)
+	}
+	return errors.Wrap(tx.Commit(), "native: error storing transfer "+id)
+}
+
+// done returns the etags of the files already transferred by a transfer.
+func (s *store) done(ctx context.Context, id string) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT path, etag FROM datatx_transfer_files WHERE transfer_id=?", id)
+	if err != nil {
+		return nil, errors.Wrap(err, "native: error loading the files of transfer "+id)
+	}
+	defer rows.Close()
+	done := map[string]string{}
+	for rows.Next() {
+		var rel, etag string
+		if err := rows.Scan(&rel, &etag); err != nil {
+			return nil, errors.Wrap(err, "native: error loading the files of transfer "+id)
+		}
+		done[rel] = etag
+	}
+	return done, rows.Err()
+}
+
+// get returns the transfer with the given id. It must be called with the lock held.
+func (s *store) get(id string) (*transfer, error) {
+	t, ok := s.Transfers[id]
+	if !ok {
+		return nil, errtypes.NotFound("datatx native driver: transfer " + id)
+	}
+	return t, nil
+}
@@ -0,0 +1,234 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package native
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/pkg/errors"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:">
+  <d:prop>
+    <d:resourcetype/>
+    <d:getcontentlength/>
+    <d:getetag/>
+  </d:prop>
+</d:propfind>`
+
+// webdavClient talks to the webdav endpoint of a transfer source or destination.
+type webdavClient struct {
+	client     *http.Client
+	base       *url.URL
+	token      string
+	authHeader string
+}
+
+// resource is a file or a folder listed by a webdav endpoint.
+type resource struct {
+	path  string
+	isDir bool
+	size  int64
+	etag  string
+}
+
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				ETag          string `xml:"getetag"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (c *webdavClient) url(p string) string {
+	u := *c.base
+	u.Path = path.Join(u.Path, p)
+	return u.String()
+}
+
+func (c *webdavClient) newRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url(p), body)
+	if err != nil {
+		return nil, err
+	}
+	if c.authHeader == "bearer" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else {
+		req.Header.Set(ctxpkg.TokenHeader, c.token)
+	}
+	return req, nil
+}
+
+func statusError(res *http.Response, p string) error {
+	switch res.StatusCode {
+	case http.StatusNotFound:
+		return errtypes.NotFound(p)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errtypes.PermissionDenied(p)
+	default:
+		return errtypes.InternalError(fmt.Sprintf("%s: unexpected status %s", p, res.Status))
+	}
+}
+
+// propfind returns the resource at p, and its children if depth is 1.
+// The resource itself is always the first one.
+func (c *webdavClient) propfind(ctx context.Context, p string, depth int) ([]*resource, error) {
+	req, err := c.newRequest(ctx, "PROPFIND", p, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", strconv.Itoa(depth))
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus {
+		return nil, statusError(res, p)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		return nil, errors.Wrap(err, "error decoding propfind response")
+	}
+
+	self := path.Join(c.base.Path, p)
+	resources := make([]*resource, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			return nil, err
+		}
+		if u, err := url.Parse(href); err == nil && u.IsAbs() {
+			href = u.Path
+		}
+		href = path.Clean(href)
+
+		res := &resource{path: p}
+		if href != self {
+			res.path = path.Join(p, path.Base(href))
+		}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			res.isDir = ps.Prop.ResourceType.Collection != nil
+			res.etag = ps.Prop.ETag
+			if ps.Prop.ContentLength != "" {
+				if res.size, err = strconv.ParseInt(ps.Prop.ContentLength, 10, 64); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if href == self {
+			resources = append([]*resource{res}, resources...)
+		} else {
+			resources = append(resources, res)
+		}
+	}
+	if len(resources) == 0 || resources[0].path != p {
+		return nil, errtypes.InternalError("propfind response without " + p)
+	}
+	return resources, nil
+}
+
+// get downloads the file at p, returning its content and the
+// checksum announced by the endpoint, if any.
+func (c *webdavClient) get(ctx context.Context, p string) (io.ReadCloser, string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, p, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, "", statusError(res, p)
+	}
+	return res.Body, res.Header.Get("OC-Checksum"), nil
+}
+
+// put uploads length bytes read from r to p. The checksum, if any,
+// is verified by the endpoint.
+func (c *webdavClient) put(ctx context.Context, p string, r io.Reader, length int64, checksum string) error {
+	req, err := c.newRequest(ctx, http.MethodPut, p, r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = length
+	if checksum != "" {
+		req.Header.Set("OC-Checksum", checksum)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	default:
+		return statusError(res, p)
+	}
+}
+
+// mkcol creates the folder p, if it does not exist.
+func (c *webdavClient) mkcol(ctx context.Context, p string) error {
+	req, err := c.newRequest(ctx, "MKCOL", p, nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusCreated, http.StatusMethodNotAllowed:
+		// 405 is returned when the folder exists
+		return nil
+	default:
+		return statusError(res, p)
+	}
+}
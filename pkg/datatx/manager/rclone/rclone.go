@@ -32,13 +32,18 @@ import (
 	"sync"
 	"time"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
 	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	txdriver "github.com/cs3org/reva/pkg/datatx"
 	registry "github.com/cs3org/reva/pkg/datatx/manager/registry"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/events"
 	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
@@ -73,9 +78,10 @@ type config struct {
 }
 
 type rclone struct {
-	config  *config
-	client  *http.Client
-	pDriver *pDriver
+	config    *config
+	client    *http.Client
+	pDriver   *pDriver
+	publisher events.Publisher
 }
 
 type rcloneHTTPErrorRes struct {
@@ -107,6 +113,11 @@ type transfer struct {
 	DestRemote     string
 	DestPath       string
 	Ctime          string
+	Creator        *userpb.UserId
+	// the last stats of the rclone job
+	Bytes      int64
+	TotalBytes int64
+	Speed      float64
 }
 
 // txEndStatuses final statuses that cannot be changed anymore.
@@ -128,7 +139,7 @@ type endpoint struct {
 }
 
 // New returns a new rclone driver.
-func New(m map[string]interface{}) (txdriver.Manager, error) {
+func New(m map[string]interface{}, publisher events.Publisher) (txdriver.Manager, error) {
 	c, err := parseConfig(m)
 	if err != nil {
 		return nil, err
@@ -149,9 +160,10 @@ func New(m map[string]interface{}) (txdriver.Manager, error) {
 	}
 
 	return &rclone{
-		config:  c,
-		client:  client,
-		pDriver: pDriver,
+		config:    c,
+		client:    client,
+		pDriver:   pDriver,
+		publisher: publisher,
 	}, nil
 }
 
@@ -195,6 +207,12 @@ func loadOrCreate(file string) (*transferModel, error) {
 	if model.Transfers == nil {
 		model.Transfers = make(map[string]*transfer)
 	}
+	// the transfers created before the creator was recorded belong to the owner of their destination
+	for _, transfer := range model.Transfers {
+		if transfer.Creator == nil {
+			transfer.Creator = tokenOwner(transfer.DestToken)
+		}
+	}
 
 	model.File = file
 	return model, nil
@@ -253,10 +271,14 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 
 	var txID string
 	var cTime *typespb.Timestamp
+	var creator *userpb.UserId
 
 	if transferID == "" {
 		txID = uuid.New().String()
 		cTime = &typespb.Timestamp{Seconds: uint64(time.Now().Unix())}
+		if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+			creator = u.Id
+		}
 	} else { // restart existing transfer if transferID is specified
 		logger.Debug().Msgf("Restarting transfer (txID: %s)", transferID)
 		txID = transferID
@@ -286,6 +308,7 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 		destToken = transfer.DestToken
 		destRemote = transfer.DestRemote
 		destPath = transfer.DestPath
+		creator = transfer.Creator
 		delete(driver.pDriver.model.Transfers, txID)
 	}
 
@@ -302,6 +325,7 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 		DestRemote:     destRemote,
 		DestPath:       destPath,
 		Ctime:          fmt.Sprint(cTime.Seconds), // TODO do we need nanos here?
+		Creator:        creator,
 	}
 
 	driver.pDriver.model.Transfers[txID] = transfer
@@ -445,27 +469,44 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 	}
 
 	// start separate dedicated process to periodically check the transfer progress
+	jobID := transfer.JobID
 	go func() {
 		// runs for as long as no end state or time out has been reached
 		startTimeMs := time.Now().Nanosecond() / 1000
 		timeout := driver.config.JobTimeout
 
-		driver.pDriver.Lock()
-		defer driver.pDriver.Unlock()
+		// the reason of a failure, sent along with the end status event
+		var reason error
+		defer func() {
+			driver.pDriver.Lock()
+			defer driver.pDriver.Unlock()
+			transfer, err := driver.pDriver.model.getTransfer(txID)
+			if err != nil || transfer.JobID != jobID {
+				return
+			}
+			if err := txdriver.EmitTransferEvent(driver.publisher, transfer.txInfo(), transfer.Creator, reason); err != nil {
+				logger.Error().Err(err).Msgf("rclone driver: error publishing transfer event: %v", err)
+			}
+		}()
 
 		for {
+			// the lock is only held to access the transfer, not while waiting for rclone
+			driver.pDriver.Lock()
 			transfer, err := driver.pDriver.model.getTransfer(txID)
+			var status datatx.Status
+			if err == nil {
+				status = transfer.TransferStatus
+			}
+			driver.pDriver.Unlock()
 			if err != nil {
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				err = driver.pDriver.model.saveTransfer(err)
 				logger.Error().Err(err).Msgf("rclone driver: unable to retrieve transfer with id: %v", txID)
 				break
 			}
 
 			// check for end status first
-			_, endStatusFound := txEndStatuses[transfer.TransferStatus.String()]
+			_, endStatusFound := txEndStatuses[status.String()]
 			if endStatusFound {
-				logger.Info().Msgf("rclone driver: transfer endstatus reached: %v", transfer.TransferStatus)
+				logger.Info().Msgf("rclone driver: transfer endstatus reached: %v", status)
 				break
 			}
 
@@ -476,141 +517,47 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 			if timePastMs > timeout {
 				logger.Info().Msgf("rclone driver: transfer timed out: %vms (timeout = %v)", timePastMs, timeout)
 				// set status to EXPIRED and save
-				transfer.TransferStatus = datatx.Status_STATUS_TRANSFER_EXPIRED
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
+				driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_TRANSFER_EXPIRED, nil)
 				break
 			}
 
-			jobID := transfer.JobID
-			type rcloneStatusReqJSON struct {
-				JobID int64 `json:"jobid"`
-			}
-			rcloneStatusReq := &rcloneStatusReqJSON{
-				JobID: jobID,
-			}
-
-			data, err := json.Marshal(rcloneStatusReq)
+			resData, err := driver.jobStatus(jobID)
 			if err != nil {
-				logger.Error().Err(err).Msgf("rclone driver: marshalling request failed: %v", err)
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
+				logger.Error().Err(err).Msgf("rclone driver: error getting the job status: %v", err)
+				driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_INVALID, nil)
 				break
 			}
 
-			transferFileMethod := "/job/status"
-
-			u, err := url.Parse(driver.config.Endpoint)
+			// the stats are kept with the transfer, so that listing the transfers does not query rclone
+			stats, err := driver.jobStats(jobID)
 			if err != nil {
-				logger.Error().Err(err).Msgf("rclone driver: could not parse driver endpoint: %v", err)
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
-				break
-			}
-			u.Path = path.Join(u.Path, transferFileMethod)
-			requestURL := u.String()
-
-			req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(data))
-			if err != nil {
-				logger.Error().Err(err).Msgf("rclone driver: error framing post request: %v", err)
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
-				break
-			}
-			req.Header.Set("Content-Type", "application/json")
-			req.SetBasicAuth(driver.config.AuthUser, driver.config.AuthPass)
-			res, err := driver.client.Do(req)
-			if err != nil {
-				logger.Error().Err(err).Msgf("rclone driver: error sending post request: %v", err)
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
-				break
-			}
-
-			defer res.Body.Close()
-
-			if res.StatusCode != http.StatusOK {
-				var errorResData rcloneHTTPErrorRes
-				if err = json.NewDecoder(res.Body).Decode(&errorResData); err != nil {
-					err = errors.Wrap(err, "rclone driver: error decoding response data")
-					logger.Error().Err(err).Msgf("rclone driver: error reading response body: %v", err)
-				}
-				logger.Error().Err(err).Msgf("rclone driver: rclone request responded with error, status: %v, error: %v", errorResData.Status, errorResData.Error)
-				transfer.TransferStatus = datatx.Status_STATUS_INVALID
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: save transfer failed: %v", err)
-				}
-				break
-			}
-
-			type rcloneStatusResJSON struct {
-				Finished  bool    `json:"finished"`
-				Success   bool    `json:"success"`
-				ID        int64   `json:"id"`
-				Error     string  `json:"error"`
-				Group     string  `json:"group"`
-				StartTime string  `json:"startTime"`
-				EndTime   string  `json:"endTime"`
-				Duration  float64 `json:"duration"`
-				// think we don't need this
-				// "output": {} // output of the job as would have been returned if called synchronously
-			}
-			var resData rcloneStatusResJSON
-			if err = json.NewDecoder(res.Body).Decode(&resData); err != nil {
-				logger.Error().Err(err).Msgf("rclone driver: error decoding response data: %v", err)
-				break
+				logger.Error().Err(err).Msgf("rclone driver: error getting the job stats: %v", err)
 			}
 
 			if resData.Error != "" {
-				logger.Error().Err(err).Msgf("rclone driver: rclone responded with error: %v", resData.Error)
-				transfer.TransferStatus = datatx.Status_STATUS_TRANSFER_FAILED
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: error saving transfer: %v", err)
-					break
-				}
+				logger.Error().Msgf("rclone driver: rclone responded with error: %v", resData.Error)
+				reason = errors.New(resData.Error)
+				driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_TRANSFER_FAILED, stats)
 				break
 			}
 
 			// transfer complete
 			if resData.Finished && resData.Success {
 				logger.Info().Msg("rclone driver: transfer job finished")
-				transfer.TransferStatus = datatx.Status_STATUS_TRANSFER_COMPLETE
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: error saving transfer: %v", err)
-					break
-				}
+				driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_TRANSFER_COMPLETE, stats)
 				break
 			}
 
 			// transfer completed unsuccessfully without error
 			if resData.Finished && !resData.Success {
 				logger.Info().Msgf("rclone driver: transfer job failed")
-				transfer.TransferStatus = datatx.Status_STATUS_TRANSFER_FAILED
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: error saving transfer: %v", err)
-					break
-				}
+				driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_TRANSFER_FAILED, stats)
 				break
 			}
 
 			// transfer not yet finished: continue
-			if !resData.Finished {
-				logger.Info().Msgf("rclone driver: transfer job in progress")
-				transfer.TransferStatus = datatx.Status_STATUS_TRANSFER_IN_PROGRESS
-				if err := driver.pDriver.model.saveTransfer(nil); err != nil {
-					logger.Error().Err(err).Msgf("rclone driver: error saving transfer: %v", err)
-					break
-				}
-			}
+			logger.Info().Msgf("rclone driver: transfer job in progress")
+			driver.updateTransfer(ctx, txID, jobID, datatx.Status_STATUS_TRANSFER_IN_PROGRESS, stats)
 
 			<-time.After(time.Millisecond * time.Duration(driver.config.JobStatusCheckInterval))
 		}
@@ -623,8 +570,122 @@ func (driver *rclone) startJob(ctx context.Context, transferID string, srcRemote
 	}, nil
 }
 
+// updateTransfer saves the status and the stats, if any, of the transfer, unless it was
+// restarted with another job in the meantime.
+func (driver *rclone) updateTransfer(ctx context.Context, txID string, jobID int64, status datatx.Status, stats *rcloneStatsResJSON) {
+	driver.pDriver.Lock()
+	defer driver.pDriver.Unlock()
+
+	transfer, err := driver.pDriver.model.getTransfer(txID)
+	if err != nil || transfer.JobID != jobID {
+		return
+	}
+	// a cancelled transfer keeps its status
+	if _, endStatusFound := txEndStatuses[transfer.TransferStatus.String()]; endStatusFound {
+		return
+	}
+	transfer.TransferStatus = status
+	if stats != nil {
+		transfer.Bytes = stats.Bytes
+		transfer.TotalBytes = stats.TotalBytes
+		transfer.Speed = stats.Speed
+	}
+	if err := driver.pDriver.model.saveTransfer(nil); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msgf("rclone driver: error saving transfer: %v", err)
+	}
+}
+
+type rcloneStatusResJSON struct {
+	Finished  bool    `json:"finished"`
+	Success   bool    `json:"success"`
+	ID        int64   `json:"id"`
+	Error     string  `json:"error"`
+	Group     string  `json:"group"`
+	StartTime string  `json:"startTime"`
+	EndTime   string  `json:"endTime"`
+	Duration  float64 `json:"duration"`
+	// think we don't need this
+	// "output": {} // output of the job as would have been returned if called synchronously
+}
+
+// jobStatus returns the status of the rclone job.
+func (driver *rclone) jobStatus(jobID int64) (*rcloneStatusResJSON, error) {
+	type rcloneStatusReqJSON struct {
+		JobID int64 `json:"jobid"`
+	}
+	var resData rcloneStatusResJSON
+	if err := driver.call("/job/status", &rcloneStatusReqJSON{JobID: jobID}, &resData); err != nil {
+		return nil, err
+	}
+	return &resData, nil
+}
+
+type rcloneStatsResJSON struct {
+	Bytes      int64   `json:"bytes"`
+	TotalBytes int64   `json:"totalBytes"`
+	Speed      float64 `json:"speed"`
+}
+
+// jobStats returns the stats of the rclone job.
+func (driver *rclone) jobStats(jobID int64) (*rcloneStatsResJSON, error) {
+	type rcloneStatsReqJSON struct {
+		Group string `json:"group"`
+	}
+	var resData rcloneStatsResJSON
+	if err := driver.call("/core/stats", &rcloneStatsReqJSON{Group: fmt.Sprintf("job/%d", jobID)}, &resData); err != nil {
+		return nil, err
+	}
+	return &resData, nil
+}
+
+// call posts the request to the rclone method and decodes the response.
+func (driver *rclone) call(method string, rcloneReq, rcloneRes interface{}) error {
+	data, err := json.Marshal(rcloneReq)
+	if err != nil {
+		return errors.Wrap(err, "rclone driver: error marshalling rclone req data")
+	}
+
+	u, err := url.Parse(driver.config.Endpoint)
+	if err != nil {
+		return errors.Wrap(err, "rclone driver: error parsing driver endpoint")
+	}
+	u.Path = path.Join(u.Path, method)
+	requestURL := u.String()
+
+	req, err := http.NewRequest(http.MethodPost, requestURL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "rclone driver: error framing post request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	req.SetBasicAuth(driver.config.AuthUser, driver.config.AuthPass)
+
+	res, err := driver.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "rclone driver: error sending post request")
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var errorResData rcloneHTTPErrorRes
+		if err = json.NewDecoder(res.Body).Decode(&errorResData); err != nil {
+			return errors.Wrap(err, "rclone driver: error decoding response data")
+		}
+		return errors.Wrap(errors.Errorf("status: %v, error: %v", errorResData.Status, errorResData.Error), "rclone driver: rclone request responded with error")
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(rcloneRes); err != nil {
+		return errors.Wrap(err, "rclone driver: error decoding response data")
+	}
+	return nil
+}
+
 // GetTransferStatus returns the status of the transfer with the specified job id.
 func (driver *rclone) GetTransferStatus(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	driver.pDriver.Lock()
+	defer driver.pDriver.Unlock()
+
 	transfer, err := driver.pDriver.model.getTransfer(transferID)
 	if err != nil {
 		return &datatx.TxInfo{
@@ -643,6 +704,9 @@ func (driver *rclone) GetTransferStatus(ctx context.Context, transferID string)
 
 // CancelTransfer cancels the transfer with the specified transfer id.
 func (driver *rclone) CancelTransfer(ctx context.Context, transferID string) (*datatx.TxInfo, error) {
+	driver.pDriver.Lock()
+	defer driver.pDriver.Unlock()
+
 	transfer, err := driver.pDriver.model.getTransfer(transferID)
 	if err != nil {
 		return &datatx.TxInfo{
@@ -789,6 +853,71 @@ func (driver *rclone) RetryTransfer(ctx context.Context, transferID string) (*da
 	return driver.startJob(ctx, transferID, "", "", "", "", "", "")
 }
 
+// ListTransfers returns the transfers created by the user in the context, or all
+// the transfers without a user, matching the status and transfer id filters.
+func (driver *rclone) ListTransfers(ctx context.Context, filters []*datatx.ListTransfersRequest_Filter) ([]*datatx.TxInfo, error) {
+	u, hasUser := ctxpkg.ContextGetUser(ctx)
+
+	driver.pDriver.Lock()
+	defer driver.pDriver.Unlock()
+
+	var txInfos []*datatx.TxInfo
+	for _, transfer := range driver.pDriver.model.Transfers {
+		if hasUser && !utils.UserEqual(u.Id, transfer.Creator) {
+			continue
+		}
+		if txInfo := transfer.txInfo(); txdriver.MatchesFilters(txInfo, filters) {
+			txInfos = append(txInfos, txInfo)
+		}
+	}
+	return txInfos, nil
+}
+
+// GetTransfersProgress returns the progress of the transfers with the specified transfer IDs,
+// as last reported by the stats of their rclone job.
+func (driver *rclone) GetTransfersProgress(ctx context.Context, transferIDs []string) (map[string]*txdriver.Progress, error) {
+	driver.pDriver.Lock()
+	defer driver.pDriver.Unlock()
+
+	progress := make(map[string]*txdriver.Progress, len(transferIDs))
+	for _, id := range transferIDs {
+		transfer, err := driver.pDriver.model.getTransfer(id)
+		if err != nil {
+			continue
+		}
+		speed := transfer.Speed
+		if _, endStatusFound := txEndStatuses[transfer.TransferStatus.String()]; endStatusFound {
+			speed = 0
+		}
+		progress[id] = txdriver.NewProgress(transfer.Bytes, transfer.TotalBytes, speed)
+	}
+	return progress, nil
+}
+
+// txInfo returns the TxInfo object of the transfer.
+func (t *transfer) txInfo() *datatx.TxInfo {
+	cTime, _ := strconv.ParseInt(t.Ctime, 10, 64)
+	return &datatx.TxInfo{
+		Id:      &datatx.TxId{OpaqueId: t.TransferID},
+		Status:  t.TransferStatus,
+		Creator: t.Creator,
+		Ctime:   &typespb.Timestamp{Seconds: uint64(cTime)},
+	}
+}
+
+// tokenOwner returns the id of the user the reva token was minted for, without verifying
+// the token, or nil if the token cannot be decoded.
+func tokenOwner(token string) *userpb.UserId {
+	claims := struct {
+		jwt.StandardClaims
+		User *userpb.User `json:"user"`
+	}{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(token, &claims); err != nil || claims.User == nil {
+		return nil
+	}
+	return claims.User.Id
+}
+
 // getTransfer returns the transfer with the specified transfer ID.
 func (m *transferModel) getTransfer(transferID string) (*transfer, error) {
 	transfer, ok := m.Transfers[transferID]
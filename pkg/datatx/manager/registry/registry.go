@@ -20,11 +20,12 @@ package registry
 
 import (
 	"github.com/cs3org/reva/pkg/datatx"
+	"github.com/cs3org/reva/pkg/events"
 )
 
 // NewFunc is the function that datatx implementations
 // should register at init time.
-type NewFunc func(map[string]interface{}) (datatx.Manager, error)
+type NewFunc func(map[string]interface{}, events.Publisher) (datatx.Manager, error)
 
 // NewFuncs is a map containing all the registered datatx backends.
 var NewFuncs = map[string]NewFunc{}
@@ -122,6 +122,17 @@ func (e InsufficientStorage) IsInsufficientStorage() {}
 // https://developer.mozilla.org/en-US/docs/Web/HTTP/Status/507
 const StatusInssufficientStorage = 507
 
+// Locked is the error to use when a resource is locked and the caller does not hold the lock.
+type Locked string
+
+func (e Locked) Error() string { return "error: resource is locked, lockID: " + string(e) }
+
+// LockID returns the id of the lock held on the resource.
+func (e Locked) LockID() string { return string(e) }
+
+// IsLocked implements the IsLocked interface.
+func (e Locked) IsLocked() {}
+
 // IsNotFound is the interface to implement
 // to specify that an a resource is not found.
 type IsNotFound interface {
@@ -187,3 +198,9 @@ type IsChecksumMismatch interface {
 type IsInsufficientStorage interface {
 	IsInsufficientStorage()
 }
+
+// IsLocked is the interface to implement
+// to specify that a resource is locked.
+type IsLocked interface {
+	IsLocked()
+}
@@ -19,8 +19,10 @@
 package events
 
 import (
+	"fmt"
 	"log"
 	"reflect"
+	"time"
 
 	"go-micro.dev/v4/events"
 )
@@ -34,6 +36,9 @@ var (
 
 	// MetadatakeyEventType is the key used for the eventtype in the metadata map of the event.
 	MetadatakeyEventType = "eventtype"
+
+	// AckWait is the time after which events that have not been acknowledged are delivered again.
+	AckWait = 30 * time.Second
 )
 
 type (
@@ -59,11 +64,56 @@ type (
 	}
 )
 
+// AckEvent is an event that has to be acknowledged by the consumer once it has been processed.
+type AckEvent struct {
+	Event interface{}
+
+	raw *events.Event
+}
+
+// Ack acknowledges the event, it won't be delivered again.
+func (e AckEvent) Ack() error {
+	return e.raw.Ack()
+}
+
+// Nack negatively acknowledges the event, it will be delivered again.
+func (e AckEvent) Nack() error {
+	return e.raw.Nack()
+}
+
+// DeadLetter is an event that could not be decoded by the consumer.
+type DeadLetter struct {
+	Event events.Event
+	Err   error
+}
+
 // Consume returns a channel that will get all events that match the given evs
 // group defines the service type: One group will get exactly one copy of a event that is emitted
 // NOTE: uses reflect on initialization.
 func Consume(s Consumer, group string, evs ...Unmarshaller) (<-chan interface{}, error) {
-	c, err := s.Consume(MainQueueName, events.WithGroup(group))
+	c, err := ConsumeWithAck(s, group, nil, evs...)
+	if err != nil {
+		return nil, err
+	}
+
+	outchan := make(chan interface{})
+	go func() {
+		defer close(outchan)
+		for e := range c {
+			if err := e.Ack(); err != nil {
+				log.Printf("can't acknowledge event %v", err)
+			}
+			outchan <- e.Event
+		}
+	}()
+	return outchan, nil
+}
+
+// ConsumeWithAck is like Consume, but the events stay in the stream until the consumer acknowledges them.
+// Events that are not registered or can't be unmarshalled are sent to deadLetters and acknowledged,
+// they are logged and dropped if deadLetters is nil.
+func ConsumeWithAck(s Consumer, group string, deadLetters chan<- DeadLetter, evs ...Unmarshaller) (<-chan AckEvent, error) {
+	c, err := s.Consume(MainQueueName, events.WithGroup(group), events.WithAutoAck(false, AckWait))
 	if err != nil {
 		return nil, err
 	}
@@ -74,29 +124,40 @@ func Consume(s Consumer, group string, evs ...Unmarshaller) (<-chan interface{},
 		registeredEvents[typ.String()] = e
 	}
 
-	outchan := make(chan interface{})
+	outchan := make(chan AckEvent)
 	go func() {
-		for {
-			e := <-c
+		defer close(outchan)
+		for e := range c {
+			e := e
 			et := e.Metadata[MetadatakeyEventType]
 			ev, ok := registeredEvents[et]
 			if !ok {
-				log.Printf("not registered: %s", et)
+				deadLetter(&e, deadLetters, fmt.Errorf("not registered: %s", et))
 				continue
 			}
 
 			event, err := ev.Unmarshal(e.Payload)
 			if err != nil {
-				log.Printf("can't unmarshal event %v", err)
+				deadLetter(&e, deadLetters, fmt.Errorf("can't unmarshal event %v", err))
 				continue
 			}
 
-			outchan <- event
+			outchan <- AckEvent{Event: event, raw: &e}
 		}
 	}()
 	return outchan, nil
 }
 
+func deadLetter(e *events.Event, deadLetters chan<- DeadLetter, err error) {
+	// undecodable events would be redelivered forever
+	_ = e.Ack()
+	if deadLetters == nil {
+		log.Print(err)
+		return
+	}
+	deadLetters <- DeadLetter{Event: *e, Err: err}
+}
+
 // Publish publishes the ev to the MainQueue from where it is distributed to all subscribers
 // NOTE: needs to use reflect on runtime.
 func Publish(s Publisher, ev interface{}) error {
@@ -0,0 +1,101 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package events_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/events/stream/memory"
+)
+
+type unknownEvent struct{}
+
+type brokenEvent struct{}
+
+func (brokenEvent) Unmarshal(v []byte) (interface{}, error) {
+	return nil, json.Unmarshal([]byte("{"), &struct{}{})
+}
+
+func TestConsume(t *testing.T) {
+	s := memory.NewStream()
+	ch, err := events.Consume(s, "test", events.ShareCreated{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sharer := &user.UserId{OpaqueId: "einstein"}
+	if err := events.Publish(s, events.ShareCreated{Sharer: sharer}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-ch:
+		sc, ok := ev.(events.ShareCreated)
+		if !ok {
+			t.Fatalf("unexpected event %T", ev)
+		}
+		if sc.Sharer.OpaqueId != "einstein" {
+			t.Errorf("unexpected sharer %v", sc.Sharer)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+func TestConsumeWithAckDeadLetters(t *testing.T) {
+	s := memory.NewStream()
+	deadLetters := make(chan events.DeadLetter)
+	ch, err := events.ConsumeWithAck(s, "test", deadLetters, events.ShareCreated{}, brokenEvent{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ev := range []interface{}{unknownEvent{}, brokenEvent{}} {
+		if err := events.Publish(s, ev); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case dl := <-deadLetters:
+			if dl.Err == nil {
+				t.Error("expected the reason of the dead letter")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for dead letter of %T", ev)
+		}
+	}
+
+	if err := events.Publish(s, events.ShareCreated{}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-ch:
+		if _, ok := ev.Event.(events.ShareCreated); !ok {
+			t.Fatalf("unexpected event %T", ev.Event)
+		}
+		if err := ev.Ack(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
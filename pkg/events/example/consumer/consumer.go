@@ -36,6 +36,9 @@ func Example(c events.Consumer) {
 	evs := []events.Unmarshaller{
 		// for example created shares
 		events.ShareCreated{},
+		// or finished uploads and deleted files
+		events.FileUploaded{},
+		events.ItemTrashed{},
 	}
 
 	// Step 3 - create event channel
@@ -52,6 +55,10 @@ func Example(c events.Consumer) {
 		switch v := event.(type) {
 		case events.ShareCreated:
 			fmt.Printf("%s) Share created: %+v\n", group, v)
+		case events.FileUploaded:
+			fmt.Printf("%s) File uploaded: %+v\n", group, v)
+		case events.ItemTrashed:
+			fmt.Printf("%s) Item trashed: %+v\n", group, v)
 		default:
 			fmt.Printf("%s) Unregistered event: %+v\n", group, v)
 		}
@@ -0,0 +1,123 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package events
+
+import (
+	"encoding/json"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+)
+
+// FileUploaded is emitted when a file upload has finished.
+type FileUploaded struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (FileUploaded) Unmarshal(v []byte) (interface{}, error) {
+	e := FileUploaded{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// FileDownloaded is emitted when a file download is initiated.
+type FileDownloaded struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (FileDownloaded) Unmarshal(v []byte) (interface{}, error) {
+	e := FileDownloaded{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// ItemTrashed is emitted when a file or folder is deleted.
+type ItemTrashed struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (ItemTrashed) Unmarshal(v []byte) (interface{}, error) {
+	e := ItemTrashed{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// ItemMoved is emitted when a file or folder is moved or renamed.
+type ItemMoved struct {
+	Executant    *user.UserId
+	Ref          *provider.Reference
+	OldReference *provider.Reference
+	ItemID       *provider.ResourceId
+	Timestamp    *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (ItemMoved) Unmarshal(v []byte) (interface{}, error) {
+	e := ItemMoved{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// ItemRestored is emitted when a file or folder is restored from the trash.
+type ItemRestored struct {
+	Executant *user.UserId
+	// Ref is the reference of the space the item was deleted from
+	Ref *provider.Reference
+	// Key identifies the item in the trash
+	Key string
+	// RestoreRef is the location the item was restored to, if not its original one
+	RestoreRef *provider.Reference
+	Timestamp  *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (ItemRestored) Unmarshal(v []byte) (interface{}, error) {
+	e := ItemRestored{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// FileVersionRestored is emitted when a file version is restored.
+type FileVersionRestored struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Key       string
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (FileVersionRestored) Unmarshal(v []byte) (interface{}, error) {
+	e := FileVersionRestored{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
@@ -0,0 +1,74 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package events
+
+import (
+	"encoding/json"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+)
+
+// FileLocked is emitted when a lock is set on a resource.
+type FileLocked struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Lock      *provider.Lock
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (FileLocked) Unmarshal(v []byte) (interface{}, error) {
+	e := FileLocked{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// LockRefreshed is emitted when the lock of a resource is refreshed.
+type LockRefreshed struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Lock      *provider.Lock
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (LockRefreshed) Unmarshal(v []byte) (interface{}, error) {
+	e := LockRefreshed{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// FileUnlocked is emitted when the lock of a resource is removed.
+type FileUnlocked struct {
+	Executant *user.UserId
+	Ref       *provider.Reference
+	ItemID    *provider.ResourceId
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (FileUnlocked) Unmarshal(v []byte) (interface{}, error) {
+	e := FileUnlocked{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
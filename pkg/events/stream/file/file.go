@@ -0,0 +1,342 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package file implements a durable event stream on top of append-only files.
+// Every topic is stored in its own log file and the position of every named
+// consumer group is persisted next to it, so that groups continue where they
+// left off after a restart. Events a named group did not acknowledge within
+// its retries are appended to the dead letter file of the group. The logs are
+// never truncated.
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/events/stream"
+	"github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	microevents "go-micro.dev/v4/events"
+)
+
+func init() {
+	registry.Register("file", New)
+}
+
+type config struct {
+	Root         string `mapstructure:"root" docs:"/var/tmp/reva/events;The directory where the events are stored."`
+	PollInterval int    `mapstructure:"poll_interval" docs:"1000;The interval in milliseconds in which the logs are checked for events written by other processes."`
+}
+
+func (c *config) init() {
+	if c.Root == "" {
+		c.Root = "/var/tmp/reva/events"
+	}
+	if c.PollInterval == 0 {
+		c.PollInterval = 1000
+	}
+}
+
+var (
+	mu      sync.Mutex
+	streams = map[string]*Stream{}
+)
+
+// New returns the file stream stored in the configured root.
+func New(m map[string]interface{}) (events.Stream, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+	c.init()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if s, ok := streams[c.Root]; ok {
+		return s, nil
+	}
+	s, err := NewStream(c.Root, time.Duration(c.PollInterval)*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	streams[c.Root] = s
+	return s, nil
+}
+
+// Stream is an event stream persisted in a directory.
+type Stream struct {
+	root string
+	poll time.Duration
+
+	mu     sync.Mutex
+	groups map[string]map[string]*group
+}
+
+// NewStream returns a stream storing its events in root.
+func NewStream(root string, poll time.Duration) (*Stream, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, errors.Wrap(err, "file: error creating root")
+	}
+	return &Stream{
+		root:   root,
+		poll:   poll,
+		groups: map[string]map[string]*group{},
+	}, nil
+}
+
+func (s *Stream) logPath(topic string) string {
+	return filepath.Join(s.root, url.PathEscape(topic)+".log")
+}
+
+func (s *Stream) cursorPath(topic, group string) string {
+	return filepath.Join(s.root, url.PathEscape(topic)+"."+url.PathEscape(group)+".offset")
+}
+
+func (s *Stream) deadLetterPath(topic, group string) string {
+	return filepath.Join(s.root, url.PathEscape(topic)+"."+url.PathEscape(group)+".dead")
+}
+
+// Publish appends an event to the log of the topic.
+func (s *Stream) Publish(topic string, msg interface{}, opts ...microevents.PublishOption) error {
+	ev, err := stream.NewEvent(topic, msg, opts...)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return microevents.ErrEncodingMessage
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.logPath(topic), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "file: error opening log of topic "+topic)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return errors.Wrap(err, "file: error writing event")
+	}
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "file: error writing event")
+	}
+
+	for _, g := range s.groups[topic] {
+		g.notify()
+	}
+	return nil
+}
+
+// Consume returns the channel of the consumer group. Consumers of the same group in this process
+// share the channel and the options of the first consumer of the group.
+// New named groups start at the beginning of the log, consumers without a group only receive
+// the events published after they subscribed. Both can be moved with the Offset option.
+func (s *Stream) Consume(topic string, opts ...microevents.ConsumeOption) (<-chan microevents.Event, error) {
+	if topic == "" {
+		return nil, microevents.ErrMissingTopic
+	}
+
+	explicit := microevents.ConsumeOptions{}
+	for _, opt := range opts {
+		opt(&explicit)
+	}
+	durable := explicit.Group != ""
+	o := stream.ConsumeOptions(opts...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups, ok := s.groups[topic]
+	if !ok {
+		groups = map[string]*group{}
+		s.groups[topic] = groups
+	}
+	if g, ok := groups[o.Group]; ok {
+		return g.ch, nil
+	}
+
+	g := &group{
+		logPath: s.logPath(topic),
+		opts:    o,
+		poll:    s.poll,
+		ch:      make(chan microevents.Event),
+		wake:    make(chan struct{}, 1),
+	}
+	if durable {
+		g.cursorPath = s.cursorPath(topic, o.Group)
+		g.deadLetterPath = s.deadLetterPath(topic, o.Group)
+	}
+
+	offset, err := g.initialOffset()
+	if err != nil {
+		return nil, err
+	}
+	groups[o.Group] = g
+	go g.dispatch(offset)
+
+	return g.ch, nil
+}
+
+type group struct {
+	logPath        string
+	cursorPath     string
+	deadLetterPath string
+	opts           microevents.ConsumeOptions
+	poll           time.Duration
+	ch             chan microevents.Event
+	wake           chan struct{}
+}
+
+func (g *group) notify() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (g *group) initialOffset() (int64, error) {
+	if g.cursorPath != "" {
+		b, err := os.ReadFile(g.cursorPath)
+		switch {
+		case err == nil:
+			return strconv.ParseInt(string(b), 10, 64)
+		case !os.IsNotExist(err):
+			return 0, errors.Wrap(err, "file: error reading cursor")
+		}
+	}
+
+	if g.cursorPath != "" || !g.opts.Offset.IsZero() {
+		return 0, nil
+	}
+	fi, err := os.Stat(g.logPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, errors.Wrap(err, "file: error reading log")
+	}
+	return fi.Size(), nil
+}
+
+func (g *group) saveOffset(offset int64) {
+	if g.cursorPath == "" {
+		return
+	}
+	tmp := g.cursorPath + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(offset, 10)), 0600); err != nil {
+		log.Error().Err(err).Str("cursor", g.cursorPath).Msg("file: error writing cursor")
+		return
+	}
+	if err := os.Rename(tmp, g.cursorPath); err != nil {
+		log.Error().Err(err).Str("cursor", g.cursorPath).Msg("file: error writing cursor")
+	}
+}
+
+// deadLetter keeps an event the group did not acknowledge within its retries,
+// so that it is not lost when the group moves on.
+func (g *group) deadLetter(line []byte, ev microevents.Event) {
+	l := log.Error().Str("id", ev.ID).Str("topic", ev.Topic).Str("group", g.opts.Group)
+	if g.deadLetterPath == "" {
+		l.Msg("file: dropping event that was not acknowledged")
+		return
+	}
+	l.Str("dead_letters", g.deadLetterPath).Msg("file: moving event that was not acknowledged to the dead letters")
+
+	f, err := os.OpenFile(g.deadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Error().Err(err).Str("id", ev.ID).Msg("file: error opening dead letters")
+		return
+	}
+	if _, err := f.Write(line); err != nil {
+		log.Error().Err(err).Str("id", ev.ID).Msg("file: error writing dead letter")
+	}
+	if err := f.Close(); err != nil {
+		log.Error().Err(err).Str("id", ev.ID).Msg("file: error writing dead letter")
+	}
+}
+
+func (g *group) wait() {
+	timer := time.NewTimer(g.poll)
+	defer timer.Stop()
+	select {
+	case <-g.wake:
+	case <-timer.C:
+	}
+}
+
+func (g *group) dispatch(offset int64) {
+	var f *os.File
+	var r *bufio.Reader
+	for {
+		if f == nil {
+			var err error
+			if f, err = os.Open(g.logPath); err != nil {
+				if !os.IsNotExist(err) {
+					log.Error().Err(err).Str("log", g.logPath).Msg("file: error opening log")
+				}
+				f = nil
+				g.wait()
+				continue
+			}
+			if _, err = f.Seek(offset, io.SeekStart); err != nil {
+				log.Error().Err(err).Str("log", g.logPath).Msg("file: error seeking log")
+				f.Close()
+				f = nil
+				g.wait()
+				continue
+			}
+			r = bufio.NewReader(f)
+		}
+
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				log.Error().Err(err).Str("log", g.logPath).Msg("file: error reading log")
+			}
+			// the last event might not be written completely yet
+			if len(line) > 0 {
+				f.Close()
+				f = nil
+			}
+			g.wait()
+			continue
+		}
+		next := offset + int64(len(line))
+
+		var ev microevents.Event
+		if err := json.Unmarshal(line, &ev); err != nil {
+			log.Error().Err(err).Str("log", g.logPath).Int64("offset", offset).Msg("file: skipping corrupt event")
+		} else if !ev.Timestamp.Before(g.opts.Offset) && !stream.Deliver(g.ch, ev, g.opts) {
+			g.deadLetter(line, ev)
+		}
+
+		offset = next
+		g.saveOffset(offset)
+	}
+}
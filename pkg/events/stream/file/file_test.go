@@ -0,0 +1,180 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/events"
+)
+
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return events.Event{}
+}
+
+func TestGroupsSurviveRestarts(t *testing.T) {
+	root := t.TempDir()
+
+	s, err := NewStream(root, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"1", "2", "3"} {
+		if err := s.Publish("topic", []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// new named groups start at the beginning of the log
+	ch, err := s.Consume("topic", events.WithGroup("g"), events.WithAutoAck(false, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := receive(t, ch)
+	if string(ev.Payload) != "1" {
+		t.Fatalf("expected first event, got %s", ev.Payload)
+	}
+	_ = ev.Ack()
+	ev = receive(t, ch)
+	if string(ev.Payload) != "2" {
+		t.Fatalf("expected second event, got %s", ev.Payload)
+	}
+	// the second event is not acknowledged before the "restart"
+
+	restarted, err := NewStream(root, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err = restarted.Consume("topic", events.WithGroup("g"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"2", "3"} {
+		if ev := receive(t, ch); string(ev.Payload) != want {
+			t.Fatalf("expected event %s, got %s", want, ev.Payload)
+		}
+	}
+}
+
+func TestAnonymousConsumersOnlyGetNewEvents(t *testing.T) {
+	s, err := NewStream(t.TempDir(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish("topic", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := s.Consume("topic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish("topic", map[string]string{"hello": "world"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := receive(t, ch)
+	var msg map[string]string
+	if err := ev.Unmarshal(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg["hello"] != "world" {
+		t.Errorf("unexpected payload %s", ev.Payload)
+	}
+}
+
+func TestEventsWrittenByOtherProcesses(t *testing.T) {
+	root := t.TempDir()
+	consumer, err := NewStream(root, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publisher, err := NewStream(root, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch, err := consumer.Consume("topic", events.WithGroup("g"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := publisher.Publish("topic", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if ev := receive(t, ch); string(ev.Payload) != "1" {
+		t.Fatalf("unexpected payload %s", ev.Payload)
+	}
+}
+
+func TestUnacknowledgedEventsAreDeadLettered(t *testing.T) {
+	s, err := NewStream(t.TempDir(), 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"1", "2"} {
+		if err := s.Publish("topic", []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ch, err := s.Consume("topic", events.WithGroup("g"), events.WithAutoAck(false, time.Minute), events.WithRetryLimit(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first event is rejected on every attempt
+	for i := 0; i < 2; i++ {
+		ev := receive(t, ch)
+		if string(ev.Payload) != "1" {
+			t.Fatalf("expected first event, got %s", ev.Payload)
+		}
+		_ = ev.Nack()
+	}
+	if ev := receive(t, ch); string(ev.Payload) != "2" {
+		t.Fatalf("expected second event, got %s", ev.Payload)
+	}
+
+	f, err := os.Open(s.deadLetterPath("topic", "g"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var dead []events.Event
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var ev events.Event
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatal(err)
+		}
+		dead = append(dead, ev)
+	}
+	if len(dead) != 1 || string(dead[0].Payload) != "1" {
+		t.Errorf("expected the first event in the dead letters, got %v", dead)
+	}
+}
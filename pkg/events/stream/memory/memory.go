@@ -0,0 +1,162 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package memory implements an in-process event stream. Events are only
+// delivered to consumers of the same process and are lost on restart.
+package memory
+
+import (
+	"sync"
+
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/events/stream"
+	"github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	microevents "go-micro.dev/v4/events"
+)
+
+func init() {
+	registry.Register("memory", New)
+}
+
+type config struct {
+	// Name identifies the stream in the process, all the services
+	// configured with the same name share their events.
+	Name string `mapstructure:"name"`
+}
+
+func (c *config) init() {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+}
+
+var (
+	mu      sync.Mutex
+	streams = map[string]*Stream{}
+)
+
+// New returns the in-process stream with the configured name.
+func New(m map[string]interface{}) (events.Stream, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+	c.init()
+
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := streams[c.Name]
+	if !ok {
+		s = NewStream()
+		streams[c.Name] = s
+	}
+	return s, nil
+}
+
+// Stream broadcasts the published events to every consumer group of a topic.
+// Within a group the events are delivered one at a time, in the order they
+// were published.
+type Stream struct {
+	mu     sync.Mutex
+	topics map[string]map[string]*group
+}
+
+// NewStream returns a new, isolated in-process stream.
+func NewStream() *Stream {
+	return &Stream{
+		topics: map[string]map[string]*group{},
+	}
+}
+
+type group struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []microevents.Event
+	ch      chan microevents.Event
+}
+
+func newGroup(opts microevents.ConsumeOptions) *group {
+	g := &group{
+		ch: make(chan microevents.Event),
+	}
+	g.cond = sync.NewCond(&g.mu)
+	go g.dispatch(opts)
+	return g
+}
+
+func (g *group) push(ev microevents.Event) {
+	g.mu.Lock()
+	g.pending = append(g.pending, ev)
+	g.mu.Unlock()
+	g.cond.Signal()
+}
+
+func (g *group) dispatch(opts microevents.ConsumeOptions) {
+	for {
+		g.mu.Lock()
+		for len(g.pending) == 0 {
+			g.cond.Wait()
+		}
+		ev := g.pending[0]
+		g.pending = g.pending[1:]
+		g.mu.Unlock()
+
+		stream.Deliver(g.ch, ev, opts)
+	}
+}
+
+// Publish publishes an event to all the consumer groups of the topic.
+func (s *Stream) Publish(topic string, msg interface{}, opts ...microevents.PublishOption) error {
+	ev, err := stream.NewEvent(topic, msg, opts...)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, g := range s.topics[topic] {
+		g.push(*ev)
+	}
+	return nil
+}
+
+// Consume returns the channel of the consumer group. Consumers of the same group
+// share the channel and the options of the first consumer of the group.
+// Events published before the group existed are not delivered.
+func (s *Stream) Consume(topic string, opts ...microevents.ConsumeOption) (<-chan microevents.Event, error) {
+	if topic == "" {
+		return nil, microevents.ErrMissingTopic
+	}
+	o := stream.ConsumeOptions(opts...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	groups, ok := s.topics[topic]
+	if !ok {
+		groups = map[string]*group{}
+		s.topics[topic] = groups
+	}
+	g, ok := groups[o.Group]
+	if !ok {
+		g = newGroup(o)
+		groups[o.Group] = g
+	}
+	return g.ch, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/events"
+)
+
+func receive(t *testing.T, ch <-chan events.Event) events.Event {
+	t.Helper()
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return events.Event{}
+}
+
+func TestBroadcastToGroups(t *testing.T) {
+	s := NewStream()
+	a, err := s.Consume("topic", events.WithGroup("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.Consume("topic", events.WithGroup("b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.Consume("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Publish("topic", map[string]string{"hello": "world"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ch := range []<-chan events.Event{a, b} {
+		ev := receive(t, ch)
+		var msg map[string]string
+		if err := ev.Unmarshal(&msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg["hello"] != "world" {
+			t.Errorf("unexpected payload %s", ev.Payload)
+		}
+	}
+
+	select {
+	case ev := <-other:
+		t.Errorf("received event of another topic: %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSameGroupSharesEvents(t *testing.T) {
+	s := NewStream()
+	first, _ := s.Consume("topic", events.WithGroup("g"))
+	second, _ := s.Consume("topic", events.WithGroup("g"))
+	if first != second {
+		t.Fatal("consumers of the same group must share the channel")
+	}
+}
+
+func TestNackRedelivers(t *testing.T) {
+	s := NewStream()
+	ch, _ := s.Consume("topic", events.WithGroup("g"), events.WithAutoAck(false, time.Minute))
+
+	if err := s.Publish("topic", []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Publish("topic", []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+
+	ev := receive(t, ch)
+	if string(ev.Payload) != "1" {
+		t.Fatalf("expected first event, got %s", ev.Payload)
+	}
+	_ = ev.Nack()
+
+	ev = receive(t, ch)
+	if string(ev.Payload) != "1" {
+		t.Fatalf("expected first event again, got %s", ev.Payload)
+	}
+	_ = ev.Ack()
+
+	ev = receive(t, ch)
+	if string(ev.Payload) != "2" {
+		t.Fatalf("expected second event, got %s", ev.Payload)
+	}
+	_ = ev.Ack()
+}
+
+func TestAckWaitAndRetryLimit(t *testing.T) {
+	s := NewStream()
+	ch, _ := s.Consume("topic", events.WithGroup("g"), events.WithAutoAck(false, 10*time.Millisecond), events.WithRetryLimit(1))
+
+	_ = s.Publish("topic", []byte("1"))
+	_ = s.Publish("topic", []byte("2"))
+
+	// never acknowledged: delivered once and retried once
+	for i := 0; i < 2; i++ {
+		if ev := receive(t, ch); string(ev.Payload) != "1" {
+			t.Fatalf("expected first event, got %s", ev.Payload)
+		}
+	}
+	if ev := receive(t, ch); string(ev.Payload) != "2" {
+		t.Fatalf("expected second event after the retry limit, got %s", ev.Payload)
+	}
+}
+
+func TestNewSharesNamedStreams(t *testing.T) {
+	a, _ := New(map[string]interface{}{"name": "shared"})
+	b, _ := New(map[string]interface{}{"name": "shared"})
+	c, _ := New(map[string]interface{}{"name": "isolated"})
+	if a != b {
+		t.Error("streams with the same name must be shared")
+	}
+	if a == c {
+		t.Error("streams with different names must be isolated")
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package nats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/asim/go-micro/plugins/events/nats/v4"
+	"github.com/cs3org/reva/pkg/events"
+	"github.com/cs3org/reva/pkg/events/server"
+	"github.com/cs3org/reva/pkg/events/stream/registry"
+	"github.com/mitchellh/mapstructure"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("nats", New)
+}
+
+type config struct {
+	Address   string `mapstructure:"address"`
+	ClusterID string `mapstructure:"clusterID"`
+}
+
+// New returns a stream backed by a nats streaming server.
+func New(m map[string]interface{}) (events.Stream, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+
+	s, err := server.NewNatsStream(nats.Address(c.Address), nats.ClusterID(c.ClusterID))
+	if err != nil {
+		return nil, err
+	}
+	return &stream{Stream: s, address: c.Address}, nil
+}
+
+// stream is a nats stream which can tell whether the nats server is reachable.
+type stream struct {
+	events.Stream
+	address string
+
+	mu   sync.Mutex
+	conn *natsgo.Conn
+}
+
+// Ready tells whether the nats server is reachable, through a connection
+// kept open for the checks, which reconnects by itself.
+func (s *stream) Ready(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		address := s.address
+		if address == "" {
+			address = natsgo.DefaultURL
+		}
+		opts := []natsgo.Option{natsgo.MaxReconnects(-1)}
+		if deadline, ok := ctx.Deadline(); ok {
+			opts = append(opts, natsgo.Timeout(time.Until(deadline)))
+		}
+		conn, err := natsgo.Connect(address, opts...)
+		if err != nil {
+			return errors.Wrap(err, "nats server not reachable")
+		}
+		s.conn = conn
+	}
+	if !s.conn.IsConnected() {
+		return errors.New("nats server not reachable")
+	}
+	return nil
+}
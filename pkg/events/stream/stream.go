@@ -0,0 +1,129 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package stream contains helpers shared by the event stream implementations.
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go-micro.dev/v4/events"
+)
+
+// DefaultAckWait is used when a consumer doesn't acknowledge events automatically
+// and didn't specify how long to wait for the acknowledgement.
+const DefaultAckWait = 30 * time.Second
+
+// ConsumeOptions returns the options for a consumer. Events are acknowledged automatically
+// and consumers without a group get a group of their own, so that they receive every event.
+func ConsumeOptions(opts ...events.ConsumeOption) events.ConsumeOptions {
+	o := events.ConsumeOptions{
+		Group:   uuid.New().String(),
+		AutoAck: true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.AckWait <= 0 {
+		o.AckWait = DefaultAckWait
+	}
+	return o
+}
+
+// NewEvent builds the event published to a topic.
+func NewEvent(topic string, msg interface{}, opts ...events.PublishOption) (*events.Event, error) {
+	if topic == "" {
+		return nil, events.ErrMissingTopic
+	}
+
+	o := events.PublishOptions{
+		Timestamp: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var payload []byte
+	if p, ok := msg.([]byte); ok {
+		payload = p
+	} else {
+		p, err := json.Marshal(msg)
+		if err != nil {
+			return nil, events.ErrEncodingMessage
+		}
+		payload = p
+	}
+
+	return &events.Event{
+		ID:        uuid.New().String(),
+		Topic:     topic,
+		Timestamp: o.Timestamp,
+		Metadata:  o.Metadata,
+		Payload:   payload,
+	}, nil
+}
+
+// Deliver sends ev to the consumer until it is acknowledged or the retry limit of the consumer
+// is reached. Events that are not acknowledged automatically are sent again when the consumer
+// nacks them or doesn't acknowledge them within the AckWait of the options. It reports
+// whether the event has been acknowledged.
+func Deliver(ch chan<- events.Event, ev events.Event, o events.ConsumeOptions) bool {
+	limit := o.GetRetryLimit()
+	for attempt := 0; limit < 0 || attempt <= limit; attempt++ {
+		if send(ch, ev, o) {
+			return true
+		}
+	}
+	return false
+}
+
+func send(ch chan<- events.Event, ev events.Event, o events.ConsumeOptions) bool {
+	if o.AutoAck {
+		noop := func() error { return nil }
+		ev.SetAckFunc(noop)
+		ev.SetNackFunc(noop)
+		ch <- ev
+		return true
+	}
+
+	acked := make(chan bool, 1)
+	var once sync.Once
+	ev.SetAckFunc(func() error {
+		once.Do(func() { acked <- true })
+		return nil
+	})
+	ev.SetNackFunc(func() error {
+		once.Do(func() { acked <- false })
+		return nil
+	})
+	ch <- ev
+
+	timer := time.NewTimer(o.AckWait)
+	defer timer.Stop()
+	select {
+	case ok := <-acked:
+		return ok
+	case <-timer.C:
+		// a late acknowledgement must not be taken for the next attempt
+		once.Do(func() {})
+		return false
+	}
+}
@@ -21,26 +21,37 @@ package events
 import (
 	"encoding/json"
 
-	group "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
-	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	tx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 )
 
-// ShareCreated is emitted when a share is created.
-type ShareCreated struct { // TODO: Rename to ShareCreatedEvent?
-	Sharer *user.UserId
-	// split the protobuf Grantee oneof so we can use stdlib encoding/json
-	GranteeUserID  *user.UserId
-	GranteeGroupID *group.GroupId
-	Sharee         *provider.Grantee
-	ItemID         *provider.ResourceId
-	CTime          *types.Timestamp
+// TransferCompleted is emitted when a data transfer has completed.
+type TransferCompleted struct {
+	Executant *user.UserId
+	ID        *tx.TxId
+	Timestamp *types.Timestamp
 }
 
 // Unmarshal to fulfill umarshaller interface.
-func (ShareCreated) Unmarshal(v []byte) (interface{}, error) {
-	e := ShareCreated{}
+func (TransferCompleted) Unmarshal(v []byte) (interface{}, error) {
+	e := TransferCompleted{}
+	err := json.Unmarshal(v, &e)
+	return e, err
+}
+
+// TransferFailed is emitted when a data transfer has failed.
+type TransferFailed struct {
+	Executant *user.UserId
+	ID        *tx.TxId
+	Status    tx.Status
+	Error     string
+	Timestamp *types.Timestamp
+}
+
+// Unmarshal to fulfill umarshaller interface.
+func (TransferFailed) Unmarshal(v []byte) (interface{}, error) {
+	e := TransferFailed{}
 	err := json.Unmarshal(v, &e)
 	return e, err
 }
@@ -0,0 +1,68 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+// Handler serves the liveness probe under /healthz and the readiness probe under /readyz.
+func Handler(log *zerolog.Logger) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", LivenessHandler())
+	mux.Handle("/readyz", ReadinessHandler(log))
+	return mux
+}
+
+// LivenessHandler answers as long as the process serves requests.
+func LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "alive"})
+	})
+}
+
+// ReadinessHandler answers 200 OK when the registered checks pass, and 503 Service
+// Unavailable otherwise. The failed checks are logged rather than returned, as they
+// may disclose details about the backends of the process.
+func ReadinessHandler(log *zerolog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := Cached(r.Context(), "readiness", func(ctx context.Context) error {
+			err := Check(ctx)
+			if err != nil {
+				log.Warn().Err(err).Msg("health: not ready")
+			}
+			return err
+		})
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "not ready"})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ready"})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
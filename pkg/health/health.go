@@ -0,0 +1,192 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package health lets the services, and the drivers they rely on, report
+// whether they are ready to serve requests, e.g. whether their database
+// is reachable, and aggregates these reports for the probes.
+package health
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CheckTimeout is the time given to a check before it is considered failed.
+var CheckTimeout = 5 * time.Second
+
+// CacheTTL is how long the result of the checks run by the probes is reused,
+// so that frequent probes do not hit the databases and the other backends.
+var CacheTTL = 10 * time.Second
+
+// Checker is implemented by the services and the drivers
+// which can tell whether they are ready to serve requests.
+type Checker interface {
+	Ready(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function into a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+// Ready calls f.
+func (f CheckerFunc) Ready(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Ready returns the readiness of v if it implements Checker,
+// v being ready otherwise.
+func Ready(ctx context.Context, v interface{}) error {
+	if c, ok := v.(Checker); ok {
+		return c.Ready(ctx)
+	}
+	return nil
+}
+
+// Writable tells whether files can be created in the folder.
+func Writable(folder string) error {
+	f, err := os.CreateTemp(folder, ".readiness-*")
+	if err != nil {
+		return errors.Wrap(err, "folder not writable")
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Named is a named checker.
+type Named struct {
+	Name    string
+	Checker interface{}
+}
+
+// All runs the checks in parallel, and returns the errors of the
+// checks which failed, prefixed with their names.
+func All(ctx context.Context, checkers []Named) error {
+	errs := checkAll(ctx, checkers)
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, errors.Wrap(err, checkers[i].Name).Error())
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return &Error{Failures: failed}
+	}
+	return nil
+}
+
+// checkAll runs the checks in parallel, returning their results in the same order.
+func checkAll(ctx context.Context, checkers []Named) []error {
+	ctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+	defer cancel()
+
+	errs := make([]error, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c Named) {
+			defer wg.Done()
+			errs[i] = Ready(ctx, c.Checker)
+		}(i, c)
+	}
+	wg.Wait()
+	return errs
+}
+
+// Error is the error of the checks which failed.
+type Error struct {
+	Failures []string
+}
+
+func (e *Error) Error() string {
+	msg := e.Failures[0]
+	for _, f := range e.Failures[1:] {
+		msg += "; " + f
+	}
+	return msg
+}
+
+var (
+	mu       sync.RWMutex
+	checkers = map[string]Checker{}
+)
+
+// Register registers the checker of a component of the process, such as
+// a grpc or an http server, replacing the one registered under the same name.
+func Register(name string, c Checker) {
+	mu.Lock()
+	defer mu.Unlock()
+	checkers[name] = c
+}
+
+// Unregister removes the checker registered under the name.
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(checkers, name)
+}
+
+// Check runs the registered checks, returning the errors
+// of the ones which failed, prefixed with their names.
+func Check(ctx context.Context) error {
+	mu.RLock()
+	named := make([]Named, 0, len(checkers))
+	for name, c := range checkers {
+		named = append(named, Named{Name: name, Checker: c})
+	}
+	mu.RUnlock()
+
+	return All(ctx, named)
+}
+
+type result struct {
+	sync.Mutex
+	err error
+	at  time.Time
+}
+
+var (
+	resultsMu sync.Mutex
+	results   = map[string]*result{}
+)
+
+// Cached returns the result of the check last run under the key if it is
+// not older than CacheTTL, and runs the check otherwise. The concurrent
+// callers wait for the same run.
+func Cached(ctx context.Context, key string, check func(ctx context.Context) error) error {
+	resultsMu.Lock()
+	r, ok := results[key]
+	if !ok {
+		r = &result{}
+		results[key] = r
+	}
+	resultsMu.Unlock()
+
+	r.Lock()
+	defer r.Unlock()
+	if !r.at.IsZero() && time.Since(r.at) < CacheTTL {
+		return r.err
+	}
+	r.err, r.at = check(ctx), time.Now()
+	return r.err
+}
@@ -0,0 +1,114 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func TestAll(t *testing.T) {
+	ready := CheckerFunc(func(context.Context) error { return nil })
+	down := CheckerFunc(func(context.Context) error { return errors.New("down") })
+	slow := CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	timeout := CheckTimeout
+	CheckTimeout = 10 * time.Millisecond
+	defer func() { CheckTimeout = timeout }()
+
+	if err := All(context.Background(), []Named{{"ready", ready}, {"not a checker", struct{}{}}, {"nil", nil}}); err != nil {
+		t.Fatal(err)
+	}
+
+	err := All(context.Background(), []Named{{"slow", slow}, {"ready", ready}, {"db", down}})
+	e, ok := err.(*Error)
+	if !ok {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if len(e.Failures) != 2 || e.Failures[0] != "db: down" || e.Failures[1] != "slow: context deadline exceeded" {
+		t.Fatalf("unexpected failures %q", e.Failures)
+	}
+}
+
+func TestReadinessHandler(t *testing.T) {
+	var err error
+	var calls int
+	Register("test", CheckerFunc(func(context.Context) error {
+		calls++
+		return err
+	}))
+	defer Unregister("test")
+
+	ttl := CacheTTL
+	defer func() { CacheTTL = ttl }()
+
+	log := zerolog.Nop()
+	get := func() (int, string) {
+		w := httptest.NewRecorder()
+		Handler(&log).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code, w.Body.String()
+	}
+
+	CacheTTL = 0
+	if code, body := get(); code != http.StatusOK || !strings.Contains(body, `"ready"`) {
+		t.Fatalf("got %d %s", code, body)
+	}
+
+	err = errors.New("database unreachable")
+	code, body := get()
+	if code != http.StatusServiceUnavailable || strings.Contains(body, "database") {
+		t.Fatalf("got %d %s", code, body)
+	}
+
+	// the result is reused while it is fresh
+	CacheTTL = time.Hour
+	err = nil
+	if code, _ := get(); code != http.StatusServiceUnavailable || calls != 2 {
+		t.Fatalf("expected the cached result, got %d after %d checks", code, calls)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := Writable(dir); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("the probe file was not removed")
+	}
+	if err := Writable(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected a missing folder not to be writable")
+	}
+}
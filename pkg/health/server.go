@@ -0,0 +1,75 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// Server serves the probes on a listener of its own, apart from the
+// http services, so that they are only reachable internally.
+type Server struct {
+	address string
+	srv     *http.Server
+}
+
+// NewServer returns a server serving the probes at the address.
+func NewServer(address string, log zerolog.Logger) *Server {
+	return &Server{
+		address: address,
+		srv: &http.Server{
+			Handler:           Handler(&log),
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// Start serves the probes on the listener.
+func (s *Server) Start(ln net.Listener) error {
+	if err := s.srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return errors.Wrap(err, "health: serve failed")
+	}
+	return nil
+}
+
+// Stop stops the server.
+func (s *Server) Stop() error {
+	return s.srv.Close()
+}
+
+// GracefulStop gracefully stops the server.
+func (s *Server) GracefulStop() error {
+	return s.srv.Shutdown(context.Background())
+}
+
+// Network returns the network type.
+func (s *Server) Network() string {
+	return "tcp"
+}
+
+// Address returns the network address.
+func (s *Server) Address() string {
+	return s.address
+}
@@ -0,0 +1,303 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package maintenance keeps the read-only modes of the process. A mode blocks
+// the writes to the whole server or to chosen storages, spaces or paths, and
+// can be toggled at runtime, for instance while migrating a storage backend.
+// The modes only live in the process where they were set: the replicas and
+// the other revad processes keep their own.
+package maintenance
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	rstatus "github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/utils"
+)
+
+const (
+	defaultMessage    = "the storage is under maintenance"
+	defaultRetryAfter = 300
+)
+
+// Config is the configuration of a read-only mode.
+type Config struct {
+	Disabled     bool     `mapstructure:"disabled" docs:"false;Whether the mode starts disabled, to be enabled at runtime through the maintenance service."`
+	StorageIDs   []string `mapstructure:"storage_ids" docs:";The storage providers made read-only. All of them when no storage, space or path is given."`
+	SpaceIDs     []string `mapstructure:"space_ids" docs:";The spaces made read-only."`
+	Paths        []string `mapstructure:"paths" docs:";The paths made read-only with everything below them. The references by id are matched by the path the gateway resolves them to."`
+	ExemptUsers  []string `mapstructure:"exempt_users" docs:";The usernames still allowed to write."`
+	ExemptGroups []string `mapstructure:"exempt_groups" docs:";The groups whose members are still allowed to write. The groups missing from the token are looked up through the gateway."`
+	Maintenance  bool     `mapstructure:"maintenance" docs:"false;Whether the blocked writes are reported as a temporary maintenance, which ocdav and ocs answer with 503, rather than as permission denied."`
+	Message      string   `mapstructure:"message" docs:"the storage is under maintenance;The message returned for the blocked writes during a maintenance."`
+	RetryAfter   int      `mapstructure:"retry_after" docs:"300;The seconds the clients are asked to wait before retrying during a maintenance."`
+}
+
+func (c *Config) init() {
+	if c.Message == "" {
+		c.Message = defaultMessage
+	}
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = defaultRetryAfter
+	}
+}
+
+// Resolver looks up what the requests may lack to be matched by a mode:
+// the path of the resources referenced by id and the groups of the users
+// whose token does not carry them.
+type Resolver interface {
+	GetPath(ctx context.Context, id *provider.ResourceId) (string, error)
+	GetUserGroups(ctx context.Context, u *userpb.User) ([]string, error)
+}
+
+// State is the part of a mode that can be changed at runtime.
+type State struct {
+	Enabled     bool   `json:"enabled"`
+	Maintenance bool   `json:"maintenance"`
+	Message     string `json:"message"`
+	RetryAfter  int    `json:"retry_after"`
+}
+
+// Mode is a read-only mode.
+type Mode struct {
+	name     string
+	conf     *Config
+	resolver Resolver
+
+	mu    sync.RWMutex
+	state State
+}
+
+var (
+	mu    sync.Mutex
+	modes = map[string]*Mode{}
+)
+
+// Register returns the mode with the given name, creating it from the
+// configuration if needed. The interceptors configured with the same
+// name share their mode, as configured by the first one. Without a
+// resolver the references by id are not matched against the paths and
+// only the groups in the token are checked.
+func Register(name string, c *Config, r Resolver) *Mode {
+	mu.Lock()
+	defer mu.Unlock()
+	if m, ok := modes[name]; ok {
+		return m
+	}
+	c.init()
+	m := &Mode{
+		name:     name,
+		conf:     c,
+		resolver: r,
+		state: State{
+			Enabled:     !c.Disabled,
+			Maintenance: c.Maintenance,
+			Message:     c.Message,
+			RetryAfter:  c.RetryAfter,
+		},
+	}
+	modes[name] = m
+	return m
+}
+
+// Get returns the mode with the given name.
+func Get(name string) (*Mode, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	m, ok := modes[name]
+	return m, ok
+}
+
+// List returns the modes of the process sorted by name.
+func List() []*Mode {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]*Mode, 0, len(modes))
+	for _, m := range modes {
+		list = append(list, m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// Name returns the name of the mode.
+func (m *Mode) Name() string {
+	return m.name
+}
+
+// State returns the current state of the mode.
+func (m *Mode) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// SetState changes the state of the mode.
+func (m *Mode) SetState(s State) {
+	if s.Message == "" {
+		s.Message = defaultMessage
+	}
+	if s.RetryAfter <= 0 {
+		s.RetryAfter = defaultRetryAfter
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = s
+}
+
+// scoped tells whether the mode only applies to some storages, spaces or paths.
+func (m *Mode) scoped() bool {
+	return len(m.conf.StorageIDs) > 0 || len(m.conf.SpaceIDs) > 0 || len(m.conf.Paths) > 0
+}
+
+func (m *Mode) exempt(ctx context.Context) bool {
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		return false
+	}
+	for _, e := range m.conf.ExemptUsers {
+		if u.Username == e {
+			return true
+		}
+	}
+	if len(m.conf.ExemptGroups) == 0 {
+		return false
+	}
+	groups := u.Groups
+	if len(groups) == 0 && m.resolver != nil {
+		var err error
+		if groups, err = m.resolver.GetUserGroups(ctx, u); err != nil {
+			appctx.GetLogger(ctx).Error().Err(err).Str("mode", m.name).Msg("maintenance: error getting the groups of the user")
+		}
+	}
+	for _, g := range groups {
+		for _, e := range m.conf.ExemptGroups {
+			if g == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *Mode) matchesID(id *provider.ResourceId) bool {
+	for _, sid := range m.conf.StorageIDs {
+		if id.GetStorageId() == sid {
+			return true
+		}
+	}
+	for _, sid := range m.conf.SpaceIDs {
+		if id.GetSpaceId() == sid {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mode) matchesPath(p string) bool {
+	if !strings.HasPrefix(p, "/") {
+		return false
+	}
+	for _, mp := range m.conf.Paths {
+		mp = strings.TrimSuffix(mp, "/")
+		if p == mp || strings.HasPrefix(p, mp+"/") || mp == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mode) matches(ctx context.Context, ref *provider.Reference) bool {
+	if ref == nil {
+		return false
+	}
+	if m.matchesID(ref.ResourceId) {
+		return true
+	}
+	if len(m.conf.Paths) == 0 {
+		return false
+	}
+	if utils.IsAbsolutePathReference(ref) {
+		return m.matchesPath(ref.Path)
+	}
+	if ref.ResourceId == nil || m.resolver == nil {
+		return false
+	}
+	p, err := m.resolver.GetPath(ctx, ref.ResourceId)
+	if err != nil {
+		// the write could be below one of the paths, block it
+		appctx.GetLogger(ctx).Error().Err(err).Str("mode", m.name).Interface("ref", ref).Msg("maintenance: error resolving the path of the reference")
+		return true
+	}
+	return m.matchesPath(path.Join(p, ref.Path))
+}
+
+// Blocks tells whether the writes of the user in the context to the references
+// are blocked. A request without references is only blocked when the mode
+// applies to the whole server.
+func (m *Mode) Blocks(ctx context.Context, refs ...*provider.Reference) bool {
+	if !m.State().Enabled {
+		return false
+	}
+	if m.scoped() {
+		matched := false
+		for _, ref := range refs {
+			if matched = m.matches(ctx, ref); matched {
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return !m.exempt(ctx)
+}
+
+// BlocksResource tells whether the writes of the user in the context to the
+// resource are blocked. Unlike Blocks, it does not resolve the path of the
+// resource, taken from its info, so it can be used while serving the lookups.
+func (m *Mode) BlocksResource(ctx context.Context, info *provider.ResourceInfo) bool {
+	if !m.State().Enabled {
+		return false
+	}
+	if m.scoped() && !m.matchesID(info.GetId()) && !m.matchesPath(info.GetPath()) {
+		return false
+	}
+	return !m.exempt(ctx)
+}
+
+// Status returns the status of a blocked write, denied with the given
+// message or reported as a maintenance. During a maintenance the seconds
+// to wait are sent to the client in the retry-after header of the call.
+func (m *Mode) Status(ctx context.Context, msg string) *rpc.Status {
+	s := m.State()
+	if s.Maintenance {
+		SetRetryAfter(ctx, s.RetryAfter)
+		return rstatus.NewUnavailable(ctx, s.Message)
+	}
+	return rstatus.NewPermissionDenied(ctx, nil, msg)
+}
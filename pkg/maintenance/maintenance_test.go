@@ -0,0 +1,149 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+)
+
+func TestBlocks(t *testing.T) {
+	m := Register("test-blocks", &Config{
+		StorageIDs:   []string{"eos-old"},
+		SpaceIDs:     []string{"space-1"},
+		Paths:        []string{"/eos/project/"},
+		ExemptUsers:  []string{"admin"},
+		ExemptGroups: []string{"migration"},
+	}, nil)
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Username: "einstein", Groups: []string{"physics"}})
+
+	tests := []struct {
+		ref    *provider.Reference
+		blocks bool
+	}{
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos-old", OpaqueId: "1"}}, true},
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos-new", SpaceId: "space-1"}, Path: "./a"}, true},
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos-new", SpaceId: "space-2"}}, false},
+		{&provider.Reference{Path: "/eos/project"}, true},
+		{&provider.Reference{Path: "/eos/project/a/b"}, true},
+		{&provider.Reference{Path: "/eos/projects"}, false},
+		{&provider.Reference{Path: "/eos/user/e/einstein"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := m.Blocks(ctx, tt.ref); got != tt.blocks {
+			t.Errorf("Blocks(%v) = %v, expected %v", tt.ref, got, tt.blocks)
+		}
+	}
+	if m.Blocks(ctx) {
+		t.Error("expected the requests without references to be allowed by a scoped mode")
+	}
+
+	blocked := &provider.Reference{Path: "/eos/project/a"}
+	for _, u := range []*userpb.User{{Username: "admin"}, {Username: "marie", Groups: []string{"migration"}}} {
+		if m.Blocks(ctxpkg.ContextSetUser(context.Background(), u), blocked) {
+			t.Errorf("expected %s to be exempted", u.Username)
+		}
+	}
+
+	m.SetState(State{Enabled: false})
+	if m.Blocks(ctx, blocked) {
+		t.Error("expected a disabled mode to block nothing")
+	}
+}
+
+func TestWholeServer(t *testing.T) {
+	m := Register("test-whole", &Config{}, nil)
+	if !m.Blocks(context.Background()) || !m.Blocks(context.Background(), &provider.Reference{Path: "/any"}) {
+		t.Fatal("expected a mode without storages, spaces or paths to block everything")
+	}
+	if again := Register("test-whole", &Config{Disabled: true}, nil); again != m {
+		t.Fatal("expected the modes with the same name to be shared")
+	}
+	if s := m.Status(context.Background(), "permission denied"); s.Code != rpc.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected permission denied, got %v", s.Code)
+	}
+
+	m.SetState(State{Enabled: true, Maintenance: true, Message: "migrating", RetryAfter: 60})
+	if s := m.Status(context.Background(), "permission denied"); s.Code != rpc.Code_CODE_UNAVAILABLE || s.Message != "migrating" {
+		t.Fatalf("unexpected maintenance status %v %q", s.Code, s.Message)
+	}
+}
+
+type resolver struct {
+	paths  map[string]string
+	groups map[string][]string
+}
+
+func (r *resolver) GetPath(ctx context.Context, id *provider.ResourceId) (string, error) {
+	if p, ok := r.paths[id.OpaqueId]; ok {
+		return p, nil
+	}
+	return "", errors.New("not found")
+}
+
+func (r *resolver) GetUserGroups(ctx context.Context, u *userpb.User) ([]string, error) {
+	return r.groups[u.Id.OpaqueId], nil
+}
+
+func TestResolver(t *testing.T) {
+	m := Register("test-resolver", &Config{
+		Paths:        []string{"/eos/project"},
+		ExemptGroups: []string{"migration"},
+	}, &resolver{
+		paths:  map[string]string{"project": "/eos/project/a", "user": "/eos/user/e/einstein"},
+		groups: map[string][]string{"marie": {"migration"}},
+	})
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}, Username: "einstein"})
+
+	tests := []struct {
+		ref    *provider.Reference
+		blocks bool
+	}{
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos", OpaqueId: "project"}}, true},
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos", OpaqueId: "project"}, Path: "./b"}, true},
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos", OpaqueId: "user"}, Path: "./b"}, false},
+		{&provider.Reference{ResourceId: &provider.ResourceId{StorageId: "eos", OpaqueId: "unknown"}}, true},
+	}
+	for _, tt := range tests {
+		if got := m.Blocks(ctx, tt.ref); got != tt.blocks {
+			t.Errorf("Blocks(%v) = %v, expected %v", tt.ref, got, tt.blocks)
+		}
+	}
+
+	marie := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "marie"}, Username: "marie"})
+	if m.Blocks(marie, tests[0].ref) {
+		t.Error("expected the groups missing from the token to be looked up")
+	}
+
+	info := &provider.ResourceInfo{Id: &provider.ResourceId{StorageId: "eos", OpaqueId: "unknown"}, Path: "/eos/project/c"}
+	if !m.BlocksResource(ctx, info) {
+		t.Error("expected the resource to be matched by its path")
+	}
+	info.Path = "/eos/user/e/einstein"
+	if m.BlocksResource(ctx, info) {
+		t.Error("expected the resource not to be resolved")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package maintenance
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// HeaderRetryAfter is the grpc header carrying, during a maintenance,
+// the seconds the clients should wait before retrying.
+const HeaderRetryAfter = "retry-after"
+
+type retryAfterKey struct{}
+
+// retryAfter keeps the seconds to wait received while serving an http request.
+type retryAfter struct {
+	seconds atomic.Int64
+}
+
+// SetRetryAfter sends the seconds to wait in the header of the grpc call
+// served with the context, if any, and keeps them for the http response
+// when the request is served through Handler.
+func SetRetryAfter(ctx context.Context, seconds int) {
+	// the context may not belong to a grpc call
+	_ = grpc.SetHeader(ctx, metadata.Pairs(HeaderRetryAfter, strconv.Itoa(seconds)))
+	if ra, ok := ctx.Value(retryAfterKey{}).(*retryAfter); ok {
+		ra.seconds.Store(int64(seconds))
+	}
+}
+
+// UnaryClientInterceptor forwards the seconds to wait received from the
+// upstream services, so that the gateway passes them on to the http services.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		var md metadata.MD
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Header(&md))...)
+		if v := md.Get(HeaderRetryAfter); len(v) > 0 {
+			if seconds, convErr := strconv.Atoi(v[0]); convErr == nil && seconds > 0 {
+				SetRetryAfter(ctx, seconds)
+			}
+		}
+		return err
+	}
+}
+
+// Handler wraps an http service calling the grpc services, adding the
+// Retry-After header to its 503 responses when the services asked to wait.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ra := &retryAfter{}
+		ctx := context.WithValue(r.Context(), retryAfterKey{}, ra)
+		h.ServeHTTP(&responseWriter{ResponseWriter: w, retryAfter: ra}, r.WithContext(ctx))
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	retryAfter *retryAfter
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if code == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		if seconds := w.retryAfter.seconds.Load(); seconds > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,63 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package maintenance
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRetryAfter(t *testing.T) {
+	// an upstream service answering during a maintenance
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		for _, o := range opts {
+			if h, ok := o.(grpc.HeaderCallOption); ok {
+				*h.HeaderAddr = metadata.Pairs(HeaderRetryAfter, "600")
+			}
+		}
+		return nil
+	}
+
+	w := httptest.NewRecorder()
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := UnaryClientInterceptor()(r.Context(), "/cs3.gateway.v1beta1.GatewayAPI/Delete", nil, nil, nil, invoker); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/file", nil))
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "600" {
+		t.Fatalf("expected a 503 with Retry-After 600, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	w = httptest.NewRecorder()
+	h = Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/file", nil))
+	if h := w.Header().Get("Retry-After"); h != "" {
+		t.Fatalf("expected no Retry-After without a maintenance, got %q", h)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	"github.com/pkg/errors"
+)
+
+const tokenTableColumns = `
+	token VARCHAR(255) NOT NULL PRIMARY KEY,
+	initiator_idp VARCHAR(255) NOT NULL,
+	initiator_opaque_id VARCHAR(255) NOT NULL,
+	initiator_type INTEGER NOT NULL DEFAULT 0,
+	description TEXT NOT NULL,
+	expiration BIGINT NOT NULL`
+
+// accepted users are keyed by the opaque id of the user who generated the token, like in the json driver.
+const acceptedUserTableColumns = `
+	initiator VARCHAR(255) NOT NULL,
+	idp VARCHAR(255) NOT NULL,
+	opaque_id VARCHAR(255) NOT NULL,
+	type INTEGER NOT NULL DEFAULT 0,
+	username VARCHAR(255) NOT NULL DEFAULT '',
+	mail VARCHAR(255) NOT NULL DEFAULT '',
+	display_name VARCHAR(255) NOT NULL DEFAULT '',
+	PRIMARY KEY (initiator, idp, opaque_id)`
+
+// schema holds the statements creating the tables and their indexes for every supported engine.
+var schema = map[string][]string{
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS ocm_invite_tokens (` + tokenTableColumns + `,
+	INDEX ocm_invite_tokens_initiator (initiator_idp, initiator_opaque_id))`,
+		`CREATE TABLE IF NOT EXISTS ocm_accepted_users (` + acceptedUserTableColumns + `,
+	INDEX ocm_accepted_users_user (idp, opaque_id))`,
+	},
+	"sqlite3": {
+		`CREATE TABLE IF NOT EXISTS ocm_invite_tokens (` + tokenTableColumns + `)`,
+		`CREATE INDEX IF NOT EXISTS ocm_invite_tokens_initiator ON ocm_invite_tokens (initiator_idp, initiator_opaque_id)`,
+		`CREATE TABLE IF NOT EXISTS ocm_accepted_users (` + acceptedUserTableColumns + `)`,
+		`CREATE INDEX IF NOT EXISTS ocm_accepted_users_user ON ocm_accepted_users (idp, opaque_id)`,
+	},
+}
+
+func createTables(ctx context.Context, engine string, db *sql.DB) error {
+	stmts, ok := schema[engine]
+	if !ok {
+		return errors.New("sql: unsupported db engine " + engine)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSON copies the tokens and accepted users stored in the file of the json invite manager
+// to the database configured in m. Entries which already exist in the database are skipped,
+// so an interrupted import can be run again. It returns the number of imported entries.
+func ImportJSON(ctx context.Context, m map[string]interface{}, file string) (int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return 0, err
+	}
+	db, err := open(c)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := createTables(ctx, c.DBEngine, db); err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "sql: error reading the json invites")
+	}
+	model := struct {
+		Invites       map[string]*invitepb.InviteToken `json:"invites"`
+		AcceptedUsers map[string][]*userpb.User        `json:"accepted_users"`
+	}{}
+	if err := json.Unmarshal(data, &model); err != nil {
+		return 0, errors.Wrap(err, "sql: error decoding the json invites")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var n int
+	for tkn, t := range model.Invites {
+		var count int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ocm_invite_tokens WHERE token=?", tkn).Scan(&count); err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := insertToken(ctx, tx, t); err != nil {
+			return 0, errors.Wrap(err, "sql: error importing token "+tkn)
+		}
+		n++
+	}
+	for initiator, users := range model.AcceptedUsers {
+		for _, u := range users {
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ocm_accepted_users WHERE initiator=? AND idp=? AND opaque_id=?",
+				initiator, u.Id.GetIdp(), u.Id.GetOpaqueId()).Scan(&count); err != nil {
+				return 0, err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := insertAcceptedUser(ctx, tx, initiator, u); err != nil {
+				return 0, errors.Wrap(err, "sql: error importing accepted user "+u.Id.GetOpaqueId())
+			}
+			n++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,341 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/ocm/invite"
+	"github.com/cs3org/reva/pkg/ocm/invite/manager/registry"
+	"github.com/cs3org/reva/pkg/ocm/invite/token"
+	"github.com/cs3org/reva/pkg/rhttp"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	// Provides sqlite drivers.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const acceptInviteEndpoint = "invites/accept"
+
+const userColumns = "idp, opaque_id, type, username, mail, display_name"
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type config struct {
+	DBEngine            string `mapstructure:"db_engine"`
+	DBFile              string `mapstructure:"db_file"`
+	DBUsername          string `mapstructure:"db_username"`
+	DBPassword          string `mapstructure:"db_password"`
+	DBHost              string `mapstructure:"db_host"`
+	DBPort              int    `mapstructure:"db_port"`
+	DBName              string `mapstructure:"db_name"`
+	Expiration          string `mapstructure:"expiration"`
+	InsecureConnections bool   `mapstructure:"insecure_connections"`
+}
+
+func (c *config) init() {
+	if c.DBEngine == "" {
+		c.DBEngine = "mysql"
+	}
+	if c.DBFile == "" {
+		c.DBFile = "/var/tmp/reva/ocm-invites.db"
+	}
+	if c.Expiration == "" {
+		c.Expiration = token.DefaultExpirationTime
+	}
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, err
+	}
+	c.init()
+	return c, nil
+}
+
+type manager struct {
+	config *config
+	db     *sql.DB
+	client *http.Client
+}
+
+// New returns a new invite manager storing the tokens and the accepted users in a mysql or sqlite database.
+func New(m map[string]interface{}) (invite.Manager, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		err = errors.Wrap(err, "error creating a new manager")
+		return nil, err
+	}
+
+	db, err := open(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := createTables(context.Background(), c.DBEngine, db); err != nil {
+		return nil, errors.Wrap(err, "sql: error creating the invite tables")
+	}
+
+	return &manager{
+		config: c,
+		db:     db,
+		client: rhttp.GetHTTPClient(
+			rhttp.Timeout(5*time.Second),
+			rhttp.Insecure(c.InsecureConnections),
+		),
+	}, nil
+}
+
+func open(c *config) (*sql.DB, error) {
+	switch c.DBEngine {
+	case "mysql":
+		return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	case "sqlite3":
+		return sql.Open("sqlite3", c.DBFile)
+	default:
+		return nil, errtypes.NotSupported("sql: unsupported db engine " + c.DBEngine)
+	}
+}
+
+// Ready tells whether the database is reachable.
+func (m *manager) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *manager) GenerateToken(ctx context.Context) (*invitepb.InviteToken, error) {
+	contexUser := ctxpkg.ContextMustGetUser(ctx)
+	inviteToken, err := token.CreateToken(m.config.Expiration, contexUser.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertToken(ctx, m.db, inviteToken); err != nil {
+		return nil, errors.Wrap(err, "sql: error storing token")
+	}
+	return inviteToken, nil
+}
+
+func (m *manager) ForwardInvite(ctx context.Context, invite *invitepb.InviteToken, originProvider *ocmprovider.ProviderInfo) error {
+	contextUser := ctxpkg.ContextMustGetUser(ctx)
+	recipientProvider := contextUser.GetId().GetIdp()
+
+	requestBody := url.Values{
+		"token":             {invite.GetToken()},
+		"userID":            {contextUser.GetId().GetOpaqueId()},
+		"recipientProvider": {recipientProvider},
+		"email":             {contextUser.GetMail()},
+		"name":              {contextUser.GetDisplayName()},
+	}
+
+	ocmEndpoint, err := getOCMEndpoint(originProvider)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(ocmEndpoint)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, acceptInviteEndpoint)
+	recipientURL := u.String()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recipientURL, strings.NewReader(requestBody.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "sql: error framing post request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "sql: error sending post request")
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, e := io.ReadAll(resp.Body)
+		if e != nil {
+			return errors.Wrap(e, "sql: error reading request body")
+		}
+		return errors.Wrap(fmt.Errorf("%s: %s", resp.Status, string(respBody)), "sql: error sending accept post request")
+	}
+
+	return nil
+}
+
+func (m *manager) AcceptInvite(ctx context.Context, invite *invitepb.InviteToken, remoteUser *userpb.User) error {
+	// The token is checked and the user added in one transaction, so that
+	// concurrent acceptances of the same token cannot add the user twice.
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	inviteToken, err := getToken(ctx, tx, invite.GetToken())
+	if err != nil {
+		return err
+	}
+	if uint64(time.Now().Unix()) > inviteToken.Expiration.Seconds {
+		return errors.New("sql: token expired")
+	}
+
+	currUser := inviteToken.GetUserId()
+
+	// do not allow the user who created the token to accept it
+	if remoteUser.Id.Idp == currUser.Idp && remoteUser.Id.OpaqueId == currUser.OpaqueId {
+		return errors.New("sql: token creator and recipient are the same")
+	}
+
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ocm_accepted_users WHERE initiator=? AND idp=? AND opaque_id=?",
+		currUser.GetOpaqueId(), remoteUser.Id.GetIdp(), remoteUser.Id.GetOpaqueId()).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return errors.New("sql: user already added to accepted users")
+	}
+
+	if err := insertAcceptedUser(ctx, tx, currUser.GetOpaqueId(), remoteUser); err != nil {
+		return errors.Wrap(err, "sql: error storing accepted user")
+	}
+	return tx.Commit()
+}
+
+func (m *manager) GetAcceptedUser(ctx context.Context, remoteUserID *userpb.UserId) (*userpb.User, error) {
+	userKey := ctxpkg.ContextMustGetUser(ctx).GetId().GetOpaqueId()
+	query := "SELECT " + userColumns + " FROM ocm_accepted_users WHERE initiator=? AND opaque_id=? AND (?='' OR idp=?)"
+	u, err := scanUser(m.db.QueryRowContext(ctx, query, userKey, remoteUserID.OpaqueId, remoteUserID.Idp, remoteUserID.Idp))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errtypes.NotFound(remoteUserID.OpaqueId)
+		}
+		return nil, err
+	}
+	return u, nil
+}
+
+func (m *manager) FindAcceptedUsers(ctx context.Context, query string) ([]*userpb.User, error) {
+	userKey := ctxpkg.ContextMustGetUser(ctx).GetId().GetOpaqueId()
+	q := "SELECT " + userColumns + " FROM ocm_accepted_users WHERE initiator=?"
+	params := []interface{}{userKey}
+	if query != "" {
+		q += " AND (LOWER(username) LIKE ? OR LOWER(display_name) LIKE ? OR LOWER(mail) LIKE ? OR LOWER(opaque_id) LIKE ?)"
+		like := "%" + strings.ToLower(query) + "%"
+		params = append(params, like, like, like, like)
+	}
+
+	rows, err := m.db.QueryContext(ctx, q, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*userpb.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// execer is implemented by both sql.DB and sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func getToken(ctx context.Context, tx *sql.Tx, tkn string) (*invitepb.InviteToken, error) {
+	var idp, opaqueID, description string
+	var userType int
+	var expiration int64
+	err := tx.QueryRowContext(ctx, "SELECT initiator_idp, initiator_opaque_id, initiator_type, description, expiration FROM ocm_invite_tokens WHERE token=?", tkn).
+		Scan(&idp, &opaqueID, &userType, &description, &expiration)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("sql: invalid token")
+		}
+		return nil, err
+	}
+	return &invitepb.InviteToken{
+		Token:       tkn,
+		UserId:      &userpb.UserId{Idp: idp, OpaqueId: opaqueID, Type: userpb.UserType(userType)},
+		Expiration:  &typespb.Timestamp{Seconds: uint64(expiration)},
+		Description: description,
+	}, nil
+}
+
+func insertToken(ctx context.Context, e execer, t *invitepb.InviteToken) error {
+	_, err := e.ExecContext(ctx, "INSERT INTO ocm_invite_tokens (token, initiator_idp, initiator_opaque_id, initiator_type, description, expiration) VALUES (?, ?, ?, ?, ?, ?)",
+		t.Token, t.UserId.GetIdp(), t.UserId.GetOpaqueId(), int(t.UserId.GetType()), t.Description, int64(t.Expiration.GetSeconds()))
+	return err
+}
+
+func insertAcceptedUser(ctx context.Context, e execer, initiator string, u *userpb.User) error {
+	_, err := e.ExecContext(ctx, "INSERT INTO ocm_accepted_users (initiator, "+userColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		initiator, u.Id.GetIdp(), u.Id.GetOpaqueId(), int(u.Id.GetType()), u.Username, u.Mail, u.DisplayName)
+	return err
+}
+
+func scanUser(s scanner) (*userpb.User, error) {
+	var idp, opaqueID, username, mail, displayName string
+	var userType int
+	if err := s.Scan(&idp, &opaqueID, &userType, &username, &mail, &displayName); err != nil {
+		return nil, err
+	}
+	return &userpb.User{
+		Id:          &userpb.UserId{Idp: idp, OpaqueId: opaqueID, Type: userpb.UserType(userType)},
+		Username:    username,
+		Mail:        mail,
+		DisplayName: displayName,
+	}, nil
+}
+
+func getOCMEndpoint(originProvider *ocmprovider.ProviderInfo) (string, error) {
+	for _, s := range originProvider.Services {
+		if s.Endpoint.Type.Name == "OCM" {
+			return s.Endpoint.Path, nil
+		}
+	}
+	return "", errors.New("sql: ocm endpoint not specified for mesh provider")
+}
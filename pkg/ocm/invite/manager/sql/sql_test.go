@@ -0,0 +1,120 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/ocm/invite"
+	sqlmanager "github.com/cs3org/reva/pkg/ocm/invite/manager/sql"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL manager", func() {
+	var (
+		mgr  invite.Manager
+		dir  string
+		conf map[string]interface{}
+		ctx  context.Context
+
+		einstein = &userpb.User{Id: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein"}}
+		marie    = &userpb.User{
+			Id:          &userpb.UserId{Idp: "cesnet.cz", OpaqueId: "marie", Type: userpb.UserType_USER_TYPE_FEDERATED},
+			Username:    "marie",
+			Mail:        "marie@cesnet.cz",
+			DisplayName: "Marie Curie",
+		}
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "reva-unit-tests-*")
+		Expect(err).ToNot(HaveOccurred())
+
+		conf = map[string]interface{}{"db_engine": "sqlite3", "db_file": filepath.Join(dir, "invites.db")}
+		mgr, err = sqlmanager.New(conf)
+		Expect(err).ToNot(HaveOccurred())
+
+		ctx = ctxpkg.ContextSetUser(context.Background(), einstein)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("accepts an invite once", func() {
+		tkn, err := mgr.GenerateToken(ctx)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(mgr.AcceptInvite(ctx, tkn, marie)).To(Succeed())
+		Expect(mgr.AcceptInvite(ctx, tkn, marie)).ToNot(Succeed())
+
+		u, err := mgr.GetAcceptedUser(ctx, &userpb.UserId{OpaqueId: "marie"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.DisplayName).To(Equal("Marie Curie"))
+
+		users, err := mgr.FindAcceptedUsers(ctx, "CURIE")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(users).To(HaveLen(1))
+		users, err = mgr.FindAcceptedUsers(ctx, "einstein")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(users).To(BeEmpty())
+	})
+
+	It("rejects invalid invites", func() {
+		Expect(mgr.AcceptInvite(ctx, &invitepb.InviteToken{Token: "unknown"}, marie)).ToNot(Succeed())
+
+		tkn, err := mgr.GenerateToken(ctx)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mgr.AcceptInvite(ctx, tkn, einstein)).ToNot(Succeed())
+
+		_, err = mgr.GetAcceptedUser(ctx, marie.Id)
+		Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+	})
+
+	It("imports the state of the json driver once", func() {
+		data, err := json.Marshal(map[string]interface{}{
+			"invites":        map[string]*invitepb.InviteToken{},
+			"accepted_users": map[string][]*userpb.User{"einstein": {marie}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		file := filepath.Join(dir, "ocm-invites.json")
+		Expect(os.WriteFile(file, data, 0600)).To(Succeed())
+
+		n, err := sqlmanager.ImportJSON(context.Background(), conf, file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(1))
+		n, err = sqlmanager.ImportJSON(context.Background(), conf, file)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(n).To(Equal(0))
+
+		u, err := mgr.GetAcceptedUser(ctx, marie.Id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(u.Mail).To(Equal("marie@cesnet.cz"))
+	})
+})
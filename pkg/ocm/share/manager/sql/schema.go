@@ -0,0 +1,181 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+const shareTableColumns = `
+	id VARCHAR(64) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL DEFAULT '',
+	storage_id VARCHAR(255) NOT NULL,
+	opaque_id VARCHAR(255) NOT NULL,
+	owner_idp VARCHAR(255) NOT NULL,
+	owner_opaque_id VARCHAR(255) NOT NULL,
+	owner_type INTEGER NOT NULL DEFAULT 0,
+	creator_idp VARCHAR(255) NOT NULL,
+	creator_opaque_id VARCHAR(255) NOT NULL,
+	creator_type INTEGER NOT NULL DEFAULT 0,
+	grantee_type INTEGER NOT NULL,
+	grantee_idp VARCHAR(255) NOT NULL,
+	grantee_opaque_id VARCHAR(255) NOT NULL,
+	grantee_user_type INTEGER NOT NULL DEFAULT 0,
+	token VARCHAR(255) NOT NULL DEFAULT '',
+	permissions TEXT NOT NULL,
+	share_type INTEGER NOT NULL,
+	ctime BIGINT NOT NULL,
+	mtime BIGINT NOT NULL`
+
+// schema holds the statements creating the tables and their indexes for every supported engine.
+// A resource is shared only once by an owner with a grantee. As mysql limits the length of
+// index keys, the ids of the unique key are indexed there by their first 80 characters.
+var schema = map[string][]string{
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS ocm_shares (` + shareTableColumns + `,
+	UNIQUE INDEX ocm_shares_key (owner_idp(80), owner_opaque_id(80), storage_id(80), opaque_id(80), grantee_type, grantee_idp(80), grantee_opaque_id(80)),
+	INDEX ocm_shares_owner (owner_idp, owner_opaque_id),
+	INDEX ocm_shares_creator (creator_idp, creator_opaque_id),
+	INDEX ocm_shares_grantee (grantee_idp, grantee_opaque_id),
+	INDEX ocm_shares_token (token))`,
+		`CREATE TABLE IF NOT EXISTS ocm_received_shares (` + shareTableColumns + `,
+	state INTEGER NOT NULL,
+	INDEX ocm_received_shares_owner (owner_idp, owner_opaque_id),
+	INDEX ocm_received_shares_grantee (grantee_idp, grantee_opaque_id),
+	INDEX ocm_received_shares_token (token))`,
+	},
+	"sqlite3": {
+		`CREATE TABLE IF NOT EXISTS ocm_shares (` + shareTableColumns + `)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS ocm_shares_key ON ocm_shares (owner_idp, owner_opaque_id, storage_id, opaque_id, grantee_type, grantee_idp, grantee_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_shares_owner ON ocm_shares (owner_idp, owner_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_shares_creator ON ocm_shares (creator_idp, creator_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_shares_grantee ON ocm_shares (grantee_idp, grantee_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_shares_token ON ocm_shares (token)`,
+		`CREATE TABLE IF NOT EXISTS ocm_received_shares (` + shareTableColumns + `,
+	state INTEGER NOT NULL)`,
+		`CREATE INDEX IF NOT EXISTS ocm_received_shares_owner ON ocm_received_shares (owner_idp, owner_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_received_shares_grantee ON ocm_received_shares (grantee_idp, grantee_opaque_id)`,
+		`CREATE INDEX IF NOT EXISTS ocm_received_shares_token ON ocm_received_shares (token)`,
+	},
+}
+
+func createTables(ctx context.Context, engine string, db *sql.DB) error {
+	stmts, ok := schema[engine]
+	if !ok {
+		return errors.New("sql: unsupported db engine " + engine)
+	}
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSON copies the shares stored in the file of the json ocm share manager
+// to the database configured in m. Shares which already exist in the database are skipped,
+// so an interrupted import can be run again. It returns the number of imported shares.
+func ImportJSON(ctx context.Context, m map[string]interface{}, file string) (int, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return 0, err
+	}
+	db, err := open(c)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	if err := createTables(ctx, c.DBEngine, db); err != nil {
+		return 0, err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, errors.Wrap(err, "sql: error reading the json ocm shares")
+	}
+	model := struct {
+		Shares         map[string]string `json:"shares"`
+		ReceivedShares map[string]string `json:"received_shares"`
+	}{}
+	if err := json.Unmarshal(data, &model); err != nil {
+		return 0, errors.Wrap(err, "sql: error decoding the json ocm shares")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var n int
+	for id, enc := range model.Shares {
+		exists, err := rowExists(ctx, tx, "ocm_shares", id)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			continue
+		}
+		var s ocm.Share
+		if err := utils.UnmarshalJSONToProtoV1([]byte(enc), &s); err != nil {
+			return 0, errors.Wrap(err, "sql: error decoding share "+id)
+		}
+		if err := insertShare(ctx, tx, &s); err != nil {
+			return 0, errors.Wrap(err, "sql: error importing share "+id)
+		}
+		n++
+	}
+	for id, enc := range model.ReceivedShares {
+		exists, err := rowExists(ctx, tx, "ocm_received_shares", id)
+		if err != nil {
+			return 0, err
+		}
+		if exists {
+			continue
+		}
+		var rs ocm.ReceivedShare
+		if err := utils.UnmarshalJSONToProtoV1([]byte(enc), &rs); err != nil {
+			return 0, errors.Wrap(err, "sql: error decoding received share "+id)
+		}
+		if err := insertReceivedShare(ctx, tx, &rs); err != nil {
+			return 0, errors.Wrap(err, "sql: error importing received share "+id)
+		}
+		n++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func rowExists(ctx context.Context, tx *sql.Tx, table, id string) (bool, error) {
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table+" WHERE id=?", id).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,599 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/ocm/share"
+	"github.com/cs3org/reva/pkg/ocm/share/manager/registry"
+	"github.com/cs3org/reva/pkg/ocm/share/sender"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/google/uuid"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	// Provides sqlite drivers.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/protobuf/field_mask"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+// shareColumns lists the columns read for ocm_shares and ocm_received_shares, in the order scanShare expects them.
+const shareColumns = "id, name, storage_id, opaque_id, owner_idp, owner_opaque_id, owner_type, creator_idp, creator_opaque_id, creator_type, " +
+	"grantee_type, grantee_idp, grantee_opaque_id, grantee_user_type, token, permissions, share_type, ctime, mtime"
+
+const ownerCondition = "((owner_idp=? AND owner_opaque_id=?) OR (creator_idp=? AND creator_opaque_id=?))"
+
+type config struct {
+	DBEngine   string `mapstructure:"db_engine"`
+	DBFile     string `mapstructure:"db_file"`
+	DBUsername string `mapstructure:"db_username"`
+	DBPassword string `mapstructure:"db_password"`
+	DBHost     string `mapstructure:"db_host"`
+	DBPort     int    `mapstructure:"db_port"`
+	DBName     string `mapstructure:"db_name"`
+}
+
+func (c *config) init() {
+	if c.DBEngine == "" {
+		c.DBEngine = "mysql"
+	}
+	if c.DBFile == "" {
+		c.DBFile = "/var/tmp/reva/ocm-shares.db"
+	}
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, err
+	}
+	c.init()
+	return c, nil
+}
+
+type mgr struct {
+	engine string
+	db     *sql.DB
+}
+
+// execer is implemented by both sql.DB and sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// querier is implemented by both sql.DB and sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// New returns a new ocm share manager storing the shares in a mysql or sqlite database.
+func New(m map[string]interface{}) (share.Manager, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		err = errors.Wrap(err, "error creating a new manager")
+		return nil, err
+	}
+
+	db, err := open(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithDB(c.DBEngine, db)
+}
+
+// NewWithDB returns a new ocm share manager using the given database, creating the tables if needed.
+func NewWithDB(engine string, db *sql.DB) (share.Manager, error) {
+	if err := createTables(context.Background(), engine, db); err != nil {
+		return nil, errors.Wrap(err, "sql: error creating the ocm share tables")
+	}
+	return &mgr{
+		engine: engine,
+		db:     db,
+	}, nil
+}
+
+func open(c *config) (*sql.DB, error) {
+	switch c.DBEngine {
+	case "mysql":
+		return sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	case "sqlite3":
+		return sql.Open("sqlite3", c.DBFile)
+	default:
+		return nil, errtypes.NotSupported("sql: unsupported db engine " + c.DBEngine)
+	}
+}
+
+func genID() string {
+	return uuid.New().String()
+}
+
+// Ready tells whether the database is reachable.
+func (m *mgr) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+// Called from both grpc CreateOCMShare for outgoing
+// and http /ocm/shares for incoming
+// pi is provider info
+// pm is permissions.
+func (m *mgr) Share(ctx context.Context, md *provider.ResourceId, g *ocm.ShareGrant, name string,
+	pi *ocmprovider.ProviderInfo, pm string, owner *userpb.UserId, token string, st ocm.Share_ShareType) (*ocm.Share, error) {
+	now := time.Now().UnixNano()
+	ts := &typespb.Timestamp{
+		Seconds: uint64(now / 1000000000),
+		Nanos:   uint32(now % 1000000000),
+	}
+
+	// As in the json driver, the presence of the provider info tells whether this
+	// call is on the owner's mesh provider, where the share has to be sent to the
+	// remote provider as well, or on the remote provider receiving it.
+	isOwnersMeshProvider := pi != nil
+
+	var userID *userpb.UserId
+	if !isOwnersMeshProvider {
+		// Since this call is on the remote provider, the owner of the resource is expected to be specified.
+		if owner == nil {
+			return nil, errors.New("sql: owner of resource not provided")
+		}
+		userID = owner
+		g.Grantee.Opaque = &typespb.Opaque{
+			Map: map[string]*typespb.OpaqueEntry{
+				"token": {
+					Decoder: "plain",
+					Value:   []byte(token),
+				},
+			},
+		}
+	} else {
+		userID = ctxpkg.ContextMustGetUser(ctx).GetId()
+	}
+
+	// do not allow share to myself if share is for a user
+	if g.Grantee.Type == provider.GranteeType_GRANTEE_TYPE_USER && utils.UserEqual(g.Grantee.GetUserId(), userID) {
+		return nil, errors.New("sql: user and grantee are the same")
+	}
+
+	s := &ocm.Share{
+		Id: &ocm.ShareId{
+			OpaqueId: genID(),
+		},
+		Name:        name,
+		ResourceId:  md,
+		Permissions: g.Permissions,
+		Grantee:     g.Grantee,
+		Owner:       userID,
+		Creator:     userID,
+		Ctime:       ts,
+		Mtime:       ts,
+		ShareType:   st,
+	}
+
+	if !isOwnersMeshProvider {
+		rs := &ocm.ReceivedShare{
+			Share: s,
+			State: ocm.ShareState_SHARE_STATE_PENDING,
+		}
+		if err := insertReceivedShare(ctx, m.db, rs); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+
+	// The share is stored before it is sent, so that the unique key of the table settles
+	// concurrent requests, and removed again if the remote provider refuses it. No transaction
+	// is held open during the call to the remote provider.
+	key := &ocm.ShareKey{
+		Owner:      userID,
+		ResourceId: md,
+		Grantee:    g.Grantee,
+	}
+	if err := insertShare(ctx, m.db, s); err != nil {
+		if exists, _ := m.shareExists(ctx, key); exists {
+			return nil, errtypes.AlreadyExists(key.String())
+		}
+		return nil, err
+	}
+
+	protocol := map[string]interface{}{
+		"name": "webdav",
+		"options": map[string]string{
+			"permissions": pm,
+			"token":       ctxpkg.ContextMustGetToken(ctx),
+		},
+	}
+	if st == ocm.Share_SHARE_TYPE_TRANSFER {
+		protocol["name"] = "datatx"
+	}
+
+	requestBodyMap := map[string]interface{}{
+		"shareWith":    g.Grantee.GetUserId().OpaqueId,
+		"name":         name,
+		"providerId":   fmt.Sprintf("%s:%s", md.StorageId, md.OpaqueId),
+		"owner":        userID.OpaqueId,
+		"protocol":     protocol,
+		"meshProvider": userID.Idp, // FIXME: move this into the 'owner' string?
+	}
+	if err := sender.Send(requestBodyMap, pi); err != nil {
+		if _, derr := m.db.ExecContext(ctx, "DELETE FROM ocm_shares WHERE id=?", s.Id.OpaqueId); derr != nil {
+			appctx.GetLogger(ctx).Error().Err(derr).Str("share", s.Id.OpaqueId).Msg("error removing the share refused by the remote provider")
+		}
+		err = errors.Wrap(err, "error sending OCM POST")
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *mgr) shareExists(ctx context.Context, key *ocm.ShareKey) (bool, error) {
+	cond, params := keyCondition(key)
+	var id string
+	switch err := m.db.QueryRowContext(ctx, "SELECT id FROM ocm_shares WHERE "+cond, params...).Scan(&id); err {
+	case nil:
+		return true, nil
+	case sql.ErrNoRows:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (m *mgr) GetShare(ctx context.Context, ref *ocm.ShareReference) (*ocm.Share, error) {
+	cond, params, err := refCondition(ref)
+	if err != nil {
+		return nil, err
+	}
+	user := ctxpkg.ContextMustGetUser(ctx)
+	params = append(params, ownerParams(user.Id)...)
+
+	// we return not found to not disclose information if we are not the owner
+	s, err := scanShare(m.db.QueryRowContext(ctx, "SELECT "+shareColumns+" FROM ocm_shares WHERE "+cond+" AND "+ownerCondition, params...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errtypes.NotFound(ref.String())
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *mgr) Unshare(ctx context.Context, ref *ocm.ShareReference) error {
+	cond, params, err := refCondition(ref)
+	if err != nil {
+		return err
+	}
+	user := ctxpkg.ContextMustGetUser(ctx)
+	params = append(params, ownerParams(user.Id)...)
+
+	res, err := m.db.ExecContext(ctx, "DELETE FROM ocm_shares WHERE "+cond+" AND "+ownerCondition, params...)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errtypes.NotFound(ref.String())
+	}
+	return nil
+}
+
+func (m *mgr) UpdateShare(ctx context.Context, ref *ocm.ShareReference, p *ocm.SharePermissions) (*ocm.Share, error) {
+	cond, params, err := refCondition(ref)
+	if err != nil {
+		return nil, err
+	}
+	user := ctxpkg.ContextMustGetUser(ctx)
+	params = append(params, ownerParams(user.Id)...)
+
+	perms, err := utils.MarshalProtoV1ToJSON(p)
+	if err != nil {
+		return nil, err
+	}
+	params = append([]interface{}{string(perms), time.Now().Unix()}, params...)
+
+	res, err := m.db.ExecContext(ctx, "UPDATE ocm_shares SET permissions=?, mtime=? WHERE "+cond+" AND "+ownerCondition, params...)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, errtypes.NotFound(ref.String())
+	}
+	return m.GetShare(ctx, ref)
+}
+
+func (m *mgr) ListShares(ctx context.Context, filters []*ocm.ListOCMSharesRequest_Filter) ([]*ocm.Share, error) {
+	user := ctxpkg.ContextMustGetUser(ctx)
+	query := "SELECT " + shareColumns + " FROM ocm_shares WHERE " + ownerCondition
+	params := ownerParams(user.Id)
+
+	if len(filters) > 0 {
+		// TODO(labkode): add the rest of filters.
+		var conds []string
+		for _, f := range filters {
+			if f.Type == ocm.ListOCMSharesRequest_Filter_TYPE_RESOURCE_ID {
+				conds = append(conds, "(storage_id=? AND opaque_id=?)")
+				params = append(params, f.GetResourceId().GetStorageId(), f.GetResourceId().GetOpaqueId())
+			}
+		}
+		if len(conds) == 0 {
+			return nil, nil
+		}
+		query += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+
+	rows, err := m.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ss []*ocm.Share
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		ss = append(ss, s)
+	}
+	return ss, rows.Err()
+}
+
+func (m *mgr) ListReceivedShares(ctx context.Context) ([]*ocm.ReceivedShare, error) {
+	user := ctxpkg.ContextMustGetUser(ctx)
+	// omit shares created by me
+	query := "SELECT " + shareColumns + ", state FROM ocm_received_shares WHERE grantee_type=? AND grantee_idp=? AND grantee_opaque_id=? AND NOT " + ownerCondition
+	params := append([]interface{}{int(provider.GranteeType_GRANTEE_TYPE_USER), user.Id.Idp, user.Id.OpaqueId}, ownerParams(user.Id)...)
+
+	rows, err := m.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rss []*ocm.ReceivedShare
+	for rows.Next() {
+		rs, err := scanReceivedShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		rss = append(rss, rs)
+	}
+	return rss, rows.Err()
+}
+
+func (m *mgr) GetReceivedShare(ctx context.Context, ref *ocm.ShareReference) (*ocm.ReceivedShare, error) {
+	return m.getReceived(ctx, m.db, ref)
+}
+
+func (m *mgr) getReceived(ctx context.Context, q querier, ref *ocm.ShareReference) (*ocm.ReceivedShare, error) {
+	cond, params, err := refCondition(ref)
+	if err != nil {
+		return nil, err
+	}
+	user := ctxpkg.ContextMustGetUser(ctx)
+	params = append(params, int(provider.GranteeType_GRANTEE_TYPE_USER), user.Id.Idp, user.Id.OpaqueId)
+
+	rs, err := scanReceivedShare(q.QueryRowContext(ctx, "SELECT "+shareColumns+", state FROM ocm_received_shares WHERE "+cond+" AND grantee_type=? AND grantee_idp=? AND grantee_opaque_id=?", params...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errtypes.NotFound(ref.String())
+		}
+		return nil, err
+	}
+	return rs, nil
+}
+
+func (m *mgr) UpdateReceivedShare(ctx context.Context, share *ocm.ReceivedShare, fieldMask *field_mask.FieldMask) (*ocm.ReceivedShare, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	rs, err := m.getReceived(ctx, tx, &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: share.Share.Id}})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range fieldMask.Paths {
+		switch fieldMask.Paths[i] {
+		case "state":
+			rs.State = share.State
+		// TODO case "mount_point":
+		default:
+			return nil, errtypes.NotSupported("updating " + fieldMask.Paths[i] + " is not supported")
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE ocm_received_shares SET state=? WHERE id=?", int(rs.State), rs.Share.Id.OpaqueId); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func ownerParams(u *userpb.UserId) []interface{} {
+	return []interface{}{u.GetIdp(), u.GetOpaqueId(), u.GetIdp(), u.GetOpaqueId()}
+}
+
+func keyCondition(key *ocm.ShareKey) (string, []interface{}) {
+	gtype, gidp, gid, _, _ := granteeColumns(key.Grantee)
+	params := append(ownerParams(key.Owner), key.ResourceId.GetStorageId(), key.ResourceId.GetOpaqueId(), gtype, gidp, gid)
+	return ownerCondition + " AND storage_id=? AND opaque_id=? AND grantee_type=? AND grantee_idp=? AND grantee_opaque_id=?", params
+}
+
+func refCondition(ref *ocm.ShareReference) (string, []interface{}, error) {
+	switch {
+	case ref.GetId() != nil:
+		return "id=?", []interface{}{ref.GetId().OpaqueId}, nil
+	case ref.GetKey() != nil:
+		cond, params := keyCondition(ref.GetKey())
+		return cond, params, nil
+	default:
+		return "", nil, errtypes.NotFound(ref.String())
+	}
+}
+
+// granteeColumns returns the values stored for a grantee: its type, the idp and opaque id
+// of the user or group, the user type and the token a received share was sent with.
+func granteeColumns(g *provider.Grantee) (int, string, string, int, string) {
+	var idp, id string
+	var userType int
+	u, gr := utils.ExtractGranteeID(g)
+	switch {
+	case u != nil:
+		idp, id, userType = u.Idp, u.OpaqueId, int(u.Type)
+	case gr != nil:
+		idp, id = gr.Idp, gr.OpaqueId
+	}
+	var token string
+	if e, ok := g.GetOpaque().GetMap()["token"]; ok {
+		token = string(e.Value)
+	}
+	return int(g.GetType()), idp, id, userType, token
+}
+
+func shareValues(s *ocm.Share) ([]interface{}, error) {
+	perms, err := utils.MarshalProtoV1ToJSON(s.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	gtype, gidp, gid, guserType, token := granteeColumns(s.Grantee)
+	return []interface{}{
+		s.Id.OpaqueId, s.Name, s.ResourceId.GetStorageId(), s.ResourceId.GetOpaqueId(),
+		s.Owner.GetIdp(), s.Owner.GetOpaqueId(), int(s.Owner.GetType()),
+		s.Creator.GetIdp(), s.Creator.GetOpaqueId(), int(s.Creator.GetType()),
+		gtype, gidp, gid, guserType, token, string(perms), int(s.ShareType),
+		int64(s.Ctime.GetSeconds()), int64(s.Mtime.GetSeconds()),
+	}, nil
+}
+
+func insertShare(ctx context.Context, e execer, s *ocm.Share) error {
+	values, err := shareValues(s)
+	if err != nil {
+		return err
+	}
+	_, err = e.ExecContext(ctx, "INSERT INTO ocm_shares ("+shareColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", values...)
+	return err
+}
+
+func insertReceivedShare(ctx context.Context, e execer, rs *ocm.ReceivedShare) error {
+	values, err := shareValues(rs.Share)
+	if err != nil {
+		return err
+	}
+	values = append(values, int(rs.State))
+	_, err = e.ExecContext(ctx, "INSERT INTO ocm_received_shares ("+shareColumns+", state) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", values...)
+	return err
+}
+
+func scanShare(s scanner, extra ...interface{}) (*ocm.Share, error) {
+	var (
+		id, name, storageID, opaqueID                 string
+		ownerIdp, ownerID, creatorIdp, creatorID      string
+		granteeIdp, granteeID, token, perms           string
+		ownerType, creatorType, granteeType, userType int
+		shareType                                     int
+		ctime, mtime                                  int64
+	)
+	dest := append([]interface{}{
+		&id, &name, &storageID, &opaqueID, &ownerIdp, &ownerID, &ownerType, &creatorIdp, &creatorID, &creatorType,
+		&granteeType, &granteeIdp, &granteeID, &userType, &token, &perms, &shareType, &ctime, &mtime,
+	}, extra...)
+	if err := s.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	permissions := &ocm.SharePermissions{}
+	if err := utils.UnmarshalJSONToProtoV1([]byte(perms), permissions); err != nil {
+		return nil, err
+	}
+
+	grantee := &provider.Grantee{Type: provider.GranteeType(granteeType)}
+	if grantee.Type == provider.GranteeType_GRANTEE_TYPE_GROUP {
+		grantee.Id = &provider.Grantee_GroupId{GroupId: &grouppb.GroupId{Idp: granteeIdp, OpaqueId: granteeID}}
+	} else {
+		grantee.Id = &provider.Grantee_UserId{UserId: &userpb.UserId{Idp: granteeIdp, OpaqueId: granteeID, Type: userpb.UserType(userType)}}
+	}
+	if token != "" {
+		grantee.Opaque = &typespb.Opaque{
+			Map: map[string]*typespb.OpaqueEntry{
+				"token": {
+					Decoder: "plain",
+					Value:   []byte(token),
+				},
+			},
+		}
+	}
+
+	return &ocm.Share{
+		Id:          &ocm.ShareId{OpaqueId: id},
+		Name:        name,
+		ResourceId:  &provider.ResourceId{StorageId: storageID, OpaqueId: opaqueID},
+		Permissions: permissions,
+		Grantee:     grantee,
+		Owner:       &userpb.UserId{Idp: ownerIdp, OpaqueId: ownerID, Type: userpb.UserType(ownerType)},
+		Creator:     &userpb.UserId{Idp: creatorIdp, OpaqueId: creatorID, Type: userpb.UserType(creatorType)},
+		Ctime:       &typespb.Timestamp{Seconds: uint64(ctime)},
+		Mtime:       &typespb.Timestamp{Seconds: uint64(mtime)},
+		ShareType:   ocm.Share_ShareType(shareType),
+	}, nil
+}
+
+func scanReceivedShare(s scanner) (*ocm.ReceivedShare, error) {
+	var state int
+	share, err := scanShare(s, &state)
+	if err != nil {
+		return nil, err
+	}
+	return &ocm.ReceivedShare{
+		Share: share,
+		State: ocm.ShareState(state),
+	}, nil
+}
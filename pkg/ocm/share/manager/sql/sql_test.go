@@ -0,0 +1,221 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/ocm/share"
+	sqlmanager "github.com/cs3org/reva/pkg/ocm/share/manager/sql"
+	"github.com/cs3org/reva/pkg/utils"
+	"google.golang.org/genproto/protobuf/field_mask"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL manager", func() {
+	var (
+		mgr    share.Manager
+		db     *sql.DB
+		dir    string
+		server *httptest.Server
+		status int
+		posts  int
+
+		einstein = &userpb.User{Id: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein", Type: userpb.UserType_USER_TYPE_PRIMARY}}
+		marie    = &userpb.User{Id: &userpb.UserId{Idp: "cesnet.cz", OpaqueId: "marie", Type: userpb.UserType_USER_TYPE_FEDERATED}}
+		resource = &provider.ResourceId{StorageId: "storage", OpaqueId: "file"}
+		grant    = func() *ocm.ShareGrant {
+			return &ocm.ShareGrant{
+				Grantee: &provider.Grantee{
+					Type: provider.GranteeType_GRANTEE_TYPE_USER,
+					Id:   &provider.Grantee_UserId{UserId: marie.Id},
+				},
+				Permissions: &ocm.SharePermissions{Permissions: &provider.ResourcePermissions{Stat: true}},
+			}
+		}
+		pi  *ocmprovider.ProviderInfo
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "reva-unit-tests-*")
+		Expect(err).ToNot(HaveOccurred())
+
+		db, err = sql.Open("sqlite3", filepath.Join(dir, "ocm.db"))
+		Expect(err).ToNot(HaveOccurred())
+		mgr, err = sqlmanager.NewWithDB("sqlite3", db)
+		Expect(err).ToNot(HaveOccurred())
+
+		status, posts = http.StatusCreated, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			posts++
+			w.WriteHeader(status)
+		}))
+		pi = &ocmprovider.ProviderInfo{
+			Services: []*ocmprovider.Service{{
+				Endpoint: &ocmprovider.ServiceEndpoint{Type: &ocmprovider.ServiceType{Name: "OCM"}, Path: server.URL},
+			}},
+		}
+
+		ctx = ctxpkg.ContextSetUser(context.Background(), einstein)
+		ctx = ctxpkg.ContextSetToken(ctx, "token")
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	Describe("outgoing shares", func() {
+		It("creates a share and sends it to the remote provider", func() {
+			s, err := mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(posts).To(Equal(1))
+
+			got, err := mgr.GetShare(ctx, &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: s.Id}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got.Name).To(Equal("file"))
+			Expect(utils.UserEqual(got.Owner, einstein.Id)).To(BeTrue())
+			Expect(utils.GranteeEqual(got.Grantee, s.Grantee)).To(BeTrue())
+			Expect(got.Permissions.Permissions.Stat).To(BeTrue())
+		})
+
+		It("does not create a share twice", func() {
+			_, err := mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.AlreadyExists("")))
+		})
+
+		It("keeps a single share per owner, resource and grantee in the table", func() {
+			_, err := mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec("INSERT INTO ocm_shares SELECT 'duplicate', name, storage_id, opaque_id, owner_idp, owner_opaque_id, owner_type, " +
+				"creator_idp, creator_opaque_id, creator_type, grantee_type, grantee_idp, grantee_opaque_id, grantee_user_type, " +
+				"token, permissions, share_type, ctime, mtime FROM ocm_shares")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("does not keep the share when the remote provider rejects it", func() {
+			status = http.StatusInternalServerError
+			_, err := mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).To(HaveOccurred())
+
+			shares, err := mgr.ListShares(ctx, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shares).To(BeEmpty())
+		})
+
+		It("lists, updates and removes shares", func() {
+			s, err := mgr.Share(ctx, resource, grant(), "file", pi, "r", nil, "", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).ToNot(HaveOccurred())
+
+			shares, err := mgr.ListShares(ctx, []*ocm.ListOCMSharesRequest_Filter{share.ResourceIDFilter(resource)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shares).To(HaveLen(1))
+			shares, err = mgr.ListShares(ctx, []*ocm.ListOCMSharesRequest_Filter{share.ResourceIDFilter(&provider.ResourceId{StorageId: "storage", OpaqueId: "other"})})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(shares).To(BeEmpty())
+
+			ref := &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: s.Id}}
+			updated, err := mgr.UpdateShare(ctx, ref, &ocm.SharePermissions{Permissions: &provider.ResourcePermissions{Stat: true, InitiateFileDownload: true}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(updated.Permissions.Permissions.InitiateFileDownload).To(BeTrue())
+
+			marieCtx := ctxpkg.ContextSetUser(context.Background(), marie)
+			Expect(mgr.Unshare(marieCtx, ref)).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+			Expect(mgr.Unshare(ctx, ref)).To(Succeed())
+			_, err = mgr.GetShare(ctx, ref)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+		})
+	})
+
+	Describe("received shares", func() {
+		It("stores a received share as pending and updates its state", func() {
+			marieCtx := ctxpkg.ContextSetUser(context.Background(), marie)
+			s, err := mgr.Share(marieCtx, resource, grant(), "file", nil, "", einstein.Id, "secret", ocm.Share_SHARE_TYPE_REGULAR)
+			Expect(err).ToNot(HaveOccurred())
+
+			rss, err := mgr.ListReceivedShares(marieCtx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rss).To(HaveLen(1))
+			Expect(rss[0].State).To(Equal(ocm.ShareState_SHARE_STATE_PENDING))
+			Expect(string(rss[0].Share.Grantee.Opaque.Map["token"].Value)).To(Equal("secret"))
+
+			rss[0].State = ocm.ShareState_SHARE_STATE_ACCEPTED
+			_, err = mgr.UpdateReceivedShare(marieCtx, rss[0], &field_mask.FieldMask{Paths: []string{"state"}})
+			Expect(err).ToNot(HaveOccurred())
+
+			rs, err := mgr.GetReceivedShare(marieCtx, &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: s.Id}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rs.State).To(Equal(ocm.ShareState_SHARE_STATE_ACCEPTED))
+
+			rss, err = mgr.ListReceivedShares(ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rss).To(BeEmpty())
+		})
+	})
+
+	Describe("ImportJSON", func() {
+		It("imports the shares of the json driver once", func() {
+			s := &ocm.Share{
+				Id:          &ocm.ShareId{OpaqueId: "imported"},
+				Name:        "file",
+				ResourceId:  resource,
+				Permissions: grant().Permissions,
+				Grantee:     grant().Grantee,
+				Owner:       einstein.Id,
+				Creator:     einstein.Id,
+			}
+			enc, err := utils.MarshalProtoV1ToJSON(s)
+			Expect(err).ToNot(HaveOccurred())
+			data, err := json.Marshal(map[string]interface{}{"shares": map[string]string{"imported": string(enc)}})
+			Expect(err).ToNot(HaveOccurred())
+			file := filepath.Join(dir, "ocm-shares.json")
+			Expect(os.WriteFile(file, data, 0600)).To(Succeed())
+
+			conf := map[string]interface{}{"db_engine": "sqlite3", "db_file": filepath.Join(dir, "ocm.db")}
+			n, err := sqlmanager.ImportJSON(context.Background(), conf, file)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(1))
+			n, err = sqlmanager.ImportJSON(context.Background(), conf, file)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(0))
+
+			got, err := mgr.GetShare(ctx, &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: s.Id}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(got.Name).To(Equal("file"))
+		})
+	})
+})
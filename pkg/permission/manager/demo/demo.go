@@ -19,6 +19,9 @@
 package demo
 
 import (
+	"context"
+
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/permission"
 	"github.com/cs3org/reva/pkg/permission/manager/registry"
@@ -36,8 +39,8 @@ func New(c map[string]interface{}) (permission.Manager, error) {
 type manager struct {
 }
 
-func (m manager) CheckPermission(permission string, subject string, ref *provider.Reference) bool {
+func (m manager) CheckPermission(ctx context.Context, permission string, subject *permissions.SubjectReference, ref *provider.Reference) (bool, error) {
 	// We can currently return true all the time.
 	// Once we beginn testing roles we need to somehow check the roles of the users here
-	return true
+	return true, nil
 }
@@ -0,0 +1,89 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package json
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/permission/manager/registry"
+	"github.com/cs3org/reva/pkg/permission/manager/roles"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("json", New)
+}
+
+type config struct {
+	// Policy holds a path to a file containing json conforming to the roles.Policy struct
+	Policy        string                            `mapstructure:"policy"`
+	GroupManager  string                            `mapstructure:"group_manager"`
+	GroupManagers map[string]map[string]interface{} `mapstructure:"group_managers"`
+}
+
+func (c *config) init() {
+	if c.Policy == "" {
+		c.Policy = "/etc/revad/permissions.json"
+	}
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		err = errors.Wrap(err, "error decoding conf")
+		return nil, err
+	}
+	c.init()
+	return c, nil
+}
+
+type store struct {
+	policy *roles.Policy
+}
+
+func (s *store) Policy(ctx context.Context) (*roles.Policy, error) {
+	return s.policy, nil
+}
+
+// New returns a permission manager granting the roles and overrides read from a json file.
+func New(m map[string]interface{}) (permission.Manager, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.ReadFile(c.Policy)
+	if err != nil {
+		return nil, err
+	}
+	policy := &roles.Policy{}
+	if err := json.Unmarshal(f, policy); err != nil {
+		return nil, err
+	}
+
+	groups, err := roles.GroupManager(c.GroupManager, c.GroupManagers)
+	if err != nil {
+		return nil, err
+	}
+	return roles.New(&store{policy: policy}, groups), nil
+}
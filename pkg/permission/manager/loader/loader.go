@@ -21,5 +21,7 @@ package loader
 import (
 	// Load permission manager drivers.
 	_ "github.com/cs3org/reva/pkg/permission/manager/demo"
+	_ "github.com/cs3org/reva/pkg/permission/manager/json"
+	_ "github.com/cs3org/reva/pkg/permission/manager/sql"
 	// Add your own here.
 )
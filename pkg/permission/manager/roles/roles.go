@@ -0,0 +1,251 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package roles implements a permission manager granting permissions through roles
+// assigned to users and groups, with optional overrides for single references.
+package roles
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/group"
+	groupregistry "github.com/cs3org/reva/pkg/group/manager/registry"
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// Wildcard is the permission name granting every permission.
+const Wildcard = "*"
+
+// membershipTTL is how long the group memberships looked up through the group manager are cached.
+const membershipTTL = time.Minute
+
+// Role is a named set of permissions.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// Assignment assigns a role to a user or a group, identified by their opaque id.
+type Assignment struct {
+	Role  string `json:"role"`
+	User  string `json:"user,omitempty"`
+	Group string `json:"group,omitempty"`
+}
+
+// Override allows or denies a permission to a user or a group on a single reference,
+// regardless of their roles. The reference is matched by resource id if set,
+// otherwise by path, including everything below it.
+type Override struct {
+	Permission string `json:"permission"`
+	User       string `json:"user,omitempty"`
+	Group      string `json:"group,omitempty"`
+	StorageID  string `json:"storage_id,omitempty"`
+	OpaqueID   string `json:"opaque_id,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Allow      bool   `json:"allow"`
+}
+
+// Policy holds the roles, their assignments and the overrides.
+type Policy struct {
+	Roles       []*Role       `json:"roles"`
+	Assignments []*Assignment `json:"assignments"`
+	Overrides   []*Override   `json:"overrides"`
+}
+
+// Store provides the policy to evaluate.
+type Store interface {
+	Policy(ctx context.Context) (*Policy, error)
+}
+
+type manager struct {
+	store       Store
+	groups      group.Manager
+	memberships *ttlcache.Cache
+}
+
+// New returns a permission manager evaluating the policy of the store.
+// The groups of the user in the context are known from its token, the other
+// memberships are looked up through the group manager, if there is one.
+func New(store Store, groups group.Manager) permission.Manager {
+	memberships := ttlcache.NewCache()
+	_ = memberships.SetTTL(membershipTTL)
+	memberships.SkipTTLExtensionOnHit(true)
+	return &manager{store: store, groups: groups, memberships: memberships}
+}
+
+// GroupManager returns the configured group manager, nil if name is empty.
+func GroupManager(name string, drivers map[string]map[string]interface{}) (group.Manager, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if f, ok := groupregistry.NewFuncs[name]; ok {
+		return f(drivers[name])
+	}
+	return nil, fmt.Errorf("roles: group manager not found: %s", name)
+}
+
+type subject struct {
+	user   *userpb.UserId
+	groups map[string]bool
+}
+
+func (s *subject) matches(user, group string) bool {
+	if user != "" && s.user != nil && user == s.user.OpaqueId {
+		return true
+	}
+	return group != "" && s.groups[group]
+}
+
+func (m *manager) CheckPermission(ctx context.Context, perm string, ref *permissions.SubjectReference, res *provider.Reference) (bool, error) {
+	p, err := m.store.Policy(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "roles: error getting policy")
+	}
+
+	s, err := m.resolve(ctx, p, ref)
+	if err != nil {
+		return false, err
+	}
+
+	if res != nil {
+		allowed, denied := false, false
+		for _, o := range p.Overrides {
+			if o.Permission != perm || !s.matches(o.User, o.Group) || !o.matches(res) {
+				continue
+			}
+			if o.Allow {
+				allowed = true
+			} else {
+				denied = true
+			}
+		}
+		if denied {
+			return false, nil
+		}
+		if allowed {
+			return true, nil
+		}
+	}
+
+	assigned := map[string]bool{}
+	for _, a := range p.Assignments {
+		if s.matches(a.User, a.Group) {
+			assigned[a.Role] = true
+		}
+	}
+	for _, r := range p.Roles {
+		if !assigned[r.Name] {
+			continue
+		}
+		for _, rp := range r.Permissions {
+			if rp == perm || rp == Wildcard {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// resolve returns the subject with the groups of the policy it is a member of.
+func (m *manager) resolve(ctx context.Context, p *Policy, ref *permissions.SubjectReference) (*subject, error) {
+	s := &subject{groups: map[string]bool{}}
+	switch {
+	case ref.GetGroupId() != nil:
+		s.groups[ref.GetGroupId().OpaqueId] = true
+		return s, nil
+	case ref.GetUserId() != nil:
+		s.user = ref.GetUserId()
+	default:
+		return s, nil
+	}
+
+	// the groups in the token of the user need no lookup
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok && utils.UserEqual(u.Id, s.user) {
+		for _, g := range u.Groups {
+			s.groups[g] = true
+		}
+	}
+	if m.groups == nil {
+		return s, nil
+	}
+
+	check := func(gid string) error {
+		if gid == "" || s.groups[gid] {
+			return nil
+		}
+		ok, err := m.isMember(ctx, gid, s.user)
+		if err != nil {
+			return err
+		}
+		s.groups[gid] = ok
+		return nil
+	}
+	for _, a := range p.Assignments {
+		if err := check(a.Group); err != nil {
+			return nil, err
+		}
+	}
+	for _, o := range p.Overrides {
+		if err := check(o.Group); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// isMember looks up whether the user is a member of the group through the group manager,
+// caching the result. Groups that don't exist have no members.
+func (m *manager) isMember(ctx context.Context, gid string, uid *userpb.UserId) (bool, error) {
+	key := uid.Idp + "!" + uid.OpaqueId + "!" + gid
+	if v, err := m.memberships.Get(key); err == nil {
+		return v.(bool), nil
+	}
+	ok, err := m.groups.HasMember(ctx, &grouppb.GroupId{OpaqueId: gid}, uid)
+	if _, notFound := err.(errtypes.IsNotFound); notFound {
+		ok, err = false, nil
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "roles: error checking membership of group %s", gid)
+	}
+	_ = m.memberships.Set(key, ok)
+	return ok, nil
+}
+
+func (o *Override) matches(ref *provider.Reference) bool {
+	if o.OpaqueID != "" {
+		id := ref.GetResourceId()
+		return id != nil && id.OpaqueId == o.OpaqueID && (o.StorageID == "" || id.StorageId == o.StorageID)
+	}
+	if o.Path != "" {
+		p := ref.GetPath()
+		return p == o.Path || strings.HasPrefix(p, strings.TrimSuffix(o.Path, "/")+"/")
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package roles
+
+import (
+	"context"
+	"testing"
+
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/group"
+)
+
+type staticStore struct {
+	policy *Policy
+}
+
+func (s *staticStore) Policy(ctx context.Context) (*Policy, error) {
+	return s.policy, nil
+}
+
+type groups struct {
+	group.Manager
+	members map[string][]string
+	lookups int
+}
+
+func (g *groups) HasMember(ctx context.Context, gid *grouppb.GroupId, uid *userpb.UserId) (bool, error) {
+	g.lookups++
+	members, ok := g.members[gid.OpaqueId]
+	if !ok {
+		return false, errtypes.NotFound(gid.OpaqueId)
+	}
+	for _, m := range members {
+		if m == uid.OpaqueId {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func user(id string) *permissions.SubjectReference {
+	return &permissions.SubjectReference{Spec: &permissions.SubjectReference_UserId{UserId: &userpb.UserId{OpaqueId: id}}}
+}
+
+func TestCheckPermission(t *testing.T) {
+	policy := &Policy{
+		Roles: []*Role{
+			{Name: "admin", Permissions: []string{Wildcard}},
+			{Name: "sharer", Permissions: []string{"create-public-link", "share-external"}},
+			{Name: "spacemanager", Permissions: []string{"create-space", "manage-quota"}},
+		},
+		Assignments: []*Assignment{
+			{Role: "admin", User: "admin"},
+			{Role: "sharer", Group: "staff"},
+			{Role: "spacemanager", User: "marie"},
+			{Role: "sharer", Group: "unknown"},
+		},
+		Overrides: []*Override{
+			{Permission: "create-public-link", Group: "staff", Path: "/secret", Allow: false},
+			{Permission: "create-public-link", User: "richard", StorageID: "storage", OpaqueID: "public", Allow: true},
+		},
+	}
+	m := New(&staticStore{policy: policy}, &groups{members: map[string][]string{"staff": {"einstein", "marie"}}})
+
+	tests := []struct {
+		name       string
+		permission string
+		subject    *permissions.SubjectReference
+		ref        *provider.Reference
+		expected   bool
+	}{
+		{"wildcard", "create-space", user("admin"), nil, true},
+		{"role of user", "manage-quota", user("marie"), nil, true},
+		{"role of group", "create-public-link", user("einstein"), nil, true},
+		{"no role", "create-public-link", user("richard"), nil, false},
+		{"missing permission", "create-space", user("einstein"), nil, false},
+		{"group subject", "share-external", &permissions.SubjectReference{Spec: &permissions.SubjectReference_GroupId{GroupId: &grouppb.GroupId{OpaqueId: "staff"}}}, nil, true},
+		{"denied below path", "create-public-link", user("einstein"), &provider.Reference{Path: "/secret/file"}, false},
+		{"other path", "create-public-link", user("einstein"), &provider.Reference{Path: "/secretive"}, true},
+		{"allowed by id", "create-public-link", user("richard"), &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "public"}}, true},
+		{"other id", "create-public-link", user("richard"), &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "other"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := m.CheckPermission(context.Background(), tt.permission, tt.subject, tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestGroupMembershipLookups(t *testing.T) {
+	policy := &Policy{
+		Roles:       []*Role{{Name: "sharer", Permissions: []string{"create-public-link"}}},
+		Assignments: []*Assignment{{Role: "sharer", Group: "staff"}},
+	}
+	g := &groups{members: map[string][]string{"staff": {"einstein"}}}
+	m := New(&staticStore{policy: policy}, g)
+
+	// the groups in the token of the user need no lookup
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "marie"}, Groups: []string{"staff"}})
+	if ok, err := m.CheckPermission(ctx, "create-public-link", user("marie"), nil); err != nil || !ok {
+		t.Fatalf("expected the group in the token to grant the permission, got %t %v", ok, err)
+	}
+	if g.lookups != 0 {
+		t.Errorf("expected no lookups, got %d", g.lookups)
+	}
+
+	// other subjects are looked up once
+	for i := 0; i < 3; i++ {
+		if ok, err := m.CheckPermission(ctx, "create-public-link", user("einstein"), nil); err != nil || !ok {
+			t.Fatalf("expected the group membership to grant the permission, got %t %v", ok, err)
+		}
+	}
+	if g.lookups != 1 {
+		t.Errorf("expected one lookup, got %d", g.lookups)
+	}
+}
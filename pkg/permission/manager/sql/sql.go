@@ -0,0 +1,143 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package sql implements a permission manager reading its policy from the tables
+// permission_roles(role, permission), permission_assignments(role, user_id, group_id)
+// and permission_overrides(permission, user_id, group_id, storage_id, opaque_id, path, allow).
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/cs3org/reva/pkg/group"
+	"github.com/cs3org/reva/pkg/permission"
+	"github.com/cs3org/reva/pkg/permission/manager/registry"
+	"github.com/cs3org/reva/pkg/permission/manager/roles"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type config struct {
+	DBUsername    string                            `mapstructure:"db_username"`
+	DBPassword    string                            `mapstructure:"db_password"`
+	DBHost        string                            `mapstructure:"db_host"`
+	DBPort        int                               `mapstructure:"db_port"`
+	DBName        string                            `mapstructure:"db_name"`
+	GroupManager  string                            `mapstructure:"group_manager"`
+	GroupManagers map[string]map[string]interface{} `mapstructure:"group_managers"`
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// New returns a permission manager reading the roles, their assignments and the overrides from a sql database.
+// The policy is read on every check, so changes to the tables apply immediately.
+func New(m map[string]interface{}) (permission.Manager, error) {
+	c, err := parseConfig(m)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating a new manager")
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := roles.GroupManager(c.GroupManager, c.GroupManagers)
+	if err != nil {
+		return nil, err
+	}
+	return newManager(db, groups), nil
+}
+
+func newManager(db *sql.DB, groups group.Manager) permission.Manager {
+	return roles.New(&store{db: db}, groups)
+}
+
+type store struct {
+	db *sql.DB
+}
+
+func (s *store) Policy(ctx context.Context) (*roles.Policy, error) {
+	p := &roles.Policy{}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT role, permission FROM permission_roles ORDER BY role")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var last *roles.Role
+	for rows.Next() {
+		var role, perm string
+		if err := rows.Scan(&role, &perm); err != nil {
+			return nil, err
+		}
+		if last == nil || last.Name != role {
+			last = &roles.Role{Name: role}
+			p.Roles = append(p.Roles, last)
+		}
+		last.Permissions = append(last.Permissions, perm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT role, COALESCE(user_id, ''), COALESCE(group_id, '') FROM permission_assignments")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a := &roles.Assignment{}
+		if err := rows.Scan(&a.Role, &a.User, &a.Group); err != nil {
+			return nil, err
+		}
+		p.Assignments = append(p.Assignments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, "SELECT permission, COALESCE(user_id, ''), COALESCE(group_id, ''), COALESCE(storage_id, ''), COALESCE(opaque_id, ''), COALESCE(path, ''), allow FROM permission_overrides")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		o := &roles.Override{}
+		if err := rows.Scan(&o.Permission, &o.User, &o.Group, &o.StorageID, &o.OpaqueID, &o.Path, &o.Allow); err != nil {
+			return nil, err
+		}
+		p.Overrides = append(p.Overrides, o)
+	}
+	return p, rows.Err()
+}
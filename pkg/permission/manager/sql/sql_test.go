@@ -0,0 +1,77 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestCheckPermission(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "permissions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"CREATE TABLE permission_roles (role VARCHAR(255), permission VARCHAR(255))",
+		"CREATE TABLE permission_assignments (role VARCHAR(255), user_id VARCHAR(255), group_id VARCHAR(255))",
+		"CREATE TABLE permission_overrides (permission VARCHAR(255), user_id VARCHAR(255), group_id VARCHAR(255), storage_id VARCHAR(255), opaque_id VARCHAR(255), path VARCHAR(255), allow BOOLEAN)",
+		"INSERT INTO permission_roles VALUES ('spacemanager', 'create-space'), ('spacemanager', 'manage-quota')",
+		"INSERT INTO permission_assignments VALUES ('spacemanager', 'einstein', NULL)",
+		"INSERT INTO permission_overrides VALUES ('manage-quota', 'einstein', NULL, NULL, NULL, '/projects', 0)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newManager(db, nil)
+	einstein := &permissions.SubjectReference{Spec: &permissions.SubjectReference_UserId{UserId: &userpb.UserId{OpaqueId: "einstein"}}}
+	marie := &permissions.SubjectReference{Spec: &permissions.SubjectReference_UserId{UserId: &userpb.UserId{OpaqueId: "marie"}}}
+
+	tests := []struct {
+		permission string
+		subject    *permissions.SubjectReference
+		ref        *provider.Reference
+		expected   bool
+	}{
+		{"create-space", einstein, nil, true},
+		{"manage-quota", einstein, &provider.Reference{Path: "/users/einstein"}, true},
+		{"manage-quota", einstein, &provider.Reference{Path: "/projects/cern"}, false},
+		{"create-space", marie, nil, false},
+	}
+	for _, tt := range tests {
+		ok, err := m.CheckPermission(context.Background(), tt.permission, tt.subject, tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.expected {
+			t.Errorf("%s on %v: expected %t, got %t", tt.permission, tt.ref, tt.expected, ok)
+		}
+	}
+}
@@ -19,10 +19,31 @@
 package permission
 
 import (
+	"context"
+
+	permissions "github.com/cs3org/go-cs3apis/cs3/permissions/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 )
 
+const (
+	// ListAllSpaces is the permission to list the spaces of all users.
+	ListAllSpaces = "list-all-spaces"
+	// CreateSpace is the permission to create storage spaces.
+	CreateSpace = "create-space"
+	// ManageQuota is the permission to change the quota of storage spaces.
+	ManageQuota = "manage-quota"
+	// CreatePublicLink is the permission to create public links.
+	CreatePublicLink = "create-public-link"
+	// ShareExternal is the permission to share with users of other sites.
+	ShareExternal = "share-external"
+	// ManageSessions is the permission to list and revoke the sessions of other users.
+	ManageSessions = "manage-sessions"
+	// ManageMaintenance is the permission to toggle the read-only modes at runtime.
+	ManageMaintenance = "manage-maintenance"
+)
+
 // Manager defines the interface for the permission service driver.
 type Manager interface {
-	CheckPermission(permission string, subject string, ref *provider.Reference) bool
+	// CheckPermission tells whether the subject has the permission, optionally on the given reference.
+	CheckPermission(ctx context.Context, permission string, subject *permissions.SubjectReference, ref *provider.Reference) (bool, error)
 }
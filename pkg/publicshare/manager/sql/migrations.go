@@ -0,0 +1,126 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// migrations holds, for every supported engine, the statements bringing the schema
+// from one version to the next: the n-th entry migrates the schema to version n+1.
+// New entries are appended, released ones are never changed.
+var migrations = map[string][][]string{
+	"mysql": {
+		{
+			`CREATE TABLE IF NOT EXISTS public_shares (
+	id VARCHAR(64) NOT NULL PRIMARY KEY,
+	token VARCHAR(64) NOT NULL,
+	storage_id VARCHAR(255) NOT NULL,
+	opaque_id VARCHAR(255) NOT NULL,
+	owner_idp VARCHAR(255) NOT NULL,
+	owner_opaque_id VARCHAR(255) NOT NULL,
+	owner_type INTEGER NOT NULL DEFAULT 0,
+	creator_idp VARCHAR(255) NOT NULL,
+	creator_opaque_id VARCHAR(255) NOT NULL,
+	creator_type INTEGER NOT NULL DEFAULT 0,
+	permissions TEXT NOT NULL,
+	password VARCHAR(255) NOT NULL DEFAULT '',
+	display_name VARCHAR(255) NOT NULL DEFAULT '',
+	description TEXT NOT NULL,
+	internal BOOLEAN NOT NULL DEFAULT FALSE,
+	expiration BIGINT NULL,
+	ctime BIGINT NOT NULL,
+	mtime BIGINT NOT NULL,
+	UNIQUE INDEX public_shares_token (token),
+	INDEX public_shares_owner (owner_idp, owner_opaque_id),
+	INDEX public_shares_creator (creator_idp, creator_opaque_id),
+	INDEX public_shares_resource (storage_id, opaque_id),
+	INDEX public_shares_expiration (expiration))`,
+		},
+	},
+	"sqlite3": {
+		{
+			`CREATE TABLE IF NOT EXISTS public_shares (
+	id VARCHAR(64) NOT NULL PRIMARY KEY,
+	token VARCHAR(64) NOT NULL,
+	storage_id VARCHAR(255) NOT NULL,
+	opaque_id VARCHAR(255) NOT NULL,
+	owner_idp VARCHAR(255) NOT NULL,
+	owner_opaque_id VARCHAR(255) NOT NULL,
+	owner_type INTEGER NOT NULL DEFAULT 0,
+	creator_idp VARCHAR(255) NOT NULL,
+	creator_opaque_id VARCHAR(255) NOT NULL,
+	creator_type INTEGER NOT NULL DEFAULT 0,
+	permissions TEXT NOT NULL,
+	password VARCHAR(255) NOT NULL DEFAULT '',
+	display_name VARCHAR(255) NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	internal BOOLEAN NOT NULL DEFAULT FALSE,
+	expiration BIGINT NULL,
+	ctime BIGINT NOT NULL,
+	mtime BIGINT NOT NULL)`,
+			`CREATE UNIQUE INDEX IF NOT EXISTS public_shares_token ON public_shares (token)`,
+			`CREATE INDEX IF NOT EXISTS public_shares_owner ON public_shares (owner_idp, owner_opaque_id)`,
+			`CREATE INDEX IF NOT EXISTS public_shares_creator ON public_shares (creator_idp, creator_opaque_id)`,
+			`CREATE INDEX IF NOT EXISTS public_shares_resource ON public_shares (storage_id, opaque_id)`,
+			`CREATE INDEX IF NOT EXISTS public_shares_expiration ON public_shares (expiration)`,
+		},
+	},
+}
+
+// migrate brings the schema of the database to the latest version,
+// recording the applied versions in the public_share_migrations table.
+func migrate(ctx context.Context, engine string, db *sql.DB) error {
+	steps, ok := migrations[engine]
+	if !ok {
+		return errors.New("sql: unsupported db engine " + engine)
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS public_share_migrations (version INTEGER NOT NULL PRIMARY KEY)"); err != nil {
+		return err
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM public_share_migrations").Scan(&version); err != nil {
+		return err
+	}
+
+	for v := version; v < len(steps); v++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range steps[v] {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				_ = tx.Rollback()
+				return errors.Wrapf(err, "sql: error migrating the schema to version %d", v+1)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO public_share_migrations (version) VALUES (?)", v+1); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
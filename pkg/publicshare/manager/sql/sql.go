@@ -0,0 +1,505 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/logger"
+	"github.com/cs3org/reva/pkg/publicshare"
+	"github.com/cs3org/reva/pkg/publicshare/manager/registry"
+	"github.com/cs3org/reva/pkg/utils"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	// Provides sqlite drivers.
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// shareColumns lists the columns read for a public share, in the order scanShare expects them.
+const shareColumns = "id, token, storage_id, opaque_id, owner_idp, owner_opaque_id, owner_type, creator_idp, creator_opaque_id, creator_type, " +
+	"permissions, password, display_name, description, expiration, ctime, mtime"
+
+// userCondition restricts the query to the shares the user owns or created.
+const userCondition = "((owner_idp=? AND owner_opaque_id=?) OR (creator_idp=? AND creator_opaque_id=?))"
+
+func init() {
+	registry.Register("generic-sql", New)
+}
+
+type config struct {
+	DBEngine                   string `mapstructure:"db_engine"`
+	DBFile                     string `mapstructure:"db_file"`
+	DBUsername                 string `mapstructure:"db_username"`
+	DBPassword                 string `mapstructure:"db_password"`
+	DBHost                     string `mapstructure:"db_host"`
+	DBPort                     int    `mapstructure:"db_port"`
+	DBName                     string `mapstructure:"db_name"`
+	SharePasswordHashCost      int    `mapstructure:"password_hash_cost"`
+	JanitorRunInterval         int    `mapstructure:"janitor_run_interval"`
+	EnableExpiredSharesCleanup bool   `mapstructure:"enable_expired_shares_cleanup"`
+}
+
+func (c *config) init() {
+	if c.DBEngine == "" {
+		c.DBEngine = "mysql"
+	}
+	if c.DBFile == "" {
+		c.DBFile = "/var/tmp/reva/publicshares.db"
+	}
+	if c.SharePasswordHashCost == 0 {
+		c.SharePasswordHashCost = 11
+	}
+	if c.JanitorRunInterval == 0 {
+		c.JanitorRunInterval = 3600
+	}
+}
+
+type manager struct {
+	c  *config
+	db *sql.DB
+}
+
+// New returns a new public share manager storing the shares in a mysql or sqlite database.
+func New(m map[string]interface{}) (publicshare.Manager, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, err
+	}
+	c.init()
+
+	var db *sql.DB
+	var err error
+	switch c.DBEngine {
+	case "mysql":
+		db, err = sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	case "sqlite3":
+		db, err = sql.Open("sqlite3", c.DBFile)
+	default:
+		err = errtypes.NotSupported("sql: unsupported db engine " + c.DBEngine)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrate(context.Background(), c.DBEngine, db); err != nil {
+		return nil, err
+	}
+
+	mgr := &manager{
+		c:  c,
+		db: db,
+	}
+	go mgr.startJanitorRun()
+
+	return mgr, nil
+}
+
+// Ready tells whether the database is reachable.
+func (m *manager) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *manager) startJanitorRun() {
+	if !m.c.EnableExpiredSharesCleanup {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(m.c.JanitorRunInterval) * time.Second)
+	work := make(chan os.Signal, 1)
+	signal.Notify(work, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+
+	for {
+		select {
+		case <-work:
+			return
+		case <-ticker.C:
+			if err := m.cleanupExpiredShares(context.Background()); err != nil {
+				log := logger.New().With().Int("pid", os.Getpid()).Logger()
+				log.Error().Err(err).Msg("sql: error removing expired public shares")
+			}
+		}
+	}
+}
+
+func (m *manager) cleanupExpiredShares(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "DELETE FROM public_shares WHERE expiration IS NOT NULL AND expiration < ?", time.Now().Unix())
+	return err
+}
+
+// CreatePublicShare stores a new public share, hashing its password if any.
+func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *provider.ResourceInfo, g *link.Grant, description string, internal bool) (*link.PublicShare, error) {
+	tkn := utils.RandString(15)
+	now := time.Now().Unix()
+
+	displayName, ok := rInfo.ArbitraryMetadata.GetMetadata()["name"]
+	if !ok {
+		displayName = tkn
+	}
+
+	var password string
+	if g.Password != "" {
+		h, err := bcrypt.GenerateFromPassword([]byte(g.Password), m.c.SharePasswordHashCost)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not hash share password")
+		}
+		password = string(h)
+	}
+
+	perms, err := utils.MarshalProtoV1ToJSON(g.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := &typespb.Timestamp{
+		Seconds: uint64(now),
+	}
+	s := &link.PublicShare{
+		Id: &link.PublicShareId{
+			OpaqueId: utils.RandString(15),
+		},
+		Owner:             rInfo.GetOwner(),
+		Creator:           u.Id,
+		ResourceId:        rInfo.Id,
+		Token:             tkn,
+		Permissions:       g.Permissions,
+		Ctime:             createdAt,
+		Mtime:             createdAt,
+		PasswordProtected: password != "",
+		Expiration:        g.Expiration,
+		DisplayName:       displayName,
+		Description:       description,
+	}
+
+	query := "INSERT INTO public_shares (id, token, storage_id, opaque_id, owner_idp, owner_opaque_id, owner_type, creator_idp, creator_opaque_id, creator_type, " +
+		"permissions, password, display_name, description, internal, expiration, ctime, mtime) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	params := []interface{}{
+		s.Id.OpaqueId, s.Token, s.ResourceId.GetStorageId(), s.ResourceId.GetOpaqueId(),
+		s.Owner.GetIdp(), s.Owner.GetOpaqueId(), int(s.Owner.GetType()),
+		s.Creator.GetIdp(), s.Creator.GetOpaqueId(), int(s.Creator.GetType()),
+		string(perms), password, displayName, description, internal, expirationValue(g.Expiration), now, now,
+	}
+	if _, err := m.db.ExecContext(ctx, query, params...); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// UpdatePublicShare updates the display name, permissions, expiration or password of a public share.
+func (m *manager) UpdatePublicShare(ctx context.Context, u *user.User, req *link.UpdatePublicShareRequest, g *link.Grant) (*link.PublicShare, error) {
+	share, err := m.GetPublicShare(ctx, u, req.Ref, false)
+	if err != nil {
+		return nil, errors.New("ref does not exist")
+	}
+
+	var column string
+	var value interface{}
+	switch req.GetUpdate().GetType() {
+	case link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME:
+		share.DisplayName = req.Update.GetDisplayName()
+		column, value = "display_name", share.DisplayName
+	case link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS:
+		share.Permissions = req.Update.GetGrant().GetPermissions()
+		perms, err := utils.MarshalProtoV1ToJSON(share.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		column, value = "permissions", string(perms)
+	case link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION:
+		share.Expiration = req.Update.GetGrant().GetExpiration()
+		column, value = "expiration", expirationValue(share.Expiration)
+	case link.UpdatePublicShareRequest_Update_TYPE_PASSWORD:
+		var password string
+		if req.Update.GetGrant().GetPassword() != "" {
+			h, err := bcrypt.GenerateFromPassword([]byte(req.Update.GetGrant().GetPassword()), m.c.SharePasswordHashCost)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not hash share password")
+			}
+			password = string(h)
+		}
+		share.PasswordProtected = password != ""
+		column, value = "password", password
+	default:
+		return nil, fmt.Errorf("invalid update type: %v", req.GetUpdate().GetType())
+	}
+
+	now := time.Now().Unix()
+	share.Mtime = &typespb.Timestamp{
+		Seconds: uint64(now),
+	}
+	if _, err := m.db.ExecContext(ctx, "UPDATE public_shares SET "+column+"=?, mtime=? WHERE id=?", value, now, share.Id.OpaqueId); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// GetPublicShare gets a public share either by ID or Token.
+func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference, sign bool) (*link.PublicShare, error) {
+	var s *link.PublicShare
+	var pw string
+	var err error
+	switch {
+	case ref.GetToken() != "":
+		s, pw, err = m.getByToken(ctx, u, ref.GetToken())
+	case ref.GetId().GetOpaqueId() != "":
+		s, pw, err = m.getByID(ctx, u, ref.GetId())
+	default:
+		err = errtypes.NotFound(ref.String())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.PasswordProtected && sign {
+		if err := publicshare.AddSignature(s, pw); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ListPublicShares returns the valid shares the user owns or created.
+func (m *manager) ListPublicShares(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter, md *provider.ResourceInfo, sign bool) ([]*link.PublicShare, error) {
+	query := "SELECT " + shareColumns + " FROM public_shares WHERE " + userCondition + " AND (expiration IS NULL OR expiration >= ?)"
+	params := append(userParams(u.Id), time.Now().Unix())
+
+	var conds []string
+	for _, f := range publicshare.GroupFiltersByType(filters)[link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID] {
+		conds = append(conds, "(storage_id=? AND opaque_id=?)")
+		params = append(params, f.GetResourceId().GetStorageId(), f.GetResourceId().GetOpaqueId())
+	}
+	if len(conds) > 0 {
+		query += " AND (" + strings.Join(conds, " OR ") + ")"
+	}
+
+	rows, err := m.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*link.PublicShare{}
+	for rows.Next() {
+		s, pw, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		if len(filters) > 0 && !publicshare.MatchesFilters(s, filters) {
+			continue
+		}
+		if s.PasswordProtected && sign {
+			if err := publicshare.AddSignature(s, pw); err != nil {
+				return nil, err
+			}
+		}
+		shares = append(shares, s)
+	}
+	return shares, rows.Err()
+}
+
+// RevokePublicShare removes a public share.
+func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference) error {
+	var res sql.Result
+	var err error
+	switch {
+	case ref.GetToken() != "":
+		query := "DELETE FROM public_shares WHERE token=?"
+		params := []interface{}{ref.GetToken()}
+		if u != nil {
+			query += " AND " + userCondition
+			params = append(params, userParams(u.Id)...)
+		}
+		res, err = m.db.ExecContext(ctx, query, params...)
+	case ref.GetId().GetOpaqueId() != "":
+		query := "DELETE FROM public_shares WHERE id=?"
+		params := []interface{}{ref.GetId().GetOpaqueId()}
+		if u != nil {
+			query += " AND " + userCondition
+			params = append(params, userParams(u.Id)...)
+		}
+		res, err = m.db.ExecContext(ctx, query, params...)
+	default:
+		return errtypes.NotFound(ref.String())
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errtypes.NotFound(ref.String())
+	}
+	return nil
+}
+
+// GetPublicShareByToken gets a public share by its opaque token, checking the given authentication
+// if the share is password protected.
+func (m *manager) GetPublicShareByToken(ctx context.Context, token string, auth *link.PublicShareAuthentication, sign bool) (*link.PublicShare, error) {
+	s, pw, err := m.getByToken(ctx, nil, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.PasswordProtected {
+		if !authenticate(s, pw, auth) {
+			return nil, errtypes.InvalidCredentials("sql: invalid password")
+		}
+		if sign {
+			if err := publicshare.AddSignature(s, pw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+func (m *manager) getByToken(ctx context.Context, u *user.User, token string) (*link.PublicShare, string, error) {
+	query := "SELECT " + shareColumns + " FROM public_shares WHERE token=?"
+	params := []interface{}{token}
+	if u != nil {
+		query += " AND " + userCondition
+		params = append(params, userParams(u.Id)...)
+	}
+	return m.valid(ctx, m.db.QueryRowContext(ctx, query, params...), fmt.Sprintf("share with token: `%v` not found", token))
+}
+
+func (m *manager) getByID(ctx context.Context, u *user.User, id *link.PublicShareId) (*link.PublicShare, string, error) {
+	query := "SELECT " + shareColumns + " FROM public_shares WHERE id=?"
+	params := []interface{}{id.OpaqueId}
+	if u != nil {
+		query += " AND " + userCondition
+		params = append(params, userParams(u.Id)...)
+	}
+	return m.valid(ctx, m.db.QueryRowContext(ctx, query, params...), "no shares found by id:"+id.String())
+}
+
+// valid scans the share in the row and reports expired shares as not found,
+// removing them right away if the cleanup of expired shares is enabled.
+func (m *manager) valid(ctx context.Context, row *sql.Row, notFound string) (*link.PublicShare, string, error) {
+	s, pw, err := scanShare(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", errtypes.NotFound(notFound)
+		}
+		return nil, "", err
+	}
+
+	if publicshare.IsExpired(s) {
+		if m.c.EnableExpiredSharesCleanup {
+			if _, err := m.db.ExecContext(ctx, "DELETE FROM public_shares WHERE id=?", s.Id.OpaqueId); err != nil {
+				return nil, "", err
+			}
+		}
+		return nil, "", errtypes.NotFound(notFound)
+	}
+	return s, pw, nil
+}
+
+func userParams(u *user.UserId) []interface{} {
+	return []interface{}{u.GetIdp(), u.GetOpaqueId(), u.GetIdp(), u.GetOpaqueId()}
+}
+
+func expirationValue(e *typespb.Timestamp) interface{} {
+	if e == nil || e.Seconds == 0 {
+		return nil
+	}
+	return int64(e.Seconds)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanShare(s scanner) (*link.PublicShare, string, error) {
+	var (
+		id, token, storageID, opaqueID            string
+		ownerIdp, ownerID, creatorIdp, creatorID  string
+		perms, password, displayName, description string
+		ownerType, creatorType                    int
+		expiration                                sql.NullInt64
+		ctime, mtime                              int64
+	)
+	if err := s.Scan(&id, &token, &storageID, &opaqueID, &ownerIdp, &ownerID, &ownerType, &creatorIdp, &creatorID, &creatorType,
+		&perms, &password, &displayName, &description, &expiration, &ctime, &mtime); err != nil {
+		return nil, "", err
+	}
+
+	permissions := &link.PublicSharePermissions{}
+	if err := utils.UnmarshalJSONToProtoV1([]byte(perms), permissions); err != nil {
+		return nil, "", err
+	}
+
+	share := &link.PublicShare{
+		Id:                &link.PublicShareId{OpaqueId: id},
+		Token:             token,
+		ResourceId:        &provider.ResourceId{StorageId: storageID, OpaqueId: opaqueID},
+		Owner:             &user.UserId{Idp: ownerIdp, OpaqueId: ownerID, Type: user.UserType(ownerType)},
+		Creator:           &user.UserId{Idp: creatorIdp, OpaqueId: creatorID, Type: user.UserType(creatorType)},
+		Permissions:       permissions,
+		PasswordProtected: password != "",
+		DisplayName:       displayName,
+		Description:       description,
+		Ctime:             &typespb.Timestamp{Seconds: uint64(ctime)},
+		Mtime:             &typespb.Timestamp{Seconds: uint64(mtime)},
+	}
+	if expiration.Valid {
+		share.Expiration = &typespb.Timestamp{Seconds: uint64(expiration.Int64)}
+	}
+	return share, password, nil
+}
+
+func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuthentication) bool {
+	switch {
+	case auth.GetPassword() != "":
+		if err := bcrypt.CompareHashAndPassword([]byte(pw), []byte(auth.GetPassword())); err == nil {
+			return true
+		}
+	case auth.GetSignature() != nil:
+		sig := auth.GetSignature()
+		now := time.Now()
+		expiration := time.Unix(int64(sig.GetSignatureExpiration().GetSeconds()), int64(sig.GetSignatureExpiration().GetNanos()))
+		if now.After(expiration) {
+			return false
+		}
+		s, err := publicshare.CreateSignature(share.Token, pw, expiration)
+		if err != nil {
+			return false
+		}
+		return sig.GetSignature() == s
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/publicshare"
+)
+
+var (
+	einstein = &user.User{Id: &user.UserId{Idp: "localhost", OpaqueId: "einstein"}}
+	marie    = &user.User{Id: &user.UserId{Idp: "localhost", OpaqueId: "marie"}}
+	resource = &provider.ResourceInfo{
+		Id:                &provider.ResourceId{StorageId: "storage", OpaqueId: "file"},
+		Owner:             einstein.Id,
+		ArbitraryMetadata: &provider.ArbitraryMetadata{Metadata: map[string]string{"name": "file"}},
+	}
+)
+
+func newTestManager(t *testing.T) *manager {
+	m, err := New(map[string]interface{}{
+		"db_engine":          "sqlite3",
+		"db_file":            filepath.Join(t.TempDir(), "publicshares.db"),
+		"password_hash_cost": 4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m.(*manager)
+}
+
+func TestMigrate(t *testing.T) {
+	m := newTestManager(t)
+	// running the migrations again must be a no-op
+	if err := migrate(context.Background(), "sqlite3", m.db); err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	if err := m.db.QueryRow("SELECT MAX(version) FROM public_share_migrations").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations["sqlite3"]) {
+		t.Fatalf("expected schema version %d, got %d", len(migrations["sqlite3"]), version)
+	}
+}
+
+func TestPasswordProtectedShare(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	s, err := m.CreatePublicShare(ctx, einstein, resource, &link.Grant{
+		Permissions: &link.PublicSharePermissions{Permissions: &provider.ResourcePermissions{Stat: true}},
+		Password:    "secret",
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.PasswordProtected {
+		t.Fatal("expected the share to be password protected")
+	}
+
+	var stored string
+	if err := m.db.QueryRow("SELECT password FROM public_shares WHERE id=?", s.Id.OpaqueId).Scan(&stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored == "secret" {
+		t.Fatal("the password is stored in clear text")
+	}
+
+	if _, err := m.GetPublicShareByToken(ctx, s.Token, &link.PublicShareAuthentication{
+		Spec: &link.PublicShareAuthentication_Password{Password: "wrong"},
+	}, false); err == nil {
+		t.Fatal("expected an error for a wrong password")
+	}
+
+	signed, err := m.GetPublicShareByToken(ctx, s.Token, &link.PublicShareAuthentication{
+		Spec: &link.PublicShareAuthentication_Password{Password: "secret"},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Signature == nil {
+		t.Fatal("expected a signature")
+	}
+
+	// the signature works as authentication in place of the password
+	if _, err := m.GetPublicShareByToken(ctx, s.Token, &link.PublicShareAuthentication{
+		Spec: &link.PublicShareAuthentication_Signature{Signature: signed.Signature},
+	}, false); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := publicshare.CreateSignature(s.Token, stored, time.Unix(int64(signed.Signature.SignatureExpiration.Seconds), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected != signed.Signature.Signature {
+		t.Fatal("the signature does not match publicshare.CreateSignature")
+	}
+}
+
+func TestUpdateListAndRevoke(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	s, err := m.CreatePublicShare(ctx, einstein, resource, &link.Grant{
+		Permissions: &link.PublicSharePermissions{Permissions: &provider.ResourcePermissions{Stat: true}},
+	}, "description", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: s.Id}}
+
+	if _, err := m.UpdatePublicShare(ctx, einstein, &link.UpdatePublicShareRequest{
+		Ref:    ref,
+		Update: &link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := m.ListPublicShares(ctx, einstein, []*link.ListPublicSharesRequest_Filter{publicshare.ResourceIDFilter(resource.Id)}, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 1 || shares[0].DisplayName != "renamed" || shares[0].Description != "description" {
+		t.Fatalf("unexpected shares: %v", shares)
+	}
+
+	if shares, _ := m.ListPublicShares(ctx, marie, nil, nil, false); len(shares) != 0 {
+		t.Fatalf("expected no shares for marie, got %v", shares)
+	}
+	if err := m.RevokePublicShare(ctx, marie, ref); err == nil {
+		t.Fatal("marie must not be able to revoke einstein's share")
+	}
+	if err := m.RevokePublicShare(ctx, einstein, ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetPublicShare(ctx, einstein, ref, false); err == nil {
+		t.Fatal("expected the share to be gone")
+	}
+}
+
+func TestTokenRefsOfOtherUsers(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	s, err := m.CreatePublicShare(ctx, einstein, resource, &link.Grant{
+		Permissions: &link.PublicSharePermissions{Permissions: &provider.ResourcePermissions{Stat: true}},
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: s.Token}}
+
+	if _, err := m.GetPublicShare(ctx, marie, ref, false); err == nil {
+		t.Fatal("marie must not get einstein's share by its token")
+	}
+	if _, err := m.UpdatePublicShare(ctx, marie, &link.UpdatePublicShareRequest{
+		Ref: ref,
+		Update: &link.UpdatePublicShareRequest_Update{
+			Type:  link.UpdatePublicShareRequest_Update_TYPE_PASSWORD,
+			Grant: &link.Grant{Password: "stolen"},
+		},
+	}, nil); err == nil {
+		t.Fatal("marie must not be able to update einstein's share by its token")
+	}
+	if err := m.RevokePublicShare(ctx, marie, ref); err == nil {
+		t.Fatal("marie must not be able to revoke einstein's share by its token")
+	}
+
+	got, err := m.GetPublicShare(ctx, einstein, ref, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PasswordProtected {
+		t.Fatal("the share must not have been changed")
+	}
+	if err := m.RevokePublicShare(ctx, einstein, ref); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpiredShares(t *testing.T) {
+	m := newTestManager(t)
+	ctx := context.Background()
+
+	s, err := m.CreatePublicShare(ctx, einstein, resource, &link.Grant{
+		Permissions: &link.PublicSharePermissions{Permissions: &provider.ResourcePermissions{Stat: true}},
+		Expiration:  &typespb.Timestamp{Seconds: uint64(time.Now().Add(-time.Hour).Unix())},
+	}, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.GetPublicShareByToken(ctx, s.Token, nil, false); err == nil {
+		t.Fatal("expected an expired share not to be found")
+	} else if _, ok := err.(errtypes.NotFound); !ok {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	if shares, _ := m.ListPublicShares(ctx, einstein, nil, nil, false); len(shares) != 0 {
+		t.Fatalf("expected no valid shares, got %v", shares)
+	}
+
+	if err := m.cleanupExpiredShares(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := m.db.QueryRow("SELECT COUNT(*) FROM public_shares").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected the expired share to be removed, %d left", n)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/ratelimit"
+	"github.com/cs3org/reva/pkg/ratelimit/registry"
+)
+
+func init() {
+	registry.Register("memory", New)
+}
+
+// sweepInterval is how often the full buckets are dropped.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  ratelimit.Limit
+}
+
+// full tells whether the bucket has been refilled,
+// in which case it is the same as a new one.
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst)
+}
+
+type store struct {
+	sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// New returns a store keeping the buckets in memory,
+// they are not shared with the other replicas.
+func New(m map[string]interface{}) (ratelimit.Store, error) {
+	return &store{buckets: map[string]*bucket{}, swept: time.Now(), now: time.Now}, nil
+}
+
+func (s *store) Take(ctx context.Context, key string, l ratelimit.Limit, n int) (bool, time.Duration, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	now := s.now()
+	if now.Sub(s.swept) >= sweepInterval {
+		for k, b := range s.buckets {
+			if b.full(now) {
+				delete(s.buckets, k)
+			}
+		}
+		s.swept = now
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		s.buckets[key] = b
+	}
+	tokens, allowed, wait := ratelimit.Refill(b.tokens, now.Sub(b.last), l, n)
+	b.tokens, b.last, b.limit = tokens, now, l
+	return allowed, wait, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/pkg/ratelimit"
+)
+
+func TestRefillAndSweep(t *testing.T) {
+	now := time.Now()
+	s := &store{buckets: map[string]*bucket{}, swept: now, now: func() time.Time { return now }}
+	l := ratelimit.Limit{Rate: 1, Burst: 1}
+
+	if ok, _, _ := s.Take(context.Background(), "k", l, 1); !ok {
+		t.Fatal("expected the first request to be allowed")
+	}
+	if ok, wait, _ := s.Take(context.Background(), "k", l, 1); ok || wait != time.Second {
+		t.Fatalf("expected to wait a second, got %v %v", ok, wait)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _ := s.Take(context.Background(), "k", l, 1); !ok {
+		t.Fatal("expected the bucket to be refilled")
+	}
+
+	now = now.Add(sweepInterval)
+	if ok, _, _ := s.Take(context.Background(), "other", l, 1); !ok {
+		t.Fatal("expected the other key to be allowed")
+	}
+	if _, ok := s.buckets["k"]; ok {
+		t.Fatal("expected the full bucket to be dropped")
+	}
+}
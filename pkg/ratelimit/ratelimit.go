@@ -0,0 +1,220 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package ratelimit throttles the requests of the clients with token buckets,
+// refilled at a constant rate up to a maximum number of tokens.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/pkg/errors"
+)
+
+// The keys the requests can be limited per.
+const (
+	PerUser        = "user"
+	PerIP          = "ip"
+	PerAppPassword = "app_password"
+	PerGlobal      = "global"
+)
+
+// Limit is the configuration of a token bucket.
+type Limit struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the maximum number of tokens in the bucket.
+	Burst int
+}
+
+// Store keeps the token buckets.
+type Store interface {
+	// Take takes n tokens from the bucket of the key, if at least one is left.
+	// Taking none only tells whether the bucket is empty. When the bucket is
+	// empty it returns false and how long to wait for the next token.
+	Take(ctx context.Context, key string, l Limit, n int) (bool, time.Duration, error)
+}
+
+// Refill adds to the tokens the ones earned since the last update and,
+// if at least one is left, takes n of them, returning the tokens left.
+func Refill(tokens float64, elapsed time.Duration, l Limit, n int) (float64, bool, time.Duration) {
+	if elapsed > 0 {
+		tokens = math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+	}
+	if tokens >= 1 {
+		return tokens - float64(n), true, 0
+	}
+	return tokens, false, time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+}
+
+// Rule limits the requests matching it.
+type Rule struct {
+	Match []string `mapstructure:"match" docs:";The prefixes of the grpc methods or of the http paths the rule applies to, all of them when empty."`
+	Per   string   `mapstructure:"per" docs:"user;What the requests are counted per, one of user, ip, app_password or global. The requests without a user or an app password are counted per ip."`
+	Rate  float64  `mapstructure:"rate" docs:";The number of requests allowed per second."`
+	Burst int      `mapstructure:"burst" docs:";The number of requests allowed at once, the rate rounded up by default."`
+}
+
+func (r *Rule) init() error {
+	if r.Per == "" {
+		r.Per = PerUser
+	}
+	switch r.Per {
+	case PerUser, PerIP, PerAppPassword, PerGlobal:
+	default:
+		return fmt.Errorf("ratelimit: unknown key %q", r.Per)
+	}
+	if r.Rate <= 0 {
+		return errors.New("ratelimit: the rate must be positive")
+	}
+	if r.Burst <= 0 {
+		r.Burst = int(math.Ceil(r.Rate))
+	}
+	return nil
+}
+
+func (r *Rule) matches(method string) bool {
+	if len(r.Match) == 0 {
+		return true
+	}
+	for _, prefix := range r.Match {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// key returns the key of the bucket of the request.
+func (r *Rule) key(req *Request) string {
+	switch {
+	case r.Per == PerGlobal:
+		return PerGlobal
+	case r.Per == PerUser && req.User != nil:
+		return PerUser + ":" + req.User.GetId().GetIdp() + ":" + req.User.GetId().GetOpaqueId()
+	case r.Per == PerAppPassword && req.Credential != "":
+		// the credentials are secrets, they must not end up in the store
+		sum := sha256.Sum256([]byte(req.Credential))
+		return PerAppPassword + ":" + hex.EncodeToString(sum[:])
+	default:
+		return PerIP + ":" + req.IP
+	}
+}
+
+// Request describes the request to limit.
+type Request struct {
+	// Method is the grpc method or the http path.
+	Method string
+	// User is the user logged in, if any.
+	User *userpb.User
+	// IP is the address of the client.
+	IP string
+	// Credential is the app password, or the token, of the client.
+	Credential string
+}
+
+// Limiter applies the rules to the requests.
+type Limiter struct {
+	rules []*Rule
+	store Store
+}
+
+// NewLimiter returns a limiter applying the rules,
+// keeping the buckets in the store.
+func NewLimiter(rules []*Rule, store Store) (*Limiter, error) {
+	for _, r := range rules {
+		if err := r.init(); err != nil {
+			return nil, err
+		}
+	}
+	return &Limiter{rules: rules, store: store}, nil
+}
+
+// Allow takes a token from the buckets of all the rules matching the
+// request. When one is empty it returns false and how long to wait.
+func (l *Limiter) Allow(ctx context.Context, req *Request) (bool, time.Duration, error) {
+	for i, r := range l.rules {
+		if !r.matches(req.Method) {
+			continue
+		}
+		ok, wait, err := l.store.Take(ctx, fmt.Sprintf("%d:%s", i, r.key(req)), Limit{Rate: r.Rate, Burst: r.Burst}, 1)
+		if err != nil {
+			return false, 0, errors.Wrap(err, "ratelimit: error taking a token")
+		}
+		if !ok {
+			return false, wait, nil
+		}
+	}
+	return true, 0, nil
+}
+
+// FailedAuth limits per ip the requests failing the authentication, which are
+// rejected before reaching the limiter. The requests of an ip are refused while
+// its bucket is empty, and only the failed ones take a token.
+type FailedAuth struct {
+	limit Limit
+	store Store
+}
+
+// NewFailedAuth returns a limiter of the failed authentications, with the rate and
+// the burst of the rule, keeping the buckets in the store. The rule is counted per ip.
+func NewFailedAuth(r *Rule, store Store) (*FailedAuth, error) {
+	r.Per = PerIP
+	if err := r.init(); err != nil {
+		return nil, err
+	}
+	return &FailedAuth{limit: Limit{Rate: r.Rate, Burst: r.Burst}, store: store}, nil
+}
+
+// Allow tells whether the ip has failures left. When not,
+// it returns false and how long to wait.
+func (f *FailedAuth) Allow(ctx context.Context, ip string) (bool, time.Duration, error) {
+	ok, wait, err := f.store.Take(ctx, f.key(ip), f.limit, 0)
+	if err != nil {
+		return false, 0, errors.Wrap(err, "ratelimit: error checking the failures")
+	}
+	return ok, wait, nil
+}
+
+// Fail takes a token from the bucket of the ip, after a failed authentication.
+func (f *FailedAuth) Fail(ctx context.Context, ip string) error {
+	if _, _, err := f.store.Take(ctx, f.key(ip), f.limit, 1); err != nil {
+		return errors.Wrap(err, "ratelimit: error counting the failure")
+	}
+	return nil
+}
+
+func (f *FailedAuth) key(ip string) string {
+	return "failed_auth:" + PerIP + ":" + ip
+}
+
+// RetryAfter returns the seconds to wait, rounded up,
+// as expected by the Retry-After header.
+func RetryAfter(wait time.Duration) int {
+	if s := int(math.Ceil(wait.Seconds())); s > 0 {
+		return s
+	}
+	return 1
+}
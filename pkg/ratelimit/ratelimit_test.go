@@ -0,0 +1,109 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/ratelimit"
+	"github.com/cs3org/reva/pkg/ratelimit/memory"
+)
+
+func TestRefill(t *testing.T) {
+	l := ratelimit.Limit{Rate: 2, Burst: 4}
+
+	tokens, ok, _ := ratelimit.Refill(0.5, time.Second, l, 1)
+	if !ok || tokens != 1.5 {
+		t.Fatalf("expected a token to be taken, got %v %v", tokens, ok)
+	}
+	tokens, ok, _ = ratelimit.Refill(3, time.Hour, l, 1)
+	if !ok || tokens != 3 {
+		t.Fatalf("expected the bucket to be capped at the burst, got %v %v", tokens, ok)
+	}
+	tokens, ok, wait := ratelimit.Refill(0.5, 0, l, 1)
+	if ok || tokens != 0.5 || wait != 250*time.Millisecond {
+		t.Fatalf("expected to wait for a token, got %v %v %v", tokens, ok, wait)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	store, _ := memory.New(nil)
+	l, err := ratelimit.NewLimiter([]*ratelimit.Rule{
+		{Match: []string{"/cs3.gateway.v1beta1.GatewayAPI/Stat"}, Per: ratelimit.PerUser, Rate: 0.001, Burst: 2},
+		{Per: ratelimit.PerIP, Rate: 0.001, Burst: 5},
+	}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	einstein := &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}}
+	marie := &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "marie"}}
+	allow := func(method string, u *userpb.User, ip string) bool {
+		ok, wait, err := l.Allow(context.Background(), &ratelimit.Request{Method: method, User: u, IP: ip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok && wait <= 0 {
+			t.Fatal("expected a positive wait when rejected")
+		}
+		return ok
+	}
+
+	stat := "/cs3.gateway.v1beta1.GatewayAPI/Stat"
+	if !allow(stat, einstein, "10.0.0.1") || !allow(stat, einstein, "10.0.0.1") {
+		t.Fatal("expected the burst to be allowed")
+	}
+	if allow(stat, einstein, "10.0.0.1") {
+		t.Fatal("expected the third stat of the user to be rejected")
+	}
+	if !allow(stat, marie, "10.0.0.2") {
+		t.Fatal("expected the users to be limited separately")
+	}
+	// the rejected call consumed no token of the second rule
+	for i := 0; i < 3; i++ {
+		if !allow("/cs3.gateway.v1beta1.GatewayAPI/ListContainer", nil, "10.0.0.1") {
+			t.Fatalf("expected call %d from the ip to be allowed", i)
+		}
+	}
+	if allow("/cs3.gateway.v1beta1.GatewayAPI/ListContainer", nil, "10.0.0.1") {
+		t.Fatal("expected the ip to exceed its limit")
+	}
+}
+
+func TestRuleValidation(t *testing.T) {
+	store, _ := memory.New(nil)
+	if _, err := ratelimit.NewLimiter([]*ratelimit.Rule{{Rate: 0}}, store); err == nil {
+		t.Fatal("expected a rule without rate to be rejected")
+	}
+	if _, err := ratelimit.NewLimiter([]*ratelimit.Rule{{Per: "planet", Rate: 1}}, store); err == nil {
+		t.Fatal("expected an unknown key to be rejected")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if s := ratelimit.RetryAfter(1500 * time.Millisecond); s != 2 {
+		t.Fatalf("expected 2 seconds, got %d", s)
+	}
+	if s := ratelimit.RetryAfter(0); s != 1 {
+		t.Fatalf("expected at least 1 second, got %d", s)
+	}
+}
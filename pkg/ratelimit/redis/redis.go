@@ -0,0 +1,138 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/pkg/ratelimit"
+	"github.com/cs3org/reva/pkg/ratelimit/registry"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("redis", New)
+}
+
+const keyPrefix = "reva:ratelimit:"
+
+// take refills the bucket and takes the tokens atomically, the same way as
+// ratelimit.Refill. The bucket expires once it would be full again.
+var take = redis.NewScript(1, `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local n = tonumber(ARGV[4])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1])
+local last = tonumber(bucket[2])
+if tokens == nil or last == nil then
+	tokens = burst
+	last = now
+end
+if now > last then
+	tokens = math.min(burst, tokens + (now - last) * rate)
+end
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - n
+	allowed = 1
+else
+	wait = (1 - tokens) / rate
+end
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", tostring(now))
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(wait)}
+`)
+
+type config struct {
+	RedisAddress  string `mapstructure:"redis_address" docs:"localhost:6379;The address of the redis server."`
+	RedisUsername string `mapstructure:"redis_username" docs:";The username to authenticate to redis."`
+	RedisPassword string `mapstructure:"redis_password" docs:";The password to authenticate to redis."`
+}
+
+type store struct {
+	redisPool *redis.Pool
+}
+
+// New returns a store keeping the buckets in redis,
+// shared by all the replicas using the same server.
+func New(m map[string]interface{}) (ratelimit.Store, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+
+	if c.RedisAddress == "" {
+		c.RedisAddress = "localhost:6379"
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+
+		Dial: func() (redis.Conn, error) {
+			var opts []redis.DialOption
+			if c.RedisUsername != "" {
+				opts = append(opts, redis.DialUsername(c.RedisUsername))
+			}
+			if c.RedisPassword != "" {
+				opts = append(opts, redis.DialPassword(c.RedisPassword))
+			}
+			return redis.Dial("tcp", c.RedisAddress, opts...)
+		},
+
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	return &store{redisPool: pool}, nil
+}
+
+func (s *store) Take(ctx context.Context, key string, l ratelimit.Limit, n int) (bool, time.Duration, error) {
+	conn, err := s.redisPool.GetContext(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+	defer conn.Close()
+
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	res, err := redis.Values(take.Do(conn, keyPrefix+key, l.Rate, l.Burst, strconv.FormatFloat(now, 'f', 6, 64), n))
+	if err != nil {
+		return false, 0, err
+	}
+	var allowed int
+	var wait string
+	if _, err := redis.Scan(res, &allowed, &wait); err != nil {
+		return false, 0, err
+	}
+	seconds, err := strconv.ParseFloat(wait, 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return allowed == 1, time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package file implements a service registry shared by the revad processes
+// through a folder, e.g. on a shared filesystem. Every node is a file named
+// after its ID in the folder of its service, refreshed by the heartbeats of
+// its process and ignored once it has not been refreshed within the ttl. The
+// files are removed by their process when it deregisters, or swept by the
+// heartbeats of the other processes long after they expired.
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("file", New)
+}
+
+type config struct {
+	Root  string `mapstructure:"root" docs:"/var/tmp/reva/registry;The folder shared by the revad processes to register their services."`
+	TTL   int    `mapstructure:"ttl" docs:"30;The seconds after which a node not refreshed is considered gone."`
+	Sweep int    `mapstructure:"sweep" docs:"10 times the ttl;The seconds after which the files of the nodes not refreshed, left by the processes which did not deregister, are removed."`
+}
+
+func (c *config) init() {
+	if c.Root == "" {
+		c.Root = "/var/tmp/reva/registry"
+	}
+	if c.TTL == 0 {
+		c.TTL = 30
+	}
+	if c.Sweep == 0 {
+		c.Sweep = 10 * c.TTL
+	}
+}
+
+type fileRegistry struct {
+	root  string
+	ttl   time.Duration
+	sweep time.Duration
+}
+
+// New returns a registry keeping the services in a folder.
+func New(m map[string]interface{}) (registry.Registry, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+	c.init()
+
+	if err := os.MkdirAll(c.Root, 0700); err != nil {
+		return nil, errors.Wrap(err, "registry: error creating the root folder")
+	}
+	return &fileRegistry{
+		root:  c.Root,
+		ttl:   time.Duration(c.TTL) * time.Second,
+		sweep: time.Duration(c.Sweep) * time.Second,
+	}, nil
+}
+
+// record is the content of the file of a node.
+type record struct {
+	ID       string            `json:"id"`
+	Address  string            `json:"address"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (r *fileRegistry) serviceDir(name string) string {
+	return filepath.Join(r.root, url.PathEscape(name))
+}
+
+func (r *fileRegistry) nodeFile(service, id string) string {
+	return filepath.Join(r.serviceDir(service), url.PathEscape(id)+".json")
+}
+
+// Add writes the files of the nodes, through a rename for
+// the readers never to see a partially written file, and
+// sweeps the files left in the folder of the service.
+func (r *fileRegistry) Add(svc registry.Service) error {
+	dir := r.serviceDir(svc.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "registry: error creating the service folder")
+	}
+	for _, n := range svc.Nodes() {
+		data, err := json.Marshal(&record{ID: n.ID(), Address: n.Address(), Metadata: n.Metadata()})
+		if err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(dir, ".node-")
+		if err != nil {
+			return errors.Wrap(err, "registry: error writing node")
+		}
+		_, err = tmp.Write(data)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), r.nodeFile(svc.Name(), n.ID()))
+		}
+		if err != nil {
+			_ = os.Remove(tmp.Name())
+			return errors.Wrap(err, "registry: error writing node")
+		}
+	}
+	r.sweepService(dir)
+	return nil
+}
+
+// sweepService removes the files of the nodes, and the temporary files of the
+// writes, which have not been refreshed for longer than the sweep interval.
+func (r *fileRegistry) sweepService(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || (filepath.Ext(e.Name()) != ".json" && !strings.HasPrefix(e.Name(), ".node-")) {
+			continue
+		}
+		if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > r.sweep {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+func (r *fileRegistry) Remove(svc registry.Service) error {
+	for _, n := range svc.Nodes() {
+		if err := os.Remove(r.nodeFile(svc.Name(), n.ID())); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "registry: error removing node")
+		}
+	}
+	return nil
+}
+
+// GetService returns the nodes refreshed within the ttl, sorted by ID.
+// The files of the expired ones are only skipped: removing them here
+// would race with the heartbeats replacing them.
+func (r *fileRegistry) GetService(name string) (registry.Service, error) {
+	entries, err := os.ReadDir(r.serviceDir(name))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "registry: error reading service")
+	}
+
+	records := []*record{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		file := filepath.Join(r.serviceDir(name), e.Name())
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		if time.Since(info.ModTime()) > r.ttl {
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			// removed in the meantime
+			continue
+		}
+		rec := &record{}
+		if err := json.Unmarshal(data, rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("service %v not found", name)
+	}
+
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	nodes := make([]registry.Node, 0, len(records))
+	for _, rec := range records {
+		nodes = append(nodes, registry.NewNode(rec.ID, rec.Address, rec.Metadata))
+	}
+	return registry.NewService(name, nodes...), nil
+}
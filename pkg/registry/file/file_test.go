@@ -0,0 +1,95 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package file
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/pkg/registry"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := New(map[string]interface{}{"root": t.TempDir(), "ttl": 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a second process sharing the folder
+	other, err := New(map[string]interface{}{"root": r.(*fileRegistry).root, "ttl": 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	home := registry.NewNode("storage-1:19000-42", "storage-1:19000", map[string]string{"mount_id": "home"})
+	project := registry.NewNode("storage-2:19000-43", "storage-2:19000", map[string]string{"mount_id": "project"})
+	if err := r.Add(registry.NewService("storageprovider", home)); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Add(registry.NewService("storageprovider", project)); err != nil {
+		t.Fatal(err)
+	}
+	// a heartbeat replaces the node
+	if err := r.Add(registry.NewService("storageprovider", home)); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := other.GetService("storageprovider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(svc.Nodes()) != 2 {
+		t.Fatalf("expected 2 nodes, got %d", len(svc.Nodes()))
+	}
+	selected := registry.Select(svc, map[string]string{"mount_id": "project"})
+	if len(selected) != 1 || selected[0].Address() != "storage-2:19000" {
+		t.Fatalf("unexpected selection %v", selected)
+	}
+
+	// the node of a process which died without deregistering expires
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(r.(*fileRegistry).nodeFile("storageprovider", project.ID()), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if svc, err = r.GetService("storageprovider"); err != nil || len(svc.Nodes()) != 1 {
+		t.Fatalf("expected the expired node to be ignored, got %v %v", svc, err)
+	}
+	expired := r.(*fileRegistry).nodeFile("storageprovider", project.ID())
+	if _, err := os.Stat(expired); err != nil {
+		t.Fatalf("expected the expired node to be left to the sweeps, got %v", err)
+	}
+	// until the heartbeats sweep it, long after
+	old = time.Now().Add(-time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(registry.NewService("storageprovider", home)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired node to be swept, got %v", err)
+	}
+
+	if err := r.Remove(registry.NewService("storageprovider", home)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.GetService("storageprovider"); err == nil {
+		t.Fatal("expected the service to be gone with its last node")
+	}
+}
@@ -51,7 +51,39 @@ func (r *Registry) Add(svc registry.Service) error {
 		return nil
 	}
 
-	r.services[svc.Name()] = svc
+	// keep a copy, the caller may reuse the service
+	s := service{name: svc.Name(), nodes: make([]node, 0)}
+	s.mergeNodes(svc.Nodes(), nil)
+	r.services[svc.Name()] = s
+	return nil
+}
+
+// Remove implements the Registry interface. The service is forgotten together with its last node.
+func (r *Registry) Remove(svc registry.Service) error {
+	r.Lock()
+	defer r.Unlock()
+
+	known, ok := r.services[svc.Name()]
+	if !ok {
+		return nil
+	}
+
+	removed := map[string]bool{}
+	for _, n := range svc.Nodes() {
+		removed[n.ID()] = true
+	}
+	s := service{name: svc.Name(), nodes: make([]node, 0)}
+	for _, n := range known.Nodes() {
+		if !removed[n.ID()] {
+			s.nodes = append(s.nodes, node{id: n.ID(), address: n.Address(), metadata: n.Metadata()})
+		}
+	}
+
+	if len(s.nodes) == 0 {
+		delete(r.services, svc.Name())
+		return nil
+	}
+	r.services[svc.Name()] = s
 	return nil
 }
 
@@ -68,6 +100,12 @@ func (r *Registry) GetService(name string) (registry.Service, error) {
 	return nil, fmt.Errorf("service %v not found", name)
 }
 
+func init() {
+	registry.Register("memory", func(m map[string]interface{}) (registry.Registry, error) {
+		return New(m), nil
+	})
+}
+
 // New returns an implementation of the Registry interface.
 func New(m map[string]interface{}) registry.Registry {
 	// c, err := registry.ParseConfig(m)
@@ -133,3 +133,25 @@ func TestGetService(t *testing.T) {
 //		}
 //		return false
 //	}
+
+func TestRemove(t *testing.T) {
+	reg = New(in)
+	_ = reg.Add(service{name: "auth-provider", nodes: []node{node1, node2}})
+	// adding a node again replaces it
+	_ = reg.Add(service{name: "auth-provider", nodes: []node{node1}})
+
+	if err := reg.Remove(service{name: "auth-provider", nodes: []node{node1}}); err != nil {
+		t.Fatal(err)
+	}
+	svc, err := reg.GetService("auth-provider")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, len(svc.Nodes()))
+	assert.Equal(t, node2.ID(), svc.Nodes()[0].ID())
+
+	_ = reg.Remove(service{name: "auth-provider", nodes: []node{node2}})
+	if _, err := reg.GetService("auth-provider"); err == nil {
+		t.Fatal("expected the service to be gone with its last node")
+	}
+}
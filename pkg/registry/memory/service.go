@@ -58,8 +58,18 @@ func (s service) Nodes() []registry.Node {
 	return ret
 }
 
+// mergeNodes adds the new nodes n1 to the known ones n2,
+// the new nodes replacing the known ones with the same ID.
 func (s *service) mergeNodes(n1, n2 []registry.Node) {
-	n1 = append(n1, n2...)
+	added := map[string]bool{}
+	for _, n := range n1 {
+		added[n.ID()] = true
+	}
+	for _, n := range n2 {
+		if !added[n.ID()] {
+			n1 = append(n1, n)
+		}
+	}
 	for _, n := range n1 {
 		s.nodes = append(s.nodes, node{
 			id:       n.ID(),
@@ -18,11 +18,17 @@
 
 package registry
 
+import "fmt"
+
 // Registry provides with means for dynamically registering services.
 type Registry interface {
 	// Add registers a Service on the memoryRegistry. Repeated names is allowed, services are distinguished by their metadata.
+	// Adding a node again, with the same ID, replaces it and refreshes it in the registries expiring the nodes.
 	Add(Service) error
 
+	// Remove deregisters the nodes of a Service, matched by their ID.
+	Remove(Service) error
+
 	// GetService retrieves a Service and all of its nodes by Service name. It returns []*Service because we can have
 	// multiple versions of the same Service running alongside each others.
 	GetService(string) (Service, error)
@@ -47,3 +53,84 @@ type Node interface {
 	// ID returns the node ID.
 	ID() string
 }
+
+// NewFunc is the function that registry backends
+// should register at init time.
+type NewFunc func(map[string]interface{}) (Registry, error)
+
+// NewFuncs is a map containing all the registered registry backends.
+var NewFuncs = map[string]NewFunc{}
+
+// Register registers a new registry backend new function.
+// Not safe for concurrent use. Safe for use from package init.
+func Register(name string, f NewFunc) {
+	NewFuncs[name] = f
+}
+
+// New returns the configured registry backend.
+func New(name string, m map[string]interface{}) (Registry, error) {
+	if f, ok := NewFuncs[name]; ok {
+		return f(m)
+	}
+	return nil, fmt.Errorf("registry backend not found: %s", name)
+}
+
+// Select returns the nodes of the service whose metadata
+// contains all the given key-value pairs.
+func Select(s Service, metadata map[string]string) []Node {
+	nodes := []Node{}
+	for _, n := range s.Nodes() {
+		matches := true
+		for k, v := range metadata {
+			if n.Metadata()[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// NewService returns a Service made of the given nodes, e.g. to add or remove them.
+func NewService(name string, nodes ...Node) Service {
+	return &basicService{name: name, nodes: nodes}
+}
+
+// NewNode returns a Node reachable at the given address.
+func NewNode(id, address string, metadata map[string]string) Node {
+	return &basicNode{id: id, address: address, metadata: metadata}
+}
+
+type basicService struct {
+	name  string
+	nodes []Node
+}
+
+func (s *basicService) Name() string {
+	return s.name
+}
+
+func (s *basicService) Nodes() []Node {
+	return s.nodes
+}
+
+type basicNode struct {
+	id       string
+	address  string
+	metadata map[string]string
+}
+
+func (n *basicNode) Address() string {
+	return n.address
+}
+
+func (n *basicNode) Metadata() map[string]string {
+	return n.metadata
+}
+
+func (n *basicNode) ID() string {
+	return n.id
+}
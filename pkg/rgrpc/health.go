@@ -0,0 +1,100 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package rgrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/cs3org/reva/pkg/health"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// healthWatchInterval is how often the status watched by the clients is checked.
+var healthWatchInterval = 5 * time.Second
+
+// healthEndpoints are the methods of the grpc health service, unprotected
+// so that the probes can call them.
+var healthEndpoints = []string{
+	"/grpc.health.v1.Health/Check",
+	"/grpc.health.v1.Health/Watch",
+}
+
+// healthServer implements the grpc health service. The empty service name
+// stands for the whole server, the other names are the ones of the reva
+// services, e.g. storageprovider.
+type healthServer struct {
+	s *Server
+}
+
+// status returns the serving status of the service, reusing the result of the
+// checks for health.CacheTTL so that the probes do not hit the backends each time.
+func (h *healthServer) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	checker := health.Checker(h.s)
+	if service != "" {
+		svc, ok := h.s.services[service]
+		if !ok {
+			return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, status.Errorf(codes.NotFound, "unknown service %s", service)
+		}
+		checker = health.CheckerFunc(func(ctx context.Context) error {
+			return health.All(ctx, []health.Named{{Name: service, Checker: svc}})
+		})
+	}
+
+	err := health.Cached(ctx, h.s.healthName()+"/"+service, func(ctx context.Context) error {
+		err := checker.Ready(ctx)
+		if err != nil {
+			h.s.log.Warn().Err(err).Str("service", service).Msg("rgrpc: not ready")
+		}
+		return err
+	})
+	if err != nil {
+		return healthpb.HealthCheckResponse_NOT_SERVING, nil
+	}
+	return healthpb.HealthCheckResponse_SERVING, nil
+}
+
+func (h *healthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, err := h.status(ctx, req.Service)
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+func (h *healthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		// the status of unknown services is sent without ending the call
+		st, _ := h.status(stream.Context(), req.Service)
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(healthWatchInterval):
+		}
+	}
+}
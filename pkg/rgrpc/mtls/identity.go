@@ -0,0 +1,58 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package mtls
+
+import (
+	"context"
+	"path"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerID returns the SPIFFE ID of the peer of a grpc call, that is the spiffe URI
+// in the subject alternative names of its certificate. Only certificates verified
+// during the handshake, i.e. with mutual TLS, are considered.
+func PeerID(ctx context.Context) (string, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	for _, u := range info.State.VerifiedChains[0][0].URIs {
+		if u.Scheme == "spiffe" {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
+
+// MatchID tells whether the SPIFFE ID matches one of the patterns, as in path.Match:
+// spiffe://example.org/reva/* matches the IDs of the workloads below /reva.
+func MatchID(id string, patterns []string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, id); ok {
+			return true
+		}
+	}
+	return false
+}
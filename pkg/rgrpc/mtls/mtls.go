@@ -0,0 +1,154 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package mtls configures the TLS and mutual TLS credentials of the grpc
+// servers and clients, reloading the certificates when their files change.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/credentials"
+)
+
+const defaultReloadInterval = 60
+
+// ServerConfig is the TLS configuration of a grpc server. The clients must
+// present a certificate signed by the client CA when one is configured.
+type ServerConfig struct {
+	CertFile       string `mapstructure:"certfile" docs:";The certificate of the server. TLS is disabled if empty."`
+	KeyFile        string `mapstructure:"keyfile" docs:";The key of the server certificate."`
+	ClientCAFile   string `mapstructure:"clientcafile" docs:";The CA to verify the client certificates with. Client certificates are required if set."`
+	ReloadInterval int    `mapstructure:"tls_reload_interval" docs:"60;How often, in seconds, the certificate files are checked for changes."`
+}
+
+// Enabled tells whether TLS is configured.
+func (c *ServerConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+// TLSConfig returns the TLS configuration of the server.
+func (c *ServerConfig) TLSConfig() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, errors.New("mtls: no server certificate configured")
+	}
+	r, err := newReloader(c.CertFile, c.KeyFile, c.ClientCAFile, interval(c.ReloadInterval))
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.certificate()},
+				NextProtos:   []string{"h2"},
+			}
+			if pool := r.caPool(); pool != nil {
+				conf.ClientCAs = pool
+				conf.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return conf, nil
+		},
+	}, nil
+}
+
+// ServerCredentials returns the transport credentials of a grpc server.
+func ServerCredentials(c *ServerConfig) (credentials.TransportCredentials, error) {
+	conf, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(conf), nil
+}
+
+// ClientConfig is the TLS configuration of a grpc client. The client presents
+// its certificate, for mutual TLS, when one is configured.
+type ClientConfig struct {
+	Enabled        bool   `mapstructure:"enabled" docs:"false;Whether to connect with TLS."`
+	CAFile         string `mapstructure:"cafile" docs:";The CA to verify the server certificates with. The system roots are used if empty."`
+	CertFile       string `mapstructure:"certfile" docs:";The certificate presented to the servers requiring mutual TLS."`
+	KeyFile        string `mapstructure:"keyfile" docs:";The key of the client certificate."`
+	ServerName     string `mapstructure:"server_name" docs:";The name expected in the server certificates. Defaults to the host of the endpoint."`
+	ReloadInterval int    `mapstructure:"tls_reload_interval" docs:"60;How often, in seconds, the certificate files are checked for changes."`
+}
+
+// TLSConfig returns the TLS configuration of the client.
+func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
+	r, err := newReloader(c.CertFile, c.KeyFile, c.CAFile, interval(c.ReloadInterval))
+	if err != nil {
+		return nil, err
+	}
+
+	conf := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CertFile != "" {
+		conf.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate(), nil
+		}
+	}
+	if c.CAFile != "" {
+		// the CA may be reloaded, which RootCAs does not allow:
+		// the server certificate is verified against the current pool instead
+		conf.InsecureSkipVerify = true //nolint:gosec
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			return verifyServer(cs, r.caPool())
+		}
+	}
+	return conf, nil
+}
+
+// ClientCredentials returns the transport credentials of a grpc client.
+func ClientCredentials(c *ClientConfig) (credentials.TransportCredentials, error) {
+	conf, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(conf), nil
+}
+
+// verifyServer verifies the certificate chain of the server and its name,
+// as the standard verification would with the pool as root CAs.
+func verifyServer(cs tls.ConnectionState, roots *x509.CertPool) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("mtls: no server certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	return err
+}
+
+func interval(seconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = defaultReloadInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,251 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package mtls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T, dir, name string) *authority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(t, filepath.Join(dir, name+".pem"), "CERTIFICATE", der)
+	return &authority{cert: cert, key: key}
+}
+
+// issue writes a certificate for localhost with the given spiffe id, if any.
+func (a *authority) issue(t *testing.T, dir, name, spiffeID string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if spiffeID != "" {
+		u, err := url.Parse(spiffeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, file, typ string, der []byte) {
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serve starts a grpc server serving the health service, recording the spiffe id of the last peer.
+func serve(t *testing.T, conf *ServerConfig, peerID *string) string {
+	creds, err := ServerCredentials(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(grpc.Creds(creds), grpc.UnaryInterceptor(
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			*peerID, _ = PeerID(ctx)
+			return handler(ctx, req)
+		}))
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(srv.Stop)
+	return ln.Addr().String()
+}
+
+func check(t *testing.T, addr string, conf *ClientConfig) error {
+	creds, err := ClientCredentials(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, dir, "ca")
+	other := newAuthority(t, dir, "other-ca")
+	serverCert, serverKey := ca.issue(t, dir, "server", "")
+	clientCert, clientKey := ca.issue(t, dir, "client", "spiffe://example.org/reva/gateway")
+	untrustedCert, untrustedKey := other.issue(t, dir, "untrusted", "spiffe://example.org/reva/intruder")
+
+	var peerID string
+	addr := serve(t, &ServerConfig{
+		CertFile:     serverCert,
+		KeyFile:      serverKey,
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}, &peerID)
+
+	if err := check(t, addr, &ClientConfig{
+		Enabled:  true,
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		CertFile: clientCert,
+		KeyFile:  clientKey,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if peerID != "spiffe://example.org/reva/gateway" {
+		t.Fatalf("got peer id %q", peerID)
+	}
+
+	tests := map[string]*ClientConfig{
+		"without client certificate": {Enabled: true, CAFile: filepath.Join(dir, "ca.pem")},
+		"with untrusted client certificate": {
+			Enabled:  true,
+			CAFile:   filepath.Join(dir, "ca.pem"),
+			CertFile: untrustedCert,
+			KeyFile:  untrustedKey,
+		},
+		"with untrusted server certificate": {
+			Enabled:  true,
+			CAFile:   filepath.Join(dir, "other-ca.pem"),
+			CertFile: clientCert,
+			KeyFile:  clientKey,
+		},
+	}
+	for name, conf := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := check(t, addr, conf); err == nil {
+				t.Fatal("expected the call to fail")
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newAuthority(t, dir, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", "")
+
+	r, err := newReloader(certFile, keyFile, "", 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := r.certificate().Certificate[0]
+
+	ca.issue(t, dir, "server", "")
+	future := time.Now().Add(time.Minute)
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	if after := r.certificate().Certificate[0]; string(after) == string(before) {
+		t.Fatal("the certificate was not reloaded")
+	}
+
+	// a broken file does not replace the current certificate
+	current := r.certificate()
+	if err := os.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if r.certificate() != current {
+		t.Fatal("the certificate was replaced by a broken one")
+	}
+}
+
+func TestMatchID(t *testing.T) {
+	patterns := []string{"spiffe://example.org/reva/*", "spiffe://example.org/admin"}
+	tests := map[string]bool{
+		"spiffe://example.org/reva/gateway":     true,
+		"spiffe://example.org/admin":            true,
+		"spiffe://example.org/reva/nested/path": false,
+		"spiffe://other.org/reva/gateway":       false,
+	}
+	for id, expected := range tests {
+		if got := MatchID(id, patterns); got != expected {
+			t.Errorf("%s: expected %v got %v", id, expected, got)
+		}
+	}
+}
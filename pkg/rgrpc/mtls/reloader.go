@@ -0,0 +1,154 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/logger"
+	"github.com/pkg/errors"
+)
+
+// reloader holds a certificate and a CA pool loaded from files, reloading
+// them when the files change. The files are checked at most once per interval,
+// when a handshake needs them.
+type reloader struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+
+	mu      sync.Mutex
+	checked time.Time
+	mtimes  map[string]time.Time
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+}
+
+func newReloader(certFile, keyFile, caFile string, interval time.Duration) (*reloader, error) {
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("mtls: both a certificate and a key file must be configured")
+	}
+	r := &reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+	return r, nil
+}
+
+func (r *reloader) files() []string {
+	var files []string
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// load reads the files. It must be called with the lock held, or before the reloader is used.
+func (r *reloader) load() error {
+	mtimes := make(map[string]time.Time)
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return errors.Wrap(err, "mtls: error reading "+f)
+		}
+		mtimes[f] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return errors.Wrap(err, "mtls: error loading the key pair "+r.certFile)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		data, err := os.ReadFile(r.caFile)
+		if err != nil {
+			return errors.Wrap(err, "mtls: error reading "+r.caFile)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return errors.New("mtls: no certificate found in " + r.caFile)
+		}
+	}
+
+	r.cert, r.pool, r.mtimes = cert, pool, mtimes
+	return nil
+}
+
+// reload reloads the files if the interval elapsed since the last check and they
+// changed since. On errors, e.g. while the files are being replaced, the previous
+// certificates are kept.
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < r.interval {
+		return
+	}
+	r.checked = time.Now()
+
+	changed := false
+	for _, f := range r.files() {
+		info, err := os.Stat(f)
+		if err != nil || !info.ModTime().Equal(r.mtimes[f]) {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return
+	}
+
+	log := logger.New().With().Str("pkg", "mtls").Logger()
+	if err := r.load(); err != nil {
+		log.Error().Err(err).Msg("error reloading certificates, keeping the previous ones")
+		return
+	}
+	log.Info().Strs("files", r.files()).Msg("certificates reloaded")
+}
+
+// certificate returns the current certificate, nil if none is configured.
+func (r *reloader) certificate() *tls.Certificate {
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert
+}
+
+// caPool returns the current CA pool, nil if none is configured.
+func (r *reloader) caPool() *x509.CertPool {
+	r.reload()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pool
+}
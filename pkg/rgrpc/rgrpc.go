@@ -19,10 +19,12 @@
 package rgrpc
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"sort"
+	"sync/atomic"
 
 	"github.com/cs3org/reva/internal/grpc/interceptors/appctx"
 	"github.com/cs3org/reva/internal/grpc/interceptors/auth"
@@ -30,6 +32,8 @@ import (
 	"github.com/cs3org/reva/internal/grpc/interceptors/recovery"
 	"github.com/cs3org/reva/internal/grpc/interceptors/token"
 	"github.com/cs3org/reva/internal/grpc/interceptors/useragent"
+	"github.com/cs3org/reva/pkg/health"
+	"github.com/cs3org/reva/pkg/rgrpc/mtls"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	rtrace "github.com/cs3org/reva/pkg/trace"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -38,6 +42,7 @@ import (
 	"github.com/rs/zerolog"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
@@ -47,6 +52,14 @@ var UnaryInterceptors = map[string]NewUnaryInterceptor{}
 // StreamInterceptors is a map of registered streaming grpc interceptor.
 var StreamInterceptors = map[string]NewStreamInterceptor{}
 
+// PreAuthUnaryInterceptors is a map of registered unary grpc interceptors
+// chained before the auth interceptor.
+var PreAuthUnaryInterceptors = map[string]NewUnaryInterceptor{}
+
+// PreAuthStreamInterceptors is a map of registered streaming grpc interceptors
+// chained before the auth interceptor.
+var PreAuthStreamInterceptors = map[string]NewStreamInterceptor{}
+
 // NewUnaryInterceptor is the type that unary interceptors need to register.
 type NewUnaryInterceptor func(m map[string]interface{}) (grpc.UnaryServerInterceptor, int, error)
 
@@ -63,6 +76,20 @@ func RegisterStreamInterceptor(name string, newFunc NewStreamInterceptor) {
 	StreamInterceptors[name] = newFunc
 }
 
+// RegisterPreAuthUnaryInterceptor registers a unary interceptor chained before the auth
+// interceptor, e.g. to see the calls it rejects. It is enabled along with the interceptor
+// of the same name, and it is skipped when newFunc returns a nil interceptor.
+func RegisterPreAuthUnaryInterceptor(name string, newFunc NewUnaryInterceptor) {
+	PreAuthUnaryInterceptors[name] = newFunc
+}
+
+// RegisterPreAuthStreamInterceptor registers a stream interceptor chained before the auth
+// interceptor, e.g. to see the calls it rejects. It is enabled along with the interceptor
+// of the same name, and it is skipped when newFunc returns a nil interceptor.
+func RegisterPreAuthStreamInterceptor(name string, newFunc NewStreamInterceptor) {
+	PreAuthStreamInterceptors[name] = newFunc
+}
+
 // Services is a map of service name and its new function.
 var Services = map[string]NewService{}
 
@@ -75,7 +102,8 @@ func Register(name string, newFunc NewService) {
 // It returns an io.Closer to close the service and a list of service endpoints that need to be unprotected.
 type NewService func(conf map[string]interface{}, ss *grpc.Server) (Service, error)
 
-// Service represents a grpc service.
+// Service represents a grpc service. Services can report their readiness
+// by implementing health.Checker.
 type Service interface {
 	Register(ss *grpc.Server)
 	io.Closer
@@ -101,6 +129,7 @@ type config struct {
 	Services         map[string]map[string]interface{} `mapstructure:"services"`
 	Interceptors     map[string]map[string]interface{} `mapstructure:"interceptors"`
 	EnableReflection bool                              `mapstructure:"enable_reflection"`
+	TLS              mtls.ServerConfig                 `mapstructure:",squash"`
 }
 
 func (c *config) init() {
@@ -120,6 +149,7 @@ type Server struct {
 	listener net.Listener
 	log      zerolog.Logger
 	services map[string]Service
+	serving  atomic.Bool
 }
 
 // NewServer returns a new Server.
@@ -132,10 +162,27 @@ func NewServer(m interface{}, log zerolog.Logger) (*Server, error) {
 	conf.init()
 
 	server := &Server{conf: conf, log: log, services: map[string]Service{}}
+	health.Register(server.healthName(), server)
 
 	return server, nil
 }
 
+func (s *Server) healthName() string {
+	return "grpc@" + s.conf.Address
+}
+
+// Ready tells whether the server serves requests and its services are ready.
+func (s *Server) Ready(ctx context.Context) error {
+	if !s.serving.Load() {
+		return errors.New("rgrpc: server not serving yet")
+	}
+	checkers := make([]health.Named, 0, len(s.services))
+	for name, svc := range s.services {
+		checkers = append(checkers, health.Named{Name: name, Checker: svc})
+	}
+	return health.All(ctx, checkers)
+}
+
 // Start starts the server.
 func (s *Server) Start(ln net.Listener) error {
 	if err := s.registerServices(); err != nil {
@@ -145,6 +192,7 @@ func (s *Server) Start(ln net.Listener) error {
 
 	s.listener = ln
 	s.log.Info().Msgf("grpc server listening at %s:%s", s.Network(), s.Address())
+	s.serving.Store(true)
 	err := s.s.Serve(s.listener)
 	if err != nil {
 		err = errors.Wrap(err, "serve failed")
@@ -188,7 +236,7 @@ func (s *Server) registerServices() error {
 	}
 
 	// obtain list of unprotected endpoints
-	unprotected := []string{}
+	unprotected := append([]string{}, healthEndpoints...)
 	for _, svc := range s.services {
 		unprotected = append(unprotected, svc.UnprotectedEndpoints()...)
 	}
@@ -197,11 +245,25 @@ func (s *Server) registerServices() error {
 	if err != nil {
 		return err
 	}
+
+	if s.conf.TLS.Enabled() {
+		creds, err := mtls.ServerCredentials(&s.conf.TLS)
+		if err != nil {
+			return errors.Wrap(err, "rgrpc: error loading the tls certificates")
+		}
+		opts = append(opts, grpc.Creds(creds))
+		if s.conf.TLS.ClientCAFile != "" {
+			s.log.Info().Msg("rgrpc: grpc server requires mutual tls")
+		} else {
+			s.log.Info().Msg("rgrpc: grpc server uses tls")
+		}
+	}
 	grpcServer := grpc.NewServer(opts...)
 
 	for _, svc := range s.services {
 		svc.Register(grpcServer)
 	}
+	healthpb.RegisterHealthServer(grpcServer, &healthServer{s: s})
 
 	if s.conf.EnableReflection {
 		s.log.Info().Msg("rgrpc: grpc server reflection enabled")
@@ -226,6 +288,7 @@ func (s *Server) cleanupServices() {
 
 // Stop stops the server.
 func (s *Server) Stop() error {
+	health.Unregister(s.healthName())
 	s.cleanupServices()
 	s.s.Stop()
 	return nil
@@ -233,6 +296,7 @@ func (s *Server) Stop() error {
 
 // GracefulStop gracefully stops the server.
 func (s *Server) GracefulStop() error {
+	health.Unregister(s.healthName())
 	s.cleanupServices()
 	s.s.GracefulStop()
 	return nil
@@ -276,7 +340,29 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		return nil, errors.Wrap(err, "rgrpc: error creating unary auth interceptor")
 	}
 
-	unaryInterceptors := []grpc.UnaryServerInterceptor{authUnary}
+	preAuthUnaryTriples := []*unaryInterceptorTriple{}
+	for name, newFunc := range PreAuthUnaryInterceptors {
+		if s.isInterceptorEnabled(name) {
+			inter, prio, err := newFunc(s.conf.Interceptors[name])
+			if err != nil {
+				err = errors.Wrapf(err, "rgrpc: error creating pre-auth unary interceptor: %s,", name)
+				return nil, err
+			}
+			if inter != nil {
+				preAuthUnaryTriples = append(preAuthUnaryTriples, &unaryInterceptorTriple{Name: name, Priority: prio, Interceptor: inter})
+			}
+		}
+	}
+	sort.SliceStable(preAuthUnaryTriples, func(i, j int) bool {
+		return preAuthUnaryTriples[i].Priority < preAuthUnaryTriples[j].Priority
+	})
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{}
+	for _, t := range preAuthUnaryTriples {
+		unaryInterceptors = append(unaryInterceptors, t.Interceptor)
+		s.log.Info().Msgf("rgrpc: chaining grpc unary interceptor %s before auth with priority %d", t.Name, t.Priority)
+	}
+	unaryInterceptors = append(unaryInterceptors, authUnary)
 	for _, t := range unaryTriples {
 		unaryInterceptors = append(unaryInterceptors, t.Interceptor)
 		s.log.Info().Msgf("rgrpc: chaining grpc unary interceptor %s with priority %d", t.Name, t.Priority)
@@ -329,6 +415,29 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		s.log.Info().Msgf("rgrpc: chaining grpc streaming interceptor %s with priority %d", t.Name, t.Priority)
 	}
 
+	preAuthStreamTriples := []*streamInterceptorTriple{}
+	for name, newFunc := range PreAuthStreamInterceptors {
+		if s.isInterceptorEnabled(name) {
+			inter, prio, err := newFunc(s.conf.Interceptors[name])
+			if err != nil {
+				err = errors.Wrapf(err, "rgrpc: error creating pre-auth streaming interceptor: %s,", name)
+				return nil, err
+			}
+			if inter != nil {
+				preAuthStreamTriples = append(preAuthStreamTriples, &streamInterceptorTriple{Name: name, Priority: prio, Interceptor: inter})
+			}
+		}
+	}
+	sort.SliceStable(preAuthStreamTriples, func(i, j int) bool {
+		return preAuthStreamTriples[i].Priority < preAuthStreamTriples[j].Priority
+	})
+
+	preAuthStream := []grpc.StreamServerInterceptor{}
+	for _, t := range preAuthStreamTriples {
+		preAuthStream = append(preAuthStream, t.Interceptor)
+		s.log.Info().Msgf("rgrpc: chaining grpc streaming interceptor %s before auth with priority %d", t.Name, t.Priority)
+	}
+
 	streamInterceptors = append([]grpc.StreamServerInterceptor{
 		authStream,
 		appctx.NewStream(s.log),
@@ -337,6 +446,7 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		log.NewStream(),
 		recovery.NewStream(),
 	}, streamInterceptors...)
+	streamInterceptors = append(preAuthStream, streamInterceptors...)
 	streamChain := grpc_middleware.ChainStreamServer(streamInterceptors...)
 
 	opts := []grpc.ServerOption{
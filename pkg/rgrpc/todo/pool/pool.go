@@ -40,6 +40,11 @@ import (
 	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	storageregistry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
 	datatx "github.com/cs3org/go-cs3apis/cs3/tx/v1beta1"
+	"github.com/cs3org/reva/pkg/maintenance"
+	"github.com/cs3org/reva/pkg/rgrpc/mtls"
+	"github.com/cs3org/reva/pkg/search"
+	"github.com/cs3org/reva/pkg/sharedconf"
+	"github.com/cs3org/reva/pkg/token/session"
 	rtrace "github.com/cs3org/reva/pkg/trace"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
@@ -80,15 +85,26 @@ var (
 	userProviders          = newProvider()
 	groupProviders         = newProvider()
 	dataTxs                = newProvider()
+	sessionsProviders      = newProvider()
+	searchProviders        = newProvider()
 )
 
 // NewConn creates a new connection to a grpc server
-// with open census tracing support.
-// TODO(labkode): make grpc tls configurable.
+// with open census tracing support. The connection uses
+// the tls configuration shared for the endpoint, if enabled.
 func NewConn(options Options) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if c := sharedconf.GetGRPCClientTLS(options.Endpoint); c.Enabled {
+		tlsCreds, err := mtls.ClientCredentials(c)
+		if err != nil {
+			return nil, err
+		}
+		creds = tlsCreds
+	}
+
 	conn, err := grpc.Dial(
 		options.Endpoint,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		grpc.WithDefaultCallOptions(
 			grpc.MaxCallRecvMsgSize(options.MaxCallRecvMsgSize),
 		),
@@ -110,6 +126,7 @@ func NewConn(options Options) (*grpc.ClientConn, error) {
 				),
 			),
 		),
+		grpc.WithChainUnaryInterceptor(maintenance.UnaryClientInterceptor()),
 	)
 	if err != nil {
 		return nil, err
@@ -502,6 +519,46 @@ func GetDataTxClient(opts ...Option) (datatx.TxAPIClient, error) {
 	return v, nil
 }
 
+// GetSessionsClient returns a new SessionsAPIClient, the sessions are served by the gateway.
+func GetSessionsClient(opts ...Option) (session.SessionsAPIClient, error) {
+	sessionsProviders.m.Lock()
+	defer sessionsProviders.m.Unlock()
+
+	options := newOptions(opts...)
+	if c, ok := sessionsProviders.conn[options.Endpoint]; ok {
+		return c.(session.SessionsAPIClient), nil
+	}
+
+	conn, err := NewConn(options)
+	if err != nil {
+		return nil, err
+	}
+
+	v := session.NewSessionsAPIClient(conn)
+	sessionsProviders.conn[options.Endpoint] = v
+	return v, nil
+}
+
+// GetSearchClient returns a new SearchAPIClient, the searches are served by the gateway.
+func GetSearchClient(opts ...Option) (search.SearchAPIClient, error) {
+	searchProviders.m.Lock()
+	defer searchProviders.m.Unlock()
+
+	options := newOptions(opts...)
+	if c, ok := searchProviders.conn[options.Endpoint]; ok {
+		return c.(search.SearchAPIClient), nil
+	}
+
+	conn, err := NewConn(options)
+	if err != nil {
+		return nil, err
+	}
+
+	v := search.NewSearchAPIClient(conn)
+	searchProviders.conn[options.Endpoint] = v
+	return v, nil
+}
+
 // getEndpointByName resolve service names to ip addresses present on the registry.
 //	func getEndpointByName(name string) (string, error) {
 //		if services, err := utils.GlobalRegistry.GetService(name); err == nil {
@@ -0,0 +1,143 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package pool
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/registry"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/resolver"
+)
+
+// RegistryScheme is the scheme of the endpoints resolved through the service
+// registry, e.g. registry:///storageprovider?mount_id=home selects the nodes
+// of the storageprovider service with that metadata. The calls are balanced
+// between the nodes, and the nodes are looked up again periodically.
+const RegistryScheme = "registry"
+
+// resolveInterval is how often the nodes of a service are looked up.
+var resolveInterval = 10 * time.Second
+
+// roundRobin balances the calls between the nodes of a service.
+const roundRobin = `{"loadBalancingConfig": [{"round_robin": {}}]}`
+
+func init() {
+	resolver.Register(&registryBuilder{})
+}
+
+type registryBuilder struct{}
+
+func (b *registryBuilder) Scheme() string {
+	return RegistryScheme
+}
+
+func (b *registryBuilder) Build(target resolver.Target, cc resolver.ClientConn, opts resolver.BuildOptions) (resolver.Resolver, error) {
+	// both registry:///name and registry://name are accepted
+	name := strings.Trim(target.URL.Host+target.URL.Path, "/")
+	selector := map[string]string{}
+	for k, v := range target.URL.Query() {
+		selector[k] = v[0]
+	}
+
+	r := &registryResolver{
+		name:     name,
+		selector: selector,
+		cc:       cc,
+		now:      make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	r.resolve()
+	go r.watch()
+	return r, nil
+}
+
+type registryResolver struct {
+	name     string
+	selector map[string]string
+	cc       resolver.ClientConn
+
+	now       chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	last []resolver.Address
+}
+
+func (r *registryResolver) watch() {
+	ticker := time.NewTicker(resolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		case <-r.now:
+		}
+		r.resolve()
+	}
+}
+
+// resolve updates the addresses of the connection when the nodes changed.
+func (r *registryResolver) resolve() {
+	svc, err := utils.GlobalRegistry.GetService(r.name)
+	if err != nil {
+		r.cc.ReportError(err)
+		return
+	}
+
+	addrs := []resolver.Address{}
+	for _, n := range registry.Select(svc, r.selector) {
+		addr := resolver.Address{Addr: n.Address()}
+		// the certificates of the nodes are checked against their own host
+		if host, _, err := net.SplitHostPort(n.Address()); err == nil {
+			addr.ServerName = host
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		r.cc.ReportError(errors.Errorf("no node of service %s matches %v", r.name, r.selector))
+		return
+	}
+	if reflect.DeepEqual(addrs, r.last) {
+		return
+	}
+	r.last = addrs
+
+	_ = r.cc.UpdateState(resolver.State{
+		Addresses:     addrs,
+		ServiceConfig: r.cc.ParseServiceConfig(roundRobin),
+	})
+}
+
+func (r *registryResolver) ResolveNow(resolver.ResolveNowOptions) {
+	select {
+	case r.now <- struct{}{}:
+	default:
+	}
+}
+
+func (r *registryResolver) Close() {
+	r.closeOnce.Do(func() { close(r.done) })
+}
@@ -0,0 +1,86 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package pool
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/pkg/registry"
+	"github.com/cs3org/reva/pkg/registry/memory"
+	"github.com/cs3org/reva/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+)
+
+func serve(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go func() { _ = s.Serve(ln) }()
+	t.Cleanup(s.Stop)
+	return ln.Addr().String()
+}
+
+func TestRegistryResolver(t *testing.T) {
+	global := utils.GlobalRegistry
+	utils.GlobalRegistry = memory.New(nil)
+	defer func() { utils.GlobalRegistry = global }()
+
+	home, project := serve(t), serve(t)
+	_ = utils.GlobalRegistry.Add(registry.NewService("storageprovider",
+		registry.NewNode("home", home, map[string]string{"mount_id": "home"}),
+		registry.NewNode("project", project, map[string]string{"mount_id": "project"}),
+	))
+
+	call := func(endpoint string) map[string]bool {
+		conn, err := grpc.Dial(endpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		called := map[string]bool{}
+		for i := 0; i < 10; i++ {
+			var p peer.Peer
+			if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}, grpc.WaitForReady(true), grpc.Peer(&p)); err != nil {
+				t.Fatal(err)
+			}
+			called[p.Addr.String()] = true
+		}
+		return called
+	}
+
+	if called := call("registry:///storageprovider"); !called[home] || !called[project] {
+		t.Fatalf("expected the calls to be balanced between the nodes, called %v", called)
+	}
+	if called := call("registry:///storageprovider?mount_id=project"); len(called) != 1 || !called[project] {
+		t.Fatalf("expected only the selected node to be called, called %v", called)
+	}
+}
@@ -21,12 +21,98 @@
 package datatx
 
 import (
+	"context"
 	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/events"
 	"github.com/cs3org/reva/pkg/storage"
+	"github.com/cs3org/reva/pkg/utils"
+	tusd "github.com/tus/tusd/pkg/handler"
 )
 
 // DataTX provides an abstraction around various data transfer protocols.
 type DataTX interface {
 	Handler(fs storage.FS) (http.Handler, error)
 }
+
+// EventEmitter is implemented by the data transfers which can publish
+// events about finished uploads. The publisher is set before the handler
+// is created.
+type EventEmitter interface {
+	SetPublisher(publisher events.Publisher)
+}
+
+// UploadID returns the id of the upload addressed by the path of an upload request.
+// The second return value is false if the path doesn't name an upload id but a file.
+func UploadID(p string) (string, bool) {
+	id := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// UploadInfo returns the tus info of a pending upload if the storage supports it.
+func UploadInfo(ctx context.Context, fs storage.FS, uploadID string) (tusd.FileInfo, bool) {
+	if uploadID == "" {
+		return tusd.FileInfo{}, false
+	}
+	store, ok := fs.(interface {
+		GetUpload(ctx context.Context, id string) (tusd.Upload, error)
+	})
+	if !ok {
+		return tusd.FileInfo{}, false
+	}
+	upload, err := store.GetUpload(ctx, uploadID)
+	if err != nil {
+		return tusd.FileInfo{}, false
+	}
+	info, err := upload.GetInfo(ctx)
+	if err != nil {
+		return tusd.FileInfo{}, false
+	}
+	return info, true
+}
+
+// EmitFileUploadedEvent publishes a FileUploaded event for a finished upload.
+// The uploaded file and the executant are taken from the upload info,
+// falling back to the given reference and the user in the context.
+func EmitFileUploadedEvent(ctx context.Context, info tusd.FileInfo, fallback *provider.Reference, publisher events.Publisher) error {
+	ref := fallback
+	var itemID *provider.ResourceId
+	switch {
+	case info.Storage["NodeId"] != "":
+		storageID := info.Storage["ProviderId"]
+		if storageID == "" {
+			storageID = fallback.GetResourceId().GetStorageId()
+		}
+		itemID = &provider.ResourceId{StorageId: storageID, OpaqueId: info.Storage["NodeId"]}
+		ref = &provider.Reference{ResourceId: itemID}
+	case info.MetaData["filename"] != "":
+		ref = &provider.Reference{Path: filepath.Join(info.MetaData["dir"], info.MetaData["filename"])}
+	}
+
+	var executant *userpb.UserId
+	if info.Storage["UserId"] != "" {
+		executant = &userpb.UserId{
+			Idp:      info.Storage["Idp"],
+			OpaqueId: info.Storage["UserId"],
+			Type:     utils.UserTypeMap(info.Storage["UserType"]),
+		}
+	} else if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		executant = u.Id
+	}
+
+	return events.Publish(publisher, events.FileUploaded{
+		Executant: executant,
+		Ref:       ref,
+		ItemID:    itemID,
+		Timestamp: utils.TSNow(),
+	})
+}
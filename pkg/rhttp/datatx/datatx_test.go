@@ -0,0 +1,80 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package datatx
+
+import (
+	"context"
+	"testing"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/events"
+	tusd "github.com/tus/tusd/pkg/handler"
+	microevents "go-micro.dev/v4/events"
+)
+
+func TestUploadID(t *testing.T) {
+	tests := []struct {
+		path string
+		id   string
+		ok   bool
+	}{
+		{"/5c1c6a3b-0e4c-4bbb-9e35-5e0c0a2b0f1f", "5c1c6a3b-0e4c-4bbb-9e35-5e0c0a2b0f1f", true},
+		{"upload", "upload", true},
+		{"/upload/", "upload", true},
+		{"/folder/file.txt", "", false},
+		{"/", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		id, ok := UploadID(tt.path)
+		if id != tt.id || ok != tt.ok {
+			t.Errorf("UploadID(%q) = %q, %v, want %q, %v", tt.path, id, ok, tt.id, tt.ok)
+		}
+	}
+}
+
+type recordingPublisher struct {
+	published []interface{}
+}
+
+func (p *recordingPublisher) Publish(_ string, ev interface{}, _ ...microevents.PublishOption) error {
+	p.published = append(p.published, ev)
+	return nil
+}
+
+func TestEmitFileUploadedEventResourceID(t *testing.T) {
+	fallback := &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "fallback", OpaqueId: "space"}, Path: "./file.txt"}
+	tests := []struct {
+		storage map[string]string
+		id      *provider.ResourceId
+	}{
+		{map[string]string{"NodeId": "node", "ProviderId": "provider"}, &provider.ResourceId{StorageId: "provider", OpaqueId: "node"}},
+		{map[string]string{"NodeId": "node"}, &provider.ResourceId{StorageId: "fallback", OpaqueId: "node"}},
+	}
+	for _, tt := range tests {
+		p := &recordingPublisher{}
+		if err := EmitFileUploadedEvent(context.Background(), tusd.FileInfo{Storage: tt.storage}, fallback, p); err != nil {
+			t.Fatal(err)
+		}
+		ev := p.published[0].(events.FileUploaded)
+		if id := ev.ItemID; id.StorageId != tt.id.StorageId || id.OpaqueId != tt.id.OpaqueId || ev.Ref.Path != "" {
+			t.Errorf("EmitFileUploadedEvent with %v referenced %v, want %v", tt.storage, ev.Ref, tt.id)
+		}
+	}
+}
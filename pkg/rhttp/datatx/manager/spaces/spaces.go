@@ -26,6 +26,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/events"
 	"github.com/cs3org/reva/pkg/rhttp/datatx"
 	"github.com/cs3org/reva/pkg/rhttp/datatx/manager/registry"
 	"github.com/cs3org/reva/pkg/rhttp/datatx/utils/download"
@@ -43,7 +44,8 @@ func init() {
 type config struct{}
 
 type manager struct {
-	conf *config
+	conf      *config
+	publisher events.Publisher
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -65,6 +67,11 @@ func New(m map[string]interface{}) (datatx.DataTX, error) {
 	return &manager{conf: c}, nil
 }
 
+// SetPublisher sets the publisher to emit FileUploaded events to.
+func (m *manager) SetPublisher(publisher events.Publisher) {
+	m.publisher = publisher
+}
+
 func (m *manager) Handler(fs storage.FS) (http.Handler, error) {
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -95,9 +102,17 @@ func (m *manager) Handler(fs storage.FS) (http.Handler, error) {
 				ResourceId: &provider.ResourceId{StorageId: storageid, OpaqueId: opaqeid},
 				Path:       fn,
 			}
+			// the info has to be read before the upload is finished and removed
+			uploadID, _ := datatx.UploadID(fn)
+			info, _ := datatx.UploadInfo(ctx, fs, uploadID)
 			err = fs.Upload(ctx, ref, r.Body)
 			switch v := err.(type) {
 			case nil:
+				if m.publisher != nil {
+					if err := datatx.EmitFileUploadedEvent(ctx, info, ref, m.publisher); err != nil {
+						sublog.Error().Err(err).Msg("failed to publish FileUploaded event")
+					}
+				}
 				w.WriteHeader(http.StatusOK)
 			case errtypes.PartialContent:
 				w.WriteHeader(http.StatusPartialContent)
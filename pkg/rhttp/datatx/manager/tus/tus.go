@@ -19,9 +19,12 @@
 package tus
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/events"
 	"github.com/cs3org/reva/pkg/rhttp/datatx"
 	"github.com/cs3org/reva/pkg/rhttp/datatx/manager/registry"
 	"github.com/cs3org/reva/pkg/rhttp/datatx/utils/download"
@@ -38,7 +41,8 @@ func init() {
 type config struct{}
 
 type manager struct {
-	conf *config
+	conf      *config
+	publisher events.Publisher
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -60,6 +64,11 @@ func New(m map[string]interface{}) (datatx.DataTX, error) {
 	return &manager{conf: c}, nil
 }
 
+// SetPublisher sets the publisher to emit FileUploaded events to.
+func (m *manager) SetPublisher(publisher events.Publisher) {
+	m.publisher = publisher
+}
+
 func (m *manager) Handler(fs storage.FS) (http.Handler, error) {
 	composable, ok := fs.(composable)
 	if !ok {
@@ -76,7 +85,8 @@ func (m *manager) Handler(fs storage.FS) (http.Handler, error) {
 	composable.UseIn(composer)
 
 	config := tusd.Config{
-		StoreComposer: composer,
+		StoreComposer:         composer,
+		NotifyCompleteUploads: m.publisher != nil,
 	}
 
 	handler, err := tusd.NewUnroutedHandler(config)
@@ -84,6 +94,17 @@ func (m *manager) Handler(fs storage.FS) (http.Handler, error) {
 		return nil, err
 	}
 
+	if m.publisher != nil {
+		go func() {
+			for ev := range handler.CompleteUploads {
+				ctx := context.Background()
+				if err := datatx.EmitFileUploadedEvent(ctx, ev.Upload, nil, m.publisher); err != nil {
+					appctx.GetLogger(ctx).Error().Err(err).Str("upload", ev.Upload.ID).Msg("failed to publish FileUploaded event")
+				}
+			}
+		}()
+	}
+
 	h := handler.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		method := r.Method
 		// https://github.com/tus/tus-resumable-upload-protocol/blob/master/protocol.md#x-http-method-override
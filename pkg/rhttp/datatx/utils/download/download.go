@@ -30,6 +30,7 @@ import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rhttp/router"
 	"github.com/cs3org/reva/pkg/storage"
 	"github.com/cs3org/reva/pkg/utils"
 	"github.com/rs/zerolog"
@@ -78,6 +79,16 @@ func GetOrHeadFile(w http.ResponseWriter, r *http.Request, fs storage.FS, spaceI
 		return
 	}
 
+	if key := r.URL.Query().Get("revision"); key != "" {
+		downloadRevision(w, r, fs, ref, key, &sublog)
+		return
+	}
+
+	if key := r.URL.Query().Get("recycle"); key != "" {
+		downloadRecycleItem(w, r, fs, ref, key, &sublog)
+		return
+	}
+
 	var ranges []HTTPRange
 
 	if r.Header.Get("Range") != "" {
@@ -197,6 +208,50 @@ func GetOrHeadFile(w http.ResponseWriter, r *http.Request, fs storage.FS, spaceI
 	}
 }
 
+// downloadRevision sends the content of a revision of the file. The size of a revision
+// is not known without listing all of them, so the content is streamed as a whole.
+func downloadRevision(w http.ResponseWriter, r *http.Request, fs storage.FS, ref *provider.Reference, key string, sublog *zerolog.Logger) {
+	content, err := fs.DownloadRevision(r.Context(), ref, key)
+	if err != nil {
+		handleError(w, sublog, err, "download revision")
+		return
+	}
+	defer content.Close()
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		if _, err := io.Copy(w, content); err != nil {
+			sublog.Error().Err(err).Str("revision", key).Msg("error copying data to response")
+		}
+	}
+}
+
+// downloadRecycleItem sends the content of a file of the recycle bin, streamed as a whole.
+func downloadRecycleItem(w http.ResponseWriter, r *http.Request, fs storage.FS, ref *provider.Reference, key string, sublog *zerolog.Logger) {
+	rd, ok := fs.(storage.RecycleDownloader)
+	if !ok {
+		handleError(w, sublog, errtypes.NotSupported("downloading recycle items"), "download recycle item")
+		return
+	}
+
+	key, itemPath := router.ShiftPath(key)
+	content, err := rd.DownloadRecycleItem(r.Context(), ref.GetPath(), key, itemPath)
+	if err != nil {
+		handleError(w, sublog, err, "download recycle item")
+		return
+	}
+	defer content.Close()
+
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		if _, err := io.Copy(w, content); err != nil {
+			sublog.Error().Err(err).Str("recycle", key).Msg("error copying data to response")
+		}
+	}
+}
+
 func handleError(w http.ResponseWriter, log *zerolog.Logger, err error, action string) {
 	switch err.(type) {
 	case errtypes.IsNotFound:
@@ -35,6 +35,17 @@ func RegisterMiddleware(name string, n NewMiddleware) {
 	NewMiddlewares[name] = n
 }
 
+// NewPreAuthMiddlewares contains the registered new functions of the middlewares
+// chained before the auth middleware.
+var NewPreAuthMiddlewares = map[string]NewMiddleware{}
+
+// RegisterPreAuthMiddleware registers an HTTP middleware chained before the auth middleware,
+// e.g. to see the requests it rejects. It is enabled along with the middleware of the same
+// name, and it is skipped when its new function returns a nil middleware.
+func RegisterPreAuthMiddleware(name string, n NewMiddleware) {
+	NewPreAuthMiddlewares[name] = n
+}
+
 // Middleware is a middleware http handler.
 type Middleware func(h http.Handler) http.Handler
 
@@ -26,12 +26,14 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/cs3org/reva/internal/http/interceptors/appctx"
 	"github.com/cs3org/reva/internal/http/interceptors/auth"
 	"github.com/cs3org/reva/internal/http/interceptors/log"
 	"github.com/cs3org/reva/internal/http/interceptors/providerauthorizer"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	rtrace "github.com/cs3org/reva/pkg/trace"
 	"github.com/mitchellh/mapstructure"
@@ -58,6 +60,7 @@ func New(m interface{}, l zerolog.Logger) (*Server, error) {
 		handlers:    map[string]http.Handler{},
 		log:         l,
 	}
+	health.Register(s.healthName(), s)
 	return s, nil
 }
 
@@ -71,6 +74,8 @@ type Server struct {
 	handlers    map[string]http.Handler
 	middlewares []*middlewareTriple
 	log         zerolog.Logger
+	checkers    []health.Named
+	serving     atomic.Bool
 }
 
 type config struct {
@@ -110,6 +115,7 @@ func (s *Server) Start(ln net.Listener) error {
 
 	s.httpServer.Handler = handler
 	s.listener = ln
+	s.serving.Store(true)
 
 	if (s.conf.CertFile != "") && (s.conf.KeyFile != "") {
 		s.log.Info().Msgf("https server listening at https://%s '%s' '%s'", s.conf.Address, s.conf.CertFile, s.conf.KeyFile)
@@ -126,6 +132,7 @@ func (s *Server) Start(ln net.Listener) error {
 
 // Stop stops the server.
 func (s *Server) Stop() error {
+	health.Unregister(s.healthName())
 	s.closeServices()
 	// TODO(labkode): set ctx deadline to zero
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
@@ -158,6 +165,7 @@ func (s *Server) Address() string {
 
 // GracefulStop gracefully stops the server.
 func (s *Server) GracefulStop() error {
+	health.Unregister(s.healthName())
 	s.closeServices()
 	return s.httpServer.Shutdown(context.Background())
 }
@@ -191,6 +199,29 @@ func (s *Server) registerMiddlewares() error {
 	return nil
 }
 
+// preAuthMiddlewares returns the enabled middlewares chained before the auth,
+// sorted like the other middlewares.
+func (s *Server) preAuthMiddlewares() ([]*middlewareTriple, error) {
+	middlewares := []*middlewareTriple{}
+	for name, newFunc := range global.NewPreAuthMiddlewares {
+		if s.isMiddlewareEnabled(name) {
+			m, prio, err := newFunc(s.conf.Middlewares[name])
+			if err != nil {
+				err = errors.Wrapf(err, "error creating new pre-auth middleware: %s,", name)
+				return nil, err
+			}
+			if m != nil {
+				s.log.Info().Msgf("chaining http middleware %s before auth with priority %d", name, prio)
+				middlewares = append(middlewares, &middlewareTriple{Middleware: m, Name: name, Priority: prio})
+			}
+		}
+	}
+	sort.SliceStable(middlewares, func(i, j int) bool {
+		return middlewares[i].Priority > middlewares[j].Priority
+	})
+	return middlewares, nil
+}
+
 func (s *Server) isMiddlewareEnabled(name string) bool {
 	_, ok := s.conf.Middlewares[name]
 	return ok
@@ -210,6 +241,7 @@ func (s *Server) registerServices() error {
 			h := traceHandler(svcName, svc.Handler())
 			s.handlers[svc.Prefix()] = h
 			s.svcs[svc.Prefix()] = svc
+			s.checkers = append(s.checkers, health.Named{Name: svcName, Checker: svc})
 			s.unprotected = append(s.unprotected, getUnprotected(svc.Prefix(), svc.Unprotected())...)
 			s.log.Info().Msgf("http service enabled: %s@/%s", svcName, svc.Prefix())
 		} else {
@@ -344,6 +376,13 @@ func (s *Server) getHandler() (http.Handler, error) {
 	}
 
 	coreMiddlewares = append(coreMiddlewares, &middlewareTriple{Middleware: authMiddle, Name: "auth"})
+
+	preAuthMiddlewares, err := s.preAuthMiddlewares()
+	if err != nil {
+		return nil, err
+	}
+	coreMiddlewares = append(coreMiddlewares, preAuthMiddlewares...)
+
 	coreMiddlewares = append(coreMiddlewares, &middlewareTriple{Middleware: log.New(), Name: "log"})
 	coreMiddlewares = append(coreMiddlewares, &middlewareTriple{Middleware: appctx.New(s.log), Name: "appctx"})
 
@@ -354,6 +393,18 @@ func (s *Server) getHandler() (http.Handler, error) {
 	return handler, nil
 }
 
+func (s *Server) healthName() string {
+	return "http@" + s.conf.Address
+}
+
+// Ready tells whether the server serves requests and its services are ready.
+func (s *Server) Ready(ctx context.Context) error {
+	if !s.serving.Load() {
+		return errors.New("rhttp: server not serving yet")
+	}
+	return health.All(ctx, s.checkers)
+}
+
 func traceHandler(name string, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := rtrace.Propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
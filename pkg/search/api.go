@@ -0,0 +1,164 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package search
+
+import (
+	"context"
+	"encoding/json"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// ServiceName is the name of the grpc service to search the resources, served by the gateway
+// next to the CS3 gateway API. Its messages are plain structs encoded as json.
+const ServiceName = "reva.search.v1.SearchAPI"
+
+const codecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// SearchRequest asks for the resources of the caller whose name contains the pattern.
+type SearchRequest struct {
+	Pattern string `json:"pattern"`
+	Offset  int    `json:"offset,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+}
+
+// SearchResponse holds the matching resources the caller may access, most recently modified first.
+type SearchResponse struct {
+	Status *rpc.Status              `json:"status"`
+	Infos  []*provider.ResourceInfo `json:"infos"`
+}
+
+// IndexRequest asks to add the referenced resource to the index, for the changes
+// which do not pass through the gateway, e.g. the uploads to the data gateway.
+type IndexRequest struct {
+	Ref *provider.Reference `json:"ref"`
+}
+
+// IndexResponse reports the outcome of an indexing.
+type IndexResponse struct {
+	Status *rpc.Status `json:"status"`
+}
+
+// SearchAPIServer is the server API of the search service.
+type SearchAPIServer interface {
+	Search(context.Context, *SearchRequest) (*SearchResponse, error)
+	Index(context.Context, *IndexRequest) (*IndexResponse, error)
+}
+
+// SearchAPIClient is the client API of the search service.
+type SearchAPIClient interface {
+	Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error)
+	Index(ctx context.Context, in *IndexRequest, opts ...grpc.CallOption) (*IndexResponse, error)
+}
+
+// RegisterSearchAPIServer registers the search service on the grpc server.
+func RegisterSearchAPIServer(s *grpc.Server, srv SearchAPIServer) {
+	s.RegisterService(&searchAPIServiceDesc, srv)
+}
+
+// NewSearchAPIClient returns a client of the search service served on the connection.
+func NewSearchAPIClient(cc grpc.ClientConnInterface) SearchAPIClient {
+	return &searchAPIClient{cc: cc}
+}
+
+type searchAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func (c *searchAPIClient) Search(ctx context.Context, in *SearchRequest, opts ...grpc.CallOption) (*SearchResponse, error) {
+	out := &SearchResponse{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/Search", in, out, withCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *searchAPIClient) Index(ctx context.Context, in *IndexRequest, opts ...grpc.CallOption) (*IndexResponse, error) {
+	out := &IndexResponse{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/Index", in, out, withCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func withCodec(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+}
+
+var searchAPIServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*SearchAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Search",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &SearchRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SearchAPIServer).Search(ctx, req.(*SearchRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Search"}, handler)
+			},
+		},
+		{
+			MethodName: "Index",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &IndexRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SearchAPIServer).Index(ctx, req.(*IndexRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/Index"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// jsonCodec encodes the messages of the search service, which are no protobuf messages.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
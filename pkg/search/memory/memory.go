@@ -0,0 +1,106 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/search"
+	"github.com/cs3org/reva/pkg/search/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("memory", New)
+}
+
+type config struct {
+	// Name identifies the index within the process, so that e.g. the gateway
+	// feeding the index and ocdav querying it share the same entries.
+	Name string `mapstructure:"name"`
+}
+
+func (c *config) init() {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+}
+
+var (
+	mu      sync.Mutex
+	indexes = map[string]*index{}
+)
+
+type index struct {
+	sync.RWMutex
+	entries map[string]*search.Entry
+}
+
+// New returns the in-memory search index with the configured name,
+// creating it on first use.
+func New(m map[string]interface{}) (search.Index, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "memory: error decoding conf")
+	}
+	c.init()
+
+	mu.Lock()
+	defer mu.Unlock()
+	i, ok := indexes[c.Name]
+	if !ok {
+		i = &index{entries: make(map[string]*search.Entry)}
+		indexes[c.Name] = i
+	}
+	return i, nil
+}
+
+func (i *index) Upsert(_ context.Context, entry *search.Entry) error {
+	i.Lock()
+	defer i.Unlock()
+	i.entries[search.Key(entry.ID)] = entry
+	return nil
+}
+
+func (i *index) Remove(_ context.Context, id *provider.ResourceId) error {
+	i.Lock()
+	defer i.Unlock()
+	delete(i.entries, search.Key(id))
+	return nil
+}
+
+func (i *index) Search(_ context.Context, query *search.Query) ([]*search.Entry, error) {
+	i.RLock()
+	matches := []*search.Entry{}
+	for _, e := range i.entries {
+		if query.Matches(e) {
+			matches = append(matches, e)
+		}
+	}
+	i.RUnlock()
+
+	sort.Slice(matches, func(a, b int) bool {
+		return matches[a].Mtime.After(matches[b].Mtime)
+	})
+	return query.Page(matches), nil
+}
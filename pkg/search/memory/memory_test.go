@@ -0,0 +1,81 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/search"
+)
+
+func TestSearch(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	i, _ := New(map[string]interface{}{"name": "TestSearch"})
+	entries := []*search.Entry{
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "1"}, Owner: &userpb.UserId{OpaqueId: "einstein"}, Name: "Report.pdf", MimeType: "application/pdf", Mtime: now.Add(-time.Hour)},
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "2"}, Name: "report.odt", MimeType: "application/vnd.oasis.opendocument.text", Mtime: now, Tags: []string{"Work"}},
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "3"}, Name: "holiday.jpg", MimeType: "image/jpeg", Mtime: now},
+	}
+	for _, e := range entries {
+		_ = i.Upsert(ctx, e)
+	}
+	_ = i.Remove(ctx, entries[2].ID)
+
+	res, _ := i.Search(ctx, &search.Query{Pattern: "REPORT"})
+	if len(res) != 2 || res[0].ID.OpaqueId != "2" || res[1].ID.OpaqueId != "1" {
+		t.Errorf("expected the two reports, most recent first, got %+v", res)
+	}
+	res, _ = i.Search(ctx, &search.Query{Pattern: "report", Tags: []string{"work"}})
+	if len(res) != 1 || res[0].ID.OpaqueId != "2" {
+		t.Errorf("expected the tagged report, got %+v", res)
+	}
+	res, _ = i.Search(ctx, &search.Query{Pattern: "report", Offset: 1, Limit: 5})
+	if len(res) != 1 || res[0].ID.OpaqueId != "1" {
+		t.Errorf("expected the older report, got %+v", res)
+	}
+	res, _ = i.Search(ctx, &search.Query{Pattern: "report", Owners: []*userpb.UserId{{Idp: "idp", OpaqueId: "einstein"}}})
+	if len(res) != 1 || res[0].ID.OpaqueId != "1" {
+		t.Errorf("expected the report of the owner, got %+v", res)
+	}
+	res, _ = i.Search(ctx, &search.Query{MimeType: "image/"})
+	if len(res) != 0 {
+		t.Errorf("expected the removed image not to be found, got %+v", res)
+	}
+}
+
+func TestSharedByName(t *testing.T) {
+	ctx := context.Background()
+	a, _ := New(map[string]interface{}{"name": "TestSharedByName"})
+	b, _ := New(map[string]interface{}{"name": "TestSharedByName"})
+	other, _ := New(map[string]interface{}{"name": "TestSharedByName-other"})
+
+	_ = a.Upsert(ctx, &search.Entry{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "1"}, Name: "shared.txt"})
+
+	if res, _ := b.Search(ctx, &search.Query{Pattern: "shared"}); len(res) != 1 {
+		t.Errorf("expected indexes with the same name to share their entries, got %+v", res)
+	}
+	if res, _ := other.Search(ctx, &search.Query{Pattern: "shared"}); len(res) != 0 {
+		t.Errorf("expected indexes with different names to be separate, got %+v", res)
+	}
+}
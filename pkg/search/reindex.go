@@ -0,0 +1,63 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package search
+
+import (
+	"context"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/pkg/errors"
+)
+
+// Lister lists the resources of a container, as the gateway does.
+type Lister interface {
+	ListContainer(ctx context.Context, req *provider.ListContainerRequest) (*provider.ListContainerResponse, error)
+}
+
+// Reindex walks the tree below the reference and adds every resource
+// found to the index. It is used to backfill indexes which missed changes,
+// e.g. in-memory indexes after a restart.
+func Reindex(ctx context.Context, client Lister, idx Index, ref *provider.Reference) error {
+	res, err := client.ListContainer(ctx, &provider.ListContainerRequest{Ref: ref})
+	if err != nil {
+		return errors.Wrap(err, "search: error listing container")
+	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		return errors.New("search: error listing container: " + res.Status.Message)
+	}
+
+	for _, info := range res.Infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if info.Id == nil {
+			continue
+		}
+		if err := idx.Upsert(ctx, EntryFromResourceInfo(info)); err != nil {
+			return err
+		}
+		if info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+			if err := Reindex(ctx, client, idx, &provider.Reference{ResourceId: info.Id, Path: "."}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
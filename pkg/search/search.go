@@ -0,0 +1,169 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package search defines the index used to look up resources by their metadata.
+package search
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/utils"
+)
+
+// TagsKey is the arbitrary metadata key holding the comma separated tags of a resource.
+const TagsKey = "http://owncloud.org/ns/tags"
+
+// Entry is a resource known to the index.
+type Entry struct {
+	ID       *provider.ResourceId
+	Owner    *userpb.UserId
+	Name     string
+	MimeType string
+	Mtime    time.Time
+	Size     uint64
+	Tags     []string
+}
+
+// Query describes which entries to look for. All set criteria have to match.
+type Query struct {
+	// Pattern is matched case-insensitively against the name of the resource.
+	Pattern string
+	// MimeType is matched as a prefix of the mime type, e.g. "image/".
+	MimeType string
+	// Tags lists tags the resource has to carry.
+	Tags []string
+	// Owners restricts the results to resources owned by one of the users,
+	// compared by their opaque id.
+	Owners []*userpb.UserId
+	// Offset and Limit page through the results, a Limit of 0 returns all of them.
+	Offset int
+	Limit  int
+}
+
+// Index is the interface that search index drivers have to implement.
+type Index interface {
+	// Upsert adds the entry to the index or replaces the one with the same id.
+	Upsert(ctx context.Context, entry *Entry) error
+	// Remove drops the entry with the given id from the index.
+	Remove(ctx context.Context, id *provider.ResourceId) error
+	// Search returns the entries matching the query, most recently modified first.
+	Search(ctx context.Context, query *Query) ([]*Entry, error)
+}
+
+// EntryFromResourceInfo builds an index entry out of a resource info.
+func EntryFromResourceInfo(ri *provider.ResourceInfo) *Entry {
+	e := &Entry{
+		ID:       ri.Id,
+		Owner:    ri.Owner,
+		Name:     ri.Name,
+		MimeType: ri.MimeType,
+		Size:     ri.Size,
+	}
+	if e.Name == "" {
+		e.Name = lastSegment(ri.Path)
+	}
+	if ri.Mtime != nil {
+		e.Mtime = utils.TSToTime(ri.Mtime)
+	}
+	if md := ri.GetArbitraryMetadata().GetMetadata(); md != nil {
+		e.Tags = ParseTags(md[TagsKey])
+	}
+	return e
+}
+
+// ParseTags splits a comma separated list of tags.
+func ParseTags(tags string) []string {
+	var parsed []string
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			parsed = append(parsed, t)
+		}
+	}
+	return parsed
+}
+
+// Matches reports whether the entry satisfies the criteria of the query.
+func (q *Query) Matches(e *Entry) bool {
+	if q.Pattern != "" && !strings.Contains(strings.ToLower(e.Name), strings.ToLower(q.Pattern)) {
+		return false
+	}
+	if q.MimeType != "" && !strings.HasPrefix(e.MimeType, q.MimeType) {
+		return false
+	}
+	for _, t := range q.Tags {
+		if !hasTag(e.Tags, t) {
+			return false
+		}
+	}
+	if len(q.Owners) > 0 && !hasOwner(q.Owners, e.Owner) {
+		return false
+	}
+	return true
+}
+
+// Page applies the offset and limit of the query to the results.
+func (q *Query) Page(entries []*Entry) []*Entry {
+	if q.Offset >= len(entries) {
+		return []*Entry{}
+	}
+	entries = entries[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(entries) {
+		entries = entries[:q.Limit]
+	}
+	return entries
+}
+
+// Key returns the string used by drivers to identify a resource.
+func Key(id *provider.ResourceId) string {
+	return id.GetStorageId() + "!" + id.GetOpaqueId()
+}
+
+// hasOwner compares the users by their opaque id only, as not all storage
+// drivers know the identity provider of the owner.
+func hasOwner(owners []*userpb.UserId, owner *userpb.UserId) bool {
+	if owner.GetOpaqueId() == "" {
+		return false
+	}
+	for _, o := range owners {
+		if o.GetOpaqueId() == owner.GetOpaqueId() {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func lastSegment(p string) string {
+	p = strings.TrimSuffix(p, "/")
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[i+1:]
+	}
+	return p
+}
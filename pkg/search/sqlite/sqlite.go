@@ -0,0 +1,228 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/search"
+	"github.com/cs3org/reva/pkg/search/registry"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	// Provides sqlite drivers.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	registry.Register("sqlite", New)
+}
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS entries (
+		key TEXT PRIMARY KEY,
+		storage_id TEXT,
+		opaque_id TEXT,
+		owner_id TEXT,
+		name TEXT,
+		name_lower TEXT,
+		mime_type TEXT,
+		mtime INTEGER,
+		size INTEGER,
+		tags TEXT,
+		tags_lower TEXT
+	)`,
+	"CREATE INDEX IF NOT EXISTS entries_mtime ON entries (mtime)",
+	"CREATE INDEX IF NOT EXISTS entries_mime_type ON entries (mime_type)",
+}
+
+// migrations add the columns introduced after the first version of the schema.
+var migrations = map[string]string{
+	"owner_id": "ALTER TABLE entries ADD COLUMN owner_id TEXT",
+}
+
+type config struct {
+	DBFile string `mapstructure:"db_file"`
+}
+
+func (c *config) init() {
+	if c.DBFile == "" {
+		c.DBFile = "/var/tmp/reva/search.db"
+	}
+}
+
+type index struct {
+	db *sql.DB
+}
+
+// New returns a search index persisted in a sqlite database.
+func New(m map[string]interface{}) (search.Index, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "sqlite: error decoding conf")
+	}
+	c.init()
+
+	db, err := sql.Open("sqlite3", c.DBFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlite: error opening DB connection")
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, errors.Wrap(err, "sqlite: error creating schema")
+		}
+	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec("CREATE INDEX IF NOT EXISTS entries_owner_id ON entries (owner_id)"); err != nil {
+		return nil, errors.Wrap(err, "sqlite: error creating schema")
+	}
+	return &index{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM pragma_table_info('entries')")
+	if err != nil {
+		return errors.Wrap(err, "sqlite: error reading schema")
+	}
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "sqlite: error reading schema")
+		}
+		columns[name] = true
+	}
+	rows.Close()
+
+	for column, stmt := range migrations {
+		if columns[column] {
+			continue
+		}
+		if _, err := db.Exec(stmt); err != nil {
+			return errors.Wrap(err, "sqlite: error migrating schema")
+		}
+	}
+	return nil
+}
+
+func (i *index) Upsert(ctx context.Context, entry *search.Entry) error {
+	query := `INSERT INTO entries (key, storage_id, opaque_id, owner_id, name, name_lower, mime_type, mtime, size, tags, tags_lower) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET owner_id=excluded.owner_id, name=excluded.name, name_lower=excluded.name_lower, mime_type=excluded.mime_type,
+		mtime=excluded.mtime, size=excluded.size, tags=excluded.tags, tags_lower=excluded.tags_lower`
+	_, err := i.db.ExecContext(ctx, query,
+		search.Key(entry.ID),
+		entry.ID.StorageId,
+		entry.ID.OpaqueId,
+		entry.Owner.GetOpaqueId(),
+		entry.Name,
+		strings.ToLower(entry.Name),
+		entry.MimeType,
+		entry.Mtime.UnixNano(),
+		entry.Size,
+		strings.Join(entry.Tags, ","),
+		encodeTags(entry.Tags),
+	)
+	if err != nil {
+		return errors.Wrap(err, "sqlite: error upserting entry")
+	}
+	return nil
+}
+
+func (i *index) Remove(ctx context.Context, id *provider.ResourceId) error {
+	if _, err := i.db.ExecContext(ctx, "DELETE FROM entries WHERE key=?", search.Key(id)); err != nil {
+		return errors.Wrap(err, "sqlite: error removing entry")
+	}
+	return nil
+}
+
+func (i *index) Search(ctx context.Context, q *search.Query) ([]*search.Entry, error) {
+	query := "SELECT storage_id, opaque_id, owner_id, name, mime_type, mtime, size, tags FROM entries WHERE 1=1"
+	params := []interface{}{}
+	if q.Pattern != "" {
+		query += ` AND name_lower LIKE ? ESCAPE '\'`
+		params = append(params, "%"+escapeLike(strings.ToLower(q.Pattern))+"%")
+	}
+	if q.MimeType != "" {
+		query += ` AND mime_type LIKE ? ESCAPE '\'`
+		params = append(params, escapeLike(q.MimeType)+"%")
+	}
+	for _, t := range q.Tags {
+		query += ` AND tags_lower LIKE ? ESCAPE '\'`
+		params = append(params, "%,"+escapeLike(strings.ToLower(t))+",%")
+	}
+	if len(q.Owners) > 0 {
+		query += " AND owner_id IN (?" + strings.Repeat(", ?", len(q.Owners)-1) + ")"
+		for _, o := range q.Owners {
+			params = append(params, o.GetOpaqueId())
+		}
+	}
+	query += " ORDER BY mtime DESC LIMIT ? OFFSET ?"
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	params = append(params, limit, q.Offset)
+
+	rows, err := i.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, errors.Wrap(err, "sqlite: error querying entries")
+	}
+	defer rows.Close()
+
+	entries := []*search.Entry{}
+	for rows.Next() {
+		var (
+			e     = &search.Entry{ID: &provider.ResourceId{}}
+			owner sql.NullString
+			mtime int64
+			tags  string
+		)
+		if err := rows.Scan(&e.ID.StorageId, &e.ID.OpaqueId, &owner, &e.Name, &e.MimeType, &mtime, &e.Size, &tags); err != nil {
+			return nil, errors.Wrap(err, "sqlite: error scanning entry")
+		}
+		if owner.String != "" {
+			e.Owner = &userpb.UserId{OpaqueId: owner.String}
+		}
+		e.Mtime = time.Unix(0, mtime)
+		e.Tags = search.ParseTags(tags)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// encodeTags lowercases the tags and encloses them in commas,
+// so a single tag can be matched with LIKE '%,tag,%'.
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "," + strings.ToLower(strings.Join(tags, ",")) + ","
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,146 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/search"
+)
+
+func newIndex(t *testing.T, dbFile string) search.Index {
+	i, err := New(map[string]interface{}{"db_file": dbFile})
+	if err != nil {
+		t.Fatalf("error creating index: %v", err)
+	}
+	return i
+}
+
+func TestSearch(t *testing.T) {
+	tmp, err := os.MkdirTemp("", "reva-search-sqlite-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	dbFile := filepath.Join(tmp, "search.db")
+
+	ctx := context.Background()
+	now := time.Now()
+	entries := []*search.Entry{
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "1"}, Owner: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}, Name: "Report 2021.pdf", MimeType: "application/pdf", Mtime: now.Add(-time.Hour), Size: 10, Tags: []string{"work"}},
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "2"}, Owner: &userpb.UserId{OpaqueId: "marie"}, Name: "report_draft.odt", MimeType: "application/vnd.oasis.opendocument.text", Mtime: now, Size: 20},
+		{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "3"}, Name: "holiday.jpg", MimeType: "image/jpeg", Mtime: now, Size: 30, Tags: []string{"Private", "work"}},
+	}
+	i := newIndex(t, dbFile)
+	for _, e := range entries {
+		if err := i.Upsert(ctx, e); err != nil {
+			t.Fatalf("error upserting entry: %v", err)
+		}
+	}
+
+	tests := map[string]struct {
+		query *search.Query
+		ids   []string
+	}{
+		"pattern is case insensitive": {&search.Query{Pattern: "REPORT"}, []string{"2", "1"}},
+		"underscore is no wildcard":   {&search.Query{Pattern: "t_d"}, []string{"2"}},
+		"mime type prefix":            {&search.Query{MimeType: "image/"}, []string{"3"}},
+		"all tags have to match":      {&search.Query{Tags: []string{"work", "private"}}, []string{"3"}},
+		"tags are matched as a whole": {&search.Query{Tags: []string{"wor"}}, []string{}},
+		"offset and limit":            {&search.Query{Pattern: "report", Offset: 1, Limit: 1}, []string{"1"}},
+		"owners by opaque id":         {&search.Query{Owners: []*userpb.UserId{{OpaqueId: "einstein"}, {OpaqueId: "richard"}}}, []string{"1"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			res, err := i.Search(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("error searching: %v", err)
+			}
+			if len(res) != len(tt.ids) {
+				t.Fatalf("expected %d results, got %d", len(tt.ids), len(res))
+			}
+			for j := range res {
+				if res[j].ID.OpaqueId != tt.ids[j] {
+					t.Errorf("expected result %d to be %s, got %s", j, tt.ids[j], res[j].ID.OpaqueId)
+				}
+			}
+		})
+	}
+
+	// renaming replaces the entry and removed entries are gone, also after a restart
+	renamed := *entries[0]
+	renamed.Name = "summary.pdf"
+	if err := i.Upsert(ctx, &renamed); err != nil {
+		t.Fatalf("error upserting entry: %v", err)
+	}
+	if err := i.Remove(ctx, entries[1].ID); err != nil {
+		t.Fatalf("error removing entry: %v", err)
+	}
+	i = newIndex(t, dbFile)
+	res, err := i.Search(ctx, &search.Query{Pattern: "report"})
+	if err != nil {
+		t.Fatalf("error searching: %v", err)
+	}
+	if len(res) != 0 {
+		t.Errorf("expected no results, got %d", len(res))
+	}
+	res, err = i.Search(ctx, &search.Query{Pattern: "summary"})
+	if err != nil {
+		t.Fatalf("error searching: %v", err)
+	}
+	if len(res) != 1 || res[0].Size != 10 || len(res[0].Tags) != 1 || res[0].Tags[0] != "work" || !res[0].Mtime.Equal(entries[0].Mtime) {
+		t.Errorf("unexpected result %+v", res)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "search.db")
+	db, err := sql.Open("sqlite3", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the schema of the first version, without the owner
+	if _, err := db.Exec("CREATE TABLE entries (key TEXT PRIMARY KEY, storage_id TEXT, opaque_id TEXT, name TEXT, name_lower TEXT, mime_type TEXT, mtime INTEGER, size INTEGER, tags TEXT, tags_lower TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO entries VALUES ('s!1', 's', '1', 'old.txt', 'old.txt', 'text/plain', 0, 1, '', '')"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	ctx := context.Background()
+	i := newIndex(t, dbFile)
+	if err := i.Upsert(ctx, &search.Entry{ID: &provider.ResourceId{StorageId: "s", OpaqueId: "2"}, Owner: &userpb.UserId{OpaqueId: "einstein"}, Name: "new.txt"}); err != nil {
+		t.Fatalf("error upserting entry: %v", err)
+	}
+	res, err := i.Search(ctx, &search.Query{Pattern: ".txt"})
+	if err != nil {
+		t.Fatalf("error searching: %v", err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("expected the old and the new entry, got %+v", res)
+	}
+}
@@ -32,6 +32,8 @@ import (
 	"github.com/cs3org/reva/pkg/share/cache"
 	"github.com/cs3org/reva/pkg/share/cache/warmup/registry"
 	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/cs3org/reva/pkg/token/session"
+	sessionregistry "github.com/cs3org/reva/pkg/token/session/registry"
 
 	// Provides mysql drivers.
 	_ "github.com/go-sql-driver/mysql"
@@ -53,6 +55,9 @@ type config struct {
 	EOSNamespace string `mapstructure:"namespace"`
 	GatewaySvc   string `mapstructure:"gatewaysvc"`
 	JWTSecret    string `mapstructure:"jwt_secret"`
+	// SessionStore records the token minted to warm up the cache, disabled if empty.
+	SessionStore  string                            `mapstructure:"session_store"`
+	SessionStores map[string]map[string]interface{} `mapstructure:"session_stores"`
 }
 
 type manager struct {
@@ -100,6 +105,11 @@ func (m *manager) GetResourceInfos() ([]*provider.ResourceInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	sessions, err := sessionregistry.GetStore(m.conf.SessionStore, m.conf.SessionStores)
+	if err != nil {
+		return nil, err
+	}
+	tokenManager = session.NewRecordingManager(tokenManager, sessions)
 
 	u := &userpb.User{
 		Id: &userpb.UserId{
@@ -95,7 +95,7 @@ func loadOrCreate(file string) (*shareModel, error) {
 		return nil, err
 	}
 
-	m := &shareModel{State: j.State}
+	m := &shareModel{State: j.State, Expiration: j.Expiration}
 	for _, s := range j.Shares {
 		var decShare collaboration.Share
 		if err = utils.UnmarshalJSONToProtoV1([]byte(s), &decShare); err != nil {
@@ -107,24 +107,29 @@ func loadOrCreate(file string) (*shareModel, error) {
 	if m.State == nil {
 		m.State = map[string]map[string]collaboration.ShareState{}
 	}
+	if m.Expiration == nil {
+		m.Expiration = map[string]*typespb.Timestamp{}
+	}
 
 	m.file = file
 	return m, nil
 }
 
 type shareModel struct {
-	file   string
-	State  map[string]map[string]collaboration.ShareState `json:"state"` // map[username]map[share_id]ShareState
-	Shares []*collaboration.Share                         `json:"shares"`
+	file       string
+	State      map[string]map[string]collaboration.ShareState `json:"state"`      // map[username]map[share_id]ShareState
+	Expiration map[string]*typespb.Timestamp                  `json:"expiration"` // map[share_id]Timestamp
+	Shares     []*collaboration.Share                         `json:"shares"`
 }
 
 type jsonEncoding struct {
-	State  map[string]map[string]collaboration.ShareState `json:"state"` // map[username]map[share_id]ShareState
-	Shares []string                                       `json:"shares"`
+	State      map[string]map[string]collaboration.ShareState `json:"state"`      // map[username]map[share_id]ShareState
+	Expiration map[string]*typespb.Timestamp                  `json:"expiration"` // map[share_id]Timestamp
+	Shares     []string                                       `json:"shares"`
 }
 
 func (m *shareModel) Save() error {
-	j := &jsonEncoding{State: m.State}
+	j := &jsonEncoding{State: m.State, Expiration: m.Expiration}
 	for _, s := range m.Shares {
 		encShare, err := utils.MarshalProtoV1ToJSON(s)
 		if err != nil {
@@ -175,7 +180,7 @@ func genID() string {
 	return uuid.New().String()
 }
 
-func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant) (*collaboration.Share, error) {
+func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant, expiration *typespb.Timestamp) (*collaboration.Share, error) {
 	id := genID()
 	user := ctxpkg.ContextMustGetUser(ctx)
 	now := time.Now().UnixNano()
@@ -221,6 +226,9 @@ func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collabora
 	defer m.Unlock()
 
 	m.model.Shares = append(m.model.Shares, s)
+	if expiration != nil {
+		m.model.Expiration[id] = expiration
+	}
 	if err := m.model.Save(); err != nil {
 		err = errors.Wrap(err, "error saving model")
 		return nil, err
@@ -299,6 +307,7 @@ func (m *mgr) Unshare(ctx context.Context, ref *collaboration.ShareReference) er
 			if share.IsCreatedByUser(s, user) {
 				m.model.Shares[len(m.model.Shares)-1], m.model.Shares[i] = m.model.Shares[i], m.model.Shares[len(m.model.Shares)-1]
 				m.model.Shares = m.model.Shares[:len(m.model.Shares)-1]
+				delete(m.model.Expiration, s.Id.OpaqueId)
 				if err := m.model.Save(); err != nil {
 					err = errors.Wrap(err, "error saving model")
 					return err
@@ -348,6 +357,54 @@ func (m *mgr) UpdateShare(ctx context.Context, ref *collaboration.ShareReference
 	return nil, errtypes.NotFound(ref.String())
 }
 
+func (m *mgr) UpdateShareExpiration(ctx context.Context, ref *collaboration.ShareReference, expiration *typespb.Timestamp) (*collaboration.Share, error) {
+	m.Lock()
+	defer m.Unlock()
+	user := ctxpkg.ContextMustGetUser(ctx)
+	for i, s := range m.model.Shares {
+		if sharesEqual(ref, s) {
+			if share.IsCreatedByUser(s, user) {
+				if expiration != nil {
+					m.model.Expiration[s.Id.OpaqueId] = expiration
+				} else {
+					delete(m.model.Expiration, s.Id.OpaqueId)
+				}
+				m.model.Shares[i].Mtime = utils.TSNow()
+				if err := m.model.Save(); err != nil {
+					err = errors.Wrap(err, "error saving model")
+					return nil, err
+				}
+				return m.model.Shares[i], nil
+			}
+		}
+	}
+	return nil, errtypes.NotFound(ref.String())
+}
+
+func (m *mgr) GetExpirations(ctx context.Context, ids []*collaboration.ShareId) (map[string]*typespb.Timestamp, error) {
+	m.Lock()
+	defer m.Unlock()
+	expirations := make(map[string]*typespb.Timestamp)
+	for _, id := range ids {
+		if e, ok := m.model.Expiration[id.GetOpaqueId()]; ok {
+			expirations[id.GetOpaqueId()] = e
+		}
+	}
+	return expirations, nil
+}
+
+func (m *mgr) ListExpiredShares(ctx context.Context, before time.Time) ([]*collaboration.Share, error) {
+	var ss []*collaboration.Share
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.model.Shares {
+		if e, ok := m.model.Expiration[s.Id.OpaqueId]; ok && utils.TSToTime(e).Before(before) {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
 func (m *mgr) ListShares(ctx context.Context, filters []*collaboration.Filter) ([]*collaboration.Share, error) {
 	var ss []*collaboration.Share
 	m.Lock()
@@ -380,6 +437,9 @@ func (m *mgr) ListReceivedShares(ctx context.Context, filters []*collaboration.F
 			// omit shares created by the user or shares the user can't access
 			continue
 		}
+		if share.IsExpired(m.model.Expiration[s.Id.OpaqueId]) {
+			continue
+		}
 
 		if len(filters) == 0 {
 			rs := m.convert(ctx, s)
@@ -420,7 +480,7 @@ func (m *mgr) getReceived(ctx context.Context, ref *collaboration.ShareReference
 	user := ctxpkg.ContextMustGetUser(ctx)
 	for _, s := range m.model.Shares {
 		if sharesEqual(ref, s) {
-			if share.IsGrantedToUser(s, user) {
+			if share.IsGrantedToUser(s, user) && !share.IsExpired(m.model.Expiration[s.Id.OpaqueId]) {
 				rs := m.convert(ctx, s)
 				return rs, nil
 			}
@@ -48,6 +48,7 @@ func New(c map[string]interface{}) (share.Manager, error) {
 	state := map[string]map[*collaboration.ShareId]collaboration.ShareState{}
 	return &manager{
 		shareState: state,
+		expiration: map[string]*typespb.Timestamp{},
 		lock:       &sync.Mutex{},
 	}, nil
 }
@@ -58,15 +59,21 @@ type manager struct {
 	// shareState contains the share state for a user.
 	// map["alice"]["share-id"]state.
 	shareState map[string]map[*collaboration.ShareId]collaboration.ShareState
+	// expiration contains the expiration of the shares which expire.
+	// map["share-id"]expiration.
+	expiration map[string]*typespb.Timestamp
 }
 
-func (m *manager) add(ctx context.Context, s *collaboration.Share) {
+func (m *manager) add(ctx context.Context, s *collaboration.Share, expiration *typespb.Timestamp) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 	m.shares = append(m.shares, s)
+	if expiration != nil {
+		m.expiration[s.Id.OpaqueId] = expiration
+	}
 }
 
-func (m *manager) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant) (*collaboration.Share, error) {
+func (m *manager) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant, expiration *typespb.Timestamp) (*collaboration.Share, error) {
 	id := atomic.AddUint64(&counter, 1)
 	user := ctxpkg.ContextMustGetUser(ctx)
 	now := time.Now().UnixNano()
@@ -105,7 +112,7 @@ func (m *manager) Share(ctx context.Context, md *provider.ResourceInfo, g *colla
 		Mtime:       ts,
 	}
 
-	m.add(ctx, s)
+	m.add(ctx, s, expiration)
 	return s, nil
 }
 
@@ -174,6 +181,7 @@ func (m *manager) Unshare(ctx context.Context, ref *collaboration.ShareReference
 			if share.IsCreatedByUser(s, user) {
 				m.shares[len(m.shares)-1], m.shares[i] = m.shares[i], m.shares[len(m.shares)-1]
 				m.shares = m.shares[:len(m.shares)-1]
+				delete(m.expiration, s.Id.OpaqueId)
 				return nil
 			}
 		}
@@ -215,6 +223,50 @@ func (m *manager) UpdateShare(ctx context.Context, ref *collaboration.ShareRefer
 	return nil, errtypes.NotFound(ref.String())
 }
 
+func (m *manager) UpdateShareExpiration(ctx context.Context, ref *collaboration.ShareReference, expiration *typespb.Timestamp) (*collaboration.Share, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	user := ctxpkg.ContextMustGetUser(ctx)
+	for i, s := range m.shares {
+		if sharesEqual(ref, s) {
+			if share.IsCreatedByUser(s, user) {
+				if expiration != nil {
+					m.expiration[s.Id.OpaqueId] = expiration
+				} else {
+					delete(m.expiration, s.Id.OpaqueId)
+				}
+				m.shares[i].Mtime = utils.TSNow()
+				return m.shares[i], nil
+			}
+		}
+	}
+	return nil, errtypes.NotFound(ref.String())
+}
+
+func (m *manager) GetExpirations(ctx context.Context, ids []*collaboration.ShareId) (map[string]*typespb.Timestamp, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	expirations := make(map[string]*typespb.Timestamp)
+	for _, id := range ids {
+		if e, ok := m.expiration[id.GetOpaqueId()]; ok {
+			expirations[id.GetOpaqueId()] = e
+		}
+	}
+	return expirations, nil
+}
+
+func (m *manager) ListExpiredShares(ctx context.Context, before time.Time) ([]*collaboration.Share, error) {
+	var ss []*collaboration.Share
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, s := range m.shares {
+		if e, ok := m.expiration[s.Id.OpaqueId]; ok && utils.TSToTime(e).Before(before) {
+			ss = append(ss, s)
+		}
+	}
+	return ss, nil
+}
+
 func (m *manager) ListShares(ctx context.Context, filters []*collaboration.Filter) ([]*collaboration.Share, error) {
 	var ss []*collaboration.Share
 	m.lock.Lock()
@@ -247,6 +299,9 @@ func (m *manager) ListReceivedShares(ctx context.Context, filters []*collaborati
 			// omit shares created by the user or shares the user can't access
 			continue
 		}
+		if share.IsExpired(m.expiration[s.Id.OpaqueId]) {
+			continue
+		}
 
 		if len(filters) == 0 {
 			rs := m.convert(ctx, s)
@@ -287,7 +342,7 @@ func (m *manager) getReceived(ctx context.Context, ref *collaboration.ShareRefer
 	user := ctxpkg.ContextMustGetUser(ctx)
 	for _, s := range m.shares {
 		if sharesEqual(ref, s) {
-			if share.IsGrantedToUser(s, user) {
+			if share.IsGrantedToUser(s, user) && !share.IsExpired(m.expiration[s.Id.OpaqueId]) {
 				rs := m.convert(ctx, s)
 				return rs, nil
 			}
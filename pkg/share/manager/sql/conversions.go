@@ -20,6 +20,7 @@ package sql
 
 import (
 	"context"
+	"time"
 
 	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -30,8 +31,12 @@ import (
 	conversions "github.com/cs3org/reva/internal/http/services/owncloud/ocs/conversions"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/utils"
 )
 
+// expirationFormat is the layout used to store the expiration in the datetime column.
+const expirationFormat = "2006-01-02 15:04:05"
+
 //go:generate mockery -name UserConverter
 
 // DBShare stores information about user and public shares.
@@ -190,6 +195,24 @@ func intToShareState(g int) collaboration.ShareState {
 	}
 }
 
+func formatExpiration(e *typespb.Timestamp) interface{} {
+	if e == nil {
+		return nil
+	}
+	return utils.TSToTime(e).UTC().Format(expirationFormat)
+}
+
+func parseExpiration(e string) (*typespb.Timestamp, error) {
+	t, err := time.ParseInLocation(expirationFormat, e, time.UTC)
+	if err != nil {
+		// some drivers hand out datetime columns already parsed, which then get scanned as RFC 3339
+		if t, err = time.Parse(time.RFC3339Nano, e); err != nil {
+			return nil, err
+		}
+	}
+	return &typespb.Timestamp{Seconds: uint64(t.Unix()), Nanos: uint32(t.Nanosecond())}, nil
+}
+
 func formatUserID(u *userpb.UserId) string {
 	return u.OpaqueId
 }
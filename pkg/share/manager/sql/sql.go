@@ -105,7 +105,12 @@ func parseConfig(m map[string]interface{}) (*config, error) {
 	return c, nil
 }
 
-func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant) (*collaboration.Share, error) {
+// Ready tells whether the database is reachable.
+func (m *mgr) Ready(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant, expiration *typespb.Timestamp) (*collaboration.Share, error) {
 	user := ctxpkg.ContextMustGetUser(ctx)
 
 	// do not allow share to myself or the owner if share is for a user
@@ -152,8 +157,8 @@ func (m *mgr) Share(ctx context.Context, md *provider.ResourceInfo, g *collabora
 		fileSource = 0
 	}
 
-	stmtString := "INSERT INTO oc_share (share_type,uid_owner,uid_initiator,item_type,item_source,file_source,permissions,stime,share_with,file_target) VALUES (?,?,?,?,?,?,?,?,?,?)"
-	stmtValues := []interface{}{shareType, owner, user.Username, itemType, itemSource, fileSource, permissions, now, shareWith, targetPath}
+	stmtString := "INSERT INTO oc_share (share_type,uid_owner,uid_initiator,item_type,item_source,file_source,permissions,stime,share_with,file_target,expiration) VALUES (?,?,?,?,?,?,?,?,?,?,?)"
+	stmtValues := []interface{}{shareType, owner, user.Username, itemType, itemSource, fileSource, permissions, now, shareWith, targetPath, formatExpiration(expiration)}
 
 	stmt, err := m.db.Prepare(stmtString)
 	if err != nil {
@@ -275,6 +280,100 @@ func (m *mgr) UpdateShare(ctx context.Context, ref *collaboration.ShareReference
 	return m.GetShare(ctx, ref)
 }
 
+func (m *mgr) UpdateShareExpiration(ctx context.Context, ref *collaboration.ShareReference, expiration *typespb.Timestamp) (*collaboration.Share, error) {
+	uid := ctxpkg.ContextMustGetUser(ctx).Username
+
+	var query string
+	params := []interface{}{}
+	switch {
+	case ref.GetId() != nil:
+		query = "update oc_share set expiration=?,stime=? where id=? AND (uid_owner=? or uid_initiator=?)"
+		params = append(params, formatExpiration(expiration), time.Now().Unix(), ref.GetId().OpaqueId, uid, uid)
+	case ref.GetKey() != nil:
+		key := ref.GetKey()
+		shareType, shareWith, err := m.formatGrantee(ctx, key.Grantee)
+		if err != nil {
+			return nil, err
+		}
+		owner := formatUserID(key.Owner)
+		query = "update oc_share set expiration=?,stime=? where (uid_owner=? or uid_initiator=?) AND item_source=? AND share_type=? AND share_with=? AND (uid_owner=? or uid_initiator=?)"
+		params = append(params, formatExpiration(expiration), time.Now().Unix(), owner, owner, key.ResourceId.StorageId, shareType, shareWith, uid, uid)
+	default:
+		return nil, errtypes.NotFound(ref.String())
+	}
+
+	stmt, err := m.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = stmt.Exec(params...); err != nil {
+		return nil, err
+	}
+
+	return m.GetShare(ctx, ref)
+}
+
+func (m *mgr) GetExpirations(ctx context.Context, ids []*collaboration.ShareId) (map[string]*typespb.Timestamp, error) {
+	expirations := make(map[string]*typespb.Timestamp)
+	if len(ids) == 0 {
+		return expirations, nil
+	}
+
+	params := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		params = append(params, id.GetOpaqueId())
+	}
+	query := "select id, expiration FROM oc_share WHERE expiration IS NOT NULL AND id in (?" + strings.Repeat(",?", len(ids)-1) + ")"
+	rows, err := m.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, e string
+		if err := rows.Scan(&id, &e); err != nil {
+			return nil, err
+		}
+		expiration, err := parseExpiration(e)
+		if err != nil {
+			return nil, err
+		}
+		expirations[id] = expiration
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return expirations, nil
+}
+
+func (m *mgr) ListExpiredShares(ctx context.Context, before time.Time) ([]*collaboration.Share, error) {
+	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(item_source, '') as item_source, id, stime, permissions, share_type FROM oc_share WHERE (share_type=? OR share_type=?) AND expiration IS NOT NULL AND expiration < ?"
+	rows, err := m.db.Query(query, shareTypeUser, shareTypeGroup, before.UTC().Format(expirationFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var s DBShare
+	shares := []*collaboration.Share{}
+	for rows.Next() {
+		if err := rows.Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.ItemSource, &s.ID, &s.STime, &s.Permissions, &s.ShareType); err != nil {
+			continue
+		}
+		share, err := m.convertToCS3Share(ctx, s, m.storageMountID)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
 func (m *mgr) ListShares(ctx context.Context, filters []*collaboration.Filter) ([]*collaboration.Share, error) {
 	uid := ctxpkg.ContextMustGetUser(ctx).Username
 	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(item_source, '') as item_source, id, stime, permissions, share_type FROM oc_share WHERE (uid_owner=? or uid_initiator=?)"
@@ -348,6 +447,8 @@ func (m *mgr) ListReceivedShares(ctx context.Context, filters []*collaboration.F
 	} else {
 		query += "AND (share_with=?)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now().UTC().Format(expirationFormat))
 
 	filterQuery, filterParams, err := translateFilters(filters)
 	if err != nil {
@@ -492,6 +593,8 @@ func (m *mgr) getReceivedByID(ctx context.Context, id *collaboration.ShareId) (*
 	} else {
 		query += "AND (share_with=?)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now().UTC().Format(expirationFormat))
 	if err := m.db.QueryRow(query, params...).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.ItemSource, &s.STime, &s.Permissions, &s.ShareType, &s.State); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errtypes.NotFound(id.OpaqueId)
@@ -521,6 +624,8 @@ func (m *mgr) getReceivedByKey(ctx context.Context, key *collaboration.ShareKey)
 	} else {
 		query += "AND (share_with=?)"
 	}
+	query += " AND (expiration IS NULL OR expiration > ?)"
+	params = append(params, time.Now().UTC().Format(expirationFormat))
 
 	if err := m.db.QueryRow(query, params...).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.ItemSource, &s.ID, &s.STime, &s.Permissions, &s.ShareType, &s.State); err != nil {
 		if err == sql.ErrNoRows {
@@ -22,10 +22,12 @@ import (
 	"context"
 	"database/sql"
 	"os"
+	"time"
 
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	ruser "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/share"
 	sqlmanager "github.com/cs3org/reva/pkg/share/manager/sql"
@@ -152,7 +154,7 @@ var _ = Describe("SQL manager", func() {
 					OpaqueId:  "something",
 				},
 			}
-			share, err := mgr.Share(ctx, info, grant)
+			share, err := mgr.Share(ctx, info, grant, nil)
 
 			Expect(err).ToNot(HaveOccurred())
 			Expect(share).ToNot(BeNil())
@@ -271,4 +273,62 @@ var _ = Describe("SQL manager", func() {
 			Expect(share.Permissions.Permissions.Delete).To(BeFalse())
 		})
 	})
+
+	Describe("UpdateShareExpiration", func() {
+		It("hides and reaps expired shares", func() {
+			past := &typespb.Timestamp{Seconds: uint64(time.Now().Add(-time.Hour).Unix())}
+			sh, err := mgr.UpdateShareExpiration(ctx, shareRef, past)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sh).ToNot(BeNil())
+
+			expirations, err := mgr.GetExpirations(ctx, []*collaboration.ShareId{shareRef.GetId()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(expirations).To(HaveKey("1"))
+			Expect(expirations["1"].Seconds).To(Equal(past.Seconds))
+
+			expired, err := mgr.ListExpiredShares(ctx, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(expired)).To(Equal(1))
+			Expect(expired[0].Id.OpaqueId).To(Equal("1"))
+
+			loginAs(otherUser)
+			shares, err := mgr.ListReceivedShares(ctx, []*collaboration.Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(shares)).To(Equal(0))
+			_, err = mgr.GetReceivedShare(ctx, shareRef)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("keeps shares which expire in the future", func() {
+			future := &typespb.Timestamp{Seconds: uint64(time.Now().Add(time.Hour).Unix())}
+			_, err := mgr.UpdateShareExpiration(ctx, shareRef, future)
+			Expect(err).ToNot(HaveOccurred())
+
+			expired, err := mgr.ListExpiredShares(ctx, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(expired)).To(Equal(0))
+
+			loginAs(otherUser)
+			shares, err := mgr.ListReceivedShares(ctx, []*collaboration.Filter{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(shares)).To(Equal(1))
+		})
+
+		It("removes the expiration", func() {
+			past := &typespb.Timestamp{Seconds: uint64(time.Now().Add(-time.Hour).Unix())}
+			_, err := mgr.UpdateShareExpiration(ctx, shareRef, past)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = mgr.UpdateShareExpiration(ctx, shareRef, nil)
+			Expect(err).ToNot(HaveOccurred())
+
+			expirations, err := mgr.GetExpirations(ctx, []*collaboration.ShareId{shareRef.GetId()})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(expirations).To(BeEmpty())
+
+			loginAs(otherUser)
+			sh, err := mgr.GetReceivedShare(ctx, shareRef)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sh).ToNot(BeNil())
+		})
+	})
 })
@@ -30,6 +30,10 @@ import (
 	mock "github.com/stretchr/testify/mock"
 
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+
+	time "time"
+
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 )
 
 // Manager is an autogenerated mock type for the Manager type
@@ -37,6 +41,29 @@ type Manager struct {
 	mock.Mock
 }
 
+// GetExpirations provides a mock function with given fields: ctx, ids
+func (_m *Manager) GetExpirations(ctx context.Context, ids []*collaborationv1beta1.ShareId) (map[string]*typesv1beta1.Timestamp, error) {
+	ret := _m.Called(ctx, ids)
+
+	var r0 map[string]*typesv1beta1.Timestamp
+	if rf, ok := ret.Get(0).(func(context.Context, []*collaborationv1beta1.ShareId) map[string]*typesv1beta1.Timestamp); ok {
+		r0 = rf(ctx, ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(map[string]*typesv1beta1.Timestamp)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, []*collaborationv1beta1.ShareId) error); ok {
+		r1 = rf(ctx, ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetReceivedShare provides a mock function with given fields: ctx, ref
 func (_m *Manager) GetReceivedShare(ctx context.Context, ref *collaborationv1beta1.ShareReference) (*collaborationv1beta1.ReceivedShare, error) {
 	ret := _m.Called(ctx, ref)
@@ -83,6 +110,29 @@ func (_m *Manager) GetShare(ctx context.Context, ref *collaborationv1beta1.Share
 	return r0, r1
 }
 
+// ListExpiredShares provides a mock function with given fields: ctx, before
+func (_m *Manager) ListExpiredShares(ctx context.Context, before time.Time) ([]*collaborationv1beta1.Share, error) {
+	ret := _m.Called(ctx, before)
+
+	var r0 []*collaborationv1beta1.Share
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) []*collaborationv1beta1.Share); ok {
+		r0 = rf(ctx, before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*collaborationv1beta1.Share)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ListReceivedShares provides a mock function with given fields: ctx, filters
 func (_m *Manager) ListReceivedShares(ctx context.Context, filters []*collaborationv1beta1.Filter) ([]*collaborationv1beta1.ReceivedShare, error) {
 	ret := _m.Called(ctx, filters)
@@ -129,13 +179,13 @@ func (_m *Manager) ListShares(ctx context.Context, filters []*collaborationv1bet
 	return r0, r1
 }
 
-// Share provides a mock function with given fields: ctx, md, g
-func (_m *Manager) Share(ctx context.Context, md *providerv1beta1.ResourceInfo, g *collaborationv1beta1.ShareGrant) (*collaborationv1beta1.Share, error) {
-	ret := _m.Called(ctx, md, g)
+// Share provides a mock function with given fields: ctx, md, g, expiration
+func (_m *Manager) Share(ctx context.Context, md *providerv1beta1.ResourceInfo, g *collaborationv1beta1.ShareGrant, expiration *typesv1beta1.Timestamp) (*collaborationv1beta1.Share, error) {
+	ret := _m.Called(ctx, md, g, expiration)
 
 	var r0 *collaborationv1beta1.Share
-	if rf, ok := ret.Get(0).(func(context.Context, *providerv1beta1.ResourceInfo, *collaborationv1beta1.ShareGrant) *collaborationv1beta1.Share); ok {
-		r0 = rf(ctx, md, g)
+	if rf, ok := ret.Get(0).(func(context.Context, *providerv1beta1.ResourceInfo, *collaborationv1beta1.ShareGrant, *typesv1beta1.Timestamp) *collaborationv1beta1.Share); ok {
+		r0 = rf(ctx, md, g, expiration)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*collaborationv1beta1.Share)
@@ -143,8 +193,8 @@ func (_m *Manager) Share(ctx context.Context, md *providerv1beta1.ResourceInfo,
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, *providerv1beta1.ResourceInfo, *collaborationv1beta1.ShareGrant) error); ok {
-		r1 = rf(ctx, md, g)
+	if rf, ok := ret.Get(1).(func(context.Context, *providerv1beta1.ResourceInfo, *collaborationv1beta1.ShareGrant, *typesv1beta1.Timestamp) error); ok {
+		r1 = rf(ctx, md, g, expiration)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -211,3 +261,26 @@ func (_m *Manager) UpdateShare(ctx context.Context, ref *collaborationv1beta1.Sh
 
 	return r0, r1
 }
+
+// UpdateShareExpiration provides a mock function with given fields: ctx, ref, expiration
+func (_m *Manager) UpdateShareExpiration(ctx context.Context, ref *collaborationv1beta1.ShareReference, expiration *typesv1beta1.Timestamp) (*collaborationv1beta1.Share, error) {
+	ret := _m.Called(ctx, ref, expiration)
+
+	var r0 *collaborationv1beta1.Share
+	if rf, ok := ret.Get(0).(func(context.Context, *collaborationv1beta1.ShareReference, *typesv1beta1.Timestamp) *collaborationv1beta1.Share); ok {
+		r0 = rf(ctx, ref, expiration)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*collaborationv1beta1.Share)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *collaborationv1beta1.ShareReference, *typesv1beta1.Timestamp) error); ok {
+		r1 = rf(ctx, ref, expiration)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
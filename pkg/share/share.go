@@ -20,10 +20,13 @@ package share
 
 import (
 	"context"
+	"encoding/json"
+	"time"
 
 	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/owncloud/ocs/conversions"
 	"github.com/cs3org/reva/pkg/utils"
 	"google.golang.org/genproto/protobuf/field_mask"
@@ -33,8 +36,8 @@ import (
 
 // Manager is the interface that manipulates shares.
 type Manager interface {
-	// Create a new share in fn with the given acl.
-	Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant) (*collaboration.Share, error)
+	// Create a new share in fn with the given acl. A nil expiration creates a share which never expires.
+	Share(ctx context.Context, md *provider.ResourceInfo, g *collaboration.ShareGrant, expiration *typesv1beta1.Timestamp) (*collaboration.Share, error)
 
 	// GetShare gets the information for a share by the given ref.
 	GetShare(ctx context.Context, ref *collaboration.ShareReference) (*collaboration.Share, error)
@@ -45,6 +48,16 @@ type Manager interface {
 	// UpdateShare updates the mode of the given share.
 	UpdateShare(ctx context.Context, ref *collaboration.ShareReference, p *collaboration.SharePermissions) (*collaboration.Share, error)
 
+	// UpdateShareExpiration sets the expiration of the given share. A nil expiration removes it.
+	UpdateShareExpiration(ctx context.Context, ref *collaboration.ShareReference, expiration *typesv1beta1.Timestamp) (*collaboration.Share, error)
+
+	// GetExpirations returns the expiration of the given shares indexed by their opaque id.
+	// Shares which never expire are omitted.
+	GetExpirations(ctx context.Context, ids []*collaboration.ShareId) (map[string]*typesv1beta1.Timestamp, error)
+
+	// ListExpiredShares returns all the shares which expired before the given time, regardless of their owner.
+	ListExpiredShares(ctx context.Context, before time.Time) ([]*collaboration.Share, error)
+
 	// ListShares returns the shares created by the user. If md is provided is not nil,
 	// it returns only shares attached to the given resource.
 	ListShares(ctx context.Context, filters []*collaboration.Filter) ([]*collaboration.Share, error)
@@ -59,6 +72,73 @@ type Manager interface {
 	UpdateReceivedShare(ctx context.Context, share *collaboration.ReceivedShare, fieldMask *field_mask.FieldMask) (*collaboration.ReceivedShare, error)
 }
 
+const (
+	// the cs3 share messages have no expiration field, so the expiration travels in the opaque
+	expirationOpaqueKey  = "expiration"
+	expirationsOpaqueKey = "expirations"
+)
+
+// IsExpired checks if the given expiration lies in the past. A nil expiration never expires.
+func IsExpired(expiration *typesv1beta1.Timestamp) bool {
+	return expiration != nil && utils.TSToTime(expiration).Before(time.Now())
+}
+
+// AddExpirationToOpaque stores the expiration of a single share in the opaque.
+// A nil expiration is stored as well and signals that the expiration should be removed.
+func AddExpirationToOpaque(o *typesv1beta1.Opaque, expiration *typesv1beta1.Timestamp) (*typesv1beta1.Opaque, error) {
+	return addJSONToOpaque(o, expirationOpaqueKey, expiration)
+}
+
+// ExpirationFromOpaque reads the expiration of a single share from the opaque.
+// The returned bool reports whether the opaque carried an expiration at all.
+func ExpirationFromOpaque(o *typesv1beta1.Opaque) (*typesv1beta1.Timestamp, bool, error) {
+	entry, ok := o.GetMap()[expirationOpaqueKey]
+	if !ok {
+		return nil, false, nil
+	}
+	var expiration *typesv1beta1.Timestamp
+	if err := json.Unmarshal(entry.Value, &expiration); err != nil {
+		return nil, true, err
+	}
+	return expiration, true, nil
+}
+
+// AddExpirationsToOpaque stores the expirations of a list of shares, indexed by share id, in the opaque.
+func AddExpirationsToOpaque(o *typesv1beta1.Opaque, expirations map[string]*typesv1beta1.Timestamp) (*typesv1beta1.Opaque, error) {
+	if len(expirations) == 0 {
+		return o, nil
+	}
+	return addJSONToOpaque(o, expirationsOpaqueKey, expirations)
+}
+
+// ExpirationsFromOpaque reads the expirations of a list of shares, indexed by share id, from the opaque.
+func ExpirationsFromOpaque(o *typesv1beta1.Opaque) (map[string]*typesv1beta1.Timestamp, error) {
+	expirations := map[string]*typesv1beta1.Timestamp{}
+	entry, ok := o.GetMap()[expirationsOpaqueKey]
+	if !ok {
+		return expirations, nil
+	}
+	if err := json.Unmarshal(entry.Value, &expirations); err != nil {
+		return nil, err
+	}
+	return expirations, nil
+}
+
+func addJSONToOpaque(o *typesv1beta1.Opaque, key string, v interface{}) (*typesv1beta1.Opaque, error) {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		o = &typesv1beta1.Opaque{}
+	}
+	if o.Map == nil {
+		o.Map = map[string]*typesv1beta1.OpaqueEntry{}
+	}
+	o.Map[key] = &typesv1beta1.OpaqueEntry{Decoder: "json", Value: val}
+	return o, nil
+}
+
 // GroupGranteeFilter is an abstraction for creating filter by grantee type group.
 func GroupGranteeFilter() *collaboration.Filter {
 	return &collaboration.Filter{
@@ -20,11 +20,13 @@ package share
 
 import (
 	"testing"
+	"time"
 
 	groupv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
 	userv1beta1 "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 )
 
 func TestIsCreatedByUser(t *testing.T) {
@@ -211,3 +213,54 @@ func TestGroupFiltersByType(t *testing.T) {
 		}
 	}
 }
+
+func TestIsExpired(t *testing.T) {
+	past := &typesv1beta1.Timestamp{Seconds: uint64(time.Now().Add(-time.Minute).Unix())}
+	future := &typesv1beta1.Timestamp{Seconds: uint64(time.Now().Add(time.Minute).Unix())}
+
+	if IsExpired(nil) {
+		t.Error("Expected a share without expiration to never expire")
+	}
+	if !IsExpired(past) {
+		t.Error("Expected a share with an expiration in the past to be expired")
+	}
+	if IsExpired(future) {
+		t.Error("Expected a share with an expiration in the future to not be expired")
+	}
+}
+
+func TestExpirationOpaque(t *testing.T) {
+	expiration := &typesv1beta1.Timestamp{Seconds: 1234}
+
+	if _, ok, err := ExpirationFromOpaque(nil); ok || err != nil {
+		t.Errorf("Expected no expiration in an empty opaque, got %v, %v", ok, err)
+	}
+
+	o, err := AddExpirationToOpaque(nil, expiration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok, err := ExpirationFromOpaque(o)
+	if err != nil || !ok || e.Seconds != expiration.Seconds {
+		t.Errorf("Expected expiration %v got %v, %v, %v", expiration, e, ok, err)
+	}
+
+	// a nil expiration is transported to signal its removal
+	o, err = AddExpirationToOpaque(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, ok, err = ExpirationFromOpaque(o)
+	if err != nil || !ok || e != nil {
+		t.Errorf("Expected a removed expiration got %v, %v, %v", e, ok, err)
+	}
+
+	o, err = AddExpirationsToOpaque(nil, map[string]*typesv1beta1.Timestamp{"1": expiration})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expirations, err := ExpirationsFromOpaque(o)
+	if err != nil || len(expirations) != 1 || expirations["1"].Seconds != expiration.Seconds {
+		t.Errorf("Expected expirations for share 1 got %v, %v", expirations, err)
+	}
+}
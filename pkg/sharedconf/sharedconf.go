@@ -22,17 +22,26 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/cs3org/reva/pkg/rgrpc/mtls"
 	"github.com/mitchellh/mapstructure"
 )
 
 var sharedConf = &conf{}
 
 type conf struct {
-	JWTSecret             string   `mapstructure:"jwt_secret"`
-	GatewaySVC            string   `mapstructure:"gatewaysvc"`
-	DataGateway           string   `mapstructure:"datagateway"`
-	SkipUserGroupsInToken bool     `mapstructure:"skip_user_groups_in_token"`
-	BlockedUsers          []string `mapstructure:"blocked_users"`
+	JWTSecret             string    `mapstructure:"jwt_secret"`
+	GatewaySVC            string    `mapstructure:"gatewaysvc"`
+	DataGateway           string    `mapstructure:"datagateway"`
+	SkipUserGroupsInToken bool      `mapstructure:"skip_user_groups_in_token"`
+	BlockedUsers          []string  `mapstructure:"blocked_users"`
+	GRPCClientTLS         clientTLS `mapstructure:"grpc_client_tls"`
+}
+
+// clientTLS is the tls configuration of the grpc clients. The configuration
+// of an endpoint, keyed by its address, replaces the default one.
+type clientTLS struct {
+	mtls.ClientConfig `mapstructure:",squash"`
+	Endpoints         map[string]*mtls.ClientConfig `mapstructure:"endpoints"`
 }
 
 // Decode decodes the configuration.
@@ -94,6 +103,14 @@ func SkipUserGroupsInToken() bool {
 	return sharedConf.SkipUserGroupsInToken
 }
 
+// GetGRPCClientTLS returns the tls configuration of the grpc connections to the endpoint.
+func GetGRPCClientTLS(endpoint string) *mtls.ClientConfig {
+	if c, ok := sharedConf.GRPCClientTLS.Endpoints[endpoint]; ok {
+		return c
+	}
+	return &sharedConf.GRPCClientTLS.ClientConfig
+}
+
 // GetBlockedUsers returns a list of blocked users.
 func GetBlockedUsers() []string {
 	return sharedConf.BlockedUsers
@@ -62,3 +62,28 @@ func Test(t *testing.T) {
 		t.Fatalf("expected %q got %q", "dummy", got)
 	}
 }
+
+func TestGRPCClientTLS(t *testing.T) {
+	conf := map[string]interface{}{
+		"grpc_client_tls": map[string]interface{}{
+			"enabled": true,
+			"cafile":  "/etc/reva/ca.crt",
+			"endpoints": map[string]interface{}{
+				"localhost:19000": map[string]interface{}{
+					"enabled": false,
+				},
+			},
+		},
+	}
+
+	if err := Decode(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	if c := GetGRPCClientTLS("storage:19000"); !c.Enabled || c.CAFile != "/etc/reva/ca.crt" {
+		t.Fatalf("expected the default configuration, got %+v", c)
+	}
+	if c := GetGRPCClientTLS("localhost:19000"); c.Enabled {
+		t.Fatalf("expected the configuration of the endpoint, got %+v", c)
+	}
+}
@@ -30,6 +30,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	cephfs2 "github.com/ceph/go-ceph/cephfs"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
@@ -39,6 +41,7 @@ import (
 	"github.com/cs3org/reva/pkg/storage/fs/registry"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 const (
@@ -56,6 +59,7 @@ type cephfs struct {
 	conn         *connections
 	adminConn    *adminConn
 	chunkHandler *ChunkHandler
+	locksMu      sync.Mutex
 }
 
 func init() {
@@ -82,18 +86,29 @@ func New(m map[string]interface{}) (fs storage.FS, err error) {
 		return nil, errors.Wrap(err, "cephfs: Couldn't create admin connections")
 	}
 
-	for _, dir := range []string{c.ShadowFolder, c.UploadFolder} {
+	for _, dir := range []string{c.ShadowFolder, c.UploadFolder, c.IndexFolder} {
 		err = adminConn.adminMount.MakeDir(dir, dirPermFull)
 		if err != nil && err.Error() != errFileExists {
 			return nil, errors.New("cephfs: can't initialise system dir " + dir + ":" + err.Error())
 		}
 	}
 
-	return &cephfs{
+	cfs := &cephfs{
 		conf:      c,
 		conn:      cache,
 		adminConn: adminConn,
-	}, nil
+	}
+	if c.Reindex {
+		go func() {
+			if err := cfs.indexTree(string(filepath.Separator)); err != nil {
+				log.Error().Err(err).Msg("cephfs: error indexing the entries")
+				return
+			}
+			log.Info().Msg("cephfs: indexed the entries")
+		}()
+	}
+
+	return cfs, nil
 }
 
 func (fs *cephfs) GetHome(ctx context.Context) (string, error) {
@@ -157,7 +172,7 @@ func (fs *cephfs) CreateDir(ctx context.Context, ref *provider.Reference) error
 			return
 		}
 
-		//TODO(tmourati): Add entry id logic
+		_, err = fs.getEID(path)
 	})
 
 	return getRevaError(err)
@@ -171,12 +186,23 @@ func (fs *cephfs) Delete(ctx context.Context, ref *provider.Reference) (err erro
 		return err
 	}
 
+	if path == user.home || path == string(filepath.Separator) {
+		return errtypes.BadRequest("cephfs: cannot delete the root of the storage")
+	}
+	if err = fs.checkLock(ctx, path); err != nil {
+		return err
+	}
+
 	user.op(func(cv *cacheVal) {
-		if err = cv.mount.Unlink(path); err != nil && err.Error() == errIsADirectory {
-			err = cv.mount.RemoveDir(path)
+		// references in the share folder are not moved to the trash
+		if strings.HasPrefix(strings.TrimPrefix(path, user.home), fs.conf.ShareFolder) {
+			if err = cv.mount.Unlink(path); err != nil && err.Error() == errIsADirectory {
+				err = cv.mount.RemoveDir(path)
+			}
+			return
 		}
 
-		//TODO(tmourati): Add entry id logic
+		err = fs.moveToTrash(user, cv, path)
 	})
 
 	//has already been deleted by direct mount
@@ -196,13 +222,29 @@ func (fs *cephfs) Move(ctx context.Context, oldRef, newRef *provider.Reference)
 	if newPath, err = user.resolveRef(newRef); err != nil {
 		return
 	}
+	for _, p := range []string{oldPath, filepath.Dir(newPath)} {
+		if err = fs.checkLock(ctx, p); err != nil {
+			return
+		}
+	}
 
 	user.op(func(cv *cacheVal) {
+		// the index entry of an overwritten target would point to the moved entry
+		overwritten, _ := fs.adminConn.adminMount.GetXattr(newPath, xattrEID)
+
 		if err = cv.mount.Rename(oldPath, newPath); err != nil {
 			return
 		}
+		if overwritten != nil {
+			fs.removeIndex(string(overwritten))
+		}
 
-		//TODO(tmourati): Add entry id logic, handle already moved file error
+		// the id moves along with the entry, only its own index entry changes
+		var eid string
+		if eid, err = fs.getEID(newPath); err != nil {
+			return
+		}
+		err = fs.indexEntry(newPath, eid)
 	})
 
 	// has already been moved by direct mount
@@ -284,6 +326,10 @@ func (fs *cephfs) Download(ctx context.Context, ref *provider.Reference) (rc io.
 		return nil, errors.Wrap(err, "cephfs: error resolving ref")
 	}
 
+	if err = fs.checkReadLock(ctx, path); err != nil {
+		return nil, err
+	}
+
 	user.op(func(cv *cacheVal) {
 		if strings.HasPrefix(strings.TrimPrefix(path, user.home), fs.conf.ShareFolder) {
 			err = errtypes.PermissionDenied("cephfs: cannot download under the virtual share folder")
@@ -296,90 +342,80 @@ func (fs *cephfs) Download(ctx context.Context, ref *provider.Reference) (rc io.
 }
 
 func (fs *cephfs) ListRevisions(ctx context.Context, ref *provider.Reference) (fvs []*provider.FileVersion, err error) {
-	//TODO(tmourati): Fix entry id logic
 	var path string
 	user := fs.makeUser(ctx)
 	if path, err = user.resolveRef(ref); err != nil {
 		return nil, errors.Wrap(err, "cephfs: error resolving ref")
 	}
 
-	user.op(func(cv *cacheVal) {
-		if strings.HasPrefix(path, removeLeadingSlash(fs.conf.ShareFolder)) {
-			err = errtypes.PermissionDenied("cephfs: cannot download under the virtual share folder")
-			return
-		}
-		var dir *cephfs2.Directory
-		if dir, err = cv.mount.OpenDir(".snap"); err != nil {
-			return
-		}
-		defer closeDir(dir)
+	if strings.HasPrefix(strings.TrimPrefix(path, user.home), fs.conf.ShareFolder) {
+		return nil, errtypes.PermissionDenied("cephfs: cannot list revisions under the virtual share folder")
+	}
 
-		for d, _ := dir.ReadDir(); d != nil; d, _ = dir.ReadDir() {
-			var revPath string
-			var stat Statx
-			var e error
+	// snapshots are taken at the root of the mount, the names of the snapshot dirs are the revision keys
+	var dir *cephfs2.Directory
+	if dir, err = fs.adminConn.adminMount.OpenDir(addLeadingSlash(snap)); err != nil {
+		return nil, getRevaError(err)
+	}
+	defer closeDir(dir)
 
-			if strings.HasPrefix(d.Name(), ".") {
-				continue
-			}
+	var revs []string
+	for d, _ := dir.ReadDir(); d != nil; d, _ = dir.ReadDir() {
+		if strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		revs = append(revs, d.Name())
+	}
 
-			revPath, e = resolveRevRef(cv.mount, ref, d.Name())
-			if e != nil {
-				continue
-			}
-			stat, e = cv.mount.Statx(revPath, cephfs2.StatxMtime|cephfs2.StatxSize, 0)
-			if e != nil {
+	user.op(func(cv *cacheVal) {
+		for _, rev := range revs {
+			stat, e := cv.mount.Statx(fs.resolveRevRef(path, rev), cephfs2.StatxMode|cephfs2.StatxMtime|cephfs2.StatxSize, 0)
+			if e != nil || int(stat.Mode)&syscall.S_IFMT != syscall.S_IFREG {
 				continue
 			}
 			fvs = append(fvs, &provider.FileVersion{
-				Key:   d.Name(),
+				Key:   rev,
 				Size:  stat.Size,
 				Mtime: uint64(stat.Mtime.Sec),
 			})
 		}
 	})
 
-	return fvs, getRevaError(err)
+	return fvs, nil
 }
 
 func (fs *cephfs) DownloadRevision(ctx context.Context, ref *provider.Reference, key string) (file io.ReadCloser, err error) {
-	//TODO(tmourati): Fix entry id logic
+	var path string
 	user := fs.makeUser(ctx)
+	if path, err = user.resolveRef(ref); err != nil {
+		return nil, errors.Wrap(err, "cephfs: error resolving ref")
+	}
 
 	user.op(func(cv *cacheVal) {
-		var revPath string
-		revPath, err = resolveRevRef(cv.mount, ref, key)
-		if err != nil {
-			return
-		}
-
-		file, err = cv.mount.Open(revPath, os.O_RDONLY, 0)
+		file, err = cv.mount.Open(fs.resolveRevRef(path, key), os.O_RDONLY, 0)
 	})
 
 	return file, getRevaError(err)
 }
 
 func (fs *cephfs) RestoreRevision(ctx context.Context, ref *provider.Reference, key string) (err error) {
-	//TODO(tmourati): Fix entry id logic
 	var path string
 	user := fs.makeUser(ctx)
 	if path, err = user.resolveRef(ref); err != nil {
 		return errors.Wrap(err, "cephfs: error resolving ref")
 	}
+	if err = fs.checkLock(ctx, path); err != nil {
+		return err
+	}
 
 	user.op(func(cv *cacheVal) {
-		var revPath string
-		if revPath, err = resolveRevRef(cv.mount, ref, key); err != nil {
-			err = errors.Wrap(err, "cephfs: error resolving revision ref "+ref.String())
-			return
-		}
-
 		var src, dst *cephfs2.File
-		if src, err = cv.mount.Open(revPath, os.O_RDONLY, 0); err != nil {
+		if src, err = cv.mount.Open(fs.resolveRevRef(path, key), os.O_RDONLY, 0); err != nil {
 			return
 		}
 		defer closeFile(src)
 
+		// the content is copied over the current file, which keeps its entry id
 		if dst, err = cv.mount.Open(path, os.O_WRONLY|os.O_TRUNC, 0); err != nil {
 			return
 		}
@@ -392,8 +428,11 @@ func (fs *cephfs) RestoreRevision(ctx context.Context, ref *provider.Reference,
 }
 
 func (fs *cephfs) GetPathByID(ctx context.Context, id *provider.ResourceId) (str string, err error) {
-	//TODO(tmourati): Add entry id logic
-	return "", errtypes.NotSupported("cephfs: entry IDs currently not supported")
+	if str, err = fs.resolveEID(string(filepath.Separator), id.OpaqueId); err != nil {
+		return "", getRevaError(err)
+	}
+
+	return str, nil
 }
 
 func (fs *cephfs) AddGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) (err error) {
@@ -531,14 +570,16 @@ func (fs *cephfs) SetArbitraryMetadata(ctx context.Context, ref *provider.Refere
 	if path, err = user.resolveRef(ref); err != nil {
 		return err
 	}
+	if err = fs.checkLock(ctx, path); err != nil {
+		return err
+	}
 
 	user.op(func(cv *cacheVal) {
 		for k, v := range md.Metadata {
 			if !strings.HasPrefix(k, xattrUserNs) {
 				k = xattrUserNs + k
 			}
-			if e := cv.mount.SetXattr(path, k, []byte(v), 0); e != nil {
-				err = errors.Wrap(err, e.Error())
+			if err = cv.mount.SetXattr(path, k, []byte(v), 0); err != nil {
 				return
 			}
 		}
@@ -553,16 +594,20 @@ func (fs *cephfs) UnsetArbitraryMetadata(ctx context.Context, ref *provider.Refe
 	if path, err = user.resolveRef(ref); err != nil {
 		return err
 	}
+	if err = fs.checkLock(ctx, path); err != nil {
+		return err
+	}
 
 	user.op(func(cv *cacheVal) {
 		for _, key := range keys {
 			if !strings.HasPrefix(key, xattrUserNs) {
 				key = xattrUserNs + key
 			}
-			if e := cv.mount.RemoveXattr(path, key); e != nil {
-				err = errors.Wrap(err, e.Error())
+			// unsetting a key that is not set is not an error
+			if err = cv.mount.RemoveXattr(path, key); err != nil && err.Error() != errNoData {
 				return
 			}
+			err = nil
 		}
 	})
 
@@ -575,6 +620,9 @@ func (fs *cephfs) TouchFile(ctx context.Context, ref *provider.Reference) error
 	if err != nil {
 		return getRevaError(err)
 	}
+	if err = fs.checkLock(ctx, path); err != nil {
+		return err
+	}
 
 	user.op(func(cv *cacheVal) {
 		var file *cephfs2.File
@@ -583,32 +631,16 @@ func (fs *cephfs) TouchFile(ctx context.Context, ref *provider.Reference) error
 			return
 		}
 
-		//TODO(tmourati): Add entry id logic
+		_, err = fs.getEID(path)
 	})
 
 	return getRevaError(err)
 }
 
-func (fs *cephfs) EmptyRecycle(ctx context.Context) error {
-	return errtypes.NotSupported("unimplemented")
-}
-
 func (fs *cephfs) CreateStorageSpace(ctx context.Context, req *provider.CreateStorageSpaceRequest) (r *provider.CreateStorageSpaceResponse, err error) {
 	return nil, errtypes.NotSupported("unimplemented")
 }
 
-func (fs *cephfs) ListRecycle(ctx context.Context, basePath, key, relativePath string) ([]*provider.RecycleItem, error) {
-	return nil, errtypes.NotSupported("unimplemented")
-}
-
-func (fs *cephfs) RestoreRecycleItem(ctx context.Context, basePath, key, relativePath string, restoreRef *provider.Reference) error {
-	return errtypes.NotSupported("unimplemented")
-}
-
-func (fs *cephfs) PurgeRecycleItem(ctx context.Context, basePath, key, relativePath string) error {
-	return errtypes.NotSupported("unimplemented")
-}
-
 func (fs *cephfs) ListStorageSpaces(ctx context.Context, filter []*provider.ListStorageSpacesRequest_Filter) ([]*provider.StorageSpace, error) {
 	return nil, errtypes.NotSupported("unimplemented")
 }
@@ -616,19 +648,3 @@ func (fs *cephfs) ListStorageSpaces(ctx context.Context, filter []*provider.List
 func (fs *cephfs) UpdateStorageSpace(ctx context.Context, req *provider.UpdateStorageSpaceRequest) (*provider.UpdateStorageSpaceResponse, error) {
 	return nil, errtypes.NotSupported("unimplemented")
 }
-
-func (fs *cephfs) SetLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
-}
-
-func (fs *cephfs) GetLock(ctx context.Context, ref *provider.Reference) (*provider.Lock, error) {
-	return nil, errtypes.NotSupported("unimplemented")
-}
-
-func (fs *cephfs) RefreshLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock, existingLockID string) error {
-	return errtypes.NotSupported("unimplemented")
-}
-
-func (fs *cephfs) Unlock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
-}
@@ -43,6 +43,7 @@ var (
 	errNoSpaceLeft      = wrapErrorMsg(C.ENOSPC)
 	errIsADirectory     = wrapErrorMsg(C.EISDIR)
 	errPermissionDenied = wrapErrorMsg(C.EACCES)
+	errNoData           = wrapErrorMsg(C.ENODATA)
 )
 
 func getRevaError(err error) error {
@@ -0,0 +1,206 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+//go:build ceph
+// +build ceph
+
+package cephfs
+
+import (
+	"context"
+
+	cephfs2 "github.com/ceph/go-ceph/cephfs"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/locks"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// Locks are kept as a trusted xattr of the locked entry, so they move along
+// with it. New locks are created exclusively, so concurrent requests cannot
+// both acquire one; refreshing and removing a lock is serialized per process.
+const xattrLock = xattrTrustedNs + "lock"
+
+// readLock returns the lock of the entry at path, or a NotFound error if
+// there is none or it has expired.
+func (fs *cephfs) readLock(path string) (*provider.Lock, error) {
+	buf, err := fs.adminConn.adminMount.GetXattr(path, xattrLock)
+	if err != nil {
+		if err.Error() == errNoData {
+			return nil, errtypes.NotFound("no lock found")
+		}
+		return nil, getRevaError(err)
+	}
+	lock := &provider.Lock{}
+	if err := utils.UnmarshalJSONToProtoV1(buf, lock); err != nil {
+		return nil, errors.Wrap(err, "cephfs: error decoding lock of "+path)
+	}
+	if locks.IsExpired(lock) {
+		return nil, errtypes.NotFound("no lock found")
+	}
+	return lock, nil
+}
+
+func (fs *cephfs) writeLock(path string, lock *provider.Lock, flags cephfs2.XattrFlags) error {
+	buf, err := utils.MarshalProtoV1ToJSON(lock)
+	if err != nil {
+		return errors.Wrap(err, "cephfs: error encoding lock")
+	}
+	return fs.adminConn.adminMount.SetXattr(path, xattrLock, buf, flags)
+}
+
+// checkLock verifies that the lock id in the context permits modifying the entry at path.
+func (fs *cephfs) checkLock(ctx context.Context, path string) error {
+	lock, err := fs.readLock(path)
+	switch err.(type) {
+	case nil:
+		return locks.CheckWrite(ctx, lock)
+	case errtypes.NotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// checkReadLock verifies that the lock id in the context permits reading the entry at path.
+func (fs *cephfs) checkReadLock(ctx context.Context, path string) error {
+	lock, err := fs.readLock(path)
+	switch err.(type) {
+	case nil:
+		return locks.CheckRead(ctx, lock)
+	case errtypes.NotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// resolveLockable returns the path of an existing entry the user can access.
+func (fs *cephfs) resolveLockable(ctx context.Context, ref *provider.Reference) (user *User, path string, err error) {
+	user = fs.makeUser(ctx)
+	if path, err = user.resolveRef(ref); err != nil {
+		return
+	}
+	user.op(func(cv *cacheVal) {
+		_, err = cv.mount.Statx(path, cephfs2.StatxMode, cephfs2.AtSymlinkNofollow)
+	})
+	return user, path, getRevaError(err)
+}
+
+// SetLock puts a lock on the given reference.
+func (fs *cephfs) SetLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+	_, path, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	fs.locksMu.Lock()
+	defer fs.locksMu.Unlock()
+
+	flags := cephfs2.XattrCreate
+	old, err := fs.readLock(path)
+	switch err.(type) {
+	case nil:
+		return errtypes.Locked(old.LockId)
+	case errtypes.NotFound:
+		if _, e := fs.adminConn.adminMount.GetXattr(path, xattrLock); e == nil {
+			// the existing lock has expired
+			flags = cephfs2.XattrReplace
+		}
+	default:
+		return err
+	}
+
+	if err := fs.writeLock(path, lock, flags); err != nil {
+		if err.Error() == errFileExists {
+			// another process was faster
+			if old, e := fs.readLock(path); e == nil {
+				return errtypes.Locked(old.LockId)
+			}
+		}
+		return getRevaError(err)
+	}
+	return nil
+}
+
+// GetLock returns an existing lock on the given reference.
+func (fs *cephfs) GetLock(ctx context.Context, ref *provider.Reference) (*provider.Lock, error) {
+	_, path, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return fs.readLock(path)
+}
+
+// RefreshLock refreshes an existing lock on the given reference.
+func (fs *cephfs) RefreshLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock, existingLockID string) error {
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+	_, path, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	fs.locksMu.Lock()
+	defer fs.locksMu.Unlock()
+
+	old, err := fs.readLock(path)
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return errtypes.BadRequest("file was not locked")
+	default:
+		return err
+	}
+	if err := locks.CheckRefresh(old, lock, existingLockID); err != nil {
+		return err
+	}
+	return getRevaError(fs.writeLock(path, lock, cephfs2.XattrReplace))
+}
+
+// Unlock removes an existing lock from the given reference.
+func (fs *cephfs) Unlock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
+	_, path, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	fs.locksMu.Lock()
+	defer fs.locksMu.Unlock()
+
+	old, err := fs.readLock(path)
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return errtypes.BadRequest("file was not locked")
+	default:
+		return err
+	}
+	if err := locks.CheckUnlock(old, lock); err != nil {
+		return err
+	}
+	if err := fs.adminConn.adminMount.RemoveXattr(path, xattrLock); err != nil && err.Error() != errNoData {
+		return getRevaError(err)
+	}
+	return nil
+}
@@ -38,8 +38,16 @@ type Options struct {
 	ShadowFolder string `mapstructure:"shadow_folder"`
 	ShareFolder  string `mapstructure:"share_folder"`
 	UploadFolder string `mapstructure:"uploads"`
+	IndexFolder  string `mapstructure:"index_folder"`
+	TrashFolder  string `mapstructure:"trash_folder"`
 	UserLayout   string `mapstructure:"user_layout"`
 
+	// Reindex gives every entry without an entry id its inode number as id
+	// when the driver starts, see (*cephfs).indexTree. Entries are indexed
+	// anyway when their id is first handed out, reindexing only makes the
+	// ids handed out before ids were stored resolvable right away.
+	Reindex bool `mapstructure:"reindex"`
+
 	DisableHome    bool   `mapstructure:"disable_home"`
 	DirPerms       uint32 `mapstructure:"dir_perms"`
 	FilePerms      uint32 `mapstructure:"file_perms"`
@@ -93,6 +101,16 @@ func (c *Options) fillDefaults() {
 	}
 	c.UploadFolder = filepath.Join(c.ShadowFolder, c.UploadFolder)
 
+	if c.IndexFolder == "" {
+		c.IndexFolder = ".ids"
+	}
+	c.IndexFolder = filepath.Join(c.ShadowFolder, c.IndexFolder)
+
+	// the trash lives in the home of every user, see (*User).trashPath
+	if c.TrashFolder == "" {
+		c.TrashFolder = ".trash"
+	}
+
 	if c.UserLayout == "" {
 		c.UserLayout = "{{.Username}}"
 	}
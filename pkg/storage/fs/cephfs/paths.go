@@ -0,0 +1,73 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package cephfs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+// The helpers in this file don't depend on libcephfs, so that they are
+// built and tested without the ceph build tag.
+
+// trashItemPath returns the location of the trash item with the given key and
+// relative path in the trash bin, making sure it cannot point outside of the item.
+func trashItemPath(trash, key, relativePath string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsRune(key, filepath.Separator) {
+		return "", errtypes.BadRequest("cephfs: invalid key of the recycle item " + key)
+	}
+	for _, segment := range strings.Split(relativePath, string(filepath.Separator)) {
+		if segment == ".." {
+			return "", errtypes.BadRequest("cephfs: invalid path of the recycle item " + relativePath)
+		}
+	}
+
+	root := filepath.Join(trash, key)
+	p := filepath.Join(root, relativePath)
+	if p != root && !strings.HasPrefix(p, root+string(filepath.Separator)) {
+		return "", errtypes.BadRequest("cephfs: invalid path of the recycle item " + relativePath)
+	}
+	return p, nil
+}
+
+// isTrashItemRoot tells whether the relative path points to the root of a trash item.
+func isTrashItemRoot(relativePath string) bool {
+	return relativePath == "" || relativePath == string(filepath.Separator)
+}
+
+// inodeEID returns the entry id of an entry that has not been given one yet.
+// It is the inode number, which was the id of every entry before ids were
+// stored, so the ids handed out before keep working once the entry is indexed.
+func inodeEID(inode uint64) string {
+	return strconv.FormatUint(inode, 10)
+}
+
+// splitIndexTarget splits the target of an index entry, which is in the form
+// of "parentID/entryname".
+func splitIndexTarget(target string) (parent, name string, err error) {
+	ss := strings.SplitN(target, string(filepath.Separator), 2)
+	if len(ss) != 2 || ss[0] == "" || ss[1] == "" {
+		return "", "", fmt.Errorf("cephfs: entry id is not in the form of \"parentID/entryname\"")
+	}
+	return ss[0], ss[1], nil
+}
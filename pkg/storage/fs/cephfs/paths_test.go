@@ -0,0 +1,77 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package cephfs
+
+import (
+	"testing"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+func TestTrashItemPath(t *testing.T) {
+	trash := "/home/einstein/.reva_hidden/.trash/einstein"
+	tests := []struct {
+		key, relativePath string
+		expected          string
+		valid             bool
+	}{
+		{"key", "", trash + "/key", true},
+		{"key", "/", trash + "/key", true},
+		{"key", "dir/file.txt", trash + "/key/dir/file.txt", true},
+		{"key", "/dir/./file.txt", trash + "/key/dir/file.txt", true},
+		{"", "", "", false},
+		{".", "", "", false},
+		{"..", "", "", false},
+		{"../other", "", "", false},
+		{"key/dir", "", "", false},
+		{"key", "..", "", false},
+		{"key", "dir/../../other", "", false},
+		{"key", "../../../../etc", "", false},
+	}
+	for _, tt := range tests {
+		p, err := trashItemPath(trash, tt.key, tt.relativePath)
+		if !tt.valid {
+			if _, ok := err.(errtypes.IsBadRequest); !ok {
+				t.Errorf("expected %q %q to be rejected, got %q %v", tt.key, tt.relativePath, p, err)
+			}
+			continue
+		}
+		if err != nil || p != tt.expected {
+			t.Errorf("expected %q %q to resolve to %q, got %q %v", tt.key, tt.relativePath, tt.expected, p, err)
+		}
+	}
+}
+
+func TestInodeEID(t *testing.T) {
+	if eid := inodeEID(1099511627776); eid != "1099511627776" {
+		t.Errorf("expected the inode number, got %s", eid)
+	}
+}
+
+func TestSplitIndexTarget(t *testing.T) {
+	parent, name, err := splitIndexTarget("1099511627776/file.txt")
+	if err != nil || parent != "1099511627776" || name != "file.txt" {
+		t.Errorf("unexpected split %q %q %v", parent, name, err)
+	}
+	for _, target := range []string{"", "1099511627776", "/file.txt", "1099511627776/"} {
+		if _, _, err := splitIndexTarget(target); err == nil {
+			t.Errorf("expected %q to be rejected", target)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+//go:build ceph
+// +build ceph
+
+package cephfs
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	cephfs2 "github.com/ceph/go-ceph/cephfs"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/google/uuid"
+)
+
+const (
+	xattrTrashOrigin = xattrTrustedNs + "trash.origin"
+	xattrTrashTime   = xattrTrustedNs + "trash.time"
+)
+
+// moveToTrash moves the entry at path to the recycle bin of the user.
+// The origin and the deletion time are kept as trusted xattrs of the trashed entry,
+// the index entry is dropped so that the id can't be resolved until the entry is restored.
+func (fs *cephfs) moveToTrash(user *User, cv *cacheVal, path string) (err error) {
+	var eid string
+	if eid, err = fs.getEID(path); err != nil {
+		return
+	}
+
+	trash := user.trashPath()
+	err = walkPath(trash, func(p string) error {
+		return cv.mount.MakeDir(p, fs.conf.DirPerms)
+	}, false)
+	if err != nil {
+		return
+	}
+
+	dst := filepath.Join(trash, uuid.New().String())
+	if err = cv.mount.Rename(path, dst); err != nil {
+		return
+	}
+
+	mt := fs.adminConn.adminMount
+	if err = mt.SetXattr(dst, xattrTrashOrigin, []byte(path), cephfs2.XattrDefault); err != nil {
+		return
+	}
+	if err = mt.SetXattr(dst, xattrTrashTime, []byte(strconv.FormatInt(time.Now().Unix(), 10)), cephfs2.XattrDefault); err != nil {
+		return
+	}
+	// a trashed entry can't be locked anymore
+	_ = mt.RemoveXattr(dst, xattrLock)
+
+	fs.removeIndex(eid)
+	return
+}
+
+// readTrashInfo returns the origin and the deletion time of a trashed entry.
+func (fs *cephfs) readTrashInfo(path string) (origin string, deletionTime uint64, err error) {
+	mt := fs.adminConn.adminMount
+	var buf []byte
+	if buf, err = mt.GetXattr(path, xattrTrashOrigin); err != nil {
+		return
+	}
+	origin = string(buf)
+	if buf, err = mt.GetXattr(path, xattrTrashTime); err != nil {
+		return
+	}
+	deletionTime, err = strconv.ParseUint(string(buf), 10, 64)
+	return
+}
+
+func (fs *cephfs) trashItem(cv *cacheVal, path, key, origin string, deletionTime uint64) (*provider.RecycleItem, error) {
+	stat, err := cv.mount.Statx(path, cephfs2.StatxBasicStats, cephfs2.AtSymlinkNofollow)
+	if err != nil {
+		return nil, err
+	}
+
+	item := &provider.RecycleItem{
+		Key:          key,
+		Ref:          &provider.Reference{Path: origin},
+		Size:         stat.Size,
+		DeletionTime: &typesv1beta1.Timestamp{Seconds: deletionTime},
+	}
+	switch int(stat.Mode) & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		item.Type = provider.ResourceType_RESOURCE_TYPE_CONTAINER
+		if buf, err := cv.mount.GetXattr(path, "ceph.dir.rbytes"); err == nil {
+			item.Size, _ = strconv.ParseUint(string(buf), 10, 64)
+		}
+	case syscall.S_IFLNK:
+		item.Type = provider.ResourceType_RESOURCE_TYPE_SYMLINK
+	default:
+		item.Type = provider.ResourceType_RESOURCE_TYPE_FILE
+	}
+
+	return item, nil
+}
+
+func readDirNames(mt Mount, path string) (names []string, err error) {
+	var dir *cephfs2.Directory
+	if dir, err = mt.OpenDir(path); err != nil {
+		return
+	}
+	defer closeDir(dir)
+
+	var entry *cephfs2.DirEntry
+	for entry, err = dir.ReadDir(); entry != nil && err == nil; entry, err = dir.ReadDir() {
+		if entry.Name() == "." || entry.Name() == ".." {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	return
+}
+
+// removeAll recursively deletes path, dropping the index entries of everything it removes.
+func (fs *cephfs) removeAll(mt Mount, path string) (err error) {
+	var stat Statx
+	if stat, err = mt.Statx(path, cephfs2.StatxMode, cephfs2.AtSymlinkNofollow); err != nil {
+		return
+	}
+
+	if buf, e := fs.adminConn.adminMount.GetXattr(path, xattrEID); e == nil {
+		fs.removeIndex(string(buf))
+	}
+
+	if int(stat.Mode)&syscall.S_IFMT != syscall.S_IFDIR {
+		return mt.Unlink(path)
+	}
+
+	var names []string
+	if names, err = readDirNames(mt, path); err != nil {
+		return
+	}
+	for _, name := range names {
+		if err = fs.removeAll(mt, filepath.Join(path, name)); err != nil {
+			return
+		}
+	}
+
+	return mt.RemoveDir(path)
+}
+
+func (fs *cephfs) ListRecycle(ctx context.Context, basePath, key, relativePath string) (items []*provider.RecycleItem, err error) {
+	user := fs.makeUser(ctx)
+	trash := user.trashPath()
+
+	user.op(func(cv *cacheVal) {
+		if key == "" {
+			var keys []string
+			if keys, err = readDirNames(cv.mount, trash); err != nil {
+				if err.Error() == errNotFound {
+					// nothing has been deleted yet
+					err = nil
+				}
+				return
+			}
+			for _, k := range keys {
+				origin, deletionTime, e := fs.readTrashInfo(filepath.Join(trash, k))
+				if e != nil {
+					continue
+				}
+				item, e := fs.trashItem(cv, filepath.Join(trash, k), k, origin, deletionTime)
+				if e != nil {
+					continue
+				}
+				items = append(items, item)
+			}
+			return
+		}
+
+		var src string
+		if src, err = trashItemPath(trash, key, relativePath); err != nil {
+			return
+		}
+		var origin string
+		var deletionTime uint64
+		if origin, deletionTime, err = fs.readTrashInfo(filepath.Join(trash, key)); err != nil {
+			return
+		}
+
+		var item *provider.RecycleItem
+		if item, err = fs.trashItem(cv, src, filepath.Join(key, relativePath), filepath.Join(origin, relativePath), deletionTime); err != nil {
+			return
+		}
+		if !isDir(item.Type) {
+			// this is the case when we want to directly list a file in the trashbin
+			items = append(items, item)
+			return
+		}
+
+		var names []string
+		if names, err = readDirNames(cv.mount, src); err != nil {
+			return
+		}
+		for _, name := range names {
+			item, e := fs.trashItem(cv, filepath.Join(src, name), filepath.Join(key, relativePath, name), filepath.Join(origin, relativePath, name), deletionTime)
+			if e != nil {
+				continue
+			}
+			items = append(items, item)
+		}
+	})
+
+	if _, ok := err.(errtypes.IsBadRequest); ok {
+		return nil, err
+	}
+	return items, getRevaError(err)
+}
+
+func (fs *cephfs) RestoreRecycleItem(ctx context.Context, basePath, key, relativePath string, restoreRef *provider.Reference) (err error) {
+	user := fs.makeUser(ctx)
+	root := filepath.Join(user.trashPath(), key)
+	src, err := trashItemPath(user.trashPath(), key, relativePath)
+	if err != nil {
+		return err
+	}
+
+	var origin, dst string
+	if origin, _, err = fs.readTrashInfo(root); err != nil {
+		return getRevaError(err)
+	}
+	if restoreRef != nil && (restoreRef.GetPath() != "" || restoreRef.GetResourceId() != nil) {
+		if dst, err = user.resolveRef(restoreRef); err != nil {
+			return err
+		}
+	} else {
+		dst = filepath.Join(origin, relativePath)
+	}
+
+	user.op(func(cv *cacheVal) {
+		if _, err = cv.mount.Statx(dst, cephfs2.StatxMode, cephfs2.AtSymlinkNofollow); err == nil {
+			err = errtypes.AlreadyExists("cephfs: restore target " + dst + " already exists")
+			return
+		}
+
+		var eid string
+		if eid, err = fs.getEID(src); err != nil {
+			return
+		}
+		if err = cv.mount.Rename(src, dst); err != nil {
+			return
+		}
+		if isTrashItemRoot(relativePath) {
+			_ = fs.adminConn.adminMount.RemoveXattr(dst, xattrTrashOrigin)
+			_ = fs.adminConn.adminMount.RemoveXattr(dst, xattrTrashTime)
+		}
+		err = fs.indexEntry(dst, eid)
+	})
+
+	if _, ok := err.(errtypes.IsAlreadyExists); ok {
+		return err
+	}
+	return getRevaError(err)
+}
+
+func (fs *cephfs) PurgeRecycleItem(ctx context.Context, basePath, key, relativePath string) (err error) {
+	user := fs.makeUser(ctx)
+	var src string
+	if src, err = trashItemPath(user.trashPath(), key, relativePath); err != nil {
+		return err
+	}
+
+	user.op(func(cv *cacheVal) {
+		err = fs.removeAll(cv.mount, src)
+	})
+
+	return getRevaError(err)
+}
+
+func (fs *cephfs) EmptyRecycle(ctx context.Context) (err error) {
+	user := fs.makeUser(ctx)
+	trash := user.trashPath()
+
+	user.op(func(cv *cacheVal) {
+		var keys []string
+		if keys, err = readDirNames(cv.mount, trash); err != nil {
+			if err.Error() == errNotFound {
+				err = nil
+			}
+			return
+		}
+		for _, k := range keys {
+			if err = fs.removeAll(cv.mount, filepath.Join(trash, k)); err != nil {
+				return
+			}
+		}
+	})
+
+	return getRevaError(err)
+}
@@ -94,6 +94,9 @@ func (fs *cephfs) InitiateUpload(ctx context.Context, ref *provider.Reference, u
 	if err != nil {
 		return nil, errors.Wrap(err, "cephfs: error resolving reference")
 	}
+	if err = fs.checkLock(ctx, np); err != nil {
+		return nil, err
+	}
 
 	info := tusd.FileInfo{
 		MetaData: tusd.MetaData{
@@ -352,6 +355,10 @@ func (upload *fileUpload) FinishUpload(ctx context.Context) (err error) {
 	user := upload.fs.makeUser(upload.ctx)
 	log := appctx.GetLogger(ctx)
 
+	// an overwritten file keeps its entry id and its lock
+	eid, _ := upload.fs.adminConn.adminMount.GetXattr(np, xattrEID)
+	lock, _ := upload.fs.adminConn.adminMount.GetXattr(np, xattrLock)
+
 	user.op(func(cv *cacheVal) {
 		err = cv.mount.Rename(upload.binPath, np)
 	})
@@ -359,6 +366,20 @@ func (upload *fileUpload) FinishUpload(ctx context.Context) (err error) {
 		return errors.Wrap(err, upload.binPath)
 	}
 
+	if eid != nil {
+		err = upload.fs.adminConn.adminMount.SetXattr(np, xattrEID, eid, cephfs2.XattrDefault)
+	} else {
+		_, err = upload.fs.getEID(np)
+	}
+	if err != nil {
+		return errors.Wrap(err, "cephfs: error setting entry id of "+np)
+	}
+	if lock != nil {
+		if err = upload.fs.adminConn.adminMount.SetXattr(np, xattrLock, lock, cephfs2.XattrDefault); err != nil {
+			return errors.Wrap(err, "cephfs: error keeping the lock of "+np)
+		}
+	}
+
 	// only delete the upload if it was successfully written to the fs
 	user.op(func(cv *cacheVal) {
 		err = cv.mount.Unlink(upload.infoPath)
@@ -132,15 +132,20 @@ func (user *User) fileAsResourceInfo(cv *cacheVal, path string, stat *cephfs2.Ce
 	mx := make(map[string]string)
 	if xattrs, err = cv.mount.ListXattr(path); err == nil {
 		for _, xattr := range xattrs {
-			if len(mdKeys) == 0 || keys[xattr] {
+			// only the user namespace is exposed as arbitrary metadata
+			if !strings.HasPrefix(xattr, xattrUserNs) {
+				continue
+			}
+			key := strings.TrimPrefix(xattr, xattrUserNs)
+			if len(mdKeys) == 0 || keys[key] {
 				if buf, err := cv.mount.GetXattr(path, xattr); err == nil {
-					mx[xattr] = string(buf)
+					mx[key] = string(buf)
 				}
 			}
 		}
 	}
 
-	//TODO(tmourati): Add entry id logic here
+	eid := user.fs.readEID(path, stat)
 
 	var etag string
 	if isDir(_type) {
@@ -157,12 +162,6 @@ func (user *User) fileAsResourceInfo(cv *cacheVal, path string, stat *cephfs2.Ce
 
 	perms := getPermissionSet(user, stat, cv.mount, path)
 
-	for key := range mx {
-		if !strings.HasPrefix(key, xattrUserNs) {
-			delete(mx, key)
-		}
-	}
-
 	var checksum provider.ResourceChecksum
 	var md5 string
 	if _type == provider.ResourceType_RESOURCE_TYPE_FILE {
@@ -215,7 +214,7 @@ func (user *User) fileAsResourceInfo(cv *cacheVal, path string, stat *cephfs2.Ce
 
 	ri = &provider.ResourceInfo{
 		Type:              _type,
-		Id:                &provider.ResourceId{OpaqueId: fmt.Sprint(stat.Inode)},
+		Id:                &provider.ResourceId{OpaqueId: eid},
 		Checksum:          &checksum,
 		Etag:              etag,
 		MimeType:          mime.Detect(isDir(_type), path),
@@ -236,8 +235,23 @@ func (user *User) resolveRef(ref *provider.Reference) (str string, err error) {
 		return "", fmt.Errorf("cephfs: nil reference")
 	}
 
+	if id := ref.GetResourceId(); id != nil && id.OpaqueId != "" {
+		if str, err = user.fs.resolveEID(string(filepath.Separator), id.OpaqueId); err != nil {
+			if err.Error() == errNotFound {
+				return "", errtypes.NotFound("cephfs: entry id " + id.OpaqueId)
+			}
+			return "", err
+		}
+		return filepath.Join(str, ref.GetPath()), nil
+	}
+
 	if str = ref.GetPath(); str == "" {
-		return "", errtypes.NotSupported("cephfs: entry IDs not currently supported")
+		return "", fmt.Errorf("cephfs: empty reference %+v", ref)
 	}
 	return
 }
+
+// trashPath returns the location of the recycle bin of the user.
+func (user *User) trashPath() string {
+	return filepath.Join(user.home, user.fs.conf.ShadowFolder, user.fs.conf.TrashFolder, user.Id.OpaqueId)
+}
@@ -29,6 +29,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"syscall"
 
 	cephfs2 "github.com/ceph/go-ceph/cephfs"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
@@ -74,33 +75,134 @@ func isDir(t provider.ResourceType) bool {
 	return t == provider.ResourceType_RESOURCE_TYPE_CONTAINER
 }
 
-// TODO: Use when fileids are available
-/*
-func (fs *cephfs) makeFIDPath(fid string) string {
-	return "" // filepath.Join(fs.conf.EIDFolder, fid) EIDFolder does not exist
+// maxIndexDepth guards the resolution of entry ids against loops in the index
+const maxIndexDepth = 4096
+
+func (fs *cephfs) makeIndexPath(eid string) string {
+	return filepath.Join(fs.conf.IndexFolder, eid)
 }
 
-func (fs *cephfs) makeFID(absolutePath string, inode string) (rid *provider.ResourceId, err error) {
-	sum := md5.New()
-	sum.Write([]byte(absolutePath))
-	fid := fmt.Sprintf("%s-%s", hex.EncodeToString(sum.Sum(nil)), inode)
-	rid = &provider.ResourceId{OpaqueId: fid}
+// readEID returns the entry id of path. Entries written before ids were stored
+// get their inode number as id and are indexed when their id is first handed
+// out, so that it can be resolved again. The inode number is only reported
+// without indexing the entry if that fails.
+func (fs *cephfs) readEID(path string, stat Statx) string {
+	if buf, err := fs.adminConn.adminMount.GetXattr(path, xattrEID); err == nil {
+		return string(buf)
+	}
+	if eid, err := fs.getEID(path); err == nil {
+		return eid
+	}
+	return inodeEID(uint64(stat.Inode))
+}
 
-	_ = fs.adminConn.adminMount.Link(absolutePath, fs.makeFIDPath(fid))
-	_ = fs.adminConn.adminMount.SetXattr(absolutePath, xattrEID, []byte(fid), 0)
+// getEID returns the entry id of path, storing and indexing it if the entry
+// doesn't have it yet.
+func (fs *cephfs) getEID(path string) (eid string, err error) {
+	mt := fs.adminConn.adminMount
+	var buf []byte
+	if buf, err = mt.GetXattr(path, xattrEID); err == nil {
+		return string(buf), nil
+	}
+	if err.Error() != errNoData {
+		return
+	}
 
-	return
+	var stat Statx
+	if stat, err = mt.Statx(path, cephfs2.StatxIno, cephfs2.AtSymlinkNofollow); err != nil {
+		return
+	}
+	eid = inodeEID(uint64(stat.Inode))
+	if err = mt.SetXattr(path, xattrEID, []byte(eid), cephfs2.XattrCreate); err != nil {
+		if err.Error() != errFileExists {
+			return "", err
+		}
+		// a concurrent request was faster
+		if buf, err = mt.GetXattr(path, xattrEID); err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+
+	return eid, fs.indexEntry(path, eid)
 }
 
-func (fs *cephfs) getFIDPath(cv *cacheVal, path string) (fid string, err error) {
-	var buffer []byte
-	if buffer, err = cv.mount.GetXattr(path, xattrEID); err != nil {
-		return
+// indexEntry stores the location of an entry in the index as a symlink
+// pointing to "parentID/entryname", the root of the mount points to "/".
+func (fs *cephfs) indexEntry(path string, eid string) (err error) {
+	target := string(filepath.Separator)
+	if path = addLeadingSlash(path); path != target {
+		var parent string
+		if parent, err = fs.getEID(filepath.Dir(path)); err != nil {
+			return
+		}
+		target = filepath.Join(parent, filepath.Base(path))
+	}
+
+	link := fs.makeIndexPath(eid)
+	_ = fs.adminConn.adminMount.Unlink(link)
+	return fs.adminConn.adminMount.Symlink(target, link)
+}
+
+// indexTree gives an entry id to every entry below path that has none yet.
+// This migrates the entries written before ids were stored up front: their
+// id becomes their inode number, which is the id they were reported with before.
+func (fs *cephfs) indexTree(path string) error {
+	mt := fs.adminConn.adminMount
+	if _, err := fs.getEID(path); err != nil {
+		return err
+	}
+
+	stat, err := mt.Statx(path, cephfs2.StatxMode, cephfs2.AtSymlinkNofollow)
+	if err != nil {
+		return err
+	}
+	if int(stat.Mode)&syscall.S_IFMT != syscall.S_IFDIR {
+		return nil
+	}
+
+	names, err := readDirNames(mt, path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		// the trash bins and the index live in the hidden dirs
+		if name == snap || fs.conf.HiddenDirs[name] {
+			continue
+		}
+		if err := fs.indexTree(filepath.Join(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *cephfs) removeIndex(eid string) {
+	_ = fs.adminConn.adminMount.Unlink(fs.makeIndexPath(eid))
+}
+
+// resolveEID walks up the index from the given entry id to the root of the mount.
+// The index is looked up below base, which allows resolving ids in snapshots.
+func (fs *cephfs) resolveEID(base, eid string) (fullPath string, err error) {
+	root := string(filepath.Separator)
+	for i := 0; i < maxIndexDepth; i++ {
+		var target string
+		if target, err = fs.adminConn.adminMount.Readlink(filepath.Join(base, fs.makeIndexPath(eid))); err != nil {
+			return
+		}
+		if target == root {
+			return addLeadingSlash(fullPath), nil
+		}
+
+		var name string
+		if eid, name, err = splitIndexTarget(target); err != nil {
+			return
+		}
+		fullPath = filepath.Join(name, fullPath)
 	}
 
-	return fs.makeFIDPath(string(buffer)), err
+	return "", fmt.Errorf("cephfs: entry id %s exceeds the maximum depth", eid)
 }
-*/
 
 func calcChecksum(filepath string, mt Mount, stat Statx) (checksum string, err error) {
 	file, err := mt.Open(filepath, os.O_RDONLY, 0)
@@ -120,27 +222,19 @@ func calcChecksum(filepath string, mt Mount, stat Statx) (checksum string, err e
 	return
 }
 
-func resolveRevRef(mt Mount, ref *provider.Reference, revKey string) (str string, err error) {
-	var buf []byte
-	if ref.GetResourceId() != nil {
-		str, err = mt.Readlink(filepath.Join(snap, revKey, ref.ResourceId.OpaqueId))
-		if err != nil {
-			return "", fmt.Errorf("cephfs: invalid reference %+v", ref)
-		}
-	} else if str = ref.GetPath(); str != "" {
-		buf, err = mt.GetXattr(str, xattrEID)
-		if err != nil {
-			return
-		}
-		str, err = mt.Readlink(filepath.Join(snap, revKey, string(buf)))
-		if err != nil {
-			return
+// resolveRevRef returns the location of the entry at path in the given snapshot.
+// The entry is looked up by its id in the index of the snapshot, so that it is
+// found even if it has been moved since. Entries that weren't indexed yet when
+// the snapshot was taken are looked up by their current path.
+func (fs *cephfs) resolveRevRef(path string, revKey string) string {
+	base := filepath.Join(string(filepath.Separator), snap, revKey)
+	if buf, err := fs.adminConn.adminMount.GetXattr(path, xattrEID); err == nil {
+		if p, err := fs.resolveEID(base, string(buf)); err == nil {
+			return filepath.Join(base, p)
 		}
-	} else {
-		return "", fmt.Errorf("cephfs: empty reference %+v", ref)
 	}
 
-	return filepath.Join(snap, revKey, str), err
+	return filepath.Join(base, path)
 }
 
 func removeLeadingSlash(path string) string {
@@ -196,55 +290,3 @@ func walkPath(path string, f func(string) error, reverse bool) (err error) {
 
 	return
 }
-
-// TODO: Use when fileids are available
-/*
-func (fs *cephfs) writeIndex(oid string, value string) (err error) {
-	return fs.adminConn.radosIO.WriteFull(oid, []byte(value))
-}
-
-func (fs *cephfs) removeIndex(oid string) error {
-	return fs.adminConn.radosIO.Delete(oid)
-}
-
-func (fs *cephfs) resolveIndex(oid string) (fullPath string, err error) {
-	var i int
-	var currPath strings.Builder
-	root := string(filepath.Separator)
-	offset := uint64(0)
-	io := fs.adminConn.radosIO
-	bsize := 4096
-	buffer := make([]byte, bsize)
-	for {
-		for { //read object
-			i, err = io.Read(oid, buffer, offset)
-			offset += uint64(bsize)
-			currPath.Write(buffer)
-			if err == nil && i >= bsize {
-				buffer = buffer[:0]
-				continue
-			} else {
-				offset = 0
-				break
-			}
-		}
-		if err != nil {
-			return
-		}
-
-		ss := strings.SplitN(currPath.String(), string(filepath.Separator), 2)
-		if len(ss) != 2 {
-			if currPath.String() == root {
-				return
-			}
-
-			return "", fmt.Errorf("cephfs: entry id is not in the form of \"parentID/entryname\"")
-		}
-		parentOID := ss[0]
-		entryName := ss[1]
-		fullPath = filepath.Join(entryName, fullPath)
-		oid = parentOID
-		currPath.Reset()
-	}
-}
-*/
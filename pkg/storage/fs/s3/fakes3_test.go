@@ -0,0 +1,349 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeS3 is a minimal in-process stand-in for an S3 server, implementing
+// just the operations used by the driver on a single bucket.
+type fakeS3 struct {
+	sync.Mutex
+	bucket    string
+	versioned bool
+	objects   map[string][]*fakeVersion // oldest version first
+	nextID    int
+	requests  map[string]int // by method
+}
+
+type fakeVersion struct {
+	id           string
+	data         []byte
+	etag         string
+	mtime        time.Time
+	deleteMarker bool
+}
+
+func newFakeS3(bucket string, versioned bool) *fakeS3 {
+	return &fakeS3{bucket: bucket, versioned: versioned, objects: map[string][]*fakeVersion{}, requests: map[string]int{}}
+}
+
+type fakeContent struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type fakeCommonPrefix struct {
+	Prefix string
+}
+
+type fakeListResult struct {
+	XMLName               xml.Name `xml:"ListBucketResult"`
+	Name                  string
+	Prefix                string
+	IsTruncated           bool
+	NextContinuationToken string             `xml:",omitempty"`
+	NextMarker            string             `xml:",omitempty"`
+	Contents              []fakeContent      `xml:"Contents"`
+	CommonPrefixes        []fakeCommonPrefix `xml:"CommonPrefixes"`
+}
+
+type fakeVersionEntry struct {
+	Key          string
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+type fakeVersionsResult struct {
+	XMLName      xml.Name `xml:"ListVersionsResult"`
+	Name         string
+	Prefix       string
+	IsTruncated  bool
+	Versions     []fakeVersionEntry `xml:"Version"`
+	DeleteMarker []fakeVersionEntry `xml:"DeleteMarker"`
+}
+
+type fakeDeleteRequest struct {
+	Objects []struct {
+		Key string
+	} `xml:"Object"`
+}
+
+type fakeDeleteResult struct {
+	XMLName xml.Name `xml:"DeleteResult"`
+	Deleted []struct {
+		Key string
+	} `xml:"Deleted"`
+}
+
+type fakeCopyResult struct {
+	XMLName      xml.Name `xml:"CopyObjectResult"`
+	ETag         string
+	LastModified string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests[r.Method]++
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/"+f.bucket), "/")
+	q := r.URL.Query()
+
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		if _, ok := q["versions"]; ok {
+			f.listVersions(w, q)
+			return
+		}
+		f.list(w, q)
+	case key == "" && r.Method == http.MethodPost:
+		if _, ok := q["delete"]; ok {
+			f.deleteObjects(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNotImplemented)
+	case r.Method == http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			f.copy(w, key, src)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		v := f.put(key, data)
+		w.Header().Set("ETag", v.etag)
+		w.Header().Set("x-amz-version-id", v.id)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		v := f.get(key, q.Get("versionId"))
+		if v == nil {
+			f.notFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", v.etag)
+		w.Header().Set("Last-Modified", v.mtime.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(v.data)))
+		w.Header().Set("x-amz-version-id", v.id)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(v.data)
+		}
+	case r.Method == http.MethodDelete:
+		f.delete(key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) notFound(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+	}
+}
+
+func (f *fakeS3) put(key string, data []byte) *fakeVersion {
+	sum := md5.Sum(data)
+	v := &fakeVersion{
+		id:    "null",
+		data:  data,
+		etag:  `"` + hex.EncodeToString(sum[:]) + `"`,
+		mtime: time.Now().UTC(),
+	}
+	if f.versioned {
+		f.nextID++
+		v.id = "v" + strconv.Itoa(f.nextID)
+		f.objects[key] = append(f.objects[key], v)
+	} else {
+		f.objects[key] = []*fakeVersion{v}
+	}
+	return v
+}
+
+func (f *fakeS3) get(key, versionID string) *fakeVersion {
+	versions := f.objects[key]
+	if len(versions) == 0 {
+		return nil
+	}
+	if versionID == "" {
+		if v := versions[len(versions)-1]; !v.deleteMarker {
+			return v
+		}
+		return nil
+	}
+	for _, v := range versions {
+		if v.id == versionID && !v.deleteMarker {
+			return v
+		}
+	}
+	return nil
+}
+
+func (f *fakeS3) delete(key string) {
+	if !f.versioned {
+		delete(f.objects, key)
+		return
+	}
+	if f.get(key, "") != nil {
+		f.nextID++
+		f.objects[key] = append(f.objects[key], &fakeVersion{id: "v" + strconv.Itoa(f.nextID), mtime: time.Now().UTC(), deleteMarker: true})
+	}
+}
+
+func (f *fakeS3) copy(w http.ResponseWriter, key, src string) {
+	src, versionID := src, ""
+	if i := strings.Index(src, "?versionId="); i >= 0 {
+		versionID, _ = url.QueryUnescape(src[i+len("?versionId="):])
+		src = src[:i]
+	}
+	src, _ = url.PathUnescape(src)
+	src = strings.TrimPrefix(strings.TrimPrefix(src, "/"), f.bucket+"/")
+	v := f.get(src, versionID)
+	if v == nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>")
+		return
+	}
+	n := f.put(key, v.data)
+	b, _ := xml.Marshal(fakeCopyResult{ETag: n.etag, LastModified: n.mtime.Format(time.RFC3339)})
+	_, _ = w.Write(b)
+}
+
+func (f *fakeS3) deleteObjects(w http.ResponseWriter, r *http.Request) {
+	req := fakeDeleteRequest{}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	res := fakeDeleteResult{}
+	for _, o := range req.Objects {
+		f.delete(o.Key)
+		res.Deleted = append(res.Deleted, struct{ Key string }{o.Key})
+	}
+	b, _ := xml.Marshal(res)
+	_, _ = w.Write(b)
+}
+
+// keys returns the sorted keys of all objects that are not deleted.
+func (f *fakeS3) keys(prefix string) []string {
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && f.get(k, "") != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// list serves ListObjects and ListObjectsV2 requests.
+func (f *fakeS3) list(w http.ResponseWriter, q url.Values) {
+	prefix, delimiter := q.Get("prefix"), q.Get("delimiter")
+	after := q.Get("continuation-token") + q.Get("marker")
+	maxKeys := 1000
+	if m, err := strconv.Atoi(q.Get("max-keys")); err == nil {
+		maxKeys = m
+	}
+
+	res := fakeListResult{Name: f.bucket, Prefix: prefix}
+	seen := map[string]bool{}
+	count, last := 0, ""
+	for _, k := range f.keys(prefix) {
+		if k <= after {
+			continue
+		}
+		if count == maxKeys {
+			res.IsTruncated = true
+			if q.Get("list-type") == "2" {
+				res.NextContinuationToken = last
+			} else {
+				res.NextMarker = last
+			}
+			break
+		}
+		last = k
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				cp := k[:len(prefix)+i+len(delimiter)]
+				if !seen[cp] {
+					seen[cp] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, fakeCommonPrefix{Prefix: cp})
+					count++
+				}
+				continue
+			}
+		}
+		v := f.get(k, "")
+		res.Contents = append(res.Contents, fakeContent{Key: k, LastModified: v.mtime.Format(time.RFC3339), ETag: v.etag, Size: len(v.data)})
+		count++
+	}
+	b, _ := xml.Marshal(res)
+	_, _ = w.Write(b)
+}
+
+func (f *fakeS3) listVersions(w http.ResponseWriter, q url.Values) {
+	prefix := q.Get("prefix")
+	res := fakeVersionsResult{Name: f.bucket, Prefix: prefix}
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		versions := f.objects[k]
+		// newest version first
+		for i := len(versions) - 1; i >= 0; i-- {
+			v := versions[i]
+			e := fakeVersionEntry{Key: k, VersionID: v.id, IsLatest: i == len(versions)-1, LastModified: v.mtime.Format(time.RFC3339), ETag: v.etag, Size: len(v.data)}
+			if v.deleteMarker {
+				res.DeleteMarker = append(res.DeleteMarker, e)
+			} else {
+				res.Versions = append(res.Versions, e)
+			}
+		}
+	}
+	b, _ := xml.Marshal(res)
+	_, _ = w.Write(b)
+}
+
+// countRequests returns the number of requests served by method and resets the counters.
+func (f *fakeS3) countRequests() map[string]int {
+	f.Lock()
+	defer f.Unlock()
+	requests := f.requests
+	f.requests = map[string]int{}
+	return requests
+}
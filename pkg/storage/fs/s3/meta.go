@@ -0,0 +1,444 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/ace"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// S3 neither allows changing the metadata of an object in place nor has
+// objects for implicit directories, so everything reva knows about a resource
+// is kept in a sidecar record in another hidden prefix:
+//
+//	.meta/nodes/<path>    json record with the id, grants and arbitrary metadata
+//	.meta/ids/<id>        the path of the resource, to look it up by id
+//
+// Records are only written when a resource is created or changed: a resource
+// without a record has an id derived from its path, which is the id earlier
+// versions of the driver handed out, so lookups never write and concurrent
+// lookups agree on the id. Records travel along when the resource is moved,
+// so ids survive renames. Records of trashed resources are kept in
+// .trash/<user>/meta/<key> until they are restored.
+const metaDir = ".meta"
+
+// recordWorkers is the number of records read in parallel when listing a folder.
+const recordWorkers = 8
+
+type nodeRecord struct {
+	ID       string            `json:"id"`
+	Owner    *userpb.UserId    `json:"owner,omitempty"`
+	Grants   map[string][]byte `json:"grants,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// derivedID returns the id of the resource at path p as long as it has no record.
+func derivedID(p string) string {
+	return "fileid-" + strings.TrimPrefix(path.Join("/", p), "/")
+}
+
+func (fs *s3FS) nodesRoot() string {
+	return fs.addRoot("/" + metaDir + "/nodes")
+}
+
+func (fs *s3FS) idKey(id string) string {
+	return fs.addRoot(path.Join("/", metaDir, "ids", id))
+}
+
+func trashMetaRoot(root, key string) string {
+	return path.Join(root, "meta", key)
+}
+
+func (fs *s3FS) getObject(ctx context.Context, key string) ([]byte, error) {
+	output, err := fs.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errtypes.NotFound(key)
+		}
+		return nil, errors.Wrap(err, "s3fs: error reading "+key)
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+func (fs *s3FS) putObject(ctx context.Context, key string, b []byte) error {
+	_, err := fs.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(b),
+	})
+	if err != nil {
+		return errors.Wrap(err, "s3fs: error writing "+key)
+	}
+	return nil
+}
+
+func (fs *s3FS) readRecord(ctx context.Context, key string) (*nodeRecord, error) {
+	b, err := fs.getObject(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	r := &nodeRecord{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, errors.Wrap(err, "s3fs: error decoding record "+key)
+	}
+	return r, nil
+}
+
+// writeRecord stores the record of the resource at path p. Records in the
+// nodes root are indexed by their id.
+func (fs *s3FS) writeRecord(ctx context.Context, root, p string, r *nodeRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := fs.putObject(ctx, path.Join(root, p), b); err != nil {
+		return err
+	}
+	if root == fs.nodesRoot() {
+		return fs.putObject(ctx, fs.idKey(r.ID), []byte(p))
+	}
+	return nil
+}
+
+// getChildRecords returns the records of the children of the folder at path
+// p by their name. It lists the records once and only reads the ones that
+// exist, in parallel.
+func (fs *s3FS) getChildRecords(ctx context.Context, p string) (map[string]*nodeRecord, error) {
+	prefix := path.Join(fs.nodesRoot(), p) + "/"
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.config.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+	keys := []string{}
+	isTruncated := true
+	for isTruncated {
+		output, err := fs.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "s3fs: error listing records of "+p)
+		}
+		for _, o := range output.Contents {
+			keys = append(keys, *o.Key)
+		}
+		input.ContinuationToken = output.NextContinuationToken
+		isTruncated = *output.IsTruncated
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		records = make(map[string]*nodeRecord, len(keys))
+		errs    []error
+		work    = make(chan string)
+	)
+	for i := 0; i < recordWorkers && i < len(keys); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range work {
+				r, err := fs.readRecord(ctx, k)
+				mu.Lock()
+				if err == nil {
+					records[path.Base(k)] = r
+				} else if _, ok := err.(errtypes.IsNotFound); !ok {
+					errs = append(errs, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, k := range keys {
+		work <- k
+	}
+	close(work)
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return records, nil
+}
+
+// ensureRecord stores a record for the resource at path p if it has none yet.
+// Resources that already existed keep their derived id, new ones get a new id
+// and the user in the context as their owner.
+func (fs *s3FS) ensureRecord(ctx context.Context, p string, existed bool) error {
+	fs.recordsMu.Lock()
+	defer fs.recordsMu.Unlock()
+	_, err := fs.readRecord(ctx, path.Join(fs.nodesRoot(), p))
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(errtypes.IsNotFound); !ok {
+		return err
+	}
+	r := &nodeRecord{ID: derivedID(p)}
+	if !existed {
+		r.ID = uuid.New().String()
+		if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+			r.Owner = u.Id
+		}
+	}
+	return fs.writeRecord(ctx, fs.nodesRoot(), p, r)
+}
+
+// recordOf returns the path and the record of the resource referenced by ref,
+// making sure the user in the context has the permission checked by check.
+func (fs *s3FS) recordOf(ctx context.Context, ref *provider.Reference, check func(*provider.ResourcePermissions) bool) (string, *nodeRecord, error) {
+	fn, err := fs.resolve(ctx, ref)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error resolving ref")
+	}
+	if _, err := fs.isDir(ctx, fn); err != nil {
+		return "", nil, err
+	}
+	p := fs.removeRoot(fn)
+	r, acc, err := fs.lookup(ctx, p)
+	if err != nil {
+		return "", nil, err
+	}
+	if !check(acc.result(fs.permissionSet(ctx))) {
+		return "", nil, errtypes.PermissionDenied(p)
+	}
+	return p, r, nil
+}
+
+// updateRecord applies f to the record of the resource referenced by ref.
+func (fs *s3FS) updateRecord(ctx context.Context, ref *provider.Reference, check func(*provider.ResourcePermissions) bool, f func(*nodeRecord) error) error {
+	fs.recordsMu.Lock()
+	defer fs.recordsMu.Unlock()
+	p, r, err := fs.recordOf(ctx, ref, check)
+	if err != nil {
+		return err
+	}
+	if err := f(r); err != nil {
+		return err
+	}
+	return fs.writeRecord(ctx, fs.nodesRoot(), p, r)
+}
+
+// moveRecords moves the records of src and its descendants below srcRoot to
+// dst below dstRoot, keeping the id index in sync. The moved resources
+// that have no record yet, given by their path relative to src, get one
+// with the id derived from their old path, so their ids survive the move.
+func (fs *s3FS) moveRecords(ctx context.Context, srcRoot, src, dstRoot, dst string, moved []string) error {
+	log := appctx.GetLogger(ctx)
+	srcKey := path.Join(srcRoot, src)
+	keys := []string{srcKey}
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(srcKey + "/"),
+	}
+	isTruncated := true
+	for isTruncated {
+		output, err := fs.client.ListObjectsV2(input)
+		if err != nil {
+			return errors.Wrap(err, "s3fs: error listing "+srcKey)
+		}
+		for _, o := range output.Contents {
+			keys = append(keys, *o.Key)
+		}
+		input.ContinuationToken = output.NextContinuationToken
+		isTruncated = *output.IsTruncated
+	}
+
+	recorded := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		r, err := fs.readRecord(ctx, k)
+		if err != nil {
+			if _, ok := err.(errtypes.IsNotFound); ok {
+				continue
+			}
+			return err
+		}
+		recorded[path.Join("/", strings.TrimPrefix(k, srcKey))] = true
+		p := path.Join(dst, strings.TrimPrefix(k, srcKey))
+		if err := fs.writeRecord(ctx, dstRoot, p, r); err != nil {
+			return err
+		}
+		if srcRoot == fs.nodesRoot() && dstRoot != fs.nodesRoot() {
+			if err := fs.deleteObject(ctx, fs.idKey(r.ID)); err != nil {
+				log.Error().Err(err).Str("id", r.ID).Msg("could not remove id from index")
+			}
+		}
+		if err := fs.deleteObject(ctx, k); err != nil {
+			return err
+		}
+	}
+
+	if srcRoot != fs.nodesRoot() {
+		return nil
+	}
+	for _, rel := range moved {
+		if recorded[path.Join("/", rel)] {
+			continue
+		}
+		r := &nodeRecord{ID: derivedID(path.Join(src, rel))}
+		if err := fs.writeRecord(ctx, dstRoot, path.Join(dst, rel), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movedPaths returns the paths relative to src of the resources moved along
+// with the objects with the given keys, including the implicit directories.
+func movedPaths(src string, keys []string) []string {
+	seen := map[string]bool{"/": true}
+	paths := []string{"/"}
+	for _, k := range keys {
+		for p := path.Join("/", strings.TrimPrefix(k, src)); p != "/"; p = path.Dir(p) {
+			if seen[p] {
+				break
+			}
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// decorate sets the id, the owner, the permissions and the arbitrary metadata
+// of the resource info from its record.
+func decorate(md *provider.ResourceInfo, r *nodeRecord, perms *provider.ResourcePermissions, mdKeys []string) {
+	md.Id = &provider.ResourceId{OpaqueId: r.ID}
+	md.Owner = r.Owner
+	md.PermissionSet = perms
+
+	mdKeysMap := make(map[string]struct{})
+	for _, k := range mdKeys {
+		mdKeysMap[k] = struct{}{}
+	}
+	var returnAllKeys bool
+	if _, ok := mdKeysMap["*"]; len(mdKeys) == 0 || ok {
+		returnAllKeys = true
+	}
+	metadata := map[string]string{}
+	for k, v := range r.Metadata {
+		if _, ok := mdKeysMap[k]; returnAllKeys || ok {
+			metadata[k] = v
+		}
+	}
+	md.ArbitraryMetadata = &provider.ArbitraryMetadata{Metadata: metadata}
+}
+
+// pathByID looks up the path of the resource with the given id.
+func (fs *s3FS) pathByID(ctx context.Context, id string) (string, error) {
+	b, err := fs.getObject(ctx, fs.idKey(id))
+	if err == nil {
+		return string(b), nil
+	}
+	if _, ok := err.(errtypes.IsNotFound); !ok {
+		return "", err
+	}
+	// resources without a record have an id derived from their path
+	if strings.HasPrefix(id, "fileid-") {
+		return path.Join("/", strings.TrimPrefix(id, "fileid-")), nil
+	}
+	return "", errtypes.NotFound(id)
+}
+
+func (fs *s3FS) AddGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
+	return fs.updateRecord(ctx, ref, func(rp *provider.ResourcePermissions) bool { return rp.AddGrant }, func(r *nodeRecord) error {
+		principal, value := ace.FromGrant(g).Marshal()
+		if r.Grants == nil {
+			r.Grants = map[string][]byte{}
+		}
+		r.Grants[principal] = value
+		return nil
+	})
+}
+
+func (fs *s3FS) DenyGrant(ctx context.Context, ref *provider.Reference, g *provider.Grantee) error {
+	return errtypes.NotSupported("s3: operation not supported")
+}
+
+func (fs *s3FS) ListGrants(ctx context.Context, ref *provider.Reference) ([]*provider.Grant, error) {
+	log := appctx.GetLogger(ctx)
+	_, r, err := fs.recordOf(ctx, ref, func(rp *provider.ResourcePermissions) bool { return rp.ListGrants })
+	if err != nil {
+		return nil, err
+	}
+	grants := make([]*provider.Grant, 0, len(r.Grants))
+	for principal, value := range r.Grants {
+		e, err := ace.Unmarshal(principal, value)
+		if err != nil {
+			log.Error().Err(err).Str("principal", principal).Msg("could not unmarshal ace")
+			continue
+		}
+		grants = append(grants, e.Grant())
+	}
+	return grants, nil
+}
+
+func (fs *s3FS) RemoveGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
+	return fs.updateRecord(ctx, ref, func(rp *provider.ResourcePermissions) bool { return rp.RemoveGrant }, func(r *nodeRecord) error {
+		principal, _ := ace.FromGrant(g).Marshal()
+		if _, ok := r.Grants[principal]; !ok {
+			return errtypes.NotFound(principal)
+		}
+		delete(r.Grants, principal)
+		return nil
+	})
+}
+
+func (fs *s3FS) UpdateGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
+	// TODO remove AddGrant or UpdateGrant grant from CS3 api, redundant? tracked in https://github.com/cs3org/cs3apis/issues/92
+	return fs.AddGrant(ctx, ref, g)
+}
+
+func (fs *s3FS) SetArbitraryMetadata(ctx context.Context, ref *provider.Reference, md *provider.ArbitraryMetadata) error {
+	return fs.updateRecord(ctx, ref, func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileUpload }, func(r *nodeRecord) error {
+		if r.Metadata == nil {
+			r.Metadata = map[string]string{}
+		}
+		for k, v := range md.GetMetadata() {
+			r.Metadata[k] = v
+		}
+		return nil
+	})
+}
+
+func (fs *s3FS) UnsetArbitraryMetadata(ctx context.Context, ref *provider.Reference, keys []string) error {
+	return fs.updateRecord(ctx, ref, func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileUpload }, func(r *nodeRecord) error {
+		for _, k := range keys {
+			delete(r.Metadata, k)
+		}
+		return nil
+	})
+}
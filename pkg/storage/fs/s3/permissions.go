@@ -0,0 +1,190 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"context"
+	"path"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/ace"
+	"github.com/cs3org/reva/pkg/utils"
+)
+
+// Permissions are computed from the records of a resource and its ancestors:
+// the owner of any of them has all permissions, everybody else gets the union
+// of the grants given to them or to their groups.
+//
+// Trees nobody owns, like the objects written before the records carried an
+// owner, belong to the user configured as owner and everybody else can only
+// read them. To hand such a tree to another user, either configure them as
+// owner or let the configured owner grant them permissions on it. Moving the
+// tree, e.g. to the home of its new owner, does not change its ownership.
+
+// readPermissions are the permissions everybody has on trees nobody owns.
+var readPermissions = &provider.ResourcePermissions{
+	GetPath:              true,
+	GetQuota:             true,
+	InitiateFileDownload: true,
+	ListContainer:        true,
+	ListFileVersions:     true,
+	ListGrants:           true,
+	ListRecycle:          true,
+	Stat:                 true,
+}
+
+// permissionsAcc accumulates the permissions of a user along a path.
+type permissionsAcc struct {
+	user  *userpb.User
+	owned bool
+	owner bool
+	// ownsUnowned is set if the user is the configured owner of the trees nobody owns
+	ownsUnowned bool
+	perms       *provider.ResourcePermissions
+}
+
+func (a *permissionsAcc) add(ctx context.Context, r *nodeRecord) {
+	if r.Owner != nil {
+		a.owned = true
+		if a.user != nil && utils.UserEqual(r.Owner, a.user.Id) {
+			a.owner = true
+		}
+	}
+	if a.owner || a.user == nil || a.user.Id == nil {
+		return
+	}
+	principals := make([]string, 0, len(a.user.Groups)+1)
+	principals = append(principals, "u:"+a.user.Id.OpaqueId)
+	for _, g := range a.user.Groups {
+		principals = append(principals, "g:"+g)
+	}
+	for _, principal := range principals {
+		value, ok := r.Grants[principal]
+		if !ok {
+			continue
+		}
+		e, err := ace.Unmarshal(principal, value)
+		if err != nil {
+			appctx.GetLogger(ctx).Error().Err(err).Str("principal", principal).Msg("could not unmarshal ace")
+			continue
+		}
+		addPermissions(a.perms, e.Grant().Permissions)
+	}
+}
+
+// result returns the accumulated permissions, or the given owner permissions
+// if the user owns the resource.
+func (a *permissionsAcc) result(ownerPerms *provider.ResourcePermissions) *provider.ResourcePermissions {
+	switch {
+	case a.owner, !a.owned && a.ownsUnowned:
+		return ownerPerms
+	case !a.owned:
+		perms := &provider.ResourcePermissions{}
+		addPermissions(perms, readPermissions)
+		addPermissions(perms, a.perms)
+		return perms
+	}
+	return a.perms
+}
+
+// child returns an accumulator for a child of the resource, seeded with the
+// permissions accumulated so far.
+func (a *permissionsAcc) child() *permissionsAcc {
+	perms := &provider.ResourcePermissions{}
+	addPermissions(perms, a.perms)
+	return &permissionsAcc{user: a.user, owned: a.owned, owner: a.owner, ownsUnowned: a.ownsUnowned, perms: perms}
+}
+
+// ownsUnowned reports whether u is the configured owner of the resources without an owner record.
+func (fs *s3FS) ownsUnowned(u *userpb.User) bool {
+	return fs.config.Owner != "" && utils.UserEqual(u.GetId(), &userpb.UserId{Idp: fs.config.OwnerIdp, OpaqueId: fs.config.Owner})
+}
+
+// lookup returns the record of the resource at path p, with the derived id if
+// it has none, and the permissions accumulated from the records of the
+// resource and its ancestors.
+func (fs *s3FS) lookup(ctx context.Context, p string) (*nodeRecord, *permissionsAcc, error) {
+	u, _ := ctxpkg.ContextGetUser(ctx)
+	acc := &permissionsAcc{user: u, ownsUnowned: fs.ownsUnowned(u), perms: &provider.ResourcePermissions{}}
+	p = path.Join("/", p)
+	own := &nodeRecord{ID: derivedID(p)}
+	for cur := p; ; cur = path.Dir(cur) {
+		r, err := fs.readRecord(ctx, path.Join(fs.nodesRoot(), cur))
+		switch err.(type) {
+		case nil:
+			if cur == p {
+				own = r
+			}
+			acc.add(ctx, r)
+		case errtypes.IsNotFound:
+		default:
+			return nil, nil, err
+		}
+		if acc.owner || cur == "/" {
+			return own, acc, nil
+		}
+	}
+}
+
+// permissions returns the permissions of the user in the context on the resource at path p.
+func (fs *s3FS) permissions(ctx context.Context, p string) (*provider.ResourcePermissions, error) {
+	_, acc, err := fs.lookup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return acc.result(fs.permissionSet(ctx)), nil
+}
+
+// checkPermission makes sure the user in the context passes check on the resource at path p.
+func (fs *s3FS) checkPermission(ctx context.Context, p string, check func(*provider.ResourcePermissions) bool) error {
+	perms, err := fs.permissions(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !check(perms) {
+		return errtypes.PermissionDenied(p)
+	}
+	return nil
+}
+
+// addPermissions merges a set of permissions into another.
+func addPermissions(l *provider.ResourcePermissions, r *provider.ResourcePermissions) {
+	l.AddGrant = l.AddGrant || r.AddGrant
+	l.CreateContainer = l.CreateContainer || r.CreateContainer
+	l.Delete = l.Delete || r.Delete
+	l.GetPath = l.GetPath || r.GetPath
+	l.GetQuota = l.GetQuota || r.GetQuota
+	l.InitiateFileDownload = l.InitiateFileDownload || r.InitiateFileDownload
+	l.InitiateFileUpload = l.InitiateFileUpload || r.InitiateFileUpload
+	l.ListContainer = l.ListContainer || r.ListContainer
+	l.ListFileVersions = l.ListFileVersions || r.ListFileVersions
+	l.ListGrants = l.ListGrants || r.ListGrants
+	l.ListRecycle = l.ListRecycle || r.ListRecycle
+	l.Move = l.Move || r.Move
+	l.PurgeRecycle = l.PurgeRecycle || r.PurgeRecycle
+	l.RemoveGrant = l.RemoveGrant || r.RemoveGrant
+	l.RestoreFileVersion = l.RestoreFileVersion || r.RestoreFileVersion
+	l.RestoreRecycleItem = l.RestoreRecycleItem || r.RestoreRecycleItem
+	l.Stat = l.Stat || r.Stat
+	l.UpdateGrant = l.UpdateGrant || r.UpdateGrant
+}
@@ -0,0 +1,450 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// The trash bin lives in a hidden prefix next to the user data, with one
+// bin per user:
+//
+//	.trash/<user>/info/<key>     json document describing the deleted resource
+//	.trash/<user>/files/<key>    the deleted file, or
+//	.trash/<user>/files/<key>/   the objects of the deleted directory
+//	.trash/<user>/meta/<key>     the metadata records of the deleted resources, see meta.go
+//
+// Only the root of a deleted resource gets an info document, the
+// relative path of its children is resolved against that one.
+const trashDir = ".trash"
+
+type trashInfo struct {
+	Path         string                `json:"path"`
+	Type         provider.ResourceType `json:"type"`
+	Size         uint64                `json:"size"`
+	DeletionTime int64                 `json:"deletion_time"`
+}
+
+// trashRoot returns the prefix of the trash bin of the user in the context.
+func (fs *s3FS) trashRoot(ctx context.Context) (string, error) {
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok || u.Id == nil || u.Id.OpaqueId == "" {
+		return "", errtypes.UserRequired("s3fs: error getting user from ctx")
+	}
+	return fs.addRoot(path.Join("/", trashDir, url.PathEscape(u.Id.OpaqueId))), nil
+}
+
+func trashInfoKey(root, key string) string {
+	return path.Join(root, "info", key)
+}
+
+func trashFilesKey(root, key, relativePath string) string {
+	return path.Join(root, "files", key, relativePath)
+}
+
+// checkTrashItem makes sure the key and the relative path of a trash item
+// cannot point outside of the item in the trash bin.
+func checkTrashItem(key, relativePath string) error {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, "/\\") {
+		return errtypes.BadRequest("s3fs: invalid trash key " + key)
+	}
+	for _, segment := range strings.Split(relativePath, "/") {
+		if segment == ".." {
+			return errtypes.BadRequest("s3fs: invalid trash path " + relativePath)
+		}
+	}
+	if p := path.Join("/", key, relativePath); p != "/"+key && !strings.HasPrefix(p, "/"+key+"/") {
+		return errtypes.BadRequest("s3fs: invalid trash path " + relativePath)
+	}
+	return nil
+}
+
+func (fs *s3FS) moveToTrash(ctx context.Context, fn string, isDir bool) error {
+	root, err := fs.trashRoot(ctx)
+	if err != nil {
+		return err
+	}
+	key := uuid.New().String()
+	info := &trashInfo{
+		Path:         fs.removeRoot(fn),
+		Type:         getResourceType(isDir),
+		DeletionTime: time.Now().Unix(),
+	}
+
+	dst := trashFilesKey(root, key, "")
+	var moved []string
+	if isDir {
+		keys, size, err := fs.movePrefix(ctx, fn, dst)
+		if err != nil {
+			return err
+		}
+		info.Size = size
+		moved = movedPaths(fn, keys)
+	} else {
+		head, err := fs.client.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(fs.config.Bucket),
+			Key:    aws.String(fn),
+		})
+		if err != nil {
+			return errors.Wrap(err, "s3fs: error getting "+fn)
+		}
+		info.Size = uint64(aws.Int64Value(head.ContentLength))
+		if err := fs.moveObject(ctx, fn, dst); err != nil {
+			return err
+		}
+		moved = []string{"/"}
+	}
+
+	if err := fs.moveRecords(ctx, fs.nodesRoot(), info.Path, trashMetaRoot(root, key), "/", moved); err != nil {
+		return err
+	}
+	return fs.writeTrashInfo(ctx, root, key, info)
+}
+
+// movePrefix moves all objects below the src directory to the dst directory and
+// returns their keys below src and their accumulated size.
+func (fs *s3FS) movePrefix(ctx context.Context, src, dst string) ([]string, uint64, error) {
+	var (
+		keys []string
+		size uint64
+	)
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(src + "/"),
+	}
+	isTruncated := true
+
+	for isTruncated {
+		output, err := fs.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "s3fs: error listing "+src)
+		}
+
+		for _, o := range output.Contents {
+			if err := fs.moveObject(ctx, *o.Key, dst+strings.TrimPrefix(*o.Key, src)); err != nil {
+				return nil, 0, err
+			}
+			keys = append(keys, *o.Key)
+			size += uint64(aws.Int64Value(o.Size))
+		}
+
+		input.ContinuationToken = output.NextContinuationToken
+		isTruncated = *output.IsTruncated
+	}
+	return keys, size, nil
+}
+
+func (fs *s3FS) writeTrashInfo(ctx context.Context, root, key string, info *trashInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	_, err = fs.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(fs.config.Bucket),
+		Key:         aws.String(trashInfoKey(root, key)),
+		ContentType: aws.String("application/json"),
+		Body:        bytes.NewReader(b),
+	})
+	if err != nil {
+		return errors.Wrap(err, "s3fs: error writing trash info of "+info.Path)
+	}
+	return nil
+}
+
+func (fs *s3FS) readTrashInfo(ctx context.Context, root, key string) (*trashInfo, error) {
+	output, err := fs.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Key:    aws.String(trashInfoKey(root, key)),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errtypes.NotFound(key)
+		}
+		return nil, errors.Wrap(err, "s3fs: error reading trash info "+key)
+	}
+	defer output.Body.Close()
+
+	b, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, err
+	}
+	info := &trashInfo{}
+	if err := json.Unmarshal(b, info); err != nil {
+		return nil, errors.Wrap(err, "s3fs: error decoding trash info "+key)
+	}
+	return info, nil
+}
+
+func (fs *s3FS) deletePrefix(ctx context.Context, prefix string) error {
+	iter := s3manager.NewDeleteListIterator(fs.client, &s3.ListObjectsInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	batcher := s3manager.NewBatchDeleteWithClient(fs.client)
+	if err := batcher.Delete(aws.BackgroundContext(), iter); err != nil {
+		return errors.Wrap(err, "s3fs: error deleting "+prefix)
+	}
+	return nil
+}
+
+func (fs *s3FS) deleteObject(ctx context.Context, key string) error {
+	_, err := fs.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && !isNotFound(err) {
+		return errors.Wrap(err, "s3fs: error deleting "+key)
+	}
+	return nil
+}
+
+func isTrashItemRoot(relativePath string) bool {
+	return relativePath == "" || relativePath == "/"
+}
+
+func (fs *s3FS) ListRecycle(ctx context.Context, basePath, key, relativePath string) ([]*provider.RecycleItem, error) {
+	root, err := fs.trashRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return fs.listTrashRoot(ctx, root)
+	}
+	if err := checkTrashItem(key, relativePath); err != nil {
+		return nil, err
+	}
+
+	info, err := fs.readTrashInfo(ctx, root, key)
+	if err != nil {
+		return nil, err
+	}
+	src := trashFilesKey(root, key, relativePath)
+	isDir, err := fs.isDir(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	if !isDir {
+		// this is the case when we want to directly list a file in the trashbin
+		head, err := fs.client.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(fs.config.Bucket),
+			Key:    aws.String(src),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "s3fs: error getting "+src)
+		}
+		return []*provider.RecycleItem{{
+			Type:         provider.ResourceType_RESOURCE_TYPE_FILE,
+			Key:          path.Join(key, relativePath),
+			Ref:          &provider.Reference{Path: path.Join(info.Path, relativePath)},
+			Size:         uint64(aws.Int64Value(head.ContentLength)),
+			DeletionTime: &types.Timestamp{Seconds: uint64(info.DeletionTime)},
+		}}, nil
+	}
+
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(fs.config.Bucket),
+		Prefix:    aws.String(src + "/"),
+		Delimiter: aws.String("/"),
+	}
+	isTruncated := true
+
+	items := []*provider.RecycleItem{}
+	newItem := func(name string, isDir bool, size int64) *provider.RecycleItem {
+		return &provider.RecycleItem{
+			Type:         getResourceType(isDir),
+			Key:          path.Join(key, relativePath, name),
+			Ref:          &provider.Reference{Path: path.Join(info.Path, relativePath, name)},
+			Size:         uint64(size),
+			DeletionTime: &types.Timestamp{Seconds: uint64(info.DeletionTime)},
+		}
+	}
+	for isTruncated {
+		output, err := fs.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "s3fs: error listing "+src)
+		}
+		for _, p := range output.CommonPrefixes {
+			items = append(items, newItem(path.Base(*p.Prefix), true, 0))
+		}
+		for _, o := range output.Contents {
+			if *o.Key == src+"/" {
+				// skip the marker object of the directory itself
+				continue
+			}
+			items = append(items, newItem(path.Base(*o.Key), false, aws.Int64Value(o.Size)))
+		}
+		input.ContinuationToken = output.NextContinuationToken
+		isTruncated = *output.IsTruncated
+	}
+	return items, nil
+}
+
+func (fs *s3FS) listTrashRoot(ctx context.Context, root string) ([]*provider.RecycleItem, error) {
+	log := appctx.GetLogger(ctx)
+	prefix := trashInfoKey(root, "") + "/"
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(prefix),
+	}
+	isTruncated := true
+
+	items := []*provider.RecycleItem{}
+	for isTruncated {
+		output, err := fs.client.ListObjectsV2(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "s3fs: error listing trash")
+		}
+		for _, o := range output.Contents {
+			key := strings.TrimPrefix(*o.Key, prefix)
+			info, err := fs.readTrashInfo(ctx, root, key)
+			if err != nil {
+				log.Error().Err(err).Str("key", key).Msg("could not read trash info, skipping")
+				continue
+			}
+			items = append(items, &provider.RecycleItem{
+				Type:         info.Type,
+				Key:          key,
+				Ref:          &provider.Reference{Path: info.Path},
+				Size:         info.Size,
+				DeletionTime: &types.Timestamp{Seconds: uint64(info.DeletionTime)},
+			})
+		}
+		input.ContinuationToken = output.NextContinuationToken
+		isTruncated = *output.IsTruncated
+	}
+	return items, nil
+}
+
+func (fs *s3FS) RestoreRecycleItem(ctx context.Context, basePath, key, relativePath string, restoreRef *provider.Reference) error {
+	root, err := fs.trashRoot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkTrashItem(key, relativePath); err != nil {
+		return err
+	}
+	info, err := fs.readTrashInfo(ctx, root, key)
+	if err != nil {
+		return err
+	}
+
+	var dst string
+	if restoreRef != nil && restoreRef.Path != "" {
+		if dst, err = fs.resolve(ctx, restoreRef); err != nil {
+			return errors.Wrap(err, "error resolving ref")
+		}
+	} else {
+		dst = fs.addRoot(path.Join(info.Path, relativePath))
+	}
+	if _, err := fs.isDir(ctx, dst); err == nil {
+		return errtypes.AlreadyExists(fs.removeRoot(dst))
+	} else if _, ok := err.(errtypes.IsNotFound); !ok {
+		return err
+	}
+	if err := fs.checkPermission(ctx, path.Dir(fs.removeRoot(dst)), func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileUpload }); err != nil {
+		return err
+	}
+
+	src := trashFilesKey(root, key, relativePath)
+	isDir, err := fs.isDir(ctx, src)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		_, _, err = fs.movePrefix(ctx, src, dst)
+	} else {
+		err = fs.moveObject(ctx, src, dst)
+	}
+	if err != nil {
+		return err
+	}
+	if err := fs.moveRecords(ctx, trashMetaRoot(root, key), relativePath, fs.nodesRoot(), fs.removeRoot(dst), nil); err != nil {
+		return err
+	}
+
+	if isTrashItemRoot(relativePath) {
+		return fs.deleteObject(ctx, trashInfoKey(root, key))
+	}
+	return nil
+}
+
+func (fs *s3FS) PurgeRecycleItem(ctx context.Context, basePath, key, relativePath string) error {
+	root, err := fs.trashRoot(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkTrashItem(key, relativePath); err != nil {
+		return err
+	}
+	if _, err := fs.readTrashInfo(ctx, root, key); err != nil {
+		return err
+	}
+
+	src := trashFilesKey(root, key, relativePath)
+	isDir, err := fs.isDir(ctx, src)
+	if err != nil {
+		return err
+	}
+	if isDir {
+		err = fs.deletePrefix(ctx, src+"/")
+	} else {
+		err = fs.deleteObject(ctx, src)
+	}
+	if err != nil {
+		return err
+	}
+	records := path.Join(trashMetaRoot(root, key), relativePath)
+	if err := fs.deletePrefix(ctx, records+"/"); err != nil {
+		return err
+	}
+	if err := fs.deleteObject(ctx, records); err != nil {
+		return err
+	}
+
+	if isTrashItemRoot(relativePath) {
+		return fs.deleteObject(ctx, trashInfoKey(root, key))
+	}
+	return nil
+}
+
+func (fs *s3FS) EmptyRecycle(ctx context.Context) error {
+	root, err := fs.trashRoot(ctx)
+	if err != nil {
+		return err
+	}
+	return fs.deletePrefix(ctx, root+"/")
+}
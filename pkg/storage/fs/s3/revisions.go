@@ -0,0 +1,124 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/pkg/errors"
+)
+
+// Revisions are the noncurrent versions S3 keeps of an object. They only
+// exist when versioning has been enabled on the bucket, otherwise the
+// list of revisions is always empty.
+
+func (fs *s3FS) ListRevisions(ctx context.Context, ref *provider.Reference) ([]*provider.FileVersion, error) {
+	fn, err := fs.resolve(ctx, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving ref")
+	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.ListFileVersions }); err != nil {
+		return nil, err
+	}
+
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(fn),
+	}
+	revisions := []*provider.FileVersion{}
+	isTruncated := true
+
+	for isTruncated {
+		output, err := fs.client.ListObjectVersions(input)
+		if err != nil {
+			return nil, errors.Wrap(err, "s3fs: error listing versions of "+fn)
+		}
+
+		for _, v := range output.Versions {
+			// the prefix also matches siblings starting with the same name
+			if *v.Key != fn || aws.BoolValue(v.IsLatest) {
+				continue
+			}
+			revisions = append(revisions, &provider.FileVersion{
+				Key:   aws.StringValue(v.VersionId),
+				Size:  uint64(aws.Int64Value(v.Size)),
+				Mtime: uint64(v.LastModified.Unix()),
+				Etag:  aws.StringValue(v.ETag),
+			})
+		}
+
+		input.KeyMarker = output.NextKeyMarker
+		input.VersionIdMarker = output.NextVersionIdMarker
+		isTruncated = aws.BoolValue(output.IsTruncated)
+	}
+	return revisions, nil
+}
+
+func (fs *s3FS) DownloadRevision(ctx context.Context, ref *provider.Reference, revisionKey string) (io.ReadCloser, error) {
+	fn, err := fs.resolve(ctx, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "error resolving ref")
+	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileDownload }); err != nil {
+		return nil, err
+	}
+
+	r, err := fs.client.GetObject(&s3.GetObjectInput{
+		Bucket:    aws.String(fs.config.Bucket),
+		Key:       aws.String(fn),
+		VersionId: aws.String(revisionKey),
+	})
+	if err != nil {
+		if isNotFound(err) || isNoSuchVersion(err) {
+			return nil, errtypes.NotFound(fn + "@" + revisionKey)
+		}
+		return nil, errors.Wrap(err, "s3fs: error downloading revision "+revisionKey+" of "+fn)
+	}
+	return r.Body, nil
+}
+
+// RestoreRevision copies the revision over the current object. With versioning
+// enabled the current content thereby becomes a revision itself.
+func (fs *s3FS) RestoreRevision(ctx context.Context, ref *provider.Reference, revisionKey string) error {
+	fn, err := fs.resolve(ctx, ref)
+	if err != nil {
+		return errors.Wrap(err, "error resolving ref")
+	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.RestoreFileVersion }); err != nil {
+		return err
+	}
+
+	_, err = fs.client.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(fs.config.Bucket),
+		CopySource: aws.String(fs.copySource(fn, revisionKey)),
+		Key:        aws.String(fn),
+	})
+	if err != nil {
+		if isNotFound(err) || isNoSuchVersion(err) {
+			return errtypes.NotFound(fn + "@" + revisionKey)
+		}
+		return errors.Wrap(err, "s3fs: error restoring revision "+revisionKey+" of "+fn)
+	}
+	return nil
+}
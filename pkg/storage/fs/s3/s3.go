@@ -26,6 +26,7 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -56,6 +57,11 @@ type config struct {
 	Endpoint  string `mapstructure:"endpoint"`
 	Bucket    string `mapstructure:"bucket"`
 	Prefix    string `mapstructure:"prefix"`
+	// Owner and OwnerIdp identify the user owning the resources without an owner
+	// record, like the objects written by earlier versions of the driver.
+	// Everybody else can only read them.
+	Owner    string `mapstructure:"owner"`
+	OwnerIdp string `mapstructure:"owner_idp"`
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -109,20 +115,34 @@ func (fs *s3FS) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// addRoot returns the object key for the given path. This is the same key
+// the driver always used: the S3 client cleans the request path, so an
+// object written as "/<prefix>/<path>" was stored as "<prefix>/<path>".
+// The leading slash is trimmed here so listings, which send the key as a
+// query parameter that is not cleaned, look for the same keys.
 func (fs *s3FS) addRoot(p string) string {
 	np := path.Join(fs.config.Prefix, p)
-	return np
+	return strings.TrimPrefix(np, "/")
 }
 
 func (fs *s3FS) resolve(ctx context.Context, ref *provider.Reference) (string, error) {
 	if strings.HasPrefix(ref.Path, "/") {
+		if isHiddenPath(ref.Path) {
+			return "", errtypes.NotFound(ref.Path)
+		}
 		return fs.addRoot(ref.GetPath()), nil
 	}
 
 	if ref.ResourceId != nil && ref.ResourceId.OpaqueId != "" {
-		fn := path.Join("/", strings.TrimPrefix(ref.ResourceId.OpaqueId, "fileid-"))
-		fn = fs.addRoot(fn)
-		return fn, nil
+		fn, err := fs.pathByID(ctx, ref.ResourceId.OpaqueId)
+		if err != nil {
+			return "", err
+		}
+		fn = path.Join("/", fn, ref.Path)
+		if isHiddenPath(fn) {
+			return "", errtypes.NotFound(fn)
+		}
+		return fs.addRoot(fn), nil
 	}
 
 	// reference is invalid
@@ -130,16 +150,17 @@ func (fs *s3FS) resolve(ctx context.Context, ref *provider.Reference) (string, e
 }
 
 func (fs *s3FS) removeRoot(np string) string {
-	p := strings.TrimPrefix(np, fs.config.Prefix)
-	if p == "" {
-		p = "/"
-	}
-	return p
+	p := strings.TrimPrefix(strings.TrimPrefix(np, "/"), strings.TrimPrefix(fs.config.Prefix, "/"))
+	return path.Join("/", p)
 }
 
 type s3FS struct {
 	client *s3.S3
 	config *config
+	// recordsMu serializes the read-modify-write cycles on the records, S3
+	// has no conditional writes to detect concurrent updates. Records of a
+	// bucket served by several processes can still be updated concurrently.
+	recordsMu sync.Mutex
 }
 
 // permissionSet returns the permission set for the current user.
@@ -196,7 +217,7 @@ func getResourceType(isDir bool) provider.ResourceType {
 	if isDir {
 		return provider.ResourceType_RESOURCE_TYPE_CONTAINER
 	}
-	return provider.ResourceType_RESOURCE_TYPE_CONTAINER
+	return provider.ResourceType_RESOURCE_TYPE_FILE
 }
 
 func (fs *s3FS) normalizeHead(ctx context.Context, o *s3.HeadObjectOutput, fn string) *provider.ResourceInfo {
@@ -241,45 +262,27 @@ func (fs *s3FS) normalizeCommonPrefix(ctx context.Context, p *s3.CommonPrefix) *
 	return md
 }
 
-// GetPathByID returns the path pointed by the file id
-// In this implementation the file id is that path of the file without the first slash
-// thus the file id always points to the filename.
+// GetPathByID returns the path pointed by the file id, see meta.go.
 func (fs *s3FS) GetPathByID(ctx context.Context, id *provider.ResourceId) (string, error) {
-	return path.Join("/", strings.TrimPrefix(id.OpaqueId, "fileid-")), nil
-}
-
-func (fs *s3FS) AddGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
-	return errtypes.NotSupported("s3: operation not supported")
-}
-
-func (fs *s3FS) DenyGrant(ctx context.Context, ref *provider.Reference, g *provider.Grantee) error {
-	return errtypes.NotSupported("s3: operation not supported")
-}
-
-func (fs *s3FS) ListGrants(ctx context.Context, ref *provider.Reference) ([]*provider.Grant, error) {
-	return nil, errtypes.NotSupported("s3: operation not supported")
-}
-
-func (fs *s3FS) RemoveGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
-	return errtypes.NotSupported("s3: operation not supported")
+	return fs.pathByID(ctx, id.OpaqueId)
 }
 
-func (fs *s3FS) UpdateGrant(ctx context.Context, ref *provider.Reference, g *provider.Grant) error {
-	return errtypes.NotSupported("s3: operation not supported")
+// isHiddenPath tells whether the path points into the prefixes
+// used by the driver to keep the trash bin and the metadata records.
+func isHiddenPath(p string) bool {
+	p = path.Clean(p)
+	for _, dir := range []string{"/" + trashDir, "/" + metaDir} {
+		if p == dir || strings.HasPrefix(p, dir+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 func (fs *s3FS) GetQuota(ctx context.Context, ref *provider.Reference) (uint64, uint64, error) {
 	return 0, 0, nil
 }
 
-func (fs *s3FS) SetArbitraryMetadata(ctx context.Context, ref *provider.Reference, md *provider.ArbitraryMetadata) error {
-	return errtypes.NotSupported("s3: operation not supported")
-}
-
-func (fs *s3FS) UnsetArbitraryMetadata(ctx context.Context, ref *provider.Reference, keys []string) error {
-	return errtypes.NotSupported("s3: operation not supported")
-}
-
 // GetLock returns an existing lock on the given reference.
 func (fs *s3FS) GetLock(ctx context.Context, ref *provider.Reference) (*provider.Lock, error) {
 	return nil, errtypes.NotSupported("unimplemented")
@@ -320,12 +323,16 @@ func (fs *s3FS) CreateDir(ctx context.Context, ref *provider.Reference) error {
 	if err != nil {
 		return nil
 	}
-
-	fn = fs.addRoot(fn) + "/" // append / to indicate folder // TODO only if fn does not end in /
+	p := fs.removeRoot(fn)
+	if err := fs.checkPermission(ctx, path.Dir(p), func(rp *provider.ResourcePermissions) bool { return rp.CreateContainer }); err != nil {
+		return err
+	}
+	_, err = fs.isDir(ctx, fn)
+	existed := err == nil
 
 	input := &s3.PutObjectInput{
 		Bucket:        aws.String(fs.config.Bucket),
-		Key:           aws.String(fn),
+		Key:           aws.String(fn + "/"), // append / to indicate folder
 		ContentType:   aws.String("application/octet-stream"),
 		ContentLength: aws.Int64(0),
 	}
@@ -343,7 +350,7 @@ func (fs *s3FS) CreateDir(ctx context.Context, ref *provider.Reference) error {
 	}
 
 	log.Debug().Interface("result", result) // todo cache etag?
-	return nil
+	return fs.ensureRecord(ctx, p, existed)
 }
 
 // TouchFile as defined in the storage.FS interface.
@@ -351,61 +358,81 @@ func (fs *s3FS) TouchFile(ctx context.Context, ref *provider.Reference) error {
 	return fmt.Errorf("unimplemented: TouchFile")
 }
 
+// Delete moves the resource to the trash bin, see recycle.go.
 func (fs *s3FS) Delete(ctx context.Context, ref *provider.Reference) error {
-	log := appctx.GetLogger(ctx)
-
 	fn, err := fs.resolve(ctx, ref)
 	if err != nil {
 		return errors.Wrap(err, "error resolving ref")
 	}
+	if fn == fs.addRoot("/") {
+		return errtypes.PermissionDenied("s3fs: cannot delete the root")
+	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.Delete }); err != nil {
+		return err
+	}
 
-	// first we need to find out if fn is a dir or a file
+	isDir, err := fs.isDir(ctx, fn)
+	if err != nil {
+		return err
+	}
+	return fs.moveToTrash(ctx, fn, isDir)
+}
 
-	_, err = fs.client.HeadObject(&s3.HeadObjectInput{
+// isDir tells whether the key is a file or a directory.
+// It returns a NotFound error if there is neither.
+func (fs *s3FS) isDir(ctx context.Context, key string) (bool, error) {
+	_, err := fs.client.HeadObject(&s3.HeadObjectInput{
 		Bucket: aws.String(fs.config.Bucket),
-		Key:    aws.String(fn),
+		Key:    aws.String(key),
 	})
-	if err != nil {
-		log.Error().Err(err)
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchBucket:
-			case s3.ErrCodeNoSuchKey:
-				return errtypes.NotFound(fn)
-			}
-		}
-		// it might be a directory, so we can batch delete the prefix + /
-		iter := s3manager.NewDeleteListIterator(fs.client, &s3.ListObjectsInput{
-			Bucket: aws.String(fs.config.Bucket),
-			Prefix: aws.String(fn + "/"),
-		})
-		batcher := s3manager.NewBatchDeleteWithClient(fs.client)
-		if err := batcher.Delete(aws.BackgroundContext(), iter); err != nil {
-			return err
-		}
-		// ok, we are done
-		return nil
+	if err == nil {
+		return false, nil
+	}
+	if !isNotFound(err) {
+		return false, errors.Wrap(err, "s3fs: error getting "+key)
 	}
 
-	// we found an object, let's get rid of it
-	result, err := fs.client.DeleteObject(&s3.DeleteObjectInput{
-		Bucket: aws.String(fs.config.Bucket),
-		Key:    aws.String(fn),
+	output, err := fs.client.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(fs.config.Bucket),
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int64(1),
 	})
 	if err != nil {
-		log.Error().Err(err)
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchBucket:
-			case s3.ErrCodeNoSuchKey:
-				return errtypes.NotFound(fn)
-			}
+		return false, errors.Wrap(err, "s3fs: error listing "+key)
+	}
+	if len(output.Contents) == 0 {
+		return false, errtypes.NotFound(key)
+	}
+	return true, nil
+}
+
+// isNotFound tells whether the error is caused by a missing object. HEAD requests
+// carry no error body, so they report a plain "NotFound" code.
+func isNotFound(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case s3.ErrCodeNoSuchKey, "NotFound":
+			return true
 		}
-		return errors.Wrap(err, "s3fs: error deleting "+fn)
 	}
+	return false
+}
 
-	log.Debug().Interface("result", result)
-	return nil
+// isNoSuchVersion tells whether the error is caused by an unknown version id.
+func isNoSuchVersion(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == "NoSuchVersion"
+	}
+	return false
+}
+
+// copySource builds the CopySource of a CopyObjectInput, optionally pointing at a specific version.
+func (fs *s3FS) copySource(key, versionID string) string {
+	src := (&url.URL{Path: "/" + fs.config.Bucket + "/" + key}).EscapedPath()
+	if versionID != "" {
+		src += "?versionId=" + url.QueryEscape(versionID)
+	}
+	return src
 }
 
 // CreateStorageSpace creates a storage space.
@@ -419,7 +446,7 @@ func (fs *s3FS) moveObject(ctx context.Context, oldKey string, newKey string) er
 	// Docs say we need to use multipart upload: https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectCOPY.html
 	_, err := fs.client.CopyObject(&s3.CopyObjectInput{
 		Bucket:     aws.String(fs.config.Bucket),
-		CopySource: aws.String("/" + fs.config.Bucket + oldKey),
+		CopySource: aws.String(fs.copySource(oldKey, "")),
 		Key:        aws.String(newKey),
 	})
 	if aerr, ok := err.(awserr.Error); ok {
@@ -447,8 +474,6 @@ func (fs *s3FS) moveObject(ctx context.Context, oldKey string, newKey string) er
 }
 
 func (fs *s3FS) Move(ctx context.Context, oldRef, newRef *provider.Reference) error {
-	log := appctx.GetLogger(ctx)
-
 	fn, err := fs.resolve(ctx, oldRef)
 	if err != nil {
 		return errors.Wrap(err, "error resolving ref")
@@ -460,59 +485,35 @@ func (fs *s3FS) Move(ctx context.Context, oldRef, newRef *provider.Reference) er
 	}
 
 	// first we need to find out if fn is a dir or a file
-
-	_, err = fs.client.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(fs.config.Bucket),
-		Key:    aws.String(fn),
-	})
+	isDir, err := fs.isDir(ctx, fn)
 	if err != nil {
-		log.Error().Err(err)
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case s3.ErrCodeNoSuchBucket:
-			case s3.ErrCodeNoSuchKey:
-				return errtypes.NotFound(fn)
-			}
-		}
-
-		// move directory
-		input := &s3.ListObjectsV2Input{
-			Bucket: aws.String(fs.config.Bucket),
-			Prefix: aws.String(fn + "/"),
-		}
-		isTruncated := true
-
-		for isTruncated {
-			output, err := fs.client.ListObjectsV2(input)
-			if err != nil {
-				return errors.Wrap(err, "s3FS: error listing "+fn)
-			}
-
-			for _, o := range output.Contents {
-				log.Debug().
-					Interface("object", *o).
-					Str("fn", fn).
-					Msg("found Object")
-
-				err := fs.moveObject(ctx, *o.Key, strings.Replace(*o.Key, fn+"/", newName+"/", 1))
-				if err != nil {
-					return err
-				}
-			}
-
-			input.ContinuationToken = output.NextContinuationToken
-			isTruncated = *output.IsTruncated
+		return err
+	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.Move }); err != nil {
+		return err
+	}
+	if err := fs.checkPermission(ctx, path.Dir(fs.removeRoot(newName)), func(rp *provider.ResourcePermissions) bool {
+		if isDir {
+			return rp.CreateContainer
 		}
-		// ok, we are done
-		return nil
+		return rp.InitiateFileUpload
+	}); err != nil {
+		return err
 	}
 
-	// move single object
-	err = fs.moveObject(ctx, fn, newName)
-	if err != nil {
+	moved := []string{"/"}
+	if isDir {
+		keys, _, err := fs.movePrefix(ctx, fn, newName)
+		if err != nil {
+			return err
+		}
+		moved = movedPaths(fn, keys)
+	} else if err := fs.moveObject(ctx, fn, newName); err != nil {
 		return err
 	}
-	return nil
+
+	// the records carry the ids, so they survive the move
+	return fs.moveRecords(ctx, fs.nodesRoot(), fs.removeRoot(fn), fs.nodesRoot(), fs.removeRoot(newName), moved)
 }
 
 func (fs *s3FS) GetMD(ctx context.Context, ref *provider.Reference, mdKeys []string) (*provider.ResourceInfo, error) {
@@ -522,6 +523,14 @@ func (fs *s3FS) GetMD(ctx context.Context, ref *provider.Reference, mdKeys []str
 	if err != nil {
 		return nil, errors.Wrap(err, "error resolving ref")
 	}
+	r, acc, err := fs.lookup(ctx, fs.removeRoot(fn))
+	if err != nil {
+		return nil, err
+	}
+	perms := acc.result(fs.permissionSet(ctx))
+	if !perms.Stat {
+		return nil, errtypes.NotFound(fs.removeRoot(fn))
+	}
 
 	// first try a head, works for files
 	log.Debug().
@@ -565,7 +574,9 @@ func (fs *s3FS) GetMD(ctx context.Context, ref *provider.Reference, mdKeys []str
 					Str("fn", fn).
 					Msg("found CommonPrefix")
 				if *output.CommonPrefixes[i].Prefix == fn+"/" {
-					return fs.normalizeCommonPrefix(ctx, output.CommonPrefixes[i]), nil
+					md := fs.normalizeCommonPrefix(ctx, output.CommonPrefixes[i])
+					decorate(md, r, perms, mdKeys)
+					return md, nil
 				}
 			}
 
@@ -575,7 +586,9 @@ func (fs *s3FS) GetMD(ctx context.Context, ref *provider.Reference, mdKeys []str
 		return nil, errtypes.NotFound(fn)
 	}
 
-	return fs.normalizeHead(ctx, output, fn), nil
+	md := fs.normalizeHead(ctx, output, fn)
+	decorate(md, r, perms, mdKeys)
+	return md, nil
 }
 
 func (fs *s3FS) ListFolder(ctx context.Context, ref *provider.Reference, mdKeys []string) ([]*provider.ResourceInfo, error) {
@@ -583,10 +596,27 @@ func (fs *s3FS) ListFolder(ctx context.Context, ref *provider.Reference, mdKeys
 	if err != nil {
 		return nil, errors.Wrap(err, "error resolving ref")
 	}
+	p := fs.removeRoot(fn)
+	_, acc, err := fs.lookup(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	ownerPerms := fs.permissionSet(ctx)
+	if !acc.result(ownerPerms).ListContainer {
+		return nil, errtypes.PermissionDenied(p)
+	}
+	records, err := fs.getChildRecords(ctx, p)
+	if err != nil {
+		return nil, err
+	}
 
+	prefix := fn + "/"
+	if fn == "" {
+		prefix = ""
+	}
 	input := &s3.ListObjectsV2Input{
 		Bucket:    aws.String(fs.config.Bucket),
-		Prefix:    aws.String(fn + "/"),
+		Prefix:    aws.String(prefix),
 		Delimiter: aws.String("/"), // limit to a single directory
 	}
 	isTruncated := true
@@ -600,18 +630,42 @@ func (fs *s3FS) ListFolder(ctx context.Context, ref *provider.Reference, mdKeys
 		}
 
 		for i := range output.CommonPrefixes {
+			if isHiddenPath(fs.removeRoot(*output.CommonPrefixes[i].Prefix)) {
+				continue
+			}
 			finfos = append(finfos, fs.normalizeCommonPrefix(ctx, output.CommonPrefixes[i]))
 		}
 
 		for i := range output.Contents {
+			if *output.Contents[i].Key == prefix {
+				// skip the marker object of the directory itself
+				continue
+			}
 			finfos = append(finfos, fs.normalizeObject(ctx, output.Contents[i], *output.Contents[i].Key))
 		}
 
 		input.ContinuationToken = output.NextContinuationToken
 		isTruncated = *output.IsTruncated
 	}
+
+	visible := finfos[:0]
+	for _, md := range finfos {
+		childAcc := acc.child()
+		r, ok := records[path.Base(md.Path)]
+		if ok {
+			childAcc.add(ctx, r)
+		} else {
+			r = &nodeRecord{ID: derivedID(md.Path)}
+		}
+		perms := childAcc.result(ownerPerms)
+		if !perms.Stat {
+			continue
+		}
+		decorate(md, r, perms, mdKeys)
+		visible = append(visible, md)
+	}
 	// TODO sort fileinfos?
-	return finfos, nil
+	return visible, nil
 }
 
 func (fs *s3FS) Upload(ctx context.Context, ref *provider.Reference, r io.ReadCloser) error {
@@ -621,6 +675,12 @@ func (fs *s3FS) Upload(ctx context.Context, ref *provider.Reference, r io.ReadCl
 	if err != nil {
 		return errors.Wrap(err, "error resolving ref")
 	}
+	p := fs.removeRoot(fn)
+	if err := fs.checkPermission(ctx, p, func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileUpload }); err != nil {
+		return err
+	}
+	_, err = fs.isDir(ctx, fn)
+	existed := err == nil
 
 	upParams := &s3manager.UploadInput{
 		Bucket: aws.String(fs.config.Bucket),
@@ -641,7 +701,7 @@ func (fs *s3FS) Upload(ctx context.Context, ref *provider.Reference, r io.ReadCl
 	}
 
 	log.Debug().Interface("result", result) // todo cache etag?
-	return nil
+	return fs.ensureRecord(ctx, p, existed)
 }
 
 func (fs *s3FS) Download(ctx context.Context, ref *provider.Reference) (io.ReadCloser, error) {
@@ -651,6 +711,9 @@ func (fs *s3FS) Download(ctx context.Context, ref *provider.Reference) (io.ReadC
 	if err != nil {
 		return nil, errors.Wrap(err, "error resolving ref")
 	}
+	if err := fs.checkPermission(ctx, fs.removeRoot(fn), func(rp *provider.ResourcePermissions) bool { return rp.InitiateFileDownload }); err != nil {
+		return nil, err
+	}
 
 	// use GetObject instead of s3manager.Downloader:
 	// the result.Body is a ReadCloser, which allows streaming
@@ -673,34 +736,6 @@ func (fs *s3FS) Download(ctx context.Context, ref *provider.Reference) (io.ReadC
 	return r.Body, nil
 }
 
-func (fs *s3FS) ListRevisions(ctx context.Context, ref *provider.Reference) ([]*provider.FileVersion, error) {
-	return nil, errtypes.NotSupported("list revisions")
-}
-
-func (fs *s3FS) DownloadRevision(ctx context.Context, ref *provider.Reference, revisionKey string) (io.ReadCloser, error) {
-	return nil, errtypes.NotSupported("download revision")
-}
-
-func (fs *s3FS) RestoreRevision(ctx context.Context, ref *provider.Reference, revisionKey string) error {
-	return errtypes.NotSupported("restore revision")
-}
-
-func (fs *s3FS) PurgeRecycleItem(ctx context.Context, kbasePath, key, relativePath string) error {
-	return errtypes.NotSupported("purge recycle item")
-}
-
-func (fs *s3FS) EmptyRecycle(ctx context.Context) error {
-	return errtypes.NotSupported("empty recycle")
-}
-
-func (fs *s3FS) ListRecycle(ctx context.Context, basePath, key, relativePath string) ([]*provider.RecycleItem, error) {
-	return nil, errtypes.NotSupported("list recycle")
-}
-
-func (fs *s3FS) RestoreRecycleItem(ctx context.Context, basePath, key, relativePath string, restoreRef *provider.Reference) error {
-	return errtypes.NotSupported("restore recycle")
-}
-
 func (fs *s3FS) ListStorageSpaces(ctx context.Context, filter []*provider.ListStorageSpacesRequest_Filter) ([]*provider.StorageSpace, error) {
 	return nil, errtypes.NotSupported("list storage spaces")
 }
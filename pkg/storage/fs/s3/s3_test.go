@@ -0,0 +1,756 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package s3
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	grouppb "github.com/cs3org/go-cs3apis/cs3/identity/group/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage"
+)
+
+func newTestFS(t *testing.T, versioned bool) (storage.FS, func()) {
+	fs, _, shutdown := newTestFSWithPrefix(t, versioned, "")
+	return fs, shutdown
+}
+
+func newTestFSWithPrefix(t *testing.T, versioned bool, prefix string) (storage.FS, *fakeS3, func()) {
+	fake := newFakeS3("reva", versioned)
+	srv := httptest.NewServer(fake)
+	fs, err := New(map[string]interface{}{
+		"endpoint":   srv.URL,
+		"bucket":     "reva",
+		"access_key": "key",
+		"secret_key": "secret",
+		"prefix":     prefix,
+		"owner":      "marie",
+	})
+	if err != nil {
+		srv.Close()
+		t.Fatalf("error creating fs: %v", err)
+	}
+	return fs, fake, srv.Close
+}
+
+func userCtx(id string) context.Context {
+	return ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id:       &userpb.UserId{OpaqueId: id},
+		Username: id,
+	})
+}
+
+func ref(p string) *provider.Reference {
+	return &provider.Reference{Path: p}
+}
+
+func upload(t *testing.T, fs storage.FS, p, content string) {
+	if err := fs.Upload(userCtx("marie"), ref(p), io.NopCloser(strings.NewReader(content))); err != nil {
+		t.Fatalf("error uploading %s: %v", p, err)
+	}
+}
+
+func download(t *testing.T, fs storage.FS, p, revision string) string {
+	var (
+		r   io.ReadCloser
+		err error
+	)
+	if revision == "" {
+		r, err = fs.Download(userCtx("marie"), ref(p))
+	} else {
+		r, err = fs.DownloadRevision(userCtx("marie"), ref(p), revision)
+	}
+	if err != nil {
+		t.Fatalf("error downloading: %v", err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("error reading download: %v", err)
+	}
+	return string(b)
+}
+
+func listTrash(t *testing.T, fs storage.FS, key, relativePath string) []*provider.RecycleItem {
+	items, err := fs.ListRecycle(userCtx("marie"), "/", key, relativePath)
+	if err != nil {
+		t.Fatalf("error listing trash: %v", err)
+	}
+	return items
+}
+
+func TestDeleteAndRestoreFile(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/file.txt", "hello")
+	if err := fs.Delete(ctx, ref("/file.txt")); err != nil {
+		t.Fatalf("error deleting file: %v", err)
+	}
+	if _, err := fs.GetMD(ctx, ref("/file.txt"), nil); err == nil {
+		t.Fatal("expected deleted file to be gone")
+	}
+
+	infos, err := fs.ListFolder(ctx, ref("/"), nil)
+	if err != nil {
+		t.Fatalf("error listing root: %v", err)
+	}
+	if len(infos) != 0 {
+		t.Fatalf("expected the trash to be hidden, got %+v", infos)
+	}
+
+	items := listTrash(t, fs, "", "/")
+	if len(items) != 1 {
+		t.Fatalf("expected one trash item, got %d", len(items))
+	}
+	item := items[0]
+	if item.Ref.Path != "/file.txt" || item.Type != provider.ResourceType_RESOURCE_TYPE_FILE || item.Size != 5 || item.DeletionTime == nil {
+		t.Errorf("unexpected trash item %+v", item)
+	}
+
+	if err := fs.RestoreRecycleItem(ctx, "/", item.Key, "", nil); err != nil {
+		t.Fatalf("error restoring item: %v", err)
+	}
+	if content := download(t, fs, "/file.txt", ""); content != "hello" {
+		t.Errorf("expected restored content 'hello', got '%s'", content)
+	}
+	if items := listTrash(t, fs, "", "/"); len(items) != 0 {
+		t.Errorf("expected empty trash, got %d items", len(items))
+	}
+}
+
+func TestDeleteDirectory(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	if err := fs.CreateDir(ctx, ref("/dir")); err != nil {
+		t.Fatalf("error creating dir: %v", err)
+	}
+	upload(t, fs, "/dir/a.txt", "a")
+	upload(t, fs, "/dir/sub/b.txt", "bb")
+	upload(t, fs, "/dirty.txt", "not in dir")
+
+	if err := fs.Delete(ctx, ref("/dir")); err != nil {
+		t.Fatalf("error deleting dir: %v", err)
+	}
+	if err := fs.Delete(ctx, ref("/dir")); err == nil {
+		t.Error("expected deleting a deleted dir to fail")
+	} else if _, ok := err.(errtypes.IsNotFound); !ok {
+		t.Errorf("expected a NotFound error, got %v", err)
+	}
+	if content := download(t, fs, "/dirty.txt", ""); content != "not in dir" {
+		t.Errorf("sibling with the same prefix was touched, got '%s'", content)
+	}
+
+	items := listTrash(t, fs, "", "/")
+	if len(items) != 1 || items[0].Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER || items[0].Size != 3 {
+		t.Fatalf("unexpected trash items %+v", items)
+	}
+	key := items[0].Key
+
+	children := listTrash(t, fs, key, "/")
+	if len(children) != 2 {
+		t.Fatalf("expected two children, got %+v", children)
+	}
+	for _, c := range children {
+		switch c.Ref.Path {
+		case "/dir/a.txt":
+			if c.Type != provider.ResourceType_RESOURCE_TYPE_FILE || c.Key != key+"/a.txt" {
+				t.Errorf("unexpected child %+v", c)
+			}
+		case "/dir/sub":
+			if c.Type != provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+				t.Errorf("unexpected child %+v", c)
+			}
+		default:
+			t.Errorf("unexpected child %+v", c)
+		}
+	}
+
+	// restore a single file to a new location, then purge the rest
+	if err := fs.RestoreRecycleItem(ctx, "/", key, "/sub/b.txt", ref("/b.txt")); err != nil {
+		t.Fatalf("error restoring child: %v", err)
+	}
+	if content := download(t, fs, "/b.txt", ""); content != "bb" {
+		t.Errorf("expected restored content 'bb', got '%s'", content)
+	}
+	if err := fs.RestoreRecycleItem(ctx, "/", key, "/a.txt", ref("/b.txt")); err == nil {
+		t.Error("expected restoring over an existing file to fail")
+	}
+	if err := fs.PurgeRecycleItem(ctx, "/", key, "/"); err != nil {
+		t.Fatalf("error purging item: %v", err)
+	}
+	if items := listTrash(t, fs, "", "/"); len(items) != 0 {
+		t.Errorf("expected empty trash, got %d items", len(items))
+	}
+}
+
+func TestEmptyRecycle(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/a.txt", "a")
+	upload(t, fs, "/b.txt", "b")
+	for _, p := range []string{"/a.txt", "/b.txt"} {
+		if err := fs.Delete(ctx, ref(p)); err != nil {
+			t.Fatalf("error deleting %s: %v", p, err)
+		}
+	}
+	if items := listTrash(t, fs, "", "/"); len(items) != 2 {
+		t.Fatalf("expected two trash items, got %d", len(items))
+	}
+	if err := fs.EmptyRecycle(ctx); err != nil {
+		t.Fatalf("error emptying trash: %v", err)
+	}
+	if items := listTrash(t, fs, "", "/"); len(items) != 0 {
+		t.Errorf("expected empty trash, got %d items", len(items))
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	fs, shutdown := newTestFS(t, true)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/file.txt", "v1")
+	upload(t, fs, "/file.txt", "v2")
+	upload(t, fs, "/file.txt.bak", "other")
+
+	revs, err := fs.ListRevisions(ctx, ref("/file.txt"))
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	if len(revs) != 1 || revs[0].Size != 2 {
+		t.Fatalf("expected a single revision, got %+v", revs)
+	}
+	if content := download(t, fs, "/file.txt", revs[0].Key); content != "v1" {
+		t.Errorf("expected revision content 'v1', got '%s'", content)
+	}
+	if _, err := fs.DownloadRevision(ctx, ref("/file.txt"), "unknown"); err == nil {
+		t.Error("expected downloading an unknown revision to fail")
+	}
+
+	if err := fs.RestoreRevision(ctx, ref("/file.txt"), revs[0].Key); err != nil {
+		t.Fatalf("error restoring revision: %v", err)
+	}
+	if content := download(t, fs, "/file.txt", ""); content != "v1" {
+		t.Errorf("expected restored content 'v1', got '%s'", content)
+	}
+	revs, err = fs.ListRevisions(ctx, ref("/file.txt"))
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	if len(revs) != 2 {
+		t.Errorf("expected the overwritten content to become a revision, got %+v", revs)
+	}
+}
+
+func TestRevisionsUnversionedBucket(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+
+	upload(t, fs, "/file.txt", "v1")
+	upload(t, fs, "/file.txt", "v2")
+	revs, err := fs.ListRevisions(userCtx("marie"), ref("/file.txt"))
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	if len(revs) != 0 {
+		t.Errorf("expected no revisions, got %+v", revs)
+	}
+}
+
+func TestIDsSurviveRenames(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/dir/file.txt", "hello")
+	dirInfo, err := fs.GetMD(ctx, ref("/dir"), nil)
+	if err != nil {
+		t.Fatalf("error stating dir: %v", err)
+	}
+	infos, err := fs.ListFolder(ctx, ref("/dir"), nil)
+	if err != nil || len(infos) != 1 {
+		t.Fatalf("error listing dir: %v %+v", err, infos)
+	}
+	fileID := infos[0].Id
+
+	if err := fs.Move(ctx, ref("/dir"), ref("/renamed")); err != nil {
+		t.Fatalf("error moving dir: %v", err)
+	}
+	p, err := fs.GetPathByID(ctx, fileID)
+	if err != nil || p != "/renamed/file.txt" {
+		t.Errorf("expected the id to point to /renamed/file.txt, got %s %v", p, err)
+	}
+	info, err := fs.GetMD(ctx, &provider.Reference{ResourceId: dirInfo.Id, Path: "./file.txt"}, nil)
+	if err != nil || info.Id.OpaqueId != fileID.OpaqueId {
+		t.Errorf("expected to stat the file relative to the dir id, got %+v %v", info, err)
+	}
+
+	// ids are kept in the trash
+	if err := fs.Delete(ctx, ref("/renamed")); err != nil {
+		t.Fatalf("error deleting dir: %v", err)
+	}
+	if _, err := fs.GetPathByID(ctx, fileID); err == nil {
+		t.Error("expected the id of a trashed file to be unknown")
+	}
+	items := listTrash(t, fs, "", "/")
+	if len(items) != 1 {
+		t.Fatalf("expected one trash item, got %d", len(items))
+	}
+	if err := fs.RestoreRecycleItem(ctx, "/", items[0].Key, "/", nil); err != nil {
+		t.Fatalf("error restoring dir: %v", err)
+	}
+	if p, err := fs.GetPathByID(ctx, fileID); err != nil || p != "/renamed/file.txt" {
+		t.Errorf("expected the restored file to keep its id, got %s %v", p, err)
+	}
+
+	// a new file at an old location gets a new id
+	upload(t, fs, "/dir/file.txt", "new")
+	info, err = fs.GetMD(ctx, ref("/dir/file.txt"), nil)
+	if err != nil || info.Id.OpaqueId == fileID.OpaqueId {
+		t.Errorf("expected a new id, got %+v %v", info, err)
+	}
+
+	infos, err = fs.ListFolder(ctx, ref("/"), nil)
+	if err != nil {
+		t.Fatalf("error listing root: %v", err)
+	}
+	for _, i := range infos {
+		if isHiddenPath(i.Path) {
+			t.Errorf("expected hidden path %s not to be listed", i.Path)
+		}
+	}
+}
+
+func TestGrantsAndMetadata(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/file.txt", "hello")
+	grant := &provider.Grant{
+		Grantee: &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_USER,
+			Id:   &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: "einstein"}},
+		},
+		Permissions: &provider.ResourcePermissions{Stat: true, InitiateFileDownload: true},
+	}
+	if err := fs.AddGrant(ctx, ref("/file.txt"), grant); err != nil {
+		t.Fatalf("error adding grant: %v", err)
+	}
+	if err := fs.SetArbitraryMetadata(ctx, ref("/file.txt"), &provider.ArbitraryMetadata{Metadata: map[string]string{
+		"http://owncloud.org/ns/favorite": "1",
+		"http://owncloud.org/ns/tags":     "a,b",
+	}}); err != nil {
+		t.Fatalf("error setting metadata: %v", err)
+	}
+	if err := fs.UnsetArbitraryMetadata(ctx, ref("/file.txt"), []string{"http://owncloud.org/ns/tags"}); err != nil {
+		t.Fatalf("error unsetting metadata: %v", err)
+	}
+	if err := fs.Move(ctx, ref("/file.txt"), ref("/moved.txt")); err != nil {
+		t.Fatalf("error moving file: %v", err)
+	}
+
+	grants, err := fs.ListGrants(ctx, ref("/moved.txt"))
+	if err != nil {
+		t.Fatalf("error listing grants: %v", err)
+	}
+	if len(grants) != 1 || grants[0].Grantee.GetUserId().OpaqueId != "einstein" || !grants[0].Permissions.InitiateFileDownload || grants[0].Permissions.Delete {
+		t.Errorf("unexpected grants %+v", grants)
+	}
+	info, err := fs.GetMD(ctx, ref("/moved.txt"), nil)
+	if err != nil {
+		t.Fatalf("error stating file: %v", err)
+	}
+	if md := info.ArbitraryMetadata.Metadata; len(md) != 1 || md["http://owncloud.org/ns/favorite"] != "1" {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+
+	if err := fs.RemoveGrant(ctx, ref("/moved.txt"), grant); err != nil {
+		t.Fatalf("error removing grant: %v", err)
+	}
+	if grants, err := fs.ListGrants(ctx, ref("/moved.txt")); err != nil || len(grants) != 0 {
+		t.Errorf("expected no grants, got %+v %v", grants, err)
+	}
+	if err := fs.AddGrant(ctx, ref("/missing.txt"), grant); err == nil {
+		t.Error("expected adding a grant to a missing file to fail")
+	}
+}
+
+func TestTrashIsPerUser(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/file.txt", "hello")
+	if err := fs.Delete(ctx, ref("/file.txt")); err != nil {
+		t.Fatalf("error deleting file: %v", err)
+	}
+	items := listTrash(t, fs, "", "/")
+	if len(items) != 1 {
+		t.Fatalf("expected one trash item, got %d", len(items))
+	}
+
+	other := userCtx("einstein")
+	if items, err := fs.ListRecycle(other, "/", "", "/"); err != nil || len(items) != 0 {
+		t.Errorf("expected an empty trash for another user, got %+v %v", items, err)
+	}
+	if err := fs.PurgeRecycleItem(other, "/", items[0].Key, ""); err == nil {
+		t.Error("expected purging the item of another user to fail")
+	}
+	if err := fs.EmptyRecycle(other); err != nil {
+		t.Fatalf("error emptying trash: %v", err)
+	}
+	if items := listTrash(t, fs, "", "/"); len(items) != 1 {
+		t.Errorf("expected the trash item to survive, got %d items", len(items))
+	}
+
+	if _, err := fs.ListRecycle(context.Background(), "/", "", "/"); err == nil {
+		t.Error("expected listing the trash without a user to fail")
+	}
+}
+
+func TestTrashItemPathsAreValidated(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/dir/file.txt", "hello")
+	if err := fs.Delete(ctx, ref("/dir")); err != nil {
+		t.Fatalf("error deleting dir: %v", err)
+	}
+	key := listTrash(t, fs, "", "/")[0].Key
+
+	for _, tc := range []struct{ key, relativePath string }{
+		{"..", ""},
+		{key + "/..", ""},
+		{"../../.meta", ""},
+		{key, "../.."},
+		{key, "file.txt/../../other"},
+	} {
+		if err := fs.PurgeRecycleItem(ctx, "/", tc.key, tc.relativePath); err == nil {
+			t.Errorf("expected purging %s %s to fail", tc.key, tc.relativePath)
+		}
+		if err := fs.RestoreRecycleItem(ctx, "/", tc.key, tc.relativePath, nil); err == nil {
+			t.Errorf("expected restoring %s %s to fail", tc.key, tc.relativePath)
+		}
+	}
+	if err := fs.RestoreRecycleItem(ctx, "/", key, "file.txt", nil); err != nil {
+		t.Fatalf("error restoring child: %v", err)
+	}
+	if content := download(t, fs, "/dir/file.txt", ""); content != "hello" {
+		t.Errorf("expected restored content 'hello', got '%s'", content)
+	}
+}
+
+// TestKeyLayout makes sure objects written with the previous key layout,
+// where the configured prefix was joined with the absolute path, stay
+// reachable: the S3 client cleans the request path, so those objects were
+// stored without the leading slash.
+func TestKeyLayout(t *testing.T) {
+	for _, prefix := range []string{"", "/", "/data", "data"} {
+		fs, fake, shutdown := newTestFSWithPrefix(t, false, prefix)
+		ctx := userCtx("marie")
+
+		legacyKey := path.Join(prefix, "/dir/file.txt")
+		_, err := fs.(*s3FS).client.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String("reva"),
+			Key:    aws.String(legacyKey),
+			Body:   strings.NewReader("hello"),
+		})
+		if err != nil {
+			t.Fatalf("error writing legacy object: %v", err)
+		}
+		if content := download(t, fs, "/dir/file.txt", ""); content != "hello" {
+			t.Errorf("prefix %q: expected content 'hello', got '%s'", prefix, content)
+		}
+		infos, err := fs.ListFolder(ctx, ref("/dir"), nil)
+		if err != nil || len(infos) != 1 || infos[0].Path != "/dir/file.txt" {
+			t.Errorf("prefix %q: unexpected listing %+v %v", prefix, infos, err)
+		}
+
+		upload(t, fs, "/new.txt", "new")
+		fake.Lock()
+		_, ok := fake.objects[strings.TrimPrefix(path.Join(prefix, "/new.txt"), "/")]
+		fake.Unlock()
+		if !ok {
+			t.Errorf("prefix %q: expected new.txt to be stored next to the legacy objects", prefix)
+		}
+		shutdown()
+	}
+}
+
+func TestLookupsDoNotWrite(t *testing.T) {
+	fs, fake, shutdown := newTestFSWithPrefix(t, false, "")
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	for _, k := range []string{"dir/a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		_, err := fs.(*s3FS).client.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String("reva"),
+			Key:    aws.String(k),
+			Body:   strings.NewReader("hello"),
+		})
+		if err != nil {
+			t.Fatalf("error writing object: %v", err)
+		}
+	}
+	fake.countRequests()
+
+	first, err := fs.GetMD(ctx, ref("/dir/a.txt"), nil)
+	if err != nil {
+		t.Fatalf("error stating file: %v", err)
+	}
+	second, err := fs.GetMD(userCtx("einstein"), ref("/dir/a.txt"), nil)
+	if err != nil {
+		t.Fatalf("error stating file: %v", err)
+	}
+	if first.Id.OpaqueId != second.Id.OpaqueId || first.Id.OpaqueId != "fileid-dir/a.txt" {
+		t.Errorf("expected the derived id, got %s and %s", first.Id.OpaqueId, second.Id.OpaqueId)
+	}
+	infos, err := fs.ListFolder(ctx, ref("/dir"), nil)
+	if err != nil || len(infos) != 3 {
+		t.Fatalf("unexpected listing %+v %v", infos, err)
+	}
+	if requests := fake.countRequests(); requests["PUT"] != 0 || requests["POST"] != 0 || requests["DELETE"] != 0 {
+		t.Errorf("expected lookups not to write, got %v", requests)
+	}
+
+	sub := ""
+	for _, i := range infos {
+		if i.Path == "/dir/sub" {
+			sub = i.Id.OpaqueId
+		}
+	}
+	if err := fs.Move(ctx, ref("/dir"), ref("/moved")); err != nil {
+		t.Fatalf("error moving dir: %v", err)
+	}
+	for p, id := range map[string]string{"/moved/a.txt": first.Id.OpaqueId, "/moved/sub": sub} {
+		info, err := fs.GetMD(ctx, ref(p), nil)
+		if err != nil {
+			t.Fatalf("error stating %s: %v", p, err)
+		}
+		if info.Id.OpaqueId != id {
+			t.Errorf("expected %s to keep id %s, got %s", p, id, info.Id.OpaqueId)
+		}
+		if got, err := fs.GetPathByID(ctx, info.Id); err != nil || got != p {
+			t.Errorf("expected id %s to point to %s, got %s %v", id, p, got, err)
+		}
+	}
+}
+
+func TestListFolderBatchesRecordReads(t *testing.T) {
+	fs, fake, shutdown := newTestFSWithPrefix(t, false, "")
+	defer shutdown()
+	ctx := userCtx("marie")
+
+	upload(t, fs, "/dir/recorded.txt", "hello")
+	_, err := fs.(*s3FS).client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("reva"),
+		Key:    aws.String("dir/unrecorded.txt"),
+		Body:   strings.NewReader("hello"),
+	})
+	if err != nil {
+		t.Fatalf("error writing object: %v", err)
+	}
+	fake.countRequests()
+
+	infos, err := fs.ListFolder(ctx, ref("/dir"), nil)
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("unexpected listing %+v %v", infos, err)
+	}
+	// two lookups for the folder and its parent, two listings and one read
+	// for the only child with a record
+	if requests := fake.countRequests(); requests["GET"] != 5 {
+		t.Errorf("expected 5 GET requests, got %v", requests)
+	}
+}
+
+func TestGrantsAreEnforced(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	owner, einstein := userCtx("marie"), userCtx("einstein")
+	physicist := ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id:     &userpb.UserId{OpaqueId: "feynman"},
+		Groups: []string{"physics"},
+	})
+
+	if err := fs.CreateDir(owner, ref("/dir")); err != nil {
+		t.Fatalf("error creating dir: %v", err)
+	}
+	upload(t, fs, "/dir/file.txt", "hello")
+	upload(t, fs, "/dir/other.txt", "hello")
+	if _, err := fs.GetMD(einstein, ref("/dir/file.txt"), nil); err == nil {
+		t.Error("expected stating without a grant to fail")
+	}
+	if _, err := fs.Download(einstein, ref("/dir/file.txt")); err == nil {
+		t.Error("expected downloading without a grant to fail")
+	}
+	if err := fs.AddGrant(einstein, ref("/dir"), &provider.Grant{Permissions: &provider.ResourcePermissions{}}); err == nil {
+		t.Error("expected adding a grant without permission to fail")
+	}
+
+	read := &provider.ResourcePermissions{Stat: true, ListContainer: true, InitiateFileDownload: true}
+	if err := fs.AddGrant(owner, ref("/dir"), &provider.Grant{
+		Grantee: &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_USER,
+			Id:   &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: "einstein"}},
+		},
+		Permissions: read,
+	}); err != nil {
+		t.Fatalf("error adding grant: %v", err)
+	}
+	if err := fs.AddGrant(owner, ref("/dir/other.txt"), &provider.Grant{
+		Grantee: &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_GROUP,
+			Id:   &provider.Grantee_GroupId{GroupId: &grouppb.GroupId{OpaqueId: "physics"}},
+		},
+		Permissions: read,
+	}); err != nil {
+		t.Fatalf("error adding grant: %v", err)
+	}
+
+	info, err := fs.GetMD(einstein, ref("/dir/file.txt"), nil)
+	if err != nil {
+		t.Fatalf("error stating shared file: %v", err)
+	}
+	if !info.PermissionSet.InitiateFileDownload || info.PermissionSet.Delete || info.Owner.GetOpaqueId() != "marie" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if infos, err := fs.ListFolder(einstein, ref("/dir"), nil); err != nil || len(infos) != 2 {
+		t.Errorf("unexpected listing %+v %v", infos, err)
+	}
+	if err := fs.Delete(einstein, ref("/dir/file.txt")); err == nil {
+		t.Error("expected deleting with a read grant to fail")
+	}
+	if err := fs.Upload(einstein, ref("/dir/new.txt"), io.NopCloser(strings.NewReader("new"))); err == nil {
+		t.Error("expected uploading with a read grant to fail")
+	}
+
+	if infos, err := fs.ListFolder(physicist, ref("/dir"), nil); err == nil {
+		t.Errorf("expected listing without a grant on the folder to fail, got %+v", infos)
+	}
+	if _, err := fs.GetMD(physicist, ref("/dir/other.txt"), nil); err != nil {
+		t.Errorf("expected the group grant to allow stating: %v", err)
+	}
+	if _, err := fs.GetMD(physicist, ref("/dir/file.txt"), nil); err == nil {
+		t.Error("expected the group grant not to leak to siblings")
+	}
+}
+
+func TestUnownedTreesAreReadOnly(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	owner, einstein := userCtx("marie"), userCtx("einstein")
+
+	// objects written by earlier versions of the driver have no record
+	if _, err := fs.(*s3FS).client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("reva"),
+		Key:    aws.String("legacy/file.txt"),
+		Body:   strings.NewReader("hello"),
+	}); err != nil {
+		t.Fatalf("error writing object: %v", err)
+	}
+
+	info, err := fs.GetMD(einstein, ref("/legacy/file.txt"), nil)
+	if err != nil {
+		t.Fatalf("error stating unowned file: %v", err)
+	}
+	if !info.PermissionSet.InitiateFileDownload || info.PermissionSet.Delete || info.PermissionSet.AddGrant {
+		t.Errorf("expected read permissions only, got %+v", info.PermissionSet)
+	}
+	if err := fs.Delete(einstein, ref("/legacy/file.txt")); err == nil {
+		t.Error("expected deleting an unowned file to fail")
+	}
+	if err := fs.Move(einstein, ref("/legacy"), ref("/stolen")); err == nil {
+		t.Error("expected moving an unowned folder to fail")
+	}
+	if err := fs.Upload(einstein, ref("/legacy/file.txt"), io.NopCloser(strings.NewReader("overwritten"))); err == nil {
+		t.Error("expected overwriting an unowned file to fail")
+	}
+	if err := fs.AddGrant(einstein, ref("/legacy"), &provider.Grant{
+		Grantee: &provider.Grantee{
+			Type: provider.GranteeType_GRANTEE_TYPE_USER,
+			Id:   &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: "einstein"}},
+		},
+		Permissions: &provider.ResourcePermissions{Delete: true},
+	}); err == nil {
+		t.Error("expected granting on an unowned folder to fail")
+	}
+
+	// the configured owner has all permissions
+	if err := fs.Move(owner, ref("/legacy"), ref("/moved")); err != nil {
+		t.Fatalf("error moving unowned folder as its configured owner: %v", err)
+	}
+	if got := download(t, fs, "/moved/file.txt", ""); got != "hello" {
+		t.Errorf("unexpected content %q", got)
+	}
+}
+
+func TestConcurrentGrantUpdates(t *testing.T) {
+	fs, shutdown := newTestFS(t, false)
+	defer shutdown()
+	owner := userCtx("marie")
+
+	if err := fs.CreateDir(owner, ref("/dir")); err != nil {
+		t.Fatalf("error creating dir: %v", err)
+	}
+	grantees := []string{"einstein", "feynman", "curie", "bohr"}
+	var wg sync.WaitGroup
+	for _, g := range grantees {
+		wg.Add(1)
+		go func(g string) {
+			defer wg.Done()
+			if err := fs.AddGrant(owner, ref("/dir"), &provider.Grant{
+				Grantee: &provider.Grantee{
+					Type: provider.GranteeType_GRANTEE_TYPE_USER,
+					Id:   &provider.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: g}},
+				},
+				Permissions: &provider.ResourcePermissions{Stat: true},
+			}); err != nil {
+				t.Errorf("error adding grant: %v", err)
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	grants, err := fs.ListGrants(owner, ref("/dir"))
+	if err != nil {
+		t.Fatalf("error listing grants: %v", err)
+	}
+	if len(grants) != len(grantees) {
+		t.Errorf("expected %d grants, got %d", len(grantees), len(grants))
+	}
+}
@@ -67,6 +67,12 @@ type FS interface {
 	UpdateStorageSpace(ctx context.Context, req *provider.UpdateStorageSpaceRequest) (*provider.UpdateStorageSpaceResponse, error)
 }
 
+// RecycleDownloader is implemented by the storage drivers able to
+// read the content of the files in the recycle bin.
+type RecycleDownloader interface {
+	DownloadRecycleItem(ctx context.Context, basePath, key, relativePath string) (io.ReadCloser, error)
+}
+
 // Registry is the interface that storage registries implement
 // for discovering storage providers.
 type Registry interface {
@@ -38,6 +38,7 @@ import (
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/logger"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/storage"
@@ -72,6 +73,7 @@ type Tree interface {
 	Delete(ctx context.Context, node *node.Node) (err error)
 	RestoreRecycleItemFunc(ctx context.Context, key, trashPath, restorePath string) (*node.Node, *node.Node, func() error, error) // FIXME REFERENCE use ref instead of path
 	PurgeRecycleItemFunc(ctx context.Context, key, purgePath string) (*node.Node, func() error, error)
+	ReadRecycleItem(ctx context.Context, key, path string) (*node.Node, error)
 
 	WriteBlob(key string, reader io.Reader) error
 	ReadBlob(key string) (io.ReadCloser, error)
@@ -140,6 +142,11 @@ func (fs *Decomposedfs) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Ready tells whether the root is writable.
+func (fs *Decomposedfs) Ready(ctx context.Context) error {
+	return health.Writable(fs.o.Root)
+}
+
 // GetQuota returns the quota available
 // TODO Document in the cs3 should we return quota or free space?
 func (fs *Decomposedfs) GetQuota(ctx context.Context, ref *provider.Reference) (total uint64, inUse uint64, err error) {
@@ -398,14 +405,27 @@ func (fs *Decomposedfs) Move(ctx context.Context, oldRef, newRef *provider.Refer
 		return errtypes.PermissionDenied(oldNode.ID)
 	}
 
+	if err = oldNode.CheckTreeLocks(ctx); err != nil {
+		return
+	}
+
 	if newNode, err = fs.lu.NodeFromResource(ctx, newRef); err != nil {
 		return
 	}
 	if newNode.Exists {
+		// a locked target must not be replaced
+		if err = newNode.CheckTreeLocks(ctx); err != nil {
+			return
+		}
 		err = errtypes.AlreadyExists(filepath.Join(newNode.ParentID, newNode.Name))
 		return
 	}
 
+	// a lock on the target folder protects its members
+	if err = node.New(newNode.ParentID, "", "", 0, "", nil, fs.lu).CheckLock(ctx); err != nil {
+		return
+	}
+
 	return fs.tp.Move(ctx, oldNode, newNode)
 }
 
@@ -494,6 +514,10 @@ func (fs *Decomposedfs) Delete(ctx context.Context, ref *provider.Reference) (er
 		return errtypes.PermissionDenied(filepath.Join(node.ParentID, node.Name))
 	}
 
+	if err = node.CheckTreeLocks(ctx); err != nil {
+		return
+	}
+
 	return fs.tp.Delete(ctx, node)
 }
 
@@ -519,6 +543,10 @@ func (fs *Decomposedfs) Download(ctx context.Context, ref *provider.Reference) (
 		return nil, errtypes.PermissionDenied(filepath.Join(node.ParentID, node.Name))
 	}
 
+	if err := node.CheckReadLock(ctx); err != nil {
+		return nil, err
+	}
+
 	reader, err := fs.tp.ReadBlob(node.BlobID)
 	if err != nil {
 		return nil, errors.Wrap(err, "decomposedfs: error download blob '"+node.ID+"'")
@@ -528,20 +556,75 @@ func (fs *Decomposedfs) Download(ctx context.Context, ref *provider.Reference) (
 
 // GetLock returns an existing lock on the given reference.
 func (fs *Decomposedfs) GetLock(ctx context.Context, ref *provider.Reference) (*provider.Lock, error) {
-	return nil, errtypes.NotSupported("unimplemented")
+	n, err := fs.lu.NodeFromResource(ctx, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "decomposedfs: error resolving ref")
+	}
+
+	if !n.Exists {
+		return nil, errtypes.NotFound(filepath.Join(n.ParentID, n.Name))
+	}
+
+	ok, err := fs.p.HasPermission(ctx, n, func(rp *provider.ResourcePermissions) bool {
+		return rp.Stat
+	})
+	switch {
+	case err != nil:
+		return nil, errtypes.InternalError(err.Error())
+	case !ok:
+		return nil, errtypes.PermissionDenied(filepath.Join(n.ParentID, n.Name))
+	}
+
+	return n.ReadLock(ctx)
 }
 
 // SetLock puts a lock on the given reference.
 func (fs *Decomposedfs) SetLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
+	n, err := fs.lockableNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return n.SetLock(ctx, lock)
 }
 
 // RefreshLock refreshes an existing lock on the given reference.
 func (fs *Decomposedfs) RefreshLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock, existingLockID string) error {
-	return errtypes.NotSupported("unimplemented")
+	n, err := fs.lockableNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return n.RefreshLock(ctx, lock, existingLockID)
 }
 
 // Unlock removes an existing lock from the given reference.
 func (fs *Decomposedfs) Unlock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
+	n, err := fs.lockableNode(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return n.Unlock(ctx, lock)
+}
+
+// lockableNode resolves the reference and checks that the current user may
+// change the lock of the node, which requires write access to it.
+func (fs *Decomposedfs) lockableNode(ctx context.Context, ref *provider.Reference) (*node.Node, error) {
+	n, err := fs.lu.NodeFromResource(ctx, ref)
+	if err != nil {
+		return nil, errors.Wrap(err, "decomposedfs: error resolving ref")
+	}
+
+	if !n.Exists {
+		return nil, errtypes.NotFound(filepath.Join(n.ParentID, n.Name))
+	}
+
+	ok, err := fs.p.HasPermission(ctx, n, func(rp *provider.ResourcePermissions) bool {
+		return rp.InitiateFileUpload
+	})
+	switch {
+	case err != nil:
+		return nil, errtypes.InternalError(err.Error())
+	case !ok:
+		return nil, errtypes.PermissionDenied(filepath.Join(n.ParentID, n.Name))
+	}
+	return n, nil
 }
@@ -22,6 +22,7 @@ import (
 	"context"
 	"os"
 	"path"
+	"strconv"
 	"sync"
 
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -120,6 +121,42 @@ var _ = Describe("Decomposed", func() {
 			})
 		})
 
+		Describe("SetLock", func() {
+			It("grants the lock to exactly one caller", func() {
+				ref := &provider.Reference{Path: "/locked"}
+				Expect(fs.CreateDir(ctx, ref)).To(Succeed())
+
+				var (
+					wg      sync.WaitGroup
+					mu      sync.Mutex
+					winners []string
+				)
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func(i int) {
+						defer GinkgoRecover()
+						defer wg.Done()
+						lockID := "lock-" + strconv.Itoa(i)
+						err := fs.SetLock(ctx, ref, &provider.Lock{
+							Type:   provider.LockType_LOCK_TYPE_EXCL,
+							LockId: lockID,
+						})
+						if err == nil {
+							mu.Lock()
+							winners = append(winners, lockID)
+							mu.Unlock()
+						}
+					}(i)
+				}
+				wg.Wait()
+
+				Expect(winners).To(HaveLen(1))
+				lock, err := fs.GetLock(ctx, ref)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(lock.LockId).To(Equal(winners[0]))
+			})
+		})
+
 		Describe("CreateDir", func() {
 			It("handle already existing directories", func() {
 				for i := 0; i < 10; i++ {
@@ -20,6 +20,8 @@ package decomposedfs_test
 
 import (
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/storage/utils/decomposedfs"
 	helpers "github.com/cs3org/reva/pkg/storage/utils/decomposedfs/testhelpers"
 	treemocks "github.com/cs3org/reva/pkg/storage/utils/decomposedfs/tree/mocks"
@@ -83,6 +85,66 @@ var _ = Describe("Decomposed", func() {
 				Expect(err).ToNot(HaveOccurred())
 				env.Blobstore.AssertNotCalled(GinkgoT(), "Delete", mock.AnythingOfType("string"))
 			})
+
+			It("refuses to delete a locked resource without the lock id", func() {
+				Expect(env.Fs.SetLock(env.Ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+				err := env.Fs.Delete(env.Ctx, ref)
+				Expect(err).To(MatchError(errtypes.Locked("lock-id")))
+
+				err = env.Fs.Delete(ctxpkg.ContextSetLockID(env.Ctx, "lock-id"), ref)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("refuses to delete a folder with a locked child", func() {
+				child := &provider.Reference{Path: "/dir1/file1"}
+				Expect(env.Fs.SetLock(env.Ctx, child, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+				err := env.Fs.Delete(env.Ctx, ref)
+				Expect(err).To(MatchError(errtypes.Locked("lock-id")))
+			})
+
+			It("ignores the locks outside of the folder", func() {
+				Expect(env.Fs.CreateDir(env.Ctx, &provider.Reference{Path: "/dir2"})).To(Succeed())
+				Expect(env.Fs.SetLock(env.Ctx, &provider.Reference{Path: "/dir2"}, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+				Expect(env.Fs.Delete(env.Ctx, ref)).To(Succeed())
+			})
+		})
+	})
+
+	Describe("Move", func() {
+		JustBeforeEach(func() {
+			env.Permissions.On("HasPermission", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
+		})
+
+		It("refuses to move a locked resource without the lock id", func() {
+			target := &provider.Reference{Path: "/dir2"}
+			Expect(env.Fs.SetLock(env.Ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+			err := env.Fs.Move(env.Ctx, ref, target)
+			Expect(err).To(MatchError(errtypes.Locked("lock-id")))
+
+			err = env.Fs.Move(ctxpkg.ContextSetLockID(env.Ctx, "lock-id"), ref, target)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("refuses to move a resource into a locked folder without the lock id", func() {
+			Expect(env.Fs.CreateDir(env.Ctx, &provider.Reference{Path: "/dir2"})).To(Succeed())
+			Expect(env.Fs.SetLock(env.Ctx, &provider.Reference{Path: "/dir2"}, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+			err := env.Fs.Move(env.Ctx, ref, &provider.Reference{Path: "/dir2/dir1"})
+			Expect(err).To(MatchError(errtypes.Locked("lock-id")))
+		})
+
+		It("refuses to replace a target with a locked child", func() {
+			Expect(env.Fs.CreateDir(env.Ctx, &provider.Reference{Path: "/dir2"})).To(Succeed())
+			Expect(env.Fs.CreateDir(env.Ctx, &provider.Reference{Path: "/dir2/sub"})).To(Succeed())
+			Expect(env.Fs.CreateDir(env.Ctx, &provider.Reference{Path: "/dir2/sub/deeper"})).To(Succeed())
+			Expect(env.Fs.SetLock(env.Ctx, &provider.Reference{Path: "/dir2/sub/deeper"}, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"})).To(Succeed())
+
+			err := env.Fs.Move(env.Ctx, ref, &provider.Reference{Path: "/dir2"})
+			Expect(err).To(MatchError(errtypes.Locked("lock-id")))
 		})
 	})
 })
@@ -58,6 +58,10 @@ func (fs *Decomposedfs) SetArbitraryMetadata(ctx context.Context, ref *provider.
 		return errtypes.PermissionDenied(filepath.Join(n.ParentID, n.Name))
 	}
 
+	if err := n.CheckLock(ctx); err != nil {
+		return err
+	}
+
 	nodePath := n.InternalPath()
 
 	errs := []error{}
@@ -146,6 +150,10 @@ func (fs *Decomposedfs) UnsetArbitraryMetadata(ctx context.Context, ref *provide
 		return errtypes.PermissionDenied(filepath.Join(n.ParentID, n.Name))
 	}
 
+	if err := n.CheckLock(ctx); err != nil {
+		return err
+	}
+
 	nodePath := n.InternalPath()
 	errs := []error{}
 	for _, k := range keys {
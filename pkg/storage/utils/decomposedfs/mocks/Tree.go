@@ -289,3 +289,26 @@ func (_m *Tree) WriteBlob(key string, reader io.Reader) error {
 
 	return r0
 }
+
+// ReadRecycleItem provides a mock function with given fields: ctx, key, path
+func (_m *Tree) ReadRecycleItem(ctx context.Context, key string, path string) (*node.Node, error) {
+	ret := _m.Called(ctx, key, path)
+
+	var r0 *node.Node
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) *node.Node); ok {
+		r0 = rf(ctx, key, path)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*node.Node)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string, string) error); ok {
+		r1 = rf(ctx, key, path)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,312 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package node
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/decomposedfs/xattrs"
+	"github.com/cs3org/reva/pkg/storage/utils/locks"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/pkg/xattr"
+)
+
+// LockFileSuffix is appended to the internal path of a node to build the path of its lock file.
+const LockFileSuffix = ".lock"
+
+// lockStripes serializes lock operations within the process. Creating the
+// lock file is additionally atomic on the filesystem, so concurrent
+// storage providers sharing the same root cannot both acquire a lock.
+var lockStripes [64]sync.Mutex
+
+func lockStripe(id string) *sync.Mutex {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return &lockStripes[h.Sum32()%uint32(len(lockStripes))]
+}
+
+// LockFilePath returns the internal path of the lock file of the node.
+func (n *Node) LockFilePath() string {
+	return n.InternalPath() + LockFileSuffix
+}
+
+// lockIndexPath returns the folder holding an entry per locked node, to
+// find the locks below a folder without walking it.
+func (n *Node) lockIndexPath() string {
+	return filepath.Join(n.lu.InternalRoot(), "locks")
+}
+
+// ReadLock returns the lock of the node. Expired locks are removed and
+// reported as not found.
+func (n *Node) ReadLock(ctx context.Context) (*provider.Lock, error) {
+	m := lockStripe(n.ID)
+	m.Lock()
+	defer m.Unlock()
+	return n.readLock()
+}
+
+// SetLock puts a new lock on the node. It fails if the node is already locked.
+func (n *Node) SetLock(ctx context.Context, lock *provider.Lock) error {
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+
+	m := lockStripe(n.ID)
+	m.Lock()
+	defer m.Unlock()
+
+	switch oldLock, err := n.readLock(); err.(type) {
+	case nil:
+		return errtypes.Locked(oldLock.LockId)
+	case errtypes.NotFound:
+	default:
+		return err
+	}
+
+	return n.writeLock(lock, false)
+}
+
+// RefreshLock replaces the existing lock of the node with the given one.
+// When existingLockID is set it has to match the id of the current lock,
+// otherwise the id of the given lock has to.
+func (n *Node) RefreshLock(ctx context.Context, lock *provider.Lock, existingLockID string) error {
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+
+	m := lockStripe(n.ID)
+	m.Lock()
+	defer m.Unlock()
+
+	oldLock, err := n.readLock()
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return errtypes.BadRequest("file was not locked")
+	default:
+		return err
+	}
+
+	if err := locks.CheckRefresh(oldLock, lock, existingLockID); err != nil {
+		return err
+	}
+
+	return n.writeLock(lock, true)
+}
+
+// Unlock removes the given lock from the node.
+func (n *Node) Unlock(ctx context.Context, lock *provider.Lock) error {
+	m := lockStripe(n.ID)
+	m.Lock()
+	defer m.Unlock()
+
+	oldLock, err := n.readLock()
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return errtypes.BadRequest("file was not locked")
+	default:
+		return err
+	}
+
+	if err := locks.CheckUnlock(oldLock, lock); err != nil {
+		return err
+	}
+
+	return n.RemoveLock()
+}
+
+// RemoveLock deletes the lock file of the node, if any.
+func (n *Node) RemoveLock() error {
+	if err := os.Remove(n.LockFilePath()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Decomposedfs: could not remove lock file")
+	}
+	if err := os.Remove(filepath.Join(n.lockIndexPath(), n.ID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "Decomposedfs: could not remove lock index entry")
+	}
+	return nil
+}
+
+// CheckLock verifies that the lock id in the context permits modifying the node.
+func (n *Node) CheckLock(ctx context.Context) error {
+	lock, err := n.ReadLock(ctx)
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return nil
+	default:
+		return err
+	}
+	return locks.CheckWrite(ctx, lock)
+}
+
+// CheckTreeLocks verifies that the lock id in the context permits modifying
+// the node and everything below it. The locked nodes are looked up in the
+// lock index and matched by their ancestors, rather than walking the tree.
+func (n *Node) CheckTreeLocks(ctx context.Context) error {
+	if err := n.CheckLock(ctx); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(n.lockIndexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "Decomposedfs: could not read lock index")
+	}
+	for _, e := range entries {
+		if e.Name() == n.ID {
+			continue
+		}
+		locked := New(e.Name(), "", "", 0, "", nil, n.lu)
+		below, err := locked.isBelow(n.ID)
+		if err != nil {
+			if isNotFound(err) {
+				// the locked node is gone
+				_ = os.Remove(filepath.Join(n.lockIndexPath(), e.Name()))
+				continue
+			}
+			return err
+		}
+		if !below {
+			continue
+		}
+		if err := locked.CheckLock(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBelow tells whether the node is a descendant of the node with the given id.
+func (n *Node) isBelow(ancestorID string) (bool, error) {
+	id := n.ID
+	for {
+		parentID, err := xattr.Get(n.lu.InternalPath(id), xattrs.ParentidAttr)
+		if err != nil {
+			if id != n.ID && isAttrUnset(err) {
+				// reached the root
+				return false, nil
+			}
+			return false, err
+		}
+		id = string(parentID)
+		if id == ancestorID {
+			return true, nil
+		}
+		if id == "" || id == "root" {
+			return false, nil
+		}
+	}
+}
+
+// CheckReadLock verifies that the lock id in the context permits reading the node.
+func (n *Node) CheckReadLock(ctx context.Context) error {
+	lock, err := n.ReadLock(ctx)
+	switch err.(type) {
+	case nil:
+	case errtypes.NotFound:
+		return nil
+	default:
+		return err
+	}
+	return locks.CheckRead(ctx, lock)
+}
+
+func (n *Node) readLock() (*provider.Lock, error) {
+	lockPath := n.LockFilePath()
+	b, err := os.ReadFile(lockPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errtypes.NotFound("lock not found for node " + n.ID)
+		}
+		return nil, errors.Wrap(err, "Decomposedfs: could not read lock file")
+	}
+
+	lock := &provider.Lock{}
+	if err := utils.UnmarshalJSONToProtoV1(b, lock); err != nil {
+		return nil, errors.Wrap(err, "Decomposedfs: could not decode lock file")
+	}
+
+	if locks.IsExpired(lock) {
+		if err := n.RemoveLock(); err != nil {
+			return nil, err
+		}
+		return nil, errtypes.NotFound("lock not found for node " + n.ID)
+	}
+	return lock, nil
+}
+
+// writeLock writes the lock to a temporary file before moving it in place,
+// so readers never see a partially written lock. New locks are linked in
+// place, which fails if another process created a lock in the meantime.
+func (n *Node) writeLock(lock *provider.Lock, replace bool) error {
+	b, err := utils.MarshalProtoV1ToJSON(lock)
+	if err != nil {
+		return errors.Wrap(err, "Decomposedfs: could not encode lock")
+	}
+
+	lockPath := n.LockFilePath()
+	tmpPath := filepath.Join(filepath.Dir(lockPath), "."+filepath.Base(lockPath)+"."+uuid.New().String())
+	if err := os.WriteFile(tmpPath, b, 0600); err != nil {
+		return errors.Wrap(err, "Decomposedfs: could not write lock file")
+	}
+
+	if replace {
+		if err := os.Rename(tmpPath, lockPath); err != nil {
+			_ = os.Remove(tmpPath)
+			return errors.Wrap(err, "Decomposedfs: could not replace lock file")
+		}
+		return nil
+	}
+
+	defer os.Remove(tmpPath)
+	if err := os.Link(tmpPath, lockPath); err != nil {
+		if os.IsExist(err) {
+			var lockID string
+			if existing, err := n.readLock(); err == nil {
+				lockID = existing.LockId
+			}
+			return errtypes.Locked(lockID)
+		}
+		return errors.Wrap(err, "Decomposedfs: could not create lock file")
+	}
+	return n.indexLock()
+}
+
+// indexLock adds the node to the lock index.
+func (n *Node) indexLock() error {
+	if err := os.MkdirAll(n.lockIndexPath(), 0700); err != nil {
+		return errors.Wrap(err, "Decomposedfs: could not create lock index")
+	}
+	f, err := os.OpenFile(filepath.Join(n.lockIndexPath(), n.ID), os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "Decomposedfs: could not add lock index entry")
+	}
+	return f.Close()
+}
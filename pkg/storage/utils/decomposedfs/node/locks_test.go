@@ -0,0 +1,192 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package node_test
+
+import (
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/decomposedfs/node"
+	helpers "github.com/cs3org/reva/pkg/storage/utils/decomposedfs/testhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Node locks", func() {
+	var (
+		env *helpers.TestEnv
+
+		n    *node.Node
+		lock *provider.Lock
+	)
+
+	BeforeEach(func() {
+		var err error
+		env, err = helpers.NewTestEnv()
+		Expect(err).ToNot(HaveOccurred())
+
+		n, err = env.Lookup.NodeFromPath(env.Ctx, "/dir1/file1", false)
+		Expect(err).ToNot(HaveOccurred())
+
+		lock = &provider.Lock{
+			Type:   provider.LockType_LOCK_TYPE_WRITE,
+			User:   env.Owner.Id,
+			LockId: "lock-id",
+		}
+	})
+
+	AfterEach(func() {
+		if env != nil {
+			env.Cleanup()
+		}
+	})
+
+	Describe("SetLock", func() {
+		It("stores the lock", func() {
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			l, err := n.ReadLock(env.Ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(l.LockId).To(Equal("lock-id"))
+			Expect(l.Type).To(Equal(provider.LockType_LOCK_TYPE_WRITE))
+		})
+
+		It("refuses to lock a locked node", func() {
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			other := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "other"}
+			Expect(n.SetLock(env.Ctx, other)).To(MatchError(errtypes.Locked("lock-id")))
+		})
+
+		It("replaces an expired lock", func() {
+			expiration := time.Now().Add(50 * time.Millisecond)
+			lock.Expiration = &types.Timestamp{Seconds: uint64(expiration.Unix()), Nanos: uint32(expiration.Nanosecond())}
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			time.Sleep(time.Until(expiration))
+
+			_, err := n.ReadLock(env.Ctx)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+
+			other := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "other"}
+			Expect(n.SetLock(env.Ctx, other)).To(Succeed())
+		})
+
+		It("rejects invalid locks", func() {
+			Expect(n.SetLock(env.Ctx, &provider.Lock{LockId: "lock-id"})).ToNot(Succeed())
+			Expect(n.SetLock(env.Ctx, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE})).ToNot(Succeed())
+		})
+	})
+
+	Describe("RefreshLock", func() {
+		JustBeforeEach(func() {
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+		})
+
+		It("replaces the lock", func() {
+			refreshed := &provider.Lock{
+				Type:       provider.LockType_LOCK_TYPE_WRITE,
+				User:       env.Owner.Id,
+				LockId:     "new-lock-id",
+				Expiration: &types.Timestamp{Seconds: uint64(time.Now().Add(time.Hour).Unix())},
+			}
+			Expect(n.RefreshLock(env.Ctx, refreshed, "lock-id")).To(Succeed())
+
+			l, err := n.ReadLock(env.Ctx)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(l.LockId).To(Equal("new-lock-id"))
+		})
+
+		It("checks the existing lock id", func() {
+			Expect(n.RefreshLock(env.Ctx, lock, "wrong")).To(MatchError(errtypes.Locked("lock-id")))
+		})
+
+		It("checks the lock holder", func() {
+			lock.User = &userpb.UserId{OpaqueId: "someone-else"}
+			Expect(n.RefreshLock(env.Ctx, lock, "")).To(MatchError(errtypes.Locked("lock-id")))
+		})
+	})
+
+	Describe("Unlock", func() {
+		JustBeforeEach(func() {
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+		})
+
+		It("removes the lock", func() {
+			Expect(n.Unlock(env.Ctx, lock)).To(Succeed())
+
+			_, err := n.ReadLock(env.Ctx)
+			Expect(err).To(BeAssignableToTypeOf(errtypes.NotFound("")))
+		})
+
+		It("checks the lock id", func() {
+			Expect(n.Unlock(env.Ctx, &provider.Lock{LockId: "wrong", User: env.Owner.Id})).ToNot(Succeed())
+		})
+
+		It("checks the app name", func() {
+			Expect(n.Unlock(env.Ctx, lock)).To(Succeed())
+			lock.AppName = "Collabora"
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			Expect(n.Unlock(env.Ctx, &provider.Lock{LockId: "lock-id", User: env.Owner.Id, AppName: "OnlyOffice"})).
+				To(MatchError(errtypes.Locked("lock-id")))
+		})
+	})
+
+	Describe("CheckLock", func() {
+		It("allows writes to unlocked nodes", func() {
+			Expect(n.CheckLock(env.Ctx)).To(Succeed())
+		})
+
+		It("requires the lock id for write locks", func() {
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			Expect(n.CheckLock(env.Ctx)).To(MatchError(errtypes.Locked("lock-id")))
+			Expect(n.CheckLock(ctxpkg.ContextSetLockID(env.Ctx, "lock-id"))).To(Succeed())
+			Expect(n.CheckReadLock(env.Ctx)).To(Succeed())
+		})
+
+		It("ignores shared locks", func() {
+			lock.Type = provider.LockType_LOCK_TYPE_SHARED
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			Expect(n.CheckLock(env.Ctx)).To(Succeed())
+		})
+
+		It("restricts reads for exclusive locks", func() {
+			lock.Type = provider.LockType_LOCK_TYPE_EXCL
+			lock.User = &userpb.UserId{OpaqueId: "someone-else"}
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			Expect(n.CheckReadLock(env.Ctx)).To(MatchError(errtypes.Locked("lock-id")))
+			Expect(n.CheckReadLock(ctxpkg.ContextSetLockID(env.Ctx, "lock-id"))).To(Succeed())
+		})
+
+		It("lets the holder of an exclusive lock read", func() {
+			lock.Type = provider.LockType_LOCK_TYPE_EXCL
+			Expect(n.SetLock(env.Ctx, lock)).To(Succeed())
+
+			Expect(n.CheckReadLock(env.Ctx)).To(Succeed())
+		})
+	})
+})
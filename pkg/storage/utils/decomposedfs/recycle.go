@@ -20,6 +20,7 @@ package decomposedfs
 
 import (
 	"context"
+	"io"
 	"os"
 	"path"
 	"path/filepath"
@@ -314,6 +315,30 @@ func (fs *Decomposedfs) PurgeRecycleItem(ctx context.Context, basePath, key, rel
 	return purgeFunc()
 }
 
+// DownloadRecycleItem returns the content of a file of the recycle bin.
+func (fs *Decomposedfs) DownloadRecycleItem(ctx context.Context, basePath, key, relativePath string) (io.ReadCloser, error) {
+	rn, err := fs.tp.ReadRecycleItem(ctx, key, relativePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// check permissions of deleted node
+	ok, err := fs.p.HasPermission(ctx, rn, func(rp *provider.ResourcePermissions) bool {
+		return rp.ListRecycle
+	})
+	switch {
+	case err != nil:
+		return nil, errtypes.InternalError(err.Error())
+	case !ok:
+		return nil, errtypes.PermissionDenied(key)
+	}
+
+	if rn.BlobID == "" {
+		return nil, errtypes.BadRequest("recycle item is not a file: " + key)
+	}
+	return fs.tp.ReadBlob(rn.BlobID)
+}
+
 // EmptyRecycle empties the trash.
 func (fs *Decomposedfs) EmptyRecycle(ctx context.Context) error {
 	u, ok := ctxpkg.ContextGetUser(ctx)
@@ -35,6 +35,7 @@ import (
 	ocsconv "github.com/cs3org/reva/internal/http/services/owncloud/ocs/conversions"
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/permission"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/storage/utils/decomposedfs/node"
 	"github.com/cs3org/reva/pkg/storage/utils/decomposedfs/xattrs"
@@ -208,7 +209,7 @@ func (fs *Decomposedfs) ListStorageSpaces(ctx context.Context, filter []*provide
 	}
 
 	checkRes, err := client.CheckPermission(ctx, &permissionsv1beta1.CheckPermissionRequest{
-		Permission: "list-all-spaces",
+		Permission: permission.ListAllSpaces,
 		SubjectRef: &permissionsv1beta1.SubjectReference{
 			Spec: &permissionsv1beta1.SubjectReference_UserId{
 				UserId: u.Id,
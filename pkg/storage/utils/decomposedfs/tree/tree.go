@@ -445,6 +445,11 @@ func (t *Tree) Delete(ctx context.Context, n *node.Node) (err error) {
 		return
 	}
 
+	// locks are not kept in the trash
+	if err = n.RemoveLock(); err != nil {
+		return
+	}
+
 	return t.Propagate(ctx, n)
 }
 
@@ -568,6 +573,12 @@ func (t *Tree) PurgeRecycleItemFunc(ctx context.Context, key string, path string
 	return rn, fn, nil
 }
 
+// ReadRecycleItem returns the node of an item of the recycle bin.
+func (t *Tree) ReadRecycleItem(ctx context.Context, key, path string) (*node.Node, error) {
+	rn, _, _, _, err := t.readRecycleItem(ctx, key, path)
+	return rn, err
+}
+
 // Propagate propagates changes to the root of the tree.
 func (t *Tree) Propagate(ctx context.Context, n *node.Node) (err error) {
 	sublog := appctx.GetLogger(ctx).With().Interface("node", n).Logger()
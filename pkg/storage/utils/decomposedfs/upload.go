@@ -128,9 +128,15 @@ func (fs *Decomposedfs) InitiateUpload(ctx context.Context, ref *provider.Refere
 		if metadata["mtime"] != "" {
 			info.MetaData["mtime"] = metadata["mtime"]
 		}
+		if metadata["providerID"] != "" {
+			info.Storage["ProviderId"] = metadata["providerID"]
+		}
 		if _, ok := metadata["sizedeferred"]; ok {
 			info.SizeIsDeferred = true
 		}
+		if metadata[ctxpkg.LockIDHeader] != "" {
+			info.MetaData[ctxpkg.LockIDHeader] = metadata[ctxpkg.LockIDHeader]
+		}
 		if metadata["checksum"] != "" {
 			parts := strings.SplitN(metadata["checksum"], " ", 2)
 			if len(parts) != 2 {
@@ -145,6 +151,10 @@ func (fs *Decomposedfs) InitiateUpload(ctx context.Context, ref *provider.Refere
 		}
 	}
 
+	if lockID, ok := ctxpkg.ContextGetLockID(ctx); ok && info.MetaData[ctxpkg.LockIDHeader] == "" {
+		info.MetaData[ctxpkg.LockIDHeader] = lockID
+	}
+
 	log.Debug().Interface("info", info).Interface("node", n).Interface("metadata", metadata).Msg("Decomposedfs: resolved filename")
 
 	_, err = node.CheckQuota(n.SpaceRoot, uint64(info.Size))
@@ -227,6 +237,13 @@ func (fs *Decomposedfs) NewUpload(ctx context.Context, info tusd.FileInfo) (uplo
 		return nil, errtypes.PermissionDenied(filepath.Join(n.ParentID, n.Name))
 	}
 
+	// check the lock of the file to be overwritten
+	if n.Exists {
+		if err := n.CheckLock(ctxpkg.ContextSetLockID(ctx, info.MetaData[ctxpkg.LockIDHeader])); err != nil {
+			return nil, err
+		}
+	}
+
 	info.ID = uuid.New().String()
 
 	binPath, err := fs.getUploadPath(ctx, info.ID)
@@ -239,8 +256,9 @@ func (fs *Decomposedfs) NewUpload(ctx context.Context, info tusd.FileInfo) (uplo
 	if err != nil {
 		return nil, errors.Wrap(err, "decomposedfs: error determining owner")
 	}
-	var spaceRoot string
+	var spaceRoot, providerID string
 	if info.Storage != nil {
+		providerID = info.Storage["ProviderId"]
 		if spaceRoot, ok = info.Storage["SpaceRoot"]; !ok {
 			spaceRoot = n.SpaceRoot.ID
 		}
@@ -256,6 +274,7 @@ func (fs *Decomposedfs) NewUpload(ctx context.Context, info tusd.FileInfo) (uplo
 		"NodeParentId": n.ParentID,
 		"NodeName":     n.Name,
 		"SpaceRoot":    spaceRoot,
+		"ProviderId":   providerID,
 
 		"Idp":      usr.Id.Idp,
 		"UserId":   usr.Id.OpaqueId,
@@ -470,6 +489,12 @@ func (upload *fileUpload) FinishUpload(ctx context.Context) (err error) {
 	if n.ID == "" {
 		n.ID = uuid.New().String()
 	}
+
+	// the file might have been locked while the upload was in progress
+	if err = n.CheckLock(ctxpkg.ContextSetLockID(upload.ctx, upload.info.MetaData[ctxpkg.LockIDHeader])); err != nil {
+		return err
+	}
+
 	targetPath := n.InternalPath()
 	sublog := appctx.GetLogger(upload.ctx).
 		With().
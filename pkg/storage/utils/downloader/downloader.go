@@ -27,6 +27,7 @@ import (
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/datagateway"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rhttp"
@@ -60,21 +61,109 @@ func getDownloadProtocol(protocols []*gateway.FileDownloadProtocol, prot string)
 	return nil, errtypes.InternalError(fmt.Sprintf("protocol %s not supported for downloading", prot))
 }
 
+// RevisionDownloader is implemented by the Downloaders able to
+// list and download the revisions of a file.
+type RevisionDownloader interface {
+	ListRevisions(ctx context.Context, path string) ([]*provider.FileVersion, error)
+	DownloadRevision(ctx context.Context, path, key string, dst io.Writer) error
+}
+
+// RecycleDownloader is implemented by the Downloaders able to
+// list and download the items of the recycle bin.
+type RecycleDownloader interface {
+	ListRecycle(ctx context.Context, path, key string) ([]*provider.RecycleItem, error)
+	DownloadRecycleItem(ctx context.Context, path, key string, dst io.Writer) error
+}
+
 // Download downloads a resource given the path to the dst Writer.
 func (r *revaDownloader) Download(ctx context.Context, path string, dst io.Writer) error {
-	downResp, err := r.gtw.InitiateFileDownload(ctx, &provider.InitiateFileDownloadRequest{
+	return r.download(ctx, &provider.InitiateFileDownloadRequest{
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	}, path, dst)
+}
+
+// ListRevisions lists the revisions of the file with the given path.
+func (r *revaDownloader) ListRevisions(ctx context.Context, path string) ([]*provider.FileVersion, error) {
+	res, err := r.gtw.ListFileVersions(ctx, &provider.ListFileVersionsRequest{
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	})
+
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return nil, errtypes.InternalError(res.Status.Message)
+	}
+	return res.Versions, nil
+}
+
+// DownloadRevision downloads the revision with the given key of a file to the dst Writer.
+func (r *revaDownloader) DownloadRevision(ctx context.Context, path, key string, dst io.Writer) error {
+	return r.download(ctx, &provider.InitiateFileDownloadRequest{
+		Opaque: &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				"revision": {
+					Decoder: "plain",
+					Value:   []byte(key),
+				},
+			},
+		},
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	}, path, dst)
+}
+
+// ListRecycle lists the recycle items of the storage of the given path,
+// or the content of the recycle item with the given key.
+func (r *revaDownloader) ListRecycle(ctx context.Context, path, key string) ([]*provider.RecycleItem, error) {
+	res, err := r.gtw.ListRecycle(ctx, &provider.ListRecycleRequest{
 		Ref: &provider.Reference{
 			Path: path,
 		},
+		Key: key,
 	})
 
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return nil, errtypes.InternalError(res.Status.Message)
+	}
+	return res.RecycleItems, nil
+}
+
+// DownloadRecycleItem downloads the recycle item with the given key
+// of the storage of the given path to the dst Writer.
+func (r *revaDownloader) DownloadRecycleItem(ctx context.Context, path, key string, dst io.Writer) error {
+	return r.download(ctx, &provider.InitiateFileDownloadRequest{
+		Opaque: &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				"recycle": {
+					Decoder: "plain",
+					Value:   []byte(key),
+				},
+			},
+		},
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	}, path, dst)
+}
+
+func (r *revaDownloader) download(ctx context.Context, req *provider.InitiateFileDownloadRequest, path string, dst io.Writer) error {
+	downResp, err := r.gtw.InitiateFileDownload(ctx, req)
+
 	switch {
 	case err != nil:
 		return err
 	case downResp.Status.Code != rpc.Code_CODE_OK:
 		return errtypes.InternalError(downResp.Status.Message)
 	}
-
 	p, err := getDownloadProtocol(downResp.Protocols, "simple")
 	if err != nil {
 		return err
@@ -22,9 +22,12 @@ import (
 	"context"
 	"database/sql"
 	"path"
+	"time"
 
-	// Provides sqlite drivers.
-	_ "github.com/mattn/go-sqlite3"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 )
 
@@ -71,6 +74,15 @@ func initializeDB(root, dbName string) (*sql.DB, error) {
 		return nil, errors.Wrap(err, "localfs: error executing create statement")
 	}
 
+	stmt, err = db.Prepare("CREATE TABLE IF NOT EXISTS locks (resource TEXT PRIMARY KEY, lock_id TEXT, payload TEXT, expiration INTEGER DEFAULT 0)")
+	if err != nil {
+		return nil, errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec()
+	if err != nil {
+		return nil, errors.Wrap(err, "localfs: error executing create statement")
+	}
+
 	return db, nil
 }
 
@@ -243,5 +255,160 @@ func (fs *localfs) copyMD(s string, t string) (err error) {
 	if err != nil {
 		return errors.Wrap(err, "localfs: error executing delete statement")
 	}
+
+	// the locks of the resources below a folder move along with it
+	stmt, err = fs.db.Prepare("UPDATE locks SET resource=? || substr(resource, length(?)+1) WHERE resource=? OR substr(resource, 1, length(?))=?")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec(t, s, s, s+"/", s+"/")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error executing update statement")
+	}
+	return nil
+}
+
+// addToLocksDB stores a new lock for the resource. Expired locks are dropped
+// first, the primary key on the resource guarantees that only one of several
+// concurrent callers can acquire the lock.
+func (fs *localfs) addToLocksDB(ctx context.Context, resource string, lock *provider.Lock) error {
+	payload, err := utils.MarshalProtoV1ToJSON(lock)
+	if err != nil {
+		return errors.Wrap(err, "localfs: error encoding lock")
+	}
+
+	if err := fs.removeExpiredLocks(ctx, resource); err != nil {
+		return err
+	}
+
+	stmt, err := fs.db.Prepare("INSERT INTO locks (resource, lock_id, payload, expiration) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec(resource, lock.LockId, string(payload), lockExpiration(lock))
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+			var lockID string
+			if existing, err := fs.getLockEntry(ctx, resource); err == nil {
+				lockID = existing.LockId
+			}
+			return errtypes.Locked(lockID)
+		}
+		return errors.Wrap(err, "localfs: error executing insert statement")
+	}
+	return nil
+}
+
+func (fs *localfs) updateLockDB(ctx context.Context, resource, existingLockID string, lock *provider.Lock) error {
+	payload, err := utils.MarshalProtoV1ToJSON(lock)
+	if err != nil {
+		return errors.Wrap(err, "localfs: error encoding lock")
+	}
+
+	stmt, err := fs.db.Prepare("UPDATE locks SET lock_id=?, payload=?, expiration=? WHERE resource=? AND lock_id=?")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	res, err := stmt.Exec(lock.LockId, string(payload), lockExpiration(lock), resource, existingLockID)
+	if err != nil {
+		return errors.Wrap(err, "localfs: error executing update statement")
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errtypes.Locked(existingLockID)
+	}
+	return nil
+}
+
+func (fs *localfs) getLockEntry(ctx context.Context, resource string) (*provider.Lock, error) {
+	if err := fs.removeExpiredLocks(ctx, resource); err != nil {
+		return nil, err
+	}
+
+	var payload string
+	err := fs.db.QueryRow("SELECT payload FROM locks WHERE resource=?", resource).Scan(&payload)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errtypes.NotFound("lock not found for " + resource)
+		}
+		return nil, errors.Wrap(err, "localfs: error querying lock")
+	}
+
+	lock := &provider.Lock{}
+	if err := utils.UnmarshalJSONToProtoV1([]byte(payload), lock); err != nil {
+		return nil, errors.Wrap(err, "localfs: error decoding lock")
+	}
+	return lock, nil
+}
+
+// getLockEntriesBelow returns the locks held on the resources below the folder.
+func (fs *localfs) getLockEntriesBelow(ctx context.Context, resource string) ([]*provider.Lock, error) {
+	prefix := resource + "/"
+	rows, err := fs.db.Query("SELECT payload FROM locks WHERE substr(resource, 1, length(?))=? AND (expiration = 0 OR expiration > ?)", prefix, prefix, time.Now().UnixNano())
+	if err != nil {
+		return nil, errors.Wrap(err, "localfs: error querying locks")
+	}
+	defer rows.Close()
+
+	var entries []*provider.Lock
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, errors.Wrap(err, "localfs: error scanning lock")
+		}
+		lock := &provider.Lock{}
+		if err := utils.UnmarshalJSONToProtoV1([]byte(payload), lock); err != nil {
+			return nil, errors.Wrap(err, "localfs: error decoding lock")
+		}
+		entries = append(entries, lock)
+	}
+	return entries, rows.Err()
+}
+
+// removeFromLocksDB removes the lock of the resource, the locks of the
+// resources below it are kept as they may be held by others.
+func (fs *localfs) removeFromLocksDB(ctx context.Context, resource string) error {
+	stmt, err := fs.db.Prepare("DELETE FROM locks WHERE resource=?")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec(resource)
+	if err != nil {
+		return errors.Wrap(err, "localfs: error executing delete statement")
+	}
 	return nil
 }
+
+// removeTreeFromLocksDB removes the lock of the resource and the locks of the
+// resources below it, once they are gone.
+func (fs *localfs) removeTreeFromLocksDB(ctx context.Context, resource string) error {
+	stmt, err := fs.db.Prepare("DELETE FROM locks WHERE resource=? OR substr(resource, 1, length(?))=?")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec(resource, resource+"/", resource+"/")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error executing delete statement")
+	}
+	return nil
+}
+
+func (fs *localfs) removeExpiredLocks(ctx context.Context, resource string) error {
+	stmt, err := fs.db.Prepare("DELETE FROM locks WHERE resource=? AND expiration > 0 AND expiration <= ?")
+	if err != nil {
+		return errors.Wrap(err, "localfs: error preparing statement")
+	}
+	_, err = stmt.Exec(resource, time.Now().UnixNano())
+	if err != nil {
+		return errors.Wrap(err, "localfs: error executing delete statement")
+	}
+	return nil
+}
+
+// lockExpiration returns the expiration of the lock in nanoseconds, 0 if the lock does not expire.
+func lockExpiration(lock *provider.Lock) int64 {
+	if lock.Expiration == nil {
+		return 0
+	}
+	return utils.TSToTime(lock.Expiration).UnixNano()
+}
@@ -37,11 +37,13 @@ import (
 	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/health"
 	"github.com/cs3org/reva/pkg/mime"
 	"github.com/cs3org/reva/pkg/storage"
 	"github.com/cs3org/reva/pkg/storage/utils/acl"
 	"github.com/cs3org/reva/pkg/storage/utils/chunking"
 	"github.com/cs3org/reva/pkg/storage/utils/grants"
+	"github.com/cs3org/reva/pkg/storage/utils/locks"
 	"github.com/cs3org/reva/pkg/storage/utils/templates"
 	"github.com/cs3org/reva/pkg/utils"
 	"github.com/pkg/errors"
@@ -135,6 +137,14 @@ func (fs *localfs) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Ready tells whether the root is writable and the database reachable.
+func (fs *localfs) Ready(ctx context.Context) error {
+	if err := health.Writable(fs.conf.Root); err != nil {
+		return err
+	}
+	return fs.db.PingContext(ctx)
+}
+
 func (fs *localfs) resolve(ctx context.Context, ref *provider.Reference) (p string, err error) {
 	if ref.ResourceId != nil {
 		if p, err = fs.GetPathByID(ctx, ref.ResourceId); err != nil {
@@ -591,6 +601,10 @@ func (fs *localfs) SetArbitraryMetadata(ctx context.Context, ref *provider.Refer
 		return errors.Wrap(err, "localfs: error stating "+np)
 	}
 
+	if err := fs.checkLock(ctx, np); err != nil {
+		return err
+	}
+
 	if md.Metadata != nil {
 		if val, ok := md.Metadata["mtime"]; ok {
 			if mtime, err := parseMTime(val); err == nil {
@@ -677,6 +691,10 @@ func (fs *localfs) UnsetArbitraryMetadata(ctx context.Context, ref *provider.Ref
 		return errors.Wrap(err, "localfs: error stating "+np)
 	}
 
+	if err := fs.checkLock(ctx, np); err != nil {
+		return err
+	}
+
 	for _, k := range keys {
 		switch k {
 		case "favorite":
@@ -709,22 +727,141 @@ func (fs *localfs) UnsetArbitraryMetadata(ctx context.Context, ref *provider.Ref
 
 // GetLock returns an existing lock on the given reference.
 func (fs *localfs) GetLock(ctx context.Context, ref *provider.Reference) (*provider.Lock, error) {
-	return nil, errtypes.NotSupported("unimplemented")
+	np, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return fs.getLockEntry(ctx, np)
 }
 
 // SetLock puts a lock on the given reference.
 func (fs *localfs) SetLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+
+	np, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return fs.addToLocksDB(ctx, np, lock)
 }
 
 // RefreshLock refreshes an existing lock on the given reference.
 func (fs *localfs) RefreshLock(ctx context.Context, ref *provider.Reference, lock *provider.Lock, existingLockID string) error {
-	return errtypes.NotSupported("unimplemented")
+	if err := locks.Validate(lock); err != nil {
+		return err
+	}
+
+	np, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	oldLock, err := fs.getLockEntry(ctx, np)
+	if err != nil {
+		if _, ok := err.(errtypes.NotFound); ok {
+			return errtypes.BadRequest("file was not locked")
+		}
+		return err
+	}
+
+	if err := locks.CheckRefresh(oldLock, lock, existingLockID); err != nil {
+		return err
+	}
+	if existingLockID == "" {
+		existingLockID = lock.LockId
+	}
+
+	return fs.updateLockDB(ctx, np, existingLockID, lock)
 }
 
 // Unlock removes an existing lock from the given reference.
 func (fs *localfs) Unlock(ctx context.Context, ref *provider.Reference, lock *provider.Lock) error {
-	return errtypes.NotSupported("unimplemented")
+	np, err := fs.resolveLockable(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	oldLock, err := fs.getLockEntry(ctx, np)
+	if err != nil {
+		if _, ok := err.(errtypes.NotFound); ok {
+			return errtypes.BadRequest("file was not locked")
+		}
+		return err
+	}
+
+	if err := locks.CheckUnlock(oldLock, lock); err != nil {
+		return err
+	}
+
+	return fs.removeFromLocksDB(ctx, np)
+}
+
+// resolveLockable returns the internal path of an existing resource which can carry a lock.
+func (fs *localfs) resolveLockable(ctx context.Context, ref *provider.Reference) (string, error) {
+	fn, err := fs.resolve(ctx, ref)
+	if err != nil {
+		return "", errors.Wrap(err, "localfs: error resolving ref")
+	}
+
+	if fs.isShareFolder(ctx, fn) {
+		return "", errtypes.PermissionDenied("localfs: cannot lock under the virtual share folder")
+	}
+
+	np := fs.wrap(ctx, fn)
+	if _, err := os.Stat(np); err != nil {
+		if os.IsNotExist(err) {
+			return "", errtypes.NotFound(fn)
+		}
+		return "", errors.Wrap(err, "localfs: error stating "+np)
+	}
+	return np, nil
+}
+
+// checkLock verifies that the lock id in the context permits writing to
+// the resource at the internal path np.
+func (fs *localfs) checkLock(ctx context.Context, np string) error {
+	lock, err := fs.getLockEntry(ctx, np)
+	if err != nil {
+		if _, ok := err.(errtypes.NotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return locks.CheckWrite(ctx, lock)
+}
+
+// checkTreeLocks verifies that the lock id in the context permits writing
+// to the resource at the internal path np and to everything below it.
+func (fs *localfs) checkTreeLocks(ctx context.Context, np string) error {
+	if err := fs.checkLock(ctx, np); err != nil {
+		return err
+	}
+
+	children, err := fs.getLockEntriesBelow(ctx, np)
+	if err != nil {
+		return err
+	}
+	for _, lock := range children {
+		if err := locks.CheckWrite(ctx, lock); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkReadLock verifies that the lock id in the context permits reading
+// the resource at the internal path np.
+func (fs *localfs) checkReadLock(ctx context.Context, np string) error {
+	lock, err := fs.getLockEntry(ctx, np)
+	if err != nil {
+		if _, ok := err.(errtypes.NotFound); ok {
+			return nil
+		}
+		return err
+	}
+	return locks.CheckRead(ctx, lock)
 }
 
 func (fs *localfs) GetHome(ctx context.Context) (string, error) {
@@ -827,6 +964,10 @@ func (fs *localfs) Delete(ctx context.Context, ref *provider.Reference) error {
 		return errors.Wrap(err, "localfs: error stating "+fp)
 	}
 
+	if err := fs.checkTreeLocks(ctx, fp); err != nil {
+		return err
+	}
+
 	key := fmt.Sprintf("%s.d%d", path.Base(fn), time.Now().UnixNano()/int64(time.Millisecond))
 	if err := os.Rename(fp, fs.wrapRecycleBin(ctx, key)); err != nil {
 		return errors.Wrap(err, "localfs: could not delete item")
@@ -837,6 +978,11 @@ func (fs *localfs) Delete(ctx context.Context, ref *provider.Reference) error {
 		return errors.Wrap(err, "localfs: error adding entry to DB")
 	}
 
+	// locks are not kept in the recycle bin
+	if err := fs.removeTreeFromLocksDB(ctx, fp); err != nil {
+		return err
+	}
+
 	return fs.propagate(ctx, path.Dir(fp))
 }
 
@@ -858,10 +1004,27 @@ func (fs *localfs) Move(ctx context.Context, oldRef, newRef *provider.Reference)
 	oldName = fs.wrap(ctx, oldName)
 	newName = fs.wrap(ctx, newName)
 
+	if err := fs.checkTreeLocks(ctx, oldName); err != nil {
+		return err
+	}
+	// a replaced target must not be locked
+	if err := fs.checkTreeLocks(ctx, newName); err != nil {
+		return err
+	}
+	// a lock on the target folder protects its members
+	if err := fs.checkLock(ctx, path.Dir(newName)); err != nil {
+		return err
+	}
+
 	if err := os.Rename(oldName, newName); err != nil {
 		return errors.Wrap(err, "localfs: error moving "+oldName+" to "+newName)
 	}
 
+	// the expired locks of a replaced target are left over
+	if err := fs.removeTreeFromLocksDB(ctx, newName); err != nil {
+		return err
+	}
+
 	if err := fs.copyMD(oldName, newName); err != nil {
 		return errors.Wrap(err, "localfs: error copying metadata")
 	}
@@ -1058,6 +1221,10 @@ func (fs *localfs) Download(ctx context.Context, ref *provider.Reference) (io.Re
 	}
 
 	fn = fs.wrap(ctx, fn)
+	if err := fs.checkReadLock(ctx, fn); err != nil {
+		return nil, err
+	}
+
 	r, err := os.Open(fn)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -0,0 +1,258 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package localfs
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage"
+)
+
+func newTestFS(t *testing.T) (storage.FS, context.Context, *provider.Reference) {
+	root := t.TempDir()
+	fs, err := NewLocalFS(&Config{Root: root, DisableHome: true})
+	if err != nil {
+		t.Fatalf("error creating localfs: %v", err)
+	}
+	t.Cleanup(func() { _ = fs.Shutdown(context.Background()) })
+
+	if err := os.WriteFile(path.Join(root, "data", "file.txt"), []byte("content"), 0600); err != nil {
+		t.Fatalf("error creating test file: %v", err)
+	}
+
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id:       &userpb.UserId{Idp: "idp", OpaqueId: "einstein"},
+		Username: "einstein",
+	})
+	return fs, ctx, &provider.Reference{Path: "/file.txt"}
+}
+
+func TestSetAndGetLock(t *testing.T) {
+	fs, ctx, ref := newTestFS(t)
+
+	lock := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id", AppName: "app"}
+	if err := fs.SetLock(ctx, ref, lock); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	if err := fs.SetLock(ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "other"}); err != errtypes.Locked("lock-id") {
+		t.Fatalf("SetLock returned %v on a locked resource, expected a locked error", err)
+	}
+
+	got, err := fs.GetLock(ctx, ref)
+	if err != nil {
+		t.Fatalf("GetLock failed: %v", err)
+	}
+	if got.LockId != "lock-id" || got.AppName != "app" {
+		t.Fatalf("GetLock returned %v, expected %v", got, lock)
+	}
+}
+
+func TestLockSurvivesRestart(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(path.Join(root, "data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path.Join(root, "data", "file.txt"), []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}})
+	ref := &provider.Reference{Path: "/file.txt"}
+
+	fs, err := NewLocalFS(&Config{Root: root, DisableHome: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.SetLock(ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_EXCL, LockId: "lock-id"}); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+	if err := fs.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = NewLocalFS(&Config{Root: root, DisableHome: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Shutdown(ctx)
+
+	lock, err := fs.GetLock(ctx, ref)
+	if err != nil {
+		t.Fatalf("GetLock failed after restart: %v", err)
+	}
+	if lock.LockId != "lock-id" || lock.Type != provider.LockType_LOCK_TYPE_EXCL {
+		t.Fatalf("unexpected lock after restart: %v", lock)
+	}
+}
+
+func TestRefreshAndUnlock(t *testing.T) {
+	fs, ctx, ref := newTestFS(t)
+
+	lock := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id", AppName: "app"}
+	if err := fs.SetLock(ctx, ref, lock); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	refreshed := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "new-id", AppName: "other-app"}
+	if err := fs.RefreshLock(ctx, ref, refreshed, "lock-id"); err == nil {
+		t.Fatal("RefreshLock succeeded for a different holder")
+	}
+
+	refreshed.AppName = "app"
+	if err := fs.RefreshLock(ctx, ref, refreshed, "wrong-id"); err == nil {
+		t.Fatal("RefreshLock succeeded with a wrong existing lock id")
+	}
+	if err := fs.RefreshLock(ctx, ref, refreshed, "lock-id"); err != nil {
+		t.Fatalf("RefreshLock failed: %v", err)
+	}
+
+	if err := fs.Unlock(ctx, ref, lock); err == nil {
+		t.Fatal("Unlock succeeded with an outdated lock id")
+	}
+	if err := fs.Unlock(ctx, ref, refreshed); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if _, err := fs.GetLock(ctx, ref); err == nil {
+		t.Fatal("GetLock found a removed lock")
+	}
+}
+
+func TestWritesHonorLock(t *testing.T) {
+	fs, ctx, ref := newTestFS(t)
+
+	if err := fs.SetLock(ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"}); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	md := &provider.ArbitraryMetadata{Metadata: map[string]string{"foo": "bar"}}
+	if err := fs.SetArbitraryMetadata(ctx, ref, md); err != errtypes.Locked("lock-id") {
+		t.Fatalf("SetArbitraryMetadata returned %v, expected a locked error", err)
+	}
+	if err := fs.Move(ctx, ref, &provider.Reference{Path: "/moved.txt"}); err != errtypes.Locked("lock-id") {
+		t.Fatalf("Move returned %v, expected a locked error", err)
+	}
+	if err := fs.Delete(ctx, ref); err != errtypes.Locked("lock-id") {
+		t.Fatalf("Delete returned %v, expected a locked error", err)
+	}
+
+	lockCtx := ctxpkg.ContextSetLockID(ctx, "lock-id")
+	moved := &provider.Reference{Path: "/moved.txt"}
+	if err := fs.Move(lockCtx, ref, moved); err != nil {
+		t.Fatalf("Move with lock id failed: %v", err)
+	}
+	if lock, err := fs.GetLock(ctx, moved); err != nil || lock.LockId != "lock-id" {
+		t.Fatalf("lock did not follow the moved resource: %v %v", lock, err)
+	}
+	if err := fs.Delete(lockCtx, moved); err != nil {
+		t.Fatalf("Delete with lock id failed: %v", err)
+	}
+}
+
+func TestExclusiveLockBlocksDownload(t *testing.T) {
+	fs, ctx, ref := newTestFS(t)
+
+	if err := fs.SetLock(ctx, ref, &provider.Lock{Type: provider.LockType_LOCK_TYPE_EXCL, LockId: "lock-id"}); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	if _, err := fs.Download(ctx, ref); err != errtypes.Locked("lock-id") {
+		t.Fatalf("Download returned %v, expected a locked error", err)
+	}
+
+	r, err := fs.Download(ctxpkg.ContextSetLockID(ctx, "lock-id"), ref)
+	if err != nil {
+		t.Fatalf("Download with lock id failed: %v", err)
+	}
+	r.Close()
+}
+
+func TestFolderLocksFollowTheFolder(t *testing.T) {
+	fs, ctx, _ := newTestFS(t)
+
+	dir := &provider.Reference{Path: "/dir"}
+	if err := fs.CreateDir(ctx, dir); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	if err := fs.CreateDir(ctx, &provider.Reference{Path: "/dir/sub"}); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	child := &provider.Reference{Path: "/dir/sub"}
+	if err := fs.SetLock(ctx, child, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "lock-id"}); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	if err := fs.Delete(ctx, dir); err != errtypes.Locked("lock-id") {
+		t.Fatalf("Delete returned %v, expected a locked error", err)
+	}
+
+	lockCtx := ctxpkg.ContextSetLockID(ctx, "lock-id")
+	if err := fs.Move(lockCtx, dir, &provider.Reference{Path: "/moved"}); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	if lock, err := fs.GetLock(ctx, &provider.Reference{Path: "/moved/sub"}); err != nil || lock.LockId != "lock-id" {
+		t.Fatalf("lock did not follow the moved folder: %v %v", lock, err)
+	}
+
+	if err := fs.Delete(lockCtx, &provider.Reference{Path: "/moved"}); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := fs.CreateDir(ctx, &provider.Reference{Path: "/moved"}); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	if err := fs.CreateDir(ctx, &provider.Reference{Path: "/moved/sub"}); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	if _, err := fs.GetLock(ctx, &provider.Reference{Path: "/moved/sub"}); err == nil {
+		t.Fatal("the lock of a deleted child was left behind")
+	}
+}
+
+func TestFolderUnlockKeepsChildLocks(t *testing.T) {
+	fs, ctx, _ := newTestFS(t)
+
+	dir := &provider.Reference{Path: "/dir"}
+	if err := fs.CreateDir(ctx, dir); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	if err := fs.CreateDir(ctx, &provider.Reference{Path: "/dir/sub"}); err != nil {
+		t.Fatalf("CreateDir failed: %v", err)
+	}
+	folderLock := &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "folder-lock", AppName: "app"}
+	if err := fs.SetLock(ctx, dir, folderLock); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+	child := &provider.Reference{Path: "/dir/sub"}
+	if err := fs.SetLock(ctx, child, &provider.Lock{Type: provider.LockType_LOCK_TYPE_WRITE, LockId: "wopi-lock", AppName: "Collabora"}); err != nil {
+		t.Fatalf("SetLock failed: %v", err)
+	}
+
+	if err := fs.Unlock(ctx, dir, folderLock); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if lock, err := fs.GetLock(ctx, child); err != nil || lock.LockId != "wopi-lock" {
+		t.Fatalf("the lock of the child was dropped with the folder lock: %v %v", lock, err)
+	}
+}
@@ -107,6 +107,12 @@ func (fs *localfs) InitiateUpload(ctx context.Context, ref *provider.Reference,
 		if _, ok := metadata["sizedeferred"]; ok {
 			info.SizeIsDeferred = true
 		}
+		if metadata[ctxpkg.LockIDHeader] != "" {
+			info.MetaData[ctxpkg.LockIDHeader] = metadata[ctxpkg.LockIDHeader]
+		}
+	}
+	if lockID, ok := ctxpkg.ContextGetLockID(ctx); ok && info.MetaData[ctxpkg.LockIDHeader] == "" {
+		info.MetaData[ctxpkg.LockIDHeader] = lockID
 	}
 
 	upload, err := fs.NewUpload(ctx, info)
@@ -153,6 +159,11 @@ func (fs *localfs) NewUpload(ctx context.Context, info tusd.FileInfo) (upload tu
 
 	log.Debug().Interface("info", info).Msg("localfs: resolved filename")
 
+	// check the lock of the file to be overwritten
+	if err := fs.checkLock(ctxpkg.ContextSetLockID(ctx, info.MetaData[ctxpkg.LockIDHeader]), np); err != nil {
+		return nil, err
+	}
+
 	info.ID = uuid.New().String()
 
 	binPath, err := fs.getUploadPath(ctx, info.ID)
@@ -317,6 +328,11 @@ func (upload *fileUpload) FinishUpload(ctx context.Context) error {
 	// the local storage does not track revisions
 	//}
 
+	// the file might have been locked while the upload was in progress
+	if err := upload.fs.checkLock(ctxpkg.ContextSetLockID(upload.ctx, upload.info.MetaData[ctxpkg.LockIDHeader]), np); err != nil {
+		return err
+	}
+
 	// if destination exists
 	if _, err := os.Stat(np); err == nil {
 		// create revision
@@ -0,0 +1,118 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package locks contains helpers shared by the storage drivers
+// implementing CS3 locks.
+package locks
+
+import (
+	"context"
+	"time"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/utils"
+)
+
+// Validate checks that the lock can be put on a resource.
+func Validate(lock *provider.Lock) error {
+	switch {
+	case lock == nil:
+		return errtypes.BadRequest("missing lock")
+	case lock.LockId == "":
+		return errtypes.BadRequest("missing lock id")
+	case lock.Type == provider.LockType_LOCK_TYPE_INVALID:
+		return errtypes.BadRequest("invalid lock type")
+	case IsExpired(lock):
+		return errtypes.BadRequest("lock already expired")
+	}
+	return nil
+}
+
+// IsExpired tells whether the expiration of the lock has passed.
+func IsExpired(lock *provider.Lock) bool {
+	return lock.Expiration != nil && !time.Now().Before(utils.TSToTime(lock.Expiration))
+}
+
+// SameHolder tells whether l2 is held by the user and app holding l1.
+func SameHolder(l1, l2 *provider.Lock) bool {
+	if l1.User != nil && !utils.UserEqual(l1.User, l2.User) {
+		return false
+	}
+	if l1.AppName != "" && l1.AppName != l2.AppName {
+		return false
+	}
+	return true
+}
+
+// CheckRefresh verifies that lock may replace the existing lock old. The id
+// of the lock changes when existingLockID is given.
+func CheckRefresh(old, lock *provider.Lock, existingLockID string) error {
+	lockID := lock.LockId
+	if existingLockID != "" {
+		lockID = existingLockID
+	}
+	if old.LockId != lockID {
+		return errtypes.Locked(old.LockId)
+	}
+	if old.Type != provider.LockType_LOCK_TYPE_SHARED && !SameHolder(old, lock) {
+		return errtypes.Locked(old.LockId)
+	}
+	return nil
+}
+
+// CheckUnlock verifies that lock may remove the existing lock old.
+func CheckUnlock(old, lock *provider.Lock) error {
+	if old.LockId != lock.GetLockId() {
+		return errtypes.Locked(old.LockId)
+	}
+	if old.Type != provider.LockType_LOCK_TYPE_SHARED && !SameHolder(old, lock) {
+		return errtypes.Locked(old.LockId)
+	}
+	return nil
+}
+
+// CheckWrite verifies that the lock id in the context permits modifying a
+// resource carrying the lock. Shared locks are advisory and never block a write.
+func CheckWrite(ctx context.Context, lock *provider.Lock) error {
+	if lock.Type == provider.LockType_LOCK_TYPE_SHARED {
+		return nil
+	}
+	return checkLockID(ctx, lock)
+}
+
+// CheckRead verifies that the lock id in the context permits reading a
+// resource carrying the lock. Only exclusive locks restrict read access and
+// the holder of the lock may always read, as downloads do not carry a lock id.
+func CheckRead(ctx context.Context, lock *provider.Lock) error {
+	if lock.Type != provider.LockType_LOCK_TYPE_EXCL {
+		return nil
+	}
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok && lock.User != nil && utils.UserEqual(lock.User, u.Id) {
+		return nil
+	}
+	return checkLockID(ctx, lock)
+}
+
+func checkLockID(ctx context.Context, lock *provider.Lock) error {
+	if lockID, ok := ctxpkg.ContextGetLockID(ctx); ok && lockID == lock.LockId {
+		return nil
+	}
+	return errtypes.Locked(lock.LockId)
+}
@@ -0,0 +1,68 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package locks
+
+import (
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+func TestCheckRefreshAndUnlock(t *testing.T) {
+	einstein := &userpb.UserId{OpaqueId: "einstein"}
+	marie := &userpb.UserId{OpaqueId: "marie"}
+	old := &provider.Lock{LockId: "lock-id", Type: provider.LockType_LOCK_TYPE_EXCL, User: einstein}
+
+	tests := []struct {
+		name           string
+		lock           *provider.Lock
+		existingLockID string
+		allowed        bool
+	}{
+		{"same lock", &provider.Lock{LockId: "lock-id", User: einstein}, "", true},
+		{"other lock id", &provider.Lock{LockId: "other", User: einstein}, "", false},
+		{"other holder", &provider.Lock{LockId: "lock-id", User: marie}, "", false},
+		{"changed lock id", &provider.Lock{LockId: "new-id", User: einstein}, "lock-id", true},
+		{"wrong existing id", &provider.Lock{LockId: "new-id", User: einstein}, "other", false},
+	}
+	for _, tt := range tests {
+		err := CheckRefresh(old, tt.lock, tt.existingLockID)
+		if tt.allowed && err != nil {
+			t.Errorf("%s: expected refresh to be allowed, got %v", tt.name, err)
+		}
+		if !tt.allowed {
+			if _, ok := err.(errtypes.Locked); !ok {
+				t.Errorf("%s: expected a Locked error, got %v", tt.name, err)
+			}
+		}
+	}
+
+	if err := CheckUnlock(old, &provider.Lock{LockId: "lock-id", User: einstein}); err != nil {
+		t.Errorf("expected unlock by the holder to be allowed, got %v", err)
+	}
+	if _, ok := CheckUnlock(old, &provider.Lock{LockId: "lock-id", User: marie}).(errtypes.Locked); !ok {
+		t.Error("expected unlock by another user to fail with Locked")
+	}
+	shared := &provider.Lock{LockId: "lock-id", Type: provider.LockType_LOCK_TYPE_SHARED, User: einstein}
+	if err := CheckUnlock(shared, &provider.Lock{LockId: "lock-id", User: marie}); err != nil {
+		t.Errorf("expected anyone knowing the id to remove a shared lock, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package mock
+
+import (
+	"context"
+	"io"
+	"os"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/storage/utils/uploader"
+)
+
+type mockUploader struct{}
+
+// NewUploader creates a mock uploader that implements the Uploader interface
+// supposed to be used for testing.
+func NewUploader() uploader.Uploader {
+	return &mockUploader{}
+}
+
+// Stat returns the info of a local file.
+func (m *mockUploader) Stat(ctx context.Context, path string) (*provider.ResourceInfo, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errtypes.NotFound(path)
+		}
+		return nil, err
+	}
+	t := provider.ResourceType_RESOURCE_TYPE_FILE
+	if info.IsDir() {
+		t = provider.ResourceType_RESOURCE_TYPE_CONTAINER
+	}
+	return &provider.ResourceInfo{
+		Path: path,
+		Type: t,
+		Size: uint64(info.Size()),
+	}, nil
+}
+
+// CreateFolder creates a local folder.
+func (m *mockUploader) CreateFolder(ctx context.Context, path string) error {
+	err := os.Mkdir(path, 0755)
+	if os.IsExist(err) {
+		return errtypes.AlreadyExists(path)
+	}
+	return err
+}
+
+// Upload copies the content read from r into a local file.
+func (m *mockUploader) Upload(ctx context.Context, path string, r io.Reader, length int64) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(f, r, length)
+	return err
+}
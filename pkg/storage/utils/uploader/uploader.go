@@ -0,0 +1,160 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/datagateway"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rhttp"
+)
+
+// Uploader is the interface implemented by the objects that are able to
+// create folders and upload files in a given path.
+type Uploader interface {
+	// Stat returns the info of the resource at the given path,
+	// or an errtypes.NotFound if it does not exist.
+	Stat(ctx context.Context, path string) (*provider.ResourceInfo, error)
+	// CreateFolder creates a folder at the given path.
+	CreateFolder(ctx context.Context, path string) error
+	// Upload uploads length bytes read from r at the given path.
+	Upload(ctx context.Context, path string, r io.Reader, length int64) error
+}
+
+type revaUploader struct {
+	gtw        gateway.GatewayAPIClient
+	httpClient *http.Client
+}
+
+// NewUploader creates an Uploader from the reva gateway.
+func NewUploader(gtw gateway.GatewayAPIClient, options ...rhttp.Option) Uploader {
+	return &revaUploader{
+		gtw:        gtw,
+		httpClient: rhttp.GetHTTPClient(options...),
+	}
+}
+
+func getUploadProtocol(protocols []*gateway.FileUploadProtocol, prot string) (*gateway.FileUploadProtocol, error) {
+	for _, p := range protocols {
+		if p.Protocol == prot {
+			return p, nil
+		}
+	}
+	return nil, errtypes.InternalError(fmt.Sprintf("protocol %s not supported for uploading", prot))
+}
+
+// Stat returns the info of the resource at the given path.
+func (u *revaUploader) Stat(ctx context.Context, path string) (*provider.ResourceInfo, error) {
+	res, err := u.gtw.Stat(ctx, &provider.StatRequest{
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	})
+
+	switch {
+	case err != nil:
+		return nil, err
+	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		return nil, errtypes.NotFound(path)
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return nil, errtypes.InternalError(res.Status.Message)
+	}
+	return res.Info, nil
+}
+
+// CreateFolder creates a folder at the given path.
+func (u *revaUploader) CreateFolder(ctx context.Context, path string) error {
+	res, err := u.gtw.CreateContainer(ctx, &provider.CreateContainerRequest{
+		Ref: &provider.Reference{
+			Path: path,
+		},
+	})
+
+	switch {
+	case err != nil:
+		return err
+	case res.Status.Code == rpc.Code_CODE_ALREADY_EXISTS:
+		return errtypes.AlreadyExists(path)
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return errtypes.InternalError(res.Status.Message)
+	}
+	return nil
+}
+
+// Upload uploads the content read from r at the given path.
+func (u *revaUploader) Upload(ctx context.Context, path string, r io.Reader, length int64) error {
+	upResp, err := u.gtw.InitiateFileUpload(ctx, &provider.InitiateFileUploadRequest{
+		Ref: &provider.Reference{
+			Path: path,
+		},
+		Opaque: &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				"Upload-Length": {
+					Decoder: "plain",
+					Value:   []byte(strconv.FormatInt(length, 10)),
+				},
+			},
+		},
+	})
+
+	switch {
+	case err != nil:
+		return err
+	case upResp.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(path)
+	case upResp.Status.Code != rpc.Code_CODE_OK:
+		return errtypes.InternalError(upResp.Status.Message)
+	}
+
+	p, err := getUploadProtocol(upResp.Protocols, "simple")
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := rhttp.NewRequest(ctx, http.MethodPut, p.UploadEndpoint, r)
+	if err != nil {
+		return err
+	}
+	httpReq.ContentLength = length
+	httpReq.Header.Set(datagateway.TokenTransportHeader, p.Token)
+
+	httpRes, err := u.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+
+	switch httpRes.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	case http.StatusForbidden:
+		return errtypes.PermissionDenied(path)
+	default:
+		return errtypes.InternalError(httpRes.Status)
+	}
+}
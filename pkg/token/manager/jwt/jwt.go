@@ -20,6 +20,8 @@ package jwt
 
 import (
 	"context"
+	"crypto"
+	"os"
 	"time"
 
 	auth "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
@@ -29,6 +31,7 @@ import (
 	"github.com/cs3org/reva/pkg/token"
 	"github.com/cs3org/reva/pkg/token/manager/registry"
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 )
@@ -43,10 +46,24 @@ type config struct {
 	Secret             string `mapstructure:"secret"`
 	Expires            int64  `mapstructure:"expires"`
 	ExpiresNextWeekend bool   `mapstructure:"expires_next_weekend"`
+
+	// SigningMethod is one of HS256, RS256, ES256 or EdDSA.
+	SigningMethod string `mapstructure:"signing_method"`
+	// PrivateKey is the path to the PEM encoded key used to sign tokens with the asymmetric methods.
+	// Tokens can only be verified if it is empty.
+	PrivateKey string `mapstructure:"private_key"`
+	// KeyID is sent in the kid header of the minted tokens.
+	KeyID string `mapstructure:"key_id"`
+	// PublicKeys maps key ids to the paths of the PEM encoded keys accepted when verifying tokens,
+	// keeping the previous keys here allows rotating them without invalidating the issued tokens.
+	PublicKeys map[string]string `mapstructure:"public_keys"`
 }
 
 type manager struct {
-	conf *config
+	conf       *config
+	method     jwt.SigningMethod
+	signingKey interface{}
+	publicKeys map[string]crypto.PublicKey
 }
 
 // claims are custom claims for the JWT token.
@@ -75,17 +92,57 @@ func New(value map[string]interface{}) (token.Manager, error) {
 	if c.Expires == 0 {
 		c.Expires = defaultExpiration
 	}
+	if c.SigningMethod == "" {
+		c.SigningMethod = "HS256"
+	}
 
-	c.Secret = sharedconf.GetJWTSecret(c.Secret)
+	m := &manager{conf: c, publicKeys: map[string]crypto.PublicKey{}}
+	if m.method = jwt.GetSigningMethod(c.SigningMethod); m.method == nil {
+		return nil, errors.New("jwt: unknown signing method " + c.SigningMethod)
+	}
 
-	if c.Secret == "" {
-		return nil, errors.New("jwt: secret for signing payloads is not defined in config")
+	if _, ok := m.method.(*jwt.SigningMethodHMAC); ok {
+		c.Secret = sharedconf.GetJWTSecret(c.Secret)
+		if c.Secret == "" {
+			return nil, errors.New("jwt: secret for signing payloads is not defined in config")
+		}
+		m.signingKey = []byte(c.Secret)
+		return m, nil
 	}
 
-	m := &manager{conf: c}
+	if c.PrivateKey != "" {
+		key, err := readPrivateKey(c.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKeyType(m.method, key.Public()); err != nil {
+			return nil, err
+		}
+		m.signingKey = key
+		m.publicKeys[c.KeyID] = key.Public()
+	}
+	for kid, path := range c.PublicKeys {
+		key, err := readPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkKeyType(m.method, key); err != nil {
+			return nil, err
+		}
+		m.publicKeys[kid] = key
+	}
+	if len(m.publicKeys) == 0 {
+		return nil, errors.New("jwt: neither a private nor public keys are defined in config")
+	}
 	return m, nil
 }
 
+// PublicKeys returns the keys accepted to verify tokens, by key id.
+// It is empty when the tokens are signed with a shared secret.
+func (m *manager) PublicKeys() map[string]crypto.PublicKey {
+	return m.publicKeys
+}
+
 func (m *manager) MintToken(ctx context.Context, u *user.User, scope map[string]*auth.Scope) (string, error) {
 	claims := claims{
 		StandardClaims: jwt.StandardClaims{
@@ -93,14 +150,22 @@ func (m *manager) MintToken(ctx context.Context, u *user.User, scope map[string]
 			Issuer:    u.Id.Idp,
 			Audience:  "reva",
 			IssuedAt:  time.Now().Unix(),
+			Id:        uuid.New().String(),
 		},
 		User:  u,
 		Scope: scope,
 	}
 
-	t := jwt.NewWithClaims(jwt.GetSigningMethod("HS256"), claims)
+	if m.signingKey == nil {
+		return "", errors.New("jwt: no private key configured, tokens can only be verified")
+	}
+
+	t := jwt.NewWithClaims(m.method, claims)
+	if m.conf.KeyID != "" {
+		t.Header["kid"] = m.conf.KeyID
+	}
 
-	tkn, err := t.SignedString([]byte(m.conf.Secret))
+	tkn, err := t.SignedString(m.signingKey)
 	if err != nil {
 		return "", errors.Wrapf(err, "error signing token with claims %+v", claims)
 	}
@@ -125,9 +190,7 @@ func setTime(t time.Time, hour, min, sec int) time.Time {
 }
 
 func (m *manager) DismantleToken(ctx context.Context, tkn string) (*user.User, map[string]*auth.Scope, error) {
-	token, err := jwt.ParseWithClaims(tkn, &claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(m.conf.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tkn, &claims{}, m.verificationKey)
 
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error parsing token")
@@ -139,3 +202,63 @@ func (m *manager) DismantleToken(ctx context.Context, tkn string) (*user.User, m
 
 	return nil, nil, errtypes.InvalidCredentials("invalid token")
 }
+
+// Inspect returns the id and validity of the token, it must have been verified before.
+func (m *manager) Inspect(tkn string) (*token.Info, error) {
+	c := &claims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tkn, c); err != nil {
+		return nil, errors.Wrap(err, "error parsing token")
+	}
+	return &token.Info{
+		ID:        c.Id,
+		IssuedAt:  time.Unix(c.IssuedAt, 0),
+		ExpiresAt: time.Unix(c.ExpiresAt, 0),
+	}, nil
+}
+
+// verificationKey returns the key to verify the token with, refusing tokens signed with another method
+// so that a public key can't be abused as a shared secret.
+func (m *manager) verificationKey(token *jwt.Token) (interface{}, error) {
+	if token.Method.Alg() != m.method.Alg() {
+		return nil, errors.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+	if _, ok := m.method.(*jwt.SigningMethodHMAC); ok {
+		return []byte(m.conf.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := m.publicKeys[kid]; ok {
+		return key, nil
+	}
+	// tokens minted before key ids were configured
+	if kid == "" && len(m.publicKeys) == 1 {
+		for _, key := range m.publicKeys {
+			return key, nil
+		}
+	}
+	return nil, errors.Errorf("unknown key id %q", kid)
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: error reading private key")
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "jwt: error parsing private key %s", path)
+	}
+	return key, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwt: error reading public key")
+	}
+	key, err := parsePublicKey(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "jwt: error parsing public key %s", path)
+	}
+	return key, nil
+}
@@ -19,8 +19,22 @@
 package jwt
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
+
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/token"
 )
 
 func TestGetNextWeekend(t *testing.T) {
@@ -87,3 +101,138 @@ func TestSetTi(t *testing.T) {
 		}
 	}
 }
+
+func writeKey(t *testing.T, dir, name string, key crypto.Signer) (string, string) {
+	t.Helper()
+	priv, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privPath, pubPath := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priv}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return privPath, pubPath
+}
+
+func newManager(t *testing.T, conf map[string]interface{}) token.Manager {
+	t.Helper()
+	m, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		method string
+		key    crypto.Signer
+	}{
+		{"RS256", rsaKey},
+		{"ES256", ecKey},
+		{"EdDSA", edKey},
+	}
+
+	u := &user.User{Id: &user.UserId{Idp: "idp", OpaqueId: "einstein"}, Username: "einstein"}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			priv, pub := writeKey(t, dir, tt.method, tt.key)
+			minter := newManager(t, map[string]interface{}{"signing_method": tt.method, "private_key": priv, "key_id": "current"})
+			verifier := newManager(t, map[string]interface{}{"signing_method": tt.method, "public_keys": map[string]string{"current": pub}})
+
+			tkn, err := minter.MintToken(context.Background(), u, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _, err := verifier.DismantleToken(context.Background(), tkn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Username != u.Username {
+				t.Fatalf("expected user %s, got %s", u.Username, got.Username)
+			}
+			info, err := verifier.(token.Inspector).Inspect(tkn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ID == "" || !info.ExpiresAt.After(time.Now()) {
+				t.Fatalf("unexpected token info %+v", info)
+			}
+
+			if _, err := verifier.MintToken(context.Background(), u, nil); err == nil {
+				t.Fatal("expected minting to fail without a private key")
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	newKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	unknownKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	oldPriv, oldPub := writeKey(t, dir, "old", oldKey)
+	newPriv, newPub := writeKey(t, dir, "new", newKey)
+	unknownPriv, _ := writeKey(t, dir, "unknown", unknownKey)
+
+	u := &user.User{Id: &user.UserId{Idp: "idp", OpaqueId: "einstein"}}
+	oldMinter := newManager(t, map[string]interface{}{"signing_method": "ES256", "private_key": oldPriv, "key_id": "old"})
+	newMinter := newManager(t, map[string]interface{}{"signing_method": "ES256", "private_key": newPriv, "key_id": "new"})
+	unknownMinter := newManager(t, map[string]interface{}{"signing_method": "ES256", "private_key": unknownPriv, "key_id": "new"})
+	verifier := newManager(t, map[string]interface{}{"signing_method": "ES256", "public_keys": map[string]string{"old": oldPub, "new": newPub}})
+
+	for _, m := range []token.Manager{oldMinter, newMinter} {
+		tkn, err := m.MintToken(context.Background(), u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := verifier.DismantleToken(context.Background(), tkn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tkn, err := unknownMinter.MintToken(context.Background(), u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := verifier.DismantleToken(context.Background(), tkn); err == nil {
+		t.Fatal("expected token signed with an unknown key to be rejected")
+	}
+}
+
+func TestRejectOtherSigningMethods(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, pub := writeKey(t, t.TempDir(), "rsa", rsaKey)
+	pubPEM, err := os.ReadFile(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a token signed with HS256 using the public key as secret must not be accepted
+	hmac := newManager(t, map[string]interface{}{"secret": string(pubPEM)})
+	tkn, err := hmac.MintToken(context.Background(), &user.User{Id: &user.UserId{OpaqueId: "einstein"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := newManager(t, map[string]interface{}{"signing_method": "RS256", "public_keys": map[string]string{"": pub}})
+	if _, _, err := verifier.DismantleToken(context.Background(), tkn); err == nil {
+		t.Fatal("expected token signed with HS256 to be rejected")
+	}
+
+	if _, err := New(map[string]interface{}{"signing_method": "ES256", "public_keys": map[string]string{"": pub}}); err == nil {
+		t.Fatal("expected rsa key to be refused for ES256")
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package session
+
+import (
+	"context"
+	"encoding/json"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// ServiceName is the name of the grpc service to manage the sessions, served by the gateway
+// next to the CS3 gateway API. Its messages are plain structs encoded as json.
+const ServiceName = "reva.sessions.v1.SessionsAPI"
+
+const codecName = "json"
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// ListSessionsRequest asks for the sessions of a user, those of the caller if User is nil.
+type ListSessionsRequest struct {
+	User *userpb.UserId `json:"user,omitempty"`
+}
+
+// ListSessionsResponse holds the sessions which have neither expired nor been revoked.
+type ListSessionsResponse struct {
+	Status   *rpc.Status `json:"status"`
+	Sessions []*Session  `json:"sessions"`
+}
+
+// RevokeSessionsRequest asks to revoke the session with the given id,
+// or all the sessions of a user if ID is empty. User defaults to the caller.
+type RevokeSessionsRequest struct {
+	ID   string         `json:"id,omitempty"`
+	User *userpb.UserId `json:"user,omitempty"`
+}
+
+// RevokeSessionsResponse reports the outcome of a revocation.
+type RevokeSessionsResponse struct {
+	Status *rpc.Status `json:"status"`
+}
+
+// SessionsAPIServer is the server API of the sessions service.
+type SessionsAPIServer interface {
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	RevokeSessions(context.Context, *RevokeSessionsRequest) (*RevokeSessionsResponse, error)
+}
+
+// SessionsAPIClient is the client API of the sessions service.
+type SessionsAPIClient interface {
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	RevokeSessions(ctx context.Context, in *RevokeSessionsRequest, opts ...grpc.CallOption) (*RevokeSessionsResponse, error)
+}
+
+// RegisterSessionsAPIServer registers the sessions service on the grpc server.
+func RegisterSessionsAPIServer(s *grpc.Server, srv SessionsAPIServer) {
+	s.RegisterService(&sessionsAPIServiceDesc, srv)
+}
+
+// NewSessionsAPIClient returns a client of the sessions service served on the connection.
+func NewSessionsAPIClient(cc grpc.ClientConnInterface) SessionsAPIClient {
+	return &sessionsAPIClient{cc: cc}
+}
+
+type sessionsAPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func (c *sessionsAPIClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	out := &ListSessionsResponse{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/ListSessions", in, out, withCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sessionsAPIClient) RevokeSessions(ctx context.Context, in *RevokeSessionsRequest, opts ...grpc.CallOption) (*RevokeSessionsResponse, error) {
+	out := &RevokeSessionsResponse{}
+	if err := c.cc.Invoke(ctx, "/"+ServiceName+"/RevokeSessions", in, out, withCodec(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func withCodec(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.CallContentSubtype(codecName)}, opts...)
+}
+
+var sessionsAPIServiceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*SessionsAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &ListSessionsRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SessionsAPIServer).ListSessions(ctx, req.(*ListSessionsRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/ListSessions"}, handler)
+			},
+		},
+		{
+			MethodName: "RevokeSessions",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := &RevokeSessionsRequest{}
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(SessionsAPIServer).RevokeSessions(ctx, req.(*RevokeSessionsRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ServiceName + "/RevokeSessions"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+// jsonCodec encodes the messages of the sessions service, which are no protobuf messages.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
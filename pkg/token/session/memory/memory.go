@@ -0,0 +1,150 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/cs3org/reva/pkg/token/session/registry"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("memory", New)
+}
+
+type config struct {
+	// Name identifies the store in the process, all the services
+	// configured with the same name share their sessions.
+	Name string `mapstructure:"name"`
+	// SingleProcess confirms that the gateway recording the sessions and all the
+	// auth interceptors checking the revocations run in this process.
+	SingleProcess bool `mapstructure:"single_process"`
+}
+
+func (c *config) init() {
+	if c.Name == "" {
+		c.Name = "default"
+	}
+}
+
+var (
+	mu     sync.Mutex
+	stores = map[string]*store{}
+)
+
+type store struct {
+	sync.RWMutex
+	sessions map[string]*session.Session
+	revoked  map[string]bool
+}
+
+// New returns the in-process session store with the configured name,
+// it can only be used when the tokens are minted and verified by the same process.
+func New(m map[string]interface{}) (session.Store, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+	c.init()
+
+	if !c.SingleProcess {
+		return nil, errors.New("memory: the sessions are not shared with other processes, use the sql or redis store " +
+			"or set single_process if the gateway and the auth interceptors run in the same process")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := stores[c.Name]
+	if !ok {
+		s = newStore()
+		stores[c.Name] = s
+	}
+	return s, nil
+}
+
+func newStore() *store {
+	return &store{
+		sessions: map[string]*session.Session{},
+		revoked:  map[string]bool{},
+	}
+}
+
+func (s *store) Add(ctx context.Context, sess *session.Session) error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for id, old := range s.sessions {
+		if now.After(old.ExpiresAt) {
+			delete(s.sessions, id)
+			delete(s.revoked, id)
+		}
+	}
+	s.sessions[sess.ID] = sess
+	return nil
+}
+
+func (s *store) Get(ctx context.Context, id string) (*session.Session, error) {
+	s.RLock()
+	defer s.RUnlock()
+	sess, ok := s.sessions[id]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return nil, errtypes.NotFound(id)
+	}
+	return sess, nil
+}
+
+func (s *store) List(ctx context.Context, u *userpb.UserId) ([]*session.Session, error) {
+	s.RLock()
+	defer s.RUnlock()
+	now := time.Now()
+	sessions := []*session.Session{}
+	for id, sess := range s.sessions {
+		if utils.UserEqual(sess.User, u) && !s.revoked[id] && now.Before(sess.ExpiresAt) {
+			sessions = append(sessions, sess)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].IssuedAt.Before(sessions[j].IssuedAt) })
+	return sessions, nil
+}
+
+func (s *store) Revoke(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return errtypes.NotFound(id)
+	}
+	s.revoked[id] = true
+	return nil
+}
+
+func (s *store) IsRevoked(ctx context.Context, id string) (bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+	return s.revoked[id], nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/session"
+)
+
+func TestRevoke(t *testing.T) {
+	ctx := context.Background()
+	s := newStore()
+
+	einstein := &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}
+	marie := &userpb.UserId{Idp: "idp", OpaqueId: "marie"}
+	now := time.Now()
+	for _, sess := range []*session.Session{
+		{ID: "1", User: einstein, IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", User: einstein, IssuedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", User: marie, IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "4", User: einstein, IssuedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	} {
+		if err := s.Add(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sessions, err := s.List(ctx, einstein)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "1" || sessions[1].ID != "2" {
+		t.Fatalf("expected sessions 1 and 2, got %v", sessions)
+	}
+
+	if err := s.Revoke(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := s.IsRevoked(ctx, "1"); !revoked {
+		t.Fatal("expected session 1 to be revoked")
+	}
+	if revoked, _ := s.IsRevoked(ctx, "2"); revoked {
+		t.Fatal("expected session 2 not to be revoked")
+	}
+	if sessions, _ := s.List(ctx, einstein); len(sessions) != 1 || sessions[0].ID != "2" {
+		t.Fatalf("expected session 2, got %v", sessions)
+	}
+
+	if err := s.Revoke(ctx, "4"); err == nil {
+		t.Fatal("expected expired session not to be found")
+	} else if _, ok := err.(errtypes.IsNotFound); !ok {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
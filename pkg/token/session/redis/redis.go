@@ -0,0 +1,189 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/cs3org/reva/pkg/token/session/registry"
+	"github.com/gomodule/redigo/redis"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("redis", New)
+}
+
+const (
+	sessionPrefix = "reva:session:"
+	userPrefix    = "reva:sessions:"
+	revokedPrefix = "reva:session-revoked:"
+)
+
+type config struct {
+	RedisAddress  string `mapstructure:"redis_address"`
+	RedisUsername string `mapstructure:"redis_username"`
+	RedisPassword string `mapstructure:"redis_password"`
+}
+
+type store struct {
+	redisPool *redis.Pool
+}
+
+// New returns a session store keeping the sessions in redis, they expire together with their tokens.
+func New(m map[string]interface{}) (session.Store, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+
+	if c.RedisAddress == "" {
+		c.RedisAddress = "localhost:6379"
+	}
+
+	pool := &redis.Pool{
+		MaxIdle:     50,
+		MaxActive:   1000,
+		IdleTimeout: 240 * time.Second,
+
+		Dial: func() (redis.Conn, error) {
+			var opts []redis.DialOption
+			if c.RedisUsername != "" {
+				opts = append(opts, redis.DialUsername(c.RedisUsername))
+			}
+			if c.RedisPassword != "" {
+				opts = append(opts, redis.DialPassword(c.RedisPassword))
+			}
+			return redis.Dial("tcp", c.RedisAddress, opts...)
+		},
+
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			_, err := c.Do("PING")
+			return err
+		},
+	}
+
+	return &store{redisPool: pool}, nil
+}
+
+func userKey(u *userpb.UserId) string {
+	return userPrefix + u.GetIdp() + ":" + u.GetOpaqueId()
+}
+
+// ttl returns the seconds until the session expires, at least one.
+func ttl(sess *session.Session) int64 {
+	if s := int64(time.Until(sess.ExpiresAt).Seconds()); s > 0 {
+		return s
+	}
+	return 1
+}
+
+func (s *store) Add(ctx context.Context, sess *session.Session) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Do("SET", sessionPrefix+sess.ID, value, "EX", ttl(sess)); err != nil {
+		return err
+	}
+	// the sessions of a user are indexed by expiration so that the expired ones can be dropped
+	if _, err := conn.Do("ZADD", userKey(sess.User), sess.ExpiresAt.Unix(), sess.ID); err != nil {
+		return err
+	}
+	_, err = conn.Do("ZREMRANGEBYSCORE", userKey(sess.User), "-inf", time.Now().Unix())
+	return err
+}
+
+func (s *store) Get(ctx context.Context, id string) (*session.Session, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+	return get(conn, id)
+}
+
+func get(conn redis.Conn, id string) (*session.Session, error) {
+	value, err := redis.Bytes(conn.Do("GET", sessionPrefix+id))
+	if err == redis.ErrNil {
+		return nil, errtypes.NotFound(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess := &session.Session{}
+	if err := json.Unmarshal(value, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func (s *store) List(ctx context.Context, u *userpb.UserId) ([]*session.Session, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	ids, err := redis.Strings(conn.Do("ZRANGEBYSCORE", userKey(u), time.Now().Unix(), "+inf"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := []*session.Session{}
+	for _, id := range ids {
+		revoked, err := redis.Bool(conn.Do("EXISTS", revokedPrefix+id))
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			continue
+		}
+		sess, err := get(conn, id)
+		if _, ok := err.(errtypes.IsNotFound); ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+func (s *store) Revoke(ctx context.Context, id string) error {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+
+	sess, err := get(conn, id)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Do("SET", revokedPrefix+id, 1, "EX", ttl(sess))
+	return err
+}
+
+func (s *store) IsRevoked(ctx context.Context, id string) (bool, error) {
+	conn := s.redisPool.Get()
+	defer conn.Close()
+	return redis.Bool(conn.Do("EXISTS", revokedPrefix+id))
+}
@@ -0,0 +1,139 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package session
+
+import (
+	"context"
+	"time"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token"
+	"github.com/pkg/errors"
+)
+
+// Session is a token minted for a user.
+type Session struct {
+	ID        string         `json:"id"`
+	User      *userpb.UserId `json:"user"`
+	IssuedAt  time.Time      `json:"issued_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// Store is the interface to implement to keep track of the sessions and of the revoked tokens.
+type Store interface {
+	// Add records the session of a newly minted token.
+	Add(ctx context.Context, s *Session) error
+	// Get returns the session with the given id, unless it expired.
+	Get(ctx context.Context, id string) (*Session, error)
+	// List returns the sessions of the user which have neither expired nor been revoked.
+	List(ctx context.Context, u *userpb.UserId) ([]*Session, error)
+	// Revoke denies the token of the session until it expires.
+	Revoke(ctx context.Context, id string) error
+	// IsRevoked tells whether the token of the session has been revoked.
+	IsRevoked(ctx context.Context, id string) (bool, error)
+}
+
+// FromToken returns the session of a token minted by the manager for the user,
+// nil if the manager does not assign ids to its tokens.
+func FromToken(mgr token.Manager, tkn string, u *userpb.UserId) (*Session, error) {
+	info, err := inspect(mgr, tkn)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	return &Session{
+		ID:        info.ID,
+		User:      u,
+		IssuedAt:  info.IssuedAt,
+		ExpiresAt: info.ExpiresAt,
+	}, nil
+}
+
+// Check returns an error if the token, already verified by the manager, has been revoked.
+// Tokens without an id can't be revoked and are always accepted.
+func Check(ctx context.Context, store Store, mgr token.Manager, tkn string) error {
+	if store == nil {
+		return nil
+	}
+	info, err := inspect(mgr, tkn)
+	if err != nil || info == nil {
+		return err
+	}
+	revoked, err := store.IsRevoked(ctx, info.ID)
+	if err != nil {
+		return errors.Wrap(err, "session: error checking revocation")
+	}
+	if revoked {
+		return errtypes.InvalidCredentials("token has been revoked")
+	}
+	return nil
+}
+
+func inspect(mgr token.Manager, tkn string) (*token.Info, error) {
+	i, ok := mgr.(token.Inspector)
+	if !ok {
+		return nil, nil
+	}
+	info, err := i.Inspect(tkn)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil || info.ID == "" {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// NewRecordingManager wraps the token manager to record the session of every token
+// it mints in the store. The manager is returned unchanged if the store is nil.
+func NewRecordingManager(mgr token.Manager, store Store) token.Manager {
+	if store == nil {
+		return mgr
+	}
+	return &recordingManager{Manager: mgr, store: store}
+}
+
+type recordingManager struct {
+	token.Manager
+	store Store
+}
+
+func (m *recordingManager) MintToken(ctx context.Context, u *userpb.User, scope map[string]*authpb.Scope) (string, error) {
+	tkn, err := m.Manager.MintToken(ctx, u, scope)
+	if err != nil {
+		return "", err
+	}
+	sess, err := FromToken(m.Manager, tkn, u.GetId())
+	if err == nil && sess != nil {
+		err = m.store.Add(ctx, sess)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "session: error recording session")
+	}
+	return tkn, nil
+}
+
+// Inspect passes the inspection on to the wrapped manager.
+func (m *recordingManager) Inspect(tkn string) (*token.Info, error) {
+	if i, ok := m.Manager.(token.Inspector); ok {
+		return i.Inspect(tkn)
+	}
+	return nil, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package session
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type fakeManager struct {
+	minted int
+}
+
+func (m *fakeManager) MintToken(ctx context.Context, u *userpb.User, scope map[string]*authpb.Scope) (string, error) {
+	m.minted++
+	return fmt.Sprintf("token-%d", m.minted), nil
+}
+
+func (m *fakeManager) DismantleToken(ctx context.Context, tkn string) (*userpb.User, map[string]*authpb.Scope, error) {
+	return nil, nil, nil
+}
+
+func (m *fakeManager) Inspect(tkn string) (*token.Info, error) {
+	return &token.Info{ID: tkn, IssuedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+type fakeStore struct {
+	Store
+	added []*Session
+}
+
+func (s *fakeStore) Add(ctx context.Context, sess *Session) error {
+	s.added = append(s.added, sess)
+	return nil
+}
+
+func TestRecordingManager(t *testing.T) {
+	mgr := &fakeManager{}
+	if NewRecordingManager(mgr, nil) != mgr {
+		t.Fatal("expected the manager to be returned unchanged without a store")
+	}
+
+	store := &fakeStore{}
+	recording := NewRecordingManager(mgr, store)
+	einstein := &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}}
+	for i := 0; i < 2; i++ {
+		if _, err := recording.MintToken(context.Background(), einstein, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(store.added) != 2 || store.added[0].ID != "token-1" || store.added[1].ID != "token-2" {
+		t.Fatalf("expected both tokens to be recorded, got %v", store.added)
+	}
+	if store.added[0].User.OpaqueId != "einstein" {
+		t.Fatalf("expected the session of einstein, got %v", store.added[0].User)
+	}
+	if _, ok := recording.(token.Inspector); !ok {
+		t.Fatal("expected the recording manager to inspect tokens")
+	}
+}
+
+type fakeServer struct {
+	revoked []string
+}
+
+func (s *fakeServer) ListSessions(ctx context.Context, req *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return &ListSessionsResponse{
+		Status:   &rpc.Status{Code: rpc.Code_CODE_OK},
+		Sessions: []*Session{{ID: "1", User: req.User}},
+	}, nil
+}
+
+func (s *fakeServer) RevokeSessions(ctx context.Context, req *RevokeSessionsRequest) (*RevokeSessionsResponse, error) {
+	s.revoked = append(s.revoked, req.ID)
+	return &RevokeSessionsResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+}
+
+func TestSessionsAPI(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeServer{}
+	s := grpc.NewServer()
+	RegisterSessionsAPIServer(s, srv)
+	go func() { _ = s.Serve(ln) }()
+	defer s.Stop()
+
+	conn, err := grpc.Dial(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewSessionsAPIClient(conn)
+
+	marie := &userpb.UserId{Idp: "idp", OpaqueId: "marie"}
+	list, err := client.ListSessions(context.Background(), &ListSessionsRequest{User: marie})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Status.Code != rpc.Code_CODE_OK || len(list.Sessions) != 1 || list.Sessions[0].User.OpaqueId != "marie" {
+		t.Fatalf("unexpected response %v", list)
+	}
+
+	revoke, err := client.RevokeSessions(context.Background(), &RevokeSessionsRequest{ID: "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoke.Status.Code != rpc.Code_CODE_NOT_FOUND || len(srv.revoked) != 1 || srv.revoked[0] != "2" {
+		t.Fatalf("unexpected response %v, revoked %v", revoke, srv.revoked)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package sql implements a session store using the table
+// token_sessions(id, user_idp, user_id, issued_at, expires_at, revoked),
+// where the times are unix timestamps.
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/session"
+	"github.com/cs3org/reva/pkg/token/session/registry"
+
+	// Provides mysql drivers.
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	registry.Register("sql", New)
+}
+
+type config struct {
+	DBUsername string `mapstructure:"db_username"`
+	DBPassword string `mapstructure:"db_password"`
+	DBHost     string `mapstructure:"db_host"`
+	DBPort     int    `mapstructure:"db_port"`
+	DBName     string `mapstructure:"db_name"`
+}
+
+type store struct {
+	db *sql.DB
+}
+
+// New returns a session store keeping the sessions in a sql database.
+func New(m map[string]interface{}) (session.Store, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "error decoding conf")
+	}
+
+	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	if err != nil {
+		return nil, err
+	}
+	return &store{db: db}, nil
+}
+
+func (s *store) Add(ctx context.Context, sess *session.Session) error {
+	// expired sessions are of no use anymore, revoked or not
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM token_sessions WHERE expires_at < ?", time.Now().Unix()); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "INSERT INTO token_sessions (id, user_idp, user_id, issued_at, expires_at, revoked) VALUES (?, ?, ?, ?, ?, ?)",
+		sess.ID, sess.User.GetIdp(), sess.User.GetOpaqueId(), sess.IssuedAt.Unix(), sess.ExpiresAt.Unix(), false)
+	return err
+}
+
+func (s *store) Get(ctx context.Context, id string) (*session.Session, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT id, user_idp, user_id, issued_at, expires_at FROM token_sessions WHERE id = ? AND expires_at >= ?", id, time.Now().Unix())
+	sess, err := scan(row)
+	if err == sql.ErrNoRows {
+		return nil, errtypes.NotFound(id)
+	}
+	return sess, err
+}
+
+func (s *store) List(ctx context.Context, u *userpb.UserId) ([]*session.Session, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, user_idp, user_id, issued_at, expires_at FROM token_sessions WHERE user_idp = ? AND user_id = ? AND revoked = ? AND expires_at >= ? ORDER BY issued_at",
+		u.GetIdp(), u.GetOpaqueId(), false, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*session.Session{}
+	for rows.Next() {
+		sess, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
+
+func (s *store) Revoke(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE token_sessions SET revoked = ? WHERE id = ? AND expires_at >= ?", true, id, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errtypes.NotFound(id)
+	}
+	return nil
+}
+
+func (s *store) IsRevoked(ctx context.Context, id string) (bool, error) {
+	var revoked bool
+	err := s.db.QueryRowContext(ctx, "SELECT revoked FROM token_sessions WHERE id = ?", id).Scan(&revoked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return revoked, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scan(row scanner) (*session.Session, error) {
+	var (
+		sess               = &session.Session{User: &userpb.UserId{}}
+		issued, expiration int64
+	)
+	if err := row.Scan(&sess.ID, &sess.User.Idp, &sess.User.OpaqueId, &issued, &expiration); err != nil {
+		return nil, err
+	}
+	sess.IssuedAt, sess.ExpiresAt = time.Unix(issued, 0), time.Unix(expiration, 0)
+	return sess, nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/token/session"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestRevoke(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE token_sessions (id VARCHAR(64) PRIMARY KEY, user_idp VARCHAR(255), user_id VARCHAR(255), issued_at BIGINT, expires_at BIGINT, revoked BOOLEAN)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	s := &store{db: db}
+	einstein := &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}
+	now := time.Now()
+	for _, sess := range []*session.Session{
+		{ID: "1", User: einstein, IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{ID: "2", User: einstein, IssuedAt: now.Add(time.Second), ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", User: &userpb.UserId{Idp: "idp", OpaqueId: "marie"}, IssuedAt: now, ExpiresAt: now.Add(time.Hour)},
+	} {
+		if err := s.Add(ctx, sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Revoke(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(ctx, "unknown"); err == nil {
+		t.Fatal("expected unknown session not to be found")
+	}
+
+	for id, expected := range map[string]bool{"1": true, "2": false, "unknown": false} {
+		revoked, err := s.IsRevoked(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if revoked != expected {
+			t.Errorf("session %s: expected revoked %t, got %t", id, expected, revoked)
+		}
+	}
+
+	sessions, err := s.List(ctx, einstein)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != "2" || sessions[0].User.OpaqueId != "einstein" {
+		t.Fatalf("expected session 2, got %v", sessions)
+	}
+}
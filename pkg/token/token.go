@@ -20,6 +20,8 @@ package token
 
 import (
 	"context"
+	"crypto"
+	"time"
 
 	auth "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
@@ -30,3 +32,22 @@ type Manager interface {
 	MintToken(ctx context.Context, u *user.User, scope map[string]*auth.Scope) (string, error)
 	DismantleToken(ctx context.Context, token string) (*user.User, map[string]*auth.Scope, error)
 }
+
+// KeySet is implemented by the token managers verifying tokens with public keys,
+// which can be published so that other services can validate the tokens.
+type KeySet interface {
+	PublicKeys() map[string]crypto.PublicKey
+}
+
+// Info holds the metadata of a minted token.
+type Info struct {
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// Inspector is implemented by the token managers assigning ids to their tokens.
+type Inspector interface {
+	// Inspect returns the metadata of the token without verifying it.
+	Inspect(token string) (*Info, error)
+}
@@ -19,6 +19,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"net"
@@ -132,6 +133,42 @@ func TSToTime(ts *types.Timestamp) time.Time {
 	return time.Unix(int64(ts.Seconds), int64(ts.Nanos))
 }
 
+// TSNow returns the current UTC timestamp.
+func TSNow() *types.Timestamp {
+	t := time.Now().UTC()
+	return &types.Timestamp{
+		Seconds: uint64(t.Unix()),
+		Nanos:   uint32(t.Nanosecond()),
+	}
+}
+
+// AppendJSONToOpaque stores the json encoding of v under the given key in the opaque.
+// A new opaque is created if o is nil. Values which can't be encoded are skipped.
+func AppendJSONToOpaque(o *types.Opaque, key string, v interface{}) *types.Opaque {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return o
+	}
+	if o == nil {
+		o = &types.Opaque{}
+	}
+	if o.Map == nil {
+		o.Map = map[string]*types.OpaqueEntry{}
+	}
+	o.Map[key] = &types.OpaqueEntry{Decoder: "json", Value: val}
+	return o
+}
+
+// ReadJSONFromOpaque decodes the json value stored under the given key in the opaque into v.
+// v is left untouched if the opaque doesn't carry the key.
+func ReadJSONFromOpaque(o *types.Opaque, key string, v interface{}) error {
+	entry, ok := o.GetMap()[key]
+	if !ok || entry.Decoder != "json" {
+		return nil
+	}
+	return json.Unmarshal(entry.Value, v)
+}
+
 // LaterTS returns the timestamp which occurs later.
 func LaterTS(t1 *types.Timestamp, t2 *types.Timestamp) *types.Timestamp {
 	if TSToUnixNano(t1) > TSToUnixNano(t2) {
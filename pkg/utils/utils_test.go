@@ -276,3 +276,21 @@ func TestHasPermissions(t *testing.T) {
 		})
 	}
 }
+
+func TestJSONOpaque(t *testing.T) {
+	id := &provider.ResourceId{StorageId: "storage", OpaqueId: "node"}
+	o := AppendJSONToOpaque(nil, "resourceid", id)
+
+	var got *provider.ResourceId
+	if err := ReadJSONFromOpaque(o, "resourceid", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.StorageId != id.StorageId || got.OpaqueId != id.OpaqueId {
+		t.Errorf("got %v, want %v", got, id)
+	}
+
+	var missing *provider.ResourceId
+	if err := ReadJSONFromOpaque(nil, "resourceid", &missing); err != nil || missing != nil {
+		t.Errorf("expected no value from a nil opaque, got %v, %v", missing, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018-2022 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// migrate-ocm-json imports the state of the json ocm share and invite managers
+// into the database used by their sql counterparts.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	invitesql "github.com/cs3org/reva/pkg/ocm/invite/manager/sql"
+	sharesql "github.com/cs3org/reva/pkg/ocm/share/manager/sql"
+)
+
+var (
+	sharesFile  = flag.String("shares", "", "json file of the ocm share manager: /var/tmp/reva/ocm-shares.json")
+	invitesFile = flag.String("invites", "", "json file of the ocm invite manager: /var/tmp/reva/ocm-invites.json")
+	dbEngine    = flag.String("db-engine", "mysql", "database engine: mysql or sqlite3")
+	dbFile      = flag.String("db-file", "", "database file when using sqlite3")
+	dbUsername  = flag.String("db-username", "", "database username")
+	dbPassword  = flag.String("db-password", "", "database password")
+	dbHost      = flag.String("db-host", "localhost", "database host")
+	dbPort      = flag.Int("db-port", 3306, "database port")
+	dbName      = flag.String("db-name", "", "database name")
+)
+
+func init() {
+	flag.Parse()
+
+	if *sharesFile == "" && *invitesFile == "" {
+		fmt.Fprintf(os.Stderr, "nothing to import: use the -shares and/or -invites flags\n")
+		os.Exit(1)
+	}
+	if *dbEngine == "sqlite3" && *dbFile == "" {
+		fmt.Fprintf(os.Stderr, "missing database file: use -db-file flag\n")
+		os.Exit(1)
+	}
+}
+
+func main() {
+	ctx := context.Background()
+	conf := map[string]interface{}{
+		"db_engine":   *dbEngine,
+		"db_file":     *dbFile,
+		"db_username": *dbUsername,
+		"db_password": *dbPassword,
+		"db_host":     *dbHost,
+		"db_port":     *dbPort,
+		"db_name":     *dbName,
+	}
+
+	if *sharesFile != "" {
+		n, err := sharesql.ImportJSON(ctx, conf, *sharesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error importing ocm shares: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d ocm shares from %s\n", n, *sharesFile)
+	}
+
+	if *invitesFile != "" {
+		n, err := invitesql.ImportJSON(ctx, conf, *invitesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error importing ocm invites: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %d ocm invite tokens and accepted users from %s\n", n, *invitesFile)
+	}
+}